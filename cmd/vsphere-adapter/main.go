@@ -20,13 +20,9 @@ import (
 	"github.com/vmware-tanzu/sources-for-knative/pkg/vsphere"
 )
 
-const (
-	adapterName = "vsphere-source-adapter"
-)
-
 func main() {
 	ctx := signals.NewContext()
 	kc := kubernetes.NewForConfigOrDie(injection.ParseAndGetRESTConfigOrDie())
 	ctx = context.WithValue(ctx, kubeclient.Key{}, kc)
-	adapter.MainWithContext(ctx, adapterName, vsphere.NewEnvConfig, vsphere.NewAdapter)
+	adapter.MainWithContext(ctx, vsphere.AdapterComponent, vsphere.NewEnvConfig, vsphere.NewAdapter)
 }
@@ -10,6 +10,8 @@ import (
 	"os"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	filteredinformerfactory "knative.dev/pkg/client/injection/kube/informers/factory/filtered"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/injection"
@@ -17,6 +19,7 @@ import (
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/metrics"
 	"knative.dev/pkg/signals"
+	tracingconfig "knative.dev/pkg/tracing/config"
 	"knative.dev/pkg/webhook"
 	"knative.dev/pkg/webhook/certificates"
 	"knative.dev/pkg/webhook/configmaps"
@@ -25,9 +28,11 @@ import (
 	"knative.dev/pkg/webhook/resourcesemantics/defaulting"
 	"knative.dev/pkg/webhook/resourcesemantics/validation"
 
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/config"
 	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
 	"github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspherebinding"
 	"github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspheresource"
+	"github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspheresource/resources"
 )
 
 var types = map[schema.GroupVersionKind]resourcesemantics.GenericCRD{
@@ -41,6 +46,10 @@ const (
 )
 
 func NewDefaultingAdmissionController(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
+	// the cluster-wide defaults of the VSphereSources
+	store := config.NewStore(logging.FromContext(ctx).Named("config-store"))
+	store.WatchConfigs(cmw)
+
 	return defaulting.NewAdmissionController(ctx,
 
 		// Name of the resource webhook.
@@ -53,11 +62,7 @@ func NewDefaultingAdmissionController(ctx context.Context, cmw configmap.Watcher
 		types,
 
 		// A function that infuses the context passed to Validate/SetDefaults with custom metadata.
-		func(ctx context.Context) context.Context {
-			// Here is where you would infuse the context with state
-			// (e.g. attach a store with configmap data)
-			return ctx
-		},
+		store.ToContext,
 
 		// Whether to disallow unknown fields.
 		true,
@@ -65,6 +70,10 @@ func NewDefaultingAdmissionController(ctx context.Context, cmw configmap.Watcher
 }
 
 func NewValidationAdmissionController(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
+	// checks that the keys referenced by the sources and bindings exist in
+	// their credentials secret, which is read from the API server
+	secrets := kubeclient.Get(ctx).CoreV1()
+
 	return validation.NewAdmissionController(ctx,
 
 		// Name of the resource webhook.
@@ -78,9 +87,7 @@ func NewValidationAdmissionController(ctx context.Context, cmw configmap.Watcher
 
 		// A function that infuses the context passed to Validate/SetDefaults with custom metadata.
 		func(ctx context.Context) context.Context {
-			// Here is where you would infuse the context with state
-			// (e.g. attach a store with configmap data)
-			return ctx
+			return v1alpha1.WithSecretsGetter(ctx, secrets)
 		},
 
 		// Whether to disallow unknown fields.
@@ -99,15 +106,17 @@ func NewConfigValidationController(ctx context.Context, cmw configmap.Watcher) *
 
 		// The configmaps to validate.
 		configmap.Constructors{
-			logging.ConfigMapName(): logging.NewConfigFromConfigMap,
-			metrics.ConfigMapName(): metrics.NewObservabilityConfigFromConfigMap,
+			logging.ConfigMapName():  logging.NewConfigFromConfigMap,
+			metrics.ConfigMapName():  metrics.NewObservabilityConfigFromConfigMap,
+			tracingconfig.ConfigName: tracingconfig.NewTracingConfigFromConfigMap,
+			config.VSphereConfigName: config.NewVSphereConfigFromConfigMap,
 		},
 	)
 }
 
 func NewVSphereBindingWebhook(opts ...psbinding.ReconcilerOption) injection.ControllerConstructor {
 	return func(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
-		return psbinding.NewAdmissionController(ctx,
+		impl := psbinding.NewAdmissionController(ctx,
 			// Name of the resource webhook.
 			"vspherebindings.webhook.vsphere.sources.tanzu.vmware.com",
 
@@ -125,6 +134,8 @@ func NewVSphereBindingWebhook(opts ...psbinding.ReconcilerOption) injection.Cont
 			},
 			opts...,
 		)
+		impl.Reconciler = vspherebinding.WithCronJobs(impl.Reconciler.(*psbinding.Reconciler))
+		return impl
 	}
 }
 
@@ -134,6 +145,9 @@ func main() {
 		Port:        8443,
 		SecretName:  "vsphere-webhook-certs",
 	})
+	// the source controller caches the adapter pods and the Leases of the
+	// sources only
+	ctx = filteredinformerfactory.WithSelectors(ctx, resources.NameLabelKey)
 
 	vsbSelector := psbinding.WithSelector(psbinding.ExclusionSelector)
 	if os.Getenv("VSPHERE_BINDING_SELECTION_MODE") == "inclusion" {
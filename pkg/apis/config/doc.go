@@ -0,0 +1,8 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package config holds the cluster-wide configuration of the VSphereSource
+// controller and webhook, which is read from the config-vsphere ConfigMap.
+package config
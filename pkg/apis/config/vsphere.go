@@ -0,0 +1,186 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"knative.dev/pkg/configmap"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/vsphere"
+)
+
+const (
+	// VSphereConfigName is the name of the ConfigMap holding the cluster-wide
+	// defaults of the VSphereSources.
+	VSphereConfigName = "config-vsphere"
+
+	defaultCheckpointPeriodKey     = "default-checkpoint-period"
+	defaultPayloadEncodingKey      = "default-payload-encoding"
+	defaultAdapterCPURequestKey    = "default-adapter-cpu-request"
+	defaultAdapterCPULimitKey      = "default-adapter-cpu-limit"
+	defaultAdapterMemoryRequestKey = "default-adapter-memory-request"
+	defaultAdapterMemoryLimitKey   = "default-adapter-memory-limit"
+	allowedVCenterHostsKey         = "allowed-vcenter-hosts"
+)
+
+// VSphere is the cluster-wide configuration of the VSphereSources. Its
+// defaults apply to the sources which do not set the corresponding fields.
+type VSphere struct {
+	// DefaultCheckpointPeriod is the default of
+	// spec.checkpointConfig.periodSeconds.
+	DefaultCheckpointPeriod time.Duration
+	// DefaultPayloadEncoding is the default of spec.payloadEncoding.
+	DefaultPayloadEncoding string
+	// DefaultAdapterResources are the default compute resources of the
+	// receive adapters. Only the quantities set in the ConfigMap are present.
+	DefaultAdapterResources corev1.ResourceRequirements
+	// AllowedVCenterHosts are the vCenter hosts the sources may connect to.
+	// All hosts are allowed when empty.
+	AllowedVCenterHosts sets.String
+}
+
+// NewVSphereConfig returns the configuration used when the ConfigMap does not
+// set a value.
+func NewVSphereConfig() *VSphere {
+	return &VSphere{
+		DefaultCheckpointPeriod: vsphere.CheckpointDefaultPeriod,
+		// preserve backward-compatibility
+		DefaultPayloadEncoding: cloudevents.ApplicationXML,
+	}
+}
+
+// NewVSphereConfigFromConfigMap parses and validates the given ConfigMap.
+func NewVSphereConfigFromConfigMap(cm *corev1.ConfigMap) (*VSphere, error) {
+	cfg := NewVSphereConfig()
+
+	var cpuRequest, cpuLimit, memoryRequest, memoryLimit *resource.Quantity
+	var hosts sets.String
+	if err := configmap.Parse(cm.Data,
+		configmap.AsDuration(defaultCheckpointPeriodKey, &cfg.DefaultCheckpointPeriod),
+		configmap.AsString(defaultPayloadEncodingKey, &cfg.DefaultPayloadEncoding),
+		configmap.AsQuantity(defaultAdapterCPURequestKey, &cpuRequest),
+		configmap.AsQuantity(defaultAdapterCPULimitKey, &cpuLimit),
+		configmap.AsQuantity(defaultAdapterMemoryRequestKey, &memoryRequest),
+		configmap.AsQuantity(defaultAdapterMemoryLimitKey, &memoryLimit),
+		configmap.AsStringSet(allowedVCenterHostsKey, &hosts),
+	); err != nil {
+		return nil, err
+	}
+
+	if cfg.DefaultCheckpointPeriod < time.Second {
+		return nil, fmt.Errorf("%s must be at least 1s, got %s", defaultCheckpointPeriodKey, cfg.DefaultCheckpointPeriod)
+	}
+
+	cfg.DefaultPayloadEncoding = strings.ToLower(cfg.DefaultPayloadEncoding)
+	if e := cfg.DefaultPayloadEncoding; e != cloudevents.ApplicationXML && e != cloudevents.ApplicationJSON {
+		return nil, fmt.Errorf("%s must be %q or %q, got %q", defaultPayloadEncodingKey,
+			cloudevents.ApplicationXML, cloudevents.ApplicationJSON, e)
+	}
+
+	for _, q := range []struct {
+		quantity *resource.Quantity
+		name     corev1.ResourceName
+		list     *corev1.ResourceList
+	}{
+		{cpuRequest, corev1.ResourceCPU, &cfg.DefaultAdapterResources.Requests},
+		{cpuLimit, corev1.ResourceCPU, &cfg.DefaultAdapterResources.Limits},
+		{memoryRequest, corev1.ResourceMemory, &cfg.DefaultAdapterResources.Requests},
+		{memoryLimit, corev1.ResourceMemory, &cfg.DefaultAdapterResources.Limits},
+	} {
+		if q.quantity == nil {
+			continue
+		}
+		if *q.list == nil {
+			*q.list = corev1.ResourceList{}
+		}
+		(*q.list)[q.name] = *q.quantity
+	}
+
+	for name, request := range cfg.DefaultAdapterResources.Requests {
+		if limit, ok := cfg.DefaultAdapterResources.Limits[name]; ok && request.Cmp(limit) > 0 {
+			return nil, fmt.Errorf("default adapter %s request %s exceeds the limit %s", name, request.String(), limit.String())
+		}
+	}
+
+	// hosts are matched case-insensitively, empty entries of a trailing comma
+	// are ignored
+	for _, host := range hosts.UnsortedList() {
+		if host == "" {
+			continue
+		}
+		if cfg.AllowedVCenterHosts == nil {
+			cfg.AllowedVCenterHosts = sets.NewString()
+		}
+		cfg.AllowedVCenterHosts.Insert(strings.ToLower(host))
+	}
+
+	return cfg, nil
+}
+
+// VCenterHostAllowed returns true if the sources may connect to the vCenter
+// with the given host name, which must not include a port.
+func (v *VSphere) VCenterHostAllowed(host string) bool {
+	return v.AllowedVCenterHosts.Len() == 0 || v.AllowedVCenterHosts.Has(strings.ToLower(host))
+}
+
+type cfgKey struct{}
+
+// ToContext attaches the given configuration to the context.
+func ToContext(ctx context.Context, cfg *VSphere) context.Context {
+	return context.WithValue(ctx, cfgKey{}, cfg)
+}
+
+// FromContextOrDefaults returns the configuration attached to the context, or
+// the default configuration if none is attached.
+func FromContextOrDefaults(ctx context.Context) *VSphere {
+	if cfg, ok := ctx.Value(cfgKey{}).(*VSphere); ok && cfg != nil {
+		return cfg
+	}
+	return NewVSphereConfig()
+}
+
+// Store is a typed wrapper around configmap.UntypedStore for the
+// config-vsphere ConfigMap.
+type Store struct {
+	*configmap.UntypedStore
+}
+
+// NewStore returns a Store watching the config-vsphere ConfigMap once
+// WatchConfigs is called.
+func NewStore(logger configmap.Logger, onAfterStore ...func(name string, value interface{})) *Store {
+	return &Store{
+		UntypedStore: configmap.NewUntypedStore(
+			"vsphere",
+			logger,
+			configmap.Constructors{
+				VSphereConfigName: NewVSphereConfigFromConfigMap,
+			},
+			onAfterStore...,
+		),
+	}
+}
+
+// ToContext attaches the current configuration to the context.
+func (s *Store) ToContext(ctx context.Context) context.Context {
+	return ToContext(ctx, s.Load())
+}
+
+// Load returns the current configuration, or the default configuration if the
+// ConfigMap has not been loaded yet.
+func (s *Store) Load() *VSphere {
+	if cfg, ok := s.UntypedLoad(VSphereConfigName).(*VSphere); ok && cfg != nil {
+		return cfg
+	}
+	return NewVSphereConfig()
+}
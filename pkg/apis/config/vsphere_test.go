@@ -0,0 +1,129 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/vsphere"
+)
+
+func TestNewVSphereConfigFromConfigMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    *VSphere
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			want: &VSphere{
+				DefaultCheckpointPeriod: vsphere.CheckpointDefaultPeriod,
+				DefaultPayloadEncoding:  cloudevents.ApplicationXML,
+			},
+		},
+		{
+			name: "all set",
+			data: map[string]string{
+				"_example":                       "ignored",
+				"default-checkpoint-period":      "1m",
+				"default-payload-encoding":       "Application/JSON",
+				"default-adapter-cpu-request":    "100m",
+				"default-adapter-cpu-limit":      "1",
+				"default-adapter-memory-request": "64Mi",
+				"default-adapter-memory-limit":   "256Mi",
+				"allowed-vcenter-hosts":          "VCenter.example.com, standby.example.com,",
+			},
+			want: &VSphere{
+				DefaultCheckpointPeriod: time.Minute,
+				DefaultPayloadEncoding:  cloudevents.ApplicationJSON,
+				DefaultAdapterResources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("100m"),
+						corev1.ResourceMemory: resource.MustParse("64Mi"),
+					},
+					Limits: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("1"),
+						corev1.ResourceMemory: resource.MustParse("256Mi"),
+					},
+				},
+				AllowedVCenterHosts: sets.NewString("vcenter.example.com", "standby.example.com"),
+			},
+		},
+		{
+			name:    "invalid checkpoint period",
+			data:    map[string]string{"default-checkpoint-period": "10"},
+			wantErr: true,
+		},
+		{
+			name:    "checkpoint period too short",
+			data:    map[string]string{"default-checkpoint-period": "500ms"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid payload encoding",
+			data:    map[string]string{"default-payload-encoding": "text/plain"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid quantity",
+			data:    map[string]string{"default-adapter-cpu-request": "lots"},
+			wantErr: true,
+		},
+		{
+			name: "request exceeds limit",
+			data: map[string]string{
+				"default-adapter-memory-request": "1Gi",
+				"default-adapter-memory-limit":   "256Mi",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewVSphereConfigFromConfigMap(&corev1.ConfigMap{Data: tt.data})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewVSphereConfigFromConfigMap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("NewVSphereConfigFromConfigMap() (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestVCenterHostAllowed(t *testing.T) {
+	if !NewVSphereConfig().VCenterHostAllowed("vcenter.example.com") {
+		t.Error("VCenterHostAllowed() without allowed hosts = false, want true")
+	}
+
+	cfg := &VSphere{AllowedVCenterHosts: sets.NewString("vcenter.example.com")}
+	if !cfg.VCenterHostAllowed("VCENTER.example.com") {
+		t.Error("VCenterHostAllowed() of allowed host = false, want true")
+	}
+	if cfg.VCenterHostAllowed("other.example.com") {
+		t.Error("VCenterHostAllowed() of other host = true, want false")
+	}
+}
+
+func TestFromContextOrDefaults(t *testing.T) {
+	if diff := cmp.Diff(NewVSphereConfig(), FromContextOrDefaults(context.Background())); diff != "" {
+		t.Errorf("FromContextOrDefaults() without config (-want, +got) = %v", diff)
+	}
+
+	cfg := &VSphere{DefaultCheckpointPeriod: time.Minute}
+	if got := FromContextOrDefaults(ToContext(context.Background(), cfg)); got != cfg {
+		t.Errorf("FromContextOrDefaults() = %v, want %v", got, cfg)
+	}
+}
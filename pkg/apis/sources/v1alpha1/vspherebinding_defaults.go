@@ -7,6 +7,8 @@ package v1alpha1
 
 import (
 	"context"
+
+	corev1 "k8s.io/api/core/v1"
 )
 
 // SetDefaults implements apis.Defaultable
@@ -15,4 +17,18 @@ func (vsb *VSphereBinding) SetDefaults(ctx context.Context) {
 		// Default the subject's namespace to our namespace.
 		vsb.Spec.Subject.Namespace = vsb.Namespace
 	}
+	vsb.Spec.VAuthSpec.SetDefaults(ctx)
+}
+
+// SetDefaults implements apis.Defaultable
+func (vas *VAuthSpec) SetDefaults(ctx context.Context) {
+	if vas.UsernameKey == "" {
+		vas.UsernameKey = corev1.BasicAuthUsernameKey
+	}
+	if vas.PasswordKey == "" {
+		vas.PasswordKey = corev1.BasicAuthPasswordKey
+	}
+	if vas.Projection == "" {
+		vas.Projection = CredentialsProjectionEnv
+	}
 }
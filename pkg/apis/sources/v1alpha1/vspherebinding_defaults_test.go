@@ -10,11 +10,21 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	duckv1alpha1 "knative.dev/pkg/apis/duck/v1alpha1"
 	"knative.dev/pkg/tracker"
 )
 
+// defaultedVAuthSpec is validVAuthSpec with the default secret keys.
+var defaultedVAuthSpec = func() VAuthSpec {
+	vas := validVAuthSpec
+	vas.UsernameKey = corev1.BasicAuthUsernameKey
+	vas.PasswordKey = corev1.BasicAuthPasswordKey
+	vas.Projection = CredentialsProjectionEnv
+	return vas
+}()
+
 func TestVSphereBindingDefaulting(t *testing.T) {
 	tests := []struct {
 		name string
@@ -37,7 +47,7 @@ func TestVSphereBindingDefaulting(t *testing.T) {
 			},
 			Spec: VSphereBindingSpec{
 				BindingSpec: validBindingSpec,
-				VAuthSpec:   validVAuthSpec,
+				VAuthSpec:   defaultedVAuthSpec,
 			},
 		},
 	}, {
@@ -72,7 +82,41 @@ func TestVSphereBindingDefaulting(t *testing.T) {
 						Namespace:  "with-namespace",
 					},
 				},
-				VAuthSpec: validVAuthSpec,
+				VAuthSpec: defaultedVAuthSpec,
+			},
+		},
+	}, {
+		name: "configured secret keys and projection",
+		c: &VSphereBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereBindingSpec{
+				BindingSpec: validBindingSpec,
+				VAuthSpec: VAuthSpec{
+					Address:     validVAuthSpec.Address,
+					SecretRef:   validVAuthSpec.SecretRef,
+					UsernameKey: "user",
+					PasswordKey: "pass",
+					CACertKey:   "ca.crt",
+					Projection:  CredentialsProjectionVolume,
+				},
+			},
+		},
+		want: &VSphereBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereBindingSpec{
+				BindingSpec: validBindingSpec,
+				VAuthSpec: VAuthSpec{
+					Address:     validVAuthSpec.Address,
+					SecretRef:   validVAuthSpec.SecretRef,
+					UsernameKey: "user",
+					PasswordKey: "pass",
+					CACertKey:   "ca.crt",
+					Projection:  CredentialsProjectionVolume,
+				},
 			},
 		},
 	}}
@@ -8,6 +8,7 @@ package v1alpha1
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -55,7 +56,8 @@ func (sbs *VSphereBindingStatus) InitializeConditions() {
 // MarkBindingUnavailable marks the VSphereBinding's Ready condition to False with
 // the provided reason and message.
 func (sbs *VSphereBindingStatus) MarkBindingUnavailable(reason, message string) {
-	vsbCondSet.Manage(sbs).MarkFalse(VSphereBindingConditionReady, reason, message)
+	// the message is an error message, not a format string
+	vsbCondSet.Manage(sbs).MarkFalse(VSphereBindingConditionReady, reason, "%s", message)
 }
 
 // MarkBindingAvailable marks the VSphereBinding's Ready condition to True.
@@ -68,6 +70,10 @@ func (vsb *VSphereBinding) Do(ctx context.Context, ps *duckv1.WithPod) {
 	// First undo so that we can just unconditionally append below.
 	vsb.Undo(ctx, ps)
 
+	// The binding might have been created before the keys were defaulted.
+	auth := vsb.Spec.VAuthSpec
+	auth.SetDefaults(ctx)
+
 	// Make sure the PodSpec has a Volume like this:
 	volume := corev1.Volume{
 		Name: vsphere.VolumeName,
@@ -86,86 +92,69 @@ func (vsb *VSphereBinding) Do(ctx context.Context, ps *duckv1.WithPod) {
 		MountPath: vsphere.DefaultMountPath,
 	}
 
-	spec := ps.Spec.Template.Spec
-	for i := range spec.InitContainers {
-		spec.InitContainers[i].VolumeMounts = append(spec.InitContainers[i].VolumeMounts, volumeMount)
-		spec.InitContainers[i].Env = append(spec.InitContainers[i].Env, corev1.EnvVar{
-			Name:  "VC_URL",
-			Value: vsb.Spec.Address.String(),
-		}, corev1.EnvVar{
-			Name:  "VC_INSECURE",
-			Value: fmt.Sprintf("%v", vsb.Spec.SkipTLSVerify),
-		}, corev1.EnvVar{
-			Name: "VC_USERNAME",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: vsb.Spec.SecretRef.Name,
-					},
-					Key: corev1.BasicAuthUsernameKey,
-				},
-			},
-		}, corev1.EnvVar{
-			Name: "VC_PASSWORD",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: vsb.Spec.SecretRef.Name,
-					},
-					Key: corev1.BasicAuthPasswordKey,
+	// If a CA bundle is configured, also project its key as ca.crt with a
+	// Volume and a VolumeMount for each [init]container.
+	var caBundleMount *corev1.VolumeMount
+	if cab := vsb.Spec.CABundle; cab != nil {
+		ps.Spec.Template.Spec.Volumes = append(ps.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: vsphere.CABundleVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: cab.LocalObjectReference,
+					Items: []corev1.KeyToPath{{
+						Key:  cab.Key,
+						Path: vsphere.CACertsKey,
+					}},
+					Optional: cab.Optional,
 				},
 			},
 		})
+		caBundleMount = &corev1.VolumeMount{
+			Name:      vsphere.CABundleVolumeName,
+			ReadOnly:  true,
+			MountPath: vsphere.CABundleMountPath,
+		}
+	}
+
+	spec := ps.Spec.Template.Spec
+	for i := range spec.InitContainers {
+		spec.InitContainers[i].VolumeMounts = append(spec.InitContainers[i].VolumeMounts, volumeMount)
+		spec.InitContainers[i].Env = append(spec.InitContainers[i].Env, auth.bindingEnv()...)
+		if caBundleMount != nil {
+			spec.InitContainers[i].VolumeMounts = append(spec.InitContainers[i].VolumeMounts, *caBundleMount)
+			spec.InitContainers[i].Env = append(spec.InitContainers[i].Env, corev1.EnvVar{
+				Name:  "VC_CA_CERT_PATH",
+				Value: filepath.Join(vsphere.CABundleMountPath, vsphere.CACertsKey),
+			})
+		}
 	}
 	for i := range spec.Containers {
 		spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts, volumeMount)
-		spec.Containers[i].Env = append(spec.Containers[i].Env, corev1.EnvVar{
-			Name:  "VC_URL",
-			Value: vsb.Spec.Address.String(),
-		}, corev1.EnvVar{
-			Name:  "VC_INSECURE",
-			Value: fmt.Sprintf("%v", vsb.Spec.SkipTLSVerify),
-		}, corev1.EnvVar{
-			Name: "VC_USERNAME",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: vsb.Spec.SecretRef.Name,
-					},
-					Key: corev1.BasicAuthUsernameKey,
-				},
-			},
-		}, corev1.EnvVar{
-			Name: "VC_PASSWORD",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: vsb.Spec.SecretRef.Name,
-					},
-					Key: corev1.BasicAuthPasswordKey,
-				},
-			},
-		})
+		spec.Containers[i].Env = append(spec.Containers[i].Env, auth.bindingEnv()...)
+		if caBundleMount != nil {
+			spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts, *caBundleMount)
+			spec.Containers[i].Env = append(spec.Containers[i].Env, corev1.EnvVar{
+				Name:  "VC_CA_CERT_PATH",
+				Value: filepath.Join(vsphere.CABundleMountPath, vsphere.CACertsKey),
+			})
+		}
 	}
 }
 
 func (vsb *VSphereBinding) Undo(ctx context.Context, ps *duckv1.WithPod) {
 	spec := ps.Spec.Template.Spec
 
-	for i, v := range spec.Volumes {
-		if v.Name == vsphere.VolumeName {
-			ps.Spec.Template.Spec.Volumes = append(spec.Volumes[:i], spec.Volumes[i+1:]...)
-			break
+	for _, name := range bindingVolumes {
+		for i, v := range ps.Spec.Template.Spec.Volumes {
+			if v.Name == name {
+				ps.Spec.Template.Spec.Volumes = append(ps.Spec.Template.Spec.Volumes[:i], ps.Spec.Template.Spec.Volumes[i+1:]...)
+				break
+			}
 		}
 	}
 
 	for i, c := range spec.InitContainers {
-		for j, vm := range c.VolumeMounts {
-			if vm.Name == vsphere.VolumeName {
-				spec.InitContainers[i].VolumeMounts = append(c.VolumeMounts[:j], c.VolumeMounts[j+1:]...)
-				break
-			}
-		}
+		spec.InitContainers[i].VolumeMounts = removeBindingVolumeMounts(c.VolumeMounts)
 
 		if len(c.Env) == 0 {
 			continue
@@ -173,7 +162,8 @@ func (vsb *VSphereBinding) Undo(ctx context.Context, ps *duckv1.WithPod) {
 		env := make([]corev1.EnvVar, 0, len(spec.InitContainers[i].Env))
 		for j, ev := range c.Env {
 			switch ev.Name {
-			case "VC_URL", "VC_INSECURE", "VC_USERNAME", "VC_PASSWORD":
+			case "VC_URL", "VC_INSECURE", "VC_USERNAME", "VC_PASSWORD", "VC_USERNAME_KEY", "VC_PASSWORD_KEY",
+				"VC_CA_CERT_KEY", "VC_CA_CERT_PATH", "VC_CREDENTIALS_DIR":
 				continue
 			default:
 				env = append(env, spec.InitContainers[i].Env[j])
@@ -182,12 +172,7 @@ func (vsb *VSphereBinding) Undo(ctx context.Context, ps *duckv1.WithPod) {
 		spec.InitContainers[i].Env = env
 	}
 	for i, c := range spec.Containers {
-		for j, vm := range c.VolumeMounts {
-			if vm.Name == vsphere.VolumeName {
-				spec.Containers[i].VolumeMounts = append(c.VolumeMounts[:j], c.VolumeMounts[j+1:]...)
-				break
-			}
-		}
+		spec.Containers[i].VolumeMounts = removeBindingVolumeMounts(c.VolumeMounts)
 
 		if len(c.Env) == 0 {
 			continue
@@ -195,7 +180,8 @@ func (vsb *VSphereBinding) Undo(ctx context.Context, ps *duckv1.WithPod) {
 		env := make([]corev1.EnvVar, 0, len(spec.Containers[i].Env))
 		for j, ev := range c.Env {
 			switch ev.Name {
-			case "VC_URL", "VC_INSECURE", "VC_USERNAME", "VC_PASSWORD":
+			case "VC_URL", "VC_INSECURE", "VC_USERNAME", "VC_PASSWORD", "VC_USERNAME_KEY", "VC_PASSWORD_KEY",
+				"VC_CA_CERT_KEY", "VC_CA_CERT_PATH", "VC_CREDENTIALS_DIR":
 				continue
 			default:
 				env = append(env, spec.Containers[i].Env[j])
@@ -204,3 +190,70 @@ func (vsb *VSphereBinding) Undo(ctx context.Context, ps *duckv1.WithPod) {
 		spec.Containers[i].Env = env
 	}
 }
+
+// bindingEnv returns the environment variables with the address and the
+// credentials of the binding. With the volume projection, only the directory
+// of the mounted secret is set instead of the credentials. The keys of the
+// credentials are only passed on when they are not the default keys, so that
+// bound workloads are not changed when the keys are defaulted.
+func (vas *VAuthSpec) bindingEnv() []corev1.EnvVar {
+	env := []corev1.EnvVar{{
+		Name:  "VC_URL",
+		Value: vas.Address.String(),
+	}, {
+		Name:  "VC_INSECURE",
+		Value: fmt.Sprintf("%v", vas.SkipTLSVerify),
+	}}
+
+	if vas.Projection == CredentialsProjectionVolume {
+		env = append(env, corev1.EnvVar{
+			Name:  "VC_CREDENTIALS_DIR",
+			Value: vsphere.DefaultMountPath,
+		})
+	} else {
+		env = append(env, corev1.EnvVar{
+			Name: "VC_USERNAME",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: vas.SecretRef,
+					Key:                  vas.UsernameKey,
+				},
+			},
+		}, corev1.EnvVar{
+			Name: "VC_PASSWORD",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: vas.SecretRef,
+					Key:                  vas.PasswordKey,
+				},
+			},
+		})
+	}
+
+	if vas.UsernameKey != corev1.BasicAuthUsernameKey {
+		env = append(env, corev1.EnvVar{Name: "VC_USERNAME_KEY", Value: vas.UsernameKey})
+	}
+	if vas.PasswordKey != corev1.BasicAuthPasswordKey {
+		env = append(env, corev1.EnvVar{Name: "VC_PASSWORD_KEY", Value: vas.PasswordKey})
+	}
+	if vas.CACertKey != "" {
+		env = append(env, corev1.EnvVar{Name: "VC_CA_CERT_KEY", Value: vas.CACertKey})
+	}
+	return env
+}
+
+// bindingVolumes are the names of the volumes projected by the binding.
+var bindingVolumes = []string{vsphere.VolumeName, vsphere.CABundleVolumeName}
+
+// removeBindingVolumeMounts removes the mounts of the binding volumes.
+func removeBindingVolumeMounts(mounts []corev1.VolumeMount) []corev1.VolumeMount {
+	for _, name := range bindingVolumes {
+		for j, vm := range mounts {
+			if vm.Name == name {
+				mounts = append(mounts[:j], mounts[j+1:]...)
+				break
+			}
+		}
+	}
+	return mounts
+}
@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"knative.dev/pkg/apis"
@@ -627,6 +628,247 @@ func TestVSphereBindingDo(t *testing.T) {
 	}
 }
 
+func TestVSphereBindingDoCABundle(t *testing.T) {
+	vsb := &VSphereBinding{
+		Spec: VSphereBindingSpec{
+			VAuthSpec: VAuthSpec{
+				Address: apis.URL{
+					Scheme: "https",
+					Host:   "vcenter.corp.local",
+				},
+				SecretRef: corev1.LocalObjectReference{
+					Name: "vsphere-credentials",
+				},
+				CABundle: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: "corp-ca",
+					},
+					Key: "ca-bundle.pem",
+				},
+			},
+		},
+	}
+
+	in := &duckv1.WithPod{
+		Spec: duckv1.WithPodSpec{
+			Template: duckv1.PodSpecable{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "blah",
+						Image: "busybox",
+					}},
+				},
+			},
+		},
+	}
+	got := in.DeepCopy()
+
+	ctx := context.Background()
+	vsb.Do(ctx, got)
+	// binding twice must not duplicate the CA bundle
+	vsb.Do(ctx, got)
+
+	wantVolume := corev1.Volume{
+		Name: vsphere.CABundleVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: "corp-ca",
+				},
+				Items: []corev1.KeyToPath{{
+					Key:  "ca-bundle.pem",
+					Path: vsphere.CACertsKey,
+				}},
+			},
+		},
+	}
+	spec := got.Spec.Template.Spec
+	if len(spec.Volumes) != 2 {
+		t.Fatalf("Do() volumes = %v, want 2", spec.Volumes)
+	}
+	if diff := cmp.Diff(wantVolume, spec.Volumes[1]); diff != "" {
+		t.Errorf("Do() CA bundle volume (-want, +got): %s", diff)
+	}
+
+	wantMount := corev1.VolumeMount{
+		Name:      vsphere.CABundleVolumeName,
+		ReadOnly:  true,
+		MountPath: vsphere.CABundleMountPath,
+	}
+	if mounts := spec.Containers[0].VolumeMounts; len(mounts) != 2 || mounts[1] != wantMount {
+		t.Errorf("Do() volume mounts = %v, want CA bundle mount %v", mounts, wantMount)
+	}
+
+	var caCertPath []string
+	for _, env := range spec.Containers[0].Env {
+		if env.Name == "VC_CA_CERT_PATH" {
+			caCertPath = append(caCertPath, env.Value)
+		}
+	}
+	if want := []string{vsphere.CABundleMountPath + "/" + vsphere.CACertsKey}; !cmp.Equal(caCertPath, want) {
+		t.Errorf("Do() VC_CA_CERT_PATH = %v, want %v", caCertPath, want)
+	}
+
+	vsb.Undo(ctx, got)
+	if diff := cmp.Diff(in, got, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("Undo (-want, +got): %s", diff)
+	}
+}
+
+func TestVSphereBindingDoSecretKeys(t *testing.T) {
+	vsb := &VSphereBinding{
+		Spec: VSphereBindingSpec{
+			VAuthSpec: VAuthSpec{
+				Address: apis.URL{
+					Scheme: "https",
+					Host:   "vcenter.corp.local",
+				},
+				SecretRef: corev1.LocalObjectReference{
+					Name: "vsphere-credentials",
+				},
+				UsernameKey: "user",
+				PasswordKey: "pass",
+				CACertKey:   "ca.pem",
+			},
+		},
+	}
+
+	in := &duckv1.WithPod{
+		Spec: duckv1.WithPodSpec{
+			Template: duckv1.PodSpecable{
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{{
+						Name:  "setup",
+						Image: "busybox",
+					}},
+					Containers: []corev1.Container{{
+						Name:  "blah",
+						Image: "busybox",
+					}},
+				},
+			},
+		},
+	}
+	got := in.DeepCopy()
+
+	ctx := context.Background()
+	vsb.Do(ctx, got)
+
+	secretRef := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "vsphere-credentials"},
+				Key:                  key,
+			},
+		}
+	}
+	want := []corev1.EnvVar{
+		{Name: "VC_URL", Value: "https://vcenter.corp.local"},
+		{Name: "VC_INSECURE", Value: "false"},
+		{Name: "VC_USERNAME", ValueFrom: secretRef("user")},
+		{Name: "VC_PASSWORD", ValueFrom: secretRef("pass")},
+		{Name: "VC_USERNAME_KEY", Value: "user"},
+		{Name: "VC_PASSWORD_KEY", Value: "pass"},
+		{Name: "VC_CA_CERT_KEY", Value: "ca.pem"},
+	}
+	spec := got.Spec.Template.Spec
+	for _, c := range append(spec.InitContainers, spec.Containers...) {
+		if diff := cmp.Diff(want, c.Env); diff != "" {
+			t.Errorf("Do() container %q env (-want, +got): %s", c.Name, diff)
+		}
+	}
+
+	vsb.Undo(ctx, got)
+	if diff := cmp.Diff(in, got, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("Undo (-want, +got): %s", diff)
+	}
+}
+
+func TestVSphereBindingDoVolumeProjection(t *testing.T) {
+	vsb := &VSphereBinding{
+		Spec: VSphereBindingSpec{
+			VAuthSpec: VAuthSpec{
+				Address: apis.URL{
+					Scheme: "https",
+					Host:   "vcenter.corp.local",
+				},
+				SecretRef: corev1.LocalObjectReference{
+					Name: "vsphere-credentials",
+				},
+				Projection: CredentialsProjectionVolume,
+			},
+		},
+	}
+
+	in := &duckv1.WithPod{
+		Spec: duckv1.WithPodSpec{
+			Template: duckv1.PodSpecable{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "blah",
+						Image: "busybox",
+						Env: []corev1.EnvVar{{
+							Name:  "FOO",
+							Value: "bar",
+						}},
+					}},
+				},
+			},
+		},
+	}
+	got := in.DeepCopy()
+
+	ctx := context.Background()
+	vsb.Do(ctx, got)
+
+	want := &duckv1.WithPod{
+		Spec: duckv1.WithPodSpec{
+			Template: duckv1.PodSpecable{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "blah",
+						Image: "busybox",
+						Env: []corev1.EnvVar{{
+							Name:  "FOO",
+							Value: "bar",
+						}, {
+							Name:  "VC_URL",
+							Value: "https://vcenter.corp.local",
+						}, {
+							Name:  "VC_INSECURE",
+							Value: "false",
+						}, {
+							Name:  "VC_CREDENTIALS_DIR",
+							Value: vsphere.DefaultMountPath,
+						}},
+						VolumeMounts: []corev1.VolumeMount{{
+							Name:      vsphere.VolumeName,
+							ReadOnly:  true,
+							MountPath: vsphere.DefaultMountPath,
+						}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: vsphere.VolumeName,
+						VolumeSource: corev1.VolumeSource{
+							Secret: &corev1.SecretVolumeSource{
+								SecretName: "vsphere-credentials",
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Do (-want, +got): %s", diff)
+	}
+
+	vsb.Undo(ctx, got)
+	if diff := cmp.Diff(in, got, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("Undo (-want, +got): %s", diff)
+	}
+}
+
 func TestTypicalBindingFlow(t *testing.T) {
 	r := &VSphereBindingStatus{}
 	r.InitializeConditions()
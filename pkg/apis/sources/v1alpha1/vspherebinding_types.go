@@ -51,15 +51,59 @@ type VAuthSpec struct {
 	Address apis.URL `json:"address"`
 
 	// SkipTLSVerify specifies whether the client should skip TLS verification when
-	// talking to the vsphere address.
+	// talking to the vsphere address. This is unsafe and must not be used in
+	// production environments.
 	SkipTLSVerify bool `json:"skipTLSVerify,omitempty"`
 
 	// SecretRef is a reference to a Kubernetes secret of type kubernetes.io/basic-auth
 	// which contains keys for "username" and "password", which will be used to authenticate
-	//  with the vSphere API at "address".
+	//  with the vSphere API at "address". The keys can be changed with
+	// usernameKey and passwordKey.
 	SecretRef corev1.LocalObjectReference `json:"secretRef"`
+
+	// UsernameKey is the key of the username in the secret. Defaults to
+	// "username".
+	// +optional
+	UsernameKey string `json:"usernameKey,omitempty"`
+
+	// PasswordKey is the key of the password in the secret. Defaults to
+	// "password".
+	// +optional
+	PasswordKey string `json:"passwordKey,omitempty"`
+
+	// CACertKey is the key of a PEM encoded CA bundle in the secret, which is
+	// trusted when talking to the vsphere address. No CA bundle is read from
+	// the secret when empty.
+	// +optional
+	CACertKey string `json:"caCertKey,omitempty"`
+
+	// Projection is how a VSphereBinding projects the credentials into the
+	// bound containers: env sets VC_USERNAME and VC_PASSWORD, volume only
+	// sets VC_CREDENTIALS_DIR to the directory the secret is mounted at.
+	// Defaults to env.
+	// +optional
+	Projection CredentialsProjection `json:"projection,omitempty"`
+
+	// CABundle is a reference to a key of a ConfigMap holding a PEM encoded CA
+	// bundle, which is trusted when talking to the vsphere address, e.g. when
+	// vCenter uses a certificate issued by an internal CA.
+	// +optional
+	CABundle *corev1.ConfigMapKeySelector `json:"caBundle,omitempty"`
 }
 
+// CredentialsProjection is how the credentials are projected into the bound
+// containers.
+type CredentialsProjection string
+
+const (
+	// CredentialsProjectionEnv sets the credentials as environment variables
+	// in addition to mounting the secret.
+	CredentialsProjectionEnv CredentialsProjection = "env"
+	// CredentialsProjectionVolume only mounts the secret, so that the
+	// credentials do not appear in the environment of the containers.
+	CredentialsProjectionVolume CredentialsProjection = "volume"
+)
+
 const (
 	// VSphereBindingConditionReady is configured to indicate whether the Binding
 	// has been configured for resources subject to its runtime contract.
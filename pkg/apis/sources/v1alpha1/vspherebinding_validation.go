@@ -7,13 +7,23 @@ package v1alpha1
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	"knative.dev/pkg/apis"
 )
 
 // Validate implements apis.Validatable
 func (vsb *VSphereBinding) Validate(ctx context.Context) *apis.FieldError {
 	err := vsb.Spec.Validate(ctx).ViaField("spec")
+	var original *VAuthSpec
+	if apis.IsInUpdate(ctx) {
+		original = &apis.GetBaseline(ctx).(*VSphereBinding).Spec.VAuthSpec
+	}
+	err = err.Also(validateSecretKeysExist(ctx, vsb.Namespace, &vsb.Spec.VAuthSpec, original).ViaField("spec"))
 	if vsb.Spec.Subject.Namespace != "" && vsb.Namespace != vsb.Spec.Subject.Namespace {
 		err = err.Also(apis.ErrInvalidValue(vsb.Spec.Subject.Namespace, "spec.subject.namespace"))
 	}
@@ -33,5 +43,93 @@ func (vas *VAuthSpec) Validate(ctx context.Context) (err *apis.FieldError) {
 	if vas.SecretRef.Name == "" {
 		err = err.Also(apis.ErrMissingField("secretRef.name"))
 	}
+	if vas.CABundle != nil {
+		if vas.CABundle.Name == "" {
+			err = err.Also(apis.ErrMissingField("caBundle.name"))
+		}
+		if vas.CABundle.Key == "" {
+			err = err.Also(apis.ErrMissingField("caBundle.key"))
+		}
+	}
+	switch vas.Projection {
+	case "", CredentialsProjectionEnv, CredentialsProjectionVolume:
+	default:
+		err = err.Also(apis.ErrInvalidValue(vas.Projection, "projection"))
+	}
+	err = err.Also(validateSecretKey(vas.UsernameKey, "usernameKey")).
+		Also(validateSecretKey(vas.PasswordKey, "passwordKey")).
+		Also(validateSecretKey(vas.CACertKey, "caCertKey"))
 	return err
 }
+
+type secretsGetterKey struct{}
+
+// WithSecretsGetter attaches a SecretsGetter to the context, which the
+// validation uses to check that the keys referenced by a VAuthSpec exist in
+// its secret. The secret is read from the API server rather than a cache, so
+// that a recently created or updated secret is not rejected.
+func WithSecretsGetter(ctx context.Context, getter corev1client.SecretsGetter) context.Context {
+	return context.WithValue(ctx, secretsGetterKey{}, getter)
+}
+
+// validateSecretKeysExist returns an error if the secret of the given
+// VAuthSpec exists but lacks one of the referenced keys. The secret is only
+// checked when a SecretsGetter is attached to the context and, on updates, when
+// the secret or its keys changed compared to the original VAuthSpec, so that
+// later changes to the secret do not block updates of the resource. A missing
+// secret is not an error since it may be created after the resource.
+func validateSecretKeysExist(ctx context.Context, namespace string, vas, original *VAuthSpec) *apis.FieldError {
+	getter, ok := ctx.Value(secretsGetterKey{}).(corev1client.SecretsGetter)
+	if !ok || vas.SecretRef.Name == "" || apis.IsInStatusUpdate(ctx) {
+		return nil
+	}
+
+	auth := vas.DeepCopy()
+	auth.SetDefaults(ctx)
+	if original != nil {
+		orig := original.DeepCopy()
+		orig.SetDefaults(ctx)
+		if orig.SecretRef == auth.SecretRef && orig.UsernameKey == auth.UsernameKey &&
+			orig.PasswordKey == auth.PasswordKey && orig.CACertKey == auth.CACertKey {
+			return nil
+		}
+	}
+
+	secret, err := getter.Secrets(namespace).Get(ctx, auth.SecretRef.Name, metav1.GetOptions{})
+	if err != nil {
+		// the secret may not exist yet, the adapter reports missing keys then
+		return nil
+	}
+
+	var errs *apis.FieldError
+	for _, k := range []struct{ key, field string }{
+		{auth.UsernameKey, "usernameKey"},
+		{auth.PasswordKey, "passwordKey"},
+		{auth.CACertKey, "caCertKey"},
+	} {
+		if k.key == "" {
+			continue
+		}
+		if _, ok := secret.Data[k.key]; !ok {
+			errs = errs.Also(apis.ErrInvalidValue(k.key, k.field,
+				fmt.Sprintf("key does not exist in secret %q", auth.SecretRef.Name)))
+		}
+	}
+	return errs
+}
+
+// validateSecretKey returns an error if the given key of the secret is blank
+// or not a valid secret key. An empty key is valid, it defaults to the
+// current key name.
+func validateSecretKey(key, field string) *apis.FieldError {
+	if key == "" {
+		return nil
+	}
+	if strings.TrimSpace(key) == "" {
+		return apis.ErrInvalidValue(key, field, "key must not be blank")
+	}
+	if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+		return apis.ErrInvalidValue(key, field, strings.Join(errs, ", "))
+	}
+	return nil
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 var (
@@ -115,6 +116,128 @@ func TestVSphereBindingValidation(t *testing.T) {
 			},
 		},
 		want: apis.ErrMissingField("spec.address.host"),
+	}, {
+		name: "valid CA bundle",
+		c: &VSphereBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "valid",
+				Namespace: validBindingSpec.Subject.Namespace,
+			},
+			Spec: VSphereBindingSpec{
+				BindingSpec: validBindingSpec,
+				VAuthSpec: VAuthSpec{
+					Address:   validVAuthSpec.Address,
+					SecretRef: validVAuthSpec.SecretRef,
+					CABundle: &corev1.ConfigMapKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{
+							Name: "corp-ca",
+						},
+						Key: "ca.crt",
+					},
+				},
+			},
+		},
+		want: nil,
+	}, {
+		name: "CA bundle without key",
+		c: &VSphereBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "valid",
+				Namespace: validBindingSpec.Subject.Namespace,
+			},
+			Spec: VSphereBindingSpec{
+				BindingSpec: validBindingSpec,
+				VAuthSpec: VAuthSpec{
+					Address:   validVAuthSpec.Address,
+					SecretRef: validVAuthSpec.SecretRef,
+					CABundle: &corev1.ConfigMapKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{
+							Name: "corp-ca",
+						},
+					},
+				},
+			},
+		},
+		want: apis.ErrMissingField("spec.caBundle.key"),
+	}, {
+		name: "configured secret keys",
+		c: &VSphereBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "valid",
+				Namespace: validBindingSpec.Subject.Namespace,
+			},
+			Spec: VSphereBindingSpec{
+				BindingSpec: validBindingSpec,
+				VAuthSpec: VAuthSpec{
+					Address:     validVAuthSpec.Address,
+					SecretRef:   validVAuthSpec.SecretRef,
+					UsernameKey: "user",
+					PasswordKey: "pass",
+					CACertKey:   "ca.crt",
+				},
+			},
+		},
+		want: nil,
+	}, {
+		name: "invalid projection",
+		c: &VSphereBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "valid",
+				Namespace: validBindingSpec.Subject.Namespace,
+			},
+			Spec: VSphereBindingSpec{
+				BindingSpec: validBindingSpec,
+				VAuthSpec: VAuthSpec{
+					Address:    validVAuthSpec.Address,
+					SecretRef:  validVAuthSpec.SecretRef,
+					Projection: "file",
+				},
+			},
+		},
+		want: apis.ErrInvalidValue("file", "spec.projection"),
+	}, {
+		name: "blank and invalid secret keys",
+		c: &VSphereBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "valid",
+				Namespace: validBindingSpec.Subject.Namespace,
+			},
+			Spec: VSphereBindingSpec{
+				BindingSpec: validBindingSpec,
+				VAuthSpec: VAuthSpec{
+					Address:     validVAuthSpec.Address,
+					SecretRef:   validVAuthSpec.SecretRef,
+					UsernameKey: " ",
+					PasswordKey: "pass",
+					CACertKey:   "certs/ca.crt",
+				},
+			},
+		},
+		want: apis.ErrInvalidValue(" ", "spec.usernameKey", "key must not be blank").
+			Also(apis.ErrInvalidValue("certs/ca.crt", "spec.caCertKey",
+				"a valid config key must consist of alphanumeric characters, '-', '_' or '.' (e.g. 'key.name',  or 'KEY_NAME',  or 'key-name', regex used for validation is '[-._a-zA-Z0-9]+')")),
+	}, {
+		name: "Job subject",
+		c: &VSphereBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "valid",
+				Namespace: validBindingSpec.Subject.Namespace,
+			},
+			Spec: VSphereBindingSpec{
+				BindingSpec: duckv1alpha1.BindingSpec{
+					Subject: tracker.Reference{
+						APIVersion: "batch/v1",
+						Kind:       "Job",
+						Namespace:  validBindingSpec.Subject.Namespace,
+						Selector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"app": "nightly-govc"},
+						},
+					},
+				},
+				VAuthSpec: validVAuthSpec,
+			},
+		},
+		want: nil,
 	}}
 
 	for _, test := range tests {
@@ -127,3 +250,92 @@ func TestVSphereBindingValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestVSphereBindingValidateSecretKeysExist(t *testing.T) {
+	kc := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "super-duper-secret", Namespace: "knobots"},
+		Data: map[string][]byte{
+			"username": []byte("user"),
+			"password": []byte("pass"),
+			"user":     []byte("user"),
+			"pass":     []byte("pass"),
+		},
+	})
+	ctx := WithSecretsGetter(context.Background(), kc.CoreV1())
+
+	newBinding := func(secret, usernameKey, passwordKey string) *VSphereBinding {
+		auth := validVAuthSpec
+		auth.SecretRef.Name = secret
+		auth.UsernameKey = usernameKey
+		auth.PasswordKey = passwordKey
+		return &VSphereBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "binding", Namespace: "knobots"},
+			Spec: VSphereBindingSpec{
+				BindingSpec: validBindingSpec,
+				VAuthSpec:   auth,
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		binding  *VSphereBinding
+		original *VSphereBinding
+		want     *apis.FieldError
+	}{
+		{
+			name:    "default keys exist",
+			ctx:     ctx,
+			binding: newBinding("super-duper-secret", "", ""),
+		},
+		{
+			name:    "custom keys exist",
+			ctx:     ctx,
+			binding: newBinding("super-duper-secret", "user", "pass"),
+		},
+		{
+			name:    "custom keys missing",
+			ctx:     ctx,
+			binding: newBinding("super-duper-secret", "login", "secret"),
+			want: apis.ErrInvalidValue("login", "spec.usernameKey", `key does not exist in secret "super-duper-secret"`).
+				Also(apis.ErrInvalidValue("secret", "spec.passwordKey", `key does not exist in secret "super-duper-secret"`)),
+		},
+		{
+			name:    "secret does not exist yet",
+			ctx:     ctx,
+			binding: newBinding("other-secret", "login", "secret"),
+		},
+		{
+			name:    "no secrets getter",
+			ctx:     context.Background(),
+			binding: newBinding("super-duper-secret", "login", "secret"),
+		},
+		{
+			name:     "keys unchanged on update",
+			ctx:      ctx,
+			binding:  newBinding("super-duper-secret", "login", "secret"),
+			original: newBinding("super-duper-secret", "login", "secret"),
+		},
+		{
+			name:     "keys changed on update",
+			ctx:      ctx,
+			binding:  newBinding("super-duper-secret", "user", "secret"),
+			original: newBinding("super-duper-secret", "login", "secret"),
+			want:     apis.ErrInvalidValue("secret", "spec.passwordKey", `key does not exist in secret "super-duper-secret"`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if tt.original != nil {
+				ctx = apis.WithinUpdate(ctx, tt.original)
+			}
+
+			got := tt.binding.Validate(ctx)
+			if diff := cmp.Diff(tt.want.Error(), got.Error()); diff != "" {
+				t.Errorf("Validate() (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
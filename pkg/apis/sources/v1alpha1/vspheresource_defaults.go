@@ -9,9 +9,9 @@ import (
 	"context"
 	"strings"
 
-	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"knative.dev/pkg/apis"
 
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/config"
 	"github.com/vmware-tanzu/sources-for-knative/pkg/vsphere"
 )
 
@@ -19,17 +19,79 @@ import (
 func (vs *VSphereSource) SetDefaults(ctx context.Context) {
 	withNS := apis.WithinParent(ctx, vs.ObjectMeta)
 	vs.Spec.Sink.SetDefaults(withNS)
+	vs.Spec.VAuthSpec.SetDefaults(ctx)
+	for i := range vs.Spec.Sinks {
+		vs.Spec.Sinks[i].SetDefaults(withNS)
+	}
+	if vs.Spec.DeadLetterSink != nil {
+		vs.Spec.DeadLetterSink.SetDefaults(withNS)
+	}
+
+	// cluster-wide defaults of the config-vsphere ConfigMap
+	cfg := config.FromContextOrDefaults(ctx)
 
 	// only checking period, setting maxAge to 0 will disable event replay
 	// to get at-most-once semantics
 	if vs.Spec.CheckpointConfig.PeriodSeconds == 0 {
-		vs.Spec.CheckpointConfig.PeriodSeconds = int64(vsphere.CheckpointDefaultPeriod.Seconds())
+		vs.Spec.CheckpointConfig.PeriodSeconds = int64(cfg.DefaultCheckpointPeriod.Seconds())
+	}
+
+	// existing sources have a checkpoint, which takes precedence
+	if vs.Spec.StartFrom == "" {
+		vs.Spec.StartFrom = StartFromNow
+	}
+
+	if vs.Spec.PollIntervalSeconds == 0 {
+		vs.Spec.PollIntervalSeconds = int64(vsphere.DefaultPollInterval.Seconds())
+	}
+
+	// store the effective CloudEvent source, which otherwise defaults to the
+	// vCenter host in the adapter
+	if vs.Spec.CloudEventSource == "" && vs.Spec.Address.Host != "" {
+		vs.Spec.CloudEventSource = vs.Spec.Address.Host
+	}
+	// a CloudEvent source defaulted from the previous address follows it
+	if apis.IsInUpdate(ctx) {
+		baseline := apis.GetBaseline(ctx).(*VSphereSource)
+		if oldHost := baseline.Spec.Address.Host; oldHost != vs.Spec.Address.Host &&
+			baseline.Spec.CloudEventSource == oldHost && vs.Spec.CloudEventSource == oldHost {
+			vs.Spec.CloudEventSource = vs.Spec.Address.Host
+		}
+	}
+
+	if vs.Spec.SinkTimeoutSeconds == 0 {
+		vs.Spec.SinkTimeoutSeconds = int64(vsphere.DefaultSinkTimeout.Seconds())
+	}
+
+	if vs.Spec.Retry != nil {
+		vs.Spec.Retry.SetDefaults(ctx)
+	}
+
+	if vs.Spec.RateLimit != nil {
+		vs.Spec.RateLimit.SetDefaults(ctx)
 	}
 
-	// preserve backward-compatibility
 	if vs.Spec.PayloadEncoding == "" {
-		vs.Spec.PayloadEncoding = cloudevents.ApplicationXML
+		vs.Spec.PayloadEncoding = cfg.DefaultPayloadEncoding
 	} else {
 		vs.Spec.PayloadEncoding = strings.ToLower(vs.Spec.PayloadEncoding)
 	}
 }
+
+// SetDefaults implements apis.Defaultable
+func (rs *RetrySpec) SetDefaults(ctx context.Context) {
+	if rs.InitialBackoffSeconds == 0 {
+		rs.InitialBackoffSeconds = int64(vsphere.DefaultRetryBackoff.Seconds())
+	}
+
+	if rs.BackoffPolicy == "" {
+		rs.BackoffPolicy = BackoffPolicyExponential
+	}
+}
+
+// SetDefaults implements apis.Defaultable
+func (rls *RateLimitSpec) SetDefaults(ctx context.Context) {
+	if rls.Burst == 0 {
+		rls.Burst = rls.EventsPerSecond
+	}
+}
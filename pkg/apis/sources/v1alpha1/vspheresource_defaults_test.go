@@ -8,12 +8,15 @@ package v1alpha1
 import (
 	"context"
 	"testing"
+	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"github.com/google/go-cmp/cmp"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
 
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/config"
 	"github.com/vmware-tanzu/sources-for-knative/pkg/vsphere"
 )
 
@@ -34,17 +37,51 @@ func TestVSphereSourceDefaulting(t *testing.T) {
 			},
 		},
 		want: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec: validSourceSpec,
+				VAuthSpec:  defaultedVAuthSpec,
+				CheckpointConfig: VCheckpointSpec{
+					MaxAgeSeconds: 0,
+					PeriodSeconds: int64(vsphere.CheckpointDefaultPeriod.Seconds()),
+				},
+				StartFrom:           StartFromNow,
+				PollIntervalSeconds: int64(vsphere.DefaultPollInterval.Seconds()),
+				SinkTimeoutSeconds:  int64(vsphere.DefaultSinkTimeout.Seconds()),
+				CloudEventSource:    validVAuthSpec.Address.Host,
+				PayloadEncoding:     cloudevents.ApplicationXML,
+			},
+		},
+	}, {
+		name: "startFrom earliest kept",
+		c: &VSphereSource{
 			ObjectMeta: metav1.ObjectMeta{
 				Name: "valid",
 			},
 			Spec: VSphereSourceSpec{
 				SourceSpec: validSourceSpec,
 				VAuthSpec:  validVAuthSpec,
+				StartFrom:  StartFromEarliest,
+			},
+		},
+		want: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec: validSourceSpec,
+				VAuthSpec:  defaultedVAuthSpec,
 				CheckpointConfig: VCheckpointSpec{
 					MaxAgeSeconds: 0,
 					PeriodSeconds: int64(vsphere.CheckpointDefaultPeriod.Seconds()),
 				},
-				PayloadEncoding: cloudevents.ApplicationXML,
+				StartFrom:           StartFromEarliest,
+				PollIntervalSeconds: int64(vsphere.DefaultPollInterval.Seconds()),
+				SinkTimeoutSeconds:  int64(vsphere.DefaultSinkTimeout.Seconds()),
+				CloudEventSource:    validVAuthSpec.Address.Host,
+				PayloadEncoding:     cloudevents.ApplicationXML,
 			},
 		},
 	}, {
@@ -60,17 +97,94 @@ func TestVSphereSourceDefaulting(t *testing.T) {
 			},
 		},
 		want: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec: validSourceSpec,
+				VAuthSpec:  defaultedVAuthSpec,
+				CheckpointConfig: VCheckpointSpec{
+					MaxAgeSeconds: 0,
+					PeriodSeconds: int64(vsphere.CheckpointDefaultPeriod.Seconds()),
+				},
+				StartFrom:           StartFromNow,
+				PollIntervalSeconds: int64(vsphere.DefaultPollInterval.Seconds()),
+				SinkTimeoutSeconds:  int64(vsphere.DefaultSinkTimeout.Seconds()),
+				CloudEventSource:    validVAuthSpec.Address.Host,
+				PayloadEncoding:     cloudevents.ApplicationJSON,
+			},
+		},
+	}, {
+		name: "retry defaults",
+		c: &VSphereSource{
 			ObjectMeta: metav1.ObjectMeta{
 				Name: "valid",
 			},
 			Spec: VSphereSourceSpec{
 				SourceSpec: validSourceSpec,
 				VAuthSpec:  validVAuthSpec,
+				Retry: &RetrySpec{
+					MaxRetries: 3,
+				},
+			},
+		},
+		want: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec: validSourceSpec,
+				VAuthSpec:  defaultedVAuthSpec,
 				CheckpointConfig: VCheckpointSpec{
 					MaxAgeSeconds: 0,
 					PeriodSeconds: int64(vsphere.CheckpointDefaultPeriod.Seconds()),
 				},
-				PayloadEncoding: cloudevents.ApplicationJSON,
+				StartFrom:           StartFromNow,
+				PollIntervalSeconds: int64(vsphere.DefaultPollInterval.Seconds()),
+				SinkTimeoutSeconds:  int64(vsphere.DefaultSinkTimeout.Seconds()),
+				CloudEventSource:    validVAuthSpec.Address.Host,
+				PayloadEncoding:     cloudevents.ApplicationXML,
+				Retry: &RetrySpec{
+					MaxRetries:            3,
+					InitialBackoffSeconds: int64(vsphere.DefaultRetryBackoff.Seconds()),
+					BackoffPolicy:         BackoffPolicyExponential,
+				},
+			},
+		},
+	}, {
+		name: "rate limit burst defaults to events per second",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec: validSourceSpec,
+				VAuthSpec:  validVAuthSpec,
+				RateLimit: &RateLimitSpec{
+					EventsPerSecond: 50,
+				},
+			},
+		},
+		want: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec: validSourceSpec,
+				VAuthSpec:  defaultedVAuthSpec,
+				CheckpointConfig: VCheckpointSpec{
+					MaxAgeSeconds: 0,
+					PeriodSeconds: int64(vsphere.CheckpointDefaultPeriod.Seconds()),
+				},
+				StartFrom:           StartFromNow,
+				PollIntervalSeconds: int64(vsphere.DefaultPollInterval.Seconds()),
+				SinkTimeoutSeconds:  int64(vsphere.DefaultSinkTimeout.Seconds()),
+				CloudEventSource:    validVAuthSpec.Address.Host,
+				PayloadEncoding:     cloudevents.ApplicationXML,
+				RateLimit: &RateLimitSpec{
+					EventsPerSecond: 50,
+					Burst:           50,
+				},
 			},
 		},
 	}, {
@@ -109,12 +223,62 @@ func TestVSphereSourceDefaulting(t *testing.T) {
 						},
 					},
 				},
-				VAuthSpec: validVAuthSpec,
+				VAuthSpec: defaultedVAuthSpec,
+				CheckpointConfig: VCheckpointSpec{
+					MaxAgeSeconds: 0,
+					PeriodSeconds: int64(vsphere.CheckpointDefaultPeriod.Seconds()),
+				},
+				StartFrom:           StartFromNow,
+				PollIntervalSeconds: int64(vsphere.DefaultPollInterval.Seconds()),
+				SinkTimeoutSeconds:  int64(vsphere.DefaultSinkTimeout.Seconds()),
+				CloudEventSource:    validVAuthSpec.Address.Host,
+				PayloadEncoding:     cloudevents.ApplicationXML,
+			},
+		},
+	}, {
+		name: "deadLetterSink ref gets namespace",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "valid",
+				Namespace: "with-namespace",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec: validSourceSpec,
+				VAuthSpec:  validVAuthSpec,
+				DeadLetterSink: &duckv1.Destination{
+					Ref: &duckv1.KReference{
+						APIVersion: "serving.knative.dev",
+						Kind:       "Service",
+						Name:       "no-namespace",
+					},
+				},
+			},
+		},
+		want: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "valid",
+				Namespace: "with-namespace",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec: validSourceSpec,
+				VAuthSpec:  defaultedVAuthSpec,
 				CheckpointConfig: VCheckpointSpec{
 					MaxAgeSeconds: 0,
 					PeriodSeconds: int64(vsphere.CheckpointDefaultPeriod.Seconds()),
 				},
-				PayloadEncoding: cloudevents.ApplicationXML,
+				StartFrom:           StartFromNow,
+				PollIntervalSeconds: int64(vsphere.DefaultPollInterval.Seconds()),
+				SinkTimeoutSeconds:  int64(vsphere.DefaultSinkTimeout.Seconds()),
+				CloudEventSource:    validVAuthSpec.Address.Host,
+				PayloadEncoding:     cloudevents.ApplicationXML,
+				DeadLetterSink: &duckv1.Destination{
+					Ref: &duckv1.KReference{
+						APIVersion: "serving.knative.dev",
+						Kind:       "Service",
+						Namespace:  "with-namespace",
+						Name:       "no-namespace",
+					},
+				},
 			},
 		},
 	}, {
@@ -138,12 +302,107 @@ func TestVSphereSourceDefaulting(t *testing.T) {
 			},
 			Spec: VSphereSourceSpec{
 				SourceSpec: validSourceSpec,
-				VAuthSpec:  validVAuthSpec,
+				VAuthSpec:  defaultedVAuthSpec,
 				CheckpointConfig: VCheckpointSpec{
 					MaxAgeSeconds: 3600,
 					PeriodSeconds: 60,
 				},
-				PayloadEncoding: cloudevents.ApplicationXML,
+				StartFrom:           StartFromNow,
+				PollIntervalSeconds: int64(vsphere.DefaultPollInterval.Seconds()),
+				SinkTimeoutSeconds:  int64(vsphere.DefaultSinkTimeout.Seconds()),
+				CloudEventSource:    validVAuthSpec.Address.Host,
+				PayloadEncoding:     cloudevents.ApplicationXML,
+			},
+		},
+	}, {
+		name: "custom poll interval",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:          validSourceSpec,
+				VAuthSpec:           validVAuthSpec,
+				PollIntervalSeconds: 30,
+			},
+		},
+		want: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec: validSourceSpec,
+				VAuthSpec:  defaultedVAuthSpec,
+				CheckpointConfig: VCheckpointSpec{
+					MaxAgeSeconds: 0,
+					PeriodSeconds: int64(vsphere.CheckpointDefaultPeriod.Seconds()),
+				},
+				StartFrom:           StartFromNow,
+				PollIntervalSeconds: 30,
+				SinkTimeoutSeconds:  int64(vsphere.DefaultSinkTimeout.Seconds()),
+				CloudEventSource:    validVAuthSpec.Address.Host,
+				PayloadEncoding:     cloudevents.ApplicationXML,
+			},
+		},
+	}, {
+		name: "custom cloudEventSource is kept",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:          validSourceSpec,
+				VAuthSpec:           validVAuthSpec,
+				PollIntervalSeconds: 30,
+				CloudEventSource:    "/vcenter/dc-1",
+			},
+		},
+		want: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec: validSourceSpec,
+				VAuthSpec:  defaultedVAuthSpec,
+				CheckpointConfig: VCheckpointSpec{
+					MaxAgeSeconds: 0,
+					PeriodSeconds: int64(vsphere.CheckpointDefaultPeriod.Seconds()),
+				},
+				StartFrom:           StartFromNow,
+				PollIntervalSeconds: 30,
+				SinkTimeoutSeconds:  int64(vsphere.DefaultSinkTimeout.Seconds()),
+				CloudEventSource:    "/vcenter/dc-1",
+				PayloadEncoding:     cloudevents.ApplicationXML,
+			},
+		},
+	}, {
+		name: "custom sink timeout is kept",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:         validSourceSpec,
+				VAuthSpec:          validVAuthSpec,
+				SinkTimeoutSeconds: 5,
+			},
+		},
+		want: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec: validSourceSpec,
+				VAuthSpec:  defaultedVAuthSpec,
+				CheckpointConfig: VCheckpointSpec{
+					MaxAgeSeconds: 0,
+					PeriodSeconds: int64(vsphere.CheckpointDefaultPeriod.Seconds()),
+				},
+				StartFrom:           StartFromNow,
+				PollIntervalSeconds: int64(vsphere.DefaultPollInterval.Seconds()),
+				SinkTimeoutSeconds:  5,
+				CloudEventSource:    validVAuthSpec.Address.Host,
+				PayloadEncoding:     cloudevents.ApplicationXML,
 			},
 		},
 	}}
@@ -158,3 +417,98 @@ func TestVSphereSourceDefaulting(t *testing.T) {
 		})
 	}
 }
+
+func TestVSphereSourceDefaultingAddressChange(t *testing.T) {
+	newSource := func(host, ceSource string) *VSphereSource {
+		vs := &VSphereSource{
+			Spec: VSphereSourceSpec{
+				SourceSpec:       validSourceSpec,
+				VAuthSpec:        validVAuthSpec,
+				CloudEventSource: ceSource,
+			},
+		}
+		vs.Spec.Address.Host = host
+		return vs
+	}
+
+	tests := []struct {
+		name     string
+		baseline *VSphereSource
+		updated  *VSphereSource
+		want     string
+	}{{
+		name:     "defaulted source follows the address",
+		baseline: newSource("vc1.corp.local", "vc1.corp.local"),
+		updated:  newSource("vc2.corp.local", "vc1.corp.local"),
+		want:     "vc2.corp.local",
+	}, {
+		name:     "unset source is defaulted from the new address",
+		baseline: newSource("vc1.corp.local", "vc1.corp.local"),
+		updated:  newSource("vc2.corp.local", ""),
+		want:     "vc2.corp.local",
+	}, {
+		name:     "source set by the user is kept",
+		baseline: newSource("vc1.corp.local", "vcenter-prod"),
+		updated:  newSource("vc2.corp.local", "vcenter-prod"),
+		want:     "vcenter-prod",
+	}, {
+		name:     "source changed with the address is kept",
+		baseline: newSource("vc1.corp.local", "vc1.corp.local"),
+		updated:  newSource("vc2.corp.local", "vcenter-prod"),
+		want:     "vcenter-prod",
+	}, {
+		name:     "address unchanged",
+		baseline: newSource("vc1.corp.local", "vc1.corp.local"),
+		updated:  newSource("vc1.corp.local", "vc1.corp.local"),
+		want:     "vc1.corp.local",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := apis.WithinUpdate(context.Background(), test.baseline)
+			got := test.updated.DeepCopy()
+			got.SetDefaults(ctx)
+			if got.Spec.CloudEventSource != test.want {
+				t.Errorf("SetDefaults() cloudEventSource = %q, want %q", got.Spec.CloudEventSource, test.want)
+			}
+		})
+	}
+}
+
+func TestVSphereSourceDefaultingFromConfig(t *testing.T) {
+	cfg := config.NewVSphereConfig()
+	cfg.DefaultCheckpointPeriod = time.Minute
+	cfg.DefaultPayloadEncoding = cloudevents.ApplicationJSON
+	ctx := config.ToContext(context.Background(), cfg)
+
+	got := &VSphereSource{
+		Spec: VSphereSourceSpec{
+			SourceSpec: validSourceSpec,
+			VAuthSpec:  validVAuthSpec,
+		},
+	}
+	got.SetDefaults(ctx)
+	if got, want := got.Spec.CheckpointConfig.PeriodSeconds, int64(60); got != want {
+		t.Errorf("SetDefaults() checkpointConfig.periodSeconds = %d, want %d", got, want)
+	}
+	if got, want := got.Spec.PayloadEncoding, cloudevents.ApplicationJSON; got != want {
+		t.Errorf("SetDefaults() payloadEncoding = %q, want %q", got, want)
+	}
+
+	// fields set in the spec take precedence
+	got = &VSphereSource{
+		Spec: VSphereSourceSpec{
+			SourceSpec:       validSourceSpec,
+			VAuthSpec:        validVAuthSpec,
+			CheckpointConfig: VCheckpointSpec{PeriodSeconds: 5},
+			PayloadEncoding:  cloudevents.ApplicationXML,
+		},
+	}
+	got.SetDefaults(ctx)
+	if got, want := got.Spec.CheckpointConfig.PeriodSeconds, int64(5); got != want {
+		t.Errorf("SetDefaults() checkpointConfig.periodSeconds = %d, want %d", got, want)
+	}
+	if got, want := got.Spec.PayloadEncoding, cloudevents.ApplicationXML; got != want {
+		t.Errorf("SetDefaults() payloadEncoding = %q, want %q", got, want)
+	}
+}
@@ -6,14 +6,23 @@ SPDX-License-Identifier: Apache-2.0
 package v1alpha1
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/config"
+	"github.com/vmware-tanzu/sources-for-knative/pkg/vsphere"
 )
 
 var condSet = apis.NewLivingConditionSet(
+	VSphereSourceConditionSinkProvided,
 	VSphereSourceConditionAuthReady,
 	VSphereSourceConditionAdapterReady,
 )
@@ -33,21 +42,214 @@ func (vss *VSphereSourceStatus) InitializeConditions() {
 	condSet.Manage(vss).InitializeConditions()
 }
 
+// PropagateAuthStatus reflects the Ready condition of the VSphereBinding in
+// the AuthReady condition. The reason and message of the binding condition
+// are copied verbatim, so that the cause of a failed binding, e.g.
+// SubjectMissing or BindingFailed, is visible on the source.
 func (vss *VSphereSourceStatus) PropagateAuthStatus(status duckv1.Status) {
 	cond := status.GetCondition(apis.ConditionReady)
 	switch {
 	case cond == nil:
 		condSet.Manage(vss).MarkUnknown(VSphereSourceConditionAuthReady, "", "")
 	case cond.Status == corev1.ConditionUnknown:
-		condSet.Manage(vss).MarkUnknown(VSphereSourceConditionAuthReady, cond.Reason, cond.Message)
+		// the message is not a format string
+		condSet.Manage(vss).MarkUnknown(VSphereSourceConditionAuthReady, cond.Reason, "%s", cond.Message)
 	case cond.Status == corev1.ConditionFalse:
-		condSet.Manage(vss).MarkFalse(VSphereSourceConditionAuthReady, cond.Reason, cond.Message)
+		condSet.Manage(vss).MarkFalse(VSphereSourceConditionAuthReady, cond.Reason, "%s", cond.Message)
 	case cond.Status == corev1.ConditionTrue:
 		condSet.Manage(vss).MarkTrue(VSphereSourceConditionAuthReady)
 	}
 }
 
-func (vss *VSphereSourceStatus) PropagateAdapterStatus(d appsv1.DeploymentStatus) {
+// MarkSink sets the sink URI and marks the sink as resolved.
+func (vss *VSphereSourceStatus) MarkSink(uri *apis.URL) {
+	vss.MarkSinks(uri)
+}
+
+// MarkSinks sets the URIs of the sink followed by the additional sinks and
+// marks the sinks as resolved.
+func (vss *VSphereSourceStatus) MarkSinks(sink *apis.URL, additional ...*apis.URL) {
+	vss.SinkURI = sink
+	vss.SinkURIs = append([]*apis.URL{sink}, additional...)
+	condSet.Manage(vss).MarkTrue(VSphereSourceConditionSinkProvided)
+}
+
+// MarkNoSink clears the sink URIs and marks the sinks as not resolvable.
+func (vss *VSphereSourceStatus) MarkNoSink(reason, messageFormat string, messageA ...interface{}) {
+	vss.SinkURI = nil
+	vss.SinkURIs = nil
+	condSet.Manage(vss).MarkFalse(VSphereSourceConditionSinkProvided, reason, messageFormat, messageA...)
+}
+
+// MarkDeadLetterSink sets the dead letter sink URI and marks it as resolved.
+func (vss *VSphereSourceStatus) MarkDeadLetterSink(uri *apis.URL) {
+	vss.DeadLetterSinkURI = uri
+	condSet.Manage(vss).MarkTrue(VSphereSourceConditionDeadLetterSinkResolved)
+}
+
+// MarkNoDeadLetterSink clears the dead letter sink URI and marks it as not
+// resolvable. Events are still delivered to the sink.
+func (vss *VSphereSourceStatus) MarkNoDeadLetterSink(reason, messageFormat string, messageA ...interface{}) {
+	vss.DeadLetterSinkURI = nil
+	condSet.Manage(vss).MarkFalse(VSphereSourceConditionDeadLetterSinkResolved, reason, messageFormat, messageA...)
+}
+
+// ClearDeadLetterSink removes the dead letter sink URI and condition when no
+// dead letter sink is configured.
+func (vss *VSphereSourceStatus) ClearDeadLetterSink() {
+	vss.DeadLetterSinkURI = nil
+	_ = condSet.Manage(vss).ClearCondition(VSphereSourceConditionDeadLetterSinkResolved)
+}
+
+// MarkCheckpoint sets the last checkpoint of the adapter and its lag, rounded
+// to seconds.
+func (vss *VSphereSourceStatus) MarkCheckpoint(lastEventKey int32, lastEventTime time.Time, lag time.Duration) {
+	vss.Checkpoint = &CheckpointStatus{
+		LastEventKey:  lastEventKey,
+		LastEventTime: metav1.NewTime(lastEventTime),
+		LagSeconds:    int64(lag.Round(time.Second).Seconds()),
+	}
+}
+
+// ClearCheckpoint removes the checkpoint and its condition when the adapter
+// has not saved a checkpoint of a processed event yet.
+func (vss *VSphereSourceStatus) ClearCheckpoint() {
+	vss.Checkpoint = nil
+	vss.ClearCheckpointCondition()
+}
+
+// MarkCheckpointCurrent marks the lag of the checkpoint as within the
+// threshold.
+func (vss *VSphereSourceStatus) MarkCheckpointCurrent() {
+	condSet.Manage(vss).MarkTrue(VSphereSourceConditionCheckpointCurrent)
+}
+
+// MarkCheckpointLagging marks the lag of the checkpoint as exceeding the
+// given threshold. The Ready condition is not affected.
+func (vss *VSphereSourceStatus) MarkCheckpointLagging(lag, threshold time.Duration) {
+	condSet.Manage(vss).MarkFalse(VSphereSourceConditionCheckpointCurrent, "CheckpointLagging",
+		"The last checkpoint lags behind by %s, more than %s", lag.Round(time.Second), threshold)
+}
+
+// ClearCheckpointCondition removes the CheckpointCurrent condition when no
+// lag threshold is configured.
+func (vss *VSphereSourceStatus) ClearCheckpointCondition() {
+	_ = condSet.Manage(vss).ClearCondition(VSphereSourceConditionCheckpointCurrent)
+}
+
+// MarkAdapterHealthy marks the adapter as reporting a recent heartbeat without
+// persistent failures.
+func (vss *VSphereSourceStatus) MarkAdapterHealthy() {
+	condSet.Manage(vss).MarkTrue(VSphereSourceConditionAdapterHealthy)
+}
+
+// MarkAdapterUnhealthy marks the adapter as unhealthy, i.e. its heartbeat is
+// stale or reports a persistent failure. The Ready condition is not affected.
+func (vss *VSphereSourceStatus) MarkAdapterUnhealthy(reason, messageFormat string, messageA ...interface{}) {
+	condSet.Manage(vss).MarkFalse(VSphereSourceConditionAdapterHealthy, reason, messageFormat, messageA...)
+}
+
+// ClearAdapterHealthCondition removes the AdapterHealthy condition when the
+// adapter has not written a heartbeat yet.
+func (vss *VSphereSourceStatus) ClearAdapterHealthCondition() {
+	_ = condSet.Manage(vss).ClearCondition(VSphereSourceConditionAdapterHealthy)
+}
+
+// MarkTLSVerificationSkipped marks TLS verification against vCenter as
+// disabled. The Ready condition is not affected.
+func (vss *VSphereSourceStatus) MarkTLSVerificationSkipped() {
+	condSet.Manage(vss).MarkFalse(VSphereSourceConditionTLSVerified, "InsecureSkipTLSVerify",
+		"TLS verification against vCenter is disabled, this is unsafe and must not be used in production")
+}
+
+// ClearTLSVerifiedCondition removes the TLSVerified condition when TLS
+// verification against vCenter is enabled.
+func (vss *VSphereSourceStatus) ClearTLSVerifiedCondition() {
+	_ = condSet.Manage(vss).ClearCondition(VSphereSourceConditionTLSVerified)
+}
+
+// MarkAdapterPaused marks the adapter as ready while it is scaled to zero
+// replicas because the source is paused.
+func (vss *VSphereSourceStatus) MarkAdapterPaused() {
+	condSet.Manage(vss).MarkTrueWithReason(VSphereSourceConditionAdapterReady, "Paused",
+		"The adapter is scaled to zero replicas while the source is paused")
+}
+
+// MarkPaused marks the source as paused. A Ready condition which is True
+// gets the reason Paused, so it must be called after all other conditions
+// are updated.
+func (vss *VSphereSourceStatus) MarkPaused() {
+	mgr := condSet.Manage(vss)
+	mgr.MarkTrue(VSphereSourceConditionPaused)
+	if mgr.IsHappy() {
+		mgr.MarkTrueWithReason(apis.ConditionReady, "Paused", "The source is paused, no events are delivered")
+	}
+}
+
+// ClearPaused removes the Paused condition when the source is not paused.
+func (vss *VSphereSourceStatus) ClearPaused() {
+	_ = condSet.Manage(vss).ClearCondition(VSphereSourceConditionPaused)
+}
+
+// UpdateCloudEventAttributes sets the CloudEvent attributes of the events
+// emitted for the given spec. An unfiltered source advertises the type prefix
+// shared by all vSphere events of its type scheme.
+func (vss *VSphereSourceStatus) UpdateCloudEventAttributes(spec VSphereSourceSpec) {
+	source := spec.CloudEventSource
+	if source == "" {
+		source = spec.Address.Host
+	}
+
+	if len(spec.EventTypes) == 0 {
+		vss.CloudEventAttributes = []duckv1.CloudEventAttributes{{
+			Type:   vsphere.SchemeEventTypePrefix(string(spec.TypeScheme)),
+			Source: source,
+		}}
+		return
+	}
+
+	attrs := make([]duckv1.CloudEventAttributes, 0, len(spec.EventTypes))
+	for _, et := range spec.EventTypes {
+		attrs = append(attrs, duckv1.CloudEventAttributes{
+			Type:   vsphere.SchemeEventType(string(spec.TypeScheme), et),
+			Source: source,
+		})
+	}
+	vss.CloudEventAttributes = attrs
+}
+
+// MarkNoServiceAccount marks the adapter as not ready because the configured
+// ServiceAccount does not exist.
+func (vss *VSphereSourceStatus) MarkNoServiceAccount(name string) {
+	condSet.Manage(vss).MarkFalse(VSphereSourceConditionAdapterReady, "ServiceAccountNotFound",
+		"ServiceAccount %q does not exist", name)
+}
+
+// MarkVCenterNotAllowed marks the adapter as not ready because the vCenter
+// with the given host is not allowed by the cluster-wide configuration.
+func (vss *VSphereSourceStatus) MarkVCenterNotAllowed(host string) {
+	condSet.Manage(vss).MarkFalse(VSphereSourceConditionAdapterReady, "VCenterNotAllowed",
+		"vCenter %q is not allowed by the %s ConfigMap", host, config.VSphereConfigName)
+}
+
+// PropagateAdapterStatus reflects the state of the adapter Deployment and of
+// its pods in the AdapterReady condition. A crash-looping adapter container
+// marks the adapter as not ready with the reason CrashLoopBackOff and the
+// last termination message of the container, regardless of the Deployment
+// status. So does an adapter container whose image cannot be pulled, with the
+// reason reported by the kubelet, e.g. ImagePullBackOff. An unavailable Deployment with a running but unready adapter
+// container, e.g. because its vCenter event stream is not active, is reported
+// with the reason AdapterNotReady.
+func (vss *VSphereSourceStatus) PropagateAdapterStatus(d appsv1.DeploymentStatus, pods ...*corev1.Pod) {
+	if msg, ok := crashLoopMessage(pods); ok {
+		condSet.Manage(vss).MarkFalse(VSphereSourceConditionAdapterReady, "CrashLoopBackOff", msg)
+		return
+	}
+	if reason, msg, ok := imagePullMessage(pods); ok {
+		condSet.Manage(vss).MarkFalse(VSphereSourceConditionAdapterReady, reason, msg)
+		return
+	}
+
 	// Check if the Deployment is available.
 	for _, cond := range d.Conditions {
 		if cond.Type == appsv1.DeploymentAvailable {
@@ -55,6 +257,10 @@ func (vss *VSphereSourceStatus) PropagateAdapterStatus(d appsv1.DeploymentStatus
 			case cond.Status == corev1.ConditionUnknown:
 				condSet.Manage(vss).MarkUnknown(VSphereSourceConditionAdapterReady, cond.Reason, cond.Message)
 			case cond.Status == corev1.ConditionFalse:
+				if msg, ok := notReadyMessage(pods); ok {
+					condSet.Manage(vss).MarkFalse(VSphereSourceConditionAdapterReady, "AdapterNotReady", msg)
+					return
+				}
 				condSet.Manage(vss).MarkFalse(VSphereSourceConditionAdapterReady, cond.Reason, cond.Message)
 			case cond.Status == corev1.ConditionTrue:
 				condSet.Manage(vss).MarkTrue(VSphereSourceConditionAdapterReady)
@@ -65,3 +271,60 @@ func (vss *VSphereSourceStatus) PropagateAdapterStatus(d appsv1.DeploymentStatus
 
 	condSet.Manage(vss).MarkUnknown(VSphereSourceConditionAdapterReady, "", "")
 }
+
+// notReadyMessage returns a message describing the first running but unready
+// container of the given pods, if any.
+func notReadyMessage(pods []*corev1.Pod) (string, bool) {
+	for _, pod := range pods {
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.State.Running == nil || cs.Ready {
+				continue
+			}
+			return fmt.Sprintf("container %q of pod %q is running but not ready", cs.Name, pod.Name), true
+		}
+	}
+	return "", false
+}
+
+// imagePullMessage returns the reason and a message describing the first
+// container of the given pods whose image cannot be pulled, if any.
+func imagePullMessage(pods []*corev1.Pod) (string, string, bool) {
+	for _, pod := range pods {
+		for _, cs := range pod.Status.ContainerStatuses {
+			w := cs.State.Waiting
+			if w == nil || (w.Reason != "ErrImagePull" && w.Reason != "ImagePullBackOff") {
+				continue
+			}
+
+			msg := fmt.Sprintf("container %q of pod %q cannot pull image %q", cs.Name, pod.Name, cs.Image)
+			if w.Message != "" {
+				msg = fmt.Sprintf("%s: %s", msg, strings.TrimSpace(w.Message))
+			}
+			msg += ", check that spec.adapterOverrides.imagePullSecrets grant access to the registry"
+			return w.Reason, msg, true
+		}
+	}
+	return "", "", false
+}
+
+// crashLoopMessage returns a message describing the first crash-looping
+// container of the given pods, if any.
+func crashLoopMessage(pods []*corev1.Pod) (string, bool) {
+	for _, pod := range pods {
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.State.Waiting == nil || cs.State.Waiting.Reason != "CrashLoopBackOff" {
+				continue
+			}
+
+			msg := fmt.Sprintf("container %q of pod %q is crash-looping", cs.Name, pod.Name)
+			if term := cs.LastTerminationState.Terminated; term != nil {
+				msg = fmt.Sprintf("%s, last terminated with exit code %d", msg, term.ExitCode)
+				if term.Message != "" {
+					msg = fmt.Sprintf("%s: %s", msg, strings.TrimSpace(term.Message))
+				}
+			}
+			return msg, true
+		}
+	}
+	return "", false
+}
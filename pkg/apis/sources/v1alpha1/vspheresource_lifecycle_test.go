@@ -6,15 +6,20 @@ SPDX-License-Identifier: Apache-2.0
 package v1alpha1
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"knative.dev/pkg/apis"
 	"knative.dev/pkg/apis/duck"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
 	apistest "knative.dev/pkg/apis/testing"
+	"knative.dev/pkg/reconciler"
 )
 
 func TestVSphereSourceDuckTypes(t *testing.T) {
@@ -83,6 +88,14 @@ func TestTypicalSourceFlow(t *testing.T) {
 	apistest.CheckConditionSucceeded(r, VSphereSourceConditionAuthReady, t)
 	apistest.CheckConditionOngoing(r, VSphereSourceConditionReady, t)
 
+	// Check the progression of the SinkProvided condition.
+	r.MarkNoSink("SinkNotFound", "sink %q not found", "broker")
+	apistest.CheckConditionFailed(r, VSphereSourceConditionSinkProvided, t)
+	apistest.CheckConditionFailed(r, VSphereSourceConditionReady, t)
+	r.MarkSink(apis.HTTP("sink.example.com"))
+	apistest.CheckConditionSucceeded(r, VSphereSourceConditionSinkProvided, t)
+	apistest.CheckConditionOngoing(r, VSphereSourceConditionReady, t)
+
 	// Check the progression of the AdapterReady condition.
 	r.PropagateAdapterStatus(appsv1.DeploymentStatus{})
 	apistest.CheckConditionOngoing(r, VSphereSourceConditionAdapterReady, t)
@@ -112,3 +125,532 @@ func TestTypicalSourceFlow(t *testing.T) {
 	// After all of that, we're finally ready!
 	apistest.CheckConditionSucceeded(r, VSphereSourceConditionReady, t)
 }
+
+func TestPropagateAuthStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		markBinding func(*VSphereBindingStatus)
+		wantStatus  corev1.ConditionStatus
+		wantReason  string
+		wantMessage string
+	}{{
+		name:        "binding not reconciled",
+		markBinding: func(*VSphereBindingStatus) {},
+		wantStatus:  corev1.ConditionUnknown,
+	}, {
+		name: "subject unavailable",
+		markBinding: func(vsbs *VSphereBindingStatus) {
+			vsbs.MarkBindingUnavailable("SubjectUnavailable", `deployments.apps "source-adapter" is forbidden`)
+		},
+		wantStatus:  corev1.ConditionFalse,
+		wantReason:  "SubjectUnavailable",
+		wantMessage: `deployments.apps "source-adapter" is forbidden`,
+	}, {
+		name: "subject missing",
+		markBinding: func(vsbs *VSphereBindingStatus) {
+			vsbs.MarkBindingUnavailable("SubjectMissing", `deployment.apps "source-adapter" not found`)
+		},
+		wantStatus:  corev1.ConditionFalse,
+		wantReason:  "SubjectMissing",
+		wantMessage: `deployment.apps "source-adapter" not found`,
+	}, {
+		name: "binding failed",
+		markBinding: func(vsbs *VSphereBindingStatus) {
+			vsbs.MarkBindingUnavailable("BindingFailed", `failed to patch: admission webhook denied the request: quota 100% used`)
+		},
+		wantStatus:  corev1.ConditionFalse,
+		wantReason:  "BindingFailed",
+		wantMessage: `failed to patch: admission webhook denied the request: quota 100% used`,
+	}, {
+		name: "binding available",
+		markBinding: func(vsbs *VSphereBindingStatus) {
+			vsbs.MarkBindingAvailable()
+		},
+		wantStatus: corev1.ConditionTrue,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vsb := &VSphereBinding{}
+			vsb.Status.InitializeConditions()
+			tt.markBinding(&vsb.Status)
+
+			vss := &VSphereSourceStatus{}
+			vss.InitializeConditions()
+			vss.PropagateAuthStatus(vsb.Status.Status)
+
+			cond := vss.GetCondition(VSphereSourceConditionAuthReady)
+			got := []string{string(cond.Status), cond.Reason, cond.Message}
+			want := []string{string(tt.wantStatus), tt.wantReason, tt.wantMessage}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("PropagateAuthStatus() AuthReady status, reason, message (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestPropagateAdapterStatusCrashLoop(t *testing.T) {
+	available := appsv1.DeploymentStatus{
+		Conditions: []appsv1.DeploymentCondition{{
+			Type:   appsv1.DeploymentAvailable,
+			Status: corev1.ConditionTrue,
+		}},
+	}
+
+	running := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "adapter-1"},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "adapter",
+				State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			}},
+		},
+	}
+
+	crashLooping := func(term *corev1.ContainerStateTerminated) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "adapter-2"},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name: "adapter",
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+						Reason: "CrashLoopBackOff",
+					}},
+					LastTerminationState: corev1.ContainerState{Terminated: term},
+				}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		pods        []*corev1.Pod
+		wantReady   corev1.ConditionStatus
+		wantReason  string
+		wantMessage string
+	}{
+		{
+			name:      "running pod",
+			pods:      []*corev1.Pod{running},
+			wantReady: corev1.ConditionTrue,
+		},
+		{
+			name: "crash-looping pod with termination message",
+			pods: []*corev1.Pod{running, crashLooping(&corev1.ContainerStateTerminated{
+				ExitCode: 1,
+				Message:  "unable to create vSphere client: ServerFaultCode: Cannot complete login\n",
+			})},
+			wantReady:   corev1.ConditionFalse,
+			wantReason:  "CrashLoopBackOff",
+			wantMessage: `container "adapter" of pod "adapter-2" is crash-looping, last terminated with exit code 1: unable to create vSphere client: ServerFaultCode: Cannot complete login`,
+		},
+		{
+			name:        "crash-looping pod without last state",
+			pods:        []*corev1.Pod{crashLooping(nil)},
+			wantReady:   corev1.ConditionFalse,
+			wantReason:  "CrashLoopBackOff",
+			wantMessage: `container "adapter" of pod "adapter-2" is crash-looping`,
+		},
+		{
+			name: "image pull back-off",
+			pods: []*corev1.Pod{running, {
+				ObjectMeta: metav1.ObjectMeta{Name: "adapter-3"},
+				Status: corev1.PodStatus{
+					ContainerStatuses: []corev1.ContainerStatus{{
+						Name:  "adapter",
+						Image: "registry.example.com/adapter:v1",
+						State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+							Reason:  "ImagePullBackOff",
+							Message: `Back-off pulling image "registry.example.com/adapter:v1"`,
+						}},
+					}},
+				},
+			}},
+			wantReady:   corev1.ConditionFalse,
+			wantReason:  "ImagePullBackOff",
+			wantMessage: `container "adapter" of pod "adapter-3" cannot pull image "registry.example.com/adapter:v1": Back-off pulling image "registry.example.com/adapter:v1", check that spec.adapterOverrides.imagePullSecrets grant access to the registry`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &VSphereSourceStatus{}
+			r.InitializeConditions()
+			r.PropagateAdapterStatus(available, tt.pods...)
+
+			cond := r.GetCondition(VSphereSourceConditionAdapterReady)
+			if cond.Status != tt.wantReady {
+				t.Errorf("PropagateAdapterStatus() status = %v, want %v", cond.Status, tt.wantReady)
+			}
+			if cond.Reason != tt.wantReason {
+				t.Errorf("PropagateAdapterStatus() reason = %q, want %q", cond.Reason, tt.wantReason)
+			}
+			if cond.Message != tt.wantMessage {
+				t.Errorf("PropagateAdapterStatus() message = %q, want %q", cond.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestPropagateAdapterStatusNotReady(t *testing.T) {
+	unavailable := appsv1.DeploymentStatus{
+		Conditions: []appsv1.DeploymentCondition{{
+			Type:    appsv1.DeploymentAvailable,
+			Status:  corev1.ConditionFalse,
+			Reason:  "MinimumReplicasUnavailable",
+			Message: "Deployment does not have minimum availability.",
+		}},
+	}
+
+	pod := func(ready bool) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "adapter-1"},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:  "adapter",
+					Ready: ready,
+					State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		pods        []*corev1.Pod
+		wantReason  string
+		wantMessage string
+	}{
+		{
+			name:        "no pods",
+			wantReason:  "MinimumReplicasUnavailable",
+			wantMessage: "Deployment does not have minimum availability.",
+		},
+		{
+			name:        "ready pod",
+			pods:        []*corev1.Pod{pod(true)},
+			wantReason:  "MinimumReplicasUnavailable",
+			wantMessage: "Deployment does not have minimum availability.",
+		},
+		{
+			name:        "running but unready pod",
+			pods:        []*corev1.Pod{pod(false)},
+			wantReason:  "AdapterNotReady",
+			wantMessage: `container "adapter" of pod "adapter-1" is running but not ready`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &VSphereSourceStatus{}
+			r.InitializeConditions()
+			r.PropagateAdapterStatus(unavailable, tt.pods...)
+
+			cond := r.GetCondition(VSphereSourceConditionAdapterReady)
+			if cond.Status != corev1.ConditionFalse {
+				t.Errorf("PropagateAdapterStatus() status = %v, want %v", cond.Status, corev1.ConditionFalse)
+			}
+			if cond.Reason != tt.wantReason {
+				t.Errorf("PropagateAdapterStatus() reason = %q, want %q", cond.Reason, tt.wantReason)
+			}
+			if cond.Message != tt.wantMessage {
+				t.Errorf("PropagateAdapterStatus() message = %q, want %q", cond.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestMarkNoServiceAccount(t *testing.T) {
+	r := &VSphereSourceStatus{}
+	r.InitializeConditions()
+
+	r.MarkNoServiceAccount("missing")
+	apistest.CheckConditionFailed(r, VSphereSourceConditionAdapterReady, t)
+	apistest.CheckConditionFailed(r, VSphereSourceConditionReady, t)
+
+	cond := r.GetCondition(VSphereSourceConditionAdapterReady)
+	if got, want := cond.Reason, "ServiceAccountNotFound"; got != want {
+		t.Errorf("MarkNoServiceAccount() reason = %q, want %q", got, want)
+	}
+}
+
+func TestMarkNoSink(t *testing.T) {
+	r := &VSphereSourceStatus{}
+	r.InitializeConditions()
+
+	r.MarkSink(apis.HTTP("sink.example.com"))
+	apistest.CheckConditionSucceeded(r, VSphereSourceConditionSinkProvided, t)
+
+	r.MarkNoSink("NotAddressable", "sink is not addressable")
+	apistest.CheckConditionFailed(r, VSphereSourceConditionSinkProvided, t)
+
+	if r.SinkURI != nil {
+		t.Errorf("MarkNoSink() SinkURI = %v, want nil", r.SinkURI)
+	}
+	cond := r.GetCondition(VSphereSourceConditionSinkProvided)
+	if got, want := cond.Reason, "NotAddressable"; got != want {
+		t.Errorf("MarkNoSink() reason = %q, want %q", got, want)
+	}
+}
+
+func TestMarkSinks(t *testing.T) {
+	r := &VSphereSourceStatus{}
+	r.InitializeConditions()
+
+	sink, other := apis.HTTP("sink.example.com"), apis.HTTP("other.example.com")
+	r.MarkSinks(sink, other)
+	apistest.CheckConditionSucceeded(r, VSphereSourceConditionSinkProvided, t)
+
+	if r.SinkURI != sink {
+		t.Errorf("MarkSinks() SinkURI = %v, want %v", r.SinkURI, sink)
+	}
+	if diff := cmp.Diff([]*apis.URL{sink, other}, r.SinkURIs); diff != "" {
+		t.Errorf("MarkSinks() unexpected SinkURIs (-want, +got) = %v", diff)
+	}
+
+	r.MarkNoSink("NotAddressable", "sink is not addressable")
+	if r.SinkURIs != nil {
+		t.Errorf("MarkNoSink() SinkURIs = %v, want nil", r.SinkURIs)
+	}
+}
+
+func TestMarkCheckpoint(t *testing.T) {
+	r := &VSphereSourceStatus{}
+	r.InitializeConditions()
+	r.MarkSink(apis.HTTP("sink.example.com"))
+	r.PropagateAuthStatus(duckv1.Status{Conditions: duckv1.Conditions{{Type: apis.ConditionReady, Status: corev1.ConditionTrue}}})
+	r.PropagateAdapterStatus(appsv1.DeploymentStatus{Conditions: []appsv1.DeploymentCondition{{
+		Type:   appsv1.DeploymentAvailable,
+		Status: corev1.ConditionTrue,
+	}}})
+	apistest.CheckConditionSucceeded(r, VSphereSourceConditionReady, t)
+
+	lastEventTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.MarkCheckpoint(42, lastEventTime, 90*time.Second+400*time.Millisecond)
+	want := &CheckpointStatus{LastEventKey: 42, LastEventTime: metav1.NewTime(lastEventTime), LagSeconds: 90}
+	if diff := cmp.Diff(want, r.Checkpoint); diff != "" {
+		t.Errorf("MarkCheckpoint() unexpected checkpoint (-want, +got) = %v", diff)
+	}
+
+	r.MarkCheckpointCurrent()
+	apistest.CheckConditionSucceeded(r, VSphereSourceConditionCheckpointCurrent, t)
+
+	// a lagging checkpoint does not affect readiness
+	r.MarkCheckpointLagging(90*time.Second, time.Minute)
+	apistest.CheckConditionFailed(r, VSphereSourceConditionCheckpointCurrent, t)
+	apistest.CheckConditionSucceeded(r, VSphereSourceConditionReady, t)
+	if got, want := r.GetCondition(VSphereSourceConditionCheckpointCurrent).Reason, "CheckpointLagging"; got != want {
+		t.Errorf("MarkCheckpointLagging() reason = %q, want %q", got, want)
+	}
+
+	r.ClearCheckpoint()
+	if r.Checkpoint != nil {
+		t.Errorf("ClearCheckpoint() Checkpoint = %v, want nil", r.Checkpoint)
+	}
+	if cond := r.GetCondition(VSphereSourceConditionCheckpointCurrent); cond != nil {
+		t.Errorf("ClearCheckpoint() condition = %v, want nil", cond)
+	}
+}
+
+func TestMarkPaused(t *testing.T) {
+	r := &VSphereSourceStatus{}
+	r.InitializeConditions()
+	r.MarkSink(apis.HTTP("sink.example.com"))
+	r.PropagateAuthStatus(duckv1.Status{Conditions: duckv1.Conditions{{Type: apis.ConditionReady, Status: corev1.ConditionTrue}}})
+
+	r.MarkAdapterPaused()
+	r.MarkPaused()
+	apistest.CheckConditionSucceeded(r, VSphereSourceConditionPaused, t)
+	apistest.CheckConditionSucceeded(r, VSphereSourceConditionAdapterReady, t)
+	apistest.CheckConditionSucceeded(r, VSphereSourceConditionReady, t)
+	if got, want := r.GetCondition(VSphereSourceConditionReady).Reason, "Paused"; got != want {
+		t.Errorf("MarkPaused() Ready reason = %q, want %q", got, want)
+	}
+
+	// an unready paused source keeps the reason of the failure
+	r.MarkNoSink("NotAddressable", "sink is not addressable")
+	r.MarkPaused()
+	apistest.CheckConditionFailed(r, VSphereSourceConditionReady, t)
+	if got, want := r.GetCondition(VSphereSourceConditionReady).Reason, "NotAddressable"; got != want {
+		t.Errorf("MarkPaused() Ready reason = %q, want %q", got, want)
+	}
+
+	r.MarkSink(apis.HTTP("sink.example.com"))
+	r.PropagateAdapterStatus(appsv1.DeploymentStatus{Conditions: []appsv1.DeploymentCondition{{
+		Type:   appsv1.DeploymentAvailable,
+		Status: corev1.ConditionTrue,
+	}}})
+	r.ClearPaused()
+	apistest.CheckConditionSucceeded(r, VSphereSourceConditionReady, t)
+	if cond := r.GetCondition(VSphereSourceConditionPaused); cond != nil {
+		t.Errorf("ClearPaused() condition = %v, want nil", cond)
+	}
+	if got := r.GetCondition(VSphereSourceConditionReady).Reason; got != "" {
+		t.Errorf("ClearPaused() Ready reason = %q, want none", got)
+	}
+}
+
+func TestMarkTLSVerificationSkipped(t *testing.T) {
+	r := &VSphereSourceStatus{}
+	r.InitializeConditions()
+	r.MarkSink(apis.HTTP("sink.example.com"))
+	r.PropagateAuthStatus(duckv1.Status{Conditions: duckv1.Conditions{{Type: apis.ConditionReady, Status: corev1.ConditionTrue}}})
+	r.PropagateAdapterStatus(appsv1.DeploymentStatus{Conditions: []appsv1.DeploymentCondition{{
+		Type:   appsv1.DeploymentAvailable,
+		Status: corev1.ConditionTrue,
+	}}})
+
+	r.MarkTLSVerificationSkipped()
+	apistest.CheckConditionFailed(r, VSphereSourceConditionTLSVerified, t)
+	apistest.CheckConditionSucceeded(r, VSphereSourceConditionReady, t)
+	if got, want := r.GetCondition(VSphereSourceConditionTLSVerified).Reason, "InsecureSkipTLSVerify"; got != want {
+		t.Errorf("MarkTLSVerificationSkipped() reason = %q, want %q", got, want)
+	}
+
+	r.ClearTLSVerifiedCondition()
+	if cond := r.GetCondition(VSphereSourceConditionTLSVerified); cond != nil {
+		t.Errorf("ClearTLSVerifiedCondition() condition = %v, want nil", cond)
+	}
+	apistest.CheckConditionSucceeded(r, VSphereSourceConditionReady, t)
+}
+
+func TestUpdateCloudEventAttributes(t *testing.T) {
+	address := apis.URL{Scheme: "https", Host: "vcenter.example.com"}
+
+	tests := []struct {
+		name string
+		spec VSphereSourceSpec
+		want []duckv1.CloudEventAttributes
+	}{{
+		name: "all events",
+		spec: VSphereSourceSpec{
+			VAuthSpec: VAuthSpec{Address: address},
+		},
+		want: []duckv1.CloudEventAttributes{{
+			Type:   "com.vmware.vsphere",
+			Source: "vcenter.example.com",
+		}},
+	}, {
+		name: "filtered events",
+		spec: VSphereSourceSpec{
+			VAuthSpec:  VAuthSpec{Address: address},
+			EventTypes: []string{"VmPoweredOnEvent", "VmPoweredOffEvent"},
+		},
+		want: []duckv1.CloudEventAttributes{{
+			Type:   "com.vmware.vsphere.VmPoweredOnEvent.v0",
+			Source: "vcenter.example.com",
+		}, {
+			Type:   "com.vmware.vsphere.VmPoweredOffEvent.v0",
+			Source: "vcenter.example.com",
+		}},
+	}, {
+		name: "cloudEventSource override",
+		spec: VSphereSourceSpec{
+			VAuthSpec:        VAuthSpec{Address: address},
+			CloudEventSource: "urn:vcenter:prod",
+			EventTypes:       []string{"VmPoweredOnEvent"},
+		},
+		want: []duckv1.CloudEventAttributes{{
+			Type:   "com.vmware.vsphere.VmPoweredOnEvent.v0",
+			Source: "urn:vcenter:prod",
+		}},
+	}, {
+		name: "veb all events",
+		spec: VSphereSourceSpec{
+			VAuthSpec:  VAuthSpec{Address: address},
+			TypeScheme: TypeSchemeVEB,
+		},
+		want: []duckv1.CloudEventAttributes{{
+			Type:   "com.vmware.event.router",
+			Source: "vcenter.example.com",
+		}},
+	}, {
+		name: "veb filtered events",
+		spec: VSphereSourceSpec{
+			VAuthSpec:  VAuthSpec{Address: address},
+			TypeScheme: TypeSchemeVEB,
+			EventTypes: []string{"VmPoweredOnEvent"},
+		},
+		want: []duckv1.CloudEventAttributes{{
+			Type:   "com.vmware.event.router/VmPoweredOnEvent",
+			Source: "vcenter.example.com",
+		}},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &VSphereSourceStatus{}
+			r.UpdateCloudEventAttributes(test.spec)
+			if diff := cmp.Diff(test.want, r.CloudEventAttributes); diff != "" {
+				t.Errorf("UpdateCloudEventAttributes() (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestUpdateCloudEventAttributesFilterChange(t *testing.T) {
+	spec := VSphereSourceSpec{
+		VAuthSpec:  VAuthSpec{Address: apis.URL{Scheme: "https", Host: "vcenter.example.com"}},
+		EventTypes: []string{"VmPoweredOnEvent", "VmPoweredOffEvent"},
+	}
+
+	r := &VSphereSourceStatus{}
+	r.UpdateCloudEventAttributes(spec)
+	if got := len(r.CloudEventAttributes); got != 2 {
+		t.Fatalf("UpdateCloudEventAttributes() got %d attributes, want 2", got)
+	}
+
+	// removing a type from the filter drops it from the status
+	spec.EventTypes = []string{"VmPoweredOffEvent"}
+	r.UpdateCloudEventAttributes(spec)
+	want := []duckv1.CloudEventAttributes{{
+		Type:   "com.vmware.vsphere.VmPoweredOffEvent.v0",
+		Source: "vcenter.example.com",
+	}}
+	if diff := cmp.Diff(want, r.CloudEventAttributes); diff != "" {
+		t.Errorf("UpdateCloudEventAttributes() (-want, +got) = %v", diff)
+	}
+}
+
+func TestObservedGeneration(t *testing.T) {
+	ctx := context.Background()
+
+	vs := &VSphereSource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "source",
+			Namespace:  "ns",
+			Generation: 1,
+		},
+	}
+
+	markReady := func(vs *VSphereSource) {
+		vs.Status.MarkSink(apis.HTTP("sink.example.com"))
+		vs.Status.PropagateAuthStatus(duckv1.Status{
+			Conditions: []apis.Condition{{Type: apis.ConditionReady, Status: corev1.ConditionTrue}},
+		})
+		vs.Status.PropagateAdapterStatus(appsv1.DeploymentStatus{
+			Conditions: []appsv1.DeploymentCondition{{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionTrue}},
+		})
+	}
+
+	// first reconcile
+	reconciler.PreProcessReconcile(ctx, vs)
+	markReady(vs)
+	reconciler.PostProcessReconcile(ctx, vs, vs.DeepCopy())
+	if got := vs.Status.ObservedGeneration; got != 1 {
+		t.Errorf("ObservedGeneration = %d, want 1", got)
+	}
+	apistest.CheckConditionSucceeded(&vs.Status, VSphereSourceConditionReady, t)
+
+	// spec change bumps the generation
+	vs.Generation = 2
+	reconciler.PreProcessReconcile(ctx, vs)
+	if got := vs.Status.ObservedGeneration; got != 1 {
+		t.Errorf("ObservedGeneration = %d, want 1 before reconcile", got)
+	}
+	apistest.CheckConditionOngoing(&vs.Status, VSphereSourceConditionReady, t)
+
+	markReady(vs)
+	reconciler.PostProcessReconcile(ctx, vs, vs.DeepCopy())
+	if got := vs.Status.ObservedGeneration; got != 2 {
+		t.Errorf("ObservedGeneration = %d, want 2 after reconcile", got)
+	}
+	apistest.CheckConditionSucceeded(&vs.Status, VSphereSourceConditionReady, t)
+}
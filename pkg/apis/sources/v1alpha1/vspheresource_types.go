@@ -6,6 +6,7 @@ SPDX-License-Identifier: Apache-2.0
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
@@ -37,6 +38,10 @@ var _ apis.Validatable = (*VSphereSource)(nil)
 var _ apis.Defaultable = (*VSphereSource)(nil)
 var _ kmeta.OwnerRefable = (*VSphereSource)(nil)
 
+// Check that VSphereSource is KRShaped, so that the generated reconciler
+// stamps status.observedGeneration and resets Ready on a new generation.
+var _ duckv1.KRShaped = (*VSphereSource)(nil)
+
 // VSphereSourceSpec holds the desired state of the VSphereSource (from the client).
 type VSphereSourceSpec struct {
 	duckv1.SourceSpec `json:",inline"`
@@ -44,13 +49,442 @@ type VSphereSourceSpec struct {
 	VAuthSpec        `json:",inline"`
 	CheckpointConfig VCheckpointSpec `json:"checkpointConfig"`
 	PayloadEncoding  string          `json:"payloadEncoding"`
+
+	// StartFrom is where the adapter starts reading the event history of
+	// vCenter when there is no checkpoint yet, i.e. on the first start of a
+	// new source. With now, the default, only events created from then on are
+	// emitted. With earliest, all events retained by vCenter are emitted.
+	// +optional
+	StartFrom StartFrom `json:"startFrom,omitempty"`
+
+	// EventFilters restricts the vCenter events emitted by the adapter to
+	// those matching at least one of the given filters. When empty, all events
+	// are emitted.
+	// +optional
+	EventFilters []EventFilter `json:"eventFilters,omitempty"`
+
+	// EventTypes restricts the events retrieved from vCenter to the given
+	// event types, e.g. VmPoweredOnEvent. Filtering is performed by vCenter.
+	// When empty, all events are retrieved.
+	// +optional
+	EventTypes []string `json:"eventTypes,omitempty"`
+
+	// Categories are the categories of the emitted vCenter events: event,
+	// task and alarm. Tasks and alarms are emitted with their own CloudEvent
+	// types, e.g. com.vmware.vsphere.task.VirtualMachine.powerOn.v0, instead
+	// of as a TaskEvent or alarm event. When empty, only the event category
+	// is emitted.
+	// +optional
+	Categories []string `json:"categories,omitempty"`
+
+	// EventTypeAutoCreate enables the registration of an EventType for each
+	// of the configured EventTypes when the sink is a Broker, so that
+	// triggers can be authored by discovery.
+	// +optional
+	EventTypeAutoCreate bool `json:"eventTypeAutoCreate,omitempty"`
+
+	// PollIntervalSeconds is the maximum time in seconds the adapter waits
+	// before polling vCenter again when no new events were received.
+	// +optional
+	PollIntervalSeconds int64 `json:"pollIntervalSeconds,omitempty"`
+
+	// MaxEventAgeSeconds is the maximum age in seconds of the events
+	// delivered by the adapter. Older events, e.g. replayed after an outage
+	// of the adapter, are skipped. All events are delivered when unset.
+	// +optional
+	MaxEventAgeSeconds int64 `json:"maxEventAgeSeconds,omitempty"`
+
+	// ReconnectBackoffSeconds is the time in seconds the adapter waits before
+	// reconnecting to an unreachable vCenter. The backoff doubles with every
+	// consecutive failure, up to five minutes. Defaults to one second.
+	// +optional
+	ReconnectBackoffSeconds int64 `json:"reconnectBackoffSeconds,omitempty"`
+
+	// Sinks are additional destinations every event is delivered to besides
+	// the sink.
+	// +optional
+	Sinks []duckv1.Destination `json:"sinks,omitempty"`
+
+	// DeadLetterSink is the destination events are sent to when delivery to
+	// the sink fails.
+	// +optional
+	DeadLetterSink *duckv1.Destination `json:"deadLetterSink,omitempty"`
+
+	// CloudEventSource overrides the CloudEvent source attribute of the
+	// emitted events. Defaults to the vCenter host when empty.
+	// +optional
+	CloudEventSource string `json:"cloudEventSource,omitempty"`
+
+	// TypeScheme is the scheme of the CloudEvent types of the emitted events.
+	// With native, the default, the types of this source are emitted, e.g.
+	// com.vmware.vsphere.VmPoweredOnEvent.v0. With veb, the types of the
+	// VMware Event Broker are emitted, e.g.
+	// com.vmware.event.router/VmPoweredOnEvent, so that functions written for
+	// it can be reused.
+	// +optional
+	TypeScheme TypeScheme `json:"typeScheme,omitempty"`
+
+	// PayloadFormat is the format of the CloudEvent data of the emitted
+	// events. With full, the default, the data is the complete vSphere event.
+	// With minimal, the data holds the common fields of all vSphere events
+	// and the names of the entities the event refers to.
+	// +optional
+	PayloadFormat PayloadFormat `json:"payloadFormat,omitempty"`
+
+	// SubjectTemplate overrides the CloudEvent subject attribute of the
+	// emitted events with a Go template evaluated against the vSphere event,
+	// e.g. {{.Vm.Name}}. The subject is omitted when the template cannot be
+	// evaluated for an event. Defaults to the managed object reference of
+	// the entity the event refers to when empty.
+	// +optional
+	SubjectTemplate string `json:"subjectTemplate,omitempty"`
+
+	// LogLevel overrides the log level of the adapter configured in the
+	// config-logging ConfigMap, e.g. debug to troubleshoot a single source.
+	// +optional
+	LogLevel string `json:"logLevel,omitempty"`
+
+	// DisableEntityExtensions disables the CloudEvent extension attributes
+	// describing the vSphere entities of an event, e.g. vspherevmname.
+	// +optional
+	DisableEntityExtensions bool `json:"disableEntityExtensions,omitempty"`
+
+	// CACerts is a PEM encoded CA bundle the adapter trusts when connecting
+	// to vCenter.
+	// +optional
+	CACerts *string `json:"caCerts,omitempty"`
+
+	// SinkCACerts is a PEM encoded CA bundle the adapter trusts when
+	// delivering events to the sinks over HTTPS, e.g. to a broker ingress
+	// served with a private CA.
+	// +optional
+	SinkCACerts *string `json:"sinkCACerts,omitempty"`
+
+	// SinkAudience is the audience of the OIDC token the adapter presents to
+	// the sink, e.g. a Broker requiring authentication. The token is issued
+	// for the ServiceAccount of the adapter. No token is presented when
+	// empty.
+	// +optional
+	SinkAudience *string `json:"sinkAudience,omitempty"`
+
+	// ContentEncoding compresses the HTTP body of the delivered events with
+	// the given content coding and sets the Content-Encoding header. The only
+	// supported coding is gzip. It is applied to the sinks which advertise it
+	// with an Accept-Encoding response header, unless forced. Events are sent
+	// uncompressed when empty.
+	// +optional
+	ContentEncoding string `json:"contentEncoding,omitempty"`
+
+	// ForceContentEncoding applies ContentEncoding to all deliveries, also to
+	// sinks which did not advertise it.
+	// +optional
+	ForceContentEncoding bool `json:"forceContentEncoding,omitempty"`
+
+	// ServiceAccountName is the name of an existing ServiceAccount used by
+	// the adapter. When empty, a ServiceAccount is created for the source.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// ServiceAccountAnnotations are added to the ServiceAccount created for
+	// the source, e.g. to bind it to a cloud IAM role for workload identity.
+	// Annotations added by other controllers are preserved. Must not be set
+	// together with ServiceAccountName.
+	// +optional
+	ServiceAccountAnnotations map[string]string `json:"serviceAccountAnnotations,omitempty"`
+
+	// Replicas is the number of adapter replicas. When greater than 1, the
+	// replicas elect a leader which polls vCenter while the others stand by
+	// to take over. Defaults to 1.
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Paused stops the event flow without deleting the source. The adapter
+	// is scaled to zero replicas while its checkpoint and configuration are
+	// kept. When unpaused, the adapter resumes from the checkpoint, bounded
+	// by checkpointConfig.maxAgeSeconds. Cannot be changed while the source
+	// is being deleted.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// AdapterImage is the container image of the receive adapter, e.g. a
+	// custom build. The image of the controller release is used when empty
+	// or when the adapter-image-override feature is disabled, the default.
+	// +optional
+	AdapterImage string `json:"adapterImage,omitempty"`
+
+	// AdapterOverrides customizes the receive adapter deployment.
+	// +optional
+	AdapterOverrides *AdapterOverrides `json:"adapterOverrides,omitempty"`
+
+	// BatchSize is the maximum number of events sent to the sink in a single
+	// CloudEvents batch (application/cloudevents-batch+json). The sink must
+	// support batched mode when set to a value greater than 1. Events are
+	// sent one by one when unset.
+	// +optional
+	BatchSize int32 `json:"batchSize,omitempty"`
+
+	// BatchTimeoutSeconds is the maximum time in seconds the adapter waits to
+	// fill a batch before sending it. A partial batch is sent right away when
+	// unset.
+	// +optional
+	BatchTimeoutSeconds int64 `json:"batchTimeoutSeconds,omitempty"`
+
+	// Ordered delivers the events in the order in which they were created in
+	// vCenter, i.e. by event key. Without it, events after one which could
+	// not be delivered are still sent. When set, the undelivered event is
+	// sent again after a backoff before any later event, which blocks the
+	// delivery while the sink fails.
+	// +optional
+	Ordered bool `json:"ordered,omitempty"`
+
+	// SinkTimeoutSeconds is the maximum time in seconds of a single delivery
+	// attempt to a sink. A delivery which times out fails and is retried
+	// according to the retry policy. Defaults to 30 seconds.
+	// +optional
+	SinkTimeoutSeconds int64 `json:"sinkTimeoutSeconds,omitempty"`
+
+	// Retry configures the retries of failed event deliveries to the sink.
+	// Failed deliveries are not retried when unset.
+	// +optional
+	Retry *RetrySpec `json:"retry,omitempty"`
+
+	// RateLimit limits the rate of events sent to the sinks. Events are sent
+	// as fast as the sinks accept them when unset.
+	// +optional
+	RateLimit *RateLimitSpec `json:"rateLimit,omitempty"`
+
+	// Proxy configures the proxy the adapter connects to vCenter through.
+	// Event deliveries to the sinks do not use it.
+	// +optional
+	Proxy *ProxySpec `json:"proxy,omitempty"`
+
+	// FailoverAddresses are the addresses of standby vCenters, e.g. of a
+	// vCenter HA pair, the adapter fails over to in order when the vCenter
+	// it is connected to is unreachable. The vCenters must share the
+	// credentials and the event stream of the source address.
+	// +optional
+	FailoverAddresses []string `json:"failoverAddresses,omitempty"`
+
+	// FailoverThreshold is the number of consecutive connection failures
+	// after which the adapter fails over to the next vCenter. Defaults to 3.
+	// +optional
+	FailoverThreshold int32 `json:"failoverThreshold,omitempty"`
+}
+
+// BackoffPolicy is the policy used to compute the delay between retries.
+type BackoffPolicy string
+
+const (
+	// BackoffPolicyLinear increases the delay between retries linearly.
+	BackoffPolicyLinear BackoffPolicy = "linear"
+	// BackoffPolicyExponential increases the delay between retries
+	// exponentially.
+	BackoffPolicyExponential BackoffPolicy = "exponential"
+)
+
+// RetrySpec configures the retries of failed event deliveries.
+type RetrySpec struct {
+	// MaxRetries is the maximum number of retries of a failed delivery.
+	MaxRetries int32 `json:"maxRetries"`
+
+	// InitialBackoffSeconds is the delay in seconds before the first retry.
+	// +optional
+	InitialBackoffSeconds int64 `json:"initialBackoffSeconds,omitempty"`
+
+	// BackoffPolicy is the policy used to compute the delay between retries,
+	// either linear or exponential. Defaults to exponential.
+	// +optional
+	BackoffPolicy BackoffPolicy `json:"backoffPolicy,omitempty"`
+}
+
+// RateLimitSpec configures the rate limit of events sent to the sinks.
+type RateLimitSpec struct {
+	// EventsPerSecond is the maximum sustained number of events per second.
+	EventsPerSecond int32 `json:"eventsPerSecond"`
+
+	// Burst is the maximum number of events sent at once. Defaults to
+	// EventsPerSecond.
+	// +optional
+	Burst int32 `json:"burst,omitempty"`
+
+	// OverflowPolicy is the policy of events exceeding the rate limit. With
+	// block, the default, the adapter delays the events and stops reading
+	// from vCenter until they may be sent. With drop, the events are
+	// dropped and not delivered.
+	// +optional
+	OverflowPolicy RateLimitOverflowPolicy `json:"overflowPolicy,omitempty"`
+}
+
+// TypeScheme is the scheme of the CloudEvent types of the emitted events.
+type TypeScheme string
+
+const (
+	// TypeSchemeNative emits the CloudEvent types of this source.
+	TypeSchemeNative TypeScheme = "native"
+	// TypeSchemeVEB emits the CloudEvent types of the VMware Event Broker.
+	TypeSchemeVEB TypeScheme = "veb"
+)
+
+// PayloadFormat is the format of the CloudEvent data of the emitted events.
+type PayloadFormat string
+
+const (
+	// PayloadFormatFull emits the complete vSphere event.
+	PayloadFormatFull PayloadFormat = "full"
+	// PayloadFormatMinimal emits the common fields of all vSphere events.
+	PayloadFormatMinimal PayloadFormat = "minimal"
+)
+
+// StartFrom is where the adapter starts reading the event history of vCenter
+// without checkpoint.
+type StartFrom string
+
+const (
+	// StartFromNow starts at the current vCenter time.
+	StartFromNow StartFrom = "now"
+	// StartFromEarliest starts at the oldest event retained by vCenter.
+	StartFromEarliest StartFrom = "earliest"
+)
+
+// RateLimitOverflowPolicy is the policy of events exceeding the rate limit.
+type RateLimitOverflowPolicy string
+
+const (
+	// RateLimitOverflowBlock delays the events exceeding the rate limit.
+	RateLimitOverflowBlock RateLimitOverflowPolicy = "block"
+	// RateLimitOverflowDrop drops the events exceeding the rate limit.
+	RateLimitOverflowDrop RateLimitOverflowPolicy = "drop"
+)
+
+// ProxySpec configures the proxy of the vCenter API connection.
+type ProxySpec struct {
+	// HTTPProxy is the URL of the proxy for http vCenter URLs.
+	// +optional
+	HTTPProxy string `json:"httpProxy,omitempty"`
+
+	// HTTPSProxy is the URL of the proxy for https vCenter URLs.
+	// +optional
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+
+	// NoProxy is a comma-separated list of hosts, domains and CIDRs reached
+	// without the proxy, in the format of NO_PROXY.
+	// +optional
+	NoProxy string `json:"noProxy,omitempty"`
+}
+
+// AdapterOverrides holds the settings overriding the defaults of the receive
+// adapter deployment.
+type AdapterOverrides struct {
+	// Resources are the compute resources of the adapter container. When not
+	// set, the controller defaults are used.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// NodeSelector constrains the adapter pod to nodes with matching labels.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations of the adapter pod.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity contains the scheduling constraints of the adapter pod.
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// Labels are additional labels of the adapter pods, e.g. to select them
+	// in network policies. Labels set by the controller take precedence and
+	// keys with the vspheresources.sources.tanzu.vmware.com/ prefix are
+	// reserved.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are additional annotations of the adapter pods. Annotations
+	// set by the controller take precedence and keys with the
+	// vspheresources.sources.tanzu.vmware.com/ prefix are reserved.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Env holds additional environment variables of the adapter container.
+	// Variables reserved by the controller must not be used.
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// TerminationGracePeriodSeconds is the termination grace period of the
+	// adapter pod. All but 10 seconds of it are spent delivering pending
+	// events and saving the final checkpoint when the adapter is stopped.
+	// Defaults to 30 seconds.
+	// +optional
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+
+	// SecurityContext replaces the security context of the adapter
+	// container. When not set, the adapter runs as non-root with a read-only
+	// root filesystem, no privilege escalation, all capabilities dropped and
+	// the runtime default seccomp profile.
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+
+	// ImagePullSecrets are the secrets to pull the adapter image from a
+	// private registry. They are set on the adapter pod and added to the
+	// ServiceAccount generated for the source.
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+}
+
+// EventFilter matches vCenter events by type and, optionally, by the managed
+// entity the event refers to.
+type EventFilter struct {
+	// Type is the vSphere event type, e.g. VmPoweredOnEvent. For EventEx and
+	// ExtendedEvent this is the EventTypeId.
+	Type string `json:"type"`
+
+	// Entity is the optional managed object reference value, e.g. vm-42, of
+	// the entity the event must refer to.
+	// +optional
+	Entity string `json:"entity,omitempty"`
 }
 
 type VCheckpointSpec struct {
 	MaxAgeSeconds int64 `json:"maxAgeSeconds"`
 	PeriodSeconds int64 `json:"periodSeconds"`
+
+	// Mode controls which events are replayed from the last checkpoint when
+	// the adapter restarts. Defaults to at-least-once.
+	// +optional
+	Mode CheckpointMode `json:"mode,omitempty"`
+
+	// Backend is the Kubernetes resource the adapter stores its checkpoint
+	// in. Defaults to configmap.
+	// +optional
+	Backend CheckpointBackend `json:"backend,omitempty"`
 }
 
+// CheckpointMode is the replay mode of events since the last checkpoint.
+type CheckpointMode string
+
+const (
+	// CheckpointModeAtLeastOnce replays all events since the last
+	// checkpoint, including events already delivered to the sink.
+	CheckpointModeAtLeastOnce CheckpointMode = "at-least-once"
+	// CheckpointModeExactlyOnceBestEffort skips replayed events at or below
+	// the last delivered event key recorded in the checkpoint. Events
+	// delivered after the last checkpoint are still replayed.
+	CheckpointModeExactlyOnceBestEffort CheckpointMode = "exactly-once-best-effort"
+)
+
+// CheckpointBackend is the Kubernetes resource the checkpoint is stored in.
+type CheckpointBackend string
+
+const (
+	// CheckpointBackendConfigMap stores the checkpoint in a data key of a
+	// ConfigMap owned by the source.
+	CheckpointBackendConfigMap CheckpointBackend = "configmap"
+	// CheckpointBackendLease stores the checkpoint in an annotation of a
+	// coordination.k8s.io Lease owned by the source, which is cheaper to
+	// write frequently.
+	CheckpointBackendLease CheckpointBackend = "lease"
+)
+
 const (
 	// VSphereSourceConditionReady is set to reflect the overall state of the resource.
 	VSphereSourceConditionReady = apis.ConditionReady
@@ -58,16 +492,83 @@ const (
 	// VSphereSourceConditionSourceReady is set to reflect the state of the source part of the VSphereSource.
 	VSphereSourceConditionSourceReady = "SourceReady"
 
+	// VSphereSourceConditionSinkProvided is set to reflect whether the sink of the VSphereSource could be resolved.
+	VSphereSourceConditionSinkProvided = "SinkProvided"
+
+	// VSphereSourceConditionDeadLetterSinkResolved is set to reflect whether the dead letter sink of the
+	// VSphereSource could be resolved. It does not affect the readiness of the VSphereSource.
+	VSphereSourceConditionDeadLetterSinkResolved = "DeadLetterSinkResolved"
+
 	// VSphereSourceConditionAuthReady is set to reflect the state of the auth part of the VSphereSource.
 	VSphereSourceConditionAuthReady = "AuthReady"
 
 	// VSphereSourceConditionAdapterReady is set to reflect the state of the adapter part of the VSphereSource.
 	VSphereSourceConditionAdapterReady = "AdapterReady"
+
+	// VSphereSourceConditionCheckpointCurrent is set to reflect whether the lag of the last checkpoint of the
+	// adapter is within the threshold configured in the controller. It does not affect the Ready condition.
+	VSphereSourceConditionCheckpointCurrent = "CheckpointCurrent"
+
+	// VSphereSourceConditionAdapterHealthy is set to reflect whether the adapter reports a recent heartbeat
+	// without persistent failures to read or deliver events. It does not affect the Ready condition.
+	VSphereSourceConditionAdapterHealthy = "AdapterHealthy"
+
+	// VSphereSourceConditionTLSVerified is set to False while TLS verification against vCenter is disabled with
+	// skipTLSVerify. It does not affect the Ready condition.
+	VSphereSourceConditionTLSVerified = "TLSVerified"
+
+	// VSphereSourceConditionPaused is set while the source is paused, i.e. its adapter is scaled to zero
+	// replicas. The Ready condition stays True with the reason Paused.
+	VSphereSourceConditionPaused = "Paused"
 )
 
 // VSphereSourceStatus communicates the observed state of the VSphereSource (from the controller).
 type VSphereSourceStatus struct {
 	duckv1.SourceStatus `json:",inline"`
+
+	// SinkURIs are the resolved URIs of the sink followed by the additional
+	// sinks.
+	// +optional
+	SinkURIs []*apis.URL `json:"sinkUris,omitempty"`
+
+	// DeadLetterSinkURI is the resolved URI of the dead letter sink.
+	// +optional
+	DeadLetterSinkURI *apis.URL `json:"deadLetterSinkUri,omitempty"`
+
+	// Retry is the effective retry policy of event deliveries to the sink.
+	// +optional
+	Retry *RetrySpec `json:"retry,omitempty"`
+
+	// Auth is the OIDC identity the adapter authenticates with to the sink.
+	// +optional
+	Auth *AuthStatus `json:"auth,omitempty"`
+
+	// Checkpoint is the last checkpoint saved by the adapter.
+	// +optional
+	Checkpoint *CheckpointStatus `json:"checkpoint,omitempty"`
+}
+
+// CheckpointStatus is the progress of the adapter in the vCenter event stream
+// according to its last checkpoint.
+type CheckpointStatus struct {
+	// LastEventKey is the key of the last event processed by the adapter.
+	LastEventKey int32 `json:"lastEventKey"`
+
+	// LastEventTime is the time the last event processed by the adapter was
+	// created in vCenter.
+	LastEventTime metav1.Time `json:"lastEventTime"`
+
+	// LagSeconds is the time in seconds between the creation of the last
+	// processed event and the last reconciliation of the source.
+	LagSeconds int64 `json:"lagSeconds"`
+}
+
+// AuthStatus is the OIDC identity of the adapter.
+type AuthStatus struct {
+	// ServiceAccountName is the name of the ServiceAccount the tokens
+	// presented to the sink are issued for.
+	// +optional
+	ServiceAccountName *string `json:"serviceAccountName,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
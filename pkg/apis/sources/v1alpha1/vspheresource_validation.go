@@ -6,32 +6,288 @@ SPDX-License-Identifier: Apache-2.0
 package v1alpha1
 
 import (
+	"bytes"
 	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
 	"strings"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap/zapcore"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/vsphere"
+)
+
+// maxPollIntervalSeconds is the upper bound for spec.pollIntervalSeconds.
+const maxPollIntervalSeconds = 600
+
+const (
+	// maxBatchSize is the upper bound for spec.batchSize.
+	maxBatchSize = 1000
+	// maxBatchTimeoutSeconds is the upper bound for spec.batchTimeoutSeconds.
+	maxBatchTimeoutSeconds = 600
+	// maxSinkTimeoutSeconds is the upper bound for spec.sinkTimeoutSeconds.
+	maxSinkTimeoutSeconds = 600
+)
+
+const (
+	// reservedAdapterEnvPrefix is the prefix of the adapter environment
+	// variables set by the controller.
+	reservedAdapterEnvPrefix = "VSPHERE_"
+
+	// reservedAdapterMetadataPrefix is the prefix of the labels and
+	// annotations of the adapter pods set by the controller.
+	reservedAdapterMetadataPrefix = "vspheresources.sources.tanzu.vmware.com/"
+)
+
+// reservedAdapterAnnotations are the annotations of the adapter pods set by
+// the controller without the reserved prefix.
+var reservedAdapterAnnotations = sets.NewString(
+	"checksum/credentials",
+)
+
+// reservedAdapterEnvVars are the adapter environment variables set by the
+// controller and the VSphereBinding which must not be overridden.
+var reservedAdapterEnvVars = sets.NewString(
+	"NAMESPACE",
+	"NAME",
+	"K_SINK",
+	"K_CE_OVERRIDES",
+	"K_CA_CERTS",
+	"K_LOGGING_CONFIG",
+	"K_METRICS_CONFIG",
+	"K_TRACING_CONFIG",
+	"VC_URL",
+	"VC_INSECURE",
+	"VC_USERNAME",
+	"VC_PASSWORD",
+	"VC_SECRET_PATH",
+	"VC_USERNAME_KEY",
+	"VC_PASSWORD_KEY",
+	"VC_CA_CERT_KEY",
+	"VC_CA_CERT_PATH",
+	"VC_CREDENTIALS_DIR",
+	"VC_CACERTS",
+	"VC_HTTP_PROXY",
+	"VC_HTTPS_PROXY",
+	"VC_NO_PROXY",
+	"VC_USER_AGENT",
 )
 
 // Validate implements apis.Validatable
 func (vs *VSphereSource) Validate(ctx context.Context) *apis.FieldError {
-	return vs.Spec.Validate(ctx).ViaField("spec")
+	var original *VAuthSpec
+	var err *apis.FieldError
+	if apis.IsInUpdate(ctx) {
+		baseline := apis.GetBaseline(ctx).(*VSphereSource)
+		original = &baseline.Spec.VAuthSpec
+		err = validatePausedUpdate(baseline, vs)
+	}
+	return vs.Spec.Validate(ctx).
+		Also(err).
+		Also(validateSecretKeysExist(ctx, vs.Namespace, &vs.Spec.VAuthSpec, original)).
+		ViaField("spec")
+}
+
+// validatePausedUpdate rejects pausing or unpausing a source which is being
+// deleted, so that the adapter stopped by the finalizer is not scaled up
+// again.
+func validatePausedUpdate(original, vs *VSphereSource) *apis.FieldError {
+	if original.DeletionTimestamp == nil || original.Spec.Paused == vs.Spec.Paused {
+		return nil
+	}
+	return &apis.FieldError{
+		Message: "Cannot change paused while the source is being deleted",
+		Paths:   []string{"paused"},
+	}
 }
 
 // Validate implements apis.Validatable
 func (vsss *VSphereSourceSpec) Validate(ctx context.Context) *apis.FieldError {
-	err := vsss.Sink.Validate(ctx).ViaField("sink").
+	err := validateSink(ctx, vsss.Sink).
 		Also(vsss.VAuthSpec.Validate(ctx)).
 		Also(vsss.CheckpointConfig.
-			Validate(ctx))
+			Validate(ctx)).
+		Also(vsss.DeadLetterSink.Validate(ctx).ViaField("deadLetterSink")).
+		Also(validateCEOverrides(ctx, vsss.CloudEventOverrides).ViaField("ceOverrides"))
 
 	encoding := strings.ToLower(vsss.PayloadEncoding)
 	if (encoding != cloudevents.ApplicationJSON) && (encoding != cloudevents.ApplicationXML) {
 		err = err.Also(apis.ErrInvalidValue(encoding, "payloadEncoding"))
 	}
+
+	if vsss.CloudEventSource != "" {
+		if _, perr := url.Parse(vsss.CloudEventSource); perr != nil {
+			err = err.Also(apis.ErrInvalidValue(vsss.CloudEventSource, "cloudEventSource", perr.Error()))
+		}
+	}
+
+	switch vsss.StartFrom {
+	case "", StartFromNow, StartFromEarliest:
+	default:
+		err = err.Also(apis.ErrInvalidValue(vsss.StartFrom, "startFrom"))
+	}
+
+	switch vsss.TypeScheme {
+	case "", TypeSchemeNative, TypeSchemeVEB:
+	default:
+		err = err.Also(apis.ErrInvalidValue(vsss.TypeScheme, "typeScheme"))
+	}
+
+	switch vsss.PayloadFormat {
+	case "", PayloadFormatFull, PayloadFormatMinimal:
+	default:
+		err = err.Also(apis.ErrInvalidValue(vsss.PayloadFormat, "payloadFormat"))
+	}
+
+	if vsss.SubjectTemplate != "" {
+		if _, perr := vsphere.ParseSubjectTemplate(vsss.SubjectTemplate); perr != nil {
+			err = err.Also(apis.ErrInvalidValue(vsss.SubjectTemplate, "subjectTemplate", perr.Error()))
+		}
+	}
+
+	if vsss.LogLevel != "" {
+		var level zapcore.Level
+		if perr := level.UnmarshalText([]byte(vsss.LogLevel)); perr != nil {
+			err = err.Also(apis.ErrInvalidValue(vsss.LogLevel, "logLevel", perr.Error()))
+		}
+	}
+
+	if vsss.CACerts != nil {
+		if perr := validateCACerts(*vsss.CACerts); perr != nil {
+			err = err.Also(apis.ErrGeneric(fmt.Sprintf("invalid CA certificates: %v", perr), "caCerts"))
+		}
+	}
+
+	if vsss.SinkAudience != nil && strings.TrimSpace(*vsss.SinkAudience) == "" {
+		err = err.Also(apis.ErrInvalidValue(*vsss.SinkAudience, "sinkAudience"))
+	}
+
+	if vsss.ContentEncoding != "" && vsss.ContentEncoding != vsphere.ContentEncodingGzip {
+		err = err.Also(apis.ErrInvalidValue(vsss.ContentEncoding, "contentEncoding",
+			fmt.Sprintf("supported encodings: %s", vsphere.ContentEncodingGzip)))
+	}
+	if vsss.ForceContentEncoding && vsss.ContentEncoding == "" {
+		err = err.Also(apis.ErrMissingField("contentEncoding"))
+	}
+
+	if vsss.SinkCACerts != nil {
+		if perr := validateCACerts(*vsss.SinkCACerts); perr != nil {
+			err = err.Also(apis.ErrGeneric(fmt.Sprintf("invalid CA certificates: %v", perr), "sinkCACerts"))
+		}
+	}
+
+	if vsss.ServiceAccountName != "" {
+		if errs := validation.IsDNS1123Subdomain(vsss.ServiceAccountName); len(errs) > 0 {
+			err = err.Also(apis.ErrInvalidValue(vsss.ServiceAccountName, "serviceAccountName", strings.Join(errs, ", ")))
+		}
+	}
+
+	if len(vsss.ServiceAccountAnnotations) > 0 {
+		if vsss.ServiceAccountName != "" {
+			err = err.Also(apis.ErrMultipleOneOf("serviceAccountName", "serviceAccountAnnotations"))
+		}
+		for k := range vsss.ServiceAccountAnnotations {
+			if errs := validation.IsQualifiedName(strings.ToLower(k)); len(errs) > 0 {
+				err = err.Also(apis.ErrInvalidKeyName(k, "serviceAccountAnnotations", errs...))
+			}
+		}
+	}
+
+	if vsss.PollIntervalSeconds < 0 || vsss.PollIntervalSeconds > maxPollIntervalSeconds {
+		err = err.Also(apis.ErrOutOfBoundsValue(vsss.PollIntervalSeconds, 1, maxPollIntervalSeconds, "pollIntervalSeconds"))
+	}
+
+	if vsss.MaxEventAgeSeconds < 0 {
+		err = err.Also(apis.ErrInvalidValue(vsss.MaxEventAgeSeconds, "maxEventAgeSeconds"))
+	}
+
+	if vsss.ReconnectBackoffSeconds < 0 {
+		err = err.Also(apis.ErrInvalidValue(vsss.ReconnectBackoffSeconds, "reconnectBackoffSeconds"))
+	}
+
+	if vsss.BatchSize < 0 || vsss.BatchSize > maxBatchSize {
+		err = err.Also(apis.ErrOutOfBoundsValue(vsss.BatchSize, 1, maxBatchSize, "batchSize"))
+	}
+
+	if vsss.BatchTimeoutSeconds < 0 || vsss.BatchTimeoutSeconds > maxBatchTimeoutSeconds {
+		err = err.Also(apis.ErrOutOfBoundsValue(vsss.BatchTimeoutSeconds, 0, maxBatchTimeoutSeconds, "batchTimeoutSeconds"))
+	}
+
+	if vsss.SinkTimeoutSeconds < 0 || vsss.SinkTimeoutSeconds > maxSinkTimeoutSeconds {
+		err = err.Also(apis.ErrOutOfBoundsValue(vsss.SinkTimeoutSeconds, 1, maxSinkTimeoutSeconds, "sinkTimeoutSeconds"))
+	}
+
+	if vsss.Replicas != nil && *vsss.Replicas <= 0 {
+		err = err.Also(apis.ErrInvalidValue(*vsss.Replicas, "replicas"))
+	}
+
+	for i, sink := range vsss.Sinks {
+		err = err.Also(sink.Validate(ctx).ViaFieldIndex("sinks", i))
+	}
+
+	err = err.Also(vsss.AdapterOverrides.Validate(ctx).ViaField("adapterOverrides"))
+	err = err.Also(vsss.Retry.Validate(ctx).ViaField("retry"))
+	err = err.Also(vsss.RateLimit.Validate(ctx).ViaField("rateLimit"))
+	err = err.Also(vsss.Proxy.Validate(ctx).ViaField("proxy"))
+
+	for i, address := range vsss.FailoverAddresses {
+		if u, perr := url.Parse(address); perr != nil || u.Host == "" {
+			err = err.Also(apis.ErrInvalidArrayValue(address, "failoverAddresses", i))
+		}
+	}
+
+	if vsss.FailoverThreshold < 0 {
+		err = err.Also(apis.ErrInvalidValue(vsss.FailoverThreshold, "failoverThreshold"))
+	}
+
+	for i, f := range vsss.EventFilters {
+		err = err.Also(f.Validate(ctx).ViaFieldIndex("eventFilters", i))
+	}
+
+	for i, et := range vsss.EventTypes {
+		if strings.TrimSpace(et) == "" {
+			err = err.Also(apis.ErrInvalidArrayValue(et, "eventTypes", i))
+		}
+	}
+
+	categories := sets.NewString()
+	for i, c := range vsss.Categories {
+		if !sets.NewString(vsphere.Categories...).Has(c) || categories.Has(c) {
+			err = err.Also(apis.ErrInvalidArrayValue(c, "categories", i))
+		}
+		categories.Insert(c)
+	}
 	return err
 }
 
+// validateSink returns a missing field error for an empty sink, which would
+// otherwise only fail to resolve at reconcile time, and validates the sink
+// otherwise.
+func validateSink(ctx context.Context, sink duckv1.Destination) *apis.FieldError {
+	if sink.Ref == nil && sink.URI == nil {
+		err := apis.ErrMissingField("sink")
+		err.Details = "a sink ref or uri is required to deliver events"
+		return err
+	}
+	return sink.Validate(ctx).ViaField("sink")
+}
+
+// Validate implements apis.Validatable
+func (ef EventFilter) Validate(ctx context.Context) *apis.FieldError {
+	if strings.TrimSpace(ef.Type) == "" {
+		return apis.ErrMissingField("type")
+	}
+	return nil
+}
+
 func (vcs VCheckpointSpec) Validate(ctx context.Context) (err *apis.FieldError) {
 	if vcs.PeriodSeconds < 0 {
 		err = err.Also(apis.ErrInvalidValue(vcs.PeriodSeconds, "checkpointConfig.periodSeconds"))
@@ -41,5 +297,222 @@ func (vcs VCheckpointSpec) Validate(ctx context.Context) (err *apis.FieldError)
 		err = err.Also(apis.ErrInvalidValue(vcs.MaxAgeSeconds, "checkpointConfig.maxAgeSeconds"))
 	}
 
+	// maxAge of 0 disables event replay so the period is not bounded by it
+	if vcs.MaxAgeSeconds > 0 && vcs.PeriodSeconds > vcs.MaxAgeSeconds {
+		err = err.Also(apis.ErrGeneric("periodSeconds must not be greater than maxAgeSeconds",
+			"checkpointConfig.periodSeconds", "checkpointConfig.maxAgeSeconds"))
+	}
+
+	switch vcs.Mode {
+	case "", CheckpointModeAtLeastOnce, CheckpointModeExactlyOnceBestEffort:
+	default:
+		err = err.Also(apis.ErrInvalidValue(vcs.Mode, "checkpointConfig.mode"))
+	}
+
+	switch vcs.Backend {
+	case "", CheckpointBackendConfigMap, CheckpointBackendLease:
+	default:
+		err = err.Also(apis.ErrInvalidValue(vcs.Backend, "checkpointConfig.backend"))
+	}
+
+	return err
+}
+
+// Validate implements apis.Validatable
+func (ao *AdapterOverrides) Validate(ctx context.Context) (err *apis.FieldError) {
+	if ao == nil {
+		return nil
+	}
+
+	for k, v := range ao.Labels {
+		if strings.HasPrefix(k, reservedAdapterMetadataPrefix) {
+			err = err.Also(apis.ErrInvalidKeyName(k, "labels", "label is reserved"))
+			continue
+		}
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			err = err.Also(apis.ErrInvalidKeyName(k, "labels", errs...))
+		}
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			err = err.Also(apis.ErrInvalidValue(v, apis.CurrentField, errs...).ViaFieldKey("labels", k))
+		}
+	}
+
+	for k := range ao.Annotations {
+		if strings.HasPrefix(k, reservedAdapterMetadataPrefix) || reservedAdapterAnnotations.Has(k) {
+			err = err.Also(apis.ErrInvalidKeyName(k, "annotations", "annotation is reserved"))
+			continue
+		}
+		if errs := validation.IsQualifiedName(strings.ToLower(k)); len(errs) > 0 {
+			err = err.Also(apis.ErrInvalidKeyName(k, "annotations", errs...))
+		}
+	}
+
+	for i, env := range ao.Env {
+		if env.Name == "" {
+			err = err.Also(apis.ErrMissingField("name").ViaFieldIndex("env", i))
+			continue
+		}
+		if reservedAdapterEnvVars.Has(env.Name) || strings.HasPrefix(env.Name, reservedAdapterEnvPrefix) {
+			err = err.Also(apis.ErrInvalidValue(env.Name, "name", "environment variable is reserved").
+				ViaFieldIndex("env", i))
+		}
+	}
+
+	for i, secret := range ao.ImagePullSecrets {
+		if secret.Name == "" {
+			err = err.Also(apis.ErrMissingField("name").ViaFieldIndex("imagePullSecrets", i))
+		}
+	}
+
+	if ao.TerminationGracePeriodSeconds != nil {
+		margin := int64(vsphere.ShutdownGracePeriodMargin.Seconds())
+		if *ao.TerminationGracePeriodSeconds <= margin {
+			err = err.Also(apis.ErrInvalidValue(*ao.TerminationGracePeriodSeconds, "terminationGracePeriodSeconds",
+				fmt.Sprintf("must be greater than %d", margin)))
+		}
+	}
+	return err
+}
+
+// Validate implements apis.Validatable
+func (rs *RetrySpec) Validate(ctx context.Context) (err *apis.FieldError) {
+	if rs == nil {
+		return nil
+	}
+
+	if rs.MaxRetries < 0 {
+		err = err.Also(apis.ErrInvalidValue(rs.MaxRetries, "maxRetries"))
+	}
+
+	if rs.InitialBackoffSeconds < 0 {
+		err = err.Also(apis.ErrInvalidValue(rs.InitialBackoffSeconds, "initialBackoffSeconds"))
+	}
+
+	switch rs.BackoffPolicy {
+	case "", BackoffPolicyLinear, BackoffPolicyExponential:
+	default:
+		err = err.Also(apis.ErrInvalidValue(rs.BackoffPolicy, "backoffPolicy"))
+	}
+	return err
+}
+
+// Validate implements apis.Validatable
+func (rls *RateLimitSpec) Validate(ctx context.Context) (err *apis.FieldError) {
+	if rls == nil {
+		return nil
+	}
+
+	if rls.EventsPerSecond <= 0 {
+		err = err.Also(apis.ErrInvalidValue(rls.EventsPerSecond, "eventsPerSecond"))
+	}
+
+	if rls.Burst < 0 {
+		err = err.Also(apis.ErrInvalidValue(rls.Burst, "burst"))
+	}
+
+	switch rls.OverflowPolicy {
+	case "", RateLimitOverflowBlock, RateLimitOverflowDrop:
+	default:
+		err = err.Also(apis.ErrInvalidValue(rls.OverflowPolicy, "overflowPolicy"))
+	}
 	return err
 }
+
+// Validate implements apis.Validatable
+func (ps *ProxySpec) Validate(ctx context.Context) (err *apis.FieldError) {
+	if ps == nil {
+		return nil
+	}
+
+	if ps.HTTPProxy == "" && ps.HTTPSProxy == "" {
+		err = err.Also(apis.ErrMissingOneOf("httpProxy", "httpsProxy"))
+	}
+	if perr := validateProxyURL(ps.HTTPProxy); perr != nil {
+		err = err.Also(apis.ErrInvalidValue(ps.HTTPProxy, "httpProxy", perr.Error()))
+	}
+	if perr := validateProxyURL(ps.HTTPSProxy); perr != nil {
+		err = err.Also(apis.ErrInvalidValue(ps.HTTPSProxy, "httpsProxy", perr.Error()))
+	}
+	return err
+}
+
+// validateProxyURL returns an error if the given proxy is not an http, https
+// or socks5 URL with a host. An empty proxy is valid.
+func validateProxyURL(proxy string) error {
+	if proxy == "" {
+		return nil
+	}
+
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("missing host")
+	}
+	return nil
+}
+
+// ceContextAttributes are the CloudEvent context attributes, which cannot be
+// overridden with extensions.
+var ceContextAttributes = sets.NewString("id", "source", "specversion", "type",
+	"datacontenttype", "dataschema", "subject", "time")
+
+// validateCEOverrides returns an error if an extension name is invalid, is not
+// lowercase or is a CloudEvent context attribute. The CloudEvents spec only
+// allows lowercase attribute names, which the alphanumeric check of
+// CloudEventOverrides does not enforce.
+func validateCEOverrides(ctx context.Context, ceOverrides *duckv1.CloudEventOverrides) *apis.FieldError {
+	if ceOverrides == nil {
+		return nil
+	}
+
+	err := ceOverrides.Validate(ctx)
+	for _, name := range sets.StringKeySet(ceOverrides.Extensions).List() {
+		switch lower := strings.ToLower(name); {
+		case ceContextAttributes.Has(lower):
+			err = err.Also(apis.ErrInvalidKeyName(name, "extensions",
+				"CloudEvent context attributes cannot be overridden"))
+		case name != lower:
+			err = err.Also(apis.ErrInvalidKeyName(name, "extensions",
+				"CloudEvent attribute names must be lowercase"))
+		}
+	}
+	return err
+}
+
+// validateCACerts returns an error if the given string is not a bundle of one
+// or more PEM encoded certificates.
+func validateCACerts(caCerts string) error {
+	rest := []byte(caCerts)
+	var count int
+
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("unexpected PEM block type %q", block.Type)
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return err
+		}
+		count++
+	}
+
+	if count == 0 {
+		return errors.New("no PEM encoded certificate found")
+	}
+	if len(bytes.TrimSpace(rest)) > 0 {
+		return errors.New("unexpected trailing data after PEM encoded certificates")
+	}
+	return nil
+}
@@ -12,11 +12,28 @@ import (
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/pkg/ptr"
 
 	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	testCACerts = `-----BEGIN CERTIFICATE-----
+MIIBhzCCAS2gAwIBAgIUK51H+ihNVscT6/sdVjm5yXJBIGEwCgYIKoZIzj0EAwIw
+GDEWMBQGA1UEAwwNdmNlbnRlci5sb2NhbDAgFw0yNjEwMTcxODE2MzFaGA8yMTI2
+MDkyMzE4MTYzMVowGDEWMBQGA1UEAwwNdmNlbnRlci5sb2NhbDBZMBMGByqGSM49
+AgEGCCqGSM49AwEHA0IABJuO9dOjGZkY7Pj91sbmMWAxGXKytRBH/QG9NRH9KDuB
+ics/pEkermZ1p/53zQjTOMU64mQrN03oJmdgvhjZqcejUzBRMB0GA1UdDgQWBBSl
+f6I/PaqpU7MvB8GJjE29QUWOXDAfBgNVHSMEGDAWgBSlf6I/PaqpU7MvB8GJjE29
+QUWOXDAPBgNVHRMBAf8EBTADAQH/MAoGCCqGSM49BAMCA0gAMEUCICyzSp2ws88f
+Udq+HsLn29Q1cXSY3q7/3NggVM5O7wdJAiEAnz6cu2HlAk4HWk4SJ7e5Ff9IBP1S
+WZy4UZAUfOmRINw=
+-----END CERTIFICATE-----
+`
+)
+
 var (
 	validSourceSpec = duckv1.SourceSpec{
 		Sink: duckv1.Destination{
@@ -97,7 +114,26 @@ func TestVSphereSourceValidation(t *testing.T) {
 				PayloadEncoding: cloudevents.ApplicationXML,
 			},
 		},
-		want: apis.ErrGeneric("expected at least one, got none", "spec.sink.ref", "spec.sink.uri"),
+		want: &apis.FieldError{
+			Message: "missing field(s)",
+			Paths:   []string{"spec.sink"},
+			Details: "a sink ref or uri is required to deliver events",
+		},
+	}, {
+		name: "missing sink and address",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				PayloadEncoding: cloudevents.ApplicationXML,
+			},
+		},
+		want: (&apis.FieldError{
+			Message: "missing field(s)",
+			Paths:   []string{"spec.sink"},
+			Details: "a sink ref or uri is required to deliver events",
+		}).Also(apis.ErrMissingField("spec.address.host", "spec.secretRef.name")),
 	}, {
 		name: "invalid CheckpointConfig",
 		c: &VSphereSource{
@@ -116,11 +152,1121 @@ func TestVSphereSourceValidation(t *testing.T) {
 		},
 		want: apis.ErrInvalidValue("-10", "spec.checkpointConfig.maxAgeSeconds").Also(apis.ErrInvalidValue("-5",
 			"spec.checkpointConfig.periodSeconds")),
-	}}
-
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			got := test.c.Validate(context.Background())
+	}, {
+		name: "CheckpointConfig period greater than maxAge",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec: validSourceSpec,
+				VAuthSpec:  validVAuthSpec,
+				CheckpointConfig: VCheckpointSpec{
+					MaxAgeSeconds: 60,
+					PeriodSeconds: 120,
+				},
+				PayloadEncoding: cloudevents.ApplicationXML,
+			},
+		},
+		want: apis.ErrGeneric("periodSeconds must not be greater than maxAgeSeconds",
+			"spec.checkpointConfig.periodSeconds", "spec.checkpointConfig.maxAgeSeconds"),
+	}, {
+		name: "CheckpointConfig replay disabled",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec: validSourceSpec,
+				VAuthSpec:  validVAuthSpec,
+				CheckpointConfig: VCheckpointSpec{
+					MaxAgeSeconds: 0,
+					PeriodSeconds: 10,
+				},
+				PayloadEncoding: cloudevents.ApplicationXML,
+			},
+		},
+		want: nil,
+	}, {
+		name: "valid eventFilters",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				EventFilters: []EventFilter{{
+					Type: "VmPoweredOnEvent",
+				}, {
+					Type:   "VmPoweredOffEvent",
+					Entity: "vm-42",
+				}},
+			},
+		},
+		want: nil,
+	}, {
+		name: "empty eventFilters entry",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				EventFilters: []EventFilter{{
+					Type: "VmPoweredOnEvent",
+				}, {
+					Entity: "vm-42",
+				}},
+			},
+		},
+		want: apis.ErrMissingField("spec.eventFilters[1].type"),
+	}, {
+		name: "valid eventTypes",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				EventTypes:      []string{"VmPoweredOnEvent", "VmPoweredOffEvent"},
+			},
+		},
+		want: nil,
+	}, {
+		name: "empty eventTypes entry",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				EventTypes:      []string{"VmPoweredOnEvent", ""},
+			},
+		},
+		want: apis.ErrInvalidArrayValue("", "spec.eventTypes", 1),
+	}, {
+		name: "valid categories",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				Categories:      []string{"event", "task", "alarm"},
+			},
+		},
+		want: nil,
+	}, {
+		name: "unsupported and duplicate categories",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				Categories:      []string{"task", "metric", "task"},
+			},
+		},
+		want: apis.ErrInvalidArrayValue("metric", "spec.categories", 1).
+			Also(apis.ErrInvalidArrayValue("task", "spec.categories", 2)),
+	}, {
+		name: "pollIntervalSeconds out of bounds",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:          validSourceSpec,
+				VAuthSpec:           validVAuthSpec,
+				PayloadEncoding:     cloudevents.ApplicationXML,
+				PollIntervalSeconds: 3600,
+			},
+		},
+		want: apis.ErrOutOfBoundsValue(3600, 1, 600, "spec.pollIntervalSeconds"),
+	}, {
+		name: "negative sinkTimeoutSeconds",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:         validSourceSpec,
+				VAuthSpec:          validVAuthSpec,
+				PayloadEncoding:    cloudevents.ApplicationXML,
+				SinkTimeoutSeconds: -1,
+			},
+		},
+		want: apis.ErrOutOfBoundsValue(-1, 1, 600, "spec.sinkTimeoutSeconds"),
+	}, {
+		name: "batchSize out of bounds",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				BatchSize:       5000,
+			},
+		},
+		want: apis.ErrOutOfBoundsValue(5000, 1, 1000, "spec.batchSize"),
+	}, {
+		name: "valid ceOverrides",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec: duckv1.SourceSpec{
+					Sink: validSourceSpec.Sink,
+					CloudEventOverrides: &duckv1.CloudEventOverrides{
+						Extensions: map[string]string{"environment": "production"},
+					},
+				},
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+			},
+		},
+		want: nil,
+	}, {
+		name: "ceOverrides of context attributes",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec: duckv1.SourceSpec{
+					Sink: validSourceSpec.Sink,
+					CloudEventOverrides: &duckv1.CloudEventOverrides{
+						Extensions: map[string]string{"type": "foo", "Source": "bar", "environment": "production"},
+					},
+				},
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+			},
+		},
+		want: apis.ErrInvalidKeyName("Source", "spec.ceOverrides.extensions",
+			"CloudEvent context attributes cannot be overridden").
+			Also(apis.ErrInvalidKeyName("type", "spec.ceOverrides.extensions",
+				"CloudEvent context attributes cannot be overridden")),
+	}, {
+		name: "uppercase ceOverrides extension name",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec: duckv1.SourceSpec{
+					Sink: validSourceSpec.Sink,
+					CloudEventOverrides: &duckv1.CloudEventOverrides{
+						Extensions: map[string]string{"Region": "emea", "tenant1": "acme"},
+					},
+				},
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+			},
+		},
+		want: apis.ErrInvalidKeyName("Region", "spec.ceOverrides.extensions",
+			"CloudEvent attribute names must be lowercase"),
+	}, {
+		name: "invalid ceOverrides extension name",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec: duckv1.SourceSpec{
+					Sink: validSourceSpec.Sink,
+					CloudEventOverrides: &duckv1.CloudEventOverrides{
+						Extensions: map[string]string{"my-extension": "foo"},
+					},
+				},
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+			},
+		},
+		want: (&duckv1.CloudEventOverrides{Extensions: map[string]string{"my-extension": "foo"}}).
+			Validate(context.Background()).ViaField("spec", "ceOverrides"),
+	}, {
+		name: "invalid replicas",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				Replicas:        ptr.Int32(0),
+			},
+		},
+		want: apis.ErrInvalidValue(0, "spec.replicas"),
+	}, {
+		name: "batchTimeoutSeconds out of bounds",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:          validSourceSpec,
+				VAuthSpec:           validVAuthSpec,
+				PayloadEncoding:     cloudevents.ApplicationXML,
+				BatchSize:           10,
+				BatchTimeoutSeconds: -1,
+			},
+		},
+		want: apis.ErrOutOfBoundsValue(-1, 0, 600, "spec.batchTimeoutSeconds"),
+	}, {
+		name: "valid retry",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				Retry: &RetrySpec{
+					MaxRetries:            3,
+					InitialBackoffSeconds: 2,
+					BackoffPolicy:         BackoffPolicyLinear,
+				},
+			},
+		},
+		want: nil,
+	}, {
+		name: "invalid retry",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				Retry: &RetrySpec{
+					MaxRetries:            -1,
+					InitialBackoffSeconds: -1,
+					BackoffPolicy:         "constant",
+				},
+			},
+		},
+		want: apis.ErrInvalidValue(-1, "spec.retry.maxRetries").
+			Also(apis.ErrInvalidValue(-1, "spec.retry.initialBackoffSeconds")).
+			Also(apis.ErrInvalidValue("constant", "spec.retry.backoffPolicy")),
+	}, {
+		name: "invalid checkpoint mode",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				CheckpointConfig: VCheckpointSpec{
+					Mode: "exactly-once",
+				},
+			},
+		},
+		want: apis.ErrInvalidValue("exactly-once", "spec.checkpointConfig.mode"),
+	}, {
+		name: "invalid checkpoint backend",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				CheckpointConfig: VCheckpointSpec{
+					Backend: "etcd",
+				},
+			},
+		},
+		want: apis.ErrInvalidValue("etcd", "spec.checkpointConfig.backend"),
+	}, {
+		name: "invalid rateLimit",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				RateLimit: &RateLimitSpec{
+					EventsPerSecond: 0,
+					Burst:           -1,
+					OverflowPolicy:  "queue",
+				},
+			},
+		},
+		want: apis.ErrInvalidValue(0, "spec.rateLimit.eventsPerSecond").
+			Also(apis.ErrInvalidValue(-1, "spec.rateLimit.burst")).
+			Also(apis.ErrInvalidValue("queue", "spec.rateLimit.overflowPolicy")),
+	}, {
+		name: "valid rateLimit dropping overflow",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				RateLimit: &RateLimitSpec{
+					EventsPerSecond: 100,
+					OverflowPolicy:  RateLimitOverflowDrop,
+				},
+			},
+		},
+		want: nil,
+	}, {
+		name: "invalid deadLetterSink",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				DeadLetterSink:  &duckv1.Destination{},
+			},
+		},
+		want: apis.ErrGeneric("expected at least one, got none", "spec.deadLetterSink.ref", "spec.deadLetterSink.uri"),
+	}, {
+		name: "invalid sinks",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				Sinks:           []duckv1.Destination{validSourceSpec.Sink, {}},
+			},
+		},
+		want: apis.ErrGeneric("expected at least one, got none", "spec.sinks[1].ref", "spec.sinks[1].uri"),
+	}, {
+		name: "valid cloudEventSource",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:       validSourceSpec,
+				VAuthSpec:        validVAuthSpec,
+				PayloadEncoding:  cloudevents.ApplicationXML,
+				CloudEventSource: "/vcenter/dc-1",
+			},
+		},
+		want: nil,
+	}, {
+		name: "invalid cloudEventSource",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:       validSourceSpec,
+				VAuthSpec:        validVAuthSpec,
+				PayloadEncoding:  cloudevents.ApplicationXML,
+				CloudEventSource: "https://vcenter:port",
+			},
+		},
+		want: apis.ErrInvalidValue("https://vcenter:port", "spec.cloudEventSource",
+			`parse "https://vcenter:port": invalid port ":port" after host`),
+	}, {
+		name: "invalid startFrom",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				StartFrom:       "latest",
+			},
+		},
+		want: apis.ErrInvalidValue("latest", "spec.startFrom"),
+	}, {
+		name: "valid veb typeScheme",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				TypeScheme:      TypeSchemeVEB,
+			},
+		},
+		want: nil,
+	}, {
+		name: "invalid typeScheme",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				TypeScheme:      "cloudevents",
+			},
+		},
+		want: apis.ErrInvalidValue("cloudevents", "spec.typeScheme"),
+	}, {
+		name: "valid minimal payloadFormat",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				PayloadFormat:   PayloadFormatMinimal,
+			},
+		},
+		want: nil,
+	}, {
+		name: "invalid payloadFormat",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				PayloadFormat:   "reduced",
+			},
+		},
+		want: apis.ErrInvalidValue("reduced", "spec.payloadFormat"),
+	}, {
+		name: "valid subjectTemplate",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				SubjectTemplate: "{{.Vm.Name}}",
+			},
+		},
+		want: nil,
+	}, {
+		name: "invalid subjectTemplate",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				SubjectTemplate: "{{.Vm.Name}",
+			},
+		},
+		want: apis.ErrInvalidValue("{{.Vm.Name}", "spec.subjectTemplate",
+			`template: subject:1: bad character U+007D '}'`),
+	}, {
+		name: "valid logLevel",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				LogLevel:        "debug",
+			},
+		},
+		want: nil,
+	}, {
+		name: "invalid logLevel",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				LogLevel:        "verbose",
+			},
+		},
+		want: apis.ErrInvalidValue("verbose", "spec.logLevel", `unrecognized level: "verbose"`),
+	}, {
+		name: "valid adapterOverrides env",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				AdapterOverrides: &AdapterOverrides{
+					Env: []corev1.EnvVar{{Name: "HTTPS_PROXY", Value: "http://proxy:3128"}},
+				},
+			},
+		},
+		want: nil,
+	}, {
+		name: "reserved adapterOverrides env",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				AdapterOverrides: &AdapterOverrides{
+					Env: []corev1.EnvVar{
+						{Name: "HTTPS_PROXY", Value: "http://proxy:3128"},
+						{Name: "K_SINK", Value: "http://sink"},
+						{Name: "VC_PASSWORD", Value: "secret"},
+						{Name: "VSPHERE_PAYLOAD_ENCODING", Value: "application/json"},
+						{Name: "VC_USER_AGENT", Value: "custom"},
+					},
+				},
+			},
+		},
+		want: apis.ErrInvalidValue("K_SINK", "spec.adapterOverrides.env[1].name", "environment variable is reserved").
+			Also(apis.ErrInvalidValue("VC_PASSWORD", "spec.adapterOverrides.env[2].name", "environment variable is reserved")).
+			Also(apis.ErrInvalidValue("VSPHERE_PAYLOAD_ENCODING", "spec.adapterOverrides.env[3].name", "environment variable is reserved")).
+			Also(apis.ErrInvalidValue("VC_USER_AGENT", "spec.adapterOverrides.env[4].name", "environment variable is reserved")),
+	}, {
+		name: "adapterOverrides image pull secret without name",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				AdapterOverrides: &AdapterOverrides{
+					ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}, {}},
+				},
+			},
+		},
+		want: apis.ErrMissingField("spec.adapterOverrides.imagePullSecrets[1].name"),
+	}, {
+		name: "valid adapterOverrides labels and annotations",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				AdapterOverrides: &AdapterOverrides{
+					Labels:      map[string]string{"app.kubernetes.io/part-of": "vsphere"},
+					Annotations: map[string]string{"sidecar.istio.io/inject": "false"},
+				},
+			},
+		},
+		want: nil,
+	}, {
+		name: "reserved adapterOverrides label",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				AdapterOverrides: &AdapterOverrides{
+					Labels: map[string]string{"vspheresources.sources.tanzu.vmware.com/name": "other"},
+				},
+			},
+		},
+		want: apis.ErrInvalidKeyName("vspheresources.sources.tanzu.vmware.com/name", "spec.adapterOverrides.labels", "label is reserved"),
+	}, {
+		name: "invalid adapterOverrides label value",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				AdapterOverrides: &AdapterOverrides{
+					Labels: map[string]string{"app": "not valid"},
+				},
+			},
+		},
+		want: apis.ErrInvalidValue("not valid", "spec.adapterOverrides.labels[app]",
+			"a valid label must be an empty string or consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyValue',  or 'my_value',  or '12345', regex used for validation is '(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?')"),
+	}, {
+		name: "reserved adapterOverrides annotation",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				AdapterOverrides: &AdapterOverrides{
+					Annotations: map[string]string{"checksum/credentials": "other"},
+				},
+			},
+		},
+		want: apis.ErrInvalidKeyName("checksum/credentials", "spec.adapterOverrides.annotations", "annotation is reserved"),
+	}, {
+		name: "valid contentEncoding",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:           validSourceSpec,
+				VAuthSpec:            validVAuthSpec,
+				PayloadEncoding:      cloudevents.ApplicationXML,
+				ContentEncoding:      "gzip",
+				ForceContentEncoding: true,
+			},
+		},
+		want: nil,
+	}, {
+		name: "unsupported contentEncoding",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				ContentEncoding: "br",
+			},
+		},
+		want: apis.ErrInvalidValue("br", "spec.contentEncoding", "supported encodings: gzip"),
+	}, {
+		name: "forceContentEncoding without contentEncoding",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:           validSourceSpec,
+				VAuthSpec:            validVAuthSpec,
+				PayloadEncoding:      cloudevents.ApplicationXML,
+				ForceContentEncoding: true,
+			},
+		},
+		want: apis.ErrMissingField("spec.contentEncoding"),
+	}, {
+		name: "valid adapterOverrides terminationGracePeriodSeconds",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				AdapterOverrides: &AdapterOverrides{
+					TerminationGracePeriodSeconds: ptr.Int64(60),
+				},
+			},
+		},
+		want: nil,
+	}, {
+		name: "adapterOverrides terminationGracePeriodSeconds within shutdown margin",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				AdapterOverrides: &AdapterOverrides{
+					TerminationGracePeriodSeconds: ptr.Int64(10),
+				},
+			},
+		},
+		want: apis.ErrInvalidValue(int64(10), "spec.adapterOverrides.terminationGracePeriodSeconds", "must be greater than 10"),
+	}, {
+		name: "valid maxEventAgeSeconds",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:         validSourceSpec,
+				VAuthSpec:          validVAuthSpec,
+				PayloadEncoding:    cloudevents.ApplicationXML,
+				MaxEventAgeSeconds: 3600,
+			},
+		},
+		want: nil,
+	}, {
+		name: "negative maxEventAgeSeconds",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:         validSourceSpec,
+				VAuthSpec:          validVAuthSpec,
+				PayloadEncoding:    cloudevents.ApplicationXML,
+				MaxEventAgeSeconds: -1,
+			},
+		},
+		want: apis.ErrInvalidValue(int64(-1), "spec.maxEventAgeSeconds"),
+	}, {
+		name: "negative reconnectBackoffSeconds",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:              validSourceSpec,
+				VAuthSpec:               validVAuthSpec,
+				PayloadEncoding:         cloudevents.ApplicationXML,
+				ReconnectBackoffSeconds: -1,
+			},
+		},
+		want: apis.ErrInvalidValue(int64(-1), "spec.reconnectBackoffSeconds"),
+	}, {
+		name: "valid failoverAddresses",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:        validSourceSpec,
+				VAuthSpec:         validVAuthSpec,
+				PayloadEncoding:   cloudevents.ApplicationXML,
+				FailoverAddresses: []string{"https://vcenter-standby.corp.local"},
+				FailoverThreshold: 5,
+			},
+		},
+		want: nil,
+	}, {
+		name: "invalid failoverAddresses and failoverThreshold",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:        validSourceSpec,
+				VAuthSpec:         validVAuthSpec,
+				PayloadEncoding:   cloudevents.ApplicationXML,
+				FailoverAddresses: []string{"https://vcenter-standby.corp.local", "vcenter-c"},
+				FailoverThreshold: -1,
+			},
+		},
+		want: apis.ErrInvalidArrayValue("vcenter-c", "spec.failoverAddresses", 1).
+			Also(apis.ErrInvalidValue(int32(-1), "spec.failoverThreshold")),
+	}, {
+		name: "valid serviceAccountName",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:         validSourceSpec,
+				VAuthSpec:          validVAuthSpec,
+				PayloadEncoding:    cloudevents.ApplicationXML,
+				ServiceAccountName: "vsphere-adapter",
+			},
+		},
+		want: nil,
+	}, {
+		name: "invalid serviceAccountName",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:         validSourceSpec,
+				VAuthSpec:          validVAuthSpec,
+				PayloadEncoding:    cloudevents.ApplicationXML,
+				ServiceAccountName: "Invalid_Name",
+			},
+		},
+		want: apis.ErrInvalidValue("Invalid_Name", "spec.serviceAccountName",
+			"a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', "+
+				"and must start and end with an alphanumeric character (e.g. 'example.com', regex used for "+
+				"validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')"),
+	}, {
+		name: "valid serviceAccountAnnotations",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:                validSourceSpec,
+				VAuthSpec:                 validVAuthSpec,
+				PayloadEncoding:           cloudevents.ApplicationXML,
+				ServiceAccountAnnotations: map[string]string{"eks.amazonaws.com/role-arn": "arn:aws:iam::111122223333:role/adapter"},
+			},
+		},
+		want: nil,
+	}, {
+		name: "invalid serviceAccountAnnotations key",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:                validSourceSpec,
+				VAuthSpec:                 validVAuthSpec,
+				PayloadEncoding:           cloudevents.ApplicationXML,
+				ServiceAccountAnnotations: map[string]string{"role arn": "arn"},
+			},
+		},
+		want: apis.ErrInvalidKeyName("role arn", "spec.serviceAccountAnnotations",
+			"name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an "+
+				"alphanumeric character (e.g. 'MyName',  or 'my.name',  or '123-abc', regex used for validation is "+
+				"'([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]')"),
+	}, {
+		name: "serviceAccountAnnotations with serviceAccountName",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:                validSourceSpec,
+				VAuthSpec:                 validVAuthSpec,
+				PayloadEncoding:           cloudevents.ApplicationXML,
+				ServiceAccountName:        "vsphere-adapter",
+				ServiceAccountAnnotations: map[string]string{"eks.amazonaws.com/role-arn": "arn"},
+			},
+		},
+		want: apis.ErrMultipleOneOf("spec.serviceAccountName", "spec.serviceAccountAnnotations"),
+	}, {
+		name: "valid caCerts",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				CACerts:         ptr.String(testCACerts + testCACerts),
+			},
+		},
+		want: nil,
+	}, {
+		name: "malformed caCerts",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				CACerts:         ptr.String("not a certificate"),
+			},
+		},
+		want: apis.ErrGeneric("invalid CA certificates: no PEM encoded certificate found", "spec.caCerts"),
+	}, {
+		name: "caCerts with trailing data",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				CACerts:         ptr.String(testCACerts + "garbage"),
+			},
+		},
+		want: apis.ErrGeneric("invalid CA certificates: unexpected trailing data after PEM encoded certificates", "spec.caCerts"),
+	}, {
+		name: "empty sinkAudience",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				SinkAudience:    ptr.String(" "),
+			},
+		},
+		want: apis.ErrInvalidValue(" ", "spec.sinkAudience"),
+	}, {
+		name: "valid proxy",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				Proxy:           &ProxySpec{HTTPSProxy: "http://proxy.corp.local:3128", NoProxy: ".svc,10.0.0.0/8"},
+			},
+		},
+		want: nil,
+	}, {
+		name: "proxy without URL",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				Proxy:           &ProxySpec{NoProxy: ".svc"},
+			},
+		},
+		want: apis.ErrMissingOneOf("spec.proxy.httpProxy", "spec.proxy.httpsProxy"),
+	}, {
+		name: "invalid proxy URLs",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				Proxy:           &ProxySpec{HTTPProxy: "proxy.corp.local:3128", HTTPSProxy: "http://"},
+			},
+		},
+		want: apis.ErrInvalidValue("proxy.corp.local:3128", "spec.proxy.httpProxy", `unsupported scheme "proxy.corp.local"`).
+			Also(apis.ErrInvalidValue("http://", "spec.proxy.httpsProxy", "missing host")),
+	}, {
+		name: "valid sinkCACerts",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				SinkCACerts:     ptr.String(testCACerts),
+			},
+		},
+		want: nil,
+	}, {
+		name: "malformed sinkCACerts",
+		c: &VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: VSphereSourceSpec{
+				SourceSpec:      validSourceSpec,
+				VAuthSpec:       validVAuthSpec,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				SinkCACerts:     ptr.String("not a certificate"),
+			},
+		},
+		want: apis.ErrGeneric("invalid CA certificates: no PEM encoded certificate found", "spec.sinkCACerts"),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.c.Validate(context.Background())
+			if !cmp.Equal(test.want.Error(), got.Error()) {
+				t.Errorf("Validate (-want, +got) = %v",
+					cmp.Diff(test.want.Error(), got.Error()))
+			}
+		})
+	}
+}
+
+func TestVSphereSourceValidationPausedUpdate(t *testing.T) {
+	deleted := metav1.Now()
+
+	tests := []struct {
+		name      string
+		deleting  bool
+		paused    bool
+		newPaused bool
+		want      *apis.FieldError
+	}{{
+		name:      "pause",
+		newPaused: true,
+	}, {
+		name:   "unpause",
+		paused: true,
+	}, {
+		name:      "pause while deleting",
+		deleting:  true,
+		newPaused: true,
+		want: &apis.FieldError{
+			Message: "Cannot change paused while the source is being deleted",
+			Paths:   []string{"spec.paused"},
+		},
+	}, {
+		name:     "unpause while deleting",
+		deleting: true,
+		paused:   true,
+		want: &apis.FieldError{
+			Message: "Cannot change paused while the source is being deleted",
+			Paths:   []string{"spec.paused"},
+		},
+	}, {
+		name:      "paused unchanged while deleting",
+		deleting:  true,
+		paused:    true,
+		newPaused: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			original := &VSphereSource{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "valid",
+				},
+				Spec: VSphereSourceSpec{
+					SourceSpec:      validSourceSpec,
+					VAuthSpec:       validVAuthSpec,
+					PayloadEncoding: cloudevents.ApplicationXML,
+					Paused:          test.paused,
+				},
+			}
+			if test.deleting {
+				original.DeletionTimestamp = &deleted
+			}
+			updated := original.DeepCopy()
+			updated.Spec.Paused = test.newPaused
+
+			ctx := apis.WithinUpdate(context.Background(), original)
+			got := updated.Validate(ctx)
 			if !cmp.Equal(test.want.Error(), got.Error()) {
 				t.Errorf("Validate (-want, +got) = %v",
 					cmp.Diff(test.want.Error(), got.Error()))
@@ -9,7 +9,6 @@ import (
 	"context"
 
 	vsbinformer "github.com/vmware-tanzu/sources-for-knative/pkg/client/injection/informers/sources/v1alpha1/vspherebinding"
-	"knative.dev/pkg/client/injection/ducks/duck/v1/podspecable"
 	"knative.dev/pkg/client/injection/kube/informers/core/v1/namespace"
 	"knative.dev/pkg/reconciler"
 
@@ -21,6 +20,7 @@ import (
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"knative.dev/pkg/apis/duck"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/injection/clients/dynamicclient"
@@ -41,8 +41,14 @@ func NewController(
 	logger := logging.FromContext(ctx)
 
 	vsbInformer := vsbinformer.Get(ctx)
-	dc := dynamicclient.Get(ctx)
-	psInformerFactory := podspecable.Get(ctx)
+	// CronJobs are read and patched like the other subjects through dc
+	dc := withCronJobs(dynamicclient.Get(ctx))
+	psInformerFactory := &duck.TypedInformerFactory{
+		Client:       dc,
+		Type:         (&duckv1.PodSpecable{}).GetFullType(),
+		ResyncPeriod: controller.GetResyncPeriod(ctx),
+		StopChannel:  ctx.Done(),
+	}
 	namespaceInformer := namespace.Get(ctx)
 
 	c := &psbinding.BaseReconciler{
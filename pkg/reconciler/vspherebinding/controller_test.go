@@ -0,0 +1,181 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vspherebinding
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/apis/duck"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	duckv1alpha1 "knative.dev/pkg/apis/duck/v1alpha1"
+	"knative.dev/pkg/tracker"
+	"knative.dev/pkg/webhook/psbinding"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
+)
+
+var ksvcGVR = servingv1.SchemeGroupVersion.WithResource("services")
+
+// podSpecableFactory returns listers of the PodSpecable subjects in the
+// dynamic client, like the duck informer factory of the controller.
+type podSpecableFactory struct {
+	t      *testing.T
+	client dynamic.Interface
+}
+
+func (f podSpecableFactory) Get(ctx context.Context, gvr schema.GroupVersionResource) (cache.SharedIndexInformer, cache.GenericLister, error) {
+	list, err := f.client.Resource(gvr).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for i := range list.Items {
+		ps := &duckv1.WithPod{}
+		if err := duck.FromUnstructured(&list.Items[i], ps); err != nil {
+			f.t.Fatalf("FromUnstructured() = %v", err)
+		}
+		if err := indexer.Add(ps); err != nil {
+			f.t.Fatal(err)
+		}
+	}
+	return nil, cache.NewGenericLister(indexer, gvr.GroupResource()), nil
+}
+
+func TestReconcileSubjectKnativeServices(t *testing.T) {
+	const ns = "functions"
+
+	newService := func(name string, labels map[string]string) runtime.Object {
+		ksvc := &servingv1.Service{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: servingv1.SchemeGroupVersion.String(),
+				Kind:       "Service",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns,
+				Name:      name,
+				Labels:    labels,
+			},
+			Spec: servingv1.ServiceSpec{ConfigurationSpec: servingv1.ConfigurationSpec{
+				Template: servingv1.RevisionTemplateSpec{Spec: servingv1.RevisionSpec{PodSpec: corev1.PodSpec{
+					Containers: []corev1.Container{{Image: "vmware/govc"}},
+				}}},
+			}},
+		}
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(ksvc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &unstructured.Unstructured{Object: u}
+	}
+
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{ksvcGVR: "ServiceList"},
+		newService("govc-tagger", map[string]string{"app": "govc"}),
+		newService("govc-reporter", map[string]string{"app": "govc"}),
+		newService("unrelated", nil),
+	)
+
+	nsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := nsIndexer.Add(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   ns,
+		Labels: map[string]string{duck.BindingIncludeLabel: "true"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &psbinding.BaseReconciler{
+		DynamicClient:   dc,
+		Factory:         podSpecableFactory{t: t, client: dc},
+		Tracker:         tracker.New(func(types.NamespacedName) {}, time.Minute),
+		NamespaceLister: corev1listers.NewNamespaceLister(nsIndexer),
+	}
+
+	vsb := &v1alpha1.VSphereBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ns,
+			Name:      "vcenter",
+		},
+		Spec: v1alpha1.VSphereBindingSpec{
+			BindingSpec: duckv1alpha1.BindingSpec{
+				Subject: tracker.Reference{
+					APIVersion: servingv1.SchemeGroupVersion.String(),
+					Kind:       "Service",
+					Namespace:  ns,
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "govc"},
+					},
+				},
+			},
+			VAuthSpec: v1alpha1.VAuthSpec{
+				Address: apis.URL{
+					Scheme: "https",
+					Host:   "vcenter.corp.local",
+				},
+				SecretRef: corev1.LocalObjectReference{
+					Name: "vsphere-credentials",
+				},
+			},
+		},
+	}
+
+	// bound returns whether the revision template of the Service is bound
+	bound := func(name string) bool {
+		t.Helper()
+		u, err := dc.Resource(ksvcGVR).Namespace(ns).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("get service %q: %v", name, err)
+		}
+		ksvc := &servingv1.Service{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, ksvc); err != nil {
+			t.Fatal(err)
+		}
+
+		var env bool
+		for _, e := range ksvc.Spec.Template.Spec.Containers[0].Env {
+			env = env || e.Name == "VC_URL"
+		}
+		volume := len(ksvc.Spec.Template.Spec.Volumes) == 1
+		if env != volume {
+			t.Errorf("service %q has binding env = %v, binding volume = %v", name, env, volume)
+		}
+		return env
+	}
+
+	ctx := context.Background()
+	if err := r.ReconcileSubject(ctx, vsb, vsb.Do); err != nil {
+		t.Fatalf("ReconcileSubject(Do) = %v", err)
+	}
+	for name, want := range map[string]bool{"govc-tagger": true, "govc-reporter": true, "unrelated": false} {
+		if got := bound(name); got != want {
+			t.Errorf("Do() service %q bound = %v, want %v", name, got, want)
+		}
+	}
+
+	// deleting the binding undoes it, which creates new revisions
+	if err := r.ReconcileSubject(ctx, vsb, vsb.Undo); err != nil {
+		t.Fatalf("ReconcileSubject(Undo) = %v", err)
+	}
+	for _, name := range []string{"govc-tagger", "govc-reporter", "unrelated"} {
+		if bound(name) {
+			t.Errorf("Undo() service %q is still bound", name)
+		}
+	}
+}
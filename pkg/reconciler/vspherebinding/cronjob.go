@@ -0,0 +1,179 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vspherebinding
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/webhook"
+	"knative.dev/pkg/webhook/psbinding"
+)
+
+// The psbinding webhook and reconciler bind subjects through the PodSpecable
+// duck type, which has the pod template at spec.template. The pod template of
+// a CronJob is at spec.jobTemplate.spec.template, so CronJobs are passed to
+// them with their pod template copied to spec.template, and the patches of
+// spec.template they return are applied to spec.jobTemplate.spec.template.
+const (
+	podTemplatePath        = "/spec/template"
+	cronJobPodTemplatePath = "/spec/jobTemplate/spec/template"
+)
+
+// WithCronJobs returns the given psbinding webhook, binding CronJobs as well.
+func WithCronJobs(wh *psbinding.Reconciler) controller.Reconciler {
+	return &cronJobWebhook{Reconciler: wh}
+}
+
+type cronJobWebhook struct {
+	*psbinding.Reconciler
+}
+
+var _ webhook.AdmissionController = (*cronJobWebhook)(nil)
+
+// Admit implements AdmissionController
+func (wh *cronJobWebhook) Admit(ctx context.Context, request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if request.Kind.Group != batchv1.GroupName || request.Kind.Kind != "CronJob" {
+		return wh.Reconciler.Admit(ctx, request)
+	}
+
+	var cronJob map[string]interface{}
+	if err := json.Unmarshal(request.Object.Raw, &cronJob); err != nil {
+		return webhook.MakeErrorStatus("unable to decode object: %v", err)
+	}
+	copyPodTemplate(cronJob)
+	raw, err := json.Marshal(cronJob)
+	if err != nil {
+		return webhook.MakeErrorStatus("unable to encode object: %v", err)
+	}
+
+	request = request.DeepCopy()
+	request.Object.Raw = raw
+	response := wh.Reconciler.Admit(ctx, request)
+	if response.Patch != nil {
+		if response.Patch, err = cronJobPatch(response.Patch); err != nil {
+			return webhook.MakeErrorStatus("unable to create patch with binding: %v", err)
+		}
+	}
+	return response
+}
+
+// withCronJobs returns the given dynamic client, reading and patching
+// CronJobs like the PodSpecable resources the psbinding reconciler expects.
+func withCronJobs(dc dynamic.Interface) dynamic.Interface {
+	return &cronJobClient{Interface: dc}
+}
+
+type cronJobClient struct {
+	dynamic.Interface
+}
+
+func (c *cronJobClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	ri := c.Interface.Resource(gvr)
+	if gvr.Group != batchv1.GroupName || gvr.Resource != "cronjobs" {
+		return ri
+	}
+	return &namespaceableCronJobs{cronJobs: cronJobs{ResourceInterface: ri}, ri: ri}
+}
+
+type namespaceableCronJobs struct {
+	cronJobs
+	ri dynamic.NamespaceableResourceInterface
+}
+
+func (c *namespaceableCronJobs) Namespace(ns string) dynamic.ResourceInterface {
+	return &cronJobs{ResourceInterface: c.ri.Namespace(ns)}
+}
+
+type cronJobs struct {
+	dynamic.ResourceInterface
+}
+
+func (c *cronJobs) Get(ctx context.Context, name string, options metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	u, err := c.ResourceInterface.Get(ctx, name, options, subresources...)
+	if err != nil {
+		return nil, err
+	}
+	copyPodTemplate(u.Object)
+	return u, nil
+}
+
+func (c *cronJobs) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	ul, err := c.ResourceInterface.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ul.Items {
+		copyPodTemplate(ul.Items[i].Object)
+	}
+	return ul, nil
+}
+
+func (c *cronJobs) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	w, err := c.ResourceInterface.Watch(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(w, func(e watch.Event) (watch.Event, bool) {
+		if u, ok := e.Object.(*unstructured.Unstructured); ok {
+			copyPodTemplate(u.Object)
+		}
+		return e, true
+	}), nil
+}
+
+func (c *cronJobs) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if pt == types.JSONPatchType {
+		var err error
+		if data, err = cronJobPatch(data); err != nil {
+			return nil, err
+		}
+	}
+	u, err := c.ResourceInterface.Patch(ctx, name, pt, data, options, subresources...)
+	if err != nil {
+		return nil, err
+	}
+	copyPodTemplate(u.Object)
+	return u, nil
+}
+
+// copyPodTemplate copies the pod template of the Jobs of the given CronJob to
+// spec.template.
+func copyPodTemplate(cronJob map[string]interface{}) {
+	template, ok, err := unstructured.NestedFieldNoCopy(cronJob, "spec", "jobTemplate", "spec", "template")
+	if err != nil || !ok {
+		return
+	}
+	_ = unstructured.SetNestedField(cronJob, template, "spec", "template")
+}
+
+// cronJobPatch returns the given JSON patch with the operations on
+// spec.template applied to the pod template of the Jobs of a CronJob instead.
+func cronJobPatch(patch []byte) ([]byte, error) {
+	var ops []map[string]interface{}
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		for _, key := range []string{"path", "from"} {
+			path, ok := op[key].(string)
+			if ok && (path == podTemplatePath || strings.HasPrefix(path, podTemplatePath+"/")) {
+				op[key] = cronJobPodTemplatePath + strings.TrimPrefix(path, podTemplatePath)
+			}
+		}
+	}
+	return json.Marshal(ops)
+}
@@ -0,0 +1,371 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vspherebinding
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/apis/duck"
+	duckv1alpha1 "knative.dev/pkg/apis/duck/v1alpha1"
+	"knative.dev/pkg/system"
+	_ "knative.dev/pkg/system/testing"
+	"knative.dev/pkg/tracker"
+	"knative.dev/pkg/webhook"
+	certresources "knative.dev/pkg/webhook/certificates/resources"
+	"knative.dev/pkg/webhook/psbinding"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
+)
+
+const testNamespace = "jobs"
+
+var testTemplate = corev1.PodTemplateSpec{
+	Spec: corev1.PodSpec{
+		Containers: []corev1.Container{{
+			Name:  "govc",
+			Image: "vmware/govc",
+		}},
+	},
+}
+
+func newTestBinding(gvk schema.GroupVersionKind) *v1alpha1.VSphereBinding {
+	apiVersion, kind := gvk.ToAPIVersionAndKind()
+	return &v1alpha1.VSphereBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testNamespace,
+			Name:      "vcenter",
+		},
+		Spec: v1alpha1.VSphereBindingSpec{
+			BindingSpec: duckv1alpha1.BindingSpec{
+				Subject: tracker.Reference{
+					APIVersion: apiVersion,
+					Kind:       kind,
+					Namespace:  testNamespace,
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "govc"},
+					},
+				},
+			},
+			VAuthSpec: v1alpha1.VAuthSpec{
+				Address: apis.URL{
+					Scheme: "https",
+					Host:   "vcenter.corp.local",
+				},
+				SecretRef: corev1.LocalObjectReference{
+					Name: "vsphere-credentials",
+				},
+			},
+		},
+	}
+}
+
+func newCronJob(name string, labels map[string]string) *batchv1.CronJob {
+	return &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name, Labels: labels},
+		Spec: batchv1.CronJobSpec{
+			Schedule: "@daily",
+			JobTemplate: batchv1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{Template: testTemplate},
+			},
+		},
+	}
+}
+
+// newTestWebhook returns the binding webhook of the controller with its
+// index of the given binding.
+func newTestWebhook(ctx context.Context, t *testing.T, vsb *v1alpha1.VSphereBinding) webhook.AdmissionController {
+	t.Helper()
+
+	secrets := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := secrets.Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: system.Namespace(), Name: "webhook-certs"},
+		Data:       map[string][]byte{certresources.CACert: []byte("ca")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	wh := psbinding.NewReconciler("vspherebindings.webhook", "/vspherebindings", "webhook-certs",
+		kubefake.NewSimpleClientset(), nil, corev1listers.NewSecretLister(secrets), nil)
+	wh.ListAll = func() ([]psbinding.Bindable, error) {
+		return []psbinding.Bindable{vsb}, nil
+	}
+	// builds the index, only the leader updates the webhook configuration
+	if err := wh.Reconcile(ctx, ""); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+	return WithCronJobs(wh).(webhook.AdmissionController)
+}
+
+// fromUnstructured decodes the subject in the dynamic client.
+func fromUnstructured(ctx context.Context, t *testing.T, dc dynamic.Interface, gvr schema.GroupVersionResource, obj interface{}) {
+	t.Helper()
+
+	u, err := dc.Resource(gvr).Namespace(testNamespace).Get(ctx, "govc", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, obj); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// validateRevisionTemplate checks that Serving accepts the bound revision
+// template of the given Knative Service or Configuration.
+func validateRevisionTemplate(t *testing.T, obj interface {
+	apis.Defaultable
+	apis.Validatable
+}) {
+	t.Helper()
+	obj.SetDefaults(context.Background())
+	if err := obj.Validate(context.Background()); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestBindingWebhookWorkloads(t *testing.T) {
+	objectMeta := metav1.ObjectMeta{Namespace: testNamespace, Name: "govc", Labels: map[string]string{"app": "govc"}}
+	revisionTemplate := servingv1.RevisionTemplateSpec{Spec: servingv1.RevisionSpec{PodSpec: testTemplate.Spec}}
+
+	tests := []struct {
+		name    string
+		subject runtime.Object
+		gvk     schema.GroupVersionKind
+		// podSpec decodes the subject and returns its pod spec
+		podSpec func(get func(obj interface{})) corev1.PodSpec
+	}{{
+		name:    "Deployment",
+		subject: &appsv1.Deployment{ObjectMeta: objectMeta, Spec: appsv1.DeploymentSpec{Template: testTemplate}},
+		gvk:     appsv1.SchemeGroupVersion.WithKind("Deployment"),
+		podSpec: func(get func(obj interface{})) corev1.PodSpec {
+			var d appsv1.Deployment
+			get(&d)
+			return d.Spec.Template.Spec
+		},
+	}, {
+		name:    "StatefulSet",
+		subject: &appsv1.StatefulSet{ObjectMeta: objectMeta, Spec: appsv1.StatefulSetSpec{Template: testTemplate}},
+		gvk:     appsv1.SchemeGroupVersion.WithKind("StatefulSet"),
+		podSpec: func(get func(obj interface{})) corev1.PodSpec {
+			var ss appsv1.StatefulSet
+			get(&ss)
+			return ss.Spec.Template.Spec
+		},
+	}, {
+		name:    "DaemonSet",
+		subject: &appsv1.DaemonSet{ObjectMeta: objectMeta, Spec: appsv1.DaemonSetSpec{Template: testTemplate}},
+		gvk:     appsv1.SchemeGroupVersion.WithKind("DaemonSet"),
+		podSpec: func(get func(obj interface{})) corev1.PodSpec {
+			var ds appsv1.DaemonSet
+			get(&ds)
+			return ds.Spec.Template.Spec
+		},
+	}, {
+		name:    "Job",
+		subject: &batchv1.Job{ObjectMeta: objectMeta, Spec: batchv1.JobSpec{Template: testTemplate}},
+		gvk:     batchv1.SchemeGroupVersion.WithKind("Job"),
+		podSpec: func(get func(obj interface{})) corev1.PodSpec {
+			var j batchv1.Job
+			get(&j)
+			return j.Spec.Template.Spec
+		},
+	}, {
+		name:    "CronJob",
+		subject: newCronJob("govc", map[string]string{"app": "govc"}),
+		gvk:     batchv1.SchemeGroupVersion.WithKind("CronJob"),
+		podSpec: func(get func(obj interface{})) corev1.PodSpec {
+			var cj batchv1.CronJob
+			get(&cj)
+			return cj.Spec.JobTemplate.Spec.Template.Spec
+		},
+	}, {
+		name: "Knative Service",
+		subject: &servingv1.Service{ObjectMeta: objectMeta, Spec: servingv1.ServiceSpec{
+			ConfigurationSpec: servingv1.ConfigurationSpec{Template: revisionTemplate},
+		}},
+		gvk: servingv1.SchemeGroupVersion.WithKind("Service"),
+		podSpec: func(get func(obj interface{})) corev1.PodSpec {
+			var ksvc servingv1.Service
+			get(&ksvc)
+			validateRevisionTemplate(t, ksvc.DeepCopy())
+			return ksvc.Spec.Template.Spec.PodSpec
+		},
+	}, {
+		name:    "Knative Configuration",
+		subject: &servingv1.Configuration{ObjectMeta: objectMeta, Spec: servingv1.ConfigurationSpec{Template: revisionTemplate}},
+		gvk:     servingv1.SchemeGroupVersion.WithKind("Configuration"),
+		podSpec: func(get func(obj interface{})) corev1.PodSpec {
+			var cfg servingv1.Configuration
+			get(&cfg)
+			validateRevisionTemplate(t, cfg.DeepCopy())
+			return cfg.Spec.Template.Spec.PodSpec
+		},
+	}}
+
+	sch := runtime.NewScheme()
+	if err := scheme.AddToScheme(sch); err != nil {
+		t.Fatal(err)
+	}
+	if err := servingv1.AddToScheme(sch); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dc := dynamicfake.NewSimpleDynamicClient(sch, tt.subject)
+			gvr, _ := meta.UnsafeGuessKindToResource(tt.gvk)
+			get := func(obj interface{}) {
+				fromUnstructured(ctx, t, dc, gvr, obj)
+			}
+
+			// admit applies the binding to the subject like the API server
+			// with the patch of the webhook
+			admit := func(vsb *v1alpha1.VSphereBinding) {
+				t.Helper()
+				u, err := dc.Resource(gvr).Namespace(testNamespace).Get(ctx, "govc", metav1.GetOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				raw, err := u.MarshalJSON()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				resp := newTestWebhook(ctx, t, vsb).Admit(ctx, &admissionv1.AdmissionRequest{
+					Operation: admissionv1.Update,
+					Kind:      metav1.GroupVersionKind{Group: tt.gvk.Group, Version: tt.gvk.Version, Kind: tt.gvk.Kind},
+					Namespace: testNamespace,
+					Object:    runtime.RawExtension{Raw: raw},
+				})
+				if !resp.Allowed {
+					t.Fatalf("Admit() = %v", resp.Result)
+				}
+				if _, err := dc.Resource(gvr).Namespace(testNamespace).Patch(ctx, "govc", types.JSONPatchType, resp.Patch, metav1.PatchOptions{}); err != nil {
+					t.Fatalf("apply patch %s: %v", resp.Patch, err)
+				}
+			}
+
+			vsb := newTestBinding(tt.gvk)
+			admit(vsb)
+			spec := tt.podSpec(get)
+			if !hasBindingEnv(spec.Containers[0]) {
+				t.Errorf("Do() env = %v, want binding env", spec.Containers[0].Env)
+			}
+			if len(spec.Volumes) != 1 || len(spec.Containers[0].VolumeMounts) != 1 {
+				t.Errorf("Do() volumes = %v, mounts = %v, want binding volume", spec.Volumes, spec.Containers[0].VolumeMounts)
+			}
+
+			// the binding is undone while it is deleted
+			vsb.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			admit(vsb)
+			if diff := cmp.Diff(testTemplate.Spec, tt.podSpec(get), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Undo (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestReconcileSubjectCronJobs(t *testing.T) {
+	gvr := batchv1.SchemeGroupVersion.WithResource("cronjobs")
+	fake := dynamicfake.NewSimpleDynamicClient(scheme.Scheme,
+		newCronJob("govc-tagger", map[string]string{"app": "govc"}),
+		newCronJob("govc-reporter", map[string]string{"app": "govc"}),
+		newCronJob("unrelated", nil),
+	)
+	dc := withCronJobs(fake)
+
+	nsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := nsIndexer.Add(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   testNamespace,
+		Labels: map[string]string{duck.BindingIncludeLabel: "true"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &psbinding.BaseReconciler{
+		DynamicClient:   dc,
+		Factory:         podSpecableFactory{t: t, client: dc},
+		Tracker:         tracker.New(func(types.NamespacedName) {}, time.Minute),
+		NamespaceLister: corev1listers.NewNamespaceLister(nsIndexer),
+	}
+
+	vsb := newTestBinding(batchv1.SchemeGroupVersion.WithKind("CronJob"))
+
+	// bound returns whether the job template of the CronJob is bound
+	bound := func(name string) bool {
+		t.Helper()
+		u, err := fake.Resource(gvr).Namespace(testNamespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("get cronjob %q: %v", name, err)
+		}
+		cronJob := &batchv1.CronJob{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, cronJob); err != nil {
+			t.Fatal(err)
+		}
+
+		spec := cronJob.Spec.JobTemplate.Spec.Template.Spec
+		env := hasBindingEnv(spec.Containers[0])
+		volume := len(spec.Volumes) == 1
+		if env != volume {
+			t.Errorf("cronjob %q has binding env = %v, binding volume = %v", name, env, volume)
+		}
+		if _, ok, _ := unstructured.NestedFieldNoCopy(u.Object, "spec", "template"); ok {
+			t.Errorf("cronjob %q has a pod template at spec.template", name)
+		}
+		return env
+	}
+
+	ctx := context.Background()
+	if err := r.ReconcileSubject(ctx, vsb, vsb.Do); err != nil {
+		t.Fatalf("ReconcileSubject(Do) = %v", err)
+	}
+	for name, want := range map[string]bool{"govc-tagger": true, "govc-reporter": true, "unrelated": false} {
+		if got := bound(name); got != want {
+			t.Errorf("Do() cronjob %q bound = %v, want %v", name, got, want)
+		}
+	}
+
+	// deleting the binding undoes it
+	if err := r.ReconcileSubject(ctx, vsb, vsb.Undo); err != nil {
+		t.Fatalf("ReconcileSubject(Undo) = %v", err)
+	}
+	for _, name := range []string{"govc-tagger", "govc-reporter", "unrelated"} {
+		if bound(name) {
+			t.Errorf("Undo() cronjob %q is still bound", name)
+		}
+	}
+}
+
+func hasBindingEnv(c corev1.Container) bool {
+	for _, env := range c.Env {
+		if env.Name == "VC_URL" {
+			return true
+		}
+	}
+	return false
+}
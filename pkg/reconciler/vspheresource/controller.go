@@ -7,34 +7,117 @@ package vspheresource
 
 import (
 	"context"
+	"flag"
+	"fmt"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/tools/cache"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/metrics"
 	"knative.dev/pkg/resolver"
+	tracingconfig "knative.dev/pkg/tracing/config"
 	"knative.dev/pkg/tracker"
 
 	"github.com/kelseyhightower/envconfig"
 	eventingclient "knative.dev/eventing/pkg/client/injection/client"
+	eventtypeinformer "knative.dev/eventing/pkg/client/injection/informers/eventing/v1beta1/eventtype"
 	kubeclient "knative.dev/pkg/client/injection/kube/client"
 	deploymentinformer "knative.dev/pkg/client/injection/kube/informers/apps/v1/deployment"
+	leaseinformer "knative.dev/pkg/client/injection/kube/informers/coordination/v1/lease/filtered"
 	cminformer "knative.dev/pkg/client/injection/kube/informers/core/v1/configmap"
+	podinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/pod/filtered"
+	serviceinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/service"
 	sainformer "knative.dev/pkg/client/injection/kube/informers/core/v1/serviceaccount"
+	roleinformer "knative.dev/pkg/client/injection/kube/informers/rbac/v1/role"
 	rbacinformer "knative.dev/pkg/client/injection/kube/informers/rbac/v1/rolebinding"
+	"knative.dev/pkg/injection/clients/dynamicclient"
 
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/config"
 	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
 	"github.com/vmware-tanzu/sources-for-knative/pkg/client/injection/client"
 	vspherebindinginformer "github.com/vmware-tanzu/sources-for-knative/pkg/client/injection/informers/sources/v1alpha1/vspherebinding"
 	vsphereinformer "github.com/vmware-tanzu/sources-for-knative/pkg/client/injection/informers/sources/v1alpha1/vspheresource"
 	vspherereconciler "github.com/vmware-tanzu/sources-for-knative/pkg/client/injection/reconciler/sources/v1alpha1/vspheresource"
+	"github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspheresource/resources"
 )
 
 type envConfig struct {
 	VSphereAdapter string `envconfig:"VSPHERE_ADAPTER" required:"true"`
 }
 
+var (
+	adapterCPURequest    = flag.String("adapter-cpu-request", "", "Default CPU request of the vSphere receive adapter.")
+	adapterCPULimit      = flag.String("adapter-cpu-limit", "", "Default CPU limit of the vSphere receive adapter.")
+	adapterMemoryRequest = flag.String("adapter-memory-request", "", "Default memory request of the vSphere receive adapter.")
+	adapterMemoryLimit   = flag.String("adapter-memory-limit", "", "Default memory limit of the vSphere receive adapter.")
+
+	adapterMetricsPort = flag.Int("adapter-metrics-port", 9090,
+		"Port of the Prometheus metrics endpoint of the vSphere receive adapter.")
+
+	adapterServiceMonitor = flag.Bool("adapter-service-monitor", false,
+		"Create a Prometheus Operator ServiceMonitor for the metrics Service of each vSphere receive adapter. Ignored if the ServiceMonitor CRD is not installed.")
+
+	checkpointLagThreshold = flag.Duration("checkpoint-lag-threshold", 0,
+		"Lag of the adapter checkpoint above which the CheckpointCurrent condition of a source is false. Disabled when zero.")
+)
+
+// adapterResources returns the default compute resources of the receive
+// adapter as configured via flags.
+func adapterResources() (corev1.ResourceRequirements, error) {
+	var res corev1.ResourceRequirements
+
+	for _, q := range []struct {
+		value string
+		name  corev1.ResourceName
+		list  *corev1.ResourceList
+	}{
+		{*adapterCPURequest, corev1.ResourceCPU, &res.Requests},
+		{*adapterCPULimit, corev1.ResourceCPU, &res.Limits},
+		{*adapterMemoryRequest, corev1.ResourceMemory, &res.Requests},
+		{*adapterMemoryLimit, corev1.ResourceMemory, &res.Limits},
+	} {
+		if q.value == "" {
+			continue
+		}
+
+		quantity, err := resource.ParseQuantity(q.value)
+		if err != nil {
+			return res, fmt.Errorf("parse %s quantity %q: %w", q.name, q.value, err)
+		}
+
+		if *q.list == nil {
+			*q.list = corev1.ResourceList{}
+		}
+		(*q.list)[q.name] = quantity
+	}
+
+	return res, nil
+}
+
+// serviceMonitorsAvailable returns true if the ServiceMonitor resource of the
+// Prometheus Operator is served by the API server.
+func serviceMonitorsAvailable(dc discovery.DiscoveryInterface) (bool, error) {
+	gv := resources.ServiceMonitorGVR.GroupVersion().String()
+	list, err := dc.ServerResourcesForGroupVersion(gv)
+	if apierrs.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("discover %s resources: %w", gv, err)
+	}
+
+	for _, res := range list.APIResources {
+		if res.Name == resources.ServiceMonitorGVR.Resource {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // NewController creates a Reconciler and returns the result of NewImpl.
 func NewController(
 	ctx context.Context,
@@ -44,29 +127,68 @@ func NewController(
 
 	vsphereInformer := vsphereinformer.Get(ctx)
 	deploymentInformer := deploymentinformer.Get(ctx)
+	roleInformer := roleinformer.Get(ctx)
 	rbacInformer := rbacinformer.Get(ctx)
 	cmInformer := cminformer.Get(ctx)
 	vspherebindingInformer := vspherebindinginformer.Get(ctx)
 	saInformer := sainformer.Get(ctx)
+	serviceInformer := serviceinformer.Get(ctx)
+	// only the adapter pods are cached, not all pods of the cluster
+	podInformer := podinformer.Get(ctx, resources.NameLabelKey)
+	eventTypeInformer := eventtypeinformer.Get(ctx)
+	// only the checkpoint Leases of the sources are cached, not the frequently
+	// renewed leader election Leases
+	leaseInformer := leaseinformer.Get(ctx, resources.NameLabelKey)
 
 	var env envConfig
 	if err := envconfig.Process("", &env); err != nil {
 		logger.Fatalf("Unable to read environment config: %v", err)
 	}
 
+	adapterRes, err := adapterResources()
+	if err != nil {
+		logger.Fatalf("Unable to read adapter resources: %v", err)
+	}
+
+	if *adapterMetricsPort < 1024 || *adapterMetricsPort > 65535 {
+		logger.Fatalf("Invalid adapter metrics port %d: must be between 1024 and 65535", *adapterMetricsPort)
+	}
+
 	r := &Reconciler{
-		kubeclient:           kubeclient.Get(ctx),
-		eventingclient:       eventingclient.Get(ctx),
-		client:               client.Get(ctx),
-		deploymentLister:     deploymentInformer.Lister(),
-		vspherebindingLister: vspherebindingInformer.Lister(),
-		rbacLister:           rbacInformer.Lister(),
-		cmLister:             cmInformer.Lister(),
-		saLister:             saInformer.Lister(),
-		adapterImage:         env.VSphereAdapter,
-		loggingContext:       ctx,
+		kubeclient:             kubeclient.Get(ctx),
+		eventingclient:         eventingclient.Get(ctx),
+		client:                 client.Get(ctx),
+		deploymentLister:       deploymentInformer.Lister(),
+		vspherebindingLister:   vspherebindingInformer.Lister(),
+		roleLister:             roleInformer.Lister(),
+		rbacLister:             rbacInformer.Lister(),
+		cmLister:               cmInformer.Lister(),
+		saLister:               saInformer.Lister(),
+		serviceLister:          serviceInformer.Lister(),
+		podLister:              podInformer.Lister(),
+		eventTypeLister:        eventTypeInformer.Lister(),
+		leaseLister:            leaseInformer.Lister(),
+		adapterImage:           env.VSphereAdapter,
+		adapterResources:       adapterRes,
+		adapterMetricsPort:     *adapterMetricsPort,
+		checkpointLagThreshold: *checkpointLagThreshold,
+		loggingContext:         ctx,
+	}
+
+	if *adapterServiceMonitor {
+		ok, err := serviceMonitorsAvailable(r.kubeclient.Discovery())
+		switch {
+		case err != nil:
+			logger.Fatalf("Unable to detect the ServiceMonitor CRD: %v", err)
+		case ok:
+			r.serviceMonitorClient = dynamicclient.Get(ctx).Resource(resources.ServiceMonitorGVR)
+		default:
+			logger.Warn("ServiceMonitors are enabled, but the ServiceMonitor CRD is not installed, no ServiceMonitors are created.")
+		}
 	}
+
 	impl := vspherereconciler.NewImpl(ctx, r)
+	r.enqueueAfter = impl.EnqueueAfter
 
 	logger.Info("Setting up event handlers.")
 
@@ -82,23 +204,66 @@ func NewController(
 		Handler:    controller.HandleAll(impl.EnqueueControllerOf),
 	})
 
+	serviceInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: controller.FilterControllerGK(v1alpha1.Kind("VSphereSource")),
+		Handler:    controller.HandleAll(impl.EnqueueControllerOf),
+	})
+
+	// adapter pods are owned by a ReplicaSet, the source is found by label
+	podInformer.Informer().AddEventHandler(controller.HandleAll(
+		impl.EnqueueLabelOfNamespaceScopedResource("", resources.NameLabelKey),
+	))
+
+	eventTypeInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: controller.FilterControllerGK(v1alpha1.Kind("VSphereSource")),
+		Handler:    controller.HandleAll(impl.EnqueueControllerOf),
+	})
+
+	roleInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: controller.FilterControllerGK(v1alpha1.Kind("VSphereSource")),
+		Handler:    controller.HandleAll(impl.EnqueueControllerOf),
+	})
+
 	rbacInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
 		FilterFunc: controller.FilterControllerGK(v1alpha1.Kind("VSphereSource")),
 		Handler:    controller.HandleAll(impl.EnqueueControllerOf),
 	})
 
 	// Don't trigger off of CM updates because we don't care about the content
-	// and it is high churn.
+	// and it is high churn, except for the CA bundles tracked by the sources.
+	// The same applies to the checkpoint Leases.
 
 	vspherebindingInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
 		FilterFunc: controller.FilterControllerGK(v1alpha1.Kind("VSphereSource")),
 		Handler:    controller.HandleAll(impl.EnqueueControllerOf),
 	})
 
-	r.resolver = resolver.NewURIResolverFromTracker(ctx, tracker.New(impl.EnqueueKey, controller.GetTrackerLease(ctx)))
+	r.tracker = tracker.New(impl.EnqueueKey, controller.GetTrackerLease(ctx))
+	r.resolver = resolver.NewURIResolverFromTracker(ctx, r.tracker)
+
+	cmInformer.Informer().AddEventHandler(controller.HandleAll(
+		controller.EnsureTypeMeta(r.tracker.OnChanged, corev1.SchemeGroupVersion.WithKind("ConfigMap")),
+	))
 
-	cmw.Watch(logging.ConfigMapName(), r.UpdateFromLoggingConfigMap)
-	cmw.Watch(metrics.ConfigMapName(), r.UpdateFromMetricsConfigMap)
+	// only the credentials Secrets of the sources are watched, by name
+	r.secretWatches = newSecretWatches(ctx, r.kubeclient, controller.HandleAll(
+		controller.EnsureTypeMeta(r.tracker.OnChanged, corev1.SchemeGroupVersion.WithKind("Secret")),
+	), controller.GetResyncPeriod(ctx))
+
+	// The adapters are configured from these ConfigMaps, so all sources are
+	// resynced on a change to roll out their adapters. Adapter deployments
+	// whose spec does not change are not updated.
+	resync := func(update configmap.Observer) configmap.Observer {
+		return func(cm *corev1.ConfigMap) {
+			update(cm)
+			impl.GlobalResync(vsphereInformer.Informer())
+		}
+	}
+	cmw.Watch(logging.ConfigMapName(), resync(r.UpdateFromLoggingConfigMap))
+	cmw.Watch(metrics.ConfigMapName(), resync(r.UpdateFromMetricsConfigMap))
+	cmw.Watch(tracingconfig.ConfigName, resync(r.UpdateFromTracingConfigMap))
+	cmw.Watch(featuresConfigMapName, resync(r.UpdateFromFeaturesConfigMap))
+	cmw.Watch(config.VSphereConfigName, resync(r.UpdateFromVSphereConfigMap))
 
 	return impl
 }
@@ -0,0 +1,32 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vspheresource
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/reconciler"
+
+	sourcesv1alpha1 "github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
+)
+
+// recordNormalEvent logs the given message and records it as a Normal event
+// on the source.
+func recordNormalEvent(ctx context.Context, vms *sourcesv1alpha1.VSphereSource, reason, messageFmt string, args ...interface{}) {
+	logging.FromContext(ctx).Infof(messageFmt, args...)
+	controller.GetEventRecorder(ctx).Eventf(vms, corev1.EventTypeNormal, reason, messageFmt, args...)
+}
+
+// newFailedEvent returns an error carrying a Warning event with the given
+// reason. The event is recorded on the source by the generated reconciler and,
+// because the event is wrapped, the source is still requeued.
+func newFailedEvent(reason, messageFmt string, args ...interface{}) error {
+	return fmt.Errorf("%w", reconciler.NewEvent(corev1.EventTypeWarning, reason, messageFmt, args...))
+}
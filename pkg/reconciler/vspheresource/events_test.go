@@ -0,0 +1,35 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vspheresource
+
+import (
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/reconciler"
+)
+
+func TestNewFailedEvent(t *testing.T) {
+	cause := errors.New("boom")
+	err := newFailedEvent("DeploymentFailed", "failed to create deployment %q: %w", "adapter", cause)
+
+	var event *reconciler.ReconcilerEvent
+	if !reconciler.EventAs(err, &event) {
+		t.Fatalf("newFailedEvent() = %v, want wrapped reconciler event", err)
+	}
+	if event.EventType != corev1.EventTypeWarning || event.Reason != "DeploymentFailed" {
+		t.Errorf("newFailedEvent() event = %s %s, want %s %s", event.EventType, event.Reason, corev1.EventTypeWarning, "DeploymentFailed")
+	}
+
+	// a bare reconciler event is considered a successful reconciliation
+	if _, ok := err.(*reconciler.ReconcilerEvent); ok {
+		t.Error("newFailedEvent() returned a bare reconciler event, source would not be requeued")
+	}
+	if !errors.Is(err, cause) {
+		t.Errorf("newFailedEvent() = %v, want wrapped %v", err, cause)
+	}
+}
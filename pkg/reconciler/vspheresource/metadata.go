@@ -0,0 +1,67 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vspheresource
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspheresource/resources"
+)
+
+// mergeMetadata returns the labels and annotations of existing with those of
+// desired applied, and whether this changed existing. The labels and
+// annotations which were copied from the source to existing but are no longer
+// desired are removed, others, e.g. those added by other controllers, are
+// preserved.
+func mergeMetadata(existing, desired metav1.Object) (map[string]string, map[string]string, bool) {
+	labels := mergeKeys(existing.GetLabels(), desired.GetLabels(),
+		propagatedKeys(existing, resources.PropagatedLabelsAnnotationKey))
+
+	stale := propagatedKeys(existing, resources.PropagatedAnnotationsAnnotationKey)
+	// the recorded keys are removed with the propagated keys
+	stale = append(stale, resources.PropagatedLabelsAnnotationKey, resources.PropagatedAnnotationsAnnotationKey,
+		resources.PullSecretsAnnotationKey)
+	annotations := mergeKeys(existing.GetAnnotations(), desired.GetAnnotations(), stale)
+
+	changed := !equality.Semantic.DeepEqual(labels, existing.GetLabels()) ||
+		!equality.Semantic.DeepEqual(annotations, existing.GetAnnotations())
+	return labels, annotations, changed
+}
+
+// mergeKeys returns a copy of existing without the given stale keys which are
+// not desired and with the desired keys set.
+func mergeKeys(existing, desired map[string]string, stale []string) map[string]string {
+	merged := make(map[string]string, len(existing)+len(desired))
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for _, k := range stale {
+		if _, ok := desired[k]; !ok {
+			delete(merged, k)
+		}
+	}
+	for k, v := range desired {
+		merged[k] = v
+	}
+
+	if len(merged) == 0 && existing == nil {
+		return nil
+	}
+	return merged
+}
+
+// propagatedKeys returns the keys copied from the source recorded in the
+// annotation with the given key.
+func propagatedKeys(obj metav1.Object, annotationKey string) []string {
+	keys, ok := obj.GetAnnotations()[annotationKey]
+	if !ok || keys == "" {
+		return nil
+	}
+	return strings.Split(keys, ",")
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vspheresource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	appsv1listers "k8s.io/client-go/listers/apps/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	rbacv1listers "k8s.io/client-go/listers/rbac/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"knative.dev/pkg/controller"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
+	"github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspheresource/resources"
+	resourcenames "github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspheresource/resources/names"
+	"github.com/vmware-tanzu/sources-for-knative/pkg/vsphere"
+)
+
+func Test_mergeMetadata(t *testing.T) {
+	tests := []struct {
+		name            string
+		existing        metav1.ObjectMeta
+		desired         metav1.ObjectMeta
+		wantLabels      map[string]string
+		wantAnnotations map[string]string
+		wantChanged     bool
+	}{
+		{
+			name: "up to date with foreign keys",
+			existing: metav1.ObjectMeta{
+				Labels: map[string]string{"team": "infra", "other": "keep"},
+				Annotations: map[string]string{
+					resources.PropagatedLabelsAnnotationKey: "team",
+					"deployment.kubernetes.io/revision":     "2",
+				},
+			},
+			desired: metav1.ObjectMeta{
+				Labels:      map[string]string{"team": "infra"},
+				Annotations: map[string]string{resources.PropagatedLabelsAnnotationKey: "team"},
+			},
+			wantLabels: map[string]string{"team": "infra", "other": "keep"},
+			wantAnnotations: map[string]string{
+				resources.PropagatedLabelsAnnotationKey: "team",
+				"deployment.kubernetes.io/revision":     "2",
+			},
+			wantChanged: false,
+		},
+		{
+			name: "propagated keys removed from the source",
+			existing: metav1.ObjectMeta{
+				Labels: map[string]string{"team": "infra", "cost-center": "a", "other": "keep"},
+				Annotations: map[string]string{
+					"owner":                                 "jane",
+					resources.PropagatedLabelsAnnotationKey: "cost-center,team",
+					resources.PropagatedAnnotationsAnnotationKey: "owner",
+				},
+			},
+			desired: metav1.ObjectMeta{
+				Labels:      map[string]string{"team": "infra"},
+				Annotations: map[string]string{resources.PropagatedLabelsAnnotationKey: "team"},
+			},
+			wantLabels:      map[string]string{"team": "infra", "other": "keep"},
+			wantAnnotations: map[string]string{resources.PropagatedLabelsAnnotationKey: "team"},
+			wantChanged:     true,
+		},
+		{
+			name: "changed and added keys",
+			existing: metav1.ObjectMeta{
+				Labels: map[string]string{"team": "infra"},
+			},
+			desired: metav1.ObjectMeta{
+				Labels:      map[string]string{"team": "platform"},
+				Annotations: map[string]string{"owner": "jane", resources.PropagatedAnnotationsAnnotationKey: "owner"},
+			},
+			wantLabels:      map[string]string{"team": "platform"},
+			wantAnnotations: map[string]string{"owner": "jane", resources.PropagatedAnnotationsAnnotationKey: "owner"},
+			wantChanged:     true,
+		},
+		{
+			name:        "no metadata",
+			wantChanged: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := tt.existing.DeepCopy()
+
+			labels, annotations, changed := mergeMetadata(&tt.existing, &tt.desired)
+			if changed != tt.wantChanged {
+				t.Errorf("mergeMetadata() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if diff := cmp.Diff(tt.wantLabels, labels); diff != "" {
+				t.Errorf("mergeMetadata() labels (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantAnnotations, annotations); diff != "" {
+				t.Errorf("mergeMetadata() annotations (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(existing, &tt.existing); diff != "" {
+				t.Errorf("mergeMetadata() modified existing (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestReconcileSourceMetadataRemoved(t *testing.T) {
+	ctx := context.Background()
+
+	labeled := newTestSource()
+	labeled.Labels = map[string]string{"team": "infra", "cost-center": "a"}
+	labeled.Annotations = map[string]string{"owner": "jane"}
+
+	// the label and annotation are removed from the source
+	vms := labeled.DeepCopy()
+	delete(vms.Labels, "cost-center")
+	vms.Annotations = nil
+
+	deployment, err := resources.MakeDeployment(ctx, labeled, resources.AdapterArgs{
+		Image:            "adapter-image",
+		HealthPort:       vsphere.DefaultHealthPort,
+		PrometheusScrape: true,
+	})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+	// set by the deployment controller
+	deployment.Annotations["deployment.kubernetes.io/revision"] = "1"
+
+	existing := []metav1.Object{
+		deployment,
+		resources.MakeConfigMap(ctx, labeled),
+		resources.MakeRole(ctx, labeled),
+		resources.MakeRoleBinding(ctx, labeled),
+		resources.MakeServiceAccount(ctx, labeled),
+		resources.MakeMetricsService(ctx, labeled, 0),
+	}
+
+	var objs []runtime.Object
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, obj := range existing {
+		objs = append(objs, obj.(runtime.Object))
+		if err := indexer.Add(obj); err != nil {
+			t.Fatalf("add %s to indexer: %v", obj.GetName(), err)
+		}
+	}
+
+	kc := fake.NewSimpleClientset(objs...)
+	r := &Reconciler{
+		kubeclient:       kc,
+		deploymentLister: appsv1listers.NewDeploymentLister(indexer),
+		cmLister:         corev1listers.NewConfigMapLister(indexer),
+		roleLister:       rbacv1listers.NewRoleLister(indexer),
+		rbacLister:       rbacv1listers.NewRoleBindingLister(indexer),
+		saLister:         corev1listers.NewServiceAccountLister(indexer),
+		serviceLister:    corev1listers.NewServiceLister(indexer),
+		podLister:        corev1listers.NewPodLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+		adapterImage:     "adapter-image",
+	}
+
+	ctx = controller.WithEventRecorder(ctx, record.NewFakeRecorder(10))
+	for _, reconcile := range []func(context.Context, *v1alpha1.VSphereSource) error{
+		r.reconcileDeployment,
+		r.reconcileConfigMap,
+		r.reconcileRole,
+		r.reconcileRoleBinding,
+		r.reconcileServiceAccount,
+		r.reconcileMetricsService,
+	} {
+		if err := reconcile(ctx, vms.DeepCopy()); err != nil {
+			t.Fatalf("reconcile error = %v", err)
+		}
+	}
+
+	updated := map[string]metav1.Object{}
+	for _, action := range kc.Actions() {
+		if action.GetVerb() != "update" {
+			t.Errorf("unexpected %s of %s", action.GetVerb(), action.GetResource().Resource)
+			continue
+		}
+		obj := action.(interface{ GetObject() runtime.Object }).GetObject().(metav1.Object)
+		updated[action.GetResource().Resource] = obj
+	}
+
+	for _, resource := range []string{"deployments", "configmaps", "roles", "rolebindings", "serviceaccounts", "services"} {
+		obj, ok := updated[resource]
+		if !ok {
+			t.Errorf("%s not updated", resource)
+			continue
+		}
+		if got := obj.GetLabels(); got["team"] != "infra" || got["cost-center"] != "" {
+			t.Errorf("%s labels = %v, want team without cost-center", resource, got)
+		}
+		if got := obj.GetAnnotations(); got["owner"] != "" || got[resources.PropagatedAnnotationsAnnotationKey] != "" ||
+			got[resources.PropagatedLabelsAnnotationKey] != "team" {
+			t.Errorf("%s annotations = %v, want only team propagated", resource, got)
+		}
+	}
+
+	if d, ok := updated["deployments"]; ok {
+		if got := d.GetAnnotations()["deployment.kubernetes.io/revision"]; got != "1" {
+			t.Errorf("deployment revision annotation = %q, want preserved", got)
+		}
+		template := d.(*appsv1.Deployment).Spec.Template
+		if _, ok := template.Labels["cost-center"]; ok {
+			t.Errorf("pod template labels = %v, want without cost-center", template.Labels)
+		}
+		if _, ok := template.Annotations["owner"]; ok {
+			t.Errorf("pod template annotations = %v, want without owner", template.Annotations)
+		}
+		if d.GetName() != resourcenames.Deployment(vms) {
+			t.Errorf("deployment name = %q, want %q", d.GetName(), resourcenames.Deployment(vms))
+		}
+	}
+}
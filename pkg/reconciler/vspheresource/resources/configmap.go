@@ -14,15 +14,45 @@ import (
 
 	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
 	"github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspheresource/resources/names"
+	"github.com/vmware-tanzu/sources-for-knative/pkg/vsphere"
 )
 
-// MakeConfigMap creates a ConfigMap owned by the VSphereSource
+// MakeConfigMap creates a ConfigMap owned by the VSphereSource in which the
+// adapter stores its state. The labels and data keys set here are owned by the
+// reconciler, the keys written by the adapter are not part of the desired
+// state.
 func MakeConfigMap(ctx context.Context, vms *v1alpha1.VSphereSource) *corev1.ConfigMap {
+	labels, annotations := withSourceMetadata(vms, Labels(vms), nil)
 	return &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            names.ConfigMap(vms),
 			Namespace:       vms.Namespace,
+			Labels:          labels,
+			Annotations:     annotations,
 			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(vms)},
 		},
 	}
 }
+
+// MakeCACertsConfigMap creates a ConfigMap owned by the VSphereSource holding
+// the CA bundle trusted by the adapter
+func MakeCACertsConfigMap(ctx context.Context, vms *v1alpha1.VSphereSource) *corev1.ConfigMap {
+	var caCerts string
+	if vms.Spec.CACerts != nil {
+		caCerts = *vms.Spec.CACerts
+	}
+
+	labels, annotations := withSourceMetadata(vms, nil, nil)
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            names.CACertsConfigMap(vms),
+			Namespace:       vms.Namespace,
+			Labels:          labels,
+			Annotations:     annotations,
+			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(vms)},
+		},
+		Data: map[string]string{
+			vsphere.CACertsKey: caCerts,
+		},
+	}
+}
@@ -9,12 +9,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"knative.dev/pkg/kmeta"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/ptr"
@@ -24,16 +27,100 @@ import (
 	"github.com/vmware-tanzu/sources-for-knative/pkg/vsphere"
 )
 
+// Probe settings match the API server defaults so that the live Deployment
+// does not differ from the desired one.
+const (
+	probeTimeoutSeconds   = 1
+	probePeriodSeconds    = 10
+	probeFailureThreshold = 3
+)
+
+const (
+	// metricsPortName is the name of the adapter container port serving the
+	// Prometheus metrics endpoint
+	metricsPortName = "metrics"
+	// metricsPort is the default Prometheus port of the knative metrics
+	// exporter
+	metricsPort = 9090
+	// metricsPath is the path of the Prometheus metrics endpoint of the
+	// knative metrics exporter
+	metricsPath = "/metrics"
+	// healthPortName is the name of the adapter container port serving the
+	// health and debug endpoints
+	healthPortName = "health"
+)
+
+// oidcTokenExpirationSeconds is the lifetime of the OIDC token presented to
+// the sink
+const oidcTokenExpirationSeconds = 3600
+
+// terminationGracePeriod leaves the adapter time to deliver pending events and
+// save its final checkpoint within the shutdown timeout before it is killed.
+var terminationGracePeriod = vsphere.DefaultShutdownTimeout + vsphere.ShutdownGracePeriodMargin
+
 type AdapterArgs struct {
-	Image         string
-	LoggingConfig string
+	// CABundleHash is the hash of the vCenter CA bundle, which rolls out the
+	// adapter when the bundle changes
+	CABundleHash string
+	// CredentialsHash is the hash of the vCenter credentials, which rolls out
+	// the adapter when the credentials are rotated
+	CredentialsHash string
+	Image           string
+	LoggingConfig   string
+	// LogLevel overrides the level of the adapter logger in LoggingConfig
+	LogLevel      string
 	MetricsConfig string
+	TracingConfig string
+	EventFilters  string
+	// MaxEventAge is the maximum age of the events delivered by the adapter,
+	// older events are skipped
+	MaxEventAge time.Duration
+	// FailoverAddresses are the vCenters the adapter fails over to when the
+	// vCenter of the source is unreachable
+	FailoverAddresses []string
+	// Resources are the default compute resources of the adapter container
+	Resources corev1.ResourceRequirements
+	// HealthPort is the port of the adapter health endpoints, probes are
+	// disabled if not set
+	HealthPort int
+	// MetricsPort is the port of the Prometheus metrics endpoint of the
+	// adapter, the default port of the knative metrics exporter if not set
+	MetricsPort int
+	// PrometheusScrape adds the prometheus.io scrape annotations to the
+	// adapter pods, set when the adapter exports metrics with the Prometheus
+	// backend
+	PrometheusScrape bool
 }
 
-func MakeDeployment(ctx context.Context, vms *v1alpha1.VSphereSource, args AdapterArgs) (*appsv1.Deployment, error) {
-	labels := map[string]string{
-		"vspheresources.sources.tanzu.vmware.com/name": vms.Name,
+// NameLabelKey is the label holding the name of the source of the adapter
+// pods and other resources created for a source.
+const NameLabelKey = "vspheresources.sources.tanzu.vmware.com/name"
+
+// The prometheus.io annotations of the adapter pods select them for scraping
+// by the common Prometheus scrape configurations.
+const (
+	PrometheusScrapeAnnotationKey = "prometheus.io/scrape"
+	PrometheusPortAnnotationKey   = "prometheus.io/port"
+	PrometheusPathAnnotationKey   = "prometheus.io/path"
+)
+
+// CABundleHashAnnotationKey is the annotation of the adapter pods holding the
+// hash of the vCenter CA bundle of the source.
+const CABundleHashAnnotationKey = "vspheresources.sources.tanzu.vmware.com/ca-bundle-hash"
+
+// CredentialsChecksumAnnotationKey is the annotation of the adapter pods
+// holding the hash of the vCenter credentials of the source.
+const CredentialsChecksumAnnotationKey = "checksum/credentials"
+
+// Labels returns the labels of the adapter pods of the given source.
+func Labels(vms *v1alpha1.VSphereSource) map[string]string {
+	return map[string]string{
+		NameLabelKey: vms.Name,
 	}
+}
+
+func MakeDeployment(ctx context.Context, vms *v1alpha1.VSphereSource, args AdapterArgs) (*appsv1.Deployment, error) {
+	labels := Labels(vms)
 
 	var ceOverrides string
 	if vms.Spec.CloudEventOverrides != nil {
@@ -46,8 +133,10 @@ func MakeDeployment(ctx context.Context, vms *v1alpha1.VSphereSource, args Adapt
 	}
 
 	cpconf := vsphere.CheckpointConfig{
-		MaxAge: time.Second * time.Duration(vms.Spec.CheckpointConfig.MaxAgeSeconds),
-		Period: time.Second * time.Duration(vms.Spec.CheckpointConfig.PeriodSeconds),
+		MaxAge:  time.Second * time.Duration(vms.Spec.CheckpointConfig.MaxAgeSeconds),
+		Period:  time.Second * time.Duration(vms.Spec.CheckpointConfig.PeriodSeconds),
+		Mode:    string(vms.Spec.CheckpointConfig.Mode),
+		Backend: string(vms.Spec.CheckpointConfig.Backend),
 	}
 
 	jsonBytes, err := json.Marshal(&cpconf)
@@ -55,28 +144,263 @@ func MakeDeployment(ctx context.Context, vms *v1alpha1.VSphereSource, args Adapt
 		return nil, fmt.Errorf("marshal checkpoint config: %w", err)
 	}
 
+	loggingConfig, err := adapterLoggingConfig(args.LoggingConfig, args.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := args.Resources
+	overrides := vms.Spec.AdapterOverrides
+	if overrides == nil {
+		overrides = &v1alpha1.AdapterOverrides{}
+	}
+	if len(overrides.Resources.Requests) > 0 || len(overrides.Resources.Limits) > 0 {
+		resources = overrides.Resources
+	}
+
+	gracePeriod, shutdownTimeout := terminationGracePeriod, vsphere.DefaultShutdownTimeout
+	if overrides.TerminationGracePeriodSeconds != nil {
+		gracePeriod = time.Duration(*overrides.TerminationGracePeriodSeconds) * time.Second
+		shutdownTimeout = gracePeriod - vsphere.ShutdownGracePeriodMargin
+	}
+
+	// the labels and annotations set by the controller take precedence over
+	// the ones of the overrides, the reconciliation depends on them
+	podLabels := make(map[string]string, len(overrides.Labels)+len(labels))
+	for k, v := range overrides.Labels {
+		podLabels[k] = v
+	}
+	for k, v := range labels {
+		podLabels[k] = v
+	}
+
+	promPort := adapterMetricsPort(args.MetricsPort)
+
+	var podAnnotations map[string]string
+	if len(overrides.Annotations) > 0 || args.CABundleHash != "" || args.CredentialsHash != "" || args.PrometheusScrape {
+		podAnnotations = make(map[string]string, len(overrides.Annotations)+5)
+	}
+	for k, v := range overrides.Annotations {
+		podAnnotations[k] = v
+	}
+	if args.PrometheusScrape {
+		podAnnotations[PrometheusScrapeAnnotationKey] = "true"
+		podAnnotations[PrometheusPortAnnotationKey] = strconv.Itoa(promPort)
+		podAnnotations[PrometheusPathAnnotationKey] = metricsPath
+	}
+	if args.CABundleHash != "" {
+		podAnnotations[CABundleHashAnnotationKey] = args.CABundleHash
+	}
+	if args.CredentialsHash != "" {
+		podAnnotations[CredentialsChecksumAnnotationKey] = args.CredentialsHash
+	}
+	podLabels, podAnnotations = withSourceMetadata(vms, podLabels, podAnnotations)
+	deploymentLabels, deploymentAnnotations := withSourceMetadata(vms, labels, nil)
+
+	var (
+		volumes      []corev1.Volume
+		volumeMounts []corev1.VolumeMount
+		caCerts      string
+	)
+	if vms.Spec.CACerts != nil {
+		volumes = append(volumes, corev1.Volume{
+			Name: vsphere.CACertsVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: names.CACertsConfigMap(vms),
+					},
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      vsphere.CACertsVolumeName,
+			MountPath: vsphere.CACertsMountPath,
+			ReadOnly:  true,
+		})
+		caCerts = filepath.Join(vsphere.CACertsMountPath, vsphere.CACertsKey)
+	}
+
+	// the kubelet refreshes the projected token before it expires
+	var oidcTokenPath string
+	if vms.Spec.SinkAudience != nil {
+		volumes = append(volumes, corev1.Volume{
+			Name: vsphere.OIDCTokenVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: []corev1.VolumeProjection{{
+						ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+							Audience:          *vms.Spec.SinkAudience,
+							ExpirationSeconds: ptr.Int64(oidcTokenExpirationSeconds),
+							Path:              vsphere.OIDCTokenKey,
+						},
+					}},
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      vsphere.OIDCTokenVolumeName,
+			MountPath: vsphere.OIDCTokenMountPath,
+			ReadOnly:  true,
+		})
+		oidcTokenPath = filepath.Join(vsphere.OIDCTokenMountPath, vsphere.OIDCTokenKey)
+	}
+
+	// the proxy only applies to the vCenter connection, not to deliveries
+	var httpProxy, httpsProxy, noProxy string
+	if p := vms.Spec.Proxy; p != nil {
+		httpProxy, httpsProxy, noProxy = p.HTTPProxy, p.HTTPSProxy, p.NoProxy
+	}
+
+	var sinkCACerts string
+	if vms.Spec.SinkCACerts != nil {
+		sinkCACerts = *vms.Spec.SinkCACerts
+	}
+
+	var pollInterval string
+	if vms.Spec.PollIntervalSeconds > 0 {
+		pollInterval = (time.Second * time.Duration(vms.Spec.PollIntervalSeconds)).String()
+	}
+
+	var reconnectBackoff string
+	if vms.Spec.ReconnectBackoffSeconds > 0 {
+		reconnectBackoff = (time.Second * time.Duration(vms.Spec.ReconnectBackoffSeconds)).String()
+	}
+
+	var maxEventAge string
+	if args.MaxEventAge > 0 {
+		maxEventAge = args.MaxEventAge.String()
+	}
+
+	var batchSize, batchTimeout string
+	if vms.Spec.BatchSize > 0 {
+		batchSize = strconv.Itoa(int(vms.Spec.BatchSize))
+	}
+	if vms.Spec.BatchTimeoutSeconds > 0 {
+		batchTimeout = (time.Second * time.Duration(vms.Spec.BatchTimeoutSeconds)).String()
+	}
+
+	var ordered string
+	if vms.Spec.Ordered {
+		ordered = strconv.FormatBool(true)
+	}
+
+	var sinkTimeout string
+	if vms.Spec.SinkTimeoutSeconds > 0 {
+		sinkTimeout = (time.Second * time.Duration(vms.Spec.SinkTimeoutSeconds)).String()
+	}
+
+	var retryMax, retryBackoff, retryBackoffPolicy string
+	if retry := vms.Spec.Retry; retry != nil {
+		retryMax = strconv.Itoa(int(retry.MaxRetries))
+		retryBackoff = (time.Second * time.Duration(retry.InitialBackoffSeconds)).String()
+		retryBackoffPolicy = string(retry.BackoffPolicy)
+	}
+
+	var rateLimit, rateLimitBurst, rateLimitOverflowPolicy string
+	if rl := vms.Spec.RateLimit; rl != nil {
+		rateLimit = strconv.Itoa(int(rl.EventsPerSecond))
+		rateLimitBurst = strconv.Itoa(int(rl.Burst))
+		rateLimitOverflowPolicy = string(rl.OverflowPolicy)
+	}
+
+	failoverThreshold := vsphere.DefaultFailoverThreshold
+	if vms.Spec.FailoverThreshold > 0 {
+		failoverThreshold = int(vms.Spec.FailoverThreshold)
+	}
+
+	// only the leader of multiple replicas polls vCenter
+	replicas := ptr.Int32(1)
+	var leaderElectionLease string
+	if vms.Spec.Replicas != nil {
+		replicas = vms.Spec.Replicas
+		if *replicas > 1 {
+			leaderElectionLease = names.Lease(vms)
+		}
+	}
+	// a paused source keeps its adapter configured but not running, so
+	// unpausing only scales the Deployment up again
+	if vms.Spec.Paused {
+		replicas = ptr.Int32(0)
+	}
+
+	ports := []corev1.ContainerPort{{
+		Name:          metricsPortName,
+		ContainerPort: int32(promPort),
+	}}
+
+	var livenessProbe, readinessProbe *corev1.Probe
+	if args.HealthPort > 0 {
+		ports = append(ports, corev1.ContainerPort{
+			Name:          healthPortName,
+			ContainerPort: int32(args.HealthPort),
+		})
+
+		livenessProbe = &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Path: vsphere.LivenessPath,
+					Port: intstr.FromInt(args.HealthPort),
+				},
+			},
+			TimeoutSeconds:   probeTimeoutSeconds,
+			PeriodSeconds:    probePeriodSeconds,
+			SuccessThreshold: 1,
+			FailureThreshold: probeFailureThreshold,
+		}
+		readinessProbe = &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Path: vsphere.ReadinessPath,
+					Port: intstr.FromInt(args.HealthPort),
+				},
+			},
+			TimeoutSeconds:   probeTimeoutSeconds,
+			PeriodSeconds:    probePeriodSeconds,
+			SuccessThreshold: 1,
+			FailureThreshold: probeFailureThreshold,
+		}
+	}
+
 	return &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            names.Deployment(vms),
 			Namespace:       vms.Namespace,
 			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(vms)},
-			Labels:          labels,
+			Labels:          deploymentLabels,
+			Annotations:     deploymentAnnotations,
 		},
 		Spec: appsv1.DeploymentSpec{
-			Replicas: ptr.Int32(1),
+			Replicas: replicas,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: labels,
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels,
+					Labels:      podLabels,
+					Annotations: podAnnotations,
 				},
 				Spec: corev1.PodSpec{
-					ServiceAccountName: names.ServiceAccount(vms),
+					ServiceAccountName:            names.ServiceAccount(vms),
+					ImagePullSecrets:              overrides.ImagePullSecrets,
+					TerminationGracePeriodSeconds: ptr.Int64(int64(gracePeriod.Seconds())),
+					NodeSelector:                  overrides.NodeSelector,
+					Tolerations:                   overrides.Tolerations,
+					Affinity:                      overrides.Affinity,
+					Volumes:                       volumes,
 					Containers: []corev1.Container{{
-						Name:  "adapter",
-						Image: args.Image,
-						Env: []corev1.EnvVar{{
+						Name:           "adapter",
+						Image:          args.Image,
+						Resources:      resources,
+						VolumeMounts:   volumeMounts,
+						LivenessProbe:  livenessProbe,
+						ReadinessProbe: readinessProbe,
+						// the adapter logs the fatal error when crashing, which
+						// is reported in the AdapterReady condition
+						TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+						Ports:                    ports,
+						SecurityContext:          adapterSecurityContext(overrides),
+						Env: append(withoutEmptyEnv([]corev1.EnvVar{{
 							Name: "NAMESPACE",
 							ValueFrom: &corev1.EnvVarSource{
 								FieldRef: &corev1.ObjectFieldSelector{
@@ -95,23 +419,151 @@ func MakeDeployment(ctx context.Context, vms *v1alpha1.VSphereSource, args Adapt
 							Value: args.MetricsConfig,
 						}, {
 							Name:  "K_LOGGING_CONFIG",
-							Value: args.LoggingConfig,
+							Value: loggingConfig,
+						}, {
+							Name:  "K_TRACING_CONFIG",
+							Value: args.TracingConfig,
+						}, {
+							// the adapter metrics are labeled with the source
+							Name:  "VSPHERE_SOURCE_NAME",
+							Value: vms.Name,
 						}, {
 							Name:  "VSPHERE_KVSTORE_CONFIGMAP",
 							Value: names.ConfigMap(vms),
+						}, {
+							// set with any backend to migrate the checkpoint
+							// when switching backends
+							Name:  "VSPHERE_KVSTORE_LEASE",
+							Value: names.CheckpointLease(vms),
 						}, {
 							Name:  "VSPHERE_CHECKPOINT_CONFIG",
 							Value: string(jsonBytes),
 						}, {
 							Name:  "VSPHERE_PAYLOAD_ENCODING",
 							Value: strings.ToLower(vms.Spec.PayloadEncoding),
+						}, {
+							Name:  "VSPHERE_PAYLOAD_FORMAT",
+							Value: string(vms.Spec.PayloadFormat),
+						}, {
+							Name:  "VSPHERE_EVENT_FILTERS",
+							Value: args.EventFilters,
+						}, {
+							Name:  "VSPHERE_EVENT_TYPES",
+							Value: strings.Join(vms.Spec.EventTypes, ","),
+						}, {
+							Name:  "VSPHERE_CATEGORIES",
+							Value: strings.Join(vms.Spec.Categories, ","),
+						}, {
+							Name:  "VSPHERE_START_FROM",
+							Value: string(vms.Spec.StartFrom),
+						}, {
+							Name:  "VSPHERE_POLL_INTERVAL",
+							Value: pollInterval,
+						}, {
+							Name:  "VSPHERE_MAX_EVENT_AGE",
+							Value: maxEventAge,
+						}, {
+							Name:  "VSPHERE_RECONNECT_BACKOFF",
+							Value: reconnectBackoff,
+						}, {
+							Name:  "VSPHERE_SHUTDOWN_TIMEOUT",
+							Value: shutdownTimeout.String(),
+						}, {
+							Name:  "VSPHERE_BATCH_SIZE",
+							Value: batchSize,
+						}, {
+							Name:  "VSPHERE_BATCH_TIMEOUT",
+							Value: batchTimeout,
+						}, {
+							Name:  "VSPHERE_ORDERED",
+							Value: ordered,
+						}, {
+							Name:  "VSPHERE_SINK_TIMEOUT",
+							Value: sinkTimeout,
+						}, {
+							Name:  "VSPHERE_RETRY_MAX",
+							Value: retryMax,
+						}, {
+							Name:  "VSPHERE_RETRY_BACKOFF",
+							Value: retryBackoff,
+						}, {
+							Name:  "VSPHERE_RETRY_BACKOFF_POLICY",
+							Value: retryBackoffPolicy,
+						}, {
+							Name:  "VSPHERE_RATE_LIMIT",
+							Value: rateLimit,
+						}, {
+							Name:  "VSPHERE_RATE_LIMIT_BURST",
+							Value: rateLimitBurst,
+						}, {
+							Name:  "VSPHERE_RATE_LIMIT_OVERFLOW_POLICY",
+							Value: rateLimitOverflowPolicy,
+						}, {
+							Name:  "VSPHERE_CE_SOURCE",
+							Value: vms.Spec.CloudEventSource,
+						}, {
+							Name:  "VSPHERE_TYPE_SCHEME",
+							Value: string(vms.Spec.TypeScheme),
+						}, {
+							Name:  "VSPHERE_SUBJECT_TEMPLATE",
+							Value: vms.Spec.SubjectTemplate,
+						}, {
+							Name:  "VSPHERE_ENTITY_EXTENSIONS",
+							Value: strconv.FormatBool(!vms.Spec.DisableEntityExtensions),
 						}, {
 							Name:  "K_CE_OVERRIDES",
 							Value: ceOverrides,
 						}, {
 							Name:  "K_SINK",
 							Value: vms.Status.SinkURI.String(),
-						}},
+						}, {
+							Name:  "VSPHERE_SINKS",
+							Value: additionalSinks(vms),
+						}, {
+							Name:  "VSPHERE_DEAD_LETTER_SINK",
+							Value: vms.Status.DeadLetterSinkURI.String(),
+						}, {
+							Name:  "K_CA_CERTS",
+							Value: sinkCACerts,
+						}, {
+							Name:  "VSPHERE_OIDC_TOKEN_PATH",
+							Value: oidcTokenPath,
+						}, {
+							Name:  "VSPHERE_CONTENT_ENCODING",
+							Value: vms.Spec.ContentEncoding,
+						}, {
+							Name:  "VSPHERE_FORCE_CONTENT_ENCODING",
+							Value: strconv.FormatBool(vms.Spec.ForceContentEncoding),
+						}, {
+							Name:  "VC_CACERTS",
+							Value: caCerts,
+						}, {
+							Name:  "VC_HTTP_PROXY",
+							Value: httpProxy,
+						}, {
+							Name:  "VC_HTTPS_PROXY",
+							Value: httpsProxy,
+						}, {
+							Name:  "VC_NO_PROXY",
+							Value: noProxy,
+						}, {
+							// identifies the vCenter sessions of the adapter
+							// when the source is deleted
+							Name:  "VC_USER_AGENT",
+							Value: vsphere.SessionUserAgent(vms.Namespace, vms.Name),
+						}, {
+							Name:  "VSPHERE_FAILOVER_ADDRESSES",
+							Value: strings.Join(args.FailoverAddresses, ","),
+						}, {
+							Name:  "VSPHERE_FAILOVER_THRESHOLD",
+							Value: strconv.Itoa(failoverThreshold),
+						}, {
+							Name:  "VSPHERE_LEADER_ELECTION_LEASE",
+							Value: leaderElectionLease,
+						}, {
+							Name:  "VSPHERE_HEALTH_PORT",
+							Value: strconv.Itoa(args.HealthPort),
+						}}), overrides.Env...),
 					}},
 				},
 			},
@@ -124,3 +576,83 @@ func MakeDeployment(ctx context.Context, vms *v1alpha1.VSphereSource, args Adapt
 		},
 	}, nil
 }
+
+// withoutEmptyEnv returns the given environment variables without those with
+// an empty value, which the adapter would not replace with its defaults.
+func withoutEmptyEnv(env []corev1.EnvVar) []corev1.EnvVar {
+	filtered := env[:0]
+	for _, e := range env {
+		if e.Value != "" || e.ValueFrom != nil {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// additionalSinks returns the comma-separated URIs of the additional sinks of
+// the given source.
+func additionalSinks(vms *v1alpha1.VSphereSource) string {
+	if len(vms.Status.SinkURIs) < 2 {
+		return ""
+	}
+
+	uris := make([]string, 0, len(vms.Status.SinkURIs)-1)
+	for _, uri := range vms.Status.SinkURIs[1:] {
+		uris = append(uris, uri.String())
+	}
+	return strings.Join(uris, ",")
+}
+
+// adapterLoggingConfig returns the given JSON logging config with the level of
+// the adapter logger overridden by the given level, if any.
+func adapterLoggingConfig(loggingConfig, level string) (string, error) {
+	if level == "" {
+		return loggingConfig, nil
+	}
+
+	data := make(map[string]string)
+	if loggingConfig != "" {
+		if err := json.Unmarshal([]byte(loggingConfig), &data); err != nil {
+			return "", fmt.Errorf("unmarshal logging config: %w", err)
+		}
+	}
+	data["loglevel."+vsphere.AdapterComponent] = level
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("marshal logging config: %w", err)
+	}
+	return string(b), nil
+}
+
+// adapterSecurityContext returns the security context of the adapter
+// container, which is hardened unless overridden. The adapter does not write
+// to its filesystem, its checkpoint is stored via the Kubernetes API, so the
+// root filesystem is read-only.
+func adapterSecurityContext(overrides *v1alpha1.AdapterOverrides) *corev1.SecurityContext {
+	if overrides.SecurityContext != nil {
+		return overrides.SecurityContext
+	}
+
+	return &corev1.SecurityContext{
+		RunAsNonRoot:             ptr.Bool(true),
+		ReadOnlyRootFilesystem:   ptr.Bool(true),
+		AllowPrivilegeEscalation: ptr.Bool(false),
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+}
+
+// adapterMetricsPort returns the given port of the Prometheus metrics endpoint
+// of the adapter or the default port of the knative metrics exporter if not
+// set.
+func adapterMetricsPort(port int) int {
+	if port > 0 {
+		return port
+	}
+	return metricsPort
+}
@@ -0,0 +1,1296 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package resources
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kelseyhightower/envconfig"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/ptr"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
+	"github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspheresource/resources/names"
+	"github.com/vmware-tanzu/sources-for-knative/pkg/vsphere"
+)
+
+func newTestSource() *v1alpha1.VSphereSource {
+	return &v1alpha1.VSphereSource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "source",
+			Namespace: "ns",
+			UID:       "1234",
+		},
+	}
+}
+
+func TestMakeDeploymentResources(t *testing.T) {
+	defaults := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("100m"),
+		},
+	}
+
+	overrides := corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("500m"),
+			corev1.ResourceMemory: resource.MustParse("256Mi"),
+		},
+	}
+
+	tests := []struct {
+		name      string
+		overrides *v1alpha1.AdapterOverrides
+		defaults  corev1.ResourceRequirements
+		want      corev1.ResourceRequirements
+	}{
+		{
+			name: "no resources",
+			want: corev1.ResourceRequirements{},
+		},
+		{
+			name:     "controller defaults",
+			defaults: defaults,
+			want:     defaults,
+		},
+		{
+			name:      "source overrides",
+			overrides: &v1alpha1.AdapterOverrides{Resources: overrides},
+			defaults:  defaults,
+			want:      overrides,
+		},
+		{
+			name:      "empty source overrides",
+			overrides: &v1alpha1.AdapterOverrides{},
+			defaults:  defaults,
+			want:      defaults,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Spec.AdapterOverrides = tt.overrides
+
+			d, err := MakeDeployment(context.Background(), vms, AdapterArgs{Resources: tt.defaults})
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			got := d.Spec.Template.Spec.Containers[0].Resources
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("MakeDeployment() unexpected resources (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentScheduling(t *testing.T) {
+	nodeSelector := map[string]string{"pool": "vsphere"}
+	tolerations := []corev1.Toleration{{
+		Key:      "dedicated",
+		Operator: corev1.TolerationOpEqual,
+		Value:    "vsphere",
+		Effect:   corev1.TaintEffectNoSchedule,
+	}}
+	affinity := &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+					MatchExpressions: []corev1.NodeSelectorRequirement{{
+						Key:      "topology.kubernetes.io/zone",
+						Operator: corev1.NodeSelectorOpIn,
+						Values:   []string{"zone-a"},
+					}},
+				}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name             string
+		overrides        *v1alpha1.AdapterOverrides
+		wantNodeSelector map[string]string
+		wantTolerations  []corev1.Toleration
+		wantAffinity     *corev1.Affinity
+	}{
+		{
+			name: "no overrides",
+		},
+		{
+			name:      "empty overrides",
+			overrides: &v1alpha1.AdapterOverrides{},
+		},
+		{
+			name: "scheduling overrides",
+			overrides: &v1alpha1.AdapterOverrides{
+				NodeSelector: nodeSelector,
+				Tolerations:  tolerations,
+				Affinity:     affinity,
+			},
+			wantNodeSelector: nodeSelector,
+			wantTolerations:  tolerations,
+			wantAffinity:     affinity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Spec.AdapterOverrides = tt.overrides
+
+			d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			podSpec := d.Spec.Template.Spec
+			if diff := cmp.Diff(tt.wantNodeSelector, podSpec.NodeSelector); diff != "" {
+				t.Errorf("MakeDeployment() unexpected nodeSelector (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantTolerations, podSpec.Tolerations); diff != "" {
+				t.Errorf("MakeDeployment() unexpected tolerations (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantAffinity, podSpec.Affinity); diff != "" {
+				t.Errorf("MakeDeployment() unexpected affinity (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentEnv(t *testing.T) {
+	vms := newTestSource()
+	vms.Spec.AdapterOverrides = &v1alpha1.AdapterOverrides{
+		Env: []corev1.EnvVar{
+			{Name: "HTTPS_PROXY", Value: "http://proxy:3128"},
+			{Name: "FEATURE_FLAG", Value: "true"},
+		},
+	}
+
+	d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+
+	env := d.Spec.Template.Spec.Containers[0].Env
+	if len(env) < 2 {
+		t.Fatalf("MakeDeployment() unexpected number of env vars: %d", len(env))
+	}
+
+	// user-supplied env vars are appended after the controller-generated ones
+	got := env[len(env)-2:]
+	if diff := cmp.Diff(vms.Spec.AdapterOverrides.Env, got); diff != "" {
+		t.Errorf("MakeDeployment() unexpected env (-want, +got) = %v", diff)
+	}
+
+	if env[0].Name != "NAMESPACE" {
+		t.Errorf("MakeDeployment() first env var = %q, want %q", env[0].Name, "NAMESPACE")
+	}
+}
+
+func TestMakeDeploymentEnvConfig(t *testing.T) {
+	d, err := MakeDeployment(context.Background(), newTestSource(), AdapterArgs{})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+
+	for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+		if env.ValueFrom != nil {
+			t.Setenv(env.Name, "from-"+strings.ToLower(env.Name))
+			continue
+		}
+		t.Setenv(env.Name, env.Value)
+	}
+
+	// unset fields of the source must not override the adapter defaults
+	if err := envconfig.Process("", vsphere.NewEnvConfig()); err != nil {
+		t.Errorf("adapter envconfig.Process() = %v", err)
+	}
+}
+
+func TestMakeDeploymentNoEmptyEnv(t *testing.T) {
+	// a source without any optional field set
+	d, err := MakeDeployment(context.Background(), newTestSource(), AdapterArgs{})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+
+	for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+		if env.Value == "" && env.ValueFrom == nil {
+			t.Errorf("MakeDeployment() env %s has an empty value", env.Name)
+		}
+	}
+}
+
+func TestMakeDeploymentRetry(t *testing.T) {
+	tests := []struct {
+		name  string
+		retry *v1alpha1.RetrySpec
+		want  map[string]string
+	}{{
+		name: "no retry",
+		want: map[string]string{},
+	}, {
+		name: "linear retry",
+		retry: &v1alpha1.RetrySpec{
+			MaxRetries:            3,
+			InitialBackoffSeconds: 2,
+			BackoffPolicy:         v1alpha1.BackoffPolicyLinear,
+		},
+		want: map[string]string{
+			"VSPHERE_RETRY_MAX":            "3",
+			"VSPHERE_RETRY_BACKOFF":        "2s",
+			"VSPHERE_RETRY_BACKOFF_POLICY": "linear",
+		},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Spec.Retry = tt.retry
+
+			d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			got := make(map[string]string, len(tt.want))
+			for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+				if strings.HasPrefix(env.Name, "VSPHERE_RETRY_") {
+					got[env.Name] = env.Value
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("MakeDeployment() unexpected retry env (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentSinkTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		sinkTimeout int64
+		want        map[string]string
+	}{
+		{name: "not set", want: map[string]string{}},
+		{name: "set", sinkTimeout: 10, want: map[string]string{"VSPHERE_SINK_TIMEOUT": "10s"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Spec.SinkTimeoutSeconds = tt.sinkTimeout
+
+			d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			got := make(map[string]string)
+			for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+				if env.Name == "VSPHERE_SINK_TIMEOUT" {
+					got[env.Name] = env.Value
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("MakeDeployment() sink timeout env (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentCheckpointMode(t *testing.T) {
+	vms := newTestSource()
+	vms.Spec.CheckpointConfig = v1alpha1.VCheckpointSpec{
+		MaxAgeSeconds: 300,
+		PeriodSeconds: 10,
+		Mode:          v1alpha1.CheckpointModeExactlyOnceBestEffort,
+	}
+
+	d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+
+	var got string
+	for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+		if env.Name == "VSPHERE_CHECKPOINT_CONFIG" {
+			got = env.Value
+		}
+	}
+	want := `{"maxAge":"5m0s","period":"10s","mode":"exactly-once-best-effort"}`
+	if got != want {
+		t.Errorf("MakeDeployment() VSPHERE_CHECKPOINT_CONFIG = %s, want %s", got, want)
+	}
+}
+
+func TestMakeDeploymentCheckpointBackend(t *testing.T) {
+	vms := newTestSource()
+	vms.Spec.CheckpointConfig = v1alpha1.VCheckpointSpec{
+		MaxAgeSeconds: 300,
+		PeriodSeconds: 10,
+		Backend:       v1alpha1.CheckpointBackendLease,
+	}
+
+	d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+
+	got := map[string]string{}
+	for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+		switch env.Name {
+		case "VSPHERE_CHECKPOINT_CONFIG", "VSPHERE_KVSTORE_CONFIGMAP", "VSPHERE_KVSTORE_LEASE":
+			got[env.Name] = env.Value
+		}
+	}
+	want := map[string]string{
+		"VSPHERE_CHECKPOINT_CONFIG": `{"maxAge":"5m0s","period":"10s","backend":"lease"}`,
+		"VSPHERE_KVSTORE_CONFIGMAP": names.ConfigMap(vms),
+		"VSPHERE_KVSTORE_LEASE":     names.CheckpointLease(vms),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("MakeDeployment() unexpected checkpoint env (-want, +got) = %v", diff)
+	}
+}
+
+func TestMakeDeploymentRateLimit(t *testing.T) {
+	vms := newTestSource()
+	vms.Spec.RateLimit = &v1alpha1.RateLimitSpec{
+		EventsPerSecond: 100,
+		Burst:           500,
+		OverflowPolicy:  v1alpha1.RateLimitOverflowDrop,
+	}
+
+	d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+
+	want := map[string]string{
+		"VSPHERE_RATE_LIMIT":                 "100",
+		"VSPHERE_RATE_LIMIT_BURST":           "500",
+		"VSPHERE_RATE_LIMIT_OVERFLOW_POLICY": "drop",
+	}
+	got := make(map[string]string, len(want))
+	for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+		if _, ok := want[env.Name]; ok {
+			got[env.Name] = env.Value
+		}
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("MakeDeployment() unexpected rate limit env (-want, +got) = %v", diff)
+	}
+}
+
+func TestMakeDeploymentEntityExtensions(t *testing.T) {
+	tests := []struct {
+		name    string
+		disable bool
+		want    string
+	}{
+		{name: "enabled by default", disable: false, want: "true"},
+		{name: "disabled", disable: true, want: "false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Spec.DisableEntityExtensions = tt.disable
+
+			d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			var got string
+			for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+				if env.Name == "VSPHERE_ENTITY_EXTENSIONS" {
+					got = env.Value
+				}
+			}
+			if got != tt.want {
+				t.Errorf("MakeDeployment() VSPHERE_ENTITY_EXTENSIONS = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentLogLevel(t *testing.T) {
+	const zapConfig = `{"level": "info"}`
+
+	tests := []struct {
+		name          string
+		loggingConfig string
+		logLevel      string
+		want          map[string]zapcore.Level
+	}{
+		{name: "cluster logging config", loggingConfig: `{"zap-logger-config":"{\"level\": \"info\"}"}`, want: map[string]zapcore.Level{}},
+		{name: "override", loggingConfig: `{"zap-logger-config":"{\"level\": \"info\"}"}`, logLevel: "debug",
+			want: map[string]zapcore.Level{vsphere.AdapterComponent: zapcore.DebugLevel}},
+		{name: "override without logging config", logLevel: "warn",
+			want: map[string]zapcore.Level{vsphere.AdapterComponent: zapcore.WarnLevel}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := AdapterArgs{LoggingConfig: tt.loggingConfig, LogLevel: tt.logLevel}
+			d, err := MakeDeployment(context.Background(), newTestSource(), args)
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			var got string
+			for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+				if env.Name == "K_LOGGING_CONFIG" {
+					got = env.Value
+				}
+			}
+
+			if got == "" {
+				t.Fatal("MakeDeployment() K_LOGGING_CONFIG is empty")
+			}
+			cfg, err := logging.JSONToConfig(got)
+			if err != nil {
+				t.Fatalf("JSONToConfig() error = %v", err)
+			}
+			if tt.loggingConfig != "" && cfg.LoggingConfig != zapConfig {
+				t.Errorf("MakeDeployment() zap logger config = %q, want %q", cfg.LoggingConfig, zapConfig)
+			}
+			if diff := cmp.Diff(tt.want, cfg.LoggingLevel); diff != "" {
+				t.Errorf("MakeDeployment() log levels (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentShutdown(t *testing.T) {
+	tests := []struct {
+		name        string
+		overrides   *v1alpha1.AdapterOverrides
+		wantGrace   int64
+		wantTimeout time.Duration
+	}{
+		{
+			name:        "default grace period",
+			wantGrace:   30,
+			wantTimeout: vsphere.DefaultShutdownTimeout,
+		},
+		{
+			name:        "custom grace period",
+			overrides:   &v1alpha1.AdapterOverrides{TerminationGracePeriodSeconds: ptr.Int64(120)},
+			wantGrace:   120,
+			wantTimeout: 110 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Spec.AdapterOverrides = tt.overrides
+
+			d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			var timeout time.Duration
+			for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+				if env.Name == "VSPHERE_SHUTDOWN_TIMEOUT" {
+					if timeout, err = time.ParseDuration(env.Value); err != nil {
+						t.Fatalf("parse VSPHERE_SHUTDOWN_TIMEOUT: %v", err)
+					}
+				}
+			}
+			if timeout != tt.wantTimeout {
+				t.Errorf("MakeDeployment() VSPHERE_SHUTDOWN_TIMEOUT = %v, want %v", timeout, tt.wantTimeout)
+			}
+
+			grace := d.Spec.Template.Spec.TerminationGracePeriodSeconds
+			if grace == nil || *grace != tt.wantGrace {
+				t.Errorf("MakeDeployment() terminationGracePeriodSeconds = %v, want %d", grace, tt.wantGrace)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentSecurityContext(t *testing.T) {
+	override := &corev1.SecurityContext{
+		RunAsUser:    ptr.Int64(1000),
+		RunAsNonRoot: ptr.Bool(true),
+	}
+
+	tests := []struct {
+		name      string
+		overrides *v1alpha1.AdapterOverrides
+		want      *corev1.SecurityContext
+	}{
+		{
+			name: "hardened by default",
+			want: &corev1.SecurityContext{
+				RunAsNonRoot:             ptr.Bool(true),
+				ReadOnlyRootFilesystem:   ptr.Bool(true),
+				AllowPrivilegeEscalation: ptr.Bool(false),
+				Capabilities: &corev1.Capabilities{
+					Drop: []corev1.Capability{"ALL"},
+				},
+				SeccompProfile: &corev1.SeccompProfile{
+					Type: corev1.SeccompProfileTypeRuntimeDefault,
+				},
+			},
+		},
+		{
+			name:      "overrides without security context",
+			overrides: &v1alpha1.AdapterOverrides{NodeSelector: map[string]string{"zone": "a"}},
+			want: &corev1.SecurityContext{
+				RunAsNonRoot:             ptr.Bool(true),
+				ReadOnlyRootFilesystem:   ptr.Bool(true),
+				AllowPrivilegeEscalation: ptr.Bool(false),
+				Capabilities: &corev1.Capabilities{
+					Drop: []corev1.Capability{"ALL"},
+				},
+				SeccompProfile: &corev1.SeccompProfile{
+					Type: corev1.SeccompProfileTypeRuntimeDefault,
+				},
+			},
+		},
+		{
+			name:      "overridden",
+			overrides: &v1alpha1.AdapterOverrides{SecurityContext: override},
+			want:      override,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Spec.AdapterOverrides = tt.overrides
+
+			d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			got := d.Spec.Template.Spec.Containers[0].SecurityContext
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("MakeDeployment() security context (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestMakeImagePullSecrets(t *testing.T) {
+	secrets := []corev1.LocalObjectReference{{Name: "registry"}, {Name: "mirror"}}
+
+	tests := []struct {
+		name           string
+		overrides      *v1alpha1.AdapterOverrides
+		want           []corev1.LocalObjectReference
+		wantAnnotation string
+	}{
+		{
+			name: "no overrides",
+		},
+		{
+			name:           "image pull secrets",
+			overrides:      &v1alpha1.AdapterOverrides{ImagePullSecrets: secrets},
+			want:           secrets,
+			wantAnnotation: "registry,mirror",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Spec.AdapterOverrides = tt.overrides
+
+			d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, d.Spec.Template.Spec.ImagePullSecrets); diff != "" {
+				t.Errorf("MakeDeployment() image pull secrets (-want, +got) = %v", diff)
+			}
+
+			sa := MakeServiceAccount(context.Background(), vms)
+			if diff := cmp.Diff(tt.want, sa.ImagePullSecrets); diff != "" {
+				t.Errorf("MakeServiceAccount() image pull secrets (-want, +got) = %v", diff)
+			}
+			if got := sa.Annotations[PullSecretsAnnotationKey]; got != tt.wantAnnotation {
+				t.Errorf("MakeServiceAccount() %s annotation = %q, want %q", PullSecretsAnnotationKey, got, tt.wantAnnotation)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentLabelsAnnotations(t *testing.T) {
+	tests := []struct {
+		name            string
+		overrides       *v1alpha1.AdapterOverrides
+		args            AdapterArgs
+		wantLabels      map[string]string
+		wantAnnotations map[string]string
+	}{
+		{
+			name:       "no overrides",
+			wantLabels: map[string]string{NameLabelKey: "source"},
+		},
+		{
+			name: "overrides",
+			overrides: &v1alpha1.AdapterOverrides{
+				Labels:      map[string]string{"app": "vsphere", "team": "infra"},
+				Annotations: map[string]string{"sidecar.istio.io/inject": "false"},
+			},
+			wantLabels: map[string]string{
+				NameLabelKey: "source",
+				"app":        "vsphere",
+				"team":       "infra",
+			},
+			wantAnnotations: map[string]string{"sidecar.istio.io/inject": "false"},
+		},
+		{
+			name: "protected keys are not clobbered",
+			overrides: &v1alpha1.AdapterOverrides{
+				Labels: map[string]string{
+					NameLabelKey: "other",
+					"app":        "vsphere",
+				},
+				Annotations: map[string]string{
+					CABundleHashAnnotationKey:        "other",
+					CredentialsChecksumAnnotationKey: "other",
+					"team":                           "infra",
+				},
+			},
+			args: AdapterArgs{CABundleHash: "cahash", CredentialsHash: "credshash"},
+			wantLabels: map[string]string{
+				NameLabelKey: "source",
+				"app":        "vsphere",
+			},
+			wantAnnotations: map[string]string{
+				CABundleHashAnnotationKey:        "cahash",
+				CredentialsChecksumAnnotationKey: "credshash",
+				"team":                           "infra",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Spec.AdapterOverrides = tt.overrides
+
+			d, err := MakeDeployment(context.Background(), vms, tt.args)
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			if diff := cmp.Diff(tt.wantLabels, d.Spec.Template.Labels); diff != "" {
+				t.Errorf("MakeDeployment() pod labels (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantAnnotations, d.Spec.Template.Annotations); diff != "" {
+				t.Errorf("MakeDeployment() pod annotations (-want, +got) = %v", diff)
+			}
+
+			// the selector and the labels of the deployment only hold the
+			// labels of the controller
+			want := Labels(vms)
+			if diff := cmp.Diff(want, d.Spec.Selector.MatchLabels); diff != "" {
+				t.Errorf("MakeDeployment() selector (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(want, d.Labels); diff != "" {
+				t.Errorf("MakeDeployment() labels (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentMetrics(t *testing.T) {
+	tests := []struct {
+		name            string
+		args            AdapterArgs
+		wantPort        int32
+		wantAnnotations map[string]string
+	}{
+		{
+			name:     "default port without scrape annotations",
+			wantPort: 9090,
+		},
+		{
+			name:     "default port with scrape annotations",
+			args:     AdapterArgs{PrometheusScrape: true},
+			wantPort: 9090,
+			wantAnnotations: map[string]string{
+				PrometheusScrapeAnnotationKey: "true",
+				PrometheusPortAnnotationKey:   "9090",
+				PrometheusPathAnnotationKey:   "/metrics",
+			},
+		},
+		{
+			name:     "custom port with scrape annotations",
+			args:     AdapterArgs{MetricsPort: 9091, PrometheusScrape: true},
+			wantPort: 9091,
+			wantAnnotations: map[string]string{
+				PrometheusScrapeAnnotationKey: "true",
+				PrometheusPortAnnotationKey:   "9091",
+				PrometheusPathAnnotationKey:   "/metrics",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+
+			d, err := MakeDeployment(context.Background(), vms, tt.args)
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			var got int32
+			for _, p := range d.Spec.Template.Spec.Containers[0].Ports {
+				if p.Name == metricsPortName {
+					got = p.ContainerPort
+				}
+			}
+			if got != tt.wantPort {
+				t.Errorf("MakeDeployment() metrics port = %d, want %d", got, tt.wantPort)
+			}
+
+			if diff := cmp.Diff(tt.wantAnnotations, d.Spec.Template.Annotations); diff != "" {
+				t.Errorf("MakeDeployment() pod annotations (-want, +got) = %v", diff)
+			}
+
+			svc := MakeMetricsService(context.Background(), vms, tt.args.MetricsPort)
+			if got := svc.Spec.Ports[0].Port; got != tt.wantPort {
+				t.Errorf("MakeMetricsService() port = %d, want %d", got, tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentReplicas(t *testing.T) {
+	tests := []struct {
+		name         string
+		replicas     *int32
+		paused       bool
+		wantReplicas int32
+		wantLease    string
+	}{
+		{name: "default", replicas: nil, wantReplicas: 1, wantLease: ""},
+		{name: "single replica", replicas: ptr.Int32(1), wantReplicas: 1, wantLease: ""},
+		{name: "leader election", replicas: ptr.Int32(2), wantReplicas: 2, wantLease: "source-leader"},
+		{name: "paused", paused: true, wantReplicas: 0, wantLease: ""},
+		{name: "paused leader election", replicas: ptr.Int32(2), paused: true, wantReplicas: 0, wantLease: "source-leader"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Spec.Replicas = tt.replicas
+			vms.Spec.Paused = tt.paused
+
+			d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			if got := *d.Spec.Replicas; got != tt.wantReplicas {
+				t.Errorf("MakeDeployment() replicas = %d, want %d", got, tt.wantReplicas)
+			}
+
+			var got string
+			for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+				if env.Name == "VSPHERE_LEADER_ELECTION_LEASE" {
+					got = env.Value
+				}
+			}
+			if got != tt.wantLease {
+				t.Errorf("MakeDeployment() VSPHERE_LEADER_ELECTION_LEASE = %q, want %q", got, tt.wantLease)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentSinks(t *testing.T) {
+	tests := []struct {
+		name string
+		uris []*apis.URL
+		want string
+	}{{
+		name: "sink only",
+		uris: []*apis.URL{apis.HTTP("sink.example.com")},
+		want: "",
+	}, {
+		name: "additional sinks",
+		uris: []*apis.URL{apis.HTTP("sink.example.com"), apis.HTTP("a.example.com"), apis.HTTP("b.example.com")},
+		want: "http://a.example.com,http://b.example.com",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Status.MarkSinks(tt.uris[0], tt.uris[1:]...)
+
+			d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			env := make(map[string]string)
+			for _, e := range d.Spec.Template.Spec.Containers[0].Env {
+				env[e.Name] = e.Value
+			}
+			if got := env["K_SINK"]; got != tt.uris[0].String() {
+				t.Errorf("MakeDeployment() K_SINK = %q, want %q", got, tt.uris[0].String())
+			}
+			if got := env["VSPHERE_SINKS"]; got != tt.want {
+				t.Errorf("MakeDeployment() VSPHERE_SINKS = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentCACerts(t *testing.T) {
+	vms := newTestSource()
+
+	d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+	if got := len(d.Spec.Template.Spec.Volumes); got != 0 {
+		t.Errorf("MakeDeployment() volumes = %d, want 0", got)
+	}
+
+	vms.Spec.CACerts = ptr.String("cert")
+	d, err = MakeDeployment(context.Background(), vms, AdapterArgs{})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+
+	podSpec := d.Spec.Template.Spec
+	if got := len(podSpec.Volumes); got != 1 {
+		t.Fatalf("MakeDeployment() volumes = %d, want 1", got)
+	}
+	if got, want := podSpec.Volumes[0].ConfigMap.Name, "source-cacerts"; got != want {
+		t.Errorf("MakeDeployment() volume configmap = %q, want %q", got, want)
+	}
+	if got := len(podSpec.Containers[0].VolumeMounts); got != 1 {
+		t.Fatalf("MakeDeployment() volume mounts = %d, want 1", got)
+	}
+
+	var caCerts string
+	for _, env := range podSpec.Containers[0].Env {
+		if env.Name == "VC_CACERTS" {
+			caCerts = env.Value
+		}
+	}
+	if want := vsphere.CACertsMountPath + "/" + vsphere.CACertsKey; caCerts != want {
+		t.Errorf("MakeDeployment() VC_CACERTS = %q, want %q", caCerts, want)
+	}
+}
+
+func TestMakeDeploymentSinkAudience(t *testing.T) {
+	vms := newTestSource()
+	d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+	if got := len(d.Spec.Template.Spec.Volumes); got != 0 {
+		t.Errorf("MakeDeployment() volumes = %d, want 0 without sink audience", got)
+	}
+
+	vms.Spec.SinkAudience = ptr.String("broker")
+	d, err = MakeDeployment(context.Background(), vms, AdapterArgs{})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+
+	podSpec := d.Spec.Template.Spec
+	if got := len(podSpec.Volumes); got != 1 {
+		t.Fatalf("MakeDeployment() volumes = %d, want 1", got)
+	}
+	projected := podSpec.Volumes[0].Projected
+	if projected == nil || len(projected.Sources) != 1 || projected.Sources[0].ServiceAccountToken == nil {
+		t.Fatalf("MakeDeployment() volume = %+v, want projected service account token", podSpec.Volumes[0])
+	}
+	if got := projected.Sources[0].ServiceAccountToken.Audience; got != "broker" {
+		t.Errorf("MakeDeployment() token audience = %q, want %q", got, "broker")
+	}
+	if got := len(podSpec.Containers[0].VolumeMounts); got != 1 {
+		t.Fatalf("MakeDeployment() volume mounts = %d, want 1", got)
+	}
+
+	var tokenPath string
+	for _, env := range podSpec.Containers[0].Env {
+		if env.Name == "VSPHERE_OIDC_TOKEN_PATH" {
+			tokenPath = env.Value
+		}
+	}
+	if want := vsphere.OIDCTokenMountPath + "/" + vsphere.OIDCTokenKey; tokenPath != want {
+		t.Errorf("MakeDeployment() VSPHERE_OIDC_TOKEN_PATH = %q, want %q", tokenPath, want)
+	}
+}
+
+func TestMakeDeploymentProxy(t *testing.T) {
+	vms := newTestSource()
+	vms.Spec.Proxy = &v1alpha1.ProxySpec{
+		HTTPSProxy: "http://proxy.corp.local:3128",
+		NoProxy:    ".svc",
+	}
+
+	d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+		switch env.Name {
+		case "VC_HTTP_PROXY", "VC_HTTPS_PROXY", "VC_NO_PROXY", "HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY":
+			got[env.Name] = env.Value
+		}
+	}
+
+	// the standard variables would apply to deliveries as well
+	want := map[string]string{
+		"VC_HTTPS_PROXY": "http://proxy.corp.local:3128",
+		"VC_NO_PROXY":    ".svc",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("MakeDeployment() proxy env (-want, +got) = %v", diff)
+	}
+}
+
+func TestMakeDeploymentContentEncoding(t *testing.T) {
+	vms := newTestSource()
+	vms.Spec.ContentEncoding = "gzip"
+	vms.Spec.ForceContentEncoding = true
+
+	d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+		switch env.Name {
+		case "VSPHERE_CONTENT_ENCODING", "VSPHERE_FORCE_CONTENT_ENCODING":
+			got[env.Name] = env.Value
+		}
+	}
+
+	want := map[string]string{
+		"VSPHERE_CONTENT_ENCODING":       "gzip",
+		"VSPHERE_FORCE_CONTENT_ENCODING": "true",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("MakeDeployment() content encoding env (-want, +got) = %v", diff)
+	}
+}
+
+func TestMakeDeploymentMaxEventAge(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxEventAge time.Duration
+		want        map[string]string
+	}{
+		{name: "not set", want: map[string]string{}},
+		{name: "set", maxEventAge: time.Hour, want: map[string]string{"VSPHERE_MAX_EVENT_AGE": "1h0m0s"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := MakeDeployment(context.Background(), newTestSource(), AdapterArgs{MaxEventAge: tt.maxEventAge})
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			got := make(map[string]string)
+			for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+				if env.Name == "VSPHERE_MAX_EVENT_AGE" {
+					got[env.Name] = env.Value
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("MakeDeployment() max event age env (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentOrdered(t *testing.T) {
+	tests := []struct {
+		name    string
+		ordered bool
+		want    map[string]string
+	}{
+		{name: "not set", want: map[string]string{}},
+		{name: "set", ordered: true, want: map[string]string{"VSPHERE_ORDERED": "true"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Spec.Ordered = tt.ordered
+
+			d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			got := make(map[string]string)
+			for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+				if env.Name == "VSPHERE_ORDERED" {
+					got[env.Name] = env.Value
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("MakeDeployment() ordered env (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentReconnectBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		seconds int64
+		want    map[string]string
+	}{
+		{name: "not set", want: map[string]string{}},
+		{name: "set", seconds: 30, want: map[string]string{"VSPHERE_RECONNECT_BACKOFF": "30s"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Spec.ReconnectBackoffSeconds = tt.seconds
+
+			d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			got := make(map[string]string)
+			for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+				if env.Name == "VSPHERE_RECONNECT_BACKOFF" {
+					got[env.Name] = env.Value
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("MakeDeployment() reconnect backoff env (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentFailover(t *testing.T) {
+	tests := []struct {
+		name      string
+		addresses []string
+		threshold int32
+		want      map[string]string
+	}{
+		{
+			name: "no failover",
+			want: map[string]string{
+				"VSPHERE_FAILOVER_THRESHOLD": "3",
+			},
+		},
+		{
+			name:      "failover addresses with threshold",
+			addresses: []string{"https://vcenter-b.corp.local", "https://vcenter-c.corp.local"},
+			threshold: 5,
+			want: map[string]string{
+				"VSPHERE_FAILOVER_ADDRESSES": "https://vcenter-b.corp.local,https://vcenter-c.corp.local",
+				"VSPHERE_FAILOVER_THRESHOLD": "5",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Spec.FailoverThreshold = tt.threshold
+
+			d, err := MakeDeployment(context.Background(), vms, AdapterArgs{FailoverAddresses: tt.addresses})
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			got := make(map[string]string)
+			for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+				if strings.HasPrefix(env.Name, "VSPHERE_FAILOVER_") {
+					got[env.Name] = env.Value
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("MakeDeployment() failover env (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentSinkCACerts(t *testing.T) {
+	tests := []struct {
+		name        string
+		sinkCACerts *string
+		want        string
+	}{
+		{name: "not set", sinkCACerts: nil, want: ""},
+		{name: "set", sinkCACerts: ptr.String("cert"), want: "cert"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Spec.SinkCACerts = tt.sinkCACerts
+
+			d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			var got string
+			for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+				if env.Name == "K_CA_CERTS" {
+					got = env.Value
+				}
+			}
+			if got != tt.want {
+				t.Errorf("MakeDeployment() K_CA_CERTS = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentProbes(t *testing.T) {
+	vms := newTestSource()
+
+	d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+	container := d.Spec.Template.Spec.Containers[0]
+	if container.LivenessProbe != nil || container.ReadinessProbe != nil {
+		t.Errorf("MakeDeployment() unexpected probes without health port")
+	}
+
+	d, err = MakeDeployment(context.Background(), vms, AdapterArgs{HealthPort: 9000})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+	container = d.Spec.Template.Spec.Containers[0]
+
+	wantLiveness := &corev1.HTTPGetAction{Path: vsphere.LivenessPath, Port: intstr.FromInt(9000)}
+	if diff := cmp.Diff(wantLiveness, container.LivenessProbe.HTTPGet); diff != "" {
+		t.Errorf("MakeDeployment() unexpected liveness probe (-want, +got) = %v", diff)
+	}
+	wantReadiness := &corev1.HTTPGetAction{Path: vsphere.ReadinessPath, Port: intstr.FromInt(9000)}
+	if diff := cmp.Diff(wantReadiness, container.ReadinessProbe.HTTPGet); diff != "" {
+		t.Errorf("MakeDeployment() unexpected readiness probe (-want, +got) = %v", diff)
+	}
+
+	// the health port also serves the debug endpoints
+	wantPorts := []corev1.ContainerPort{
+		{Name: "metrics", ContainerPort: 9090},
+		{Name: "health", ContainerPort: 9000},
+	}
+	if diff := cmp.Diff(wantPorts, container.Ports); diff != "" {
+		t.Errorf("MakeDeployment() unexpected ports (-want, +got) = %v", diff)
+	}
+}
+
+func TestMakeDeploymentTypeScheme(t *testing.T) {
+	tests := []struct {
+		name   string
+		scheme v1alpha1.TypeScheme
+		want   map[string]string
+	}{
+		{name: "not set", want: map[string]string{}},
+		{name: "veb", scheme: v1alpha1.TypeSchemeVEB, want: map[string]string{"VSPHERE_TYPE_SCHEME": "veb"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Spec.TypeScheme = tt.scheme
+
+			d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			got := make(map[string]string)
+			for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+				if env.Name == "VSPHERE_TYPE_SCHEME" {
+					got[env.Name] = env.Value
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("MakeDeployment() type scheme env (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentPayloadFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		format v1alpha1.PayloadFormat
+		want   map[string]string
+	}{
+		{name: "not set", want: map[string]string{}},
+		{name: "minimal", format: v1alpha1.PayloadFormatMinimal, want: map[string]string{"VSPHERE_PAYLOAD_FORMAT": "minimal"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Spec.PayloadFormat = tt.format
+
+			d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			got := make(map[string]string)
+			for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+				if env.Name == "VSPHERE_PAYLOAD_FORMAT" {
+					got[env.Name] = env.Value
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("MakeDeployment() payload format env (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestMakeDeploymentStartFrom(t *testing.T) {
+	tests := []struct {
+		name      string
+		startFrom v1alpha1.StartFrom
+		want      map[string]string
+	}{
+		{name: "not set", want: map[string]string{}},
+		{name: "earliest", startFrom: v1alpha1.StartFromEarliest, want: map[string]string{"VSPHERE_START_FROM": "earliest"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Spec.StartFrom = tt.startFrom
+
+			d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+			if err != nil {
+				t.Fatalf("MakeDeployment() error = %v", err)
+			}
+
+			got := make(map[string]string)
+			for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+				if env.Name == "VSPHERE_START_FROM" {
+					got[env.Name] = env.Value
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("MakeDeployment() start from env (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package resources
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/eventing/pkg/apis/eventing/v1beta1"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/kmeta"
+
+	sourcesv1alpha1 "github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
+	"github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspheresource/resources/names"
+	"github.com/vmware-tanzu/sources-for-knative/pkg/vsphere"
+)
+
+// MakeEventTypes creates an EventType for each of the event types configured
+// in the source. No EventTypes are created unless auto creation is enabled
+// and the sink is a Broker.
+func MakeEventTypes(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) []*v1beta1.EventType {
+	ref := vms.Spec.Sink.Ref
+	if !vms.Spec.EventTypeAutoCreate || ref == nil || ref.Kind != "Broker" {
+		return nil
+	}
+
+	source := vms.Spec.CloudEventSource
+	if source == "" {
+		source = vms.Spec.Address.Host
+	}
+	// the source is validated by the webhook, an unparsable source is omitted
+	sourceURL, _ := apis.ParseURL(source)
+
+	eventTypes := make([]*v1beta1.EventType, 0, len(vms.Spec.EventTypes))
+	for _, et := range vms.Spec.EventTypes {
+		labels, annotations := withSourceMetadata(vms, Labels(vms), nil)
+		eventTypes = append(eventTypes, &v1beta1.EventType{
+			ObjectMeta: metav1.ObjectMeta{
+				OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(vms)},
+				Name:            names.EventType(vms, et),
+				Namespace:       vms.Namespace,
+				Labels:          labels,
+				Annotations:     annotations,
+			},
+			Spec: v1beta1.EventTypeSpec{
+				Type:   vsphere.SchemeEventType(string(vms.Spec.TypeScheme), et),
+				Source: sourceURL,
+				Broker: ref.Name,
+			},
+		})
+	}
+	return eventTypes
+}
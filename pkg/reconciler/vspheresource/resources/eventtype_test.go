@@ -0,0 +1,73 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package resources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"knative.dev/eventing/pkg/apis/eventing/v1beta1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+)
+
+func TestMakeEventTypes(t *testing.T) {
+	broker := &duckv1.KReference{
+		APIVersion: "eventing.knative.dev/v1",
+		Kind:       "Broker",
+		Name:       "default",
+	}
+	service := &duckv1.KReference{
+		APIVersion: "v1",
+		Kind:       "Service",
+		Name:       "display",
+	}
+
+	tests := []struct {
+		name       string
+		autoCreate bool
+		sink       *duckv1.KReference
+		want       []v1beta1.EventTypeSpec
+	}{{
+		name: "auto creation disabled",
+		sink: broker,
+	}, {
+		name:       "sink is not a broker",
+		autoCreate: true,
+		sink:       service,
+	}, {
+		name:       "sink is a broker",
+		autoCreate: true,
+		sink:       broker,
+		want: []v1beta1.EventTypeSpec{{
+			Type:   "com.vmware.vsphere.VmPoweredOnEvent.v0",
+			Source: &apis.URL{Path: "vcenter.example.com"},
+			Broker: "default",
+		}},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := newTestSource()
+			vms.Spec.Sink.Ref = tt.sink
+			vms.Spec.Address = apis.URL{Scheme: "https", Host: "vcenter.example.com"}
+			vms.Spec.EventTypeAutoCreate = tt.autoCreate
+			vms.Spec.EventTypes = []string{"VmPoweredOnEvent"}
+
+			var got []v1beta1.EventTypeSpec
+			for _, et := range MakeEventTypes(context.Background(), vms) {
+				if et.Name != "source-vmpoweredonevent" {
+					t.Errorf("MakeEventTypes() name = %q, want %q", et.Name, "source-vmpoweredonevent")
+				}
+				got = append(got, et.Spec)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("MakeEventTypes() (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
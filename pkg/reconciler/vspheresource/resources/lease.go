@@ -0,0 +1,33 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package resources
+
+import (
+	"context"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/kmeta"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
+	"github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspheresource/resources/names"
+)
+
+// MakeCheckpointLease creates a Lease owned by the VSphereSource in which the
+// adapter stores its checkpoint with the lease checkpoint backend. The
+// annotations written by the adapter are not part of the desired state.
+func MakeCheckpointLease(ctx context.Context, vms *v1alpha1.VSphereSource) *coordinationv1.Lease {
+	labels, annotations := withSourceMetadata(vms, Labels(vms), nil)
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            names.CheckpointLease(vms),
+			Namespace:       vms.Namespace,
+			Labels:          labels,
+			Annotations:     annotations,
+			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(vms)},
+		},
+	}
+}
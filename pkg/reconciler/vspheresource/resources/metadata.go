@@ -0,0 +1,116 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package resources
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
+)
+
+const (
+	// PropagatedLabelsAnnotationKey lists the keys of the labels of a child
+	// resource which were copied from its source
+	PropagatedLabelsAnnotationKey = "vspheresources.sources.tanzu.vmware.com/propagated-labels"
+
+	// PropagatedAnnotationsAnnotationKey lists the keys of the annotations of
+	// a child resource which were copied from its source
+	PropagatedAnnotationsAnnotationKey = "vspheresources.sources.tanzu.vmware.com/propagated-annotations"
+
+	// controllerKeyPrefix is the prefix of the labels and annotations set by
+	// the controller
+	controllerKeyPrefix = "vspheresources.sources.tanzu.vmware.com/"
+)
+
+// isPropagated returns true if the label or annotation with the given key is
+// copied from the source to its child resources. Keys of the kubernetes.io and
+// k8s.io domains and their subdomains, e.g.
+// kubectl.kubernetes.io/last-applied-configuration, are reserved for
+// Kubernetes components, except for the recommended app.kubernetes.io labels.
+// Keys of the controller are not copied either.
+func isPropagated(key string) bool {
+	if strings.HasPrefix(key, controllerKeyPrefix) {
+		return false
+	}
+
+	domain, _, ok := strings.Cut(key, "/")
+	if !ok || domain == "app.kubernetes.io" {
+		return true
+	}
+	for _, reserved := range []string{"kubernetes.io", "k8s.io"} {
+		if domain == reserved || strings.HasSuffix(domain, "."+reserved) {
+			return false
+		}
+	}
+	return true
+}
+
+// propagated returns the labels or annotations of the source copied to its
+// child resources.
+func propagated(m map[string]string) map[string]string {
+	var filtered map[string]string
+	for k, v := range m {
+		if !isPropagated(k) {
+			continue
+		}
+		if filtered == nil {
+			filtered = make(map[string]string, len(m))
+		}
+		filtered[k] = v
+	}
+	return filtered
+}
+
+// withSourceMetadata returns the given labels and annotations of a child
+// resource of the source with the labels and annotations of the source added,
+// the given ones taking precedence. The keys copied from the source are
+// recorded in annotations, so that they can be removed from the child when
+// they are removed from the source.
+func withSourceMetadata(vms *v1alpha1.VSphereSource, labels, annotations map[string]string) (map[string]string, map[string]string) {
+	mergedLabels, labelKeys := merge(propagated(vms.Labels), labels)
+	mergedAnnotations, annotationKeys := merge(propagated(vms.Annotations), annotations)
+
+	if len(labelKeys) > 0 {
+		mergedAnnotations = setKey(mergedAnnotations, PropagatedLabelsAnnotationKey, strings.Join(labelKeys, ","))
+	}
+	if len(annotationKeys) > 0 {
+		mergedAnnotations = setKey(mergedAnnotations, PropagatedAnnotationsAnnotationKey, strings.Join(annotationKeys, ","))
+	}
+	return mergedLabels, mergedAnnotations
+}
+
+// merge returns the union of the given maps, the values of own taking
+// precedence, and the sorted keys taken from source.
+func merge(source, own map[string]string) (map[string]string, []string) {
+	if len(source) == 0 && len(own) == 0 {
+		return nil, nil
+	}
+
+	merged := make(map[string]string, len(source)+len(own))
+	var keys []string
+	for k, v := range source {
+		if _, ok := own[k]; ok {
+			continue
+		}
+		merged[k] = v
+		keys = append(keys, k)
+	}
+	for k, v := range own {
+		merged[k] = v
+	}
+
+	sort.Strings(keys)
+	return merged, keys
+}
+
+func setKey(m map[string]string, key, value string) map[string]string {
+	if m == nil {
+		m = make(map[string]string, 1)
+	}
+	m[key] = value
+	return m
+}
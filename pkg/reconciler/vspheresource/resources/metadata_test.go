@@ -0,0 +1,127 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package resources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
+)
+
+func Test_isPropagated(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{key: "team", want: true},
+		{key: "example.com/owner", want: true},
+		{key: "app.kubernetes.io/part-of", want: true},
+		{key: "kubernetes.io/metadata.name", want: false},
+		{key: "kubectl.kubernetes.io/last-applied-configuration", want: false},
+		{key: "k8s.io/role", want: false},
+		{key: "autoscaling.k8s.io/policy", want: false},
+		{key: "notkubernetes.io/key", want: true},
+		{key: NameLabelKey, want: false},
+		{key: PropagatedLabelsAnnotationKey, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := isPropagated(tt.key); got != tt.want {
+				t.Errorf("isPropagated(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeSourceMetadata(t *testing.T) {
+	vms := newTestSource()
+	vms.Labels = map[string]string{
+		"team":                      "infra",
+		"app.kubernetes.io/part-of": "monitoring",
+		NameLabelKey:                "other",
+	}
+	vms.Annotations = map[string]string{
+		"owner": "jane",
+		"kubectl.kubernetes.io/last-applied-configuration": "{}",
+	}
+	ctx := context.Background()
+
+	wantLabels := map[string]string{
+		NameLabelKey:                "source",
+		"team":                      "infra",
+		"app.kubernetes.io/part-of": "monitoring",
+	}
+	wantAnnotations := map[string]string{
+		"owner":                            "jane",
+		PropagatedLabelsAnnotationKey:      "app.kubernetes.io/part-of,team",
+		PropagatedAnnotationsAnnotationKey: "owner",
+	}
+
+	cm := MakeConfigMap(ctx, vms)
+	if diff := cmp.Diff(wantLabels, cm.Labels); diff != "" {
+		t.Errorf("MakeConfigMap() labels (-want, +got) = %v", diff)
+	}
+	if diff := cmp.Diff(wantAnnotations, cm.Annotations); diff != "" {
+		t.Errorf("MakeConfigMap() annotations (-want, +got) = %v", diff)
+	}
+
+	d, err := MakeDeployment(ctx, vms, AdapterArgs{})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+	if diff := cmp.Diff(wantLabels, d.Labels); diff != "" {
+		t.Errorf("MakeDeployment() labels (-want, +got) = %v", diff)
+	}
+	if diff := cmp.Diff(wantLabels, d.Spec.Template.Labels); diff != "" {
+		t.Errorf("MakeDeployment() pod labels (-want, +got) = %v", diff)
+	}
+	if diff := cmp.Diff(wantAnnotations, d.Spec.Template.Annotations); diff != "" {
+		t.Errorf("MakeDeployment() pod annotations (-want, +got) = %v", diff)
+	}
+	// the selector is immutable
+	if diff := cmp.Diff(Labels(vms), d.Spec.Selector.MatchLabels); diff != "" {
+		t.Errorf("MakeDeployment() selector (-want, +got) = %v", diff)
+	}
+}
+
+func TestMakeSourceMetadataPrecedence(t *testing.T) {
+	vms := newTestSource()
+	vms.Labels = map[string]string{"team": "infra", "tier": "backend"}
+	vms.Annotations = map[string]string{"owner": "jane", "contact": "ops"}
+	vms.Spec.ServiceAccountAnnotations = map[string]string{"owner": "john"}
+	vms.Spec.AdapterOverrides = &v1alpha1.AdapterOverrides{
+		Labels: map[string]string{"team": "platform"},
+	}
+	ctx := context.Background()
+
+	sa := MakeServiceAccount(ctx, vms)
+	if diff := cmp.Diff(map[string]string{
+		"owner":                            "john",
+		"contact":                          "ops",
+		PropagatedLabelsAnnotationKey:      "team,tier",
+		PropagatedAnnotationsAnnotationKey: "contact",
+	}, sa.Annotations); diff != "" {
+		t.Errorf("MakeServiceAccount() annotations (-want, +got) = %v", diff)
+	}
+
+	d, err := MakeDeployment(ctx, vms, AdapterArgs{})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+	if diff := cmp.Diff(map[string]string{
+		NameLabelKey: "source",
+		"team":       "platform",
+		"tier":       "backend",
+	}, d.Spec.Template.Labels); diff != "" {
+		t.Errorf("MakeDeployment() pod labels (-want, +got) = %v", diff)
+	}
+	if got := d.Spec.Template.Annotations[PropagatedLabelsAnnotationKey]; got != "tier" {
+		t.Errorf("MakeDeployment() pod %s = %q, want %q", PropagatedLabelsAnnotationKey, got, "tier")
+	}
+}
@@ -6,6 +6,8 @@ SPDX-License-Identifier: Apache-2.0
 package names
 
 import (
+	"strings"
+
 	"knative.dev/pkg/kmeta"
 
 	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
@@ -23,10 +25,51 @@ func ConfigMap(vms *v1alpha1.VSphereSource) string {
 	return kmeta.ChildName(vms.Name, "-configmap")
 }
 
+func CACertsConfigMap(vms *v1alpha1.VSphereSource) string {
+	return kmeta.ChildName(vms.Name, "-cacerts")
+}
+
+func Role(vms *v1alpha1.VSphereSource) string {
+	return kmeta.ChildName(vms.Name, "-role")
+}
+
 func RoleBinding(vms *v1alpha1.VSphereSource) string {
 	return kmeta.ChildName(vms.Name, "-rolebinding")
 }
 
+// Lease returns the name of the Lease used by the adapter replicas to elect a
+// leader.
+func Lease(vms *v1alpha1.VSphereSource) string {
+	return kmeta.ChildName(vms.Name, "-leader")
+}
+
+// CheckpointLease returns the name of the Lease the adapter stores its
+// checkpoint in with the lease checkpoint backend.
+func CheckpointLease(vms *v1alpha1.VSphereSource) string {
+	return kmeta.ChildName(vms.Name, "-checkpoint")
+}
+
+func MetricsService(vms *v1alpha1.VSphereSource) string {
+	return kmeta.ChildName(vms.Name, "-metrics")
+}
+
+// ServiceMonitor returns the name of the Prometheus Operator ServiceMonitor
+// scraping the metrics Service.
+func ServiceMonitor(vms *v1alpha1.VSphereSource) string {
+	return kmeta.ChildName(vms.Name, "-metrics")
+}
+
+// EventType returns the name of the EventType registered for the given
+// vSphere event type.
+func EventType(vms *v1alpha1.VSphereSource, eventType string) string {
+	return kmeta.ChildName(vms.Name+"-"+strings.ToLower(eventType), "")
+}
+
+// ServiceAccount returns the name of the ServiceAccount used by the adapter,
+// which is either the user-provided or the generated one.
 func ServiceAccount(vms *v1alpha1.VSphereSource) string {
+	if vms.Spec.ServiceAccountName != "" {
+		return vms.Spec.ServiceAccountName
+	}
 	return kmeta.ChildName(vms.Name, "-serviceaccount")
 }
@@ -64,6 +64,42 @@ func TestNames(t *testing.T) {
 		},
 		f:    ConfigMap,
 		want: "baz-configmap",
+	}, {
+		name: "cacerts configmap",
+		vss: &v1alpha1.VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "baz",
+			},
+		},
+		f:    CACertsConfigMap,
+		want: "baz-cacerts",
+	}, {
+		name: "lease",
+		vss: &v1alpha1.VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "baz",
+			},
+		},
+		f:    Lease,
+		want: "baz-leader",
+	}, {
+		name: "checkpoint lease",
+		vss: &v1alpha1.VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "baz",
+			},
+		},
+		f:    CheckpointLease,
+		want: "baz-checkpoint",
+	}, {
+		name: "role",
+		vss: &v1alpha1.VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "baz",
+			},
+		},
+		f:    Role,
+		want: "baz-role",
 	}, {
 		name: "rolebinding",
 		vss: &v1alpha1.VSphereSource{
@@ -82,6 +118,18 @@ func TestNames(t *testing.T) {
 		},
 		f:    ServiceAccount,
 		want: "baz-serviceaccount",
+	}, {
+		name: "serviceaccount from spec",
+		vss: &v1alpha1.VSphereSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "baz",
+			},
+			Spec: v1alpha1.VSphereSourceSpec{
+				ServiceAccountName: "existing",
+			},
+		},
+		f:    ServiceAccount,
+		want: "existing",
 	}}
 
 	for _, test := range tests {
@@ -0,0 +1,59 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package resources
+
+import (
+	"context"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
+	"github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspheresource/resources/names"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/kmeta"
+)
+
+// MakeRole creates a Role object for the receive adapter in the Namespace of
+// the source. The Role only grants access to the ConfigMap and Lease used by
+// the receive adapter to store state for checkpointing and to the Lease used
+// by the adapter replicas to elect a leader. Adapters failing over to another
+// vCenter may also record events.
+func MakeRole(ctx context.Context, vms *v1alpha1.VSphereSource) *rbacv1.Role {
+	labels, annotations := withSourceMetadata(vms, nil, nil)
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(vms)},
+			Name:            names.Role(vms),
+			Namespace:       vms.Namespace,
+			Labels:          labels,
+			Annotations:     annotations,
+		},
+		Rules: []rbacv1.PolicyRule{{
+			APIGroups:     []string{""},
+			Resources:     []string{"configmaps"},
+			ResourceNames: []string{names.ConfigMap(vms)},
+			Verbs:         []string{"get", "update", "patch"},
+		}, {
+			APIGroups:     []string{"coordination.k8s.io"},
+			Resources:     []string{"leases"},
+			ResourceNames: []string{names.Lease(vms), names.CheckpointLease(vms)},
+			Verbs:         []string{"get", "update", "patch"},
+		}, {
+			// creation cannot be restricted by name
+			APIGroups: []string{"coordination.k8s.io"},
+			Resources: []string{"leases"},
+			Verbs:     []string{"create"},
+		}},
+	}
+
+	if len(vms.Spec.FailoverAddresses) > 0 {
+		role.Rules = append(role.Rules, rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"events"},
+			Verbs:     []string{"create"},
+		})
+	}
+	return role
+}
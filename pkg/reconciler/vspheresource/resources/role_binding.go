@@ -17,18 +17,21 @@ import (
 
 // MakeRoleBinding creates a RoleBinding object for the receive adapter
 // service account 'sa' in the Namespace 'ns'. This is necessary for
-// the receive adapter to be able to store state in configmaps.
+// the receive adapter to be able to store state in its configmap.
 func MakeRoleBinding(ctx context.Context, vms *v1alpha1.VSphereSource) *rbacv1.RoleBinding {
+	labels, annotations := withSourceMetadata(vms, nil, nil)
 	return &rbacv1.RoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
 			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(vms)},
 			Name:            names.RoleBinding(vms),
 			Namespace:       vms.Namespace,
+			Labels:          labels,
+			Annotations:     annotations,
 		},
 		RoleRef: rbacv1.RoleRef{
 			APIGroup: "rbac.authorization.k8s.io",
-			Kind:     "ClusterRole",
-			Name:     "vsphere-receive-adapter-cm",
+			Kind:     "Role",
+			Name:     names.Role(vms),
 		},
 		Subjects: []rbacv1.Subject{{
 			Kind:      "ServiceAccount",
@@ -0,0 +1,48 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package resources
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"knative.dev/pkg/kmeta"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
+	"github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspheresource/resources/names"
+)
+
+// metricsServicePortName is the name of the port of the metrics Service, which
+// the ServiceMonitor scrapes
+const metricsServicePortName = "http-" + metricsPortName
+
+// MakeMetricsService creates a Service exposing the Prometheus metrics
+// endpoint of the receive adapter on the given port, so that it can be
+// scraped. The default port of the knative metrics exporter is used if the
+// port is not set.
+func MakeMetricsService(ctx context.Context, vms *v1alpha1.VSphereSource, port int) *corev1.Service {
+	labels, annotations := withSourceMetadata(vms, Labels(vms), nil)
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(vms)},
+			Name:            names.MetricsService(vms),
+			Namespace:       vms.Namespace,
+			Labels:          labels,
+			Annotations:     annotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: Labels(vms),
+			Ports: []corev1.ServicePort{{
+				Name:       metricsServicePortName,
+				Protocol:   corev1.ProtocolTCP,
+				Port:       int32(adapterMetricsPort(port)),
+				TargetPort: intstr.FromString(metricsPortName),
+			}},
+		},
+	}
+}
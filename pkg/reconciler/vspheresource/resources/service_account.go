@@ -7,6 +7,7 @@ package resources
 
 import (
 	"context"
+	"strings"
 
 	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
 	"github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspheresource/resources/names"
@@ -15,13 +16,35 @@ import (
 	"knative.dev/pkg/kmeta"
 )
 
+// PullSecretsAnnotationKey lists the names of the image pull secrets of the
+// adapter ServiceAccount which were set from the source, so that they can be
+// removed from it when they are removed from the source.
+const PullSecretsAnnotationKey = "vspheresources.sources.tanzu.vmware.com/image-pull-secrets"
+
 // MakeServiceAccount creates a ServiceAccount object for the Namespace 'ns'.
 func MakeServiceAccount(ctx context.Context, vms *v1alpha1.VSphereSource) *corev1.ServiceAccount {
+	var pullSecrets []corev1.LocalObjectReference
+	if vms.Spec.AdapterOverrides != nil {
+		pullSecrets = vms.Spec.AdapterOverrides.ImagePullSecrets
+	}
+
+	// the annotations of the spec take precedence over the ones of the source
+	labels, annotations := withSourceMetadata(vms, nil, vms.Spec.ServiceAccountAnnotations)
+	if len(pullSecrets) > 0 {
+		names := make([]string, 0, len(pullSecrets))
+		for _, secret := range pullSecrets {
+			names = append(names, secret.Name)
+		}
+		annotations = setKey(annotations, PullSecretsAnnotationKey, strings.Join(names, ","))
+	}
 	return &corev1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
 			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(vms)},
 			Namespace:       vms.Namespace,
 			Name:            names.ServiceAccount(vms),
+			Labels:          labels,
+			Annotations:     annotations,
 		},
+		ImagePullSecrets: pullSecrets,
 	}
 }
@@ -0,0 +1,63 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package resources
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"knative.dev/pkg/kmeta"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
+	"github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspheresource/resources/names"
+)
+
+// ServiceMonitorGVR is the resource of the Prometheus Operator ServiceMonitors.
+// The Prometheus Operator is an optional dependency, so ServiceMonitors are
+// handled as unstructured objects.
+var ServiceMonitorGVR = schema.GroupVersionResource{
+	Group:    "monitoring.coreos.com",
+	Version:  "v1",
+	Resource: "servicemonitors",
+}
+
+// MakeServiceMonitor creates a Prometheus Operator ServiceMonitor scraping the
+// metrics Service of the receive adapter.
+func MakeServiceMonitor(ctx context.Context, vms *v1alpha1.VSphereSource) *unstructured.Unstructured {
+	labels, annotations := withSourceMetadata(vms, Labels(vms), nil)
+
+	sm := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": toInterfaceMap(Labels(vms)),
+			},
+			"endpoints": []interface{}{
+				map[string]interface{}{
+					"port": metricsServicePortName,
+					"path": "/metrics",
+				},
+			},
+		},
+	}}
+	sm.SetAPIVersion(ServiceMonitorGVR.GroupVersion().String())
+	sm.SetKind("ServiceMonitor")
+	sm.SetName(names.ServiceMonitor(vms))
+	sm.SetNamespace(vms.Namespace)
+	sm.SetLabels(labels)
+	sm.SetAnnotations(annotations)
+	sm.SetOwnerReferences([]metav1.OwnerReference{*kmeta.NewControllerRef(vms)})
+	return sm
+}
+
+func toInterfaceMap(m map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package resources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestMakeServiceMonitor(t *testing.T) {
+	vms := newTestSource()
+	ctx := context.Background()
+
+	svc := MakeMetricsService(ctx, vms, 0)
+	sm := MakeServiceMonitor(ctx, vms)
+
+	if got, want := sm.GroupVersionKind().String(), "monitoring.coreos.com/v1, Kind=ServiceMonitor"; got != want {
+		t.Errorf("MakeServiceMonitor() GVK = %q, want %q", got, want)
+	}
+	if got := sm.GetOwnerReferences(); len(got) != 1 || got[0].Name != vms.Name {
+		t.Errorf("MakeServiceMonitor() owner references = %v, want controlled by %q", got, vms.Name)
+	}
+
+	// the ServiceMonitor selects the metrics Service and scrapes its port
+	matchLabels, _, err := unstructured.NestedStringMap(sm.Object, "spec", "selector", "matchLabels")
+	if err != nil {
+		t.Fatalf("MakeServiceMonitor() selector: %v", err)
+	}
+	for k, v := range matchLabels {
+		if svc.Labels[k] != v {
+			t.Errorf("MakeServiceMonitor() selector %s=%s does not match service labels %v", k, v, svc.Labels)
+		}
+	}
+
+	endpoints, _, err := unstructured.NestedSlice(sm.Object, "spec", "endpoints")
+	if err != nil {
+		t.Fatalf("MakeServiceMonitor() endpoints: %v", err)
+	}
+	want := []interface{}{map[string]interface{}{"port": svc.Spec.Ports[0].Name, "path": "/metrics"}}
+	if diff := cmp.Diff(want, endpoints); diff != "" {
+		t.Errorf("MakeServiceMonitor() endpoints (-want, +got) = %v", diff)
+	}
+}
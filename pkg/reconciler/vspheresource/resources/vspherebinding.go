@@ -18,10 +18,13 @@ import (
 )
 
 func MakeVSphereBinding(ctx context.Context, vms *v1alpha1.VSphereSource) *v1alpha1.VSphereBinding {
+	labels, annotations := withSourceMetadata(vms, nil, nil)
 	return &v1alpha1.VSphereBinding{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            names.VSphereBinding(vms),
 			Namespace:       vms.Namespace,
+			Labels:          labels,
+			Annotations:     annotations,
 			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(vms)},
 		},
 		Spec: v1alpha1.VSphereBindingSpec{
@@ -0,0 +1,72 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package resources
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kelseyhightower/envconfig"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/vsphere"
+)
+
+func TestMakeVSphereBindingSecretKeys(t *testing.T) {
+	vms := newTestSource()
+	vms.Spec.Address = apis.URL{Scheme: "https", Host: "vcenter.corp.local"}
+	vms.Spec.SecretRef = corev1.LocalObjectReference{Name: "vsphere-credentials"}
+	vms.Spec.UsernameKey = "user"
+	vms.Spec.PasswordKey = "pass"
+	vms.Spec.CACertKey = "ca.pem"
+	vms.SetDefaults(context.Background())
+
+	d, err := MakeDeployment(context.Background(), vms, AdapterArgs{})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+
+	vsb := MakeVSphereBinding(context.Background(), vms)
+	vsb.SetDefaults(context.Background())
+	if err := vsb.Validate(context.Background()); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	ps := &duckv1.WithPod{Spec: duckv1.WithPodSpec{Template: duckv1.PodSpecable(d.Spec.Template)}}
+	vsb.Do(context.Background(), ps)
+
+	secretKeys := make(map[string]string)
+	for _, env := range ps.Spec.Template.Spec.Containers[0].Env {
+		if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+			ref := env.ValueFrom.SecretKeyRef
+			if ref.Name != "vsphere-credentials" {
+				t.Errorf("Do() %s secret = %q, want %q", env.Name, ref.Name, "vsphere-credentials")
+			}
+			secretKeys[env.Name] = ref.Key
+			continue
+		}
+		// the adapter reads its configuration from the bound environment
+		if strings.HasPrefix(env.Name, "VC_") {
+			t.Setenv(env.Name, env.Value)
+		}
+	}
+	if diff := cmp.Diff(map[string]string{"VC_USERNAME": "user", "VC_PASSWORD": "pass"}, secretKeys); diff != "" {
+		t.Errorf("Do() unexpected secret keys (-want, +got) = %v", diff)
+	}
+
+	var env vsphere.EnvConfig
+	if err := envconfig.Process("", &env); err != nil {
+		t.Fatalf("envconfig.Process() = %v", err)
+	}
+	got := []string{env.UsernameKey, env.PasswordKey, env.CACertKey}
+	if diff := cmp.Diff([]string{"user", "pass", "ca.pem"}, got); diff != "" {
+		t.Errorf("adapter secret keys (-want, +got) = %v", diff)
+	}
+}
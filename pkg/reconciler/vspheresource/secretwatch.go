@@ -0,0 +1,127 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vspheresource
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// secretWatches watches the credentials Secrets of the sources by name, so
+// that the controller neither caches nor handles the other Secrets of the
+// cluster. A Secret is watched as long as a source references it.
+type secretWatches struct {
+	// ctx stops all watches when done
+	ctx        context.Context
+	kubeclient kubernetes.Interface
+	handler    cache.ResourceEventHandler
+	resync     time.Duration
+
+	mu sync.Mutex
+	// secrets maps each source to the Secret it watches
+	secrets map[types.NamespacedName]types.NamespacedName
+	watches map[types.NamespacedName]*secretWatch
+}
+
+type secretWatch struct {
+	cancel  context.CancelFunc
+	sources sets.String
+}
+
+// newSecretWatches returns secretWatches passing the events of the watched
+// Secrets to the given handler until the given context is done.
+func newSecretWatches(ctx context.Context, kc kubernetes.Interface, handler cache.ResourceEventHandler, resync time.Duration) *secretWatches {
+	return &secretWatches{
+		ctx:        ctx,
+		kubeclient: kc,
+		handler:    handler,
+		resync:     resync,
+		secrets:    make(map[types.NamespacedName]types.NamespacedName),
+		watches:    make(map[types.NamespacedName]*secretWatch),
+	}
+}
+
+// watch watches the Secret with the given name in the namespace of the given
+// source, and stops watching the Secret the source referenced before if no
+// other source references it.
+func (w *secretWatches) watch(source types.NamespacedName, name string) {
+	secret := types.NamespacedName{Namespace: source.Namespace, Name: name}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if prev, ok := w.secrets[source]; ok {
+		if prev == secret {
+			return
+		}
+		w.release(source, prev)
+	}
+	w.secrets[source] = secret
+
+	sw, ok := w.watches[secret]
+	if !ok {
+		ctx, cancel := context.WithCancel(w.ctx)
+		sw = &secretWatch{cancel: cancel, sources: sets.NewString()}
+		w.watches[secret] = sw
+		go w.newInformer(secret).Run(ctx.Done())
+	}
+	sw.sources.Insert(source.String())
+}
+
+// forget stops watching the Secret of the given source if no other source
+// references it.
+func (w *secretWatches) forget(source types.NamespacedName) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if prev, ok := w.secrets[source]; ok {
+		w.release(source, prev)
+		delete(w.secrets, source)
+	}
+}
+
+// release removes the given source from the watch of the given Secret, w.mu
+// must be held.
+func (w *secretWatches) release(source, secret types.NamespacedName) {
+	sw, ok := w.watches[secret]
+	if !ok {
+		return
+	}
+	sw.sources.Delete(source.String())
+	if sw.sources.Len() == 0 {
+		sw.cancel()
+		delete(w.watches, secret)
+	}
+}
+
+// newInformer returns an informer of the Secret with the given name only.
+func (w *secretWatches) newInformer(secret types.NamespacedName) cache.SharedIndexInformer {
+	selector := fields.OneTermEqualSelector("metadata.name", secret.Name).String()
+	secrets := w.kubeclient.CoreV1().Secrets(secret.Namespace)
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			opts.FieldSelector = selector
+			return secrets.List(w.ctx, opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.FieldSelector = selector
+			return secrets.Watch(w.ctx, opts)
+		},
+	}
+
+	informer := cache.NewSharedIndexInformer(lw, &corev1.Secret{}, w.resync, cache.Indexers{})
+	informer.AddEventHandler(w.handler)
+	return informer
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vspheresource
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	clientgotesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestSecretWatches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type secretWatch struct {
+		selector string
+		watcher  *watch.FakeWatcher
+	}
+	watches := make(chan secretWatch, 10)
+	kc := fake.NewSimpleClientset()
+	kc.PrependWatchReactor("secrets", func(action clientgotesting.Action) (bool, watch.Interface, error) {
+		w := watch.NewFake()
+		watches <- secretWatch{
+			selector: action.(clientgotesting.WatchAction).GetWatchRestrictions().Fields.String(),
+			watcher:  w,
+		}
+		return true, w, nil
+	})
+
+	changed := make(chan string, 10)
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { changed <- obj.(*corev1.Secret).Name },
+	}
+	w := newSecretWatches(ctx, kc, handler, 0)
+
+	nextWatch := func() secretWatch {
+		t.Helper()
+		select {
+		case sw := <-watches:
+			return sw
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a secret watch")
+		}
+		return secretWatch{}
+	}
+	waitStopped := func(sw secretWatch) {
+		t.Helper()
+		if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+			return sw.watcher.IsStopped(), nil
+		}); err != nil {
+			t.Errorf("watch %q not stopped: %v", sw.selector, err)
+		}
+	}
+
+	source := types.NamespacedName{Namespace: "ns", Name: "source"}
+	other := types.NamespacedName{Namespace: "ns", Name: "other"}
+
+	// the Secret is watched by name
+	w.watch(source, "credentials")
+	credentials := nextWatch()
+	if want := "metadata.name=credentials"; credentials.selector != want {
+		t.Errorf("watch field selector = %q, want %q", credentials.selector, want)
+	}
+	credentials.watcher.Add(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "credentials"}})
+	select {
+	case name := <-changed:
+		if name != "credentials" {
+			t.Errorf("handler got secret %q, want credentials", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the secret event")
+	}
+
+	// a Secret referenced by two sources is watched once, until both are gone
+	w.watch(other, "credentials")
+	w.watch(source, "credentials")
+	w.forget(source)
+	if credentials.watcher.IsStopped() {
+		t.Error("watch stopped while the secret is still referenced")
+	}
+	w.forget(other)
+	waitStopped(credentials)
+
+	// a source referencing another Secret stops watching the previous one
+	w.watch(source, "old")
+	old := nextWatch()
+	w.watch(source, "new")
+	if sw := nextWatch(); sw.selector != "metadata.name=new" {
+		t.Errorf("watch field selector = %q, want metadata.name=new", sw.selector)
+	}
+	waitStopped(old)
+
+	select {
+	case sw := <-watches:
+		t.Errorf("unexpected watch %q", sw.selector)
+	default:
+	}
+	if got := len(w.watches); got != 1 {
+		t.Errorf("got %d watches, want 1", got)
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vspheresource
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/metrics"
+)
+
+// The steps of the reconciliation of a source, which label the reconciler
+// metrics.
+const (
+	stepVCenter         = "vcenter"
+	stepBinding         = "binding"
+	stepConfigMap       = "configmap"
+	stepCheckpointLease = "checkpointlease"
+	stepCACerts         = "cacerts"
+	stepServiceAccount  = "serviceaccount"
+	stepRole            = "role"
+	stepRoleBinding     = "rolebinding"
+	stepSink            = "sink"
+	stepDeployment      = "deployment"
+	stepMetricsService  = "metricsservice"
+	stepServiceMonitor  = "servicemonitor"
+	stepEventTypes      = "eventtypes"
+)
+
+var (
+	// stepLatencyM is a distribution of the duration of the reconciliation
+	// steps.
+	stepLatencyM = stats.Float64(
+		"reconcile_step_latency",
+		"Latency of the steps of the reconciliation of a VSphereSource",
+		stats.UnitMilliseconds,
+	)
+
+	// stepFailuresM is a counter which records the failed reconciliation
+	// steps.
+	stepFailuresM = stats.Int64(
+		"reconcile_step_failures_total",
+		"Number of failed steps of the reconciliation of a VSphereSource",
+		stats.UnitDimensionless,
+	)
+
+	// sourcesM is a gauge which records the number of sources by the status
+	// of their Ready condition.
+	sourcesM = stats.Int64(
+		"vspheresources",
+		"Number of VSphereSources by the status of their Ready condition",
+		stats.UnitDimensionless,
+	)
+
+	stepKey        = tag.MustNewKey("step")
+	successKey     = tag.MustNewKey("success")
+	readyStatusKey = tag.MustNewKey("ready_status")
+
+	// stepLatencyBounds are the bucket boundaries of stepLatencyM, from 1ms
+	// to 10s
+	stepLatencyBounds = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+)
+
+func init() {
+	register()
+}
+
+// reportStep records the latency of the reconciliation step started at the
+// given time and, if it returned an error, its failure.
+func reportStep(ctx context.Context, step string, start time.Time, err error) {
+	ctx, tagErr := tag.New(ctx,
+		tag.Insert(stepKey, step),
+		tag.Insert(successKey, strconv.FormatBool(err == nil)))
+	if tagErr != nil {
+		return
+	}
+	metrics.Record(ctx, stepLatencyM.M(float64(time.Since(start))/float64(time.Millisecond)))
+	if err != nil {
+		metrics.Record(ctx, stepFailuresM.M(1))
+	}
+}
+
+// readyStates tracks the status of the Ready condition of the reconciled
+// sources to report the number of sources in each status. The zero value is
+// ready to use.
+type readyStates struct {
+	mu     sync.Mutex
+	states map[types.NamespacedName]string
+}
+
+// set records the status of the given Ready condition of the source with the
+// given key and reports the number of sources by status.
+func (s *readyStates) set(ctx context.Context, key types.NamespacedName, ready *apis.Condition) {
+	state := string(corev1.ConditionUnknown)
+	if ready != nil && ready.Status != "" {
+		state = string(ready.Status)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.states == nil {
+		s.states = make(map[types.NamespacedName]string)
+	}
+	s.states[key] = state
+	s.report(ctx)
+}
+
+// remove forgets the deleted source with the given key and reports the number
+// of sources by status.
+func (s *readyStates) remove(ctx context.Context, key types.NamespacedName) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, key)
+	s.report(ctx)
+}
+
+// report records the number of sources by status, s.mu must be held.
+func (s *readyStates) report(ctx context.Context) {
+	// report all statuses, so that a status no source is in drops to zero
+	counts := map[string]int64{
+		string(corev1.ConditionTrue):    0,
+		string(corev1.ConditionFalse):   0,
+		string(corev1.ConditionUnknown): 0,
+	}
+	for _, state := range s.states {
+		counts[state]++
+	}
+	for state, n := range counts {
+		ctx, err := tag.New(ctx, tag.Insert(readyStatusKey, state))
+		if err != nil {
+			continue
+		}
+		metrics.Record(ctx, sourcesM.M(n))
+	}
+}
+
+func register() {
+	if err := metrics.RegisterResourceView(
+		&view.View{
+			Description: stepLatencyM.Description(),
+			Measure:     stepLatencyM,
+			Aggregation: view.Distribution(stepLatencyBounds...),
+			TagKeys:     []tag.Key{stepKey, successKey},
+		},
+		&view.View{
+			Description: stepFailuresM.Description(),
+			Measure:     stepFailuresM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{stepKey},
+		},
+		&view.View{
+			Description: sourcesM.Description(),
+			Measure:     sourcesM,
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{readyStatusKey},
+		},
+	); err != nil {
+		panic(err)
+	}
+}
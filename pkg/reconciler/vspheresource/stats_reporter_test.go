@@ -0,0 +1,92 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vspheresource
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/metrics"
+	"knative.dev/pkg/metrics/metricstest"
+)
+
+// metricValue returns the value of the metric with the given name and tags,
+// nil if it was not recorded.
+func metricValue(name string, tags map[string]string) *metricstest.Value {
+	metricstest.EnsureRecorded()
+	for _, m := range metricstest.GetMetric(name) {
+		for _, v := range m.Values {
+			if cmp.Equal(v.Tags, tags) {
+				v := v
+				return &v
+			}
+		}
+	}
+	return nil
+}
+
+func TestReportStep(t *testing.T) {
+	metrics.InitForTesting()
+	ctx := context.Background()
+
+	reportStep(ctx, stepSink, time.Now(), errors.New("sink not found"))
+	reportStep(ctx, stepDeployment, time.Now(), nil)
+
+	for _, tags := range []map[string]string{
+		{"step": stepSink, "success": "false"},
+		{"step": stepDeployment, "success": "true"},
+	} {
+		v := metricValue("reconcile_step_latency", tags)
+		if v == nil || v.Distribution == nil || v.Distribution.Count != 1 {
+			t.Errorf("reconcile_step_latency%v = %+v, want 1 observation", tags, v)
+		}
+	}
+
+	if v := metricValue("reconcile_step_failures_total", map[string]string{"step": stepSink}); v == nil || *v.Int64 != 1 {
+		t.Errorf("reconcile_step_failures_total{step=sink} = %+v, want 1", v)
+	}
+	if v := metricValue("reconcile_step_failures_total", map[string]string{"step": stepDeployment}); v != nil {
+		t.Errorf("reconcile_step_failures_total{step=deployment} = %d, want not recorded", *v.Int64)
+	}
+}
+
+func TestReadyStates(t *testing.T) {
+	metrics.InitForTesting()
+	ctx := context.Background()
+
+	ready := func(status corev1.ConditionStatus) *apis.Condition {
+		return &apis.Condition{Type: apis.ConditionReady, Status: status}
+	}
+	a := types.NamespacedName{Namespace: "ns", Name: "a"}
+	b := types.NamespacedName{Namespace: "ns", Name: "b"}
+	c := types.NamespacedName{Namespace: "ns", Name: "c"}
+
+	var s readyStates
+	s.set(ctx, a, ready(corev1.ConditionTrue))
+	s.set(ctx, b, ready(corev1.ConditionFalse))
+	s.set(ctx, c, nil)
+	// a source becoming not ready and a deleted source
+	s.set(ctx, a, ready(corev1.ConditionFalse))
+	s.remove(ctx, b)
+
+	want := map[corev1.ConditionStatus]int64{
+		corev1.ConditionTrue:    0,
+		corev1.ConditionFalse:   1,
+		corev1.ConditionUnknown: 1,
+	}
+	for status, n := range want {
+		v := metricValue("vspheresources", map[string]string{"ready_status": string(status)})
+		if v == nil || *v.Int64 != n {
+			t.Errorf("vspheresources{ready_status=%s} = %+v, want %d", status, v, n)
+		}
+	}
+}
@@ -7,38 +7,80 @@ package vspheresource
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	appsv1listers "k8s.io/client-go/listers/apps/v1"
+	coordinationv1listers "k8s.io/client-go/listers/coordination/v1"
 	corev1Listers "k8s.io/client-go/listers/core/v1"
 	rbacv1listers "k8s.io/client-go/listers/rbac/v1"
+	eventingv1beta1 "knative.dev/eventing/pkg/apis/eventing/v1beta1"
 	eventingclientset "knative.dev/eventing/pkg/client/clientset/versioned"
+	eventingv1beta1listers "knative.dev/eventing/pkg/client/listers/eventing/v1beta1"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/metrics"
+	"knative.dev/pkg/ptr"
 	"knative.dev/pkg/reconciler"
 	"knative.dev/pkg/resolver"
+	tracingconfig "knative.dev/pkg/tracing/config"
+	"knative.dev/pkg/tracker"
 
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/config"
 	sourcesv1alpha1 "github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
 	clientset "github.com/vmware-tanzu/sources-for-knative/pkg/client/clientset/versioned"
 	vspherereconciler "github.com/vmware-tanzu/sources-for-knative/pkg/client/injection/reconciler/sources/v1alpha1/vspheresource"
 	v1alpha1lister "github.com/vmware-tanzu/sources-for-knative/pkg/client/listers/sources/v1alpha1"
 	"github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspheresource/resources"
 	resourcenames "github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspheresource/resources/names"
+	"github.com/vmware-tanzu/sources-for-knative/pkg/vsphere"
 )
 
 const (
 	component = "vspheresource"
+
+	// featuresConfigMapName is the name of the ConfigMap holding the feature
+	// flags of the controller
+	featuresConfigMapName = "config-features"
+	// adapterImageOverrideKey is the feature flag allowing spec.adapterImage
+	adapterImageOverrideKey = "adapter-image-override"
+
+	// vcenterCleanupTimeout bounds the cleanup of vCenter when a source is
+	// deleted, so that an unreachable vCenter does not block the deletion
+	vcenterCleanupTimeout = 30 * time.Second
+
+	// heartbeatTimeout is the age of the heartbeat of the adapter after which
+	// the adapter is unhealthy
+	heartbeatTimeout = 3 * vsphere.HeartbeatInterval
+	// heartbeatFailureTimeout is the time the adapter may fail to read or
+	// deliver events before it is unhealthy
+	heartbeatFailureTimeout = 2 * vsphere.HeartbeatInterval
 )
 
 // Reconciler implements vspherereconciler.Interface for VSphereSource
 // resources.
 type Reconciler struct {
 	resolver *resolver.URIResolver
+	// tracker enqueues the sources when their CA bundle ConfigMap or
+	// credentials Secret changes
+	tracker tracker.Interface
 
 	kubeclient     kubernetes.Interface
 	eventingclient eventingclientset.Interface
@@ -46,52 +88,330 @@ type Reconciler struct {
 
 	deploymentLister     appsv1listers.DeploymentLister
 	vspherebindingLister v1alpha1lister.VSphereBindingLister
+	roleLister           rbacv1listers.RoleLister
 	rbacLister           rbacv1listers.RoleBindingLister
 	cmLister             corev1Listers.ConfigMapLister
 	saLister             corev1Listers.ServiceAccountLister
+	serviceLister        corev1Listers.ServiceLister
+	podLister            corev1Listers.PodLister
+	eventTypeLister      eventingv1beta1listers.EventTypeLister
+	// leaseLister lists the Leases labeled with the name of a source, i.e.
+	// the checkpoint Leases
+	leaseLister coordinationv1listers.LeaseLister
+
+	// serviceMonitorClient manages the Prometheus Operator ServiceMonitors of
+	// the metrics Services, which are not created when nil
+	serviceMonitorClient dynamic.NamespaceableResourceInterface
 
 	loggingContext context.Context
 	adapterImage   string
-	loggingConfig  *logging.Config
-	metricsConfig  *metrics.ExporterOptions
+	// adapterImageOverride allows spec.adapterImage to override adapterImage
+	adapterImageOverride bool
+	adapterResources     corev1.ResourceRequirements
+	// vsphereConfig holds the cluster-wide defaults of the config-vsphere
+	// ConfigMap, the built-in defaults apply when nil
+	vsphereConfig *config.VSphere
+	// terminateSessions terminates the vCenter sessions of a deleted source,
+	// vsphere.TerminateSessions when nil
+	terminateSessions func(context.Context, vsphere.SessionCleanup) (int, error)
+	// adapterMetricsPort is the port of the Prometheus metrics endpoint of the
+	// adapter, the default port of the knative metrics exporter when zero
+	adapterMetricsPort int
+	// checkpointLagThreshold is the lag of the adapter checkpoint above which
+	// the CheckpointCurrent condition is false, disabled when zero
+	checkpointLagThreshold time.Duration
+	loggingConfig          *logging.Config
+	metricsConfig          *metrics.ExporterOptions
+	tracingConfig          *tracingconfig.Config
+	// readyStates tracks the Ready condition of the sources for the
+	// reconciler metrics
+	readyStates readyStates
+	// enqueueAfter enqueues the given source after the given delay, so that
+	// a stale adapter heartbeat is detected, not called when nil
+	enqueueAfter func(interface{}, time.Duration)
+	// secretWatches watches the credentials Secrets of the sources, which
+	// are not watched when nil
+	secretWatches *secretWatches
 }
 
 // Check that our Reconciler implements Interface
 var _ vspherereconciler.Interface = (*Reconciler)(nil)
 
+// Check that our Reconciler implements Finalizer
+var _ vspherereconciler.Finalizer = (*Reconciler)(nil)
+
 // ReconcileKind implements Interface.ReconcileKind.
 func (r *Reconciler) ReconcileKind(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) reconciler.Event {
-	if err := r.reconcileVSphereBinding(ctx, vms); err != nil {
+	// the status has been updated when the reconciliation returns
+	defer func() {
+		r.readyStates.set(ctx, types.NamespacedName{Namespace: vms.Namespace, Name: vms.Name},
+			vms.Status.GetCondition(apis.ConditionReady))
+	}()
+
+	warnSkipTLSVerify(ctx, vms)
+	vms.Status.UpdateCloudEventAttributes(vms.Spec)
+
+	if err := r.step(ctx, stepVCenter, vms, r.checkVCenterAllowed); err != nil {
+		return err
+	}
+
+	if err := r.step(ctx, stepBinding, vms, r.reconcileVSphereBinding); err != nil {
 		return err
 	}
 
 	// Make sure the ConfigMap for storing state exists before we
 	// create the deployment so that it gets created as owned
 	// by the source and hence won't be leaked.
-	if err := r.reconcileConfigMap(ctx, vms); err != nil {
+	if err := r.step(ctx, stepConfigMap, vms, r.reconcileConfigMap); err != nil {
+		return err
+	}
+	if err := r.step(ctx, stepCheckpointLease, vms, r.reconcileCheckpointLease); err != nil {
 		return err
 	}
-	if err := r.reconcileServiceAccount(ctx, vms); err != nil {
+	if err := r.step(ctx, stepCACerts, vms, r.reconcileCACertsConfigMap); err != nil {
 		return err
 	}
-	if err := r.reconcileRoleBinding(ctx, vms); err != nil {
+	if err := r.step(ctx, stepServiceAccount, vms, r.reconcileServiceAccount); err != nil {
+		return err
+	}
+	if err := r.step(ctx, stepRole, vms, r.reconcileRole); err != nil {
+		return err
+	}
+	if err := r.step(ctx, stepRoleBinding, vms, r.reconcileRoleBinding); err != nil {
 		return err
 	}
 
-	uri, err := r.resolver.URIFromDestinationV1(ctx, vms.Spec.Sink, vms)
-	if err != nil {
+	if err := r.step(ctx, stepSink, vms, r.reconcileSink); err != nil {
 		return err
 	}
-	vms.Status.SinkURI = uri
 
-	if err = r.reconcileDeployment(ctx, vms); err != nil {
+	r.reconcileDeadLetterSink(ctx, vms)
+
+	// Spec defaults have been applied, so this is the effective policy.
+	vms.Status.Retry = vms.Spec.Retry.DeepCopy()
+
+	// The adapter presents a token issued for its ServiceAccount to the sink.
+	vms.Status.Auth = nil
+	if vms.Spec.SinkAudience != nil {
+		vms.Status.Auth = &sourcesv1alpha1.AuthStatus{
+			ServiceAccountName: ptr.String(resourcenames.ServiceAccount(vms)),
+		}
+	}
+
+	if err := r.step(ctx, stepDeployment, vms, r.reconcileDeployment); err != nil {
+		return err
+	}
+	if err := r.step(ctx, stepMetricsService, vms, r.reconcileMetricsService); err != nil {
+		return err
+	}
+	if err := r.step(ctx, stepServiceMonitor, vms, r.reconcileServiceMonitor); err != nil {
 		return err
 	}
+	if err := r.step(ctx, stepEventTypes, vms, r.reconcileEventTypes); err != nil {
+		return err
+	}
+	r.reconcileCheckpointStatus(ctx, vms)
+	r.reconcileAdapterHealth(ctx, vms)
+
+	// the reason of the Ready condition is only kept when marked last
+	if vms.Spec.Paused {
+		vms.Status.MarkPaused()
+	} else {
+		vms.Status.ClearPaused()
+	}
 	logging.FromContext(ctx).Infof("Reconciled vspheresource %q", vms.Name)
 
+	return reconciler.NewEvent(corev1.EventTypeNormal, "VSphereSourceReconciled", "VSphereSource reconciled: \"%s/%s\"", vms.Namespace, vms.Name)
+}
+
+// step runs the given step of the reconciliation of the source and reports
+// its latency and failure.
+func (r *Reconciler) step(ctx context.Context, name string, vms *sourcesv1alpha1.VSphereSource,
+	reconcile func(context.Context, *sourcesv1alpha1.VSphereSource) error) error {
+	start := time.Now()
+	err := reconcile(ctx, vms)
+	reportStep(ctx, name, start, err)
+	return err
+}
+
+// FinalizeKind implements Finalizer.FinalizeKind. It stops the adapter and
+// terminates its vCenter sessions, which also removes the event history
+// collectors vCenter keeps for them. The cleanup of vCenter is best-effort:
+// when vCenter is unreachable, a warning event is recorded and the source is
+// deleted anyway.
+func (r *Reconciler) FinalizeKind(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) reconciler.Event {
+	r.readyStates.remove(ctx, types.NamespacedName{Namespace: vms.Namespace, Name: vms.Name})
+	if r.secretWatches != nil {
+		r.secretWatches.forget(types.NamespacedName{Namespace: vms.Namespace, Name: vms.Name})
+	}
+
+	// stop the adapter first, so that it does not log in again
+	name := resourcenames.Deployment(vms)
+	err := r.kubeclient.AppsV1().Deployments(vms.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err == nil {
+		recordNormalEvent(ctx, vms, "DeploymentDeleted", "Deleted deployment %q", name)
+	} else if !apierrs.IsNotFound(err) {
+		return newFailedEvent("DeploymentFailed", "failed to delete deployment %q: %w", name, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, vcenterCleanupTimeout)
+	defer cancel()
+
+	terminated, err := r.terminateAdapterSessions(ctx, vms)
+	if err != nil {
+		controller.GetEventRecorder(ctx).Eventf(vms, corev1.EventTypeWarning, "VCenterCleanupFailed",
+			"Failed to terminate the vCenter sessions of the adapter: %v", err)
+		return nil
+	}
+	if terminated > 0 {
+		recordNormalEvent(ctx, vms, "VCenterSessionsTerminated", "Terminated %d vCenter sessions of the adapter", terminated)
+	}
+	return nil
+}
+
+// terminateAdapterSessions terminates the sessions of the adapter of the given
+// source on its vCenter and its failover vCenters. It returns the number of
+// terminated sessions and the errors of the vCenters that could not be cleaned
+// up.
+func (r *Reconciler) terminateAdapterSessions(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) (int, error) {
+	cleanup, err := r.sessionCleanup(ctx, vms)
+	if err != nil {
+		return 0, err
+	}
+
+	terminate := r.terminateSessions
+	if terminate == nil {
+		terminate = vsphere.TerminateSessions
+	}
+
+	var errs []error
+	total := 0
+	for _, address := range append([]string{vms.Spec.Address.String()}, vms.Spec.FailoverAddresses...) {
+		cleanup.Address = address
+		terminated, err := terminate(ctx, cleanup)
+		total += terminated
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", address, err))
+		}
+	}
+	return total, utilerrors.NewAggregate(errs)
+}
+
+// sessionCleanup returns the connection settings and credentials of the
+// adapter of the given source, without an address.
+func (r *Reconciler) sessionCleanup(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) (vsphere.SessionCleanup, error) {
+	auth := vms.Spec.VAuthSpec
+	auth.SetDefaults(ctx)
+
+	cleanup := vsphere.SessionCleanup{
+		Insecure:  auth.SkipTLSVerify,
+		UserAgent: vsphere.SessionUserAgent(vms.Namespace, vms.Name),
+	}
+	if p := vms.Spec.Proxy; p != nil {
+		cleanup.HTTPProxy, cleanup.HTTPSProxy, cleanup.NoProxy = p.HTTPProxy, p.HTTPSProxy, p.NoProxy
+	}
+
+	secret, err := r.kubeclient.CoreV1().Secrets(vms.Namespace).Get(ctx, auth.SecretRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return cleanup, fmt.Errorf("failed to get credentials secret %q: %w", auth.SecretRef.Name, err)
+	}
+	cleanup.Username = string(secret.Data[auth.UsernameKey])
+	cleanup.Password = string(secret.Data[auth.PasswordKey])
+
+	if auth.CACertKey != "" {
+		cleanup.CACerts = append(cleanup.CACerts, secret.Data[auth.CACertKey])
+	}
+	if vms.Spec.CACerts != nil {
+		cleanup.CACerts = append(cleanup.CACerts, []byte(*vms.Spec.CACerts))
+	}
+	if cab := auth.CABundle; cab != nil {
+		cm, err := r.cmLister.ConfigMaps(vms.Namespace).Get(cab.Name)
+		if err == nil {
+			cleanup.CACerts = append(cleanup.CACerts, []byte(cm.Data[cab.Key]))
+		} else if !apierrs.IsNotFound(err) || cab.Optional == nil || !*cab.Optional {
+			return cleanup, fmt.Errorf("failed to get CA bundle configmap %q: %w", cab.Name, err)
+		}
+	}
+	return cleanup, nil
+}
+
+// reconcileSink resolves the sink and the additional sinks of the source and
+// reflects the result in the SinkProvided condition.
+func (r *Reconciler) reconcileSink(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) error {
+	uri, err := r.resolver.URIFromDestinationV1(ctx, vms.Spec.Sink, vms)
+	if err != nil {
+		reason := sinkFailureReason(err)
+		vms.Status.MarkNoSink(reason, "%v", err)
+		return newFailedEvent(reason, "failed to resolve sink: %w", err)
+	}
+
+	additional := make([]*apis.URL, 0, len(vms.Spec.Sinks))
+	for i, sink := range vms.Spec.Sinks {
+		sinkURI, err := r.resolver.URIFromDestinationV1(ctx, sink, vms)
+		if err != nil {
+			reason := sinkFailureReason(err)
+			vms.Status.MarkNoSink(reason, "sinks[%d]: %v", i, err)
+			return newFailedEvent(reason, "failed to resolve sinks[%d]: %w", i, err)
+		}
+		additional = append(additional, sinkURI)
+	}
+	vms.Status.MarkSinks(uri, additional...)
+
 	return nil
 }
 
+// sinkFailureReason returns the condition reason for the given sink
+// resolution error.
+func sinkFailureReason(err error) string {
+	if apierrs.IsNotFound(err) {
+		return "SinkNotFound"
+	}
+	return "NotAddressable"
+}
+
+// warnSkipTLSVerify reflects whether TLS verification against vCenter is
+// disabled for the given source in the TLSVerified condition, and emits a
+// warning event when it is disabled, not on every reconciliation.
+func warnSkipTLSVerify(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) {
+	if !vms.Spec.SkipTLSVerify {
+		vms.Status.ClearTLSVerifiedCondition()
+		return
+	}
+
+	if cond := vms.Status.GetCondition(sourcesv1alpha1.VSphereSourceConditionTLSVerified); cond.IsFalse() {
+		return
+	}
+	vms.Status.MarkTLSVerificationSkipped()
+	cond := vms.Status.GetCondition(sourcesv1alpha1.VSphereSourceConditionTLSVerified)
+	controller.GetEventRecorder(ctx).Event(vms, corev1.EventTypeWarning, cond.Reason, cond.Message)
+}
+
+// reconcileDeadLetterSink resolves the dead letter sink, if any. A dead letter
+// sink which cannot be resolved is reported in the status but does not block
+// the delivery of events to the sink.
+func (r *Reconciler) reconcileDeadLetterSink(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) {
+	if vms.Spec.DeadLetterSink == nil {
+		vms.Status.ClearDeadLetterSink()
+		return
+	}
+
+	wasFailed := vms.Status.GetCondition(sourcesv1alpha1.VSphereSourceConditionDeadLetterSinkResolved).IsFalse()
+
+	uri, err := r.resolver.URIFromDestinationV1(ctx, *vms.Spec.DeadLetterSink, vms)
+	if err != nil {
+		logging.FromContext(ctx).Warnw("failed to resolve dead letter sink", zap.Error(err))
+		vms.Status.MarkNoDeadLetterSink("DeadLetterSinkNotFound", "%v", err)
+
+		// Only record on transition to avoid an event on every reconcile.
+		if !wasFailed {
+			controller.GetEventRecorder(ctx).Eventf(vms, corev1.EventTypeWarning, "DeadLetterSinkNotFound",
+				"Failed to resolve dead letter sink: %v", err)
+		}
+		return
+	}
+	vms.Status.MarkDeadLetterSink(uri)
+}
+
 func (r *Reconciler) reconcileVSphereBinding(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) error {
 	ns := vms.Namespace
 	vspherebindingName := resourcenames.VSphereBinding(vms)
@@ -101,25 +421,37 @@ func (r *Reconciler) reconcileVSphereBinding(ctx context.Context, vms *sourcesv1
 		vspherebinding = resources.MakeVSphereBinding(ctx, vms)
 		vspherebinding, err = r.client.SourcesV1alpha1().VSphereBindings(ns).Create(ctx, vspherebinding, metav1.CreateOptions{})
 		if err != nil {
-			return fmt.Errorf("failed to create vspherebinding %q: %w", vspherebindingName, err)
+			return newFailedEvent("VSphereBindingFailed", "failed to create vspherebinding %q: %w", vspherebindingName, err)
 		}
-		logging.FromContext(ctx).Infof("Created vspherebinding %q", vspherebindingName)
+		recordNormalEvent(ctx, vms, "VSphereBindingCreated", "Created vspherebinding %q", vspherebindingName)
 	} else if err != nil {
 		return fmt.Errorf("failed to get vspherebinding %q: %w", vspherebindingName, err)
 	} else {
 		// The vspherebinding exists, but make sure that it has the shape that we expect.
 		desiredVSphereBinding := resources.MakeVSphereBinding(ctx, vms)
-		vspherebinding = vspherebinding.DeepCopy()
-		vspherebinding.Spec = desiredVSphereBinding.Spec
-		vspherebinding, err = r.client.SourcesV1alpha1().VSphereBindings(ns).Update(ctx, vspherebinding, metav1.UpdateOptions{})
-		if err != nil {
-			return fmt.Errorf("failed to create vspherebinding %q: %w", vspherebindingName, err)
+		labels, annotations, metadataChanged := mergeMetadata(vspherebinding, desiredVSphereBinding)
+		if metadataChanged || !equality.Semantic.DeepDerivative(desiredVSphereBinding.Spec, vspherebinding.Spec) {
+			vspherebinding = vspherebinding.DeepCopy()
+			vspherebinding.Labels, vspherebinding.Annotations = labels, annotations
+			vspherebinding.Spec = desiredVSphereBinding.Spec
+			vspherebinding, err = r.client.SourcesV1alpha1().VSphereBindings(ns).Update(ctx, vspherebinding, metav1.UpdateOptions{})
+			if err != nil {
+				return newFailedEvent("VSphereBindingFailed", "failed to update vspherebinding %q: %w", vspherebindingName, err)
+			}
+			recordNormalEvent(ctx, vms, "VSphereBindingUpdated", "Updated vspherebinding %q", vspherebindingName)
 		}
 	}
 
 	// Reflect the state of the VSphereBinding in the VSphereSource
+	wasFailed := vms.Status.GetCondition(sourcesv1alpha1.VSphereSourceConditionAuthReady).IsFalse()
 	vms.Status.PropagateAuthStatus(vspherebinding.Status.Status)
 
+	// Only record on transition to avoid an event on every reconcile.
+	if cond := vms.Status.GetCondition(sourcesv1alpha1.VSphereSourceConditionAuthReady); cond.IsFalse() && !wasFailed {
+		controller.GetEventRecorder(ctx).Eventf(vms, corev1.EventTypeWarning, "AuthFailed",
+			"Authentication against vCenter %q failed: %s: %s", vms.Spec.Address.String(), cond.Reason, cond.Message)
+	}
+
 	return nil
 }
 
@@ -127,18 +459,248 @@ func (r *Reconciler) reconcileConfigMap(ctx context.Context, vms *sourcesv1alpha
 	ns := vms.Namespace
 	name := resourcenames.ConfigMap(vms)
 
-	_, err := r.cmLister.ConfigMaps(ns).Get(name)
-	// Note that we only create the configmap if it does not exist so that we get the
-	// OwnerRefs set up properly so it gets Garbage Collected.
+	cm, err := r.cmLister.ConfigMaps(ns).Get(name)
+	desired := resources.MakeConfigMap(ctx, vms)
 	if apierrs.IsNotFound(err) {
-		cm := resources.MakeConfigMap(ctx, vms)
-		_, err := r.kubeclient.CoreV1().ConfigMaps(ns).Create(ctx, cm, metav1.CreateOptions{})
+		_, err := r.kubeclient.CoreV1().ConfigMaps(ns).Create(ctx, desired, metav1.CreateOptions{})
 		if err != nil {
-			return fmt.Errorf("failed to create configmap %q: %w", name, err)
+			return newFailedEvent("ConfigMapFailed", "failed to create configmap %q: %w", name, err)
 		}
-		logging.FromContext(ctx).Infof("Created configmap %q", name)
+		recordNormalEvent(ctx, vms, "ConfigMapCreated", "Created configmap %q", name)
 	} else if err != nil {
 		return fmt.Errorf("failed to get configmap %q: %w", name, err)
+	} else if metav1.IsControlledBy(cm, vms) {
+		// The adapter writes its checkpoint to the configmap, so only the keys
+		// owned by the reconciler are updated.
+		if merged, changed := mergeConfigMap(cm, desired); changed {
+			_, err := r.kubeclient.CoreV1().ConfigMaps(ns).Update(ctx, merged, metav1.UpdateOptions{})
+			if err != nil {
+				return newFailedEvent("ConfigMapFailed", "failed to update configmap %q: %w", name, err)
+			}
+			recordNormalEvent(ctx, vms, "ConfigMapUpdated", "Updated configmap %q", name)
+		}
+	}
+
+	return nil
+}
+
+// reconcileCheckpointLease creates the Lease the adapter stores its checkpoint
+// in with the lease checkpoint backend. The Lease is kept when switching to
+// another backend, so that the adapter can migrate its checkpoint, and is
+// garbage collected with the source.
+func (r *Reconciler) reconcileCheckpointLease(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) error {
+	if vms.Spec.CheckpointConfig.Backend != sourcesv1alpha1.CheckpointBackendLease {
+		return nil
+	}
+
+	ns := vms.Namespace
+	name := resourcenames.CheckpointLease(vms)
+
+	lease, err := r.leaseLister.Leases(ns).Get(name)
+	desired := resources.MakeCheckpointLease(ctx, vms)
+	if apierrs.IsNotFound(err) {
+		_, err := r.kubeclient.CoordinationV1().Leases(ns).Create(ctx, desired, metav1.CreateOptions{})
+		if err != nil {
+			return newFailedEvent("LeaseFailed", "failed to create lease %q: %w", name, err)
+		}
+		recordNormalEvent(ctx, vms, "LeaseCreated", "Created lease %q", name)
+	} else if err != nil {
+		return fmt.Errorf("failed to get lease %q: %w", name, err)
+	} else if labels, annotations, changed := mergeMetadata(lease, desired); changed {
+		// the adapter writes its checkpoint to the annotations of the lease,
+		// which are preserved
+		lease = lease.DeepCopy()
+		lease.Labels, lease.Annotations = labels, annotations
+		_, err := r.kubeclient.CoordinationV1().Leases(ns).Update(ctx, lease, metav1.UpdateOptions{})
+		if err != nil {
+			return newFailedEvent("LeaseFailed", "failed to update lease %q: %w", name, err)
+		}
+		recordNormalEvent(ctx, vms, "LeaseUpdated", "Updated lease %q", name)
+	}
+
+	return nil
+}
+
+// reconcileCheckpointStatus reflects the last checkpoint saved by the adapter
+// and its lag in the status. The checkpoint is informational, so failures to
+// read it are only logged. Checkpoint updates do not trigger a reconciliation,
+// the lag is the one at the time of the last reconciliation.
+func (r *Reconciler) reconcileCheckpointStatus(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) {
+	data, err := r.checkpointData(ctx, vms)
+	if err != nil {
+		logging.FromContext(ctx).Warnw("failed to read checkpoint", zap.Error(err))
+		return
+	}
+	if data == "" {
+		vms.Status.ClearCheckpoint()
+		return
+	}
+
+	key, lastEventTime, err := vsphere.ParseCheckpoint(data)
+	if err != nil {
+		logging.FromContext(ctx).Warnw("failed to parse checkpoint", zap.Error(err))
+		return
+	}
+	// the checkpoint saved on the first start only records where the event
+	// stream begins, the adapter has not processed an event yet
+	if key == 0 {
+		vms.Status.ClearCheckpoint()
+		return
+	}
+
+	lag := time.Since(lastEventTime)
+	vms.Status.MarkCheckpoint(key, lastEventTime, lag)
+
+	switch {
+	case r.checkpointLagThreshold <= 0:
+		vms.Status.ClearCheckpointCondition()
+	case lag > r.checkpointLagThreshold:
+		vms.Status.MarkCheckpointLagging(lag, r.checkpointLagThreshold)
+	default:
+		vms.Status.MarkCheckpointCurrent()
+	}
+}
+
+// reconcileAdapterHealth reflects the heartbeat the adapter writes to the
+// ConfigMap of the source in the AdapterHealthy condition. The adapter is
+// unhealthy when its heartbeat is older than heartbeatTimeout, e.g. because
+// it stopped after a failure, or reports failures for longer than
+// heartbeatFailureTimeout. The source is enqueued again when a recent
+// heartbeat would turn stale. The condition is removed while the source is
+// paused.
+func (r *Reconciler) reconcileAdapterHealth(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) {
+	// a paused adapter does not write heartbeats
+	if vms.Spec.Paused {
+		vms.Status.ClearAdapterHealthCondition()
+		return
+	}
+
+	name := resourcenames.ConfigMap(vms)
+	cm, err := r.cmLister.ConfigMaps(vms.Namespace).Get(name)
+	if apierrs.IsNotFound(err) {
+		vms.Status.ClearAdapterHealthCondition()
+		return
+	} else if err != nil {
+		logging.FromContext(ctx).Warnw("failed to get configmap", zap.String("name", name), zap.Error(err))
+		return
+	}
+
+	data := cm.Data[vsphere.HeartbeatConfigMapKey]
+	if data == "" {
+		vms.Status.ClearAdapterHealthCondition()
+		return
+	}
+	hb, err := vsphere.ParseHeartbeat(data)
+	if err != nil {
+		logging.FromContext(ctx).Warnw("failed to parse heartbeat", zap.Error(err))
+		return
+	}
+
+	age := time.Since(hb.Time)
+	// the adapter stops writing heartbeats when it exits, a stale heartbeat is
+	// checked again when the restart of the adapter pod enqueues the source
+	if age <= heartbeatTimeout && r.enqueueAfter != nil {
+		r.enqueueAfter(vms, heartbeatTimeout-age)
+	}
+
+	switch {
+	case age > heartbeatTimeout:
+		msg := fmt.Sprintf("The adapter has not reported a heartbeat for %s", age.Round(time.Second))
+		if hb.LastError != "" {
+			msg += ", last error: " + hb.LastError
+		}
+		vms.Status.MarkAdapterUnhealthy("HeartbeatStale", "%s", msg)
+	case hb.FailingSince != nil && hb.Time.Sub(*hb.FailingSince) > heartbeatFailureTimeout:
+		vms.Status.MarkAdapterUnhealthy("AdapterFailing", "The adapter has been failing for %s: %s",
+			hb.Time.Sub(*hb.FailingSince).Round(time.Second), hb.LastError)
+	default:
+		vms.Status.MarkAdapterHealthy()
+	}
+}
+
+// checkpointData returns the JSON-encoded checkpoint of the adapter from the
+// store of the configured checkpoint backend, or an empty string if the
+// adapter has not saved a checkpoint yet.
+func (r *Reconciler) checkpointData(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) (string, error) {
+	if vms.Spec.CheckpointConfig.Backend == sourcesv1alpha1.CheckpointBackendLease {
+		name := resourcenames.CheckpointLease(vms)
+		lease, err := r.leaseLister.Leases(vms.Namespace).Get(name)
+		if apierrs.IsNotFound(err) {
+			return "", nil
+		} else if err != nil {
+			return "", fmt.Errorf("failed to get lease %q: %w", name, err)
+		}
+		return lease.Annotations[vsphere.CheckpointLeaseAnnotation], nil
+	}
+
+	name := resourcenames.ConfigMap(vms)
+	cm, err := r.cmLister.ConfigMaps(vms.Namespace).Get(name)
+	if apierrs.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get configmap %q: %w", name, err)
+	}
+	return cm.Data[vsphere.CheckpointConfigMapKey], nil
+}
+
+// mergeConfigMap returns a copy of existing with the labels, annotations and
+// data keys of desired applied, and whether this changed existing. Data keys
+// which are not in desired, e.g. the adapter checkpoint, are preserved, labels
+// and annotations are merged with mergeMetadata.
+func mergeConfigMap(existing, desired *corev1.ConfigMap) (*corev1.ConfigMap, bool) {
+	merged := existing.DeepCopy()
+	var changed bool
+	merged.Labels, merged.Annotations, changed = mergeMetadata(existing, desired)
+
+	for k, v := range desired.Data {
+		if cur, ok := merged.Data[k]; !ok || cur != v {
+			if merged.Data == nil {
+				merged.Data = make(map[string]string, len(desired.Data))
+			}
+			merged.Data[k] = v
+			changed = true
+		}
+	}
+
+	return merged, changed
+}
+
+func (r *Reconciler) reconcileCACertsConfigMap(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) error {
+	ns := vms.Namespace
+	name := resourcenames.CACertsConfigMap(vms)
+
+	cm, err := r.cmLister.ConfigMaps(ns).Get(name)
+	if vms.Spec.CACerts == nil {
+		// Clean up a previously created CA bundle.
+		if err == nil && metav1.IsControlledBy(cm, vms) {
+			err = r.kubeclient.CoreV1().ConfigMaps(ns).Delete(ctx, name, metav1.DeleteOptions{})
+			if err != nil && !apierrs.IsNotFound(err) {
+				return newFailedEvent("ConfigMapFailed", "failed to delete configmap %q: %w", name, err)
+			}
+			recordNormalEvent(ctx, vms, "ConfigMapDeleted", "Deleted configmap %q", name)
+		}
+		return nil
+	}
+
+	desired := resources.MakeCACertsConfigMap(ctx, vms)
+	if apierrs.IsNotFound(err) {
+		_, err := r.kubeclient.CoreV1().ConfigMaps(ns).Create(ctx, desired, metav1.CreateOptions{})
+		if err != nil {
+			return newFailedEvent("ConfigMapFailed", "failed to create configmap %q: %w", name, err)
+		}
+		recordNormalEvent(ctx, vms, "ConfigMapCreated", "Created configmap %q", name)
+	} else if err != nil {
+		return fmt.Errorf("failed to get configmap %q: %w", name, err)
+	} else if labels, annotations, changed := mergeMetadata(cm, desired); changed ||
+		!equality.Semantic.DeepEqual(cm.Data, desired.Data) {
+		cm = cm.DeepCopy()
+		cm.Labels, cm.Annotations = labels, annotations
+		cm.Data = desired.Data
+		_, err := r.kubeclient.CoreV1().ConfigMaps(ns).Update(ctx, cm, metav1.UpdateOptions{})
+		if err != nil {
+			return newFailedEvent("ConfigMapFailed", "failed to update configmap %q: %w", name, err)
+		}
+		recordNormalEvent(ctx, vms, "ConfigMapUpdated", "Updated configmap %q", name)
 	}
 
 	return nil
@@ -148,16 +710,252 @@ func (r *Reconciler) reconcileServiceAccount(ctx context.Context, vms *sourcesv1
 	ns := vms.Namespace
 	name := resourcenames.ServiceAccount(vms)
 
-	_, err := r.saLister.ServiceAccounts(ns).Get(name)
+	existing, err := r.saLister.ServiceAccounts(ns).Get(name)
+
+	// A user-provided ServiceAccount is not managed by the source.
+	if vms.Spec.ServiceAccountName != "" {
+		if apierrs.IsNotFound(err) {
+			vms.Status.MarkNoServiceAccount(name)
+			return fmt.Errorf("serviceaccount %q does not exist", name)
+		} else if err != nil {
+			return fmt.Errorf("failed to get serviceaccount %q: %w", name, err)
+		}
+		return nil
+	}
+
 	if apierrs.IsNotFound(err) {
 		sa := resources.MakeServiceAccount(ctx, vms)
 		_, err := r.kubeclient.CoreV1().ServiceAccounts(ns).Create(ctx, sa, metav1.CreateOptions{})
 		if err != nil {
-			return fmt.Errorf("failed to create serviceaccount %q: %w", name, err)
+			return newFailedEvent("ServiceAccountFailed", "failed to create serviceaccount %q: %w", name, err)
 		}
-		logging.FromContext(ctx).Infof("Created serviceaccount %q", name)
+		recordNormalEvent(ctx, vms, "ServiceAccountCreated", "Created serviceaccount %q", name)
 	} else if err != nil {
 		return fmt.Errorf("failed to get serviceaccount %q: %w", name, err)
+	} else if sa, changed := mergeServiceAccount(existing, resources.MakeServiceAccount(ctx, vms)); changed {
+		_, err := r.kubeclient.CoreV1().ServiceAccounts(ns).Update(ctx, sa, metav1.UpdateOptions{})
+		if err != nil {
+			return newFailedEvent("ServiceAccountFailed", "failed to update serviceaccount %q: %w", name, err)
+		}
+		recordNormalEvent(ctx, vms, "ServiceAccountUpdated", "Updated serviceaccount %q", name)
+	}
+
+	return nil
+}
+
+// mergeServiceAccount returns a copy of existing with the labels, annotations
+// and image pull secrets of desired applied, and whether this changed
+// existing. Labels and annotations are merged with mergeMetadata. Image pull
+// secrets which were set from the source but are no longer desired are
+// removed, others, e.g. those added by other controllers, are preserved.
+func mergeServiceAccount(existing, desired *corev1.ServiceAccount) (*corev1.ServiceAccount, bool) {
+	merged := existing.DeepCopy()
+	var changed bool
+	merged.Labels, merged.Annotations, changed = mergeMetadata(existing, desired)
+
+	for _, name := range propagatedKeys(existing, resources.PullSecretsAnnotationKey) {
+		if hasPullSecret(merged.ImagePullSecrets, name) && !hasPullSecret(desired.ImagePullSecrets, name) {
+			merged.ImagePullSecrets = removePullSecret(merged.ImagePullSecrets, name)
+			changed = true
+		}
+	}
+
+	for _, secret := range desired.ImagePullSecrets {
+		if !hasPullSecret(merged.ImagePullSecrets, secret.Name) {
+			merged.ImagePullSecrets = append(merged.ImagePullSecrets, secret)
+			changed = true
+		}
+	}
+
+	return merged, changed
+}
+
+func hasPullSecret(secrets []corev1.LocalObjectReference, name string) bool {
+	for _, secret := range secrets {
+		if secret.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func removePullSecret(secrets []corev1.LocalObjectReference, name string) []corev1.LocalObjectReference {
+	var kept []corev1.LocalObjectReference
+	for _, secret := range secrets {
+		if secret.Name != name {
+			kept = append(kept, secret)
+		}
+	}
+	return kept
+}
+
+func (r *Reconciler) reconcileRole(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) error {
+	ns := vms.Namespace
+	name := resourcenames.Role(vms)
+	role, err := r.roleLister.Roles(ns).Get(name)
+	if apierrs.IsNotFound(err) {
+		role := resources.MakeRole(ctx, vms)
+		_, err := r.kubeclient.RbacV1().Roles(ns).Create(ctx, role, metav1.CreateOptions{})
+		if err != nil {
+			return newFailedEvent("RoleFailed", "failed to create role %q: %w", name, err)
+		}
+		recordNormalEvent(ctx, vms, "RoleCreated", "Created role %q", name)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get role %q: %w", name, err)
+	}
+
+	// The role exists, but make sure that it only grants what we expect.
+	desiredRole := resources.MakeRole(ctx, vms)
+	labels, annotations, metadataChanged := mergeMetadata(role, desiredRole)
+	if metadataChanged || !equality.Semantic.DeepEqual(role.Rules, desiredRole.Rules) {
+		role = role.DeepCopy()
+		role.Labels, role.Annotations = labels, annotations
+		role.Rules = desiredRole.Rules
+		_, err := r.kubeclient.RbacV1().Roles(ns).Update(ctx, role, metav1.UpdateOptions{})
+		if err != nil {
+			return newFailedEvent("RoleFailed", "failed to update role %q: %w", name, err)
+		}
+		recordNormalEvent(ctx, vms, "RoleUpdated", "Updated role %q", name)
+	}
+
+	return nil
+}
+
+func (r *Reconciler) reconcileMetricsService(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) error {
+	ns := vms.Namespace
+	name := resourcenames.MetricsService(vms)
+	svc, err := r.serviceLister.Services(ns).Get(name)
+	if apierrs.IsNotFound(err) {
+		svc := resources.MakeMetricsService(ctx, vms, r.adapterMetricsPort)
+		_, err := r.kubeclient.CoreV1().Services(ns).Create(ctx, svc, metav1.CreateOptions{})
+		if err != nil {
+			return newFailedEvent("ServiceFailed", "failed to create service %q: %w", name, err)
+		}
+		recordNormalEvent(ctx, vms, "ServiceCreated", "Created service %q", name)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get service %q: %w", name, err)
+	}
+
+	// Only the selector and ports are managed, the cluster IP is assigned by
+	// the API server.
+	desired := resources.MakeMetricsService(ctx, vms, r.adapterMetricsPort)
+	labels, annotations, metadataChanged := mergeMetadata(svc, desired)
+	if metadataChanged || !equality.Semantic.DeepDerivative(desired.Spec.Selector, svc.Spec.Selector) ||
+		!equality.Semantic.DeepDerivative(desired.Spec.Ports, svc.Spec.Ports) {
+		svc = svc.DeepCopy()
+		svc.Labels, svc.Annotations = labels, annotations
+		svc.Spec.Selector = desired.Spec.Selector
+		svc.Spec.Ports = desired.Spec.Ports
+		_, err := r.kubeclient.CoreV1().Services(ns).Update(ctx, svc, metav1.UpdateOptions{})
+		if err != nil {
+			return newFailedEvent("ServiceFailed", "failed to update service %q: %w", name, err)
+		}
+		recordNormalEvent(ctx, vms, "ServiceUpdated", "Updated service %q", name)
+	}
+
+	return nil
+}
+
+// reconcileServiceMonitor creates the ServiceMonitor scraping the metrics
+// Service if ServiceMonitors are enabled. ServiceMonitors are not watched,
+// drift is corrected on the next resync of the source.
+func (r *Reconciler) reconcileServiceMonitor(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) error {
+	if r.serviceMonitorClient == nil {
+		return nil
+	}
+
+	ns := vms.Namespace
+	name := resourcenames.ServiceMonitor(vms)
+	desired := resources.MakeServiceMonitor(ctx, vms)
+	sm, err := r.serviceMonitorClient.Namespace(ns).Get(ctx, name, metav1.GetOptions{})
+	if apierrs.IsNotFound(err) {
+		_, err := r.serviceMonitorClient.Namespace(ns).Create(ctx, desired, metav1.CreateOptions{})
+		if err != nil {
+			return newFailedEvent("ServiceMonitorFailed", "failed to create service monitor %q: %w", name, err)
+		}
+		recordNormalEvent(ctx, vms, "ServiceMonitorCreated", "Created service monitor %q", name)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get service monitor %q: %w", name, err)
+	}
+
+	labels, annotations, metadataChanged := mergeMetadata(sm, desired)
+	if metadataChanged || !equality.Semantic.DeepDerivative(desired.Object["spec"], sm.Object["spec"]) {
+		sm = sm.DeepCopy()
+		sm.SetLabels(labels)
+		sm.SetAnnotations(annotations)
+		sm.Object["spec"] = desired.Object["spec"]
+		_, err := r.serviceMonitorClient.Namespace(ns).Update(ctx, sm, metav1.UpdateOptions{})
+		if err != nil {
+			return newFailedEvent("ServiceMonitorFailed", "failed to update service monitor %q: %w", name, err)
+		}
+		recordNormalEvent(ctx, vms, "ServiceMonitorUpdated", "Updated service monitor %q", name)
+	}
+
+	return nil
+}
+
+// reconcileEventTypes registers the EventTypes of the source and prunes the
+// ones of event types which are no longer configured.
+func (r *Reconciler) reconcileEventTypes(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) error {
+	ns := vms.Namespace
+	existing, err := r.eventTypeLister.EventTypes(ns).List(labels.SelectorFromSet(resources.Labels(vms)))
+	if err != nil {
+		return fmt.Errorf("failed to list eventtypes: %w", err)
+	}
+	// the lister order is random, sort for deterministic events
+	sort.Slice(existing, func(i, j int) bool {
+		return existing[i].Name < existing[j].Name
+	})
+
+	eventTypes := resources.MakeEventTypes(ctx, vms)
+	desired := make(map[string]*eventingv1beta1.EventType, len(eventTypes))
+	for _, et := range eventTypes {
+		desired[et.Name] = et
+	}
+
+	for _, et := range existing {
+		if !metav1.IsControlledBy(et, vms) {
+			continue
+		}
+
+		want, ok := desired[et.Name]
+		if !ok {
+			err := r.eventingclient.EventingV1beta1().EventTypes(ns).Delete(ctx, et.Name, metav1.DeleteOptions{})
+			if err != nil && !apierrs.IsNotFound(err) {
+				return newFailedEvent("EventTypeFailed", "failed to delete eventtype %q: %w", et.Name, err)
+			}
+			recordNormalEvent(ctx, vms, "EventTypeDeleted", "Deleted eventtype %q", et.Name)
+			continue
+		}
+		// the EventType exists, only its metadata and spec have to be kept up
+		// to date
+		delete(desired, et.Name)
+
+		labels, annotations, metadataChanged := mergeMetadata(et, want)
+		if metadataChanged || !equality.Semantic.DeepEqual(want.Spec, et.Spec) {
+			et = et.DeepCopy()
+			et.Labels, et.Annotations = labels, annotations
+			et.Spec = want.Spec
+			_, err := r.eventingclient.EventingV1beta1().EventTypes(ns).Update(ctx, et, metav1.UpdateOptions{})
+			if err != nil {
+				return newFailedEvent("EventTypeFailed", "failed to update eventtype %q: %w", et.Name, err)
+			}
+			recordNormalEvent(ctx, vms, "EventTypeUpdated", "Updated eventtype %q", et.Name)
+		}
+	}
+
+	for _, et := range eventTypes {
+		if _, ok := desired[et.Name]; !ok {
+			continue
+		}
+		_, err := r.eventingclient.EventingV1beta1().EventTypes(ns).Create(ctx, et, metav1.CreateOptions{})
+		if err != nil {
+			return newFailedEvent("EventTypeFailed", "failed to create eventtype %q: %w", et.Name, err)
+		}
+		recordNormalEvent(ctx, vms, "EventTypeCreated", "Created eventtype %q", et.Name)
 	}
 
 	return nil
@@ -166,18 +964,44 @@ func (r *Reconciler) reconcileServiceAccount(ctx context.Context, vms *sourcesv1
 func (r *Reconciler) reconcileRoleBinding(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) error {
 	ns := vms.Namespace
 	name := resourcenames.RoleBinding(vms)
-	_, err := r.rbacLister.RoleBindings(ns).Get(name)
+	roleBinding, err := r.rbacLister.RoleBindings(ns).Get(name)
 	if apierrs.IsNotFound(err) {
 		roleBinding := resources.MakeRoleBinding(ctx, vms)
 		_, err := r.kubeclient.RbacV1().RoleBindings(ns).Create(ctx, roleBinding, metav1.CreateOptions{})
 		if err != nil {
-			return fmt.Errorf("failed to create rolebinding %q: %w", name, err)
+			return newFailedEvent("RoleBindingFailed", "failed to create rolebinding %q: %w", name, err)
 		}
-		logging.FromContext(ctx).Infof("Created rolebinding %q", name)
+		recordNormalEvent(ctx, vms, "RoleBindingCreated", "Created rolebinding %q", name)
+		return nil
 	} else if err != nil {
 		return fmt.Errorf("failed to get rolebinding %q: %w", name, err)
 	}
-	// TODO: diff the roleref / subjects and update as necessary.
+
+	// The rolebinding exists, but make sure that it has the shape that we expect.
+	desiredRoleBinding := resources.MakeRoleBinding(ctx, vms)
+	if !equality.Semantic.DeepEqual(roleBinding.RoleRef, desiredRoleBinding.RoleRef) {
+		// RoleRef is immutable, so the rolebinding has to be recreated.
+		err := r.kubeclient.RbacV1().RoleBindings(ns).Delete(ctx, name, metav1.DeleteOptions{})
+		if err != nil && !apierrs.IsNotFound(err) {
+			return newFailedEvent("RoleBindingFailed", "failed to delete rolebinding %q: %w", name, err)
+		}
+		_, err = r.kubeclient.RbacV1().RoleBindings(ns).Create(ctx, desiredRoleBinding, metav1.CreateOptions{})
+		if err != nil {
+			return newFailedEvent("RoleBindingFailed", "failed to create rolebinding %q: %w", name, err)
+		}
+		recordNormalEvent(ctx, vms, "RoleBindingRecreated", "Recreated rolebinding %q", name)
+	} else if labels, annotations, changed := mergeMetadata(roleBinding, desiredRoleBinding); changed ||
+		!equality.Semantic.DeepEqual(roleBinding.Subjects, desiredRoleBinding.Subjects) {
+		roleBinding = roleBinding.DeepCopy()
+		roleBinding.Labels, roleBinding.Annotations = labels, annotations
+		roleBinding.Subjects = desiredRoleBinding.Subjects
+		_, err := r.kubeclient.RbacV1().RoleBindings(ns).Update(ctx, roleBinding, metav1.UpdateOptions{})
+		if err != nil {
+			return newFailedEvent("RoleBindingFailed", "failed to update rolebinding %q: %w", name, err)
+		}
+		recordNormalEvent(ctx, vms, "RoleBindingUpdated", "Updated rolebinding %q", name)
+	}
+
 	return nil
 }
 
@@ -195,48 +1019,263 @@ func (r *Reconciler) reconcileDeployment(ctx context.Context, vms *sourcesv1alph
 		return fmt.Errorf("marshal metrics config to JSON: %w", err)
 	}
 
+	tracingConfig, err := tracingconfig.TracingConfigToJSON(r.tracingConfig)
+	if err != nil {
+		return fmt.Errorf("marshal tracing config to JSON: %w", err)
+	}
+
+	var eventFilters string
+	if len(vms.Spec.EventFilters) > 0 {
+		ef, err := json.Marshal(vms.Spec.EventFilters)
+		if err != nil {
+			return fmt.Errorf("marshal event filters to JSON: %w", err)
+		}
+		eventFilters = string(ef)
+	}
+
+	image := r.adapterImage
+	if vms.Spec.AdapterImage != "" {
+		if r.adapterImageOverride {
+			image = vms.Spec.AdapterImage
+		} else {
+			controller.GetEventRecorder(ctx).Eventf(vms, corev1.EventTypeWarning, "AdapterImageIgnored",
+				"Ignoring adapter image %q: the %s feature is disabled", vms.Spec.AdapterImage, adapterImageOverrideKey)
+		}
+	}
+
+	caBundleHash, err := r.caBundleHash(vms)
+	if err != nil {
+		return err
+	}
+
+	credentialsHash, err := r.credentialsHash(ctx, vms)
+	if err != nil {
+		return err
+	}
+
 	args := resources.AdapterArgs{
-		Image:         r.adapterImage,
-		LoggingConfig: loggingConfig,
-		MetricsConfig: metricsConfig,
+		CABundleHash:      caBundleHash,
+		CredentialsHash:   credentialsHash,
+		Image:             image,
+		LoggingConfig:     loggingConfig,
+		LogLevel:          vms.Spec.LogLevel,
+		MetricsConfig:     metricsConfig,
+		TracingConfig:     tracingConfig,
+		EventFilters:      eventFilters,
+		Resources:         r.defaultAdapterResources(),
+		HealthPort:        vsphere.DefaultHealthPort,
+		MetricsPort:       r.adapterMetricsPort,
+		PrometheusScrape:  r.prometheusMetrics(),
+		MaxEventAge:       time.Duration(vms.Spec.MaxEventAgeSeconds) * time.Second,
+		FailoverAddresses: vms.Spec.FailoverAddresses,
 	}
 
 	deployment, err := r.deploymentLister.Deployments(ns).Get(deploymentName)
 	if apierrs.IsNotFound(err) {
 		deployment, err = resources.MakeDeployment(ctx, vms, args)
 		if err != nil {
-			return fmt.Errorf("failed to create deployment %q: %w", deploymentName, err)
+			return newFailedEvent("DeploymentFailed", "failed to create deployment %q: %w", deploymentName, err)
 		}
 
 		deployment, err = r.kubeclient.AppsV1().Deployments(ns).Create(ctx, deployment, metav1.CreateOptions{})
 		if err != nil {
-			return fmt.Errorf("failed to create deployment %q: %w", deploymentName, err)
+			return newFailedEvent("DeploymentFailed", "failed to create deployment %q: %w", deploymentName, err)
 		}
-		logging.FromContext(ctx).Infof("Created deployment %q", deploymentName)
+		recordNormalEvent(ctx, vms, "DeploymentCreated", "Created deployment %q", deploymentName)
 	} else if err != nil {
 		return fmt.Errorf("failed to get deployment %q: %w", deploymentName, err)
 	} else {
 		// The deployment exists, but make sure that it has the shape that we expect.
 		desiredDeployment, err := resources.MakeDeployment(ctx, vms, args)
 		if err != nil {
-			return fmt.Errorf("failed to create deployment %q: %w", deploymentName, err)
+			return newFailedEvent("DeploymentFailed", "failed to create deployment %q: %w", deploymentName, err)
 		}
 
-		deployment = deployment.DeepCopy()
-		deployment.Spec = desiredDeployment.Spec
-		deployment, err = r.kubeclient.AppsV1().Deployments(ns).Update(ctx, deployment, metav1.UpdateOptions{})
-		if err != nil {
-			return fmt.Errorf("failed to create deployment %q: %w", deploymentName, err)
+		labels, annotations, metadataChanged := mergeMetadata(deployment, desiredDeployment)
+		// the pod template is replaced as a whole, merging its metadata only
+		// reveals labels and annotations removed from the source
+		_, _, podMetadataChanged := mergeMetadata(&deployment.Spec.Template, &desiredDeployment.Spec.Template)
+
+		// Fields left empty in the desired spec are defaulted by the API server
+		// and must not trigger an update. The image pull secrets are not
+		// defaulted, so removing all of them must.
+		if metadataChanged || podMetadataChanged ||
+			!equality.Semantic.DeepDerivative(desiredDeployment.Spec, deployment.Spec) ||
+			!equality.Semantic.DeepEqual(desiredDeployment.Spec.Template.Spec.ImagePullSecrets,
+				deployment.Spec.Template.Spec.ImagePullSecrets) {
+			deployment = deployment.DeepCopy()
+			deployment.Labels, deployment.Annotations = labels, annotations
+			deployment.Spec = desiredDeployment.Spec
+			deployment, err = r.kubeclient.AppsV1().Deployments(ns).Update(ctx, deployment, metav1.UpdateOptions{})
+			if err != nil {
+				return newFailedEvent("DeploymentFailed", "failed to update deployment %q: %w", deploymentName, err)
+			}
+			recordNormalEvent(ctx, vms, "DeploymentUpdated", "Updated deployment %q", deploymentName)
 		}
-		logging.FromContext(ctx).Infof("Updated deployment %q", deploymentName)
+	}
+
+	// the pods reveal a crash-looping adapter, which the deployment status
+	// only reports once its progress deadline is exceeded
+	pods, err := r.podLister.Pods(ns).List(labels.SelectorFromSet(resources.Labels(vms)))
+	if err != nil {
+		return fmt.Errorf("failed to list adapter pods: %w", err)
 	}
 
 	// Reflect the state of the Adapter Deployment in the VSphereSource
-	vms.Status.PropagateAdapterStatus(deployment.Status)
+	if vms.Spec.Paused {
+		vms.Status.MarkAdapterPaused()
+	} else {
+		vms.Status.PropagateAdapterStatus(deployment.Status, pods...)
+	}
 
 	return nil
 }
 
+// checkVCenterAllowed returns an error if the source or one of its failover
+// addresses points to a vCenter which is not allowed by the config-vsphere
+// ConfigMap. The adapter of such a source is deleted, so that a source
+// created before the vCenter was disallowed stops receiving events.
+func (r *Reconciler) checkVCenterAllowed(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) error {
+	cfg := r.currentConfig()
+
+	hosts := []string{vms.Spec.Address.URL().Hostname()}
+	for _, address := range vms.Spec.FailoverAddresses {
+		// the addresses are validated by the webhook
+		if u, err := url.Parse(address); err == nil {
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+
+	for _, host := range hosts {
+		if cfg.VCenterHostAllowed(host) {
+			continue
+		}
+
+		vms.Status.MarkVCenterNotAllowed(host)
+
+		name := resourcenames.Deployment(vms)
+		if _, err := r.deploymentLister.Deployments(vms.Namespace).Get(name); err == nil {
+			err = r.kubeclient.AppsV1().Deployments(vms.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+			if err != nil && !apierrs.IsNotFound(err) {
+				return newFailedEvent("DeploymentFailed", "failed to delete deployment %q: %w", name, err)
+			}
+			recordNormalEvent(ctx, vms, "DeploymentDeleted", "Deleted deployment %q", name)
+		}
+
+		return newFailedEvent("VCenterNotAllowed", "vCenter %q is not allowed by the %s ConfigMap", host, config.VSphereConfigName)
+	}
+	return nil
+}
+
+// currentConfig returns the cluster-wide configuration of the sources.
+func (r *Reconciler) currentConfig() *config.VSphere {
+	if r.vsphereConfig == nil {
+		return config.NewVSphereConfig()
+	}
+	return r.vsphereConfig
+}
+
+// defaultAdapterResources returns the default compute resources of the
+// adapters. The quantities set in the config-vsphere ConfigMap take precedence
+// over the ones set via flags.
+func (r *Reconciler) defaultAdapterResources() corev1.ResourceRequirements {
+	res := *r.adapterResources.DeepCopy()
+	defaults := r.currentConfig().DefaultAdapterResources
+
+	for _, l := range []struct {
+		from corev1.ResourceList
+		to   *corev1.ResourceList
+	}{
+		{defaults.Requests, &res.Requests},
+		{defaults.Limits, &res.Limits},
+	} {
+		for name, quantity := range l.from {
+			if *l.to == nil {
+				*l.to = corev1.ResourceList{}
+			}
+			(*l.to)[name] = quantity
+		}
+	}
+	return res
+}
+
+// caBundleHash returns the hash of the vCenter CA bundle of the given source,
+// or an empty string if none is configured. The hash rolls out the adapter
+// when the content of the CA bundle ConfigMap changes.
+func (r *Reconciler) caBundleHash(vms *sourcesv1alpha1.VSphereSource) (string, error) {
+	cab := vms.Spec.CABundle
+	if cab == nil {
+		return "", nil
+	}
+
+	ref := tracker.Reference{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Namespace:  vms.Namespace,
+		Name:       cab.Name,
+	}
+	if err := r.tracker.TrackReference(ref, vms); err != nil {
+		return "", fmt.Errorf("failed to track CA bundle configmap %q: %w", cab.Name, err)
+	}
+
+	cm, err := r.cmLister.ConfigMaps(vms.Namespace).Get(cab.Name)
+	if apierrs.IsNotFound(err) && cab.Optional != nil && *cab.Optional {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get CA bundle configmap %q: %w", cab.Name, err)
+	}
+
+	sum := sha256.Sum256([]byte(cm.Data[cab.Key]))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// credentialsHash returns the hash of the vCenter credentials of the given
+// source, or an empty string if the credentials Secret does not exist yet. The
+// hash rolls out the adapter when the credentials are rotated, which the
+// adapter only reads on startup.
+func (r *Reconciler) credentialsHash(ctx context.Context, vms *sourcesv1alpha1.VSphereSource) (string, error) {
+	name := vms.Spec.SecretRef.Name
+	if name == "" {
+		return "", nil
+	}
+
+	ref := tracker.Reference{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Namespace:  vms.Namespace,
+		Name:       name,
+	}
+	if err := r.tracker.TrackReference(ref, vms); err != nil {
+		return "", fmt.Errorf("failed to track credentials secret %q: %w", name, err)
+	}
+	if r.secretWatches != nil {
+		r.secretWatches.watch(types.NamespacedName{Namespace: vms.Namespace, Name: vms.Name}, name)
+	}
+
+	// the Secret is read from the API server, only the credentials Secrets
+	// of the sources are watched and no Secrets are cached
+	secret, err := r.kubeclient.CoreV1().Secrets(vms.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrs.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get credentials secret %q: %w", name, err)
+	}
+
+	auth := vms.Spec.VAuthSpec
+	auth.SetDefaults(ctx)
+
+	h := sha256.New()
+	for _, key := range []string{auth.UsernameKey, auth.PasswordKey, auth.CACertKey} {
+		if key == "" {
+			continue
+		}
+		// length prefixes keep the boundaries of the keys and values
+		fmt.Fprintf(h, "%d:%s%d:", len(key), key, len(secret.Data[key]))
+		h.Write(secret.Data[key])
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 func (r *Reconciler) UpdateFromLoggingConfigMap(cfg *corev1.ConfigMap) {
 	if cfg != nil {
 		delete(cfg.Data, "_example")
@@ -258,9 +1297,69 @@ func (r *Reconciler) UpdateFromMetricsConfigMap(cfg *corev1.ConfigMap) {
 	}
 
 	r.metricsConfig = &metrics.ExporterOptions{
-		Domain:    metrics.Domain(),
-		Component: component,
-		ConfigMap: cfg.Data,
+		Domain:         metrics.Domain(),
+		Component:      component,
+		PrometheusPort: r.adapterMetricsPort,
+		ConfigMap:      cfg.Data,
 	}
 	logging.FromContext(r.loggingContext).Info("update from metrics ConfigMap", zap.Any("ConfigMap", cfg))
 }
+
+// prometheusMetrics returns true if the adapter exports its metrics with the
+// Prometheus backend, the default backend of the knative metrics exporter.
+func (r *Reconciler) prometheusMetrics() bool {
+	if r.metricsConfig == nil {
+		return true
+	}
+	backend, ok := r.metricsConfig.ConfigMap[metrics.BackendDestinationKey]
+	return !ok || strings.EqualFold(backend, "prometheus")
+}
+
+// UpdateFromFeaturesConfigMap updates the feature flags of the reconciler.
+// Features are disabled unless enabled in the ConfigMap.
+func (r *Reconciler) UpdateFromFeaturesConfigMap(cfg *corev1.ConfigMap) {
+	adapterImageOverride := false
+	if cfg != nil {
+		switch v := cfg.Data[adapterImageOverrideKey]; strings.ToLower(v) {
+		case "", "disabled":
+		case "enabled":
+			adapterImageOverride = true
+		default:
+			logging.FromContext(r.loggingContext).Warnw("invalid feature flag, keeping the current value",
+				zap.String("feature", adapterImageOverrideKey), zap.String("value", v))
+			return
+		}
+	}
+
+	r.adapterImageOverride = adapterImageOverride
+	logging.FromContext(r.loggingContext).Info("update from features ConfigMap", zap.Any("ConfigMap", cfg))
+}
+
+// UpdateFromVSphereConfigMap updates the cluster-wide defaults of the
+// sources. An invalid ConfigMap keeps the current defaults.
+func (r *Reconciler) UpdateFromVSphereConfigMap(cfg *corev1.ConfigMap) {
+	vsphereCfg, err := config.NewVSphereConfigFromConfigMap(cfg)
+	if err != nil {
+		logging.FromContext(r.loggingContext).Warnw("invalid vsphere config, keeping the current defaults",
+			zap.String("cfg.Name", cfg.Name), zap.Error(err))
+		return
+	}
+
+	r.vsphereConfig = vsphereCfg
+	logging.FromContext(r.loggingContext).Info("update from vsphere ConfigMap", zap.Any("ConfigMap", cfg))
+}
+
+func (r *Reconciler) UpdateFromTracingConfigMap(cfg *corev1.ConfigMap) {
+	if cfg != nil {
+		delete(cfg.Data, "_example")
+	}
+
+	tracingcfg, err := tracingconfig.NewTracingConfigFromConfigMap(cfg)
+	if err != nil {
+		logging.FromContext(r.loggingContext).Warn("failed to create tracing config from configmap", zap.String("cfg.Name", cfg.Name))
+		return
+	}
+
+	r.tracingConfig = tracingcfg
+	logging.FromContext(r.loggingContext).Info("update from tracing ConfigMap", zap.Any("ConfigMap", cfg))
+}
@@ -0,0 +1,2978 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vspheresource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8sscheme "k8s.io/client-go/kubernetes/scheme"
+	appsv1listers "k8s.io/client-go/listers/apps/v1"
+	coordinationv1listers "k8s.io/client-go/listers/coordination/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	rbacv1listers "k8s.io/client-go/listers/rbac/v1"
+	clientgotesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	eventingv1beta1 "knative.dev/eventing/pkg/apis/eventing/v1beta1"
+	eventingfake "knative.dev/eventing/pkg/client/clientset/versioned/fake"
+	eventingv1beta1listers "knative.dev/eventing/pkg/client/listers/eventing/v1beta1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/pkg/client/injection/ducks/duck/v1/addressable"
+	"knative.dev/pkg/controller"
+	fakedynamicclient "knative.dev/pkg/injection/clients/dynamicclient/fake"
+	"knative.dev/pkg/metrics"
+	"knative.dev/pkg/ptr"
+	"knative.dev/pkg/resolver"
+	"knative.dev/pkg/tracker"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/config"
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
+	fakeclientset "github.com/vmware-tanzu/sources-for-knative/pkg/client/clientset/versioned/fake"
+	v1alpha1lister "github.com/vmware-tanzu/sources-for-knative/pkg/client/listers/sources/v1alpha1"
+	"github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspheresource/resources"
+	resourcenames "github.com/vmware-tanzu/sources-for-knative/pkg/reconciler/vspheresource/resources/names"
+	"github.com/vmware-tanzu/sources-for-knative/pkg/vsphere"
+)
+
+func newTestSource() *v1alpha1.VSphereSource {
+	return &v1alpha1.VSphereSource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "source",
+			Namespace: "ns",
+			UID:       "1234",
+		},
+	}
+}
+
+func TestReconcileRole(t *testing.T) {
+	vms := newTestSource()
+	desired := resources.MakeRole(context.Background(), vms)
+
+	broadRule := desired.DeepCopy()
+	broadRule.Rules[0].ResourceNames = nil
+
+	extraRule := desired.DeepCopy()
+	extraRule.Rules = append(extraRule.Rules, rbacv1.PolicyRule{
+		APIGroups: []string{""},
+		Resources: []string{"secrets"},
+		Verbs:     []string{"get"},
+	})
+
+	tests := []struct {
+		name              string
+		failoverAddresses []string
+		existing          *rbacv1.Role
+		wantVerbs         []string
+		wantEvents        []string
+	}{
+		{
+			name:       "role does not exist",
+			existing:   nil,
+			wantVerbs:  []string{"create"},
+			wantEvents: []string{`Normal RoleCreated Created role "source-role"`},
+		},
+		{
+			name:       "role up to date",
+			existing:   desired.DeepCopy(),
+			wantVerbs:  nil,
+			wantEvents: nil,
+		},
+		{
+			name:       "rule without resource names",
+			existing:   broadRule,
+			wantVerbs:  []string{"update"},
+			wantEvents: []string{`Normal RoleUpdated Updated role "source-role"`},
+		},
+		{
+			name:       "extra rule",
+			existing:   extraRule,
+			wantVerbs:  []string{"update"},
+			wantEvents: []string{`Normal RoleUpdated Updated role "source-role"`},
+		},
+		{
+			name:              "failover events rule missing",
+			failoverAddresses: []string{"https://vcenter-standby.corp.local"},
+			existing:          desired.DeepCopy(),
+			wantVerbs:         []string{"update"},
+			wantEvents:        []string{`Normal RoleUpdated Updated role "source-role"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			ctx := controller.WithEventRecorder(context.Background(), recorder)
+
+			var objs []runtime.Object
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if tt.existing != nil {
+				objs = append(objs, tt.existing)
+				if err := indexer.Add(tt.existing); err != nil {
+					t.Fatalf("add role to indexer: %v", err)
+				}
+			}
+
+			kc := fake.NewSimpleClientset(objs...)
+			r := &Reconciler{
+				kubeclient: kc,
+				roleLister: rbacv1listers.NewRoleLister(indexer),
+			}
+
+			vms := vms.DeepCopy()
+			vms.Spec.FailoverAddresses = tt.failoverAddresses
+			if err := r.reconcileRole(ctx, vms); err != nil {
+				t.Fatalf("reconcileRole() error = %v", err)
+			}
+
+			var gotVerbs []string
+			for _, action := range kc.Actions() {
+				gotVerbs = append(gotVerbs, action.GetVerb())
+			}
+			if diff := cmp.Diff(tt.wantVerbs, gotVerbs); diff != "" {
+				t.Errorf("reconcileRole() unexpected actions (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantEvents, recordedEvents(recorder)); diff != "" {
+				t.Errorf("reconcileRole() unexpected events (-want, +got) = %v", diff)
+			}
+
+			got, err := kc.RbacV1().Roles(vms.Namespace).Get(ctx, resourcenames.Role(vms), metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("get role: %v", err)
+			}
+
+			want := resources.MakeRole(ctx, vms)
+			if diff := cmp.Diff(want.Rules, got.Rules); diff != "" {
+				t.Errorf("reconcileRole() unexpected rules (-want, +got) = %v", diff)
+			}
+			if len(got.OwnerReferences) != 1 || got.OwnerReferences[0].UID != vms.UID {
+				t.Errorf("reconcileRole() unexpected owner references = %v", got.OwnerReferences)
+			}
+		})
+	}
+}
+
+func TestReconcileMetricsService(t *testing.T) {
+	vms := newTestSource()
+	desired := resources.MakeMetricsService(context.Background(), vms, 0)
+
+	// the API server assigns the cluster IP
+	assigned := desired.DeepCopy()
+	assigned.Spec.ClusterIP = "10.0.0.10"
+
+	portDrift := assigned.DeepCopy()
+	portDrift.Spec.Ports[0].Port = 8080
+
+	selectorDrift := assigned.DeepCopy()
+	selectorDrift.Spec.Selector = map[string]string{"app": "other"}
+
+	tests := []struct {
+		name       string
+		existing   *corev1.Service
+		wantVerbs  []string
+		wantEvents []string
+	}{
+		{
+			name:       "service does not exist",
+			existing:   nil,
+			wantVerbs:  []string{"create"},
+			wantEvents: []string{`Normal ServiceCreated Created service "source-metrics"`},
+		},
+		{
+			name:       "service up to date",
+			existing:   assigned,
+			wantVerbs:  nil,
+			wantEvents: nil,
+		},
+		{
+			name:       "port drift",
+			existing:   portDrift,
+			wantVerbs:  []string{"update"},
+			wantEvents: []string{`Normal ServiceUpdated Updated service "source-metrics"`},
+		},
+		{
+			name:       "selector drift",
+			existing:   selectorDrift,
+			wantVerbs:  []string{"update"},
+			wantEvents: []string{`Normal ServiceUpdated Updated service "source-metrics"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			ctx := controller.WithEventRecorder(context.Background(), recorder)
+
+			var objs []runtime.Object
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if tt.existing != nil {
+				objs = append(objs, tt.existing)
+				if err := indexer.Add(tt.existing); err != nil {
+					t.Fatalf("add service to indexer: %v", err)
+				}
+			}
+
+			kc := fake.NewSimpleClientset(objs...)
+			r := &Reconciler{
+				kubeclient:    kc,
+				serviceLister: corev1listers.NewServiceLister(indexer),
+			}
+
+			if err := r.reconcileMetricsService(ctx, vms); err != nil {
+				t.Fatalf("reconcileMetricsService() error = %v", err)
+			}
+
+			var gotVerbs []string
+			for _, action := range kc.Actions() {
+				gotVerbs = append(gotVerbs, action.GetVerb())
+			}
+			if diff := cmp.Diff(tt.wantVerbs, gotVerbs); diff != "" {
+				t.Errorf("reconcileMetricsService() unexpected actions (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantEvents, recordedEvents(recorder)); diff != "" {
+				t.Errorf("reconcileMetricsService() unexpected events (-want, +got) = %v", diff)
+			}
+
+			got, err := kc.CoreV1().Services(vms.Namespace).Get(ctx, resourcenames.MetricsService(vms), metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("get service: %v", err)
+			}
+
+			if diff := cmp.Diff(desired.Spec.Ports, got.Spec.Ports); diff != "" {
+				t.Errorf("reconcileMetricsService() unexpected ports (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(desired.Spec.Selector, got.Spec.Selector); diff != "" {
+				t.Errorf("reconcileMetricsService() unexpected selector (-want, +got) = %v", diff)
+			}
+			if tt.existing != nil && got.Spec.ClusterIP != tt.existing.Spec.ClusterIP {
+				t.Errorf("reconcileMetricsService() cluster IP = %q, want %q", got.Spec.ClusterIP, tt.existing.Spec.ClusterIP)
+			}
+		})
+	}
+}
+
+func TestReconcileServiceMonitor(t *testing.T) {
+	vms := newTestSource()
+	desired := resources.MakeServiceMonitor(context.Background(), vms)
+
+	// the Prometheus Operator does not default the spec
+	existing := desired.DeepCopy()
+	existing.SetResourceVersion("1")
+
+	portDrift := existing.DeepCopy()
+	if err := unstructured.SetNestedSlice(portDrift.Object, []interface{}{
+		map[string]interface{}{"port": "web"},
+	}, "spec", "endpoints"); err != nil {
+		t.Fatalf("set endpoints: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		disabled   bool
+		existing   *unstructured.Unstructured
+		wantVerbs  []string
+		wantEvents []string
+	}{
+		{
+			name:      "disabled",
+			disabled:  true,
+			wantVerbs: nil,
+		},
+		{
+			name:       "service monitor does not exist",
+			existing:   nil,
+			wantVerbs:  []string{"get", "create"},
+			wantEvents: []string{`Normal ServiceMonitorCreated Created service monitor "source-metrics"`},
+		},
+		{
+			name:      "service monitor up to date",
+			existing:  existing,
+			wantVerbs: []string{"get"},
+		},
+		{
+			name:       "endpoint drift",
+			existing:   portDrift,
+			wantVerbs:  []string{"get", "update"},
+			wantEvents: []string{`Normal ServiceMonitorUpdated Updated service monitor "source-metrics"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			ctx := controller.WithEventRecorder(context.Background(), recorder)
+
+			var objs []runtime.Object
+			if tt.existing != nil {
+				objs = append(objs, tt.existing)
+			}
+			dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{resources.ServiceMonitorGVR: "ServiceMonitorList"}, objs...)
+
+			r := &Reconciler{}
+			if !tt.disabled {
+				r.serviceMonitorClient = dc.Resource(resources.ServiceMonitorGVR)
+			}
+
+			if err := r.reconcileServiceMonitor(ctx, vms); err != nil {
+				t.Fatalf("reconcileServiceMonitor() error = %v", err)
+			}
+
+			var gotVerbs []string
+			for _, action := range dc.Actions() {
+				gotVerbs = append(gotVerbs, action.GetVerb())
+			}
+			if diff := cmp.Diff(tt.wantVerbs, gotVerbs); diff != "" {
+				t.Errorf("reconcileServiceMonitor() unexpected actions (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantEvents, recordedEvents(recorder)); diff != "" {
+				t.Errorf("reconcileServiceMonitor() unexpected events (-want, +got) = %v", diff)
+			}
+			if tt.disabled {
+				return
+			}
+
+			got, err := r.serviceMonitorClient.Namespace(vms.Namespace).Get(ctx, resourcenames.ServiceMonitor(vms), metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("get service monitor: %v", err)
+			}
+			if diff := cmp.Diff(desired.Object["spec"], got.Object["spec"]); diff != "" {
+				t.Errorf("reconcileServiceMonitor() unexpected spec (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func Test_serviceMonitorsAvailable(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []*metav1.APIResourceList
+		want      bool
+	}{
+		{
+			name: "not installed",
+			resources: []*metav1.APIResourceList{{
+				GroupVersion: "v1",
+				APIResources: []metav1.APIResource{{Name: "services"}},
+			}},
+			want: false,
+		},
+		{
+			name: "other resources of the group",
+			resources: []*metav1.APIResourceList{{
+				GroupVersion: "monitoring.coreos.com/v1",
+				APIResources: []metav1.APIResource{{Name: "podmonitors"}},
+			}},
+			want: false,
+		},
+		{
+			name: "installed",
+			resources: []*metav1.APIResourceList{{
+				GroupVersion: "monitoring.coreos.com/v1",
+				APIResources: []metav1.APIResource{{Name: "podmonitors"}, {Name: "servicemonitors"}},
+			}},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kc := fake.NewSimpleClientset()
+			kc.Resources = tt.resources
+
+			got, err := serviceMonitorsAvailable(kc.Discovery())
+			if err != nil {
+				t.Fatalf("serviceMonitorsAvailable() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("serviceMonitorsAvailable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileEventTypes(t *testing.T) {
+	newSource := func(autoCreate bool, eventTypes ...string) *v1alpha1.VSphereSource {
+		vms := newTestSource()
+		vms.Spec.Sink = duckv1.Destination{
+			Ref: &duckv1.KReference{
+				APIVersion: "eventing.knative.dev/v1",
+				Kind:       "Broker",
+				Name:       "default",
+			},
+		}
+		vms.Spec.Address = apis.URL{Scheme: "https", Host: "vcenter.example.com"}
+		vms.Spec.EventTypeAutoCreate = autoCreate
+		vms.Spec.EventTypes = eventTypes
+		return vms
+	}
+
+	vms := newSource(true, "VmPoweredOnEvent", "VmPoweredOffEvent")
+	desired := resources.MakeEventTypes(context.Background(), vms)
+
+	stale := resources.MakeEventTypes(context.Background(), newSource(true, "VmRemovedEvent"))[0]
+
+	brokerDrift := desired[0].DeepCopy()
+	brokerDrift.Spec.Broker = "other"
+
+	notOwned := stale.DeepCopy()
+	notOwned.OwnerReferences = nil
+
+	tests := []struct {
+		name       string
+		vms        *v1alpha1.VSphereSource
+		existing   []*eventingv1beta1.EventType
+		wantVerbs  []string
+		wantEvents []string
+	}{
+		{
+			name:      "eventtypes do not exist",
+			vms:       vms,
+			wantVerbs: []string{"create", "create"},
+			wantEvents: []string{
+				`Normal EventTypeCreated Created eventtype "source-vmpoweredonevent"`,
+				`Normal EventTypeCreated Created eventtype "source-vmpoweredoffevent"`,
+			},
+		},
+		{
+			name:     "eventtypes up to date",
+			vms:      vms,
+			existing: []*eventingv1beta1.EventType{desired[0].DeepCopy(), desired[1].DeepCopy()},
+		},
+		{
+			name:      "eventtype spec drift",
+			vms:       vms,
+			existing:  []*eventingv1beta1.EventType{brokerDrift, desired[1].DeepCopy()},
+			wantVerbs: []string{"update"},
+			wantEvents: []string{
+				`Normal EventTypeUpdated Updated eventtype "source-vmpoweredonevent"`,
+			},
+		},
+		{
+			name:      "event type removed from filter",
+			vms:       vms,
+			existing:  []*eventingv1beta1.EventType{desired[0].DeepCopy(), desired[1].DeepCopy(), stale.DeepCopy()},
+			wantVerbs: []string{"delete"},
+			wantEvents: []string{
+				`Normal EventTypeDeleted Deleted eventtype "source-vmremovedevent"`,
+			},
+		},
+		{
+			name:      "auto creation disabled",
+			vms:       newSource(false, "VmPoweredOnEvent", "VmPoweredOffEvent"),
+			existing:  []*eventingv1beta1.EventType{desired[0].DeepCopy(), desired[1].DeepCopy()},
+			wantVerbs: []string{"delete", "delete"},
+			wantEvents: []string{
+				`Normal EventTypeDeleted Deleted eventtype "source-vmpoweredoffevent"`,
+				`Normal EventTypeDeleted Deleted eventtype "source-vmpoweredonevent"`,
+			},
+		},
+		{
+			name:     "eventtype not owned by the source",
+			vms:      newSource(false),
+			existing: []*eventingv1beta1.EventType{notOwned},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			ctx := controller.WithEventRecorder(context.Background(), recorder)
+
+			var objs []runtime.Object
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			for _, et := range tt.existing {
+				objs = append(objs, et)
+				if err := indexer.Add(et); err != nil {
+					t.Fatalf("add eventtype to indexer: %v", err)
+				}
+			}
+
+			ec := eventingfake.NewSimpleClientset(objs...)
+			r := &Reconciler{
+				eventingclient:  ec,
+				eventTypeLister: eventingv1beta1listers.NewEventTypeLister(indexer),
+			}
+
+			if err := r.reconcileEventTypes(ctx, tt.vms); err != nil {
+				t.Fatalf("reconcileEventTypes() error = %v", err)
+			}
+
+			var gotVerbs []string
+			for _, action := range ec.Actions() {
+				gotVerbs = append(gotVerbs, action.GetVerb())
+			}
+			if diff := cmp.Diff(tt.wantVerbs, gotVerbs); diff != "" {
+				t.Errorf("reconcileEventTypes() unexpected actions (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantEvents, recordedEvents(recorder)); diff != "" {
+				t.Errorf("reconcileEventTypes() unexpected events (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestReconcileRoleBinding(t *testing.T) {
+	vms := newTestSource()
+	desired := resources.MakeRoleBinding(context.Background(), vms)
+
+	subjectDrift := desired.DeepCopy()
+	subjectDrift.Subjects[0].Name = "old-serviceaccount"
+
+	roleRefDrift := desired.DeepCopy()
+	roleRefDrift.RoleRef.Kind = "ClusterRole"
+	roleRefDrift.RoleRef.Name = "vsphere-receive-adapter-cm"
+
+	extraSubject := desired.DeepCopy()
+	extraSubject.Subjects = append(extraSubject.Subjects, rbacv1.Subject{
+		Kind:      "ServiceAccount",
+		Namespace: "other",
+		Name:      "intruder",
+	})
+
+	bothDrift := roleRefDrift.DeepCopy()
+	bothDrift.Subjects[0].Name = "old-serviceaccount"
+
+	tests := []struct {
+		name       string
+		existing   *rbacv1.RoleBinding
+		wantVerbs  []string
+		wantEvents []string
+	}{
+		{
+			name:       "rolebinding does not exist",
+			existing:   nil,
+			wantVerbs:  []string{"create"},
+			wantEvents: []string{`Normal RoleBindingCreated Created rolebinding "source-rolebinding"`},
+		},
+		{
+			name:       "rolebinding up to date",
+			existing:   desired.DeepCopy(),
+			wantVerbs:  nil,
+			wantEvents: nil,
+		},
+		{
+			name:       "subject drift",
+			existing:   subjectDrift,
+			wantVerbs:  []string{"update"},
+			wantEvents: []string{`Normal RoleBindingUpdated Updated rolebinding "source-rolebinding"`},
+		},
+		{
+			name:       "roleref drift",
+			existing:   roleRefDrift,
+			wantVerbs:  []string{"delete", "create"},
+			wantEvents: []string{`Normal RoleBindingRecreated Recreated rolebinding "source-rolebinding"`},
+		},
+		{
+			name:       "extra subject",
+			existing:   extraSubject,
+			wantVerbs:  []string{"update"},
+			wantEvents: []string{`Normal RoleBindingUpdated Updated rolebinding "source-rolebinding"`},
+		},
+		{
+			name:       "roleref and subject drift",
+			existing:   bothDrift,
+			wantVerbs:  []string{"delete", "create"},
+			wantEvents: []string{`Normal RoleBindingRecreated Recreated rolebinding "source-rolebinding"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			ctx := controller.WithEventRecorder(context.Background(), recorder)
+
+			var objs []runtime.Object
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if tt.existing != nil {
+				objs = append(objs, tt.existing)
+				if err := indexer.Add(tt.existing); err != nil {
+					t.Fatalf("add rolebinding to indexer: %v", err)
+				}
+			}
+
+			kc := fake.NewSimpleClientset(objs...)
+			r := &Reconciler{
+				kubeclient: kc,
+				rbacLister: rbacv1listers.NewRoleBindingLister(indexer),
+			}
+
+			if err := r.reconcileRoleBinding(ctx, vms); err != nil {
+				t.Fatalf("reconcileRoleBinding() error = %v", err)
+			}
+
+			var gotVerbs []string
+			for _, action := range kc.Actions() {
+				gotVerbs = append(gotVerbs, action.GetVerb())
+			}
+			if diff := cmp.Diff(tt.wantVerbs, gotVerbs); diff != "" {
+				t.Errorf("reconcileRoleBinding() unexpected actions (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantEvents, recordedEvents(recorder)); diff != "" {
+				t.Errorf("reconcileRoleBinding() unexpected events (-want, +got) = %v", diff)
+			}
+
+			got, err := kc.RbacV1().RoleBindings(vms.Namespace).Get(ctx, resourcenames.RoleBinding(vms), metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("get rolebinding: %v", err)
+			}
+
+			if diff := cmp.Diff(desired.RoleRef, got.RoleRef); diff != "" {
+				t.Errorf("reconcileRoleBinding() unexpected roleRef (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(desired.Subjects, got.Subjects); diff != "" {
+				t.Errorf("reconcileRoleBinding() unexpected subjects (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestReconcileServiceAccount(t *testing.T) {
+	existing := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "existing",
+			Namespace: "ns",
+		},
+	}
+	generated := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "source-serviceaccount",
+			Namespace:   "ns",
+			Annotations: map[string]string{"iam.gke.io/gcp-service-account": "old@example.iam.gserviceaccount.com"},
+		},
+	}
+
+	tests := []struct {
+		name               string
+		serviceAccountName string
+		annotations        map[string]string
+		existing           *corev1.ServiceAccount
+		wantVerbs          []string
+		wantEvents         []string
+		wantErr            bool
+		wantReady          corev1.ConditionStatus
+	}{
+		{
+			name:       "generated serviceaccount is created",
+			wantVerbs:  []string{"create"},
+			wantEvents: []string{`Normal ServiceAccountCreated Created serviceaccount "source-serviceaccount"`},
+			wantReady:  corev1.ConditionUnknown,
+		},
+		{
+			name:        "generated serviceaccount is up to date",
+			annotations: map[string]string{"iam.gke.io/gcp-service-account": "old@example.iam.gserviceaccount.com"},
+			existing:    generated,
+			wantVerbs:   nil,
+			wantEvents:  nil,
+			wantReady:   corev1.ConditionUnknown,
+		},
+		{
+			name:        "generated serviceaccount annotations drifted",
+			annotations: map[string]string{"iam.gke.io/gcp-service-account": "new@example.iam.gserviceaccount.com"},
+			existing:    generated,
+			wantVerbs:   []string{"update"},
+			wantEvents:  []string{`Normal ServiceAccountUpdated Updated serviceaccount "source-serviceaccount"`},
+			wantReady:   corev1.ConditionUnknown,
+		},
+		{
+			name:               "user-provided serviceaccount exists",
+			serviceAccountName: "existing",
+			existing:           existing,
+			wantVerbs:          nil,
+			wantEvents:         nil,
+			wantReady:          corev1.ConditionUnknown,
+		},
+		{
+			name:               "user-provided serviceaccount does not exist",
+			serviceAccountName: "missing",
+			wantVerbs:          nil,
+			wantEvents:         nil,
+			wantErr:            true,
+			wantReady:          corev1.ConditionFalse,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			ctx := controller.WithEventRecorder(context.Background(), recorder)
+			vms := newTestSource()
+			vms.Spec.ServiceAccountName = tt.serviceAccountName
+			vms.Spec.ServiceAccountAnnotations = tt.annotations
+			vms.Status.InitializeConditions()
+
+			var objs []runtime.Object
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if tt.existing != nil {
+				objs = append(objs, tt.existing)
+				if err := indexer.Add(tt.existing); err != nil {
+					t.Fatalf("add serviceaccount to indexer: %v", err)
+				}
+			}
+
+			kc := fake.NewSimpleClientset(objs...)
+			r := &Reconciler{
+				kubeclient: kc,
+				saLister:   corev1listers.NewServiceAccountLister(indexer),
+			}
+
+			err := r.reconcileServiceAccount(ctx, vms)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reconcileServiceAccount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var gotVerbs []string
+			for _, action := range kc.Actions() {
+				gotVerbs = append(gotVerbs, action.GetVerb())
+			}
+			if diff := cmp.Diff(tt.wantVerbs, gotVerbs); diff != "" {
+				t.Errorf("reconcileServiceAccount() unexpected actions (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantEvents, recordedEvents(recorder)); diff != "" {
+				t.Errorf("reconcileServiceAccount() unexpected events (-want, +got) = %v", diff)
+			}
+
+			cond := vms.Status.GetCondition(v1alpha1.VSphereSourceConditionAdapterReady)
+			if cond.Status != tt.wantReady {
+				t.Errorf("reconcileServiceAccount() AdapterReady = %v, want %v", cond.Status, tt.wantReady)
+			}
+		})
+	}
+}
+
+func TestReconcileServiceAccountPullSecrets(t *testing.T) {
+	ctx := controller.WithEventRecorder(context.Background(), record.NewFakeRecorder(10))
+	vms := newTestSource()
+	vms.Spec.AdapterOverrides = &v1alpha1.AdapterOverrides{
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}, {Name: "mirror"}},
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	kc := fake.NewSimpleClientset()
+	r := &Reconciler{
+		kubeclient: kc,
+		saLister:   corev1listers.NewServiceAccountLister(indexer),
+	}
+
+	reconcile := func() *corev1.ServiceAccount {
+		t.Helper()
+		if err := r.reconcileServiceAccount(ctx, vms); err != nil {
+			t.Fatalf("reconcileServiceAccount() error = %v", err)
+		}
+		sa, err := kc.CoreV1().ServiceAccounts(vms.Namespace).Get(ctx, "source-serviceaccount", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("get serviceaccount: %v", err)
+		}
+		if err := indexer.Update(sa); err != nil {
+			t.Fatal(err)
+		}
+		return sa
+	}
+
+	sa := reconcile()
+
+	// another controller adds its own pull secret
+	sa.ImagePullSecrets = append(sa.ImagePullSecrets, corev1.LocalObjectReference{Name: "dockercfg"})
+	if _, err := kc.CoreV1().ServiceAccounts(vms.Namespace).Update(ctx, sa, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := indexer.Update(sa); err != nil {
+		t.Fatal(err)
+	}
+
+	vms.Spec.AdapterOverrides.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "mirror"}}
+	sa = reconcile()
+	want := []corev1.LocalObjectReference{{Name: "mirror"}, {Name: "dockercfg"}}
+	if diff := cmp.Diff(want, sa.ImagePullSecrets); diff != "" {
+		t.Errorf("reconcileServiceAccount() image pull secrets (-want, +got) = %v", diff)
+	}
+
+	vms.Spec.AdapterOverrides = nil
+	sa = reconcile()
+	want = []corev1.LocalObjectReference{{Name: "dockercfg"}}
+	if diff := cmp.Diff(want, sa.ImagePullSecrets); diff != "" {
+		t.Errorf("reconcileServiceAccount() image pull secrets (-want, +got) = %v", diff)
+	}
+	if got, ok := sa.Annotations[resources.PullSecretsAnnotationKey]; ok {
+		t.Errorf("reconcileServiceAccount() %s annotation = %q, want none", resources.PullSecretsAnnotationKey, got)
+	}
+}
+
+func TestWarnSkipTLSVerify(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	ctx := controller.WithEventRecorder(context.Background(), recorder)
+
+	vms := newTestSource()
+	vms.Status.InitializeConditions()
+
+	warn := func(skipTLSVerify bool, wantEvents int, wantStatus corev1.ConditionStatus) {
+		t.Helper()
+		vms.Spec.SkipTLSVerify = skipTLSVerify
+		warnSkipTLSVerify(ctx, vms)
+
+		if got := len(recordedEvents(recorder)); got != wantEvents {
+			t.Errorf("warnSkipTLSVerify(%v) events = %d, want %d", skipTLSVerify, got, wantEvents)
+		}
+		cond := vms.Status.GetCondition(v1alpha1.VSphereSourceConditionTLSVerified)
+		switch {
+		case wantStatus == "" && cond != nil:
+			t.Errorf("warnSkipTLSVerify(%v) TLSVerified = %v, want none", skipTLSVerify, cond)
+		case wantStatus != "" && (cond == nil || cond.Status != wantStatus):
+			t.Errorf("warnSkipTLSVerify(%v) TLSVerified = %v, want %v", skipTLSVerify, cond, wantStatus)
+		}
+		if ready := vms.Status.GetCondition(v1alpha1.VSphereSourceConditionReady); ready.Status != corev1.ConditionUnknown {
+			t.Errorf("warnSkipTLSVerify(%v) Ready = %v, want Unknown", skipTLSVerify, ready.Status)
+		}
+	}
+
+	warn(false, 0, "")
+	// the warning is emitted once when TLS verification is disabled, not on
+	// every reconciliation
+	warn(true, 1, corev1.ConditionFalse)
+	warn(true, 0, corev1.ConditionFalse)
+	warn(true, 0, corev1.ConditionFalse)
+	// and again when it is disabled after it was enabled
+	warn(false, 0, "")
+	warn(true, 1, corev1.ConditionFalse)
+}
+
+func TestReconcileConfigMap(t *testing.T) {
+	vms := newTestSource()
+	desired := resources.MakeConfigMap(context.Background(), vms)
+
+	// an adapter checkpoint in a configmap created before labels were set
+	outdated := desired.DeepCopy()
+	outdated.Labels = nil
+	outdated.Data = map[string]string{"checkpoint": `{"lastEventKey":42}`}
+
+	upToDate := desired.DeepCopy()
+	upToDate.Data = map[string]string{"checkpoint": `{"lastEventKey":42}`}
+
+	notOwned := outdated.DeepCopy()
+	notOwned.OwnerReferences = nil
+
+	tests := []struct {
+		name       string
+		existing   *corev1.ConfigMap
+		wantVerbs  []string
+		wantEvents []string
+		wantData   map[string]string
+	}{
+		{
+			name:       "configmap does not exist",
+			wantVerbs:  []string{"create"},
+			wantEvents: []string{`Normal ConfigMapCreated Created configmap "source-configmap"`},
+		},
+		{
+			name:       "configmap up to date",
+			existing:   upToDate,
+			wantVerbs:  nil,
+			wantEvents: nil,
+			wantData:   upToDate.Data,
+		},
+		{
+			name:       "configmap outdated",
+			existing:   outdated,
+			wantVerbs:  []string{"update"},
+			wantEvents: []string{`Normal ConfigMapUpdated Updated configmap "source-configmap"`},
+			wantData:   outdated.Data,
+		},
+		{
+			name:       "configmap not owned",
+			existing:   notOwned,
+			wantVerbs:  nil,
+			wantEvents: nil,
+			wantData:   notOwned.Data,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			ctx := controller.WithEventRecorder(context.Background(), recorder)
+
+			var objs []runtime.Object
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if tt.existing != nil {
+				objs = append(objs, tt.existing)
+				if err := indexer.Add(tt.existing); err != nil {
+					t.Fatalf("add configmap to indexer: %v", err)
+				}
+			}
+
+			kc := fake.NewSimpleClientset(objs...)
+			r := &Reconciler{
+				kubeclient: kc,
+				cmLister:   corev1listers.NewConfigMapLister(indexer),
+			}
+
+			if err := r.reconcileConfigMap(ctx, vms); err != nil {
+				t.Fatalf("reconcileConfigMap() error = %v", err)
+			}
+
+			var gotVerbs []string
+			for _, action := range kc.Actions() {
+				gotVerbs = append(gotVerbs, action.GetVerb())
+			}
+			if diff := cmp.Diff(tt.wantVerbs, gotVerbs); diff != "" {
+				t.Errorf("reconcileConfigMap() unexpected actions (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantEvents, recordedEvents(recorder)); diff != "" {
+				t.Errorf("reconcileConfigMap() unexpected events (-want, +got) = %v", diff)
+			}
+
+			got, err := kc.CoreV1().ConfigMaps(vms.Namespace).Get(ctx, desired.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("get configmap: %v", err)
+			}
+			if diff := cmp.Diff(tt.wantData, got.Data); diff != "" {
+				t.Errorf("reconcileConfigMap() unexpected data (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestReconcileCheckpointLease(t *testing.T) {
+	vms := newTestSource()
+	vms.Spec.CheckpointConfig.Backend = v1alpha1.CheckpointBackendLease
+
+	// a checkpoint written by the adapter
+	existing := resources.MakeCheckpointLease(context.Background(), vms)
+	existing.Annotations = map[string]string{"vspheresources.sources.tanzu.vmware.com/checkpoint": `{"lastEventKey":42}`}
+
+	tests := []struct {
+		name       string
+		backend    v1alpha1.CheckpointBackend
+		existing   *coordinationv1.Lease
+		wantVerbs  []string
+		wantEvents []string
+	}{
+		{
+			name:       "lease does not exist",
+			backend:    v1alpha1.CheckpointBackendLease,
+			wantVerbs:  []string{"create"},
+			wantEvents: []string{`Normal LeaseCreated Created lease "source-checkpoint"`},
+		},
+		{
+			name:      "lease exists",
+			backend:   v1alpha1.CheckpointBackendLease,
+			existing:  existing,
+			wantVerbs: nil,
+		},
+		{
+			name:    "configmap backend",
+			backend: v1alpha1.CheckpointBackendConfigMap,
+			// kept for the migration of the checkpoint
+			existing:  existing,
+			wantVerbs: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			ctx := controller.WithEventRecorder(context.Background(), recorder)
+
+			vms := vms.DeepCopy()
+			vms.Spec.CheckpointConfig.Backend = tt.backend
+
+			var objs []runtime.Object
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if tt.existing != nil {
+				objs = append(objs, tt.existing)
+				if err := indexer.Add(tt.existing); err != nil {
+					t.Fatalf("add lease to indexer: %v", err)
+				}
+			}
+			kc := fake.NewSimpleClientset(objs...)
+			r := &Reconciler{
+				kubeclient:  kc,
+				leaseLister: coordinationv1listers.NewLeaseLister(indexer),
+			}
+
+			if err := r.reconcileCheckpointLease(ctx, vms); err != nil {
+				t.Fatalf("reconcileCheckpointLease() error = %v", err)
+			}
+
+			var gotVerbs []string
+			for _, action := range kc.Actions() {
+				gotVerbs = append(gotVerbs, action.GetVerb())
+			}
+			if diff := cmp.Diff(tt.wantVerbs, gotVerbs); diff != "" {
+				t.Errorf("reconcileCheckpointLease() unexpected actions (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantEvents, recordedEvents(recorder)); diff != "" {
+				t.Errorf("reconcileCheckpointLease() unexpected events (-want, +got) = %v", diff)
+			}
+
+			if tt.existing != nil {
+				got, err := kc.CoordinationV1().Leases(vms.Namespace).Get(ctx, tt.existing.Name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("get lease: %v", err)
+				}
+				if diff := cmp.Diff(tt.existing.Annotations, got.Annotations); diff != "" {
+					t.Errorf("reconcileCheckpointLease() unexpected annotations (-want, +got) = %v", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileAdapterHealth(t *testing.T) {
+	now := time.Now().UTC()
+	heartbeat := func(at time.Time, lastError string, failingSince *time.Time) string {
+		b, err := json.Marshal(vsphere.Heartbeat{Time: at, LastError: lastError, FailingSince: failingSince})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	ago := func(d time.Duration) *time.Time {
+		at := now.Add(-d)
+		return &at
+	}
+
+	vms := newTestSource()
+	newConfigMap := func(data string) *corev1.ConfigMap {
+		cm := resources.MakeConfigMap(context.Background(), vms)
+		if data != "" {
+			cm.Data = map[string]string{vsphere.HeartbeatConfigMapKey: data}
+		}
+		return cm
+	}
+
+	tests := []struct {
+		name string
+		cm   *corev1.ConfigMap
+		// the condition of a previous reconciliation
+		healthy       bool
+		paused        bool
+		wantCondition corev1.ConditionStatus
+		wantReason    string
+		wantMessage   string
+		// the latest delay the source is expected to be enqueued after, not
+		// enqueued when zero
+		wantEnqueuedWithin time.Duration
+	}{
+		{
+			name: "no configmap",
+		},
+		{
+			name:    "no heartbeat yet",
+			cm:      newConfigMap(""),
+			healthy: true,
+		},
+		{
+			name:               "recent heartbeat",
+			cm:                 newConfigMap(heartbeat(now.Add(-time.Minute), "", nil)),
+			wantCondition:      corev1.ConditionTrue,
+			wantEnqueuedWithin: heartbeatTimeout - time.Minute,
+		},
+		{
+			name:               "recent heartbeat with transient failure",
+			cm:                 newConfigMap(heartbeat(now, "connection refused", ago(time.Second))),
+			wantCondition:      corev1.ConditionTrue,
+			wantEnqueuedWithin: heartbeatTimeout,
+		},
+		{
+			name:               "persistent failure",
+			cm:                 newConfigMap(heartbeat(now, "NoPermission: Event.Read", ago(5*time.Minute))),
+			wantCondition:      corev1.ConditionFalse,
+			wantReason:         "AdapterFailing",
+			wantMessage:        "The adapter has been failing for 5m0s: NoPermission: Event.Read",
+			wantEnqueuedWithin: heartbeatTimeout,
+		},
+		{
+			name:          "stale heartbeat",
+			cm:            newConfigMap(heartbeat(now.Add(-time.Hour), "", nil)),
+			healthy:       true,
+			wantCondition: corev1.ConditionFalse,
+			wantReason:    "HeartbeatStale",
+			wantMessage:   "The adapter has not reported a heartbeat for 1h0m0s",
+		},
+		{
+			name:          "stale heartbeat with error",
+			cm:            newConfigMap(heartbeat(now.Add(-time.Hour), "NoPermission: Event.Read", ago(time.Hour))),
+			wantCondition: corev1.ConditionFalse,
+			wantReason:    "HeartbeatStale",
+			wantMessage:   "The adapter has not reported a heartbeat for 1h0m0s, last error: NoPermission: Event.Read",
+		},
+		{
+			name:    "paused source without heartbeats",
+			cm:      newConfigMap(heartbeat(now.Add(-time.Hour), "", nil)),
+			healthy: true,
+			paused:  true,
+		},
+		{
+			name:          "malformed heartbeat keeps condition",
+			cm:            newConfigMap("{"),
+			healthy:       true,
+			wantCondition: corev1.ConditionTrue,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := vms.DeepCopy()
+			vms.Spec.Paused = tt.paused
+			vms.Status.InitializeConditions()
+			if tt.healthy {
+				vms.Status.MarkAdapterHealthy()
+			}
+
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if tt.cm != nil {
+				if err := indexer.Add(tt.cm); err != nil {
+					t.Fatalf("add configmap to indexer: %v", err)
+				}
+			}
+
+			var enqueued []time.Duration
+			r := &Reconciler{
+				cmLister: corev1listers.NewConfigMapLister(indexer),
+				enqueueAfter: func(_ interface{}, after time.Duration) {
+					enqueued = append(enqueued, after)
+				},
+			}
+			r.reconcileAdapterHealth(context.Background(), vms)
+
+			var got apis.Condition
+			if cond := vms.Status.GetCondition(v1alpha1.VSphereSourceConditionAdapterHealthy); cond != nil {
+				got = *cond
+			}
+			if got.Status != tt.wantCondition || got.Reason != tt.wantReason {
+				t.Errorf("reconcileAdapterHealth() condition = %q (%s), want %q (%s)", got.Status, got.Reason,
+					tt.wantCondition, tt.wantReason)
+			}
+			if tt.wantMessage != "" && got.Message != tt.wantMessage {
+				t.Errorf("reconcileAdapterHealth() message = %q, want %q", got.Message, tt.wantMessage)
+			}
+			switch {
+			case tt.wantEnqueuedWithin == 0 && len(enqueued) > 0:
+				t.Errorf("reconcileAdapterHealth() enqueued = %v, want none", enqueued)
+			case tt.wantEnqueuedWithin > 0 && (len(enqueued) != 1 || enqueued[0] <= 0 || enqueued[0] > tt.wantEnqueuedWithin):
+				t.Errorf("reconcileAdapterHealth() enqueued = %v, want once within %s", enqueued, tt.wantEnqueuedWithin)
+			}
+			// the Ready condition is not affected
+			if ready := vms.Status.GetCondition(apis.ConditionReady); ready.Status != corev1.ConditionUnknown {
+				t.Errorf("reconcileAdapterHealth() Ready = %q, want Unknown", ready.Status)
+			}
+		})
+	}
+}
+
+func TestReconcileCheckpointStatus(t *testing.T) {
+	now := time.Now().UTC()
+	checkpoint := func(key int32, lastEventTime time.Time) string {
+		return fmt.Sprintf(`{"lastEventKey":%d,"lastEventKeyTimestamp":%q}`, key, lastEventTime.Format(time.RFC3339Nano))
+	}
+
+	vms := newTestSource()
+	newConfigMap := func(data string) *corev1.ConfigMap {
+		cm := resources.MakeConfigMap(context.Background(), vms)
+		if data != "" {
+			cm.Data = map[string]string{vsphere.CheckpointConfigMapKey: data}
+		}
+		return cm
+	}
+	newLease := func(data string) *coordinationv1.Lease {
+		lease := resources.MakeCheckpointLease(context.Background(), vms)
+		lease.Annotations = map[string]string{vsphere.CheckpointLeaseAnnotation: data}
+		return lease
+	}
+
+	tests := []struct {
+		name      string
+		backend   v1alpha1.CheckpointBackend
+		threshold time.Duration
+		cm        *corev1.ConfigMap
+		lease     *coordinationv1.Lease
+		// the status of a previous reconciliation
+		status        *v1alpha1.CheckpointStatus
+		wantKey       int32
+		wantCondition corev1.ConditionStatus
+	}{
+		{
+			name: "no configmap",
+		},
+		{
+			name:   "no checkpoint yet",
+			cm:     newConfigMap(""),
+			status: &v1alpha1.CheckpointStatus{LastEventKey: 41},
+		},
+		{
+			name:      "start position only",
+			threshold: 10 * time.Minute,
+			cm:        newConfigMap(checkpoint(0, now.Add(-time.Hour))),
+			status:    &v1alpha1.CheckpointStatus{LastEventKey: 41},
+		},
+		{
+			name:    "checkpoint without threshold",
+			cm:      newConfigMap(checkpoint(42, now.Add(-time.Hour))),
+			wantKey: 42,
+		},
+		{
+			name:          "current checkpoint",
+			threshold:     10 * time.Minute,
+			cm:            newConfigMap(checkpoint(42, now.Add(-time.Minute))),
+			wantKey:       42,
+			wantCondition: corev1.ConditionTrue,
+		},
+		{
+			name:          "lagging checkpoint",
+			threshold:     10 * time.Minute,
+			cm:            newConfigMap(checkpoint(42, now.Add(-time.Hour))),
+			wantKey:       42,
+			wantCondition: corev1.ConditionFalse,
+		},
+		{
+			name:          "lease checkpoint",
+			backend:       v1alpha1.CheckpointBackendLease,
+			threshold:     10 * time.Minute,
+			cm:            newConfigMap(checkpoint(41, now.Add(-time.Hour))),
+			lease:         newLease(checkpoint(42, now.Add(-time.Minute))),
+			wantKey:       42,
+			wantCondition: corev1.ConditionTrue,
+		},
+		{
+			name:    "malformed checkpoint keeps status",
+			cm:      newConfigMap("{"),
+			status:  &v1alpha1.CheckpointStatus{LastEventKey: 41},
+			wantKey: 41,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vms := vms.DeepCopy()
+			vms.Spec.CheckpointConfig.Backend = tt.backend
+			vms.Status.InitializeConditions()
+			vms.Status.Checkpoint = tt.status
+
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if tt.cm != nil {
+				if err := indexer.Add(tt.cm); err != nil {
+					t.Fatalf("add configmap to indexer: %v", err)
+				}
+			}
+			leaseIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if tt.lease != nil {
+				if err := leaseIndexer.Add(tt.lease); err != nil {
+					t.Fatalf("add lease to indexer: %v", err)
+				}
+			}
+
+			r := &Reconciler{
+				cmLister:               corev1listers.NewConfigMapLister(indexer),
+				leaseLister:            coordinationv1listers.NewLeaseLister(leaseIndexer),
+				checkpointLagThreshold: tt.threshold,
+			}
+			r.reconcileCheckpointStatus(context.Background(), vms)
+
+			var gotKey int32
+			if vms.Status.Checkpoint != nil {
+				gotKey = vms.Status.Checkpoint.LastEventKey
+			}
+			if gotKey != tt.wantKey {
+				t.Errorf("reconcileCheckpointStatus() lastEventKey = %d, want %d", gotKey, tt.wantKey)
+			}
+
+			var gotCondition corev1.ConditionStatus
+			if cond := vms.Status.GetCondition(v1alpha1.VSphereSourceConditionCheckpointCurrent); cond != nil {
+				gotCondition = cond.Status
+			}
+			if gotCondition != tt.wantCondition {
+				t.Errorf("reconcileCheckpointStatus() condition = %q, want %q", gotCondition, tt.wantCondition)
+			}
+		})
+	}
+}
+
+func TestFinalizeKind(t *testing.T) {
+	newSecret := func() *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "vsphere-credentials", Namespace: "ns"},
+			Data: map[string][]byte{
+				"user": []byte("administrator"),
+				"pass": []byte("secret"),
+			},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		deployment   bool
+		secret       *corev1.Secret
+		terminateErr error
+		wantAddrs    []string
+		wantVerbs    []string
+		wantEvents   []string
+	}{
+		{
+			name:       "sessions terminated",
+			deployment: true,
+			secret:     newSecret(),
+			wantAddrs:  []string{"https://vcenter.example.com/sdk", "https://standby.example.com/sdk"},
+			wantVerbs:  []string{"delete", "get"},
+			wantEvents: []string{
+				`Normal DeploymentDeleted Deleted deployment "source-adapter"`,
+				`Normal VCenterSessionsTerminated Terminated 2 vCenter sessions of the adapter`,
+			},
+		},
+		{
+			name:         "vCenter unreachable",
+			secret:       newSecret(),
+			terminateErr: errors.New("connection refused"),
+			wantAddrs:    []string{"https://vcenter.example.com/sdk", "https://standby.example.com/sdk"},
+			wantVerbs:    []string{"delete", "get"},
+			wantEvents: []string{
+				"Warning VCenterCleanupFailed Failed to terminate the vCenter sessions of the adapter: " +
+					"[https://vcenter.example.com/sdk: connection refused, https://standby.example.com/sdk: connection refused]",
+			},
+		},
+		{
+			name:      "secret missing",
+			wantVerbs: []string{"delete", "get"},
+			wantEvents: []string{
+				`Warning VCenterCleanupFailed Failed to terminate the vCenter sessions of the adapter: ` +
+					`failed to get credentials secret "vsphere-credentials": secrets "vsphere-credentials" not found`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			ctx := controller.WithEventRecorder(context.Background(), recorder)
+
+			vms := newTestSource()
+			vms.Spec.Address = apis.URL{Scheme: "https", Host: "vcenter.example.com", Path: "/sdk"}
+			vms.Spec.FailoverAddresses = []string{"https://standby.example.com/sdk"}
+			vms.Spec.SecretRef.Name = "vsphere-credentials"
+			vms.Spec.UsernameKey = "user"
+			vms.Spec.PasswordKey = "pass"
+
+			var objs []runtime.Object
+			if tt.deployment {
+				objs = append(objs, &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Name: "source-adapter", Namespace: "ns"},
+				})
+			}
+			if tt.secret != nil {
+				objs = append(objs, tt.secret)
+			}
+
+			var gotAddrs []string
+			kc := fake.NewSimpleClientset(objs...)
+			r := &Reconciler{
+				kubeclient: kc,
+				cmLister:   corev1listers.NewConfigMapLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+				terminateSessions: func(_ context.Context, c vsphere.SessionCleanup) (int, error) {
+					gotAddrs = append(gotAddrs, c.Address)
+					if c.Username != "administrator" || c.Password != "secret" {
+						t.Errorf("terminateSessions() credentials = %q, %q", c.Username, c.Password)
+					}
+					if want := "vsphere-source/ns/source"; c.UserAgent != want {
+						t.Errorf("terminateSessions() user agent = %q, want %q", c.UserAgent, want)
+					}
+					if tt.terminateErr != nil {
+						return 0, tt.terminateErr
+					}
+					return 1, nil
+				},
+			}
+
+			if err := r.FinalizeKind(ctx, vms); err != nil {
+				t.Fatalf("FinalizeKind() error = %v", err)
+			}
+
+			if diff := cmp.Diff(tt.wantAddrs, gotAddrs); diff != "" {
+				t.Errorf("FinalizeKind() unexpected addresses (-want, +got) = %v", diff)
+			}
+			var gotVerbs []string
+			for _, action := range kc.Actions() {
+				gotVerbs = append(gotVerbs, action.GetVerb())
+			}
+			if diff := cmp.Diff(tt.wantVerbs, gotVerbs); diff != "" {
+				t.Errorf("FinalizeKind() unexpected actions (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantEvents, recordedEvents(recorder)); diff != "" {
+				t.Errorf("FinalizeKind() unexpected events (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func Test_mergeConfigMap(t *testing.T) {
+	desired := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{"owned": "label"},
+		},
+		Data: map[string]string{"config": "new"},
+	}
+
+	tests := []struct {
+		name        string
+		existing    *corev1.ConfigMap
+		want        *corev1.ConfigMap
+		wantChanged bool
+	}{
+		{
+			name: "desired and checkpoint keys up to date",
+			existing: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"owned": "label", "other": "label"},
+				},
+				Data: map[string]string{"config": "new", "checkpoint": "cp"},
+			},
+			want: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"owned": "label", "other": "label"},
+				},
+				Data: map[string]string{"config": "new", "checkpoint": "cp"},
+			},
+			wantChanged: false,
+		},
+		{
+			name: "outdated desired key with checkpoint key",
+			existing: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"owned": "label"},
+				},
+				Data: map[string]string{"config": "old", "checkpoint": "cp"},
+			},
+			want: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"owned": "label"},
+				},
+				Data: map[string]string{"config": "new", "checkpoint": "cp"},
+			},
+			wantChanged: true,
+		},
+		{
+			name:     "empty configmap",
+			existing: &corev1.ConfigMap{},
+			want: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"owned": "label"},
+				},
+				Data: map[string]string{"config": "new"},
+			},
+			wantChanged: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := tt.existing.DeepCopy()
+
+			got, changed := mergeConfigMap(tt.existing, desired)
+			if changed != tt.wantChanged {
+				t.Errorf("mergeConfigMap() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("mergeConfigMap() (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(existing, tt.existing); diff != "" {
+				t.Errorf("mergeConfigMap() modified existing (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func Test_mergeServiceAccount(t *testing.T) {
+	desired := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{"eks.amazonaws.com/role-arn": "arn:aws:iam::111122223333:role/new"},
+		},
+	}
+
+	withPullSecret := desired.DeepCopy()
+	withPullSecret.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry"}}
+
+	tests := []struct {
+		name        string
+		existing    *corev1.ServiceAccount
+		desired     *corev1.ServiceAccount
+		want        *corev1.ServiceAccount
+		wantChanged bool
+	}{
+		{
+			name: "desired and foreign annotations up to date",
+			existing: &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"eks.amazonaws.com/role-arn":              "arn:aws:iam::111122223333:role/new",
+						"kubernetes.io/enforce-mountable-secrets": "true",
+					},
+				},
+			},
+			want: &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"eks.amazonaws.com/role-arn":              "arn:aws:iam::111122223333:role/new",
+						"kubernetes.io/enforce-mountable-secrets": "true",
+					},
+				},
+			},
+			wantChanged: false,
+		},
+		{
+			name: "drifted annotation with foreign annotation",
+			existing: &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"eks.amazonaws.com/role-arn":              "arn:aws:iam::111122223333:role/old",
+						"kubernetes.io/enforce-mountable-secrets": "true",
+					},
+				},
+			},
+			want: &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"eks.amazonaws.com/role-arn":              "arn:aws:iam::111122223333:role/new",
+						"kubernetes.io/enforce-mountable-secrets": "true",
+					},
+				},
+			},
+			wantChanged: true,
+		},
+		{
+			name:     "no annotations",
+			existing: &corev1.ServiceAccount{},
+			want: &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{"eks.amazonaws.com/role-arn": "arn:aws:iam::111122223333:role/new"},
+				},
+			},
+			wantChanged: true,
+		},
+		{
+			name: "missing image pull secret with foreign secret",
+			existing: &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{"eks.amazonaws.com/role-arn": "arn:aws:iam::111122223333:role/new"},
+				},
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "dockercfg"}},
+			},
+			desired: withPullSecret,
+			want: &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{"eks.amazonaws.com/role-arn": "arn:aws:iam::111122223333:role/new"},
+				},
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "dockercfg"}, {Name: "registry"}},
+			},
+			wantChanged: true,
+		},
+		{
+			name: "removed image pull secret with foreign secret",
+			existing: &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"eks.amazonaws.com/role-arn":       "arn:aws:iam::111122223333:role/new",
+						resources.PullSecretsAnnotationKey: "registry",
+					},
+				},
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "dockercfg"}, {Name: "registry"}},
+			},
+			want: &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{"eks.amazonaws.com/role-arn": "arn:aws:iam::111122223333:role/new"},
+				},
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "dockercfg"}},
+			},
+			wantChanged: true,
+		},
+		{
+			name: "image pull secret up to date",
+			existing: &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{"eks.amazonaws.com/role-arn": "arn:aws:iam::111122223333:role/new"},
+				},
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+			},
+			desired: withPullSecret,
+			want: &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{"eks.amazonaws.com/role-arn": "arn:aws:iam::111122223333:role/new"},
+				},
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+			},
+			wantChanged: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := tt.existing.DeepCopy()
+
+			desired := desired
+			if tt.desired != nil {
+				desired = tt.desired
+			}
+			got, changed := mergeServiceAccount(tt.existing, desired)
+			if changed != tt.wantChanged {
+				t.Errorf("mergeServiceAccount() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("mergeServiceAccount() (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(existing, tt.existing); diff != "" {
+				t.Errorf("mergeServiceAccount() modified existing (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestReconcileCACertsConfigMap(t *testing.T) {
+	vms := newTestSource()
+	vms.Spec.CACerts = ptr.String("cert")
+	desired := resources.MakeCACertsConfigMap(context.Background(), vms)
+
+	outdated := desired.DeepCopy()
+	outdated.Data = map[string]string{"ca.crt": "old"}
+
+	tests := []struct {
+		name       string
+		caCerts    *string
+		existing   *corev1.ConfigMap
+		wantVerbs  []string
+		wantEvents []string
+	}{
+		{
+			name:       "no CA certs",
+			wantVerbs:  nil,
+			wantEvents: nil,
+		},
+		{
+			name:       "configmap does not exist",
+			caCerts:    ptr.String("cert"),
+			wantVerbs:  []string{"create"},
+			wantEvents: []string{`Normal ConfigMapCreated Created configmap "source-cacerts"`},
+		},
+		{
+			name:       "configmap up to date",
+			caCerts:    ptr.String("cert"),
+			existing:   desired.DeepCopy(),
+			wantVerbs:  nil,
+			wantEvents: nil,
+		},
+		{
+			name:       "configmap outdated",
+			caCerts:    ptr.String("cert"),
+			existing:   outdated,
+			wantVerbs:  []string{"update"},
+			wantEvents: []string{`Normal ConfigMapUpdated Updated configmap "source-cacerts"`},
+		},
+		{
+			name:       "CA certs removed",
+			existing:   desired.DeepCopy(),
+			wantVerbs:  []string{"delete"},
+			wantEvents: []string{`Normal ConfigMapDeleted Deleted configmap "source-cacerts"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			ctx := controller.WithEventRecorder(context.Background(), recorder)
+			vms := newTestSource()
+			vms.Spec.CACerts = tt.caCerts
+
+			var objs []runtime.Object
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if tt.existing != nil {
+				objs = append(objs, tt.existing)
+				if err := indexer.Add(tt.existing); err != nil {
+					t.Fatalf("add configmap to indexer: %v", err)
+				}
+			}
+
+			kc := fake.NewSimpleClientset(objs...)
+			r := &Reconciler{
+				kubeclient: kc,
+				cmLister:   corev1listers.NewConfigMapLister(indexer),
+			}
+
+			if err := r.reconcileCACertsConfigMap(ctx, vms); err != nil {
+				t.Fatalf("reconcileCACertsConfigMap() error = %v", err)
+			}
+
+			var gotVerbs []string
+			for _, action := range kc.Actions() {
+				gotVerbs = append(gotVerbs, action.GetVerb())
+			}
+			if diff := cmp.Diff(tt.wantVerbs, gotVerbs); diff != "" {
+				t.Errorf("reconcileCACertsConfigMap() unexpected actions (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantEvents, recordedEvents(recorder)); diff != "" {
+				t.Errorf("reconcileCACertsConfigMap() unexpected events (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *metrics.ExporterOptions
+		want bool
+	}{
+		{name: "no config", want: true},
+		{name: "default backend", cfg: &metrics.ExporterOptions{ConfigMap: map[string]string{}}, want: true},
+		{name: "prometheus backend", cfg: &metrics.ExporterOptions{ConfigMap: map[string]string{"metrics.backend-destination": "Prometheus"}}, want: true},
+		{name: "opencensus backend", cfg: &metrics.ExporterOptions{ConfigMap: map[string]string{"metrics.backend-destination": "opencensus"}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Reconciler{metricsConfig: tt.cfg}
+
+			if got := r.prometheusMetrics(); got != tt.want {
+				t.Errorf("prometheusMetrics() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileDeployment(t *testing.T) {
+	ctx := context.Background()
+	vms := newTestSource()
+
+	desired, err := resources.MakeDeployment(ctx, vms, resources.AdapterArgs{
+		Image:            "adapter-image",
+		HealthPort:       vsphere.DefaultHealthPort,
+		PrometheusScrape: true,
+	})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+
+	// simulate fields defaulted by the API server
+	defaulted := desired.DeepCopy()
+	defaulted.ResourceVersion = "1"
+	defaulted.Spec.Replicas = ptr.Int32(1)
+	defaulted.Spec.RevisionHistoryLimit = ptr.Int32(10)
+	defaulted.Spec.ProgressDeadlineSeconds = ptr.Int32(600)
+	defaulted.Spec.Template.Spec.TerminationGracePeriodSeconds = ptr.Int64(30)
+	defaulted.Spec.Template.Spec.DNSPolicy = corev1.DNSClusterFirst
+	defaulted.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyAlways
+	defaulted.Spec.Template.Spec.SchedulerName = corev1.DefaultSchedulerName
+	defaulted.Spec.Template.Spec.Containers[0].TerminationMessagePath = corev1.TerminationMessagePathDefault
+	defaulted.Spec.Template.Spec.Containers[0].ImagePullPolicy = corev1.PullIfNotPresent
+	defaulted.Spec.Template.Spec.Containers[0].LivenessProbe.HTTPGet.Scheme = corev1.URISchemeHTTP
+	defaulted.Spec.Template.Spec.Containers[0].ReadinessProbe.HTTPGet.Scheme = corev1.URISchemeHTTP
+
+	drifted := defaulted.DeepCopy()
+	drifted.Spec.Template.Spec.Containers[0].Image = "old-image"
+
+	// image pull secrets removed from the source
+	withPullSecrets := defaulted.DeepCopy()
+	withPullSecrets.Spec.Template.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry"}}
+
+	tests := []struct {
+		name       string
+		existing   *appsv1.Deployment
+		wantVerbs  []string
+		wantEvents []string
+	}{
+		{
+			name:       "deployment does not exist",
+			existing:   nil,
+			wantVerbs:  []string{"create"},
+			wantEvents: []string{`Normal DeploymentCreated Created deployment "source-adapter"`},
+		},
+		{
+			name:       "steady state",
+			existing:   desired.DeepCopy(),
+			wantVerbs:  nil,
+			wantEvents: nil,
+		},
+		{
+			name:       "steady state with server defaults",
+			existing:   defaulted,
+			wantVerbs:  nil,
+			wantEvents: nil,
+		},
+		{
+			name:       "spec drift",
+			existing:   drifted,
+			wantVerbs:  []string{"update"},
+			wantEvents: []string{`Normal DeploymentUpdated Updated deployment "source-adapter"`},
+		},
+		{
+			name:       "removed image pull secrets",
+			existing:   withPullSecrets,
+			wantVerbs:  []string{"update"},
+			wantEvents: []string{`Normal DeploymentUpdated Updated deployment "source-adapter"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			ctx := controller.WithEventRecorder(ctx, recorder)
+
+			var objs []runtime.Object
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if tt.existing != nil {
+				objs = append(objs, tt.existing)
+				if err := indexer.Add(tt.existing); err != nil {
+					t.Fatalf("add deployment to indexer: %v", err)
+				}
+			}
+
+			kc := fake.NewSimpleClientset(objs...)
+			r := &Reconciler{
+				kubeclient:       kc,
+				deploymentLister: appsv1listers.NewDeploymentLister(indexer),
+				podLister:        corev1listers.NewPodLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+				adapterImage:     "adapter-image",
+			}
+
+			if err := r.reconcileDeployment(ctx, vms.DeepCopy()); err != nil {
+				t.Fatalf("reconcileDeployment() error = %v", err)
+			}
+
+			var gotVerbs []string
+			for _, action := range kc.Actions() {
+				gotVerbs = append(gotVerbs, action.GetVerb())
+			}
+			if diff := cmp.Diff(tt.wantVerbs, gotVerbs); diff != "" {
+				t.Errorf("reconcileDeployment() unexpected actions (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantEvents, recordedEvents(recorder)); diff != "" {
+				t.Errorf("reconcileDeployment() unexpected events (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestReconcileDeploymentAdapterImageUpgrade(t *testing.T) {
+	ctx := context.Background()
+
+	var sources []*v1alpha1.VSphereSource
+	for _, name := range []string{"source-a", "source-b", "source-custom"} {
+		vms := newTestSource()
+		vms.Name = name
+		sources = append(sources, vms)
+	}
+	// sources overriding the image keep it
+	sources[2].Spec.AdapterImage = "custom-image"
+
+	// the deployments created by the previous controller version, with
+	// fields defaulted by the API server
+	var objs []runtime.Object
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, vms := range sources {
+		image := "adapter-image:v1"
+		if vms.Spec.AdapterImage != "" {
+			image = vms.Spec.AdapterImage
+		}
+		d, err := resources.MakeDeployment(ctx, vms, resources.AdapterArgs{
+			Image:            image,
+			HealthPort:       vsphere.DefaultHealthPort,
+			PrometheusScrape: true,
+		})
+		if err != nil {
+			t.Fatalf("MakeDeployment() error = %v", err)
+		}
+		d.ResourceVersion = "1"
+		d.Spec.Replicas = ptr.Int32(1)
+		d.Spec.Template.Spec.Containers[0].ImagePullPolicy = corev1.PullIfNotPresent
+
+		objs = append(objs, d)
+		if err := indexer.Add(d); err != nil {
+			t.Fatalf("add deployment to indexer: %v", err)
+		}
+	}
+
+	kc := fake.NewSimpleClientset(objs...)
+	r := &Reconciler{
+		kubeclient:           kc,
+		deploymentLister:     appsv1listers.NewDeploymentLister(indexer),
+		podLister:            corev1listers.NewPodLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+		adapterImage:         "adapter-image:v2",
+		adapterImageOverride: true,
+	}
+
+	ctx = controller.WithEventRecorder(ctx, record.NewFakeRecorder(10))
+	for _, vms := range sources {
+		if err := r.reconcileDeployment(ctx, vms.DeepCopy()); err != nil {
+			t.Fatalf("reconcileDeployment(%s) error = %v", vms.Name, err)
+		}
+	}
+
+	want := map[string]string{
+		"source-a-adapter":      "adapter-image:v2",
+		"source-b-adapter":      "adapter-image:v2",
+		"source-custom-adapter": "custom-image",
+	}
+	got := make(map[string]string, len(want))
+	var updated []string
+	for _, action := range kc.Actions() {
+		if action.GetVerb() != "update" {
+			continue
+		}
+		d := action.(clientgotesting.UpdateAction).GetObject().(*appsv1.Deployment)
+		updated = append(updated, d.Name)
+	}
+	for name := range want {
+		d, err := kc.AppsV1().Deployments(sources[0].Namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("get deployment %s: %v", name, err)
+		}
+		got[name] = d.Spec.Template.Spec.Containers[0].Image
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("reconcileDeployment() unexpected images (-want, +got) = %v", diff)
+	}
+	if diff := cmp.Diff([]string{"source-a-adapter", "source-b-adapter"}, updated); diff != "" {
+		t.Errorf("reconcileDeployment() unexpected updates (-want, +got) = %v", diff)
+	}
+}
+
+func TestReconcileDeploymentPaused(t *testing.T) {
+	ctx := controller.WithEventRecorder(context.Background(), record.NewFakeRecorder(10))
+
+	vms := newTestSource()
+	args := resources.AdapterArgs{
+		Image:            "adapter-image",
+		HealthPort:       vsphere.DefaultHealthPort,
+		PrometheusScrape: true,
+	}
+	d, err := resources.MakeDeployment(ctx, vms, args)
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+	d.Status.Conditions = []appsv1.DeploymentCondition{{
+		Type:   appsv1.DeploymentAvailable,
+		Status: corev1.ConditionTrue,
+	}}
+
+	kc := fake.NewSimpleClientset(d)
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(d); err != nil {
+		t.Fatalf("add deployment to indexer: %v", err)
+	}
+	r := &Reconciler{
+		kubeclient:       kc,
+		deploymentLister: appsv1listers.NewDeploymentLister(indexer),
+		podLister:        corev1listers.NewPodLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+		adapterImage:     args.Image,
+	}
+
+	// reconcile observes the deployment as updated by the previous step
+	reconcile := func(paused bool) (*v1alpha1.VSphereSource, *appsv1.Deployment) {
+		t.Helper()
+		vms := vms.DeepCopy()
+		vms.Spec.Paused = paused
+		vms.Status.InitializeConditions()
+		if err := r.reconcileDeployment(ctx, vms); err != nil {
+			t.Fatalf("reconcileDeployment() error = %v", err)
+		}
+		got, err := kc.AppsV1().Deployments(vms.Namespace).Get(ctx, d.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("get deployment: %v", err)
+		}
+		if err := indexer.Update(got); err != nil {
+			t.Fatalf("update deployment in indexer: %v", err)
+		}
+		return vms, got
+	}
+
+	paused, got := reconcile(true)
+	if *got.Spec.Replicas != 0 {
+		t.Errorf("reconcileDeployment() paused replicas = %d, want 0", *got.Spec.Replicas)
+	}
+	if diff := cmp.Diff(d.Spec.Template, got.Spec.Template); diff != "" {
+		t.Errorf("reconcileDeployment() paused pod template changed (-want, +got) = %v", diff)
+	}
+	cond := paused.Status.GetCondition(v1alpha1.VSphereSourceConditionAdapterReady)
+	if cond.Status != corev1.ConditionTrue || cond.Reason != "Paused" {
+		t.Errorf("reconcileDeployment() paused AdapterReady = %q (%s), want True (Paused)", cond.Status, cond.Reason)
+	}
+
+	resumed, got := reconcile(false)
+	if *got.Spec.Replicas != 1 {
+		t.Errorf("reconcileDeployment() resumed replicas = %d, want 1", *got.Spec.Replicas)
+	}
+	cond = resumed.Status.GetCondition(v1alpha1.VSphereSourceConditionAdapterReady)
+	if cond.Status != corev1.ConditionTrue || cond.Reason != "" {
+		t.Errorf("reconcileDeployment() resumed AdapterReady = %q (%s), want True", cond.Status, cond.Reason)
+	}
+
+	// the deployment is scaled, never deleted
+	for _, action := range kc.Actions() {
+		if action.GetVerb() == "delete" {
+			t.Errorf("reconcileDeployment() unexpected action %v", action)
+		}
+	}
+}
+
+func TestReconcileDeploymentAdapterImage(t *testing.T) {
+	tests := []struct {
+		name         string
+		adapterImage string
+		override     bool
+		wantImage    string
+		wantEvents   []string
+	}{
+		{
+			name:      "controller default",
+			override:  true,
+			wantImage: "adapter-image",
+			wantEvents: []string{
+				`Normal DeploymentCreated Created deployment "source-adapter"`,
+			},
+		},
+		{
+			name:         "override",
+			adapterImage: "custom-image",
+			override:     true,
+			wantImage:    "custom-image",
+			wantEvents: []string{
+				`Normal DeploymentCreated Created deployment "source-adapter"`,
+			},
+		},
+		{
+			name:         "override disabled",
+			adapterImage: "custom-image",
+			override:     false,
+			wantImage:    "adapter-image",
+			wantEvents: []string{
+				`Warning AdapterImageIgnored Ignoring adapter image "custom-image": the adapter-image-override feature is disabled`,
+				`Normal DeploymentCreated Created deployment "source-adapter"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			ctx := controller.WithEventRecorder(context.Background(), recorder)
+			vms := newTestSource()
+			vms.Spec.AdapterImage = tt.adapterImage
+
+			kc := fake.NewSimpleClientset()
+			r := &Reconciler{
+				kubeclient:           kc,
+				deploymentLister:     appsv1listers.NewDeploymentLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+				podLister:            corev1listers.NewPodLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+				adapterImage:         "adapter-image",
+				adapterImageOverride: tt.override,
+			}
+
+			if err := r.reconcileDeployment(ctx, vms); err != nil {
+				t.Fatalf("reconcileDeployment() error = %v", err)
+			}
+
+			d, err := kc.AppsV1().Deployments(vms.Namespace).Get(ctx, "source-adapter", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("get deployment: %v", err)
+			}
+			if got := d.Spec.Template.Spec.Containers[0].Image; got != tt.wantImage {
+				t.Errorf("reconcileDeployment() image = %q, want %q", got, tt.wantImage)
+			}
+			if diff := cmp.Diff(tt.wantEvents, recordedEvents(recorder)); diff != "" {
+				t.Errorf("reconcileDeployment() unexpected events (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestReconcileDeploymentLoggingConfigChange(t *testing.T) {
+	ctx := controller.WithEventRecorder(context.Background(), record.NewFakeRecorder(10))
+	vms := newTestSource()
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	kc := fake.NewSimpleClientset()
+	r := &Reconciler{
+		kubeclient:       kc,
+		deploymentLister: appsv1listers.NewDeploymentLister(indexer),
+		podLister:        corev1listers.NewPodLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+		adapterImage:     "adapter-image",
+		loggingContext:   ctx,
+	}
+
+	loggingConfigMap := func(level string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "config-logging"},
+			Data:       map[string]string{"zap-logger-config": `{"level": "` + level + `"}`},
+		}
+	}
+
+	// reconcile as after a resync triggered by the logging ConfigMap and
+	// return the actions and the logging config of the adapter
+	resync := func(cm *corev1.ConfigMap) ([]string, string) {
+		t.Helper()
+		r.UpdateFromLoggingConfigMap(cm)
+
+		kc.ClearActions()
+		if err := r.reconcileDeployment(ctx, vms.DeepCopy()); err != nil {
+			t.Fatalf("reconcileDeployment() error = %v", err)
+		}
+
+		d, err := kc.AppsV1().Deployments(vms.Namespace).Get(ctx, "source-adapter", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("get deployment: %v", err)
+		}
+		if err := indexer.Update(d); err != nil {
+			t.Fatalf("update deployment in indexer: %v", err)
+		}
+
+		var verbs []string
+		for _, action := range kc.Actions() {
+			if action.GetVerb() != "get" {
+				verbs = append(verbs, action.GetVerb())
+			}
+		}
+		for _, env := range d.Spec.Template.Spec.Containers[0].Env {
+			if env.Name == "K_LOGGING_CONFIG" {
+				return verbs, env.Value
+			}
+		}
+		t.Fatal("K_LOGGING_CONFIG not set")
+		return nil, ""
+	}
+
+	verbs, loggingConfig := resync(loggingConfigMap("info"))
+	if diff := cmp.Diff([]string{"create"}, verbs); diff != "" {
+		t.Errorf("reconcileDeployment() unexpected actions (-want, +got) = %v", diff)
+	}
+	if !strings.Contains(loggingConfig, `\"level\": \"info\"`) {
+		t.Errorf("K_LOGGING_CONFIG = %s, want info level", loggingConfig)
+	}
+
+	// the same config does not roll the adapter
+	verbs, _ = resync(loggingConfigMap("info"))
+	if len(verbs) != 0 {
+		t.Errorf("reconcileDeployment() with unchanged config actions = %v, want none", verbs)
+	}
+
+	verbs, loggingConfig = resync(loggingConfigMap("debug"))
+	if diff := cmp.Diff([]string{"update"}, verbs); diff != "" {
+		t.Errorf("reconcileDeployment() unexpected actions (-want, +got) = %v", diff)
+	}
+	if !strings.Contains(loggingConfig, `\"level\": \"debug\"`) {
+		t.Errorf("K_LOGGING_CONFIG = %s, want debug level", loggingConfig)
+	}
+}
+
+func TestUpdateFromFeaturesConfigMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		current bool
+		cfg     *corev1.ConfigMap
+		want    bool
+	}{
+		{name: "no configmap", current: true, cfg: nil, want: false},
+		{name: "not set", current: true, cfg: &corev1.ConfigMap{}, want: false},
+		{name: "enabled", cfg: &corev1.ConfigMap{Data: map[string]string{"adapter-image-override": "Enabled"}}, want: true},
+		{name: "disabled", current: true, cfg: &corev1.ConfigMap{Data: map[string]string{"adapter-image-override": "disabled"}}, want: false},
+		// invalid values keep the current value
+		{name: "invalid", current: true, cfg: &corev1.ConfigMap{Data: map[string]string{"adapter-image-override": "on"}}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Reconciler{loggingContext: context.Background(), adapterImageOverride: tt.current}
+
+			r.UpdateFromFeaturesConfigMap(tt.cfg)
+			if r.adapterImageOverride != tt.want {
+				t.Errorf("UpdateFromFeaturesConfigMap() adapterImageOverride = %v, want %v", r.adapterImageOverride, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateFromVSphereConfigMap(t *testing.T) {
+	r := &Reconciler{loggingContext: context.Background()}
+	if got, want := r.currentConfig().DefaultCheckpointPeriod, vsphere.CheckpointDefaultPeriod; got != want {
+		t.Errorf("currentConfig() without configmap DefaultCheckpointPeriod = %v, want %v", got, want)
+	}
+
+	r.UpdateFromVSphereConfigMap(&corev1.ConfigMap{Data: map[string]string{"default-checkpoint-period": "1m"}})
+	if got, want := r.currentConfig().DefaultCheckpointPeriod, time.Minute; got != want {
+		t.Errorf("UpdateFromVSphereConfigMap() DefaultCheckpointPeriod = %v, want %v", got, want)
+	}
+
+	// invalid values keep the current config
+	r.UpdateFromVSphereConfigMap(&corev1.ConfigMap{Data: map[string]string{"default-checkpoint-period": "1ms"}})
+	if got, want := r.currentConfig().DefaultCheckpointPeriod, time.Minute; got != want {
+		t.Errorf("UpdateFromVSphereConfigMap() with invalid config DefaultCheckpointPeriod = %v, want %v", got, want)
+	}
+}
+
+func TestReconcileDeploymentDefaultAdapterResources(t *testing.T) {
+	ctx := controller.WithEventRecorder(context.Background(), record.NewFakeRecorder(10))
+	vms := newTestSource()
+
+	cfg, err := config.NewVSphereConfigFromConfigMap(&corev1.ConfigMap{Data: map[string]string{
+		"default-adapter-cpu-request":  "200m",
+		"default-adapter-memory-limit": "512Mi",
+	}})
+	if err != nil {
+		t.Fatalf("NewVSphereConfigFromConfigMap() error = %v", err)
+	}
+
+	kc := fake.NewSimpleClientset()
+	r := &Reconciler{
+		kubeclient:       kc,
+		deploymentLister: appsv1listers.NewDeploymentLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+		podLister:        corev1listers.NewPodLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+		adapterImage:     "adapter-image",
+		// set via flags
+		adapterResources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("100m"),
+				corev1.ResourceMemory: resource.MustParse("64Mi"),
+			},
+		},
+		vsphereConfig: cfg,
+	}
+
+	if err := r.reconcileDeployment(ctx, vms); err != nil {
+		t.Fatalf("reconcileDeployment() error = %v", err)
+	}
+
+	d, err := kc.AppsV1().Deployments(vms.Namespace).Get(ctx, "source-adapter", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get deployment: %v", err)
+	}
+
+	want := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("200m"),
+			corev1.ResourceMemory: resource.MustParse("64Mi"),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceMemory: resource.MustParse("512Mi"),
+		},
+	}
+	if diff := cmp.Diff(want, d.Spec.Template.Spec.Containers[0].Resources); diff != "" {
+		t.Errorf("reconcileDeployment() unexpected resources (-want, +got) = %v", diff)
+	}
+
+	// the flags are not modified
+	if got, want := r.adapterResources.Requests[corev1.ResourceCPU], resource.MustParse("100m"); got.Cmp(want) != 0 {
+		t.Errorf("reconcileDeployment() modified adapterResources cpu request = %s, want %s", got.String(), want.String())
+	}
+}
+
+func TestCheckVCenterAllowed(t *testing.T) {
+	cfg, err := config.NewVSphereConfigFromConfigMap(&corev1.ConfigMap{Data: map[string]string{
+		"allowed-vcenter-hosts": "vcenter.example.com, vcenter-standby.example.com",
+	}})
+	if err != nil {
+		t.Fatalf("NewVSphereConfigFromConfigMap() error = %v", err)
+	}
+
+	existing := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "source-adapter", Namespace: "ns"},
+	}
+
+	tests := []struct {
+		name       string
+		cfg        *config.VSphere
+		address    string
+		failover   []string
+		existing   *appsv1.Deployment
+		wantErr    bool
+		wantVerbs  []string
+		wantEvents []string
+	}{
+		{
+			name:    "no allowed hosts",
+			address: "other.example.com",
+		},
+		{
+			name:     "allowed",
+			cfg:      cfg,
+			address:  "VCenter.example.com:443",
+			failover: []string{"https://vcenter-standby.example.com"},
+		},
+		{
+			name:     "not allowed",
+			cfg:      cfg,
+			address:  "other.example.com",
+			existing: existing,
+			wantErr:  true,
+			wantVerbs: []string{
+				"delete",
+			},
+			wantEvents: []string{
+				`Normal DeploymentDeleted Deleted deployment "source-adapter"`,
+			},
+		},
+		{
+			name:     "failover not allowed",
+			cfg:      cfg,
+			address:  "vcenter.example.com",
+			failover: []string{"https://other.example.com"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			ctx := controller.WithEventRecorder(context.Background(), recorder)
+
+			vms := newTestSource()
+			vms.Spec.Address = apis.URL{Scheme: "https", Host: tt.address}
+			vms.Spec.FailoverAddresses = tt.failover
+			vms.Status.InitializeConditions()
+
+			var objs []runtime.Object
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if tt.existing != nil {
+				objs = append(objs, tt.existing)
+				if err := indexer.Add(tt.existing); err != nil {
+					t.Fatalf("add deployment to indexer: %v", err)
+				}
+			}
+
+			kc := fake.NewSimpleClientset(objs...)
+			r := &Reconciler{
+				kubeclient:       kc,
+				deploymentLister: appsv1listers.NewDeploymentLister(indexer),
+				vsphereConfig:    tt.cfg,
+			}
+
+			err := r.checkVCenterAllowed(ctx, vms)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkVCenterAllowed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			cond := vms.Status.GetCondition(v1alpha1.VSphereSourceConditionAdapterReady)
+			if got := cond.IsFalse() && cond.Reason == "VCenterNotAllowed"; got != tt.wantErr {
+				t.Errorf("checkVCenterAllowed() AdapterReady = %v, want VCenterNotAllowed %v", cond, tt.wantErr)
+			}
+
+			var gotVerbs []string
+			for _, action := range kc.Actions() {
+				gotVerbs = append(gotVerbs, action.GetVerb())
+			}
+			if diff := cmp.Diff(tt.wantVerbs, gotVerbs); diff != "" {
+				t.Errorf("checkVCenterAllowed() unexpected actions (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantEvents, recordedEvents(recorder)); diff != "" {
+				t.Errorf("checkVCenterAllowed() unexpected events (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestReconcileDeploymentCrashLoop(t *testing.T) {
+	ctx := context.Background()
+	vms := newTestSource()
+
+	deployment, err := resources.MakeDeployment(ctx, vms, resources.AdapterArgs{
+		Image:      "adapter-image",
+		HealthPort: vsphere.DefaultHealthPort,
+	})
+	if err != nil {
+		t.Fatalf("MakeDeployment() error = %v", err)
+	}
+	deployment.Status.Conditions = []appsv1.DeploymentCondition{{
+		Type:   appsv1.DeploymentAvailable,
+		Status: corev1.ConditionTrue,
+	}}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "source-adapter-5d4b9c7f8-x2k4q",
+			Namespace: vms.Namespace,
+			Labels:    resources.Labels(vms),
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: "adapter",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+					Reason: "CrashLoopBackOff",
+				}},
+				LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					ExitCode: 1,
+					Message:  "could not initialize kv store",
+				}},
+			}},
+		},
+	}
+
+	// pods of other sources are ignored
+	other := pod.DeepCopy()
+	other.Name = "other-adapter-7c9d8b6f5-p8m2z"
+	other.Labels = map[string]string{resources.NameLabelKey: "other"}
+
+	tests := []struct {
+		name       string
+		pods       []*corev1.Pod
+		wantReady  corev1.ConditionStatus
+		wantReason string
+	}{
+		{
+			name:      "no crash-looping pod",
+			pods:      []*corev1.Pod{other},
+			wantReady: corev1.ConditionTrue,
+		},
+		{
+			name:       "crash-looping pod",
+			pods:       []*corev1.Pod{pod, other},
+			wantReady:  corev1.ConditionFalse,
+			wantReason: "CrashLoopBackOff",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := controller.WithEventRecorder(ctx, record.NewFakeRecorder(10))
+
+			deploymentIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if err := deploymentIndexer.Add(deployment); err != nil {
+				t.Fatalf("add deployment to indexer: %v", err)
+			}
+			podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			for _, p := range tt.pods {
+				if err := podIndexer.Add(p); err != nil {
+					t.Fatalf("add pod to indexer: %v", err)
+				}
+			}
+
+			r := &Reconciler{
+				kubeclient:       fake.NewSimpleClientset(deployment),
+				deploymentLister: appsv1listers.NewDeploymentLister(deploymentIndexer),
+				podLister:        corev1listers.NewPodLister(podIndexer),
+				adapterImage:     "adapter-image",
+			}
+
+			vms := vms.DeepCopy()
+			vms.Status.InitializeConditions()
+			if err := r.reconcileDeployment(ctx, vms); err != nil {
+				t.Fatalf("reconcileDeployment() error = %v", err)
+			}
+
+			cond := vms.Status.GetCondition(v1alpha1.VSphereSourceConditionAdapterReady)
+			if cond.Status != tt.wantReady {
+				t.Errorf("reconcileDeployment() AdapterReady = %v, want %v", cond.Status, tt.wantReady)
+			}
+			if cond.Reason != tt.wantReason {
+				t.Errorf("reconcileDeployment() AdapterReady reason = %q, want %q", cond.Reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestReconcileVSphereBinding(t *testing.T) {
+	ctx := context.Background()
+	vms := newTestSource()
+	vms.Spec.VAuthSpec = v1alpha1.VAuthSpec{
+		Address:   apis.URL{Scheme: "https", Host: "vcenter.local"},
+		SecretRef: corev1.LocalObjectReference{Name: "vsphere-credentials"},
+	}
+
+	desired := resources.MakeVSphereBinding(ctx, vms)
+
+	drifted := desired.DeepCopy()
+	drifted.Spec.Address.Host = "old-vcenter.local"
+
+	tests := []struct {
+		name       string
+		existing   *v1alpha1.VSphereBinding
+		wantVerbs  []string
+		wantEvents []string
+	}{
+		{
+			name:       "vspherebinding does not exist",
+			existing:   nil,
+			wantVerbs:  []string{"create"},
+			wantEvents: []string{`Normal VSphereBindingCreated Created vspherebinding "source-vspherebinding"`},
+		},
+		{
+			name:       "steady state",
+			existing:   desired.DeepCopy(),
+			wantVerbs:  nil,
+			wantEvents: nil,
+		},
+		{
+			name:       "spec drift",
+			existing:   drifted,
+			wantVerbs:  []string{"update"},
+			wantEvents: []string{`Normal VSphereBindingUpdated Updated vspherebinding "source-vspherebinding"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			ctx := controller.WithEventRecorder(ctx, recorder)
+
+			var objs []runtime.Object
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if tt.existing != nil {
+				objs = append(objs, tt.existing)
+				if err := indexer.Add(tt.existing); err != nil {
+					t.Fatalf("add vspherebinding to indexer: %v", err)
+				}
+			}
+
+			c := fakeclientset.NewSimpleClientset(objs...)
+			r := &Reconciler{
+				client:               c,
+				vspherebindingLister: v1alpha1lister.NewVSphereBindingLister(indexer),
+			}
+
+			if err := r.reconcileVSphereBinding(ctx, vms.DeepCopy()); err != nil {
+				t.Fatalf("reconcileVSphereBinding() error = %v", err)
+			}
+
+			var gotVerbs []string
+			for _, action := range c.Actions() {
+				gotVerbs = append(gotVerbs, action.GetVerb())
+			}
+			if diff := cmp.Diff(tt.wantVerbs, gotVerbs); diff != "" {
+				t.Errorf("reconcileVSphereBinding() unexpected actions (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantEvents, recordedEvents(recorder)); diff != "" {
+				t.Errorf("reconcileVSphereBinding() unexpected events (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+// recordedEvents drains the events recorded by the given recorder.
+func TestReconcileDeploymentCABundle(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	ctx := controller.WithEventRecorder(context.Background(), recorder)
+	vms := newTestSource()
+	vms.Spec.CABundle = &corev1.ConfigMapKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{
+			Name: "corp-ca",
+		},
+		Key: "ca-bundle.pem",
+	}
+
+	var enqueued []types.NamespacedName
+	cmIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	deploymentIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	kc := fake.NewSimpleClientset()
+	r := &Reconciler{
+		kubeclient:       kc,
+		tracker:          tracker.New(func(key types.NamespacedName) { enqueued = append(enqueued, key) }, time.Minute),
+		cmLister:         corev1listers.NewConfigMapLister(cmIndexer),
+		deploymentLister: appsv1listers.NewDeploymentLister(deploymentIndexer),
+		podLister:        corev1listers.NewPodLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+		adapterImage:     "adapter-image",
+	}
+
+	// the ConfigMap does not exist yet
+	if err := r.reconcileDeployment(ctx, vms); err == nil {
+		t.Fatal("reconcileDeployment() succeeded without CA bundle configmap")
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: vms.Namespace,
+			Name:      "corp-ca",
+		},
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		Data: map[string]string{"ca-bundle.pem": "cert"},
+	}
+	if err := cmIndexer.Add(cm); err != nil {
+		t.Fatal(err)
+	}
+
+	// creating the ConfigMap enqueues the source
+	r.tracker.OnChanged(cm)
+	if want := (types.NamespacedName{Namespace: vms.Namespace, Name: vms.Name}); len(enqueued) == 0 || enqueued[0] != want {
+		t.Errorf("tracker enqueued %v, want %v", enqueued, want)
+	}
+
+	hash := func() string {
+		t.Helper()
+		d, err := kc.AppsV1().Deployments(vms.Namespace).Get(ctx, "source-adapter", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("get deployment: %v", err)
+		}
+		if err := deploymentIndexer.Update(d); err != nil {
+			t.Fatal(err)
+		}
+		return d.Spec.Template.Annotations[resources.CABundleHashAnnotationKey]
+	}
+
+	if err := r.reconcileDeployment(ctx, vms); err != nil {
+		t.Fatalf("reconcileDeployment() error = %v", err)
+	}
+	created := hash()
+	if created == "" {
+		t.Fatal("reconcileDeployment() did not set the CA bundle hash")
+	}
+
+	// unchanged content does not roll out the adapter
+	if err := r.reconcileDeployment(ctx, vms); err != nil {
+		t.Fatalf("reconcileDeployment() error = %v", err)
+	}
+
+	cm = cm.DeepCopy()
+	cm.Data["ca-bundle.pem"] = "rotated cert"
+	if err := cmIndexer.Update(cm); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileDeployment(ctx, vms); err != nil {
+		t.Fatalf("reconcileDeployment() error = %v", err)
+	}
+	if updated := hash(); updated == created {
+		t.Errorf("reconcileDeployment() CA bundle hash = %q, want a changed hash", updated)
+	}
+
+	wantEvents := []string{
+		`Normal DeploymentCreated Created deployment "source-adapter"`,
+		`Normal DeploymentUpdated Updated deployment "source-adapter"`,
+	}
+	if diff := cmp.Diff(wantEvents, recordedEvents(recorder)); diff != "" {
+		t.Errorf("reconcileDeployment() unexpected events (-want, +got) = %v", diff)
+	}
+}
+
+func TestReconcileDeploymentCredentials(t *testing.T) {
+	ctx := controller.WithEventRecorder(context.Background(), record.NewFakeRecorder(10))
+	vms := newTestSource()
+	vms.Spec.SecretRef = corev1.LocalObjectReference{Name: "vsphere-credentials"}
+
+	var enqueued []types.NamespacedName
+	deploymentIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	kc := fake.NewSimpleClientset()
+	r := &Reconciler{
+		kubeclient:       kc,
+		tracker:          tracker.New(func(key types.NamespacedName) { enqueued = append(enqueued, key) }, time.Minute),
+		deploymentLister: appsv1listers.NewDeploymentLister(deploymentIndexer),
+		podLister:        corev1listers.NewPodLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+		adapterImage:     "adapter-image",
+	}
+
+	hash := func() string {
+		t.Helper()
+		if err := r.reconcileDeployment(ctx, vms); err != nil {
+			t.Fatalf("reconcileDeployment() error = %v", err)
+		}
+		d, err := kc.AppsV1().Deployments(vms.Namespace).Get(ctx, "source-adapter", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("get deployment: %v", err)
+		}
+		if err := deploymentIndexer.Update(d); err != nil {
+			t.Fatal(err)
+		}
+		return d.Spec.Template.Annotations[resources.CredentialsChecksumAnnotationKey]
+	}
+
+	// the adapter is deployed before the Secret exists
+	if got := hash(); got != "" {
+		t.Errorf("reconcileDeployment() credentials checksum = %q without secret, want none", got)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: vms.Namespace,
+			Name:      "vsphere-credentials",
+		},
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		Data: map[string][]byte{
+			corev1.BasicAuthUsernameKey: []byte("user"),
+			corev1.BasicAuthPasswordKey: []byte("pass"),
+		},
+	}
+	if _, err := kc.CoreV1().Secrets(vms.Namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// creating the Secret enqueues the source
+	r.tracker.OnChanged(secret)
+	if want := (types.NamespacedName{Namespace: vms.Namespace, Name: vms.Name}); len(enqueued) == 0 || enqueued[0] != want {
+		t.Errorf("tracker enqueued %v, want %v", enqueued, want)
+	}
+
+	created := hash()
+	if created == "" {
+		t.Fatal("reconcileDeployment() did not set the credentials checksum")
+	}
+
+	// unrelated keys and an unchanged password do not roll out the adapter
+	secret = secret.DeepCopy()
+	secret.Data["unrelated"] = []byte("value")
+	if _, err := kc.CoreV1().Secrets(vms.Namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := hash(); got != created {
+		t.Errorf("reconcileDeployment() credentials checksum = %q, want unchanged %q", got, created)
+	}
+
+	secret = secret.DeepCopy()
+	secret.Data[corev1.BasicAuthPasswordKey] = []byte("rotated")
+	if _, err := kc.CoreV1().Secrets(vms.Namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := hash(); got == created {
+		t.Errorf("reconcileDeployment() credentials checksum = %q, want a changed checksum", got)
+	}
+}
+
+func recordedEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case e := <-recorder.Events:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestReconcileVSphereBindingAuthFailed(t *testing.T) {
+	failedBinding := func(vms *v1alpha1.VSphereSource) *v1alpha1.VSphereBinding {
+		vsb := resources.MakeVSphereBinding(context.Background(), vms)
+		vsb.Status.InitializeConditions()
+		vsb.Status.MarkBindingUnavailable("LoginFailed", "incorrect user name or password")
+		return vsb
+	}
+
+	tests := []struct {
+		name       string
+		authFailed bool
+		wantEvents []string
+	}{
+		{
+			name:       "transition into failed state",
+			authFailed: false,
+			wantEvents: []string{`Warning AuthFailed Authentication against vCenter "https://vcenter.local" failed: LoginFailed: incorrect user name or password`},
+		},
+		{
+			name:       "already in failed state",
+			authFailed: true,
+			wantEvents: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			ctx := controller.WithEventRecorder(context.Background(), recorder)
+
+			vms := newTestSource()
+			vms.Spec.Address = apis.URL{Scheme: "https", Host: "vcenter.local"}
+			vms.Status.InitializeConditions()
+			if tt.authFailed {
+				vms.Status.PropagateAuthStatus(failedBinding(vms).Status.Status)
+			}
+
+			existing := failedBinding(vms)
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if err := indexer.Add(existing); err != nil {
+				t.Fatalf("add vspherebinding to indexer: %v", err)
+			}
+
+			r := &Reconciler{
+				client:               fakeclientset.NewSimpleClientset(existing),
+				vspherebindingLister: v1alpha1lister.NewVSphereBindingLister(indexer),
+			}
+
+			if err := r.reconcileVSphereBinding(ctx, vms); err != nil {
+				t.Fatalf("reconcileVSphereBinding() error = %v", err)
+			}
+
+			if diff := cmp.Diff(tt.wantEvents, recordedEvents(recorder)); diff != "" {
+				t.Errorf("reconcileVSphereBinding() unexpected events (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestReconcileSink(t *testing.T) {
+	sink := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sink",
+			Namespace: "ns",
+		},
+	}
+
+	ctx, cancel := context.WithCancel(controller.WithEventRecorder(context.Background(), record.NewFakeRecorder(10)))
+	defer cancel()
+
+	vms := newTestSource()
+	vms.Spec.Sink = duckv1.Destination{
+		Ref: &duckv1.KReference{
+			APIVersion: "v1",
+			Kind:       "Service",
+			Namespace:  sink.Namespace,
+			Name:       sink.Name,
+		},
+	}
+	vms.Status.InitializeConditions()
+
+	// sink exists
+	r := &Reconciler{resolver: newTestResolver(ctx, t, sink)}
+	if err := r.reconcileSink(ctx, vms); err != nil {
+		t.Fatalf("reconcileSink() error = %v", err)
+	}
+	if got := vms.Status.GetCondition(v1alpha1.VSphereSourceConditionSinkProvided); !got.IsTrue() {
+		t.Errorf("reconcileSink() SinkProvided = %v, want True", got)
+	}
+	if vms.Status.SinkURI == nil {
+		t.Error("reconcileSink() SinkURI = nil, want resolved URI")
+	}
+
+	// sink has been deleted
+	r = &Reconciler{resolver: newTestResolver(ctx, t)}
+	if err := r.reconcileSink(ctx, vms); err == nil {
+		t.Fatal("reconcileSink() error = nil, want error")
+	}
+	got := vms.Status.GetCondition(v1alpha1.VSphereSourceConditionSinkProvided)
+	if !got.IsFalse() || got.Reason != "SinkNotFound" {
+		t.Errorf("reconcileSink() SinkProvided = %v, want False with reason SinkNotFound", got)
+	}
+	if vms.Status.SinkURI != nil {
+		t.Errorf("reconcileSink() SinkURI = %v, want nil", vms.Status.SinkURI)
+	}
+}
+
+func TestReconcileAdditionalSinks(t *testing.T) {
+	other := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "other",
+			Namespace: "ns",
+		},
+	}
+
+	ctx, cancel := context.WithCancel(controller.WithEventRecorder(context.Background(), record.NewFakeRecorder(10)))
+	defer cancel()
+
+	vms := newTestSource()
+	vms.Spec.Sink = duckv1.Destination{URI: apis.HTTP("sink.example.com")}
+	vms.Spec.Sinks = []duckv1.Destination{{
+		URI: apis.HTTP("audit.example.com"),
+	}, {
+		Ref: &duckv1.KReference{
+			APIVersion: "v1",
+			Kind:       "Service",
+			Namespace:  other.Namespace,
+			Name:       other.Name,
+		},
+	}}
+	vms.Status.InitializeConditions()
+
+	// all sinks exist
+	r := &Reconciler{resolver: newTestResolver(ctx, t, other)}
+	if err := r.reconcileSink(ctx, vms); err != nil {
+		t.Fatalf("reconcileSink() error = %v", err)
+	}
+	want := []string{"http://sink.example.com", "http://audit.example.com", "http://other.ns.svc.cluster.local"}
+	got := make([]string, 0, len(vms.Status.SinkURIs))
+	for _, uri := range vms.Status.SinkURIs {
+		got = append(got, uri.String())
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("reconcileSink() unexpected SinkURIs (-want, +got) = %v", diff)
+	}
+	if vms.Status.SinkURI.String() != want[0] {
+		t.Errorf("reconcileSink() SinkURI = %v, want %v", vms.Status.SinkURI, want[0])
+	}
+
+	// an additional sink has been deleted
+	r = &Reconciler{resolver: newTestResolver(ctx, t)}
+	if err := r.reconcileSink(ctx, vms); err == nil {
+		t.Fatal("reconcileSink() error = nil, want error")
+	}
+	cond := vms.Status.GetCondition(v1alpha1.VSphereSourceConditionSinkProvided)
+	if !cond.IsFalse() || cond.Reason != "SinkNotFound" || !strings.HasPrefix(cond.Message, "sinks[1]: ") {
+		t.Errorf("reconcileSink() SinkProvided = %v, want False with reason SinkNotFound for sinks[1]", cond)
+	}
+	if vms.Status.SinkURIs != nil {
+		t.Errorf("reconcileSink() SinkURIs = %v, want nil", vms.Status.SinkURIs)
+	}
+}
+
+func TestReconcileDeadLetterSink(t *testing.T) {
+	dls := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "dls",
+			Namespace: "ns",
+		},
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	ctx, cancel := context.WithCancel(controller.WithEventRecorder(context.Background(), recorder))
+	defer cancel()
+
+	vms := newTestSource()
+	vms.Spec.DeadLetterSink = &duckv1.Destination{
+		Ref: &duckv1.KReference{
+			APIVersion: "v1",
+			Kind:       "Service",
+			Namespace:  dls.Namespace,
+			Name:       dls.Name,
+		},
+	}
+	vms.Status.InitializeConditions()
+
+	// dead letter sink exists
+	r := &Reconciler{resolver: newTestResolver(ctx, t, dls)}
+	r.reconcileDeadLetterSink(ctx, vms)
+	if got := vms.Status.GetCondition(v1alpha1.VSphereSourceConditionDeadLetterSinkResolved); !got.IsTrue() {
+		t.Errorf("reconcileDeadLetterSink() DeadLetterSinkResolved = %v, want True", got)
+	}
+	if vms.Status.DeadLetterSinkURI == nil {
+		t.Error("reconcileDeadLetterSink() DeadLetterSinkURI = nil, want resolved URI")
+	}
+
+	// dead letter sink has been deleted, the source stays ready
+	vms.Status.MarkSink(apis.HTTP("sink.example.com"))
+	r = &Reconciler{resolver: newTestResolver(ctx, t)}
+	for i := 0; i < 2; i++ {
+		r.reconcileDeadLetterSink(ctx, vms)
+	}
+	got := vms.Status.GetCondition(v1alpha1.VSphereSourceConditionDeadLetterSinkResolved)
+	if !got.IsFalse() || got.Reason != "DeadLetterSinkNotFound" {
+		t.Errorf("reconcileDeadLetterSink() DeadLetterSinkResolved = %v, want False with reason DeadLetterSinkNotFound", got)
+	}
+	if vms.Status.DeadLetterSinkURI != nil {
+		t.Errorf("reconcileDeadLetterSink() DeadLetterSinkURI = %v, want nil", vms.Status.DeadLetterSinkURI)
+	}
+	if cond := vms.Status.GetCondition(apis.ConditionReady); cond.IsFalse() {
+		t.Errorf("reconcileDeadLetterSink() Ready = %v, want not False", cond)
+	}
+
+	// the warning is only recorded once
+	events := recordedEvents(recorder)
+	if len(events) != 1 || !strings.HasPrefix(events[0], "Warning DeadLetterSinkNotFound") {
+		t.Errorf("reconcileDeadLetterSink() unexpected events = %v", events)
+	}
+
+	// dead letter sink has been removed from the spec
+	vms.Spec.DeadLetterSink = nil
+	r.reconcileDeadLetterSink(ctx, vms)
+	if got := vms.Status.GetCondition(v1alpha1.VSphereSourceConditionDeadLetterSinkResolved); got != nil {
+		t.Errorf("reconcileDeadLetterSink() DeadLetterSinkResolved = %v, want nil", got)
+	}
+}
+
+// newTestResolver returns a URI resolver for the given addressable objects.
+func newTestResolver(ctx context.Context, t *testing.T, objs ...runtime.Object) *resolver.URIResolver {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := k8sscheme.AddToScheme(scheme); err != nil {
+		t.Fatalf("add to scheme: %v", err)
+	}
+	ctx, _ = fakedynamicclient.With(ctx, scheme, objs...)
+	ctx = addressable.WithDuck(ctx)
+	return resolver.NewURIResolverFromTracker(ctx, tracker.New(func(types.NamespacedName) {}, 0))
+}
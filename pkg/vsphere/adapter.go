@@ -7,17 +7,30 @@ package vsphere
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"text/template"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/google/uuid"
 	"github.com/jpillora/backoff"
 	"github.com/vmware/govmomi"
 	"github.com/vmware/govmomi/event"
 	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/soap"
 	"github.com/vmware/govmomi/vim25/types"
 	"go.uber.org/zap"
+	"k8s.io/client-go/kubernetes"
 	"knative.dev/eventing/pkg/adapter/v2"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
 	"knative.dev/pkg/kvstore"
 	"knative.dev/pkg/logging"
 
@@ -25,26 +38,171 @@ import (
 )
 
 const (
+	// AdapterComponent is the name of the adapter component, which selects
+	// its log level in the logging config
+	AdapterComponent = "vsphere-source-adapter"
+	// EventTypePrefix is the prefix of the CloudEvent type of all vSphere events
+	EventTypePrefix = "com.vmware.vsphere"
 	// signal unstable event API for converting vSphere events to CE
-	eventTypeFormat = "com.vmware.vsphere.%s.v0"
+	eventTypeFormat = EventTypePrefix + ".%s.v0"
 	// extended attribute to filter on vSphere API version/class
 	ceVSphereAPIKey     = "vsphereapiversion"
 	ceVSphereEventClass = "eventclass"
+	// extended attribute holding the time the adapter sent the event,
+	// the time attribute is the time the event was created in vCenter
+	ceVSphereProcessedTime = "vsphereprocessedtime"
+	// extended attributes describing why an event was sent to the dead
+	// letter sink, as set by Knative eventing
+	ceErrorDestKey = "knativeerrordest"
+	ceErrorCodeKey = "knativeerrorcode"
 	// read up to max events per iteration
 	maxEventsBatch = 100
+	// DefaultPollInterval is the maximum time to wait before polling vCenter
+	// again when no new events were received
+	DefaultPollInterval = 5 * time.Second
 )
 
 type envConfig struct {
 	adapter.EnvConfig
 
+	// SourceName is the name of the VSphereSource of the adapter, which
+	// labels the adapter metrics.
+	SourceName string `envconfig:"VSPHERE_SOURCE_NAME"`
+
 	// KVConfigMap is the name of the configmap to use as our kvstore.
 	KVConfigMap string `envconfig:"VSPHERE_KVSTORE_CONFIGMAP" required:"true"`
 
+	// KVLease is the name of the Lease used as kvstore by the lease
+	// checkpoint backend.
+	KVLease string `envconfig:"VSPHERE_KVSTORE_LEASE"`
+
 	// CheckpointConfig configures the checkpoint behavior of this controller
 	CheckpointConfig string `envconfig:"VSPHERE_CHECKPOINT_CONFIG" default:"{}"`
 
 	// PayloadEncoding configures the encoding format for the cloud event payload
 	PayloadEncoding string `envconfig:"VSPHERE_PAYLOAD_ENCODING" default:"application/xml"`
+
+	// PayloadFormat is the format of the cloud event payload, full or minimal
+	PayloadFormat string `envconfig:"VSPHERE_PAYLOAD_FORMAT" default:"full"`
+
+	// EventFilters is the JSON-encoded list of event filters to apply
+	EventFilters string `envconfig:"VSPHERE_EVENT_FILTERS"`
+
+	// EventTypes restricts the events retrieved from vCenter to the given types
+	EventTypes []string `envconfig:"VSPHERE_EVENT_TYPES"`
+
+	// Categories are the categories of the emitted events, i.e. event, task
+	// and alarm. Only the event category is emitted when empty.
+	Categories []string `envconfig:"VSPHERE_CATEGORIES"`
+
+	// StartFrom is where the event history is read from without checkpoint,
+	// now or earliest
+	StartFrom string `envconfig:"VSPHERE_START_FROM" default:"now"`
+
+	// PollInterval is the maximum time to wait between polls when idle
+	PollInterval time.Duration `envconfig:"VSPHERE_POLL_INTERVAL" default:"5s"`
+
+	// MaxEventAge is the maximum age of the delivered events, older events
+	// are skipped, all events are delivered when zero
+	MaxEventAge time.Duration `envconfig:"VSPHERE_MAX_EVENT_AGE"`
+
+	// ReconnectBackoff is the time to wait before reconnecting to an
+	// unreachable vCenter, doubled with every consecutive failure
+	ReconnectBackoff time.Duration `envconfig:"VSPHERE_RECONNECT_BACKOFF" default:"1s"`
+
+	// DeadLetterSink is the URI events are sent to when delivery to the sink fails
+	DeadLetterSink string `envconfig:"VSPHERE_DEAD_LETTER_SINK"`
+
+	// Sinks are the URIs of additional sinks events are delivered to
+	Sinks []string `envconfig:"VSPHERE_SINKS"`
+
+	// RateLimit is the maximum number of events per second sent to the sinks
+	RateLimit int `envconfig:"VSPHERE_RATE_LIMIT"`
+
+	// RateLimitBurst is the maximum number of events sent at once when the
+	// rate limit allows
+	RateLimitBurst int `envconfig:"VSPHERE_RATE_LIMIT_BURST"`
+
+	// RateLimitOverflowPolicy is the policy of events exceeding the rate
+	// limit, block or drop
+	RateLimitOverflowPolicy string `envconfig:"VSPHERE_RATE_LIMIT_OVERFLOW_POLICY" default:"block"`
+
+	// CESource overrides the CloudEvent source attribute
+	CESource string `envconfig:"VSPHERE_CE_SOURCE"`
+
+	// TypeScheme is the scheme of the CloudEvent types of the emitted events,
+	// native or veb
+	TypeScheme string `envconfig:"VSPHERE_TYPE_SCHEME" default:"native"`
+
+	// SubjectTemplate overrides the CloudEvent subject attribute with a
+	// template evaluated against the vSphere event
+	SubjectTemplate string `envconfig:"VSPHERE_SUBJECT_TEMPLATE"`
+
+	// EntityExtensions enables the extended attributes describing the
+	// vSphere entities an event refers to
+	EntityExtensions bool `envconfig:"VSPHERE_ENTITY_EXTENSIONS" default:"true"`
+
+	// LeaderElectionLease is the name of the Lease used to elect the adapter
+	// replica polling vCenter, leader election is disabled when empty
+	LeaderElectionLease string `envconfig:"VSPHERE_LEADER_ELECTION_LEASE"`
+
+	// FailoverAddresses are the addresses of the vCenters the adapter fails
+	// over to, in order, when the configured vCenter is unreachable
+	FailoverAddresses []string `envconfig:"VSPHERE_FAILOVER_ADDRESSES"`
+
+	// FailoverThreshold is the number of consecutive connection failures
+	// after which the adapter fails over to the next vCenter
+	FailoverThreshold int `envconfig:"VSPHERE_FAILOVER_THRESHOLD" default:"3"`
+
+	// HealthPort is the port of the liveness and readiness endpoints
+	HealthPort int `envconfig:"VSPHERE_HEALTH_PORT" default:"8080"`
+
+	// StallTimeout is the time without progress of the event loop after
+	// which the liveness endpoint fails, disabled when zero
+	StallTimeout time.Duration `envconfig:"VSPHERE_STALL_TIMEOUT" default:"5m"`
+
+	// BatchSize is the maximum number of events sent to the sink in a single
+	// CloudEvents batch
+	BatchSize int `envconfig:"VSPHERE_BATCH_SIZE" default:"1"`
+
+	// BatchTimeout is the maximum time to wait for a batch to fill up
+	BatchTimeout time.Duration `envconfig:"VSPHERE_BATCH_TIMEOUT"`
+
+	// Ordered delivers the events in event key order, sending undelivered
+	// events again before later events
+	Ordered bool `envconfig:"VSPHERE_ORDERED" default:"false"`
+
+	// RetryMax is the maximum number of retries of a failed delivery
+	RetryMax int `envconfig:"VSPHERE_RETRY_MAX"`
+
+	// RetryBackoff is the delay before the first retry of a failed delivery
+	RetryBackoff time.Duration `envconfig:"VSPHERE_RETRY_BACKOFF"`
+
+	// RetryBackoffPolicy is the backoff policy between retries, i.e. linear
+	// or exponential
+	RetryBackoffPolicy string `envconfig:"VSPHERE_RETRY_BACKOFF_POLICY"`
+
+	// SinkTimeout is the maximum time of a single delivery attempt to a sink,
+	// a timed out delivery fails and is retried
+	SinkTimeout time.Duration `envconfig:"VSPHERE_SINK_TIMEOUT" default:"30s"`
+
+	// ShutdownTimeout is the maximum time to deliver pending events and save
+	// the final checkpoint when the adapter is stopped
+	ShutdownTimeout time.Duration `envconfig:"VSPHERE_SHUTDOWN_TIMEOUT" default:"20s"`
+
+	// SinkCACerts is a PEM encoded CA bundle trusted when delivering events
+	// to sinks over HTTPS
+	SinkCACerts string `envconfig:"K_CA_CERTS"`
+
+	// OIDCTokenPath is the path of the OIDC token presented to the sink
+	OIDCTokenPath string `envconfig:"VSPHERE_OIDC_TOKEN_PATH"`
+
+	// ContentEncoding is the content coding of the HTTP body of the delivered
+	// events, applied to the destinations advertising it unless forced
+	ContentEncoding string `envconfig:"VSPHERE_CONTENT_ENCODING"`
+
+	// ForceContentEncoding applies ContentEncoding to all deliveries
+	ForceContentEncoding bool `envconfig:"VSPHERE_FORCE_CONTENT_ENCODING"`
 }
 
 func NewEnvConfig() adapter.EnvConfigAccessor {
@@ -53,59 +211,303 @@ func NewEnvConfig() adapter.EnvConfigAccessor {
 
 // vAdapter implements the vSphereSource adapter to trigger a Sink.
 type vAdapter struct {
-	Logger          *zap.SugaredLogger
-	Namespace       string
-	Source          string
-	VClient         *govmomi.Client
-	VAPIVersion     string
-	CEClient        cloudevents.Client
-	KVStore         kvstore.Interface
-	CpConfig        CheckpointConfig
-	PayloadEncoding string
+	Logger              *zap.SugaredLogger
+	Namespace           string
+	PodName             string
+	Source              string
+	VClient             *govmomi.Client
+	Credentials         func() (*url.Userinfo, error)
+	Addresses           []string
+	Connect             connectFunc
+	FailoverThreshold   int
+	VAPIVersion         string
+	VCenterUUID         string
+	CEClient            cloudevents.Client
+	KVStore             kvstore.Interface
+	CpConfig            CheckpointConfig
+	PayloadEncoding     string
+	PayloadFormat       string
+	EventFilters        []EventFilter
+	EventTypes          []string
+	Categories          categories
+	EntityExtensions    bool
+	StartFrom           string
+	PollInterval        time.Duration
+	MaxEventAge         time.Duration
+	ReconnectBackoff    time.Duration
+	DeadLetterSink      string
+	HealthPort          int
+	Sink                string
+	Sinks               []string
+	BatchSize           int
+	BatchTimeout        time.Duration
+	Ordered             bool
+	HTTPClient          *http.Client
+	RetryParams         *cecontext.RetryParams
+	SinkTimeout         time.Duration
+	RateLimiter         *rateLimiter
+	StatsReporter       statsReporter
+	KubeClient          kubernetes.Interface
+	HeartbeatConfigMap  string
+	LeaderElectionLease string
+	CEOverrides         *duckv1.CloudEventOverrides
+	SubjectTemplate     *template.Template
+	TypeScheme          string
+	ShutdownTimeout     time.Duration
+
+	health         healthServer
+	lastCheckpoint lastCheckpoint
+	heartbeat      heartbeatState
+	// index of the address of the vCenter in Addresses the adapter is
+	// connected to
+	activeAddress      int
+	connectionFailures int
+	// key of the last event delivered before a restart, replayed events up
+	// to this key are skipped
+	deliveredKey int32
 }
 
 func NewAdapter(ctx context.Context, processed adapter.EnvConfigAccessor, ceClient cloudevents.Client) adapter.Adapter {
 	env := processed.(*envConfig)
 	logger := logging.FromContext(ctx)
 
-	vClient, err := NewSOAPClient(ctx)
+	address, err := ReadAddress()
+	if err != nil {
+		logger.Fatalf("unable to read vSphere address: %v", err)
+	}
+
+	addresses := append([]string{address}, env.FailoverAddresses...)
+	if len(env.FailoverAddresses) > 0 {
+		logger.Infow("configuring vCenter failover", zap.Strings("addresses", env.FailoverAddresses),
+			zap.Int("threshold", env.FailoverThreshold))
+	}
+
+	vClient, activeAddress, err := connectFirst(ctx, newSOAPClientAt, addresses)
 	if err != nil {
 		logger.Fatalf("unable to create vSphere client: %v", err)
 	}
 
-	source := vClient.URL().Host
+	// the source does not change when failing over
+	primaryURL, err := soap.ParseURL(address)
+	if err != nil {
+		logger.Fatalf("unable to parse vSphere address: %v", err)
+	}
+	source := primaryURL.Host
 	if source == "" {
 		logger.Fatal("unable to determine vSphere client source: empty host")
 	}
 
-	// setup checkpointing
-	store := kvstore.NewConfigMapKVStore(ctx, env.KVConfigMap, env.Namespace, kubeclient.Get(ctx).CoreV1())
-	if err = store.Init(ctx); err != nil {
-		logger.Fatalf("could not initialize kv store: %v", err)
+	if env.CESource != "" {
+		logger.Infow("overriding cloudevent source", zap.String("source", env.CESource))
+		source = env.CESource
 	}
 
+	// setup checkpointing
 	cpconf, err := newCheckpointConfig(env.CheckpointConfig)
 	if err != nil {
 		logger.Fatalf("could not not read checkpoint config: %v", err)
 	}
 
 	logger.Infow("configuring checkpointing", zap.String("ReplayWindow", cpconf.MaxAge.String()),
-		zap.String("Period", cpconf.Period.String()))
+		zap.String("Period", cpconf.Period.String()), zap.String("Mode", cpconf.Mode),
+		zap.String("Backend", cpconf.Backend))
+
+	kc := kubeclient.Get(ctx)
+	store, err := newCheckpointStore(ctx, cpconf.Backend, env, kc)
+	if err != nil {
+		logger.Fatalf("could not initialize kv store: %v", err)
+	}
 
 	if cpconf.MaxAge == time.Duration(0) {
 		logger.Warn("disabling event replay: maxAge set to 0s")
 	}
 
+	filters, err := newEventFilters(env.EventFilters)
+	if err != nil {
+		logger.Fatalf("could not read event filters: %v", err)
+	}
+
+	if len(filters) > 0 {
+		logger.Infow("configuring event filters", zap.Any("filters", filters))
+	}
+
+	logger.Infow("configuring polling", zap.String("PollInterval", env.PollInterval.String()),
+		zap.String("ReconnectBackoff", env.ReconnectBackoff.String()))
+
+	if env.MaxEventAge > 0 {
+		logger.Infow("skipping stale events", zap.String("MaxEventAge", env.MaxEventAge.String()))
+	}
+
+	if len(env.EventTypes) > 0 {
+		logger.Infow("configuring event types", zap.Strings("types", env.EventTypes))
+	}
+
+	categories, err := newCategories(env.Categories)
+	if err != nil {
+		logger.Fatalf("could not read event categories: %v", err)
+	}
+	logger.Infow("configuring event categories", zap.Strings("categories", categories.list()))
+
+	switch env.StartFrom {
+	case "", StartFromNow:
+	case StartFromEarliest:
+		logger.Infow("configuring start of event history", zap.String("startFrom", env.StartFrom))
+	default:
+		logger.Fatalf("unsupported start of event history %q", env.StartFrom)
+	}
+
+	switch env.PayloadFormat {
+	case "", PayloadFormatFull:
+	case PayloadFormatMinimal:
+		logger.Infow("configuring payload format", zap.String("format", env.PayloadFormat))
+	default:
+		logger.Fatalf("unsupported payload format %q", env.PayloadFormat)
+	}
+
+	switch env.TypeScheme {
+	case "", TypeSchemeNative:
+	case TypeSchemeVEB:
+		logger.Infow("configuring event type scheme", zap.String("scheme", env.TypeScheme))
+	default:
+		logger.Fatalf("unsupported event type scheme %q", env.TypeScheme)
+	}
+
+	var subjectTemplate *template.Template
+	if env.SubjectTemplate != "" {
+		if subjectTemplate, err = ParseSubjectTemplate(env.SubjectTemplate); err != nil {
+			logger.Fatalf("could not parse subject template: %v", err)
+		}
+		logger.Infow("configuring subject template", zap.String("template", env.SubjectTemplate))
+	}
+
+	if !env.EntityExtensions {
+		logger.Info("disabling entity extension attributes")
+	}
+
+	retryParams, err := newRetryParams(env.RetryMax, env.RetryBackoff, env.RetryBackoffPolicy)
+	if err != nil {
+		logger.Fatalf("could not read retry configuration: %v", err)
+	}
+
+	if retryParams != nil {
+		logger.Infow("configuring delivery retries", zap.Int("MaxRetries", retryParams.MaxTries),
+			zap.String("Backoff", retryParams.Period.String()), zap.String("Policy", string(retryParams.Strategy)))
+	}
+
+	if len(env.Sinks) > 0 {
+		logger.Infow("configuring additional sinks", zap.Strings("sinks", env.Sinks))
+	}
+
+	rateLimiter := newRateLimiter(env.RateLimit, env.RateLimitBurst, clock.New())
+	if rateLimiter != nil {
+		switch env.RateLimitOverflowPolicy {
+		case "", OverflowPolicyBlock:
+		case OverflowPolicyDrop:
+			rateLimiter.drop = true
+		default:
+			logger.Fatalf("unsupported rate limit overflow policy %q", env.RateLimitOverflowPolicy)
+		}
+		logger.Infow("configuring rate limit", zap.Int("EventsPerSecond", env.RateLimit),
+			zap.Int("Burst", rateLimiter.limiter.Burst()), zap.String("OverflowPolicy", env.RateLimitOverflowPolicy))
+	}
+
+	// also applied by the CE client, but batches are not sent with it
+	ceOverrides, err := env.GetCloudEventOverrides()
+	if err != nil {
+		logger.Fatalf("could not read cloudevent overrides: %v", err)
+	}
+
+	var transport http.RoundTripper
+	if env.SinkCACerts != "" {
+		if transport, err = newSinkTransport(env.SinkCACerts); err != nil {
+			logger.Fatalf("could not read sink CA certificates: %v", err)
+		}
+		logger.Info("configuring sink CA certificates")
+	}
+
+	if env.OIDCTokenPath != "" {
+		if transport, err = newOIDCTransport(transport, env.GetSink(), env.OIDCTokenPath); err != nil {
+			logger.Fatalf("could not configure OIDC authentication: %v", err)
+		}
+		logger.Infow("configuring OIDC authentication", zap.String("tokenPath", env.OIDCTokenPath))
+	}
+
+	switch env.ContentEncoding {
+	case "":
+	case ContentEncodingGzip:
+		transport = newGzipTransport(transport, env.ForceContentEncoding)
+		logger.Infow("configuring content encoding", zap.String("encoding", env.ContentEncoding),
+			zap.Bool("forced", env.ForceContentEncoding))
+	default:
+		logger.Fatalf("unsupported content encoding %q", env.ContentEncoding)
+	}
+
+	httpClient := &http.Client{}
+	if transport != nil {
+		httpClient.Transport = transport
+
+		// the client of the adapter main always uses the default transport
+		if ceClient, err = newSinkCEClient(env.GetSink(), ceOverrides, transport); err != nil {
+			logger.Fatalf("could not create cloudevents client: %v", err)
+		}
+	}
+
+	if env.LeaderElectionLease != "" {
+		logger.Infow("configuring leader election", zap.String("lease", env.LeaderElectionLease))
+	}
+
+	if env.BatchSize > 1 {
+		logger.Infow("configuring batched delivery", zap.Int("BatchSize", env.BatchSize),
+			zap.String("BatchTimeout", env.BatchTimeout.String()))
+	}
+
 	return &vAdapter{
-		Logger:          logger,
-		Namespace:       env.Namespace,
-		Source:          source,
-		VClient:         vClient,
-		VAPIVersion:     vClient.ServiceContent.About.ApiVersion,
-		CEClient:        ceClient,
-		KVStore:         store,
-		CpConfig:        *cpconf,
-		PayloadEncoding: env.PayloadEncoding,
+		Logger:              logger,
+		Namespace:           env.Namespace,
+		PodName:             env.Name,
+		Source:              source,
+		VClient:             vClient,
+		Credentials:         ReadCredentials,
+		Addresses:           addresses,
+		Connect:             newSOAPClientAt,
+		FailoverThreshold:   env.FailoverThreshold,
+		VAPIVersion:         vClient.ServiceContent.About.ApiVersion,
+		VCenterUUID:         vClient.ServiceContent.About.InstanceUuid,
+		CEClient:            ceClient,
+		KVStore:             store,
+		CpConfig:            *cpconf,
+		PayloadEncoding:     env.PayloadEncoding,
+		PayloadFormat:       env.PayloadFormat,
+		EventFilters:        filters,
+		EventTypes:          env.EventTypes,
+		Categories:          categories,
+		EntityExtensions:    env.EntityExtensions,
+		StartFrom:           env.StartFrom,
+		PollInterval:        env.PollInterval,
+		MaxEventAge:         env.MaxEventAge,
+		ReconnectBackoff:    env.ReconnectBackoff,
+		DeadLetterSink:      env.DeadLetterSink,
+		HealthPort:          env.HealthPort,
+		Sink:                env.GetSink(),
+		Sinks:               env.Sinks,
+		BatchSize:           env.BatchSize,
+		BatchTimeout:        env.BatchTimeout,
+		Ordered:             env.Ordered,
+		HTTPClient:          httpClient,
+		RetryParams:         retryParams,
+		SinkTimeout:         env.SinkTimeout,
+		RateLimiter:         rateLimiter,
+		StatsReporter:       newStatsReporter(env.Namespace, env.SourceName),
+		KubeClient:          kc,
+		HeartbeatConfigMap:  env.KVConfigMap,
+		LeaderElectionLease: env.LeaderElectionLease,
+		CEOverrides:         ceOverrides,
+		SubjectTemplate:     subjectTemplate,
+		TypeScheme:          env.TypeScheme,
+		ShutdownTimeout:     env.ShutdownTimeout,
+
+		health:        healthServer{stallTimeout: env.StallTimeout},
+		activeAddress: activeAddress,
 	}
 }
 
@@ -116,6 +518,18 @@ func (a *vAdapter) Start(ctx context.Context) error {
 		_ = a.VClient.Logout(context.Background()) // best effort, ignoring error
 	}()
 
+	if a.HealthPort > 0 {
+		a.health.checkpoint = http.HandlerFunc(a.serveCheckpoint)
+		go func() {
+			if err := a.health.run(ctx, a.HealthPort); err != nil {
+				a.Logger.Errorw("health server failed", zap.Error(err))
+			}
+		}()
+	}
+
+	if a.LeaderElectionLease != "" {
+		return a.runWithLeaderElection(ctx, a.run)
+	}
 	return a.run(ctx)
 }
 
@@ -125,7 +539,58 @@ func (a *vAdapter) Start(ctx context.Context) error {
 // checkpoint with additional validation logic to avoid unbounded event replay.
 // A checkpoint will be created periodically to track the position in the
 // vCenter event stream. This allows to implement at-least-once semantics.
+// When the vCenter session expires, run logs in again and resumes the event
+// stream from the last checkpoint. When failover addresses are configured,
+// run also resumes the event stream after vCenter was unreachable, failing
+// over to the next vCenter after consecutive connection failures. While run
+// is active, a heartbeat reporting the failures is written to the ConfigMap
+// of the source, also when run fails.
 func (a *vAdapter) run(ctx context.Context) error {
+	if a.KubeClient != nil && a.HeartbeatConfigMap != "" {
+		hbCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go a.runHeartbeat(hbCtx)
+	}
+
+	for {
+		err := a.stream(ctx)
+		if ctx.Err() != nil {
+			return err
+		}
+		a.heartbeat.failed(err)
+
+		switch {
+		case isNotAuthenticated(err):
+			a.Logger.Warnw("vCenter session expired, logging in again", zap.Error(err))
+			if err = a.relogin(ctx); err != nil {
+				return a.fail(ctx, err)
+			}
+		case isConnectionError(err):
+			if err = a.reconnect(ctx, err); err != nil {
+				return a.fail(ctx, err)
+			}
+		default:
+			return a.fail(ctx, err)
+		}
+	}
+}
+
+// fail records the given error which stops the adapter in a final heartbeat
+// and returns it.
+func (a *vAdapter) fail(ctx context.Context, err error) error {
+	if ctx.Err() != nil || a.KubeClient == nil || a.HeartbeatConfigMap == "" {
+		return err
+	}
+	a.heartbeat.failed(err)
+	if hbErr := a.writeHeartbeat(ctx); hbErr != nil {
+		a.Logger.Warnw("could not write final heartbeat", zap.Error(hbErr))
+	}
+	return err
+}
+
+// stream reads events from vCenter with a new event (history) collector
+// until ctx is done or reading events fails.
+func (a *vAdapter) stream(ctx context.Context) error {
 	var cp checkpoint
 	if err := a.KVStore.Get(ctx, checkpointKey, &cp); err != nil {
 		logging.FromContext(ctx).Warnw("could not retrieve checkpoint configuration", zap.Error(err))
@@ -137,40 +602,119 @@ func (a *vAdapter) run(ctx context.Context) error {
 	}
 
 	begin := getBeginFromCheckpoint(ctx, *vcTime, cp, a.CpConfig.MaxAge)
-	coll, err := newHistoryCollector(ctx, a.VClient.Client, begin)
+	if !cp.LastEventKeyTimestamp.IsZero() {
+		a.lastCheckpoint.set(cp)
+	} else {
+		begin = a.initCheckpoint(ctx, *vcTime)
+	}
+	a.deliveredKey = deliveredEventKey(cp, a.VClient.URL().Host, a.CpConfig)
+	if a.deliveredKey > 0 {
+		logging.FromContext(ctx).Infow("skipping replayed events already delivered", zap.Int32("eventKey", a.deliveredKey))
+	}
+
+	coll, err := newHistoryCollector(ctx, a.VClient.Client, begin, a.Categories.collectedEventTypes(a.EventTypes))
 	if err != nil {
 		return fmt.Errorf("create event collector: %w", err)
 	}
 
+	// vCenter session and event stream are active
+	a.connectionFailures = 0
+	a.health.setReady(true)
+	a.health.setStreaming(true)
+	a.StatsReporter.ReportSessionActive(true)
+	defer func() {
+		a.health.setReady(false)
+		a.health.setStreaming(false)
+		a.StatsReporter.ReportSessionActive(false)
+	}()
+
 	return a.readEvents(ctx, coll)
 }
 
 // readEvents polls vCenter for new events starting at the configured begin time
 // in the provided event history collector. A checkpoint will be periodically
 // created and stored in Kubernetes to track successfully processed events
-// (ACK-ed by sink).
+// (ACK-ed by sink). When ctx is done, pending events are delivered and a final
+// checkpoint is saved within the shutdown timeout before returning.
 func (a *vAdapter) readEvents(ctx context.Context, c *event.HistoryCollector) error {
 	logger := logging.FromContext(ctx)
 
 	var (
 		lastEvent              types.BaseEvent
 		lastCheckpointEventKey int32
+
+		// events read but not sent yet when batching
+		pending      []types.BaseEvent
+		pendingSince time.Time
+
+		// pending holds undelivered events to send again before reading new
+		// events when ordered
+		redeliver bool
 	)
 
+	pollInterval := a.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+
+	minDelay := time.Second
+	if pollInterval < minDelay {
+		minDelay = pollInterval
+	}
+
 	bOff := backoff.Backoff{
 		Factor: 2,
 		Jitter: false,
-		Min:    time.Second,
-		Max:    5 * time.Second,
+		Min:    minDelay,
+		Max:    pollInterval,
+	}
+
+	shutdownTimeout := a.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = DefaultShutdownTimeout
+	}
+
+	// deliveries and checkpoints outlive ctx until the shutdown timeout
+	deliveryCtx, cancel := shutdownContext(ctx, shutdownTimeout)
+	defer cancel()
+
+	// stop delivers the pending events and saves the final checkpoint
+	stop := func() error {
+		logger.Infow("stopping event stream", zap.Int("pendingEvents", len(pending)))
+
+		if len(pending) > 0 {
+			n, err := a.sendEvents(deliveryCtx, pending)
+			if err != nil {
+				logger.Errorf("send pending events: success %d (total %d): %v", n, len(pending), err)
+			}
+			if n > 0 {
+				lastEvent = pending[n-1]
+				if err = a.setCheckpoint(deliveryCtx, lastEvent); err != nil {
+					return fmt.Errorf("set final checkpoint: %w", err)
+				}
+			}
+		}
+
+		if lastEvent != nil && lastCheckpointEventKey != lastEvent.GetEvent().Key {
+			if err := saveCheckpoint(deliveryCtx, a.KVStore); err != nil {
+				a.StatsReporter.ReportCheckpointFailure()
+				return fmt.Errorf("save final checkpoint: %w", err)
+			}
+			logger.Infow("saved final checkpoint", zap.Int32("eventKey", lastEvent.GetEvent().Key))
+		}
+
+		return ctx.Err()
 	}
 
 	cpTicker := time.NewTicker(a.CpConfig.Period)
 	defer cpTicker.Stop()
 
 	for {
+		a.health.progress(0)
+
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return stop()
 
 		// checkpoints
 		case <-cpTicker.C:
@@ -183,7 +727,8 @@ func (a *vAdapter) readEvents(ctx context.Context, c *event.HistoryCollector) er
 				}
 
 				logger.Debugw("creating checkpoint", zap.Any("checkpoint", current))
-				if err := a.KVStore.Save(ctx); err != nil {
+				if err := saveCheckpoint(deliveryCtx, a.KVStore); err != nil {
+					a.StatsReporter.ReportCheckpointFailure()
 					return fmt.Errorf("save checkpoint: %w", err)
 				}
 				lastCheckpointEventKey = lastEvent.GetEvent().Key
@@ -193,46 +738,94 @@ func (a *vAdapter) readEvents(ctx context.Context, c *event.HistoryCollector) er
 
 		// poll vCenter events
 		default:
-			events, err := c.ReadNextEvents(ctx, maxEventsBatch)
-			if err != nil {
-				return fmt.Errorf("read events from vcenter: %w", err)
-			}
+			// undelivered events are sent again before new events are read
+			if !redeliver {
+				readCtx, span := startReadSpan(ctx)
+				newEvents, err := c.ReadNextEvents(readCtx, maxEventsBatch)
+				endReadSpan(span, newEvents, err)
+				if err != nil {
+					if ctx.Err() != nil {
+						return stop()
+					}
+					return fmt.Errorf("read events from vcenter: %w", err)
+				}
 
-			if len(events) == 0 {
-				delay := bOff.Duration()
-				logger.Debugw("backing off retrieving events: no new events received", zap.Duration("backoffSeconds", delay))
-				time.Sleep(delay)
-				continue
+				if len(newEvents) == 0 && len(pending) == 0 {
+					// reading from vCenter works and nothing is undelivered
+					a.heartbeat.succeeded()
+				}
+
+				if len(newEvents) > 0 {
+					logger.Debugf("got %d events", len(newEvents))
+					if len(pending) == 0 {
+						pendingSince = time.Now()
+					}
+					pending = append(pending, newEvents...)
+					if a.Ordered {
+						sortByKey(pending)
+					}
+				}
+
+				// wait for more events to fill up a batch
+				batchFull := len(pending) >= a.BatchSize || time.Since(pendingSince) >= a.BatchTimeout
+				if len(pending) == 0 || !batchFull {
+					if len(newEvents) == 0 {
+						delay := bOff.Duration()
+						logger.Debugw("backing off retrieving events: no new events received", zap.Duration("backoffSeconds", delay))
+						a.health.progress(delay)
+						select {
+						case <-ctx.Done():
+						case <-time.After(delay):
+						}
+					}
+					continue
+				}
 			}
 
-			logger.Debugf("got %d events", len(events))
+			events := pending
+			pending, redeliver = nil, false
 
-			n, err := a.sendEvents(ctx, events)
+			n, err := a.sendEvents(deliveryCtx, events)
+			a.heartbeat.addDelivered(n)
 			if err != nil {
+				a.heartbeat.failed(err)
+
 				// TODO: return and fail instead?
 				logger.Errorf("send events: success %d (total %d): %v", n, len(events), err)
 
-				// 	special case: all events failed so skipping checkpoint
-				if n == 0 {
+				if a.Ordered {
+					// later events must not overtake the undelivered ones
+					pending, redeliver = events[n:], true
+				} else if n == 0 {
+					// 	special case: all events failed so skipping checkpoint
 					continue
 				}
+			} else {
+				a.heartbeat.succeeded()
 			}
 
 			if n == 0 && err == nil {
 				panic("we should never get here")
 			}
 
-			// last successfully sent event from batch
-			lastEvent = events[n-1]
-			cp := checkpoint{
-				VCenter:               a.Source,
-				LastEventKey:          lastEvent.GetEvent().Key,
-				LastEventType:         getEventDetails(lastEvent).Type,
-				LastEventKeyTimestamp: lastEvent.GetEvent().CreatedTime,
-				CreatedTimestamp:      time.Now().UTC(),
+			if n > 0 {
+				// last successfully sent event from batch
+				lastEvent = events[n-1]
+				if err = a.setCheckpoint(deliveryCtx, lastEvent); err != nil {
+					return fmt.Errorf("set checkpoint: %w", err)
+				}
 			}
-			if err = a.KVStore.Set(ctx, checkpointKey, cp); err != nil {
-				return fmt.Errorf("set checkpoint: %w", err)
+
+			if redeliver {
+				delay := bOff.Duration()
+				logger.Debugw("backing off redelivering events", zap.Int("pendingEvents", len(pending)),
+					zap.Duration("backoffSeconds", delay))
+				a.health.progress(delay)
+				select {
+				case <-ctx.Done():
+				case <-time.After(delay):
+				}
+				continue
 			}
 
 			bOff.Reset()
@@ -240,28 +833,69 @@ func (a *vAdapter) readEvents(ctx context.Context, c *event.HistoryCollector) er
 	}
 }
 
+// sortByKey sorts the given events by their key, i.e. in the order in which
+// they were created in vCenter.
+func sortByKey(events []types.BaseEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].GetEvent().Key < events[j].GetEvent().Key
+	})
+}
+
+// setCheckpoint updates the checkpoint in the KV store to the given last
+// successfully sent event. The checkpoint is persisted with saveCheckpoint.
+func (a *vAdapter) setCheckpoint(ctx context.Context, lastEvent types.BaseEvent) error {
+	cp := checkpoint{
+		VCenter:               a.VClient.URL().Host,
+		LastEventKey:          lastEvent.GetEvent().Key,
+		LastEventType:         getEventDetails(lastEvent).Type,
+		LastEventKeyTimestamp: lastEvent.GetEvent().CreatedTime,
+		CreatedTimestamp:      time.Now().UTC(),
+	}
+	if err := a.KVStore.Set(ctx, checkpointKey, cp); err != nil {
+		return err
+	}
+	a.lastCheckpoint.set(cp)
+	a.StatsReporter.ReportCheckpointLag(time.Since(cp.LastEventKeyTimestamp))
+	return nil
+}
+
 // sendEvents converts all events to cloud events and sends them to the
-// configured sink. It returns the number of successfully processed events,
-// which might 0, partial or all events. sendEvents returns when all events are
-// processed or on the first error.
+// configured sinks. It returns the number of successfully processed events,
+// which might 0, partial or all events. Events not matching the configured
+// categories or event filters, delivered before a restart or older than
+// MaxEventAge are skipped but counted as processed. sendEvents returns when
+// all events are processed or on the first error.
 func (a *vAdapter) sendEvents(ctx context.Context, baseEvents []types.BaseEvent) (int, error) {
+	for _, be := range baseEvents {
+		a.StatsReporter.ReportEventReceived(a.eventType(be))
+	}
+	a.StatsReporter.ReportEventsInFlight(len(baseEvents))
+	defer a.StatsReporter.ReportEventsInFlight(0)
+
+	if a.BatchSize > 1 {
+		return a.sendEventBatches(ctx, baseEvents)
+	}
+
 	var success int
 
 	for _, be := range baseEvents {
-		ev := cloudevents.NewEvent(cloudevents.VersionV1)
-		ev.SetSource(a.Source)
-
-		details := getEventDetails(be)
+		if a.skipEvent(be) {
+			success++
+			continue
+		}
 
-		// CE envelop
-		ev.SetID(fmt.Sprintf("%d", be.GetEvent().Key))
-		ev.SetType(fmt.Sprintf(eventTypeFormat, details.Type))
-		ev.SetTime(be.GetEvent().CreatedTime)
-		ev.SetExtension(ceVSphereEventClass, details.Class)
-		ev.SetExtension(ceVSphereAPIKey, a.VAPIVersion)
+		send, err := a.throttle(ctx, be)
+		if err != nil {
+			return success, err
+		}
+		if !send {
+			success++
+			continue
+		}
 
-		if err := ev.SetData(a.PayloadEncoding, be); err != nil {
-			return success, fmt.Errorf("set data on event: %w", err)
+		ev, err := a.newCloudEvent(be)
+		if err != nil {
+			return success, err
 		}
 
 		// TODO: better partial batch failure handling here?
@@ -271,10 +905,11 @@ func (a *vAdapter) sendEvents(ctx context.Context, baseEvents []types.BaseEvent)
 			zap.Any("data", be),
 		)
 
-		result := a.CEClient.Send(ctx, ev)
-		if !cloudevents.IsACK(result) {
-			logging.FromContext(ctx).Errorw("failed to send cloudevent", zap.Error(result))
-			return success, result
+		sendCtx, span := startEventSpan(ctx, be, &ev)
+		results := a.sendEvent(sendCtx, ev)
+		endEventSpan(span, a.firstFailure(results))
+		if err := a.handleFailures(ctx, []cloudevents.Event{ev}, results); err != nil {
+			return success, err
 		}
 		success++
 	}
@@ -282,6 +917,210 @@ func (a *vAdapter) sendEvents(ctx context.Context, baseEvents []types.BaseEvent)
 	return success, nil
 }
 
+// sendEvent sends the given event to the sink and all additional sinks. Each
+// delivery is retried independently according to the configured retry
+// parameters. The delivery results are returned by sink.
+func (a *vAdapter) sendEvent(ctx context.Context, ev cloudevents.Event) map[string]error {
+	results := make(map[string]error, len(a.Sinks)+1)
+
+	// the client targets the sink unless overridden in the context
+	results[a.Sink] = a.sendWithRetries(ctx, []cloudevents.Event{ev}, a.sendSingle)
+
+	for _, sink := range a.Sinks {
+		sinkCtx := cecontext.WithTarget(ctx, sink)
+		results[sink] = a.sendWithRetries(sinkCtx, []cloudevents.Event{ev}, a.sendSingle)
+	}
+	return results
+}
+
+// sendSingle sends the only event of the given events with the CloudEvents
+// client.
+func (a *vAdapter) sendSingle(ctx context.Context, events []cloudevents.Event) error {
+	return a.CEClient.Send(ctx, events[0])
+}
+
+// handleFailures reports the delivery of the given events based on the
+// delivery results by sink. Events which could not be delivered to a sink are
+// sent to the dead letter sink, if configured. An error is returned if the
+// events could not be delivered to all sinks or the dead letter sink.
+func (a *vAdapter) handleFailures(ctx context.Context, events []cloudevents.Event, results map[string]error) error {
+	failure := a.firstFailure(results)
+	for _, ev := range events {
+		if failure != nil {
+			a.StatsReporter.ReportEventFailed(ev.Type(), resultStatusCode(failure))
+		} else {
+			a.StatsReporter.ReportEventDelivered(ev.Type())
+		}
+	}
+
+	if failure == nil {
+		return nil
+	}
+
+	for _, sink := range a.allSinks() {
+		result := results[sink]
+		if cloudevents.IsACK(result) {
+			continue
+		}
+
+		logging.FromContext(ctx).Errorw("failed to send cloudevents", zap.String("sink", sink),
+			zap.Int("count", len(events)), zap.Error(result))
+		if a.DeadLetterSink == "" {
+			return result
+		}
+
+		for _, ev := range events {
+			if err := a.sendToDeadLetterSink(ctx, ev, sink, result); err != nil {
+				return fmt.Errorf("%v: %w", result, err)
+			}
+		}
+	}
+	return nil
+}
+
+// allSinks returns the sink followed by the additional sinks.
+func (a *vAdapter) allSinks() []string {
+	return append([]string{a.Sink}, a.Sinks...)
+}
+
+// firstFailure returns the failed delivery result of the first sink in
+// allSinks order which did not accept the events, if any.
+func (a *vAdapter) firstFailure(results map[string]error) error {
+	for _, sink := range a.allSinks() {
+		if result := results[sink]; !cloudevents.IsACK(result) {
+			return result
+		}
+	}
+	return nil
+}
+
+// EventType returns the CloudEvent type of the given vSphere event type, e.g.
+// VmPoweredOnEvent.
+func EventType(vEventType string) string {
+	return fmt.Sprintf(eventTypeFormat, vEventType)
+}
+
+// eventID returns the CloudEvent ID of the vCenter event with the given key.
+// Event keys are only unique within a vCenter, so the ID is prefixed with the
+// vCenter instance UUID, if known, for consumers to deduplicate events.
+func eventID(vcenterUUID string, key int32) string {
+	if vcenterUUID == "" {
+		return strconv.Itoa(int(key))
+	}
+	return fmt.Sprintf("%s/%d", vcenterUUID, key)
+}
+
+// skipEvent returns true if the given event is not delivered because it does
+// not match the configured categories or event filters, was delivered before a
+// restart or is older than MaxEventAge. Replayed events which were delivered
+// before are reported as deduplicated.
+func (a *vAdapter) skipEvent(be types.BaseEvent) bool {
+	if !a.Categories.match(be) || !matchEventFilters(a.EventFilters, be) {
+		return true
+	}
+	if a.isDelivered(be) {
+		a.StatsReporter.ReportEventDeduplicated(a.eventType(be))
+		return true
+	}
+	return a.isStale(be)
+}
+
+// isDelivered returns true if the given event was delivered before the
+// adapter restarted according to the last checkpoint.
+func (a *vAdapter) isDelivered(be types.BaseEvent) bool {
+	return a.deliveredKey > 0 && be.GetEvent().Key <= a.deliveredKey
+}
+
+// isStale returns true if the given event was created more than MaxEventAge
+// ago, e.g. when catching up after an outage, and should not be delivered.
+func (a *vAdapter) isStale(be types.BaseEvent) bool {
+	return a.MaxEventAge > 0 && time.Since(be.GetEvent().CreatedTime) > a.MaxEventAge
+}
+
+// newCloudEvent converts the given vCenter event to a cloud event.
+func (a *vAdapter) newCloudEvent(be types.BaseEvent) (cloudevents.Event, error) {
+	ev := cloudevents.NewEvent(cloudevents.VersionV1)
+	ev.SetSource(a.Source)
+
+	details := getEventDetails(be)
+
+	// CE envelop
+	if key := be.GetEvent().Key; key > 0 {
+		ev.SetID(eventID(a.VCenterUUID, key))
+	} else {
+		// event keys start at 1, replays of this event get a different ID
+		id := uuid.New().String()
+		a.Logger.Warnw("event has no key, using a random CloudEvent ID",
+			zap.String("ID", id), zap.String("eventType", details.Type))
+		ev.SetID(id)
+	}
+	ev.SetType(a.eventType(be))
+	ev.SetTime(be.GetEvent().CreatedTime)
+	subject := eventSubject(be)
+	if a.SubjectTemplate != nil {
+		subject = a.templateSubject(be)
+	}
+	if subject != "" {
+		ev.SetSubject(subject)
+	}
+	ev.SetExtension(ceVSphereEventClass, details.Class)
+	ev.SetExtension(ceVSphereAPIKey, a.VAPIVersion)
+	if a.EntityExtensions {
+		setEntityExtensions(&ev, be)
+	}
+	if a.CEOverrides != nil {
+		for name, value := range a.CEOverrides.Extensions {
+			ev.SetExtension(name, value)
+		}
+	}
+
+	if err := ev.SetData(a.PayloadEncoding, a.eventData(be)); err != nil {
+		return ev, fmt.Errorf("set data on event: %w", err)
+	}
+	return ev, nil
+}
+
+// sendToDeadLetterSink sends the given event, which could not be delivered to
+// the sink dest because of the given error, to the configured dead letter
+// sink.
+func (a *vAdapter) sendToDeadLetterSink(ctx context.Context, ev cloudevents.Event, dest string, cause error) error {
+	logging.FromContext(ctx).Warnw("sending cloudevent to dead letter sink",
+		zap.String("ID", ev.ID()),
+		zap.String("deadLetterSink", a.DeadLetterSink),
+	)
+
+	ev = ev.Clone()
+	if dest != "" {
+		ev.SetExtension(ceErrorDestKey, dest)
+	}
+	if code := resultStatusCode(cause); code > 0 {
+		ev.SetExtension(ceErrorCodeKey, strconv.Itoa(code))
+	}
+
+	dlsCtx := cecontext.WithTarget(ctx, a.DeadLetterSink)
+	result := a.sendWithRetries(dlsCtx, []cloudevents.Event{ev}, a.sendSingle)
+	if !cloudevents.IsACK(result) {
+		logging.FromContext(ctx).Errorw("failed to send cloudevent to dead letter sink", zap.Error(result))
+		return fmt.Errorf("send to dead letter sink: %w", result)
+	}
+	return nil
+}
+
+// resultStatusCode returns the HTTP status code of the given delivery result
+// or 0 if the sink did not respond.
+func resultStatusCode(result error) int {
+	var retries *cehttp.RetriesResult
+	if errors.As(result, &retries) {
+		result = retries.Result
+	}
+
+	var res *cehttp.Result
+	if errors.As(result, &res) {
+		return res.StatusCode
+	}
+	return 0
+}
+
 // getBeginFromCheckpoint returns the valid begin time to start replaying
 // vCenter events. If the checkpoint is empty the current vCenter time (UTC) is
 // used. If the last checkpoint event timestamp is larger than maxAge, replay
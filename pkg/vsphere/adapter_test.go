@@ -8,9 +8,14 @@ package vsphere
 import (
 	"context"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
@@ -23,20 +28,28 @@ import (
 	"github.com/cloudevents/sdk-go/v2/client"
 	cecontext "github.com/cloudevents/sdk-go/v2/context"
 	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/extensions"
 	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
 	"github.com/google/go-cmp/cmp"
+	"github.com/kelseyhightower/envconfig"
 	"github.com/vmware/govmomi"
+	vevent "github.com/vmware/govmomi/event"
 	"github.com/vmware/govmomi/session"
 	"github.com/vmware/govmomi/simulator"
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/types"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
 	"knative.dev/pkg/kvstore"
+	"knative.dev/pkg/logging"
 )
 
 const (
 	source    = "https://vcenter.local/sdk"
+	testSink  = "http://sink.example.com"
 	failNever = -1
 )
 
@@ -44,6 +57,7 @@ type roundTripperTest struct {
 	statusCodes  []int
 	requestCount int
 	events       []*event.Event
+	traceParents []string
 }
 
 func (r *roundTripperTest) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -53,11 +67,27 @@ func (r *roundTripperTest) RoundTrip(req *http.Request) (*http.Response, error)
 	if err != nil {
 		return nil, err
 	}
+	// the trace context differs for every send, record it separately
+	if tp, ok := e.Extensions()[extensions.TraceParentExtension]; ok {
+		r.traceParents = append(r.traceParents, tp.(string))
+		e.SetExtension(extensions.TraceParentExtension, nil)
+	}
+	// the processed time differs for every send
+	e.SetExtension(ceVSphereProcessedTime, nil)
 	r.events = append(r.events, e)
 	r.requestCount++
 	return &http.Response{StatusCode: code}, nil
 }
 
+// deadLetterEvent returns a copy of the given event with the extensions set
+// when it is sent to the dead letter sink.
+func deadLetterEvent(ev *event.Event, dest string, code int) *event.Event {
+	dlsEvent := ev.Clone()
+	dlsEvent.SetExtension(ceErrorDestKey, dest)
+	dlsEvent.SetExtension(ceErrorCodeKey, strconv.Itoa(code))
+	return &dlsEvent
+}
+
 type mockType struct {
 	event *types.Event
 }
@@ -76,10 +106,11 @@ func TestSendEvents(t *testing.T) {
 	events := createTestEvents(3, source, now)
 
 	testCases := map[string]struct {
-		statusCodes []int
-		baseEvents  []types.BaseEvent
-		wantEvents  []*event.Event
-		result      sendResult
+		statusCodes    []int
+		deadLetterSink string
+		baseEvents     []types.BaseEvent
+		wantEvents     []*event.Event
+		result         sendResult
 	}{
 		"one event, succeeds": {
 			statusCodes: createStatusCodes(1, failNever),
@@ -117,6 +148,26 @@ func TestSendEvents(t *testing.T) {
 				err:   errors.New("500: "),
 			},
 		},
+		"one event, fails, dead letter sink succeeds": {
+			statusCodes:    []int{500, 200},
+			deadLetterSink: "http://dls.example.com",
+			baseEvents:     events.vEvents[:1],
+			wantEvents:     []*event.Event{events.ceEvents[0], deadLetterEvent(events.ceEvents[0], testSink, 500)},
+			result: sendResult{
+				count: 1,
+				err:   nil,
+			},
+		},
+		"one event, fails, dead letter sink fails": {
+			statusCodes:    []int{500, 500},
+			deadLetterSink: "http://dls.example.com",
+			baseEvents:     events.vEvents[:1],
+			wantEvents:     []*event.Event{events.ceEvents[0], deadLetterEvent(events.ceEvents[0], testSink, 500)},
+			result: sendResult{
+				count: 0,
+				err:   errors.New("500: : send to dead letter sink: 500: "),
+			},
+		},
 		"three events, all succeed": {
 			statusCodes: createStatusCodes(3, failNever),
 			baseEvents:  events.vEvents[:3],
@@ -151,6 +202,9 @@ func TestSendEvents(t *testing.T) {
 				Source:          source,
 				PayloadEncoding: cloudevents.ApplicationXML,
 				VAPIVersion:     "6.7.0",
+				StatsReporter:   &fakeStatsReporter{},
+				DeadLetterSink:  tc.deadLetterSink,
+				Sink:            testSink,
 			}
 			count, result := adapter.sendEvents(ctx, tc.baseEvents)
 
@@ -177,6 +231,113 @@ func TestSendEvents(t *testing.T) {
 	}
 }
 
+func TestSendEventsPayloadEncoding(t *testing.T) {
+	now := time.Now().UTC()
+
+	vmEvent := &types.VmPoweredOnEvent{
+		VmEvent: types.VmEvent{
+			Event: types.Event{
+				Key:         1000,
+				CreatedTime: now,
+				Vm: &types.VmEventArgument{
+					EntityEventArgument: types.EntityEventArgument{Name: "vm-1"},
+					Vm:                  types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-42"},
+				},
+			},
+		},
+	}
+
+	eventEx := &types.EventEx{
+		Event: types.Event{
+			Key:         1001,
+			CreatedTime: now,
+		},
+		EventTypeId: "com.vmware.vc.VmDiskConsolidatedEvent",
+	}
+
+	testCases := map[string]struct {
+		encoding  string
+		baseEvent types.BaseEvent
+		decoded   types.BaseEvent
+		unmarshal func([]byte, interface{}) error
+	}{
+		"VmPoweredOnEvent as JSON": {
+			encoding:  cloudevents.ApplicationJSON,
+			baseEvent: vmEvent,
+			decoded:   &types.VmPoweredOnEvent{},
+			unmarshal: json.Unmarshal,
+		},
+		"VmPoweredOnEvent as XML": {
+			encoding:  cloudevents.ApplicationXML,
+			baseEvent: vmEvent,
+			decoded:   &types.VmPoweredOnEvent{},
+			unmarshal: xml.Unmarshal,
+		},
+		"EventEx as JSON": {
+			encoding:  cloudevents.ApplicationJSON,
+			baseEvent: eventEx,
+			decoded:   &types.EventEx{},
+			unmarshal: json.Unmarshal,
+		},
+		"EventEx as XML": {
+			encoding:  cloudevents.ApplicationXML,
+			baseEvent: eventEx,
+			decoded:   &types.EventEx{},
+			unmarshal: xml.Unmarshal,
+		},
+	}
+	for n, tc := range testCases {
+		ctx := context.Background()
+		ctx = cecontext.WithTarget(ctx, "fake.example.com")
+		t.Run(n, func(t *testing.T) {
+			roundTripper := &roundTripperTest{statusCodes: createStatusCodes(1, failNever)}
+			p, err := cehttp.New(cehttp.WithRoundTripper(roundTripper))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+			if err != nil {
+				t.Fatal(err)
+			}
+			logger := zaptest.NewLogger(t, zaptest.WrapOptions(zap.AddCaller()))
+
+			adapter := vAdapter{
+				Logger:          logger.Sugar(),
+				CEClient:        c,
+				Source:          source,
+				PayloadEncoding: tc.encoding,
+				VAPIVersion:     "6.7.0",
+				StatsReporter:   &fakeStatsReporter{},
+			}
+
+			if _, err = adapter.sendEvents(ctx, []types.BaseEvent{tc.baseEvent}); err != nil {
+				t.Fatalf("sendEvents() error = %v", err)
+			}
+
+			got := roundTripper.events[0]
+			if err = got.Validate(); err != nil {
+				t.Errorf("received invalid cloudevent: %v", err)
+			}
+
+			if got.DataContentType() != tc.encoding {
+				t.Errorf("DataContentType() = %q, want %q", got.DataContentType(), tc.encoding)
+			}
+
+			if err = tc.unmarshal(got.Data(), tc.decoded); err != nil {
+				t.Fatalf("unmarshal event data: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.baseEvent.GetEvent().Key, tc.decoded.GetEvent().Key); diff != "" {
+				t.Error("unexpected diff in event key", diff)
+			}
+
+			if diff := cmp.Diff(getEventDetails(tc.baseEvent), getEventDetails(tc.decoded)); diff != "" {
+				t.Error("unexpected diff in event details", diff)
+			}
+		})
+	}
+}
+
 type testEvents struct {
 	vEvents  []types.BaseEvent
 	ceEvents []*event.Event
@@ -298,6 +459,7 @@ func Test_vAdapter_run(t *testing.T) {
 		Source      string
 		KVStore     kvstore.Interface
 		CpConfig    CheckpointConfig
+		StartFrom   string
 	}
 	tests := []struct {
 		name              string
@@ -310,13 +472,32 @@ func Test_vAdapter_run(t *testing.T) {
 			fields: fields{
 				StatusCodes: nil, // we don't send any events
 				Source:      source,
-				KVStore:     &fakeKVStore{},
+				KVStore: &fakeKVStore{
+					dataChan: make(chan string, 1),
+				},
+				CpConfig: CheckpointConfig{
+					MaxAge: CheckpointDefaultAge,
+					Period: time.Millisecond,
+				},
+			},
+			wantCheckpointKey: 0, // initial checkpoint at the current vCenter time
+			wantRunErr:        context.Canceled,
+		},
+		{
+			name: "no existing checkpoint, start from earliest and all sends succeed",
+			fields: fields{
+				StatusCodes: createStatusCodes(vcsimEvents, failNever),
+				Source:      source,
+				KVStore: &fakeKVStore{
+					dataChan: make(chan string, 1),
+				},
 				CpConfig: CheckpointConfig{
 					MaxAge: CheckpointDefaultAge,
 					Period: time.Millisecond,
 				},
+				StartFrom: StartFromEarliest,
 			},
-			wantCheckpointKey: 0, // we never checkpoint in this test
+			wantCheckpointKey: 26,
 			wantRunErr:        context.Canceled,
 		},
 		{
@@ -382,12 +563,14 @@ func Test_vAdapter_run(t *testing.T) {
 					SessionManager: session.NewManager(vim),
 				}
 				a := &vAdapter{
-					Logger:   logger.Sugar(),
-					Source:   tt.fields.Source,
-					VClient:  &vcClient,
-					CEClient: c,
-					KVStore:  tt.fields.KVStore,
-					CpConfig: tt.fields.CpConfig,
+					Logger:        logger.Sugar(),
+					Source:        tt.fields.Source,
+					VClient:       &vcClient,
+					CEClient:      c,
+					KVStore:       tt.fields.KVStore,
+					CpConfig:      tt.fields.CpConfig,
+					StartFrom:     tt.fields.StartFrom,
+					StatsReporter: &fakeStatsReporter{},
 				}
 
 				ctx, cancel := context.WithCancel(ctx)
@@ -452,6 +635,240 @@ func Test_vAdapter_run(t *testing.T) {
 	}
 }
 
+// orderedSink records the IDs of the accepted events in the order of their
+// delivery. Deliveries are delayed and the configured requests fail.
+type orderedSink struct {
+	sync.Mutex
+	// failures are the numbers of the requests which fail, starting at 1
+	failures map[int]bool
+	// want is the number of events after which done is closed
+	want int
+	done chan struct{}
+
+	requests int
+	ids      []string
+}
+
+func (s *orderedSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.requests++
+	// later requests are answered faster than earlier ones
+	time.Sleep(time.Duration(3-s.requests%3) * time.Millisecond)
+	if s.failures[s.requests] {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.Header.Get("Content-Type") == ceBatchContentType {
+		var batch []struct {
+			ID string `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, ev := range batch {
+			s.ids = append(s.ids, ev.ID)
+		}
+	} else {
+		s.ids = append(s.ids, r.Header.Get("Ce-Id"))
+	}
+
+	if len(s.ids) >= s.want && s.done != nil {
+		close(s.done)
+		s.done = nil
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func Test_vAdapter_runOrdered(t *testing.T) {
+	// number of vcsim events emitted for default VPX model
+	const vcsimEvents = 26
+
+	want := make([]string, vcsimEvents)
+	for i := range want {
+		want[i] = strconv.Itoa(i + 1)
+	}
+
+	tests := []struct {
+		name      string
+		batchSize int
+	}{
+		{name: "single events", batchSize: 1},
+		{name: "batches", batchSize: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			simulator.Run(func(ctx context.Context, vim *vim25.Client) error {
+				done := make(chan struct{})
+				sink := &orderedSink{
+					failures: map[int]bool{2: true, 3: true, 5: true},
+					want:     vcsimEvents,
+					done:     done,
+				}
+				srv := httptest.NewServer(sink)
+				defer srv.Close()
+
+				p, err := cehttp.New(cehttp.WithTarget(srv.URL), cehttp.WithClient(http.Client{}))
+				if err != nil {
+					t.Fatal(err)
+				}
+				c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				a := &vAdapter{
+					Logger:   zaptest.NewLogger(t).Sugar(),
+					Source:   source,
+					VClient:  &govmomi.Client{Client: vim, SessionManager: session.NewManager(vim)},
+					CEClient: c,
+					KVStore: &fakeKVStore{
+						data: map[string]string{
+							checkpointKey: createCheckpoint(t, time.Now().UTC().Add(-time.Hour)),
+						},
+						dataChan: make(chan string, 100),
+					},
+					CpConfig:      CheckpointConfig{MaxAge: time.Hour, Period: time.Hour},
+					PollInterval:  10 * time.Millisecond,
+					Sink:          srv.URL,
+					HTTPClient:    &http.Client{},
+					BatchSize:     tt.batchSize,
+					Ordered:       true,
+					StatsReporter: &fakeStatsReporter{},
+				}
+
+				ctx, cancel := context.WithCancel(ctx)
+				defer cancel()
+
+				errCh := make(chan error, 1)
+				go func() {
+					errCh <- a.run(ctx)
+				}()
+
+				select {
+				case <-done:
+				case <-time.After(10 * time.Second):
+					t.Error("timed out waiting for all events to be delivered")
+				}
+				cancel()
+				<-errCh
+
+				sink.Lock()
+				defer sink.Unlock()
+				if diff := cmp.Diff(want, sink.ids); diff != "" {
+					t.Errorf("run() delivered event IDs (-want, +got) = %v", diff)
+				}
+				return nil
+			})
+		})
+	}
+}
+
+// Test_vAdapter_runResumeAfterPause stops the adapter after its initial
+// checkpoint, as when the source is paused and the adapter scaled to zero,
+// and verifies that a new adapter replays the events emitted in the meantime
+// from the checkpoint.
+func Test_vAdapter_runResumeAfterPause(t *testing.T) {
+	// number of vcsim events emitted for default VPX model
+	const vcsimEvents = 26
+	// number of events emitted while paused
+	const gapEvents = 3
+
+	simulator.Run(func(ctx context.Context, vim *vim25.Client) error {
+		done := make(chan struct{})
+		sink := &orderedSink{want: gapEvents, done: done}
+		srv := httptest.NewServer(sink)
+		defer srv.Close()
+
+		newAdapter := func(store kvstore.Interface) *vAdapter {
+			p, err := cehttp.New(cehttp.WithTarget(srv.URL), cehttp.WithClient(http.Client{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return &vAdapter{
+				Logger:        zaptest.NewLogger(t).Sugar(),
+				Source:        source,
+				VClient:       &govmomi.Client{Client: vim, SessionManager: session.NewManager(vim)},
+				CEClient:      c,
+				KVStore:       store,
+				CpConfig:      CheckpointConfig{MaxAge: time.Hour, Period: time.Hour},
+				StartFrom:     StartFromNow,
+				PollInterval:  10 * time.Millisecond,
+				Sink:          srv.URL,
+				HTTPClient:    &http.Client{},
+				StatsReporter: &fakeStatsReporter{},
+			}
+		}
+
+		// the new source starts from now and is paused after its initial
+		// checkpoint
+		store := &fakeKVStore{dataChan: make(chan string, 1)}
+		runCtx, cancel := context.WithCancel(ctx)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- newAdapter(store).run(runCtx)
+		}()
+
+		var data string
+		select {
+		case data = <-store.dataChan:
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for the initial checkpoint")
+		}
+		cancel()
+		<-errCh
+
+		// events emitted while the adapter is scaled to zero
+		m := vevent.NewManager(vim)
+		for i := 0; i < gapEvents; i++ {
+			err := m.PostEvent(ctx, &types.GeneralUserEvent{
+				GeneralEvent: types.GeneralEvent{Message: fmt.Sprintf("maintenance %d", i)},
+			})
+			if err != nil {
+				t.Fatalf("post event: %v", err)
+			}
+		}
+
+		// the resumed adapter reads the checkpoint of the paused one
+		resumed := &fakeKVStore{
+			data:     map[string]string{checkpointKey: data},
+			dataChan: make(chan string, 1),
+		}
+		runCtx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			errCh <- newAdapter(resumed).run(runCtx)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Error("timed out waiting for the events emitted while paused")
+		}
+		cancel()
+		<-errCh
+
+		want := make([]string, gapEvents)
+		for i := range want {
+			want[i] = strconv.Itoa(vcsimEvents + i + 1)
+		}
+		sink.Lock()
+		defer sink.Unlock()
+		if diff := cmp.Diff(want, sink.ids); diff != "" {
+			t.Errorf("run() delivered event IDs after resume (-want, +got) = %v", diff)
+		}
+		return nil
+	})
+}
+
 func createCheckpoint(t *testing.T, lastEventTS time.Time) string {
 	t.Helper()
 	cp := checkpoint{
@@ -488,8 +905,9 @@ func createStatusCodes(count, failAt int) []int {
 
 type fakeKVStore struct {
 	sync.Mutex
-	data  map[string]string
-	saved bool
+	data   map[string]string
+	saved  bool
+	loaded int
 
 	// send last checkpoint saved over this channel (should be buffered)
 	// can be used so sync between read/write goroutines in tests
@@ -501,7 +919,10 @@ func (f *fakeKVStore) Init(ctx context.Context) error {
 }
 
 func (f *fakeKVStore) Load(ctx context.Context) error {
-	panic("implement me")
+	f.Lock()
+	defer f.Unlock()
+	f.loaded++
+	return nil
 }
 
 func (f *fakeKVStore) Save(ctx context.Context) error {
@@ -542,3 +963,645 @@ func (f *fakeKVStore) Set(ctx context.Context, key string, value interface{}) er
 	f.data[key] = string(bytes)
 	return nil
 }
+
+func TestSendEventsProcessedTime(t *testing.T) {
+	// replayed events were created in vCenter minutes before they are sent
+	created := time.Now().UTC().Add(-10 * time.Minute).Truncate(time.Second)
+	events := createTestEvents(2, source, created)
+
+	sink := &flakySink{}
+	srv := httptest.NewServer(sink)
+	defer srv.Close()
+
+	p, err := cehttp.New(cehttp.WithTarget(srv.URL), cehttp.WithClient(http.Client{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adapter := vAdapter{
+		Logger:          zaptest.NewLogger(t).Sugar(),
+		CEClient:        c,
+		Source:          source,
+		PayloadEncoding: cloudevents.ApplicationXML,
+		VAPIVersion:     "6.7.0",
+		Sink:            srv.URL,
+		StatsReporter:   &fakeStatsReporter{},
+	}
+
+	sent := time.Now().UTC().Truncate(time.Second)
+	if count, err := adapter.sendEvents(context.Background(), events.vEvents); err != nil || count != 2 {
+		t.Fatalf("sendEvents() = %d, %v, want 2, nil", count, err)
+	}
+
+	if len(sink.headers) != 2 {
+		t.Fatalf("sink received %d requests, want 2", len(sink.headers))
+	}
+	for _, h := range sink.headers {
+		eventTime, err := time.Parse(time.RFC3339Nano, h.Get("Ce-Time"))
+		if err != nil {
+			t.Fatalf("parse time attribute: %v", err)
+		}
+		if !eventTime.Equal(created) {
+			t.Errorf("time = %v, want vCenter created time %v", eventTime, created)
+		}
+
+		processed, err := time.Parse(time.RFC3339Nano, h.Get("Ce-"+ceVSphereProcessedTime))
+		if err != nil {
+			t.Fatalf("parse %s attribute: %v", ceVSphereProcessedTime, err)
+		}
+		if processed.Before(sent) || processed.Sub(eventTime) < 10*time.Minute {
+			t.Errorf("%s = %v, want send time after %v", ceVSphereProcessedTime, processed, sent)
+		}
+	}
+}
+
+func Test_newCloudEventFullPayload(t *testing.T) {
+	be := &types.VmPoweredOffEvent{VmEvent: types.VmEvent{Event: types.Event{
+		Key:                  41,
+		ChainId:              40,
+		UserName:             "user",
+		FullFormattedMessage: "vm-01 on host-01 is powered off",
+		Host:                 &types.HostEventArgument{EntityEventArgument: types.EntityEventArgument{Name: "host-01"}},
+		Vm:                   &types.VmEventArgument{EntityEventArgument: types.EntityEventArgument{Name: "vm-01"}},
+	}}}
+
+	a := vAdapter{
+		Source:          source,
+		PayloadEncoding: cloudevents.ApplicationJSON,
+		VAPIVersion:     "6.7.0",
+	}
+
+	ev, err := a.newCloudEvent(be)
+	if err != nil {
+		t.Fatalf("newCloudEvent() error = %v", err)
+	}
+
+	// the data is the complete vSphere event
+	var got types.VmPoweredOffEvent
+	if err = json.Unmarshal(ev.Data(), &got); err != nil {
+		t.Fatalf("unmarshal data: %v", err)
+	}
+	if diff := cmp.Diff(be, &got); diff != "" {
+		t.Errorf("newCloudEvent() data (-want, +got) = %v", diff)
+	}
+}
+
+func Test_newCloudEventMinimalPayload(t *testing.T) {
+	created := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	be := &types.VmPoweredOffEvent{VmEvent: types.VmEvent{Event: types.Event{
+		Key:                  41,
+		ChainId:              40,
+		CreatedTime:          created,
+		UserName:             "user",
+		FullFormattedMessage: "vm-01 on host-01 is powered off",
+		Host:                 &types.HostEventArgument{EntityEventArgument: types.EntityEventArgument{Name: "host-01"}},
+		Vm:                   &types.VmEventArgument{EntityEventArgument: types.EntityEventArgument{Name: "vm-01"}},
+	}}}
+
+	testCases := map[string]struct {
+		encoding string
+		want     string
+	}{
+		"json": {
+			encoding: cloudevents.ApplicationJSON,
+			want: `{"Key":41,"ChainId":40,"CreatedTime":"2022-08-01T12:00:00Z","UserName":"user",` +
+				`"FullFormattedMessage":"vm-01 on host-01 is powered off","Host":"host-01","Vm":"vm-01"}`,
+		},
+		"xml": {
+			encoding: cloudevents.ApplicationXML,
+			want: `<VmPoweredOffEvent><key>41</key><chainId>40</chainId>` +
+				`<createdTime>2022-08-01T12:00:00Z</createdTime><userName>user</userName>` +
+				`<fullFormattedMessage>vm-01 on host-01 is powered off</fullFormattedMessage>` +
+				`<host>host-01</host><vm>vm-01</vm></VmPoweredOffEvent>`,
+		},
+	}
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			a := vAdapter{
+				Source:          source,
+				PayloadEncoding: tc.encoding,
+				PayloadFormat:   PayloadFormatMinimal,
+				VAPIVersion:     "6.7.0",
+			}
+
+			ev, err := a.newCloudEvent(be)
+			if err != nil {
+				t.Fatalf("newCloudEvent() error = %v", err)
+			}
+			if got := string(ev.Data()); got != tc.want {
+				t.Errorf("newCloudEvent() data = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_eventID(t *testing.T) {
+	if got, want := eventID("", 42), "42"; got != want {
+		t.Errorf("eventID() = %q, want %q", got, want)
+	}
+
+	uuid := "e8a3b2f0-2d1c-4b8e-9a47-5d6c1f0b7e21"
+	if got, want := eventID(uuid, 42), uuid+"/42"; got != want {
+		t.Errorf("eventID() = %q, want %q", got, want)
+	}
+}
+
+func Test_newCloudEventID(t *testing.T) {
+	a := vAdapter{
+		Logger:          zaptest.NewLogger(t).Sugar(),
+		Source:          source,
+		PayloadEncoding: cloudevents.ApplicationJSON,
+		VAPIVersion:     "6.7.0",
+		VCenterUUID:     "e8a3b2f0-2d1c-4b8e-9a47-5d6c1f0b7e21",
+	}
+
+	newID := func(key int32) string {
+		t.Helper()
+		ev, err := a.newCloudEvent(&types.VmPoweredOnEvent{VmEvent: types.VmEvent{Event: types.Event{Key: key, ChainId: key}}})
+		if err != nil {
+			t.Fatalf("newCloudEvent() error = %v", err)
+		}
+		return ev.ID()
+	}
+
+	// replays of an event carry the same ID
+	if first, replay := newID(42), newID(42); first != replay || first != eventID(a.VCenterUUID, 42) {
+		t.Errorf("newCloudEvent() IDs = %q, %q, want %q", first, replay, eventID(a.VCenterUUID, 42))
+	}
+
+	// events without key fall back to a random ID
+	if first, second := newID(0), newID(0); first == "" || first == second {
+		t.Errorf("newCloudEvent() IDs without key = %q, %q, want distinct random IDs", first, second)
+	}
+}
+
+func TestSendEventsSkipsDelivered(t *testing.T) {
+	now := time.Now().UTC()
+	events := createTestEvents(4, source, now)
+
+	for _, batchSize := range []int{0, 4} {
+		t.Run(fmt.Sprintf("batch size %d", batchSize), func(t *testing.T) {
+			sink := &flakySink{}
+			srv := httptest.NewServer(sink)
+			defer srv.Close()
+
+			p, err := cehttp.New(cehttp.WithTarget(srv.URL), cehttp.WithClient(http.Client{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reporter := &fakeStatsReporter{}
+			adapter := vAdapter{
+				Logger:          zaptest.NewLogger(t).Sugar(),
+				CEClient:        c,
+				Source:          source,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				VAPIVersion:     "6.7.0",
+				Sink:            srv.URL,
+				BatchSize:       batchSize,
+				HTTPClient:      &http.Client{},
+				StatsReporter:   reporter,
+				// the first two events were delivered before the restart
+				deliveredKey: events.vEvents[1].GetEvent().Key,
+			}
+
+			count, err := adapter.sendEvents(context.Background(), events.vEvents)
+			if err != nil {
+				t.Fatalf("sendEvents() error = %v", err)
+			}
+			if count != 4 {
+				t.Errorf("sendEvents() count = %d, want %d", count, 4)
+			}
+			if diff := cmp.Diff(map[string]int{events.ceEvents[0].Type(): 2}, reporter.delivered); diff != "" {
+				t.Errorf("sendEvents() unexpected delivered events (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestSendEventsCategories(t *testing.T) {
+	now := time.Now().UTC()
+	events := []types.BaseEvent{
+		&types.VmPoweredOnEvent{VmEvent: types.VmEvent{Event: types.Event{Key: 1, CreatedTime: now}}},
+		&types.TaskEvent{Event: types.Event{Key: 2, CreatedTime: now}, Info: types.TaskInfo{DescriptionId: "VirtualMachine.powerOn"}},
+		&types.AlarmStatusChangedEvent{AlarmEvent: types.AlarmEvent{Event: types.Event{Key: 3, CreatedTime: now}}},
+	}
+
+	for _, batchSize := range []int{0, 3} {
+		t.Run(fmt.Sprintf("batch size %d", batchSize), func(t *testing.T) {
+			sink := &flakySink{}
+			srv := httptest.NewServer(sink)
+			defer srv.Close()
+
+			p, err := cehttp.New(cehttp.WithTarget(srv.URL), cehttp.WithClient(http.Client{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reporter := &fakeStatsReporter{}
+			adapter := vAdapter{
+				Logger:          zaptest.NewLogger(t).Sugar(),
+				CEClient:        c,
+				Source:          source,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				VAPIVersion:     "6.7.0",
+				Sink:            srv.URL,
+				BatchSize:       batchSize,
+				HTTPClient:      &http.Client{},
+				StatsReporter:   reporter,
+				Categories:      categories{CategoryTask: true, CategoryAlarm: true},
+			}
+
+			count, err := adapter.sendEvents(context.Background(), events)
+			if err != nil {
+				t.Fatalf("sendEvents() error = %v", err)
+			}
+			if count != 3 {
+				t.Errorf("sendEvents() count = %d, want %d", count, 3)
+			}
+			want := map[string]int{
+				"com.vmware.vsphere.task.VirtualMachine.powerOn.v0":   1,
+				"com.vmware.vsphere.alarm.AlarmStatusChangedEvent.v0": 1,
+			}
+			if diff := cmp.Diff(want, reporter.delivered); diff != "" {
+				t.Errorf("sendEvents() unexpected delivered events (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestSendEventsMaxEventAge(t *testing.T) {
+	now := time.Now().UTC()
+	events := []types.BaseEvent{
+		&types.VmPoweredOnEvent{VmEvent: types.VmEvent{Event: types.Event{Key: 1, CreatedTime: now.Add(-3 * time.Hour)}}},
+		&types.VmPoweredOffEvent{VmEvent: types.VmEvent{Event: types.Event{Key: 2, CreatedTime: now.Add(-2 * time.Hour)}}},
+		&types.VmPoweredOnEvent{VmEvent: types.VmEvent{Event: types.Event{Key: 3, CreatedTime: now.Add(-time.Minute)}}},
+	}
+
+	for _, batchSize := range []int{0, 3} {
+		t.Run(fmt.Sprintf("batch size %d", batchSize), func(t *testing.T) {
+			sink := &flakySink{}
+			srv := httptest.NewServer(sink)
+			defer srv.Close()
+
+			p, err := cehttp.New(cehttp.WithTarget(srv.URL), cehttp.WithClient(http.Client{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reporter := &fakeStatsReporter{}
+			adapter := vAdapter{
+				Logger:          zaptest.NewLogger(t).Sugar(),
+				CEClient:        c,
+				Source:          source,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				VAPIVersion:     "6.7.0",
+				Sink:            srv.URL,
+				BatchSize:       batchSize,
+				HTTPClient:      &http.Client{},
+				StatsReporter:   reporter,
+				MaxEventAge:     time.Hour,
+			}
+
+			// stale events are processed, so that the checkpoint moves past them
+			count, err := adapter.sendEvents(context.Background(), events)
+			if err != nil {
+				t.Fatalf("sendEvents() error = %v", err)
+			}
+			if count != 3 {
+				t.Errorf("sendEvents() count = %d, want %d", count, 3)
+			}
+			want := map[string]int{"com.vmware.vsphere.VmPoweredOnEvent.v0": 1}
+			if diff := cmp.Diff(want, reporter.delivered); diff != "" {
+				t.Errorf("sendEvents() unexpected delivered events (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func Test_resultStatusCode(t *testing.T) {
+	testCases := map[string]struct {
+		result error
+		want   int
+	}{
+		"no result": {
+			result: nil,
+			want:   0,
+		},
+		"connection error": {
+			result: errors.New("connection refused"),
+			want:   0,
+		},
+		"http result": {
+			result: cehttp.NewResult(503, "unavailable"),
+			want:   503,
+		},
+		"retries result": {
+			result: cehttp.NewRetriesResult(cehttp.NewResult(500, "error"), 3, time.Now(), nil),
+			want:   500,
+		},
+		"wrapped http result": {
+			result: fmt.Errorf("send cloudevent batch: %w", cehttp.NewResult(429, "unexpected status code")),
+			want:   429,
+		},
+	}
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			if got := resultStatusCode(tc.result); got != tc.want {
+				t.Errorf("resultStatusCode() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSendEventsMultipleSinks(t *testing.T) {
+	now := time.Now().UTC()
+	events := createTestEvents(2, source, now)
+	eventType := events.ceEvents[0].Type()
+
+	testCases := map[string]struct {
+		batchSize         int
+		failures          int
+		statusCode        int
+		deadLetterSink    bool
+		wantRequests      int
+		wantOtherRequests int
+		wantDLSRequests   int
+		wantCount         int
+		wantErr           bool
+		wantRetried       map[string]int
+		wantDelivered     map[string]int
+		wantFailed        map[string]int
+	}{
+		"single events, all sinks accept": {
+			wantRequests:      2,
+			wantOtherRequests: 2,
+			wantCount:         2,
+			wantDelivered:     map[string]int{eventType: 2},
+		},
+		"single events, other sink retried": {
+			failures:          1,
+			statusCode:        http.StatusServiceUnavailable,
+			wantRequests:      2,
+			wantOtherRequests: 3,
+			wantCount:         2,
+			wantRetried:       map[string]int{eventType: 1},
+			wantDelivered:     map[string]int{eventType: 2},
+		},
+		"single events, other sink fails": {
+			failures:          2,
+			statusCode:        http.StatusBadRequest,
+			wantRequests:      1,
+			wantOtherRequests: 1,
+			wantCount:         0,
+			wantErr:           true,
+			wantFailed:        map[string]int{eventType: 1},
+		},
+		"single events, other sink fails, dead letter sink succeeds": {
+			failures:          2,
+			statusCode:        http.StatusBadRequest,
+			deadLetterSink:    true,
+			wantRequests:      2,
+			wantOtherRequests: 2,
+			wantDLSRequests:   2,
+			wantCount:         2,
+			wantFailed:        map[string]int{eventType: 2},
+		},
+		"batch, other sink retried": {
+			batchSize:         2,
+			failures:          1,
+			statusCode:        http.StatusInternalServerError,
+			wantRequests:      1,
+			wantOtherRequests: 2,
+			wantCount:         2,
+			wantRetried:       map[string]int{eventType: 2},
+			wantDelivered:     map[string]int{eventType: 2},
+		},
+		"batch, other sink fails, dead letter sink succeeds": {
+			batchSize:         2,
+			failures:          2,
+			statusCode:        http.StatusInternalServerError,
+			deadLetterSink:    true,
+			wantRequests:      1,
+			wantOtherRequests: 2,
+			wantDLSRequests:   2,
+			wantCount:         2,
+			wantRetried:       map[string]int{eventType: 2},
+			wantFailed:        map[string]int{eventType: 2},
+		},
+	}
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			sink := &flakySink{}
+			sinkSrv := httptest.NewServer(sink)
+			defer sinkSrv.Close()
+
+			other := &flakySink{failures: tc.failures, statusCode: tc.statusCode}
+			otherSrv := httptest.NewServer(other)
+			defer otherSrv.Close()
+
+			dls := &flakySink{}
+			dlsSrv := httptest.NewServer(dls)
+			defer dlsSrv.Close()
+
+			p, err := cehttp.New(cehttp.WithTarget(sinkSrv.URL), cehttp.WithClient(http.Client{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reporter := &fakeStatsReporter{}
+			adapter := vAdapter{
+				Logger:          zaptest.NewLogger(t).Sugar(),
+				CEClient:        c,
+				Source:          source,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				VAPIVersion:     "6.7.0",
+				Sink:            sinkSrv.URL,
+				Sinks:           []string{otherSrv.URL},
+				BatchSize:       tc.batchSize,
+				HTTPClient:      &http.Client{},
+				StatsReporter:   reporter,
+				RetryParams: &cecontext.RetryParams{
+					Strategy: cecontext.BackoffStrategyLinear,
+					MaxTries: 1,
+					Period:   time.Millisecond,
+				},
+			}
+			if tc.deadLetterSink {
+				adapter.DeadLetterSink = dlsSrv.URL
+			}
+
+			count, err := adapter.sendEvents(context.Background(), events.vEvents)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("sendEvents() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if count != tc.wantCount {
+				t.Errorf("sendEvents() count = %d, want %d", count, tc.wantCount)
+			}
+			if sink.requests != tc.wantRequests {
+				t.Errorf("sink received %d requests, want %d", sink.requests, tc.wantRequests)
+			}
+			if other.requests != tc.wantOtherRequests {
+				t.Errorf("other sink received %d requests, want %d", other.requests, tc.wantOtherRequests)
+			}
+			if dls.requests != tc.wantDLSRequests {
+				t.Errorf("dead letter sink received %d requests, want %d", dls.requests, tc.wantDLSRequests)
+			}
+			for _, h := range dls.headers {
+				if got := h.Get("Ce-" + ceErrorDestKey); got != otherSrv.URL {
+					t.Errorf("dead letter event %s = %q, want %q", ceErrorDestKey, got, otherSrv.URL)
+				}
+			}
+			if diff := cmp.Diff(tc.wantRetried, reporter.retried); diff != "" {
+				t.Errorf("sendEvents() unexpected retried events (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tc.wantDelivered, reporter.delivered); diff != "" {
+				t.Errorf("sendEvents() unexpected delivered events (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tc.wantFailed, reporter.failed); diff != "" {
+				t.Errorf("sendEvents() unexpected failed events (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+// newTestAdapterFromEnv returns the adapter NewAdapter creates from the
+// environment of the adapter container for the simulator of the given client,
+// with the given variables set in addition. The ctx must provide a fake kube
+// client.
+func newTestAdapterFromEnv(ctx context.Context, t *testing.T, vim *vim25.Client, ceClient cloudevents.Client, env map[string]string) *vAdapter {
+	t.Helper()
+
+	secret := t.TempDir()
+	password, _ := simulator.DefaultLogin.Password()
+	for key, value := range map[string]string{
+		corev1.BasicAuthUsernameKey: simulator.DefaultLogin.Username(),
+		corev1.BasicAuthPasswordKey: password,
+	} {
+		if err := os.WriteFile(filepath.Join(secret, key), []byte(value), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u := vim.URL()
+	vars := map[string]string{
+		"VC_URL":                    (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String(),
+		"VC_INSECURE":               "true",
+		"VC_SECRET_PATH":            secret,
+		"NAMESPACE":                 "ns",
+		"K_SINK":                    testSink,
+		"VSPHERE_KVSTORE_CONFIGMAP": "source-configmap",
+	}
+	for name, value := range env {
+		vars[name] = value
+	}
+	for name, value := range vars {
+		t.Setenv(name, value)
+	}
+
+	processed := NewEnvConfig()
+	if err := envconfig.Process("", processed); err != nil {
+		t.Fatalf("envconfig.Process() = %v", err)
+	}
+	return NewAdapter(ctx, processed, ceClient).(*vAdapter)
+}
+
+// Test_vAdapter_readOnly runs the adapter without any writable path, like in
+// the adapter container with its read-only root filesystem.
+func Test_vAdapter_readOnly(t *testing.T) {
+	// number of vcsim events emitted for default VPX model, including the
+	// login of the adapter
+	const vcsimEvents = 27
+
+	simulator.Run(func(ctx context.Context, vim *vim25.Client) error {
+		ctx = cecontext.WithTarget(ctx, "fake.example.com")
+		ctx = logging.WithLogger(ctx, zaptest.NewLogger(t).Sugar())
+		ctx, kc := fakekubeclient.With(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "source-configmap", Namespace: "ns"},
+			// replay the events of the inventory
+			Data: map[string]string{checkpointKey: createCheckpoint(t, time.Now().UTC().Add(-time.Hour))},
+		})
+
+		roundTripper := &roundTripperTest{statusCodes: createStatusCodes(vcsimEvents, failNever)}
+		p, err := cehttp.New(cehttp.WithRoundTripper(roundTripper))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a := newTestAdapterFromEnv(ctx, t, vim, c, map[string]string{
+			"VSPHERE_CE_SOURCE":         source,
+			"VSPHERE_CHECKPOINT_CONFIG": `{"maxAge":"1h","period":"10ms"}`,
+			"VSPHERE_POLL_INTERVAL":     "10ms",
+		})
+
+		// nothing can be created below a regular file, not even by root
+		file := filepath.Join(t.TempDir(), "file")
+		if err := os.WriteFile(file, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("TMPDIR", filepath.Join(file, "tmp"))
+		t.Setenv("HOME", filepath.Join(file, "home"))
+		wd, err := os.Getwd()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chdir(filepath.Dir(file)); err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := os.Chdir(wd); err != nil {
+				t.Error(err)
+			}
+		}()
+		// the working directory is gone
+		if err := os.RemoveAll(filepath.Dir(file)); err != nil {
+			t.Fatal(err)
+		}
+
+		runCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		if err := a.Start(runCtx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Start() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+
+		if roundTripper.requestCount != vcsimEvents {
+			t.Errorf("Start() sent %d events, want %d", roundTripper.requestCount, vcsimEvents)
+		}
+		cm, err := kc.CoreV1().ConfigMaps("ns").Get(ctx, "source-configmap", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cp checkpoint
+		if err := json.Unmarshal([]byte(cm.Data[checkpointKey]), &cp); err != nil {
+			t.Fatal(err)
+		}
+		if cp.LastEventKey != vcsimEvents {
+			t.Errorf("Start() checkpoint key = %d, want %d", cp.LastEventKey, vcsimEvents)
+		}
+		return nil
+	})
+}
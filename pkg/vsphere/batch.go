@@ -0,0 +1,125 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/vmware/govmomi/vim25/types"
+	"go.opencensus.io/trace"
+)
+
+// ceBatchContentType is the content type of a batch of CloudEvents in the
+// JSON event format
+const ceBatchContentType = "application/cloudevents-batch+json"
+
+// sendEventBatches converts all events to cloud events and sends them in
+// batches of at most BatchSize events to the configured sinks. It returns the
+// number of successfully processed events and returns on the first error.
+// When a batch is not accepted by a sink, its events are sent one by one to
+// the dead letter sink, if configured.
+func (a *vAdapter) sendEventBatches(ctx context.Context, baseEvents []types.BaseEvent) (int, error) {
+	var (
+		success int
+		batch   []cloudevents.Event
+		spans   []*trace.Span
+	)
+
+	for i, be := range baseEvents {
+		if !a.skipEvent(be) {
+			send, err := a.throttle(ctx, be)
+			if err != nil {
+				return success, err
+			}
+
+			// events dropped by the rate limit are not added to the batch
+			if send {
+				ev, err := a.newCloudEvent(be)
+				if err != nil {
+					return success, err
+				}
+				_, span := startEventSpan(ctx, be, &ev)
+				batch = append(batch, ev)
+				spans = append(spans, span)
+			}
+		}
+
+		if len(batch) < a.BatchSize && i < len(baseEvents)-1 {
+			continue
+		}
+
+		if len(batch) > 0 {
+			results := a.sendBatch(ctx, batch)
+			failure := a.firstFailure(results)
+			for _, span := range spans {
+				endEventSpan(span, failure)
+			}
+
+			if err := a.handleFailures(ctx, batch, results); err != nil {
+				return success, err
+			}
+		}
+
+		success = i + 1
+		batch = nil
+		spans = nil
+	}
+
+	return success, nil
+}
+
+// sendBatch sends the given events as a single batch in the CloudEvents JSON
+// batch format to the sink and all additional sinks. Failed deliveries are
+// retried independently according to the configured retry parameters. The
+// delivery results are returned by sink.
+func (a *vAdapter) sendBatch(ctx context.Context, events []cloudevents.Event) map[string]error {
+	results := make(map[string]error, len(a.Sinks)+1)
+	for _, sink := range a.allSinks() {
+		sink := sink
+		results[sink] = a.sendWithRetries(ctx, events, func(ctx context.Context, events []cloudevents.Event) error {
+			return a.postBatch(ctx, sink, events)
+		})
+	}
+	return results
+}
+
+// postBatch posts the given events as a single batch to the given sink.
+func (a *vAdapter) postBatch(ctx context.Context, sink string, events []cloudevents.Event) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("marshal cloudevent batch: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sink, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create cloudevent batch request: %w", err)
+	}
+	req.Header.Set("Content-Type", ceBatchContentType)
+
+	client := a.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send cloudevent batch: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("send cloudevent batch: %w", cehttp.NewResult(resp.StatusCode, "unexpected status code"))
+	}
+	return nil
+}
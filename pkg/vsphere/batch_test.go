@@ -0,0 +1,181 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/client"
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/zap/zaptest"
+)
+
+// batchSink records the CloudEvent batches it receives
+type batchSink struct {
+	sync.Mutex
+	statusCodes []int
+	batches     [][]cloudevents.Event
+}
+
+func (s *batchSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if ct := r.Header.Get("Content-Type"); ct != ceBatchContentType {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var batch []cloudevents.Event
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	code := s.statusCodes[len(s.batches)]
+	s.batches = append(s.batches, batch)
+	w.WriteHeader(code)
+}
+
+func TestSendEventBatches(t *testing.T) {
+	now := time.Now().UTC()
+	events := createTestEvents(5, source, now)
+
+	testCases := map[string]struct {
+		batchSize      int
+		statusCodes    []int
+		dlsStatusCodes []int
+		deadLetterSink string
+		retryParams    *cecontext.RetryParams
+		wantBatchSizes []int
+		wantCount      int
+		wantErr        bool
+	}{
+		"five events, batch size 2": {
+			batchSize:      2,
+			statusCodes:    []int{200, 200, 200},
+			wantBatchSizes: []int{2, 2, 1},
+			wantCount:      5,
+		},
+		"five events, batch size larger than events": {
+			batchSize:      10,
+			statusCodes:    []int{200},
+			wantBatchSizes: []int{5},
+			wantCount:      5,
+		},
+		"five events, second batch fails": {
+			batchSize:      2,
+			statusCodes:    []int{200, 500},
+			wantBatchSizes: []int{2, 2},
+			wantCount:      2,
+			wantErr:        true,
+		},
+		"five events, batch retried after failure": {
+			batchSize:      5,
+			statusCodes:    []int{500, 200},
+			retryParams:    &cecontext.RetryParams{Strategy: cecontext.BackoffStrategyLinear, MaxTries: 1, Period: time.Millisecond},
+			wantBatchSizes: []int{5, 5},
+			wantCount:      5,
+		},
+		"five events, batch retries exhausted": {
+			batchSize:      5,
+			statusCodes:    []int{500, 500},
+			retryParams:    &cecontext.RetryParams{Strategy: cecontext.BackoffStrategyLinear, MaxTries: 1, Period: time.Millisecond},
+			wantBatchSizes: []int{5, 5},
+			wantCount:      0,
+			wantErr:        true,
+		},
+		"five events, batch fails, dead letter sink succeeds": {
+			batchSize:      5,
+			statusCodes:    []int{500},
+			dlsStatusCodes: createStatusCodes(5, failNever),
+			deadLetterSink: "http://dls.example.com",
+			wantBatchSizes: []int{5},
+			wantCount:      5,
+		},
+		"five events, batch fails, dead letter sink fails": {
+			batchSize:      5,
+			statusCodes:    []int{500},
+			dlsStatusCodes: createStatusCodes(5, 0),
+			deadLetterSink: "http://dls.example.com",
+			wantBatchSizes: []int{5},
+			wantCount:      0,
+			wantErr:        true,
+		},
+	}
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			sink := &batchSink{statusCodes: tc.statusCodes}
+			srv := httptest.NewServer(sink)
+			defer srv.Close()
+
+			// dead letter sink deliveries use the regular cloudevents client
+			p, err := cehttp.New(cehttp.WithRoundTripper(&roundTripperTest{statusCodes: tc.dlsStatusCodes}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			adapter := vAdapter{
+				Logger:          zaptest.NewLogger(t).Sugar(),
+				CEClient:        c,
+				Source:          source,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				VAPIVersion:     "6.7.0",
+				DeadLetterSink:  tc.deadLetterSink,
+				Sink:            srv.URL,
+				BatchSize:       tc.batchSize,
+				HTTPClient:      srv.Client(),
+				RetryParams:     tc.retryParams,
+				StatsReporter:   &fakeStatsReporter{},
+			}
+
+			count, err := adapter.sendEvents(context.Background(), events.vEvents)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("sendEvents() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if count != tc.wantCount {
+				t.Errorf("sendEvents() count = %d, want %d", count, tc.wantCount)
+			}
+
+			// retried batches are delivered again, only count each event once
+			var gotBatchSizes []int
+			var gotIDs, wantIDs []string
+			seen := make(map[string]bool)
+			for _, b := range sink.batches {
+				gotBatchSizes = append(gotBatchSizes, len(b))
+				for _, ev := range b {
+					if !seen[ev.ID()] {
+						seen[ev.ID()] = true
+						gotIDs = append(gotIDs, ev.ID())
+					}
+				}
+			}
+			if diff := cmp.Diff(tc.wantBatchSizes, gotBatchSizes); diff != "" {
+				t.Errorf("sendEvents() unexpected batch sizes (-want, +got) = %v", diff)
+			}
+
+			for _, ev := range events.ceEvents[:len(gotIDs)] {
+				wantIDs = append(wantIDs, ev.ID())
+			}
+			if diff := cmp.Diff(wantIDs, gotIDs); diff != "" {
+				t.Errorf("sendEvents() unexpected event IDs (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// Categories of the vCenter events emitted by the adapter.
+const (
+	// CategoryEvent are all vCenter events not emitted as a task or alarm
+	CategoryEvent = "event"
+	// CategoryTask are the TaskEvents vCenter records when a task is started
+	CategoryTask = "task"
+	// CategoryAlarm are the AlarmEvents vCenter records when an alarm is
+	// created, changes its status or runs an action
+	CategoryAlarm = "alarm"
+)
+
+// Categories are the supported event categories.
+var Categories = []string{CategoryEvent, CategoryTask, CategoryAlarm}
+
+const (
+	taskEventTypeFormat  = EventTypePrefix + ".task.%s.v0"
+	alarmEventTypeFormat = EventTypePrefix + ".alarm.%s.v0"
+)
+
+// alarmEventTypes are the vSphere event types of the alarm category.
+var alarmEventTypes = []string{
+	"AlarmAcknowledgedEvent",
+	"AlarmActionTriggeredEvent",
+	"AlarmClearedEvent",
+	"AlarmCreatedEvent",
+	"AlarmEmailCompletedEvent",
+	"AlarmEmailFailedEvent",
+	"AlarmReconfiguredEvent",
+	"AlarmRemovedEvent",
+	"AlarmScriptCompleteEvent",
+	"AlarmScriptFailedEvent",
+	"AlarmSnmpCompletedEvent",
+	"AlarmSnmpFailedEvent",
+	"AlarmStatusChangedEvent",
+}
+
+// TaskEventType returns the CloudEvent type of a vCenter task with the given
+// description ID, e.g. VirtualMachine.powerOn.
+func TaskEventType(descriptionID string) string {
+	return fmt.Sprintf(taskEventTypeFormat, descriptionID)
+}
+
+// AlarmEventType returns the CloudEvent type of the given vSphere alarm event
+// type, e.g. AlarmStatusChangedEvent.
+func AlarmEventType(vEventType string) string {
+	return fmt.Sprintf(alarmEventTypeFormat, vEventType)
+}
+
+// categories is the set of enabled event categories. Only the event category
+// is enabled when empty.
+type categories map[string]bool
+
+// newCategories returns the set of the given event categories.
+func newCategories(names []string) (categories, error) {
+	c := make(categories, len(names))
+	for _, name := range names {
+		switch name = strings.TrimSpace(name); name {
+		case CategoryEvent, CategoryTask, CategoryAlarm:
+			c[name] = true
+		case "":
+		default:
+			return nil, fmt.Errorf("unsupported event category %q", name)
+		}
+	}
+	return c, nil
+}
+
+// enabled returns true if the given category is enabled.
+func (c categories) enabled(category string) bool {
+	if len(c) == 0 {
+		return category == CategoryEvent
+	}
+	return c[category]
+}
+
+// list returns the enabled categories in the order of Categories.
+func (c categories) list() []string {
+	var list []string
+	for _, category := range Categories {
+		if c.enabled(category) {
+			list = append(list, category)
+		}
+	}
+	return list
+}
+
+// category returns the category the given event is emitted as. Task and alarm
+// events belong to the event category unless their own category is enabled,
+// which keeps their event type when only the event category is enabled.
+func (c categories) category(be types.BaseEvent) string {
+	switch be.(type) {
+	case *types.TaskEvent:
+		if c.enabled(CategoryTask) {
+			return CategoryTask
+		}
+	case types.BaseAlarmEvent:
+		if c.enabled(CategoryAlarm) {
+			return CategoryAlarm
+		}
+	}
+	return CategoryEvent
+}
+
+// match returns true if the category of the given event is enabled.
+func (c categories) match(be types.BaseEvent) bool {
+	return c.enabled(c.category(be))
+}
+
+// eventType returns the CloudEvent type of the given event, which depends on
+// its category.
+func (c categories) eventType(be types.BaseEvent) string {
+	switch c.category(be) {
+	case CategoryTask:
+		te := be.(*types.TaskEvent)
+		return TaskEventType(normalizeEventTypeID(te.Info.DescriptionId, "TaskEvent"))
+	case CategoryAlarm:
+		return AlarmEventType(getEventDetails(be).Type)
+	default:
+		return EventType(getEventDetails(be).Type)
+	}
+}
+
+// collectedEventTypes returns the event types to collect from vCenter. Unless
+// event types are configured, only the task and alarm events are collected
+// when the event category is disabled, so vCenter does not return events
+// which are dropped anyway.
+func (c categories) collectedEventTypes(eventTypes []string) []string {
+	if len(eventTypes) > 0 || c.enabled(CategoryEvent) {
+		return eventTypes
+	}
+
+	var collected []string
+	if c.enabled(CategoryTask) {
+		collected = append(collected, "TaskEvent")
+	}
+	if c.enabled(CategoryAlarm) {
+		collected = append(collected, alarmEventTypes...)
+	}
+	return collected
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func Test_newCategories(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty defaults to event", names: nil, want: []string{CategoryEvent}},
+		{name: "empty entry defaults to event", names: []string{""}, want: []string{CategoryEvent}},
+		{name: "task only", names: []string{"task"}, want: []string{CategoryTask}},
+		{name: "all", names: []string{"alarm", " task", "event"}, want: []string{CategoryEvent, CategoryTask, CategoryAlarm}},
+		{name: "unsupported", names: []string{"event", "metric"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newCategories(tt.names)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newCategories() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got.list(), tt.want) {
+				t.Errorf("newCategories() = %v, want %v", got.list(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_categoriesEventType(t *testing.T) {
+	task := &types.TaskEvent{Info: types.TaskInfo{DescriptionId: "VirtualMachine.powerOn"}}
+	alarm := &types.AlarmStatusChangedEvent{From: "green", To: "red"}
+	vm := &types.VmPoweredOnEvent{}
+
+	tests := []struct {
+		name       string
+		categories []string
+		event      types.BaseEvent
+		want       string
+		wantMatch  bool
+	}{
+		{
+			name:      "task event as event by default",
+			event:     task,
+			want:      "com.vmware.vsphere.TaskEvent.v0",
+			wantMatch: true,
+		},
+		{
+			name:      "alarm event as event by default",
+			event:     alarm,
+			want:      "com.vmware.vsphere.AlarmStatusChangedEvent.v0",
+			wantMatch: true,
+		},
+		{
+			name:       "task",
+			categories: []string{CategoryEvent, CategoryTask},
+			event:      task,
+			want:       "com.vmware.vsphere.task.VirtualMachine.powerOn.v0",
+			wantMatch:  true,
+		},
+		{
+			name:       "task without description",
+			categories: []string{CategoryTask},
+			event:      &types.TaskEvent{},
+			want:       "com.vmware.vsphere.task.TaskEvent.v0",
+			wantMatch:  true,
+		},
+		{
+			name:       "alarm",
+			categories: []string{CategoryAlarm},
+			event:      alarm,
+			want:       "com.vmware.vsphere.alarm.AlarmStatusChangedEvent.v0",
+			wantMatch:  true,
+		},
+		{
+			name:       "alarm event without alarm category",
+			categories: []string{CategoryTask},
+			event:      alarm,
+			want:       "com.vmware.vsphere.AlarmStatusChangedEvent.v0",
+			wantMatch:  false,
+		},
+		{
+			name:       "event without event category",
+			categories: []string{CategoryTask, CategoryAlarm},
+			event:      vm,
+			want:       "com.vmware.vsphere.VmPoweredOnEvent.v0",
+			wantMatch:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newCategories(tt.categories)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.eventType(tt.event); got != tt.want {
+				t.Errorf("eventType() = %q, want %q", got, tt.want)
+			}
+			if got := c.match(tt.event); got != tt.wantMatch {
+				t.Errorf("match() = %v, want %v", got, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func Test_categoriesCollectedEventTypes(t *testing.T) {
+	tests := []struct {
+		name       string
+		categories []string
+		eventTypes []string
+		want       []string
+	}{
+		{name: "all events by default", want: nil},
+		{name: "configured event types", categories: []string{CategoryTask}, eventTypes: []string{"VmPoweredOnEvent"}, want: []string{"VmPoweredOnEvent"}},
+		{name: "all events with event category", categories: []string{CategoryEvent, CategoryTask}, want: nil},
+		{name: "task events only", categories: []string{CategoryTask}, want: []string{"TaskEvent"}},
+		{name: "tasks and alarm events", categories: []string{CategoryTask, CategoryAlarm}, want: append([]string{"TaskEvent"}, alarmEventTypes...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newCategories(tt.categories)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.collectedEventTypes(tt.eventTypes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("collectedEventTypes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_alarmEventTypes(t *testing.T) {
+	for _, name := range alarmEventTypes {
+		typ, ok := types.TypeFunc()(name)
+		if !ok {
+			t.Errorf("unknown alarm event type %q", name)
+			continue
+		}
+		if _, ok := reflect.New(typ).Interface().(types.BaseAlarmEvent); !ok {
+			t.Errorf("%q is not an alarm event", name)
+		}
+	}
+}
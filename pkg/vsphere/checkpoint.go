@@ -6,9 +6,15 @@ SPDX-License-Identifier: Apache-2.0
 package vsphere
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"time"
+
+	"go.uber.org/zap"
+	"k8s.io/client-go/util/retry"
+	"knative.dev/pkg/kvstore"
+	"knative.dev/pkg/logging"
 )
 
 const (
@@ -20,8 +26,43 @@ const (
 	checkpointKey = "checkpoint"
 )
 
+const (
+	// CheckpointConfigMapKey is the data key of the checkpoint in the
+	// ConfigMap of the configmap checkpoint backend
+	CheckpointConfigMapKey = checkpointKey
+	// CheckpointLeaseAnnotation is the annotation of the checkpoint on the
+	// Lease of the lease checkpoint backend
+	CheckpointLeaseAnnotation = leaseAnnotationPrefix + checkpointKey
+)
+
+const (
+	// CheckpointModeAtLeastOnce replays all events since the last checkpoint
+	CheckpointModeAtLeastOnce = "at-least-once"
+	// CheckpointModeExactlyOnceBestEffort skips replayed events which were
+	// already delivered according to the last checkpoint
+	CheckpointModeExactlyOnceBestEffort = "exactly-once-best-effort"
+)
+
+const (
+	// CheckpointBackendConfigMap stores the checkpoint in a ConfigMap
+	CheckpointBackendConfigMap = "configmap"
+	// CheckpointBackendLease stores the checkpoint in a Lease
+	CheckpointBackendLease = "lease"
+)
+
+const (
+	// StartFromNow starts reading the event history at the current vCenter
+	// time when there is no checkpoint
+	StartFromNow = "now"
+	// StartFromEarliest starts reading the event history at the oldest event
+	// retained by vCenter when there is no checkpoint
+	StartFromEarliest = "earliest"
+)
+
 var (
 	ErrInvalidInterval = errors.New("invalid checkpoint time interval")
+	ErrInvalidMode     = errors.New("invalid checkpoint mode")
+	ErrInvalidBackend  = errors.New("invalid checkpoint backend")
 )
 
 // checkpoint represents a vCenter checkpoint object
@@ -46,14 +87,20 @@ type CheckpointConfig struct {
 	MaxAge time.Duration `json:"maxAge"`
 	// create checkpoints at given frequency
 	Period time.Duration `json:"period"`
+	// replay mode, defaults to at-least-once
+	Mode string `json:"mode,omitempty"`
+	// store of the checkpoint, defaults to configmap
+	Backend string `json:"backend,omitempty"`
 }
 
 // MarshalJSON defines custom marshalling logic to support human-readable time
 // input on the checkpoint configuration, e.g. "10m" or "1h".
 func (c *CheckpointConfig) MarshalJSON() ([]byte, error) {
 	var out struct {
-		MaxAge string `json:"maxAge"`
-		Period string `json:"period"`
+		MaxAge  string `json:"maxAge"`
+		Period  string `json:"period"`
+		Mode    string `json:"mode,omitempty"`
+		Backend string `json:"backend,omitempty"`
 	}
 
 	if c.MaxAge < time.Duration(0) {
@@ -66,6 +113,8 @@ func (c *CheckpointConfig) MarshalJSON() ([]byte, error) {
 
 	out.MaxAge = c.MaxAge.String()
 	out.Period = c.Period.String()
+	out.Mode = c.Mode
+	out.Backend = c.Backend
 	return json.Marshal(out)
 }
 
@@ -74,8 +123,10 @@ func (c *CheckpointConfig) MarshalJSON() ([]byte, error) {
 // without time suffix as input will fail encoding/decoding.
 func (c *CheckpointConfig) UnmarshalJSON(b []byte) error {
 	var in struct {
-		MaxAge string `json:"maxAge"`
-		Period string `json:"period"`
+		MaxAge  string `json:"maxAge"`
+		Period  string `json:"period"`
+		Mode    string `json:"mode"`
+		Backend string `json:"backend"`
 	}
 
 	var (
@@ -114,6 +165,20 @@ func (c *CheckpointConfig) UnmarshalJSON(b []byte) error {
 	}
 	c.Period = v
 
+	switch in.Mode {
+	case "", CheckpointModeAtLeastOnce, CheckpointModeExactlyOnceBestEffort:
+		c.Mode = in.Mode
+	default:
+		return ErrInvalidMode
+	}
+
+	switch in.Backend {
+	case "", CheckpointBackendConfigMap, CheckpointBackendLease:
+		c.Backend = in.Backend
+	default:
+		return ErrInvalidBackend
+	}
+
 	return nil
 }
 
@@ -132,3 +197,72 @@ func newCheckpointConfig(config string) (*CheckpointConfig, error) {
 
 	return &c, nil
 }
+
+// ParseCheckpoint returns the key and creation time of the last event
+// processed according to the given JSON-encoded checkpoint, as stored by the
+// adapter.
+func ParseCheckpoint(data string) (lastEventKey int32, lastEventTime time.Time, err error) {
+	var cp checkpoint
+	if err = json.Unmarshal([]byte(data), &cp); err != nil {
+		return 0, time.Time{}, err
+	}
+	return cp.LastEventKey, cp.LastEventKeyTimestamp, nil
+}
+
+// deliveredEventKey returns the key of the last event delivered to the sink
+// of the given vCenter according to the checkpoint, if events replayed up to
+// this key should be skipped in the configured mode. Zero is returned if
+// replayed events are sent again. vCenter event keys increase monotonically,
+// so keys of a different vCenter are not comparable. With a maxAge of zero
+// no events are replayed and there is nothing to skip.
+func deliveredEventKey(cp checkpoint, vcenter string, conf CheckpointConfig) int32 {
+	if conf.Mode != CheckpointModeExactlyOnceBestEffort || conf.MaxAge == 0 {
+		return 0
+	}
+
+	if cp.VCenter != vcenter {
+		return 0
+	}
+	return cp.LastEventKey
+}
+
+// saveCheckpoint persists the checkpoint store. The ConfigMap backed store
+// patches the checkpoint key, or falls back to an update with optimistic
+// concurrency, i.e. a concurrent modification results in a conflict, in which
+// case the save is retried against the latest version.
+func saveCheckpoint(ctx context.Context, store kvstore.Interface) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		return store.Save(ctx)
+	})
+}
+
+// initCheckpoint returns the begin of the event stream when there is no
+// checkpoint. With StartFromNow, a checkpoint at the given current vCenter
+// time is saved first, so that a restart before the first event is
+// checkpointed resumes from there instead of skipping the events in between.
+// With StartFromEarliest, the zero time is returned to read the whole event
+// history retained by vCenter.
+func (a *vAdapter) initCheckpoint(ctx context.Context, vcTime time.Time) time.Time {
+	logger := logging.FromContext(ctx)
+
+	if a.StartFrom == StartFromEarliest {
+		logger.Info("setting begin of event stream to the oldest event retained by vCenter")
+		return time.Time{}
+	}
+
+	cp := checkpoint{
+		VCenter:               a.VClient.URL().Host,
+		LastEventKeyTimestamp: vcTime,
+		CreatedTimestamp:      time.Now().UTC(),
+	}
+	err := a.KVStore.Set(ctx, checkpointKey, cp)
+	if err == nil {
+		err = saveCheckpoint(ctx, a.KVStore)
+	}
+	if err != nil {
+		// the adapter starts at the current vCenter time again on restart
+		logger.Warnw("could not save initial checkpoint", zap.Error(err))
+		a.StatsReporter.ReportCheckpointFailure()
+	}
+	return vcTime
+}
@@ -6,9 +6,22 @@ SPDX-License-Identifier: Apache-2.0
 package vsphere
 
 import (
+	"context"
+	"net/url"
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func Test_checkpointConfig_UnmarshalJSON(t *testing.T) {
@@ -183,6 +196,38 @@ func Test_newCheckpointConfig(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "config with exactly-once-best-effort mode",
+			args: args{config: `{"maxAge":"1h","period":"10s","mode":"exactly-once-best-effort"}`},
+			want: &CheckpointConfig{
+				MaxAge: time.Hour,
+				Period: 10 * time.Second,
+				Mode:   CheckpointModeExactlyOnceBestEffort,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "config with invalid mode",
+			args:    args{config: `{"mode":"exactly-once"}`},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "config with lease backend",
+			args: args{config: `{"maxAge":"1h","period":"10s","backend":"lease"}`},
+			want: &CheckpointConfig{
+				MaxAge:  time.Hour,
+				Period:  10 * time.Second,
+				Backend: CheckpointBackendLease,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "config with invalid backend",
+			args:    args{config: `{"backend":"etcd"}`},
+			want:    nil,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -197,3 +242,190 @@ func Test_newCheckpointConfig(t *testing.T) {
 		})
 	}
 }
+
+func Test_deliveredEventKey(t *testing.T) {
+	const vcenter = "vcenter.local"
+	cp := checkpoint{VCenter: vcenter, LastEventKey: 42}
+
+	tests := []struct {
+		name string
+		cp   checkpoint
+		conf CheckpointConfig
+		want int32
+	}{
+		{
+			name: "at-least-once replays all events",
+			cp:   cp,
+			conf: CheckpointConfig{MaxAge: time.Hour, Mode: CheckpointModeAtLeastOnce},
+			want: 0,
+		},
+		{
+			name: "default mode replays all events",
+			cp:   cp,
+			conf: CheckpointConfig{MaxAge: time.Hour},
+			want: 0,
+		},
+		{
+			name: "exactly-once-best-effort skips delivered events",
+			cp:   cp,
+			conf: CheckpointConfig{MaxAge: time.Hour, Mode: CheckpointModeExactlyOnceBestEffort},
+			want: 42,
+		},
+		{
+			name: "exactly-once-best-effort with replay disabled",
+			cp:   cp,
+			conf: CheckpointConfig{MaxAge: 0, Mode: CheckpointModeExactlyOnceBestEffort},
+			want: 0,
+		},
+		{
+			name: "exactly-once-best-effort with checkpoint of another vCenter",
+			cp:   checkpoint{VCenter: "other.local", LastEventKey: 42},
+			conf: CheckpointConfig{MaxAge: time.Hour, Mode: CheckpointModeExactlyOnceBestEffort},
+			want: 0,
+		},
+		{
+			name: "exactly-once-best-effort without checkpoint",
+			cp:   checkpoint{},
+			conf: CheckpointConfig{MaxAge: time.Hour, Mode: CheckpointModeExactlyOnceBestEffort},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deliveredEventKey(tt.cp, vcenter, tt.conf); got != tt.want {
+				t.Errorf("deliveredEventKey() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_saveCheckpoint(t *testing.T) {
+	tests := []struct {
+		name string
+		// reject patches, e.g. due to a role without the patch verb
+		forbidPatch bool
+		conflicts   int
+		wantErr     bool
+	}{
+		{
+			name: "patch",
+		},
+		{
+			name:        "update without conflict",
+			forbidPatch: true,
+		},
+		{
+			name:        "update retried on conflict",
+			forbidPatch: true,
+			conflicts:   2,
+		},
+		{
+			name:        "update with persistent conflict",
+			forbidPatch: true,
+			conflicts:   100,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "checkpoints", Namespace: "ns"},
+				// written by the reconciler
+				Data: map[string]string{"other": "value"},
+			}
+			kc := fake.NewSimpleClientset(cm)
+
+			kc.PrependReactor("patch", "configmaps", func(k8stesting.Action) (bool, runtime.Object, error) {
+				if !tt.forbidPatch {
+					return false, nil, nil
+				}
+				return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, cm.Name, nil)
+			})
+			conflicts := tt.conflicts
+			kc.PrependReactor("update", "configmaps", func(k8stesting.Action) (bool, runtime.Object, error) {
+				if conflicts == 0 {
+					return false, nil, nil
+				}
+				conflicts--
+				return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, cm.Name, nil)
+			})
+
+			store := newConfigMapKVStore(cm.Name, cm.Namespace, kc.CoreV1())
+			if err := store.Set(ctx, checkpointKey, checkpoint{LastEventKey: 42}); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+
+			err := saveCheckpoint(ctx, store)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("saveCheckpoint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got, err := kc.CoreV1().ConfigMaps(cm.Namespace).Get(ctx, cm.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("get configmap: %v", err)
+			}
+			if _, ok := got.Data[checkpointKey]; !ok {
+				t.Errorf("saveCheckpoint() checkpoint not persisted, data = %v", got.Data)
+			}
+			if got.Data["other"] != "value" {
+				t.Errorf("saveCheckpoint() overwrote other keys, data = %v", got.Data)
+			}
+		})
+	}
+}
+
+func Test_vAdapter_initCheckpoint(t *testing.T) {
+	u, err := url.Parse("https://vcenter.example.com/sdk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vcTime := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		startFrom      string
+		wantBegin      time.Time
+		wantCheckpoint bool
+	}{
+		{name: "default", wantBegin: vcTime, wantCheckpoint: true},
+		{name: "now", startFrom: StartFromNow, wantBegin: vcTime, wantCheckpoint: true},
+		{name: "earliest", startFrom: StartFromEarliest, wantBegin: time.Time{}, wantCheckpoint: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeKVStore{dataChan: make(chan string, 1)}
+			a := &vAdapter{
+				VClient:       &govmomi.Client{Client: &vim25.Client{Client: soap.NewClient(u, false)}},
+				KVStore:       store,
+				StartFrom:     tt.startFrom,
+				StatsReporter: &fakeStatsReporter{},
+			}
+
+			if got := a.initCheckpoint(context.Background(), vcTime); !got.Equal(tt.wantBegin) {
+				t.Errorf("initCheckpoint() = %v, want %v", got, tt.wantBegin)
+			}
+
+			var cp checkpoint
+			err := store.Get(context.Background(), checkpointKey, &cp)
+			if (err == nil) != tt.wantCheckpoint {
+				t.Fatalf("initCheckpoint() saved checkpoint = %v, want %v", err == nil, tt.wantCheckpoint)
+			}
+			if tt.wantCheckpoint {
+				if !store.saved {
+					t.Error("initCheckpoint() checkpoint not persisted")
+				}
+				if cp.VCenter != "vcenter.example.com" || cp.LastEventKey != 0 || !cp.LastEventKeyTimestamp.Equal(vcTime) {
+					t.Errorf("initCheckpoint() checkpoint = %+v, want key 0 at %v", cp, vcTime)
+				}
+				// a restart resumes from the initial checkpoint
+				if begin := getBeginFromCheckpoint(context.Background(), vcTime.Add(time.Minute), cp, CheckpointDefaultAge); !begin.Equal(vcTime) {
+					t.Errorf("getBeginFromCheckpoint() = %v, want %v", begin, vcTime)
+				}
+			}
+		})
+	}
+}
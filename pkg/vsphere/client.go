@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
@@ -22,6 +23,7 @@ import (
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/methods"
 	"github.com/vmware/govmomi/vim25/soap"
+	"golang.org/x/net/http/httpproxy"
 	"knative.dev/pkg/logging"
 
 	corev1 "k8s.io/api/core/v1"
@@ -31,12 +33,122 @@ const (
 	VolumeName        = "vsphere-binding"
 	DefaultMountPath  = "/var/bindings/vsphere" // filepath.Join isn't const.
 	keepaliveInterval = 5 * time.Minute         // vCenter APIs keep-alive
+
+	CACertsVolumeName = "vsphere-cacerts"
+	CACertsMountPath  = "/var/bindings/vsphere-cacerts"
+	CACertsKey        = "ca.crt"
+
+	CABundleVolumeName = "vsphere-ca-bundle"
+	CABundleMountPath  = "/var/bindings/vsphere-ca-bundle"
 )
 
 type EnvConfig struct {
 	Insecure   bool   `envconfig:"VC_INSECURE" default:"false"`
 	Address    string `envconfig:"VC_URL" required:"true"`
 	SecretPath string `envconfig:"VC_SECRET_PATH" default:""`
+	// CredentialsDir is the directory of the secret projected by a
+	// VSphereBinding with the volume projection
+	CredentialsDir string `envconfig:"VC_CREDENTIALS_DIR" default:""`
+	// UsernameKey and PasswordKey are the keys of the credentials in the
+	// secret
+	UsernameKey string `envconfig:"VC_USERNAME_KEY" default:"username"`
+	PasswordKey string `envconfig:"VC_PASSWORD_KEY" default:"password"`
+	// CACertKey is the key of a PEM encoded CA bundle in the secret to trust
+	// in addition to the system roots
+	CACertKey string `envconfig:"VC_CA_CERT_KEY" default:""`
+	// CACerts is the path to a PEM encoded CA bundle to trust in addition to
+	// the system roots
+	CACerts string `envconfig:"VC_CACERTS" default:""`
+	// CACertPath is the path to a PEM encoded CA bundle projected by the
+	// VSphereBinding from a ConfigMap
+	CACertPath string `envconfig:"VC_CA_CERT_PATH" default:""`
+
+	// HTTPProxy, HTTPSProxy and NoProxy configure the proxy of the vCenter
+	// API clients only, unlike HTTP_PROXY, HTTPS_PROXY and NO_PROXY which also
+	// apply to event deliveries
+	HTTPProxy  string `envconfig:"VC_HTTP_PROXY" default:""`
+	HTTPSProxy string `envconfig:"VC_HTTPS_PROXY" default:""`
+	NoProxy    string `envconfig:"VC_NO_PROXY" default:""`
+
+	// UserAgent is the user agent of the vCenter API clients, which
+	// identifies their sessions
+	UserAgent string `envconfig:"VC_USER_AGENT" default:""`
+}
+
+// proxy returns the proxy function of the vCenter API clients, or nil when no
+// proxy is configured.
+func (env EnvConfig) proxy() func(*http.Request) (*url.URL, error) {
+	if env.HTTPProxy == "" && env.HTTPSProxy == "" {
+		return nil
+	}
+
+	proxyFunc := (&httpproxy.Config{
+		HTTPProxy:  env.HTTPProxy,
+		HTTPSProxy: env.HTTPSProxy,
+		NoProxy:    env.NoProxy,
+	}).ProxyFunc()
+
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+}
+
+// rootCAs returns the list of CA bundle files trusted by the vCenter API
+// clients, or an empty string if none is configured.
+func (env EnvConfig) rootCAs() string {
+	var files []string
+	for _, f := range []string{env.CACerts, env.CACertPath} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	if env.CACertKey != "" {
+		files = append(files, filepath.Join(env.secretPath(), env.CACertKey))
+	}
+	return strings.Join(files, string(filepath.ListSeparator))
+}
+
+// secretPath returns the path the secret with the credentials is mounted at.
+func (env EnvConfig) secretPath() string {
+	switch {
+	case env.SecretPath != "":
+		return env.SecretPath
+	case env.CredentialsDir != "":
+		return env.CredentialsDir
+	default:
+		return DefaultMountPath
+	}
+}
+
+// readKey reads the key from the secret.
+func (env EnvConfig) readKey(key string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(env.secretPath(), key))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// credentials reads the username and password from the configured keys of
+// the secret.
+func (env EnvConfig) credentials() (*url.Userinfo, error) {
+	usernameKey, passwordKey := env.UsernameKey, env.PasswordKey
+	if usernameKey == "" {
+		usernameKey = corev1.BasicAuthUsernameKey
+	}
+	if passwordKey == "" {
+		passwordKey = corev1.BasicAuthPasswordKey
+	}
+
+	username, err := env.readKey(usernameKey)
+	if err != nil {
+		return nil, err
+	}
+	password, err := env.readKey(passwordKey)
+	if err != nil {
+		return nil, err
+	}
+	return url.UserPassword(username, password), nil
 }
 
 // ReadKey reads the key from the secret.
@@ -45,17 +157,25 @@ func ReadKey(key string) (string, error) {
 	if err := envconfig.Process("", &env); err != nil {
 		return "", err
 	}
+	return env.readKey(key)
+}
 
-	mountPath := DefaultMountPath
-	if env.SecretPath != "" {
-		mountPath = env.SecretPath
+// ReadCredentials reads the username and password from the secret.
+func ReadCredentials() (*url.Userinfo, error) {
+	var env EnvConfig
+	if err := envconfig.Process("", &env); err != nil {
+		return nil, err
 	}
+	return env.credentials()
+}
 
-	data, err := ioutil.ReadFile(filepath.Join(mountPath, key))
-	if err != nil {
+// ReadAddress returns the address of the configured vCenter.
+func ReadAddress() (string, error) {
+	var env EnvConfig
+	if err := envconfig.Process("", &env); err != nil {
 		return "", err
 	}
-	return string(data), nil
+	return env.Address, nil
 }
 
 // NewSOAPClient returns a vCenter SOAP API client with active keep-alive. Use
@@ -65,28 +185,57 @@ func NewSOAPClient(ctx context.Context) (*govmomi.Client, error) {
 	if err := envconfig.Process("", &env); err != nil {
 		return nil, err
 	}
+	return env.newSOAPClient(ctx)
+}
 
-	parsedURL, err := soap.ParseURL(env.Address)
-	if err != nil {
+// newSOAPClientAt returns a vCenter SOAP API client like NewSOAPClient, but
+// for the vCenter at the given address, e.g. a failover vCenter.
+func newSOAPClientAt(ctx context.Context, address string) (*govmomi.Client, error) {
+	var env EnvConfig
+	if err := envconfig.Process("", &env); err != nil {
 		return nil, err
 	}
+	env.Address = address
+	return env.newSOAPClient(ctx)
+}
 
-	// Read the username and password from the filesystem.
-	username, err := ReadKey(corev1.BasicAuthUsernameKey)
+func (env EnvConfig) newSOAPClient(ctx context.Context) (*govmomi.Client, error) {
+	parsedURL, err := soap.ParseURL(env.Address)
 	if err != nil {
 		return nil, err
 	}
-	password, err := ReadKey(corev1.BasicAuthPasswordKey)
-	if err != nil {
+
+	// Read the username and password from the filesystem.
+	if parsedURL.User, err = env.credentials(); err != nil {
 		return nil, err
 	}
-	parsedURL.User = url.UserPassword(username, password)
 
-	return soapWithKeepalive(ctx, parsedURL, env.Insecure)
+	return soapWithKeepalive(ctx, parsedURL, env)
 }
 
-func soapWithKeepalive(ctx context.Context, url *url.URL, insecure bool) (*govmomi.Client, error) {
-	soapClient := soap.NewClient(url, insecure)
+// newSOAP returns a vCenter SOAP client with its own transport, so that the
+// proxy and CA settings do not affect other HTTP clients.
+func newSOAP(url *url.URL, env EnvConfig) (*soap.Client, error) {
+	soapClient := soap.NewClient(url, env.Insecure)
+	if rootCAs := env.rootCAs(); rootCAs != "" {
+		if err := soapClient.SetRootCAs(rootCAs); err != nil {
+			return nil, err
+		}
+	}
+	if proxy := env.proxy(); proxy != nil {
+		soapClient.DefaultTransport().Proxy = proxy
+	}
+	if env.UserAgent != "" {
+		soapClient.UserAgent = env.UserAgent
+	}
+	return soapClient, nil
+}
+
+func soapWithKeepalive(ctx context.Context, url *url.URL, env EnvConfig) (*govmomi.Client, error) {
+	soapClient, err := newSOAP(url, env)
+	if err != nil {
+		return nil, err
+	}
 	vimClient, err := vim25.NewClient(ctx, soapClient)
 	if err != nil {
 		return nil, err
@@ -137,17 +286,11 @@ func NewRESTClient(ctx context.Context) (*rest.Client, error) {
 	}
 
 	// Read the username and password from the filesystem.
-	username, err := ReadKey(corev1.BasicAuthUsernameKey)
-	if err != nil {
-		return nil, err
-	}
-	password, err := ReadKey(corev1.BasicAuthPasswordKey)
-	if err != nil {
+	if parsedURL.User, err = env.credentials(); err != nil {
 		return nil, err
 	}
-	parsedURL.User = url.UserPassword(username, password)
 
-	soapclient, err := soapWithKeepalive(ctx, parsedURL, env.Insecure)
+	soapclient, err := soapWithKeepalive(ctx, parsedURL, env)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,204 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_newSOAPProxy(t *testing.T) {
+	vc, err := url.Parse("https://vcenter.corp.local/sdk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vcReq := &http.Request{URL: vc}
+
+	tests := []struct {
+		name string
+		env  EnvConfig
+		want string
+	}{
+		{
+			name: "no proxy",
+			env:  EnvConfig{},
+			want: "",
+		},
+		{
+			name: "https proxy",
+			env:  EnvConfig{HTTPSProxy: "http://proxy.corp.local:3128"},
+			want: "http://proxy.corp.local:3128",
+		},
+		{
+			name: "vCenter in noProxy",
+			env:  EnvConfig{HTTPSProxy: "http://proxy.corp.local:3128", NoProxy: ".corp.local"},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HTTPS_PROXY", "")
+
+			c, err := newSOAP(vc, tt.env)
+			if err != nil {
+				t.Fatalf("newSOAP() error = %v", err)
+			}
+
+			transport := c.DefaultTransport()
+			if transport == http.DefaultTransport {
+				t.Fatal("newSOAP() uses the default transport")
+			}
+
+			var got string
+			if transport.Proxy != nil {
+				u, err := transport.Proxy(vcReq)
+				if err != nil {
+					t.Fatalf("Proxy() error = %v", err)
+				}
+				if u != nil {
+					got = u.String()
+				}
+			}
+			if got != tt.want {
+				t.Errorf("newSOAP() proxy = %q, want %q", got, tt.want)
+			}
+
+			// the transport of event deliveries is not affected
+			sink, err := http.DefaultTransport.(*http.Transport).Proxy(vcReq)
+			if err != nil || sink != nil {
+				t.Errorf("default transport proxy = %v, %v, want no proxy", sink, err)
+			}
+		})
+	}
+}
+
+func Test_newSOAPRootCAs(t *testing.T) {
+	vc, err := url.Parse("https://vcenter.corp.local/sdk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	caCerts := filepath.Join(dir, "cacerts.crt")
+	caBundle := filepath.Join(dir, "ca.crt")
+	for _, f := range []string{caCerts, caBundle} {
+		if err := os.WriteFile(f, []byte(selfSignedCACerts(t)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		env     EnvConfig
+		wantCAs bool
+		wantErr bool
+	}{
+		{
+			name:    "system roots",
+			env:     EnvConfig{},
+			wantCAs: false,
+		},
+		{
+			name:    "inline CA certs",
+			env:     EnvConfig{CACerts: caCerts},
+			wantCAs: true,
+		},
+		{
+			name:    "CA bundle from ConfigMap",
+			env:     EnvConfig{CACertPath: caBundle},
+			wantCAs: true,
+		},
+		{
+			name:    "both",
+			env:     EnvConfig{CACerts: caCerts, CACertPath: caBundle},
+			wantCAs: true,
+		},
+		{
+			name:    "missing CA bundle",
+			env:     EnvConfig{CACertPath: filepath.Join(dir, "missing.crt")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newSOAP(vc, tt.env)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newSOAP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got := c.DefaultTransport().TLSClientConfig.RootCAs != nil; got != tt.wantCAs {
+				t.Errorf("newSOAP() custom root CAs = %v, want %v", got, tt.wantCAs)
+			}
+		})
+	}
+}
+
+func TestEnvConfig_rootCAs(t *testing.T) {
+	env := EnvConfig{CACerts: "/a/ca.crt", CACertPath: "/b/ca.crt"}
+	if got, want := env.rootCAs(), "/a/ca.crt"+string(filepath.ListSeparator)+"/b/ca.crt"; got != want {
+		t.Errorf("rootCAs() = %q, want %q", got, want)
+	}
+}
+
+func TestEnvConfig_rootCAsSecretKey(t *testing.T) {
+	env := EnvConfig{SecretPath: "/secret", CACertKey: "ca.pem"}
+	if got, want := env.rootCAs(), "/secret/ca.pem"; got != want {
+		t.Errorf("rootCAs() = %q, want %q", got, want)
+	}
+}
+
+func TestEnvConfig_credentials(t *testing.T) {
+	dir := t.TempDir()
+	for key, value := range map[string]string{
+		"username": "administrator@vsphere.local",
+		"password": "default",
+		"user":     "fxmulder",
+		"pass":     "trustno1",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, key), []byte(value), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		env  EnvConfig
+		want string
+	}{{
+		name: "default keys",
+		env:  EnvConfig{SecretPath: dir},
+		want: "administrator%40vsphere.local:default",
+	}, {
+		name: "configured keys",
+		env:  EnvConfig{SecretPath: dir, UsernameKey: "user", PasswordKey: "pass"},
+		want: "fxmulder:trustno1",
+	}, {
+		name: "credentials directory",
+		env:  EnvConfig{CredentialsDir: dir},
+		want: "administrator%40vsphere.local:default",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := tt.env.credentials()
+			if err != nil {
+				t.Fatalf("credentials() error = %v", err)
+			}
+			if got := user.String(); got != tt.want {
+				t.Errorf("credentials() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := (EnvConfig{SecretPath: dir, UsernameKey: "missing"}).credentials(); err == nil {
+		t.Error("credentials() with missing key error = nil, want error")
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentEncodingGzip compresses the HTTP body of the delivered events with
+// gzip
+const ContentEncodingGzip = "gzip"
+
+// gzipTransport compresses the bodies of the requests to destinations which
+// accept gzip. A destination accepts gzip once one of its responses
+// advertises it with the Accept-Encoding header (RFC 7694), until a response
+// advertises otherwise. When forced, the bodies of all requests are
+// compressed.
+type gzipTransport struct {
+	base  http.RoundTripper
+	force bool
+
+	mu sync.RWMutex
+	// accepting holds the hosts which advertised gzip
+	accepting map[string]bool
+}
+
+// newGzipTransport returns a transport compressing request bodies with gzip.
+// The default transport is used if base is nil.
+func newGzipTransport(base http.RoundTripper, force bool) *gzipTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &gzipTransport{
+		base:      base,
+		force:     force,
+		accepting: make(map[string]bool),
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *gzipTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" ||
+		!(t.force || t.accepts(host)) {
+		resp, err := t.base.RoundTrip(req)
+		t.observe(host, resp)
+		return resp, err
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read request body: %w", err)
+	}
+
+	compressed, err := gzipBytes(body)
+	if err != nil {
+		return nil, fmt.Errorf("compress request body: %w", err)
+	}
+
+	resp, err := t.base.RoundTrip(withBody(req, compressed, ContentEncodingGzip))
+	t.observe(host, resp)
+	if err != nil || t.force || resp.StatusCode != http.StatusUnsupportedMediaType {
+		return resp, err
+	}
+
+	// the destination no longer accepts gzip, the request is sent again
+	// uncompressed
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	t.setAccepts(host, false)
+	return t.base.RoundTrip(withBody(req, body, ""))
+}
+
+func (t *gzipTransport) accepts(host string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.accepting[host]
+}
+
+func (t *gzipTransport) setAccepts(host string, accepts bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.accepting[host] = accepts
+}
+
+// observe records whether the destination accepts gzip if the response
+// advertises its accepted content codings.
+func (t *gzipTransport) observe(host string, resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	if values := resp.Header.Values("Accept-Encoding"); len(values) > 0 {
+		t.setAccepts(host, acceptsGzip(values))
+	}
+}
+
+// acceptsGzip returns true if the given Accept-Encoding header values include
+// gzip with a non-zero quality.
+func acceptsGzip(values []string) bool {
+	for _, v := range values {
+		for _, coding := range strings.Split(v, ",") {
+			name, params, _ := strings.Cut(strings.TrimSpace(coding), ";")
+			if !strings.EqualFold(strings.TrimSpace(name), ContentEncodingGzip) {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+					q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+					return err == nil && q > 0
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// withBody returns a clone of the given request with the given body and
+// content encoding.
+func withBody(req *http.Request, body []byte, encoding string) *http.Request {
+	// RoundTrippers must not modify the request
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	if encoding == "" {
+		req.Header.Del("Content-Encoding")
+	} else {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	return req
+}
+
+func gzipBytes(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// gzipSink decompresses gzip encoded requests and records the received
+// bodies and content encodings.
+type gzipSink struct {
+	sync.Mutex
+	// acceptEncoding is advertised in the responses, if set
+	acceptEncoding string
+	// rejectGzip responds to gzip encoded requests with 415
+	rejectGzip bool
+
+	bodies    []string
+	encodings []string
+}
+
+func (s *gzipSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.acceptEncoding != "" {
+		w.Header().Set("Accept-Encoding", s.acceptEncoding)
+	}
+
+	encoding := r.Header.Get("Content-Encoding")
+	if encoding == "gzip" && s.rejectGzip {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var body io.Reader = r.Body
+	if encoding == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		defer zr.Close()
+		body = zr
+	}
+
+	b, err := io.ReadAll(body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.bodies = append(s.bodies, string(b))
+	s.encodings = append(s.encodings, encoding)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func Test_gzipTransport(t *testing.T) {
+	const payload = `{"Key":42,"FullFormattedMessage":"Task: Power On virtual machine"}`
+
+	tests := []struct {
+		name           string
+		force          bool
+		acceptEncoding string
+		rejectGzip     bool
+		wantEncodings  []string
+	}{
+		{
+			name:          "sink does not advertise gzip",
+			wantEncodings: []string{"", "", ""},
+		},
+		{
+			name:           "sink advertises gzip",
+			acceptEncoding: "gzip, deflate",
+			wantEncodings:  []string{"", "gzip", "gzip"},
+		},
+		{
+			name:           "sink advertises gzip with zero quality",
+			acceptEncoding: "gzip;q=0, identity",
+			wantEncodings:  []string{"", "", ""},
+		},
+		{
+			name:          "forced",
+			force:         true,
+			wantEncodings: []string{"gzip", "gzip", "gzip"},
+		},
+		{
+			name:           "sink rejects gzip after advertising it",
+			acceptEncoding: "gzip",
+			rejectGzip:     true,
+			// the rejected request is sent again uncompressed, the
+			// advertisement of the next response enables gzip again
+			wantEncodings: []string{"", "", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &gzipSink{acceptEncoding: tt.acceptEncoding, rejectGzip: tt.rejectGzip}
+			srv := httptest.NewServer(sink)
+			defer srv.Close()
+
+			client := &http.Client{Transport: newGzipTransport(nil, tt.force)}
+			for i := 0; i < len(tt.wantEncodings); i++ {
+				resp, err := client.Post(srv.URL, "application/json", strings.NewReader(payload))
+				if err != nil {
+					t.Fatalf("post: %v", err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusAccepted {
+					t.Fatalf("post: status = %d, want %d", resp.StatusCode, http.StatusAccepted)
+				}
+			}
+
+			if diff := cmp.Diff(tt.wantEncodings, sink.encodings); diff != "" {
+				t.Errorf("RoundTrip() content encodings (-want, +got) = %v", diff)
+			}
+			for i, body := range sink.bodies {
+				if body != payload {
+					t.Errorf("RoundTrip() body %d = %q, want %q", i, body, payload)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"go.uber.org/zap"
+	"knative.dev/pkg/logging"
+)
+
+// CheckpointPath is the HTTP path of the adapter debug endpoint reporting the
+// current checkpoint and vCenter session state
+const CheckpointPath = "/debug/checkpoint"
+
+// lastCheckpoint holds the latest checkpoint of the adapter for the debug
+// endpoint, since the KV store must only be accessed by the event loop.
+type lastCheckpoint struct {
+	sync.Mutex
+	cp *checkpoint
+}
+
+func (l *lastCheckpoint) set(cp checkpoint) {
+	l.Lock()
+	defer l.Unlock()
+	l.cp = &cp
+}
+
+func (l *lastCheckpoint) get() *checkpoint {
+	l.Lock()
+	defer l.Unlock()
+	return l.cp
+}
+
+// checkpointStatus is the response of the checkpoint debug endpoint.
+type checkpointStatus struct {
+	// Checkpoint is the last checkpoint, which might not be saved yet. Nil
+	// if no event was processed and no checkpoint was restored.
+	Checkpoint *checkpoint   `json:"checkpoint"`
+	Session    sessionStatus `json:"session"`
+}
+
+// sessionStatus describes the vCenter session of the adapter.
+type sessionStatus struct {
+	VCenter string `json:"vCenter"`
+	// Active is true if the vCenter session is logged in
+	Active bool   `json:"active"`
+	User   string `json:"user,omitempty"`
+	// EventStream is true if events are read from vCenter
+	EventStream bool   `json:"eventStream"`
+	Error       string `json:"error,omitempty"`
+}
+
+// serveCheckpoint serves the current checkpoint and vCenter session state as
+// JSON.
+func (a *vAdapter) serveCheckpoint(w http.ResponseWriter, r *http.Request) {
+	status := checkpointStatus{
+		Checkpoint: a.lastCheckpoint.get(),
+		Session: sessionStatus{
+			VCenter:     a.VClient.URL().Host,
+			EventStream: a.health.isReady(),
+		},
+	}
+
+	session, err := a.VClient.SessionManager.UserSession(r.Context())
+	switch {
+	case err != nil:
+		status.Session.Error = err.Error()
+	case session != nil:
+		status.Session.Active = true
+		status.Session.User = session.UserName
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		logging.FromContext(r.Context()).Warnw("could not write checkpoint status", zap.Error(err))
+	}
+}
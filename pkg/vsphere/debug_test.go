@@ -0,0 +1,107 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+)
+
+func TestServeCheckpoint(t *testing.T) {
+	now := time.Date(2022, 3, 21, 16, 35, 39, 0, time.UTC)
+	cp := checkpoint{
+		VCenter:               "vcenter.local",
+		LastEventKey:          41,
+		LastEventType:         "VmPoweredOffEvent",
+		LastEventKeyTimestamp: now,
+		CreatedTimestamp:      now.Add(time.Second),
+	}
+
+	tests := []struct {
+		name       string
+		checkpoint *checkpoint
+		ready      bool
+		logout     bool
+		want       checkpointStatus
+	}{
+		{
+			name:  "no checkpoint yet",
+			ready: true,
+			want: checkpointStatus{
+				Session: sessionStatus{Active: true, User: "user", EventStream: true},
+			},
+		},
+		{
+			name:       "checkpoint",
+			checkpoint: &cp,
+			ready:      true,
+			want: checkpointStatus{
+				Checkpoint: &cp,
+				Session:    sessionStatus{Active: true, User: "user", EventStream: true},
+			},
+		},
+		{
+			name:       "logged out",
+			checkpoint: &cp,
+			logout:     true,
+			want: checkpointStatus{
+				Checkpoint: &cp,
+				Session:    sessionStatus{Active: false},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			simulator.Run(func(ctx context.Context, vim *vim25.Client) error {
+				vcClient := &govmomi.Client{
+					Client:         vim,
+					SessionManager: session.NewManager(vim),
+				}
+				if tt.logout {
+					if err := vcClient.Logout(ctx); err != nil {
+						t.Fatalf("logout: %v", err)
+					}
+				}
+
+				a := &vAdapter{VClient: vcClient}
+				a.health.setReady(tt.ready)
+				if tt.checkpoint != nil {
+					a.lastCheckpoint.set(*tt.checkpoint)
+				}
+				a.health.checkpoint = http.HandlerFunc(a.serveCheckpoint)
+
+				rec := httptest.NewRecorder()
+				a.health.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, CheckpointPath, nil))
+
+				if rec.Code != http.StatusOK {
+					t.Fatalf("ServeHTTP() status = %d, want %d", rec.Code, http.StatusOK)
+				}
+
+				var got checkpointStatus
+				if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+					t.Fatalf("decode checkpoint status: %v", err)
+				}
+
+				want := tt.want
+				want.Session.VCenter = vim.URL().Host
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("serveCheckpoint() (-want, +got) = %v", diff)
+				}
+				return nil
+			})
+		})
+	}
+}
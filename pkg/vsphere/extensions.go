@@ -0,0 +1,65 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"strings"
+	"unicode"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+const (
+	// extended attributes describing the vSphere entities an event refers
+	// to, so that consumers can filter without parsing the payload
+	ceVSphereEventClassKey = "vsphereeventclass"
+	ceVSphereVMNameKey     = "vspherevmname"
+	ceVSphereHostNameKey   = "vspherehostname"
+	ceVSphereDatacenterKey = "vspheredatacenter"
+	ceVSphereUserKey       = "vsphereuser"
+)
+
+// setEntityExtensions sets the extended attributes describing the vSphere
+// entities and user of the given event. Attributes are only set for the
+// fields present in the event, e.g. datacenter-level events have no VM or
+// host attribute.
+func setEntityExtensions(ev *cloudevents.Event, be types.BaseEvent) {
+	e := be.GetEvent()
+
+	attrs := map[string]string{
+		ceVSphereEventClassKey: getEventDetails(be).Class,
+		ceVSphereUserKey:       e.UserName,
+	}
+	if e.Vm != nil {
+		attrs[ceVSphereVMNameKey] = e.Vm.Name
+	}
+	if e.Host != nil {
+		attrs[ceVSphereHostNameKey] = e.Host.Name
+	}
+	if e.Datacenter != nil {
+		attrs[ceVSphereDatacenterKey] = e.Datacenter.Name
+	}
+
+	for name, value := range attrs {
+		if value = sanitizeExtensionValue(value); value != "" {
+			ev.SetExtension(name, value)
+		}
+	}
+}
+
+// sanitizeExtensionValue returns the given value without the characters not
+// allowed in CloudEvent string attributes, i.e. control characters and
+// unassigned or invalid code points, and without surrounding spaces.
+func sanitizeExtensionValue(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if r == unicode.ReplacementChar || !unicode.IsGraphic(r) {
+			return -1
+		}
+		return r
+	}, value)
+	return strings.TrimSpace(value)
+}
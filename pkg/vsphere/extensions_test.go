@@ -0,0 +1,175 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/go-cmp/cmp"
+	"github.com/vmware/govmomi/vim25/types"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+)
+
+func Test_setEntityExtensions(t *testing.T) {
+	tests := []struct {
+		name  string
+		event types.BaseEvent
+		want  map[string]interface{}
+	}{
+		{
+			name: "vm event",
+			event: &types.VmPoweredOnEvent{VmEvent: types.VmEvent{Event: types.Event{
+				UserName:   "VSPHERE.LOCAL\\Administrator",
+				Vm:         &types.VmEventArgument{EntityEventArgument: types.EntityEventArgument{Name: "vm-01"}},
+				Host:       &types.HostEventArgument{EntityEventArgument: types.EntityEventArgument{Name: "esx-01.corp.local"}},
+				Datacenter: &types.DatacenterEventArgument{EntityEventArgument: types.EntityEventArgument{Name: "dc-01"}},
+			}}},
+			want: map[string]interface{}{
+				ceVSphereEventClassKey: "event",
+				ceVSphereUserKey:       "VSPHERE.LOCAL\\Administrator",
+				ceVSphereVMNameKey:     "vm-01",
+				ceVSphereHostNameKey:   "esx-01.corp.local",
+				ceVSphereDatacenterKey: "dc-01",
+			},
+		},
+		{
+			name: "datacenter event without vm and host",
+			event: &types.DatacenterRenamedEvent{DatacenterEvent: types.DatacenterEvent{Event: types.Event{
+				UserName:   "admin",
+				Datacenter: &types.DatacenterEventArgument{EntityEventArgument: types.EntityEventArgument{Name: "dc-02"}},
+			}}},
+			want: map[string]interface{}{
+				ceVSphereEventClassKey: "event",
+				ceVSphereUserKey:       "admin",
+				ceVSphereDatacenterKey: "dc-02",
+			},
+		},
+		{
+			name: "system event without entities and user",
+			event: &types.EventEx{
+				EventTypeId: "com.vmware.vc.example",
+			},
+			want: map[string]interface{}{
+				ceVSphereEventClassKey: "eventex",
+			},
+		},
+		{
+			name: "sanitized values",
+			event: &types.VmPoweredOffEvent{VmEvent: types.VmEvent{Event: types.Event{
+				UserName: " \t\n",
+				Vm:       &types.VmEventArgument{EntityEventArgument: types.EntityEventArgument{Name: "vm\x00-\n03 "}},
+			}}},
+			want: map[string]interface{}{
+				ceVSphereEventClassKey: "event",
+				ceVSphereVMNameKey:     "vm-03",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := cloudevents.NewEvent()
+			setEntityExtensions(&ev, tt.event)
+
+			if diff := cmp.Diff(tt.want, ev.Extensions()); diff != "" {
+				t.Errorf("setEntityExtensions() (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func Test_sanitizeExtensionValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "empty", value: "", want: ""},
+		{name: "unchanged", value: "vm 01 (copy)", want: "vm 01 (copy)"},
+		{name: "unicode", value: "Rechenzentrum München", want: "Rechenzentrum München"},
+		{name: "control characters", value: "vm\x00\x1b-01\u0085", want: "vm-01"},
+		{name: "surrounding spaces", value: "  vm-01\n", want: "vm-01"},
+		{name: "invalid utf-8", value: "vm-\xff01", want: "vm-01"},
+		{name: "noncharacter", value: "vm-01\uffff", want: "vm-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeExtensionValue(tt.value); got != tt.want {
+				t.Errorf("sanitizeExtensionValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newCloudEventEntityExtensions(t *testing.T) {
+	be := &types.VmPoweredOnEvent{VmEvent: types.VmEvent{Event: types.Event{
+		Key: 1,
+		Vm:  &types.VmEventArgument{EntityEventArgument: types.EntityEventArgument{Name: "vm-01"}},
+	}}}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		want    interface{}
+	}{
+		{name: "enabled", enabled: true, want: "vm-01"},
+		{name: "disabled", enabled: false, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := vAdapter{
+				Source:           source,
+				PayloadEncoding:  cloudevents.ApplicationJSON,
+				VAPIVersion:      "6.7.0",
+				EntityExtensions: tt.enabled,
+			}
+
+			ev, err := a.newCloudEvent(be)
+			if err != nil {
+				t.Fatalf("newCloudEvent() error = %v", err)
+			}
+			if err = ev.Validate(); err != nil {
+				t.Fatalf("newCloudEvent() invalid event: %v", err)
+			}
+			if got := ev.Extensions()[ceVSphereVMNameKey]; got != tt.want {
+				t.Errorf("newCloudEvent() %s = %v, want %v", ceVSphereVMNameKey, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newCloudEventOverrides(t *testing.T) {
+	be := &types.VmPoweredOnEvent{VmEvent: types.VmEvent{Event: types.Event{
+		Key: 1,
+		Vm:  &types.VmEventArgument{EntityEventArgument: types.EntityEventArgument{Name: "vm-01"}},
+	}}}
+
+	a := vAdapter{
+		Source:           source,
+		PayloadEncoding:  cloudevents.ApplicationJSON,
+		VAPIVersion:      "6.7.0",
+		EntityExtensions: true,
+		CEOverrides: &duckv1.CloudEventOverrides{Extensions: map[string]string{
+			"environment":      "production",
+			ceVSphereVMNameKey: "overridden",
+		}},
+	}
+
+	ev, err := a.newCloudEvent(be)
+	if err != nil {
+		t.Fatalf("newCloudEvent() error = %v", err)
+	}
+	if err = ev.Validate(); err != nil {
+		t.Fatalf("newCloudEvent() invalid event: %v", err)
+	}
+
+	// overrides take precedence over the entity extensions
+	for name, want := range a.CEOverrides.Extensions {
+		if got := ev.Extensions()[name]; got != want {
+			t.Errorf("newCloudEvent() %s = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/logging"
+)
+
+// DefaultFailoverThreshold is the number of consecutive connection failures
+// after which the adapter fails over to the next vCenter.
+const DefaultFailoverThreshold = 3
+
+const (
+	// failoverLogoutTimeout bounds the logout from the unreachable vCenter
+	failoverLogoutTimeout = 5 * time.Second
+	// failoverEventReason is the reason of the Kubernetes event recorded on
+	// the adapter pod when it fails over
+	failoverEventReason = "VCenterFailover"
+)
+
+// connectFunc returns a client of the vCenter at the given address.
+type connectFunc func(ctx context.Context, address string) (*govmomi.Client, error)
+
+// connectFirst returns a client of the first reachable vCenter of the given
+// addresses and the index of its address.
+func connectFirst(ctx context.Context, connect connectFunc, addresses []string) (*govmomi.Client, int, error) {
+	var err error
+	for i, address := range addresses {
+		var c *govmomi.Client
+		if c, err = connect(ctx, address); err == nil {
+			return c, i, nil
+		}
+		if i < len(addresses)-1 {
+			logging.FromContext(ctx).Warnw("could not connect to vCenter, trying next address",
+				zap.String("address", address), zap.Error(err))
+		}
+	}
+	return nil, 0, err
+}
+
+// failover connects to the next reachable vCenter after the active one, in
+// the order of Addresses, and replaces the client of the adapter. The event
+// stream is resumed from the last checkpoint.
+func (a *vAdapter) failover(ctx context.Context) error {
+	connect := a.Connect
+	if connect == nil {
+		connect = newSOAPClientAt
+	}
+
+	from := a.Addresses[a.activeAddress]
+	for i := 1; i < len(a.Addresses); i++ {
+		next := (a.activeAddress + i) % len(a.Addresses)
+		to := a.Addresses[next]
+
+		c, err := connect(ctx, to)
+		if err != nil {
+			a.Logger.Warnw("could not connect to vCenter", zap.String("address", to), zap.Error(err))
+			continue
+		}
+
+		// best effort, the vCenter is likely unreachable
+		logoutCtx, cancel := context.WithTimeout(context.Background(), failoverLogoutTimeout)
+		_ = a.VClient.Logout(logoutCtx)
+		cancel()
+
+		a.VClient = c
+		a.VAPIVersion = c.ServiceContent.About.ApiVersion
+		a.VCenterUUID = c.ServiceContent.About.InstanceUuid
+		a.activeAddress = next
+		a.connectionFailures = 0
+
+		a.Logger.Warnw("failed over to vCenter", zap.String("from", from), zap.String("to", to))
+		a.recordFailoverEvent(ctx, from, to)
+		return nil
+	}
+
+	return fmt.Errorf("none of the %d other vCenters is reachable", len(a.Addresses)-1)
+}
+
+// recordFailoverEvent records a Kubernetes event on the adapter pod about the
+// failover to another vCenter. Failures to record it are only logged.
+func (a *vAdapter) recordFailoverEvent(ctx context.Context, from, to string) {
+	if a.KubeClient == nil || a.PodName == "" {
+		return
+	}
+
+	now := metav1.Now()
+	ev := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", a.PodName, now.UnixNano()),
+			Namespace: a.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Name:       a.PodName,
+			Namespace:  a.Namespace,
+		},
+		Reason:         failoverEventReason,
+		Message:        fmt.Sprintf("Failed over from vCenter %q to %q", from, to),
+		Type:           corev1.EventTypeWarning,
+		Source:         corev1.EventSource{Component: AdapterComponent},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+
+	if _, err := a.KubeClient.CoreV1().Events(a.Namespace).Create(ctx, ev, metav1.CreateOptions{}); err != nil {
+		a.Logger.Warnw("could not record failover event", zap.Error(err))
+	}
+}
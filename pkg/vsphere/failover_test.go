@@ -0,0 +1,168 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/client"
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+	"go.uber.org/zap/zaptest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_vAdapter_runFailover(t *testing.T) {
+	const poweredOffEventType = "com.vmware.vsphere.VmPoweredOffEvent.v0"
+
+	simulator.Run(func(ctx context.Context, vim *vim25.Client) error {
+		ctx = cecontext.WithTarget(ctx, "fake.example.com")
+
+		p, err := cehttp.New(cehttp.WithRoundTripper(&roundTripperTest{statusCodes: createStatusCodes(100, failNever)}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the primary vCenter is a proxy of the simulator, the standby the
+		// simulator itself, so that both share the event stream
+		standby := vim.URL()
+		rp := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: standby.Scheme, Host: standby.Host})
+		rp.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+		proxy := httptest.NewServer(rp)
+		defer proxy.Close()
+		primary := proxy.URL + standby.Path
+
+		connect := func(ctx context.Context, address string) (*govmomi.Client, error) {
+			u, err := soap.ParseURL(address)
+			if err != nil {
+				return nil, err
+			}
+			u.User = simulator.DefaultLogin
+			return govmomi.NewClient(ctx, u, true)
+		}
+
+		vc, err := connect(ctx, primary)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		kc := fake.NewSimpleClientset()
+		stats := &fakeStatsReporter{}
+		a := &vAdapter{
+			Logger:            zaptest.NewLogger(t).Sugar(),
+			Namespace:         "ns",
+			PodName:           "adapter",
+			Source:            source,
+			VClient:           vc,
+			Addresses:         []string{primary, standby.String()},
+			Connect:           connect,
+			FailoverThreshold: 2,
+			CEClient:          c,
+			KVStore: &fakeKVStore{
+				// replay the events of the inventory
+				data:     map[string]string{checkpointKey: createCheckpoint(t, time.Now().UTC().Add(-time.Hour))},
+				dataChan: make(chan string, 1),
+			},
+			CpConfig: CheckpointConfig{
+				MaxAge: time.Hour,
+				// only the final checkpoint is saved
+				Period: time.Hour,
+			},
+			PollInterval:     10 * time.Millisecond,
+			ReconnectBackoff: 10 * time.Millisecond,
+			StatsReporter:    stats,
+			KubeClient:       kc,
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		runErr := make(chan error, 1)
+		go func() {
+			runErr <- a.run(ctx)
+		}()
+
+		delivered := func(eventType string) bool {
+			stats.Lock()
+			defer stats.Unlock()
+			if eventType == "" {
+				return len(stats.delivered) > 0
+			}
+			return stats.delivered[eventType] > 0
+		}
+		waitFor := func(what string, cond func() bool) {
+			t.Helper()
+			for deadline := time.Now().Add(10 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+				select {
+				case err := <-runErr:
+					t.Fatalf("run() returned while waiting for %s: %v", what, err)
+				default:
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("timed out waiting for %s", what)
+				}
+			}
+		}
+
+		waitFor("events of the primary vCenter", func() bool { return delivered("") })
+
+		// the primary vCenter becomes unreachable
+		proxy.Close()
+
+		vm, err := find.NewFinder(vim).VirtualMachine(ctx, "DC0_H0_VM0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		task, err := vm.PowerOff(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = task.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		waitFor("events of the standby vCenter", func() bool { return delivered(poweredOffEventType) })
+
+		cancel()
+		if err := <-runErr; !errors.Is(err, context.Canceled) {
+			t.Errorf("run() error = %v, want %v", err, context.Canceled)
+		}
+
+		if got := a.Addresses[a.activeAddress]; got != standby.String() {
+			t.Errorf("run() active address = %q, want %q", got, standby.String())
+		}
+
+		events, err := kc.CoreV1().Events("ns").List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events.Items) != 1 {
+			t.Fatalf("run() recorded %d events, want 1", len(events.Items))
+		}
+		if ev := events.Items[0]; ev.Reason != failoverEventReason || ev.InvolvedObject.Name != "adapter" {
+			t.Errorf("run() recorded event %s on %q, want %s on %q", ev.Reason, ev.InvolvedObject.Name, failoverEventReason, "adapter")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"go.uber.org/zap"
+	"knative.dev/pkg/logging"
+)
+
+const (
+	// DefaultHealthPort is the default port of the adapter health endpoints
+	DefaultHealthPort = 8080
+	// LivenessPath is the HTTP path of the adapter liveness endpoint
+	LivenessPath = "/healthz"
+	// ReadinessPath is the HTTP path of the adapter readiness endpoint
+	ReadinessPath = "/readyz"
+	// DefaultStallTimeout is the default time without progress of the event
+	// loop after which the adapter is considered wedged and not live
+	DefaultStallTimeout = 5 * time.Minute
+
+	healthShutdownTimeout = 5 * time.Second
+)
+
+// healthServer serves the liveness and readiness endpoints of the adapter. The
+// adapter is ready once the vCenter session and event stream are active. It
+// is live unless the event loop is streaming but has made no progress within
+// the stall timeout. The checkpoint debug endpoint is served on the same port.
+type healthServer struct {
+	ready int32
+	// streaming is 1 while the event loop reads events from vCenter
+	streaming int32
+	// progressAt is the time in unix nanoseconds from which the stall timeout
+	// of the event loop is measured
+	progressAt int64
+	// stallTimeout disables the stall detection if zero
+	stallTimeout time.Duration
+	// clock is the clock of the stall detection, the wall clock if nil
+	clock clock.Clock
+	// checkpoint serves the checkpoint debug endpoint, if set
+	checkpoint http.Handler
+}
+
+func (h *healthServer) setReady(ready bool) {
+	var v int32
+	if ready {
+		v = 1
+	}
+	atomic.StoreInt32(&h.ready, v)
+}
+
+func (h *healthServer) isReady() bool {
+	return atomic.LoadInt32(&h.ready) == 1
+}
+
+func (h *healthServer) now() time.Time {
+	if h.clock == nil {
+		return time.Now()
+	}
+	return h.clock.Now()
+}
+
+// setStreaming enables the stall detection while the event loop reads events
+// from vCenter.
+func (h *healthServer) setStreaming(streaming bool) {
+	var v int32
+	if streaming {
+		h.progress(0)
+		v = 1
+	}
+	atomic.StoreInt32(&h.streaming, v)
+}
+
+// progress records progress of the event loop, which is expected to make
+// progress again within the stall timeout after the given delay, e.g. a
+// backoff.
+func (h *healthServer) progress(delay time.Duration) {
+	atomic.StoreInt64(&h.progressAt, h.now().Add(delay).UnixNano())
+}
+
+// isStalled returns true if the event loop is streaming but has made no
+// progress within the stall timeout.
+func (h *healthServer) isStalled() bool {
+	if h.stallTimeout <= 0 || atomic.LoadInt32(&h.streaming) == 0 {
+		return false
+	}
+	progressAt := time.Unix(0, atomic.LoadInt64(&h.progressAt))
+	return h.now().Sub(progressAt) > h.stallTimeout
+}
+
+// ServeHTTP implements http.Handler
+func (h *healthServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case LivenessPath:
+		if h.isStalled() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case ReadinessPath:
+		if !h.isReady() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case CheckpointPath:
+		if h.checkpoint == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		h.checkpoint.ServeHTTP(w, r)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// run serves the health endpoints on the given port until the context is
+// cancelled.
+func (h *healthServer) run(ctx context.Context, port int) error {
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: h,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), healthShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logging.FromContext(ctx).Warnw("could not shut down health server", zap.Error(err))
+		}
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve health endpoints: %w", err)
+	}
+	return nil
+}
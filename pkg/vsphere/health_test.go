@@ -0,0 +1,125 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+func Test_healthServer(t *testing.T) {
+	tests := []struct {
+		name     string
+		ready    bool
+		path     string
+		wantCode int
+	}{
+		{
+			name:     "liveness while not ready",
+			ready:    false,
+			path:     LivenessPath,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "readiness while not ready",
+			ready:    false,
+			path:     ReadinessPath,
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "readiness when ready",
+			ready:    true,
+			path:     ReadinessPath,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "checkpoint without handler",
+			ready:    true,
+			path:     CheckpointPath,
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "unknown path",
+			ready:    true,
+			path:     "/unknown",
+			wantCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &healthServer{}
+			h.setReady(tt.ready)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("ServeHTTP() status = %d, want %d", rec.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func Test_healthServerStall(t *testing.T) {
+	clk := clock.NewMock()
+	h := &healthServer{stallTimeout: time.Minute, clock: clk}
+
+	live := func() bool {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, LivenessPath, nil))
+		return rec.Code == http.StatusOK
+	}
+
+	// not streaming, e.g. while reconnecting
+	clk.Add(time.Hour)
+	if !live() {
+		t.Fatal("not live while not streaming")
+	}
+
+	h.setStreaming(true)
+	clk.Add(time.Minute)
+	if !live() {
+		t.Fatal("not live within the stall timeout")
+	}
+
+	clk.Add(time.Second)
+	if live() {
+		t.Fatal("live after the stall timeout without progress")
+	}
+
+	h.progress(0)
+	if !live() {
+		t.Fatal("not live after progress")
+	}
+
+	// a backoff delay extends the stall timeout
+	h.progress(10 * time.Minute)
+	clk.Add(10*time.Minute + 30*time.Second)
+	if !live() {
+		t.Fatal("not live within the stall timeout after the backoff delay")
+	}
+	clk.Add(time.Minute)
+	if live() {
+		t.Fatal("live after the stall timeout after the backoff delay")
+	}
+
+	h.setStreaming(false)
+	if !live() {
+		t.Fatal("not live after streaming stopped")
+	}
+
+	h.stallTimeout = 0
+	h.setStreaming(true)
+	clk.Add(time.Hour)
+	if !live() {
+		t.Fatal("not live with stall detection disabled")
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/logging"
+)
+
+const (
+	// HeartbeatConfigMapKey is the data key of the heartbeat of the adapter
+	// in the ConfigMap of the source
+	HeartbeatConfigMapKey = "heartbeat"
+	// HeartbeatInterval is the interval at which the adapter writes its
+	// heartbeat
+	HeartbeatInterval = 30 * time.Second
+)
+
+// Heartbeat is the health of the event stream reported periodically by the
+// adapter.
+type Heartbeat struct {
+	// Time is when the heartbeat was written
+	Time time.Time `json:"time"`
+	// EventsPerSecond is the rate of events delivered since the previous
+	// heartbeat
+	EventsPerSecond float64 `json:"eventsPerSecond"`
+	// LastError is the last error reading or delivering events, empty unless
+	// the adapter is failing
+	LastError string `json:"lastError,omitempty"`
+	// FailingSince is the time of the first of the consecutive failures, nil
+	// unless the adapter is failing
+	FailingSince *time.Time `json:"failingSince,omitempty"`
+}
+
+// ParseHeartbeat returns the given JSON-encoded heartbeat, as stored by the
+// adapter.
+func ParseHeartbeat(data string) (Heartbeat, error) {
+	var hb Heartbeat
+	err := json.Unmarshal([]byte(data), &hb)
+	return hb, err
+}
+
+// heartbeatState collects the health of the event stream between heartbeats.
+type heartbeatState struct {
+	mu           sync.Mutex
+	lastError    string
+	failingSince *time.Time
+	delivered    int
+	// since is the time of the previous heartbeat
+	since time.Time
+}
+
+// failed records a failure to read or deliver events. Consecutive failures
+// keep the time of the first one.
+func (h *heartbeatState) failed(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastError = err.Error()
+	if h.failingSince == nil {
+		now := time.Now().UTC()
+		h.failingSince = &now
+	}
+}
+
+// succeeded records that events were read and delivered, which ends a series
+// of failures.
+func (h *heartbeatState) succeeded() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastError = ""
+	h.failingSince = nil
+}
+
+// addDelivered records the given number of delivered events.
+func (h *heartbeatState) addDelivered(n int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.delivered += n
+}
+
+// next returns the heartbeat at the given time and starts the measurement of
+// the event rate for the next one.
+func (h *heartbeatState) next(now time.Time) Heartbeat {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hb := Heartbeat{
+		Time:         now.UTC(),
+		LastError:    h.lastError,
+		FailingSince: h.failingSince,
+	}
+	if elapsed := now.Sub(h.since); !h.since.IsZero() && elapsed > 0 {
+		hb.EventsPerSecond = float64(h.delivered) / elapsed.Seconds()
+	}
+	h.delivered = 0
+	h.since = now
+	return hb
+}
+
+// runHeartbeat writes the heartbeat of the adapter every HeartbeatInterval
+// until ctx is done. Failures to write the heartbeat are logged only, the
+// controller reports the adapter as unhealthy once the heartbeat is stale.
+func (a *vAdapter) runHeartbeat(ctx context.Context) {
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.writeHeartbeat(ctx); err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).Warnw("could not write heartbeat", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// writeHeartbeat patches the heartbeat key of the ConfigMap of the source with
+// the current heartbeat.
+func (a *vAdapter) writeHeartbeat(ctx context.Context) error {
+	hb, err := json.Marshal(a.heartbeat.next(time.Now()))
+	if err != nil {
+		return fmt.Errorf("marshal heartbeat: %w", err)
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"data": map[string]string{HeartbeatConfigMapKey: string(hb)},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal patch: %w", err)
+	}
+	_, err = a.KubeClient.CoreV1().ConfigMaps(a.Namespace).Patch(ctx, a.HeartbeatConfigMap,
+		types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_heartbeatState(t *testing.T) {
+	var h heartbeatState
+	start := time.Now()
+
+	if hb := h.next(start); hb.LastError != "" || hb.FailingSince != nil || hb.EventsPerSecond != 0 {
+		t.Errorf("next() = %+v, want healthy without rate", hb)
+	}
+
+	h.addDelivered(30)
+	h.failed(errors.New("first"))
+	h.failed(errors.New("second"))
+	hb := h.next(start.Add(10 * time.Second))
+	if hb.EventsPerSecond != 3 {
+		t.Errorf("next() eventsPerSecond = %v, want 3", hb.EventsPerSecond)
+	}
+	if hb.LastError != "second" || hb.FailingSince == nil {
+		t.Fatalf("next() = %+v, want failing with the last error", hb)
+	}
+	failingSince := *hb.FailingSince
+
+	// consecutive failures keep the time of the first one
+	h.failed(errors.New("third"))
+	hb = h.next(start.Add(20 * time.Second))
+	if hb.FailingSince == nil || !hb.FailingSince.Equal(failingSince) {
+		t.Errorf("next() failingSince = %v, want %v", hb.FailingSince, failingSince)
+	}
+	if hb.EventsPerSecond != 0 {
+		t.Errorf("next() eventsPerSecond = %v, want 0", hb.EventsPerSecond)
+	}
+
+	h.succeeded()
+	if hb := h.next(start.Add(30 * time.Second)); hb.LastError != "" || hb.FailingSince != nil {
+		t.Errorf("next() = %+v, want healthy after success", hb)
+	}
+}
+
+func Test_vAdapter_writeHeartbeat(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "source-configmap"},
+		Data:       map[string]string{checkpointKey: `{"lastEventKey":42}`},
+	}
+	kc := fake.NewSimpleClientset(cm)
+	a := &vAdapter{
+		Logger:             zaptest.NewLogger(t).Sugar(),
+		Namespace:          "ns",
+		KubeClient:         kc,
+		HeartbeatConfigMap: "source-configmap",
+	}
+
+	ctx := context.Background()
+	cause := errors.New("NoPermission: Event.Read")
+	if err := a.fail(ctx, cause); err != cause {
+		t.Fatalf("fail() = %v, want %v", err, cause)
+	}
+
+	got, err := kc.CoreV1().ConfigMaps("ns").Get(ctx, "source-configmap", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Data[checkpointKey] != `{"lastEventKey":42}` {
+		t.Errorf("writeHeartbeat() checkpoint = %q, want unchanged", got.Data[checkpointKey])
+	}
+	hb, err := ParseHeartbeat(got.Data[HeartbeatConfigMapKey])
+	if err != nil {
+		t.Fatalf("ParseHeartbeat() error = %v", err)
+	}
+	if hb.LastError != cause.Error() || hb.FailingSince == nil || time.Since(hb.Time) > time.Minute {
+		t.Errorf("writeHeartbeat() heartbeat = %+v, want recent with error %q", hb, cause)
+	}
+}
@@ -0,0 +1,362 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	coordv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	coordinationv1 "k8s.io/client-go/kubernetes/typed/coordination/v1"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"knative.dev/pkg/kvstore"
+	"knative.dev/pkg/logging"
+)
+
+// leaseAnnotationPrefix prefixes the keys of the Lease store, which are stored
+// as annotations of the Lease
+const leaseAnnotationPrefix = "vspheresources.sources.tanzu.vmware.com/"
+
+// kvData holds the data of a store and the keys set since the last save.
+type kvData struct {
+	data map[string]string
+	// keys set since the last successful save
+	dirty map[string]struct{}
+}
+
+// Get unmarshals the value of the given key.
+func (d *kvData) Get(_ context.Context, key string, value interface{}) error {
+	v, ok := d.data[key]
+	if !ok {
+		return fmt.Errorf("key %s does not exist", key)
+	}
+	if err := json.Unmarshal([]byte(v), value); err != nil {
+		return fmt.Errorf("unmarshal %q: %w", v, err)
+	}
+	return nil
+}
+
+// Set marshals the given value and sets it under the given key. The key is
+// written to the store on the next Save.
+func (d *kvData) Set(_ context.Context, key string, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("marshal value: %w", err)
+	}
+	if d.data == nil {
+		d.data = make(map[string]string)
+	}
+	if d.dirty == nil {
+		d.dirty = make(map[string]struct{})
+	}
+	d.data[key] = string(b)
+	d.dirty[key] = struct{}{}
+	return nil
+}
+
+// changed returns the keys and values set since the last save.
+func (d *kvData) changed() map[string]string {
+	changed := make(map[string]string, len(d.dirty))
+	for k := range d.dirty {
+		changed[k] = d.data[k]
+	}
+	return changed
+}
+
+// isPatchRejected returns true if the store should fall back to an update
+// because the patch was rejected, e.g. because the role of the adapter does not
+// allow it.
+func isPatchRejected(err error) bool {
+	return apierrors.IsForbidden(err) || apierrors.IsMethodNotSupported(err)
+}
+
+// configMapKVStore is a kvstore.Interface backed by a ConfigMap. Unlike the
+// store of knative.dev/pkg/kvstore, which replaces the data of the ConfigMap on
+// every save, it only writes the keys set since the last save with a JSON merge
+// patch, so that keys written by others, e.g. the reconciler, are preserved.
+type configMapKVStore struct {
+	kvData
+	cmClient  v1.ConfigMapInterface
+	name      string
+	namespace string
+}
+
+var _ kvstore.Interface = (*configMapKVStore)(nil)
+
+// newConfigMapKVStore returns a store persisting its data in the ConfigMap with
+// the given name and namespace.
+func newConfigMapKVStore(name, namespace string, clientset v1.CoreV1Interface) *configMapKVStore {
+	return &configMapKVStore{
+		cmClient:  clientset.ConfigMaps(namespace),
+		name:      name,
+		namespace: namespace,
+	}
+}
+
+// Init loads the ConfigMap or creates an empty one if it does not exist.
+func (cs *configMapKVStore) Init(ctx context.Context) error {
+	err := cs.Load(ctx)
+	if apierrors.IsNotFound(err) {
+		logging.FromContext(ctx).Info("no kv store configmap found, creating empty")
+		return cs.createConfigMap(ctx)
+	}
+	return err
+}
+
+// Load fetches the data of the ConfigMap.
+func (cs *configMapKVStore) Load(ctx context.Context) error {
+	cm, err := cs.cmClient.Get(ctx, cs.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	cs.data = cm.Data
+	return nil
+}
+
+// Save writes the keys set since the last save to the ConfigMap with a JSON
+// merge patch. If the patch is rejected, e.g. because the role of the adapter
+// does not allow it, the keys are written with an update of the latest version
+// of the ConfigMap instead, which fails with a conflict on a concurrent
+// modification.
+func (cs *configMapKVStore) Save(ctx context.Context) error {
+	if len(cs.dirty) == 0 {
+		return nil
+	}
+
+	changed := cs.changed()
+	err := cs.patch(ctx, changed)
+	if isPatchRejected(err) {
+		logging.FromContext(ctx).Debugw("could not patch kv store configmap, updating instead", zap.Error(err))
+		err = cs.update(ctx, changed)
+	}
+	if err != nil {
+		return err
+	}
+
+	cs.dirty = nil
+	return nil
+}
+
+func (cs *configMapKVStore) patch(ctx context.Context, changed map[string]string) error {
+	patch, err := json.Marshal(map[string]interface{}{"data": changed})
+	if err != nil {
+		return fmt.Errorf("marshal patch: %w", err)
+	}
+	_, err = cs.cmClient.Patch(ctx, cs.name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+func (cs *configMapKVStore) update(ctx context.Context, changed map[string]string) error {
+	cm, err := cs.cmClient.Get(ctx, cs.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string, len(changed))
+	}
+	for k, v := range changed {
+		cm.Data[k] = v
+	}
+	// the resource version of the fetched ConfigMap guards against concurrent
+	// modifications
+	_, err = cs.cmClient.Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
+
+func (cs *configMapKVStore) createConfigMap(ctx context.Context) error {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cs.name,
+			Namespace: cs.namespace,
+		},
+	}
+	_, err := cs.cmClient.Create(ctx, cm, metav1.CreateOptions{})
+	return err
+}
+
+// leaseKVStore is a kvstore.Interface backed by the annotations of a Lease.
+// Leases are lightweight objects meant to be written frequently, so writing
+// the checkpoint to a Lease puts less load on the API server than writing it
+// to a ConfigMap. Like configMapKVStore, only the keys set since the last save
+// are written.
+type leaseKVStore struct {
+	kvData
+	leaseClient coordinationv1.LeaseInterface
+	name        string
+	namespace   string
+}
+
+var _ kvstore.Interface = (*leaseKVStore)(nil)
+
+// newLeaseKVStore returns a store persisting its data in the Lease with the
+// given name and namespace.
+func newLeaseKVStore(name, namespace string, clientset coordinationv1.CoordinationV1Interface) *leaseKVStore {
+	return &leaseKVStore{
+		leaseClient: clientset.Leases(namespace),
+		name:        name,
+		namespace:   namespace,
+	}
+}
+
+// Init loads the Lease or creates an empty one if it does not exist.
+func (ls *leaseKVStore) Init(ctx context.Context) error {
+	err := ls.Load(ctx)
+	if apierrors.IsNotFound(err) {
+		logging.FromContext(ctx).Info("no kv store lease found, creating empty")
+		return ls.createLease(ctx)
+	}
+	return err
+}
+
+// Load fetches the data of the Lease from its prefixed annotations.
+func (ls *leaseKVStore) Load(ctx context.Context) error {
+	lease, err := ls.leaseClient.Get(ctx, ls.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	ls.data = make(map[string]string)
+	for k, v := range lease.Annotations {
+		if key := strings.TrimPrefix(k, leaseAnnotationPrefix); key != k {
+			ls.data[key] = v
+		}
+	}
+	return nil
+}
+
+// Save writes the keys set since the last save to the annotations of the Lease
+// with a JSON merge patch, falling back to an update like
+// configMapKVStore.Save.
+func (ls *leaseKVStore) Save(ctx context.Context) error {
+	if len(ls.dirty) == 0 {
+		return nil
+	}
+
+	annotations := make(map[string]string, len(ls.dirty))
+	for k, v := range ls.changed() {
+		annotations[leaseAnnotationPrefix+k] = v
+	}
+
+	err := ls.patch(ctx, annotations)
+	if isPatchRejected(err) {
+		logging.FromContext(ctx).Debugw("could not patch kv store lease, updating instead", zap.Error(err))
+		err = ls.update(ctx, annotations)
+	}
+	if err != nil {
+		return err
+	}
+
+	ls.dirty = nil
+	return nil
+}
+
+func (ls *leaseKVStore) patch(ctx context.Context, annotations map[string]string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal patch: %w", err)
+	}
+	_, err = ls.leaseClient.Patch(ctx, ls.name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+func (ls *leaseKVStore) update(ctx context.Context, annotations map[string]string) error {
+	lease, err := ls.leaseClient.Get(ctx, ls.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if lease.Annotations == nil {
+		lease.Annotations = make(map[string]string, len(annotations))
+	}
+	for k, v := range annotations {
+		lease.Annotations[k] = v
+	}
+	_, err = ls.leaseClient.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
+
+func (ls *leaseKVStore) createLease(ctx context.Context) error {
+	lease := &coordv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ls.name,
+			Namespace: ls.namespace,
+		},
+	}
+	_, err := ls.leaseClient.Create(ctx, lease, metav1.CreateOptions{})
+	return err
+}
+
+// newCheckpointStore returns the initialized store of the configured checkpoint
+// backend. When the store holds no checkpoint yet, e.g. after switching the
+// backend, the checkpoint of the store of the other backend is migrated once.
+func newCheckpointStore(ctx context.Context, backend string, env *envConfig, kc kubernetes.Interface) (kvstore.Interface, error) {
+	var store, previous kvstore.Interface
+	cm := newConfigMapKVStore(env.KVConfigMap, env.Namespace, kc.CoreV1())
+
+	switch backend {
+	case CheckpointBackendLease:
+		if env.KVLease == "" {
+			return nil, errors.New("lease checkpoint backend requires a lease name")
+		}
+		store, previous = newLeaseKVStore(env.KVLease, env.Namespace, kc.CoordinationV1()), cm
+	default:
+		store = cm
+		if env.KVLease != "" {
+			previous = newLeaseKVStore(env.KVLease, env.Namespace, kc.CoordinationV1())
+		}
+	}
+
+	if err := store.Init(ctx); err != nil {
+		return nil, err
+	}
+
+	if previous != nil {
+		if err := migrateCheckpoint(ctx, store, previous); err != nil {
+			logging.FromContext(ctx).Warnw("could not migrate checkpoint from previous backend", zap.Error(err))
+		}
+	}
+	return store, nil
+}
+
+// migrateCheckpoint copies the checkpoint of the previous store to the given
+// store if the latter does not hold a checkpoint. A missing previous store is
+// not an error.
+func migrateCheckpoint(ctx context.Context, store, previous kvstore.Interface) error {
+	var cp json.RawMessage
+	if err := store.Get(ctx, checkpointKey, &cp); err == nil {
+		return nil
+	}
+
+	if err := previous.Load(ctx); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("load previous store: %w", err)
+	}
+	if err := previous.Get(ctx, checkpointKey, &cp); err != nil {
+		// nothing to migrate
+		return nil
+	}
+
+	if err := store.Set(ctx, checkpointKey, cp); err != nil {
+		return err
+	}
+	if err := saveCheckpoint(ctx, store); err != nil {
+		return fmt.Errorf("save migrated checkpoint: %w", err)
+	}
+	logging.FromContext(ctx).Infow("migrated checkpoint from previous backend", zap.ByteString("checkpoint", cp))
+	return nil
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	coordv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_configMapKVStore_concurrentWriters(t *testing.T) {
+	ctx := context.Background()
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "checkpoints", Namespace: "ns"},
+	}
+	kc := fake.NewSimpleClientset(cm)
+
+	// both stores load the same version of the ConfigMap and write different
+	// keys, neither save may drop the key of the other
+	first := newConfigMapKVStore(cm.Name, cm.Namespace, kc.CoreV1())
+	second := newConfigMapKVStore(cm.Name, cm.Namespace, kc.CoreV1())
+	for _, store := range []*configMapKVStore{first, second} {
+		if err := store.Init(ctx); err != nil {
+			t.Fatalf("Init() error = %v", err)
+		}
+	}
+
+	if err := first.Set(ctx, checkpointKey, checkpoint{LastEventKey: 42}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := second.Set(ctx, "other", "value"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := saveCheckpoint(ctx, first); err != nil {
+		t.Fatalf("saveCheckpoint() error = %v", err)
+	}
+	if err := saveCheckpoint(ctx, second); err != nil {
+		t.Fatalf("saveCheckpoint() error = %v", err)
+	}
+
+	// only changed keys are written
+	if err := first.Set(ctx, checkpointKey, checkpoint{LastEventKey: 43}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := saveCheckpoint(ctx, first); err != nil {
+		t.Fatalf("saveCheckpoint() error = %v", err)
+	}
+
+	got := newConfigMapKVStore(cm.Name, cm.Namespace, kc.CoreV1())
+	if err := got.Load(ctx); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var cp checkpoint
+	if err := got.Get(ctx, checkpointKey, &cp); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if cp.LastEventKey != 43 {
+		t.Errorf("Get() lastEventKey = %d, want 43", cp.LastEventKey)
+	}
+	var other string
+	if err := got.Get(ctx, "other", &other); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if other != "value" {
+		t.Errorf("Get() other = %q, want %q", other, "value")
+	}
+}
+
+func Test_leaseKVStore(t *testing.T) {
+	ctx := context.Background()
+	lease := &coordv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "checkpoints",
+			Namespace: "ns",
+			// written by others
+			Annotations: map[string]string{"other": "value"},
+		},
+	}
+	kc := fake.NewSimpleClientset(lease)
+
+	store := newLeaseKVStore(lease.Name, lease.Namespace, kc.CoordinationV1())
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err := store.Set(ctx, checkpointKey, checkpoint{LastEventKey: 42}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := saveCheckpoint(ctx, store); err != nil {
+		t.Fatalf("saveCheckpoint() error = %v", err)
+	}
+
+	got, err := kc.CoordinationV1().Leases(lease.Namespace).Get(ctx, lease.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get lease: %v", err)
+	}
+	want := map[string]string{
+		"other":                               "value",
+		leaseAnnotationPrefix + checkpointKey: `{"vCenter":"","lastEventKey":42,"lastEventType":"","lastEventKeyTimestamp":"0001-01-01T00:00:00Z","createdTimestamp":"0001-01-01T00:00:00Z"}`,
+	}
+	if diff := cmp.Diff(want, got.Annotations); diff != "" {
+		t.Errorf("Save() unexpected annotations (-want, +got) = %v", diff)
+	}
+
+	// only the prefixed annotations are loaded
+	loaded := newLeaseKVStore(lease.Name, lease.Namespace, kc.CoordinationV1())
+	if err := loaded.Load(ctx); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	var cp checkpoint
+	if err := loaded.Get(ctx, checkpointKey, &cp); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if cp.LastEventKey != 42 {
+		t.Errorf("Get() lastEventKey = %d, want 42", cp.LastEventKey)
+	}
+	var other string
+	if err := loaded.Get(ctx, "other", &other); err == nil {
+		t.Errorf("Get() of an annotation without prefix succeeded, got %q", other)
+	}
+}
+
+func Test_newCheckpointStore(t *testing.T) {
+	const (
+		cmName    = "source-configmap"
+		leaseName = "source-checkpoint"
+		ns        = "ns"
+	)
+
+	withCheckpoint := func(key int32) string {
+		return fmt.Sprintf(`{"lastEventKey":%d}`, key)
+	}
+	newConfigMap := func(data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: cmName, Namespace: ns},
+			Data:       data,
+		}
+	}
+	newLease := func(annotations map[string]string) *coordv1.Lease {
+		return &coordv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: leaseName, Namespace: ns, Annotations: annotations},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		backend  string
+		kvLease  string
+		existing []runtime.Object
+		wantKey  int32
+		wantErr  bool
+	}{
+		{
+			name:     "configmap backend without previous lease",
+			backend:  CheckpointBackendConfigMap,
+			existing: []runtime.Object{newConfigMap(map[string]string{checkpointKey: withCheckpoint(42)})},
+			wantKey:  42,
+		},
+		{
+			name:     "lease backend migrates configmap checkpoint",
+			backend:  CheckpointBackendLease,
+			kvLease:  leaseName,
+			existing: []runtime.Object{newConfigMap(map[string]string{checkpointKey: withCheckpoint(42)}), newLease(nil)},
+			wantKey:  42,
+		},
+		{
+			name:    "lease backend keeps its checkpoint",
+			backend: CheckpointBackendLease,
+			kvLease: leaseName,
+			existing: []runtime.Object{
+				newConfigMap(map[string]string{checkpointKey: withCheckpoint(42)}),
+				newLease(map[string]string{leaseAnnotationPrefix + checkpointKey: withCheckpoint(43)}),
+			},
+			wantKey: 43,
+		},
+		{
+			name:     "lease backend creates missing lease",
+			backend:  CheckpointBackendLease,
+			kvLease:  leaseName,
+			existing: []runtime.Object{newConfigMap(map[string]string{checkpointKey: withCheckpoint(42)})},
+			wantKey:  42,
+		},
+		{
+			name:    "configmap backend migrates lease checkpoint",
+			backend: CheckpointBackendConfigMap,
+			kvLease: leaseName,
+			existing: []runtime.Object{
+				newConfigMap(nil),
+				newLease(map[string]string{leaseAnnotationPrefix + checkpointKey: withCheckpoint(43)}),
+			},
+			wantKey: 43,
+		},
+		{
+			name:     "configmap backend ignores missing lease",
+			backend:  CheckpointBackendConfigMap,
+			kvLease:  leaseName,
+			existing: []runtime.Object{newConfigMap(nil)},
+		},
+		{
+			name:     "lease backend without lease name",
+			backend:  CheckpointBackendLease,
+			existing: []runtime.Object{newConfigMap(nil)},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			kc := fake.NewSimpleClientset(tt.existing...)
+
+			env := &envConfig{KVConfigMap: cmName, KVLease: tt.kvLease}
+			env.Namespace = ns
+
+			store, err := newCheckpointStore(ctx, tt.backend, env, kc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newCheckpointStore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			// the checkpoint must be persisted, not only cached by the store
+			if err := store.Load(ctx); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			var cp checkpoint
+			if err := store.Get(ctx, checkpointKey, &cp); err != nil && tt.wantKey != 0 {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if cp.LastEventKey != tt.wantKey {
+				t.Errorf("newCheckpointStore() lastEventKey = %d, want %d", cp.LastEventKey, tt.wantKey)
+			}
+		})
+	}
+}
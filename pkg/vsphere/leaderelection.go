@@ -0,0 +1,116 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"knative.dev/pkg/logging"
+)
+
+const (
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// errLeadershipLost is returned when the adapter stops leading, so that it
+// restarts as a standby.
+var errLeadershipLost = errors.New("leader election lost")
+
+// runWithLeaderElection waits to acquire the configured lease and calls run
+// while leading. Standbys are reported ready to take over. The checkpoint is
+// reloaded when leadership is acquired to resume from the position of the
+// previous leader.
+func (a *vAdapter) runWithLeaderElection(ctx context.Context, run func(context.Context) error) error {
+	logger := logging.FromContext(ctx)
+
+	id, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("get leader election identity: %w", err)
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      a.LeaderElectionLease,
+			Namespace: a.Namespace,
+		},
+		Client:     a.KubeClient.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: id},
+	}
+
+	leCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// the context of the leader is cancelled when leadership is lost
+	leading := make(chan context.Context, 1)
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: leaseDuration,
+		RenewDeadline: renewDeadline,
+		RetryPeriod:   retryPeriod,
+		// run has stopped when the context is cancelled, the next leader
+		// does not have to wait for the lease to expire
+		ReleaseOnCancel: true,
+		Name:            a.LeaderElectionLease,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				logger.Infow("started leading", zap.String("identity", id))
+				leading <- ctx
+			},
+			OnStoppedLeading: func() {
+				logger.Infow("stopped leading", zap.String("identity", id))
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("create leader elector: %w", err)
+	}
+
+	logger.Infow("waiting for leadership", zap.String("lease", a.LeaderElectionLease), zap.String("identity", id))
+	a.health.setReady(true)
+	defer a.health.setReady(false)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		le.Run(leCtx)
+	}()
+
+	var leaderCtx context.Context
+	select {
+	case <-stopped:
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return errLeadershipLost
+	case leaderCtx = <-leading:
+	}
+
+	// release the lease once run returns
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	if err := a.KVStore.Load(leaderCtx); err != nil {
+		return fmt.Errorf("reload checkpoint: %w", err)
+	}
+
+	err = run(leaderCtx)
+	if ctx.Err() == nil && leaderCtx.Err() != nil {
+		return errLeadershipLost
+	}
+	return err
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/client"
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"go.uber.org/zap/zaptest"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/ptr"
+)
+
+func TestRunWithLeaderElection(t *testing.T) {
+	const (
+		ns    = "ns"
+		lease = "source-adapter"
+	)
+
+	errRun := errors.New("run failed")
+
+	heldByOther := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: lease, Namespace: ns},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       ptr.String("other"),
+			LeaseDurationSeconds: ptr.Int32(int32(leaseDuration.Seconds())),
+			AcquireTime:          &metav1.MicroTime{Time: time.Now()},
+			RenewTime:            &metav1.MicroTime{Time: time.Now()},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		existing   *coordinationv1.Lease
+		wantRun    bool
+		wantErr    error
+		wantLoaded int
+	}{
+		{
+			name:       "leader runs with reloaded checkpoint",
+			wantRun:    true,
+			wantErr:    errRun,
+			wantLoaded: 1,
+		},
+		{
+			name:     "standby waits for the lease",
+			existing: heldByOther,
+			wantRun:  false,
+			wantErr:  context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kc := fake.NewSimpleClientset()
+			if tt.existing != nil {
+				kc = fake.NewSimpleClientset(tt.existing)
+			}
+
+			store := &fakeKVStore{}
+			a := &vAdapter{
+				Namespace:           ns,
+				KVStore:             store,
+				KubeClient:          kc,
+				LeaderElectionLease: lease,
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+
+			var ran bool
+			err := a.runWithLeaderElection(ctx, func(ctx context.Context) error {
+				ran = true
+				if !a.health.isReady() {
+					t.Error("runWithLeaderElection() adapter not ready while leading")
+				}
+				return errRun
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("runWithLeaderElection() error = %v, want %v", err, tt.wantErr)
+			}
+			if ran != tt.wantRun {
+				t.Errorf("runWithLeaderElection() ran = %v, want %v", ran, tt.wantRun)
+			}
+			if store.loaded != tt.wantLoaded {
+				t.Errorf("runWithLeaderElection() checkpoint loaded %d times, want %d", store.loaded, tt.wantLoaded)
+			}
+			if a.health.isReady() {
+				t.Error("runWithLeaderElection() adapter still ready after returning")
+			}
+		})
+	}
+}
+
+func TestNewAdapterLeaderElection(t *testing.T) {
+	const (
+		ns    = "ns"
+		lease = "source-adapter"
+	)
+
+	heldByOther := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: lease, Namespace: ns},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       ptr.String("other"),
+			LeaseDurationSeconds: ptr.Int32(int32(leaseDuration.Seconds())),
+			AcquireTime:          &metav1.MicroTime{Time: time.Now()},
+			RenewTime:            &metav1.MicroTime{Time: time.Now()},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		existing *coordinationv1.Lease
+		wantSent bool
+	}{
+		{
+			name:     "leader sends events",
+			wantSent: true,
+		},
+		{
+			name:     "standby does not poll vCenter",
+			existing: heldByOther,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			simulator.Run(func(ctx context.Context, vim *vim25.Client) error {
+				ctx = cecontext.WithTarget(ctx, "fake.example.com")
+				ctx = logging.WithLogger(ctx, zaptest.NewLogger(t).Sugar())
+				objs := []runtime.Object{&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "source-configmap", Namespace: ns},
+					// replay the events of the inventory
+					Data: map[string]string{checkpointKey: createCheckpoint(t, time.Now().UTC().Add(-time.Hour))},
+				}}
+				if tt.existing != nil {
+					objs = append(objs, tt.existing)
+				}
+				ctx, kc := fakekubeclient.With(ctx, objs...)
+
+				roundTripper := &roundTripperTest{statusCodes: createStatusCodes(100, failNever)}
+				p, err := cehttp.New(cehttp.WithRoundTripper(roundTripper))
+				if err != nil {
+					t.Fatal(err)
+				}
+				c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				a := newTestAdapterFromEnv(ctx, t, vim, c, map[string]string{
+					"NAMESPACE":                     ns,
+					"VSPHERE_CE_SOURCE":             source,
+					"VSPHERE_CHECKPOINT_CONFIG":     `{"maxAge":"1h","period":"10ms"}`,
+					"VSPHERE_POLL_INTERVAL":         "10ms",
+					"VSPHERE_LEADER_ELECTION_LEASE": lease,
+				})
+				if a.LeaderElectionLease != lease {
+					t.Errorf("NewAdapter() LeaderElectionLease = %q, want %q", a.LeaderElectionLease, lease)
+				}
+
+				runCtx, cancel := context.WithTimeout(ctx, time.Second)
+				defer cancel()
+				_ = a.Start(runCtx)
+
+				if sent := roundTripper.requestCount > 0; sent != tt.wantSent {
+					t.Errorf("Start() sent events = %v, want %v", sent, tt.wantSent)
+				}
+				if _, err := kc.CoordinationV1().Leases(ns).Get(ctx, lease, metav1.GetOptions{}); err != nil {
+					t.Errorf("Start() did not elect a leader with the lease: %v", err)
+				}
+				return nil
+			})
+		})
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	// OIDCTokenVolumeName is the name of the projected volume holding the
+	// OIDC token presented to the sink
+	OIDCTokenVolumeName = "oidc-token"
+	// OIDCTokenMountPath is the path the OIDC token volume is mounted at
+	OIDCTokenMountPath = "/var/run/secrets/tokens/oidc"
+	// OIDCTokenKey is the file name of the OIDC token in the volume
+	OIDCTokenKey = "token"
+)
+
+// oidcTransport adds the OIDC token of the adapter as bearer token to
+// requests to the sink. Requests to other destinations, e.g. the dead letter
+// sink, are sent without the token.
+type oidcTransport struct {
+	base      http.RoundTripper
+	sink      *url.URL
+	tokenPath string
+}
+
+// newOIDCTransport returns a transport presenting the token read from
+// tokenPath to the sink. The default transport is used if base is nil.
+func newOIDCTransport(base http.RoundTripper, sink, tokenPath string) (*oidcTransport, error) {
+	u, err := url.Parse(sink)
+	if err != nil {
+		return nil, fmt.Errorf("parse sink URI: %w", err)
+	}
+
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &oidcTransport{
+		base:      base,
+		sink:      u,
+		tokenPath: tokenPath,
+	}, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *oidcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.isSink(req.URL) {
+		return t.base.RoundTrip(req)
+	}
+
+	// The token is read for every request since the kubelet refreshes the
+	// projected token before it expires.
+	token, err := os.ReadFile(t.tokenPath)
+	if err != nil {
+		return nil, fmt.Errorf("read OIDC token: %w", err)
+	}
+
+	// RoundTrippers must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	return t.base.RoundTrip(req)
+}
+
+func (t *oidcTransport) isSink(u *url.URL) bool {
+	return strings.EqualFold(u.Scheme, t.sink.Scheme) && strings.EqualFold(u.Host, t.sink.Host) &&
+		strings.TrimSuffix(u.Path, "/") == strings.TrimSuffix(t.sink.Path, "/")
+}
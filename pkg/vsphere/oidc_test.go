@@ -0,0 +1,82 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+type authSink struct {
+	sync.Mutex
+	auth []string
+}
+
+func (s *authSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	s.auth = append(s.auth, r.Header.Get("Authorization"))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func Test_oidcTransport(t *testing.T) {
+	sink := &authSink{}
+	srv := httptest.NewServer(sink)
+	defer srv.Close()
+
+	tokenPath := filepath.Join(t.TempDir(), OIDCTokenKey)
+	writeToken := func(token string) {
+		t.Helper()
+		if err := os.WriteFile(tokenPath, []byte(token+"\n"), 0o600); err != nil {
+			t.Fatalf("write token: %v", err)
+		}
+	}
+
+	transport, err := newOIDCTransport(nil, srv.URL+"/default/default", tokenPath)
+	if err != nil {
+		t.Fatalf("newOIDCTransport() error = %v", err)
+	}
+	client := &http.Client{Transport: transport}
+
+	post := func(path string) {
+		t.Helper()
+		resp, err := client.Post(srv.URL+path, "text/plain", nil)
+		if err != nil {
+			t.Fatalf("post %s: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+
+	writeToken("first")
+	post("/default/default")
+	// the token of the sink is not presented to other destinations
+	post("/dead-letter")
+	// refreshed tokens are picked up
+	writeToken("second")
+	post("/default/default")
+
+	want := []string{"Bearer first", "", "Bearer second"}
+	if len(sink.auth) != len(want) {
+		t.Fatalf("sink received %d requests, want %d", len(sink.auth), len(want))
+	}
+	for i := range want {
+		if sink.auth[i] != want[i] {
+			t.Errorf("request %d Authorization = %q, want %q", i, sink.auth[i], want[i])
+		}
+	}
+
+	// deliveries fail without a token
+	if err = os.Remove(tokenPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.Post(srv.URL+"/default/default", "text/plain", nil); err == nil {
+		t.Error("post without token error = nil, want an error")
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"encoding/xml"
+	"reflect"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+const (
+	// PayloadFormatFull emits the complete vSphere event object as CloudEvent
+	// data
+	PayloadFormatFull = "full"
+	// PayloadFormatMinimal emits the common fields of all vSphere events as
+	// CloudEvent data, with the entities reduced to their names
+	PayloadFormatMinimal = "minimal"
+)
+
+// minimalEvent is the CloudEvent data of a vSphere event with the minimal
+// payload format. The field names match those of the complete event in both
+// encodings.
+type minimalEvent struct {
+	XMLName xml.Name `json:"-"`
+
+	Key                  int32     `json:"Key" xml:"key"`
+	ChainID              int32     `json:"ChainId" xml:"chainId"`
+	CreatedTime          time.Time `json:"CreatedTime" xml:"createdTime"`
+	UserName             string    `json:"UserName" xml:"userName"`
+	FullFormattedMessage string    `json:"FullFormattedMessage,omitempty" xml:"fullFormattedMessage,omitempty"`
+
+	// the names of the entities the event refers to
+	Datacenter      string `json:"Datacenter,omitempty" xml:"datacenter,omitempty"`
+	ComputeResource string `json:"ComputeResource,omitempty" xml:"computeResource,omitempty"`
+	Host            string `json:"Host,omitempty" xml:"host,omitempty"`
+	VM              string `json:"Vm,omitempty" xml:"vm,omitempty"`
+	Ds              string `json:"Ds,omitempty" xml:"ds,omitempty"`
+	Net             string `json:"Net,omitempty" xml:"net,omitempty"`
+	Dvs             string `json:"Dvs,omitempty" xml:"dvs,omitempty"`
+}
+
+// newMinimalEvent returns the minimal payload of the given event. Like the
+// complete event, it is encoded as an XML element named after the event class,
+// e.g. VmPoweredOffEvent.
+func newMinimalEvent(be types.BaseEvent) *minimalEvent {
+	e := be.GetEvent()
+	me := &minimalEvent{
+		XMLName:              xml.Name{Local: reflect.TypeOf(be).Elem().Name()},
+		Key:                  e.Key,
+		ChainID:              e.ChainId,
+		CreatedTime:          e.CreatedTime,
+		UserName:             e.UserName,
+		FullFormattedMessage: e.FullFormattedMessage,
+	}
+	if e.Datacenter != nil {
+		me.Datacenter = e.Datacenter.Name
+	}
+	if e.ComputeResource != nil {
+		me.ComputeResource = e.ComputeResource.Name
+	}
+	if e.Host != nil {
+		me.Host = e.Host.Name
+	}
+	if e.Vm != nil {
+		me.VM = e.Vm.Name
+	}
+	if e.Ds != nil {
+		me.Ds = e.Ds.Name
+	}
+	if e.Net != nil {
+		me.Net = e.Net.Name
+	}
+	if e.Dvs != nil {
+		me.Dvs = e.Dvs.Name
+	}
+	return me
+}
+
+// eventData returns the CloudEvent data of the given event in the configured
+// payload format.
+func (a *vAdapter) eventData(be types.BaseEvent) interface{} {
+	if a.PayloadFormat == PayloadFormatMinimal {
+		return newMinimalEvent(be)
+	}
+	return be
+}
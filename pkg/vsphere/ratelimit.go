@@ -0,0 +1,103 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/vmware/govmomi/vim25/types"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+	"knative.dev/pkg/logging"
+)
+
+const (
+	// OverflowPolicyBlock delays the events exceeding the rate limit
+	OverflowPolicyBlock = "block"
+	// OverflowPolicyDrop drops the events exceeding the rate limit
+	OverflowPolicyDrop = "drop"
+)
+
+// rateLimiter limits the rate of events sent to the sink with a token bucket.
+// Waiting for a token blocks the caller, i.e. the event read loop, so that
+// events are not buffered in memory while throttled. With the drop overflow
+// policy, events exceeding the rate limit are dropped instead.
+type rateLimiter struct {
+	clock   clock.Clock
+	limiter *rate.Limiter
+	drop    bool
+}
+
+// newRateLimiter returns a rate limiter allowing eventsPerSecond events with
+// bursts of up to burst events. Nil is returned when rate limiting is
+// disabled.
+func newRateLimiter(eventsPerSecond, burst int, clk clock.Clock) *rateLimiter {
+	if eventsPerSecond <= 0 {
+		return nil
+	}
+
+	if burst <= 0 {
+		burst = eventsPerSecond
+	}
+
+	return &rateLimiter{
+		clock:   clk,
+		limiter: rate.NewLimiter(rate.Limit(eventsPerSecond), burst),
+	}
+}
+
+// wait blocks until the next event may be sent and returns the time spent
+// throttled. An error is returned if ctx is cancelled while waiting.
+func (r *rateLimiter) wait(ctx context.Context) (time.Duration, error) {
+	now := r.clock.Now()
+	res := r.limiter.ReserveN(now, 1)
+
+	delay := res.DelayFrom(now)
+	if delay == 0 {
+		return 0, nil
+	}
+
+	select {
+	case <-ctx.Done():
+		res.CancelAt(r.clock.Now())
+		return r.clock.Since(now), ctx.Err()
+	case <-r.clock.After(delay):
+		return delay, nil
+	}
+}
+
+// allow returns true if the next event may be sent right away.
+func (r *rateLimiter) allow() bool {
+	return r.limiter.AllowN(r.clock.Now(), 1)
+}
+
+// throttle waits for the rate limiter, if configured, and reports the time
+// spent throttled. With the drop overflow policy, throttle does not wait but
+// returns false if the given event exceeds the rate limit and is dropped.
+func (a *vAdapter) throttle(ctx context.Context, be types.BaseEvent) (bool, error) {
+	if a.RateLimiter == nil {
+		return true, nil
+	}
+
+	if a.RateLimiter.drop {
+		if a.RateLimiter.allow() {
+			return true, nil
+		}
+		a.StatsReporter.ReportEventDropped(a.eventType(be))
+		logging.FromContext(ctx).Debugw("dropping event exceeding the rate limit", zap.Int32("eventKey", be.GetEvent().Key))
+		return false, nil
+	}
+
+	throttled, err := a.RateLimiter.wait(ctx)
+	if throttled > 0 {
+		a.StatsReporter.ReportThrottled(throttled)
+		// waiting for the rate limiter is progress of the event loop
+		a.health.progress(0)
+	}
+	return err == nil, err
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/client"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_newRateLimiter(t *testing.T) {
+	if r := newRateLimiter(0, 10, clock.NewMock()); r != nil {
+		t.Errorf("newRateLimiter() = %v, want nil when disabled", r)
+	}
+
+	r := newRateLimiter(5, 0, clock.NewMock())
+	if got := r.limiter.Burst(); got != 5 {
+		t.Errorf("newRateLimiter() burst = %d, want %d", got, 5)
+	}
+}
+
+func Test_rateLimiterWait(t *testing.T) {
+	mock := clock.NewMock()
+	r := newRateLimiter(1, 2, mock)
+	ctx := context.Background()
+
+	// the burst is not throttled
+	for i := 0; i < 2; i++ {
+		if d, err := r.wait(ctx); d != 0 || err != nil {
+			t.Fatalf("wait() = %v, %v, want 0, nil", d, err)
+		}
+	}
+
+	// a cancelled wait returns its token
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := r.wait(cancelled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("wait() error = %v, want %v", err, context.Canceled)
+	}
+
+	var (
+		throttled time.Duration
+		err       error
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		throttled, err = r.wait(ctx)
+	}()
+
+	advanceUntil(t, mock, done)
+	if err != nil {
+		t.Fatalf("wait() error = %v", err)
+	}
+	if throttled <= 0 || throttled > time.Second {
+		t.Errorf("wait() throttled = %v, want between 0s and 1s", throttled)
+	}
+	if elapsed := mock.Now().Sub(time.Unix(0, 0)); elapsed < throttled {
+		t.Errorf("wait() returned after %v, want at least %v", elapsed, throttled)
+	}
+}
+
+func TestSendEventsRateLimit(t *testing.T) {
+	now := time.Now().UTC()
+	events := createTestEvents(3, source, now)
+
+	sink := &flakySink{}
+	srv := httptest.NewServer(sink)
+	defer srv.Close()
+
+	p, err := cehttp.New(cehttp.WithTarget(srv.URL), cehttp.WithClient(http.Client{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock := clock.NewMock()
+	reporter := &fakeStatsReporter{}
+	adapter := vAdapter{
+		Logger:          zaptest.NewLogger(t).Sugar(),
+		CEClient:        c,
+		Source:          source,
+		PayloadEncoding: cloudevents.ApplicationXML,
+		VAPIVersion:     "6.7.0",
+		Sink:            srv.URL,
+		StatsReporter:   reporter,
+		RateLimiter:     newRateLimiter(1, 2, mock),
+	}
+
+	var count int
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		count, err = adapter.sendEvents(context.Background(), events.vEvents)
+	}()
+
+	// the burst is sent right away, the last event waits for the clock
+	deadline := time.Now().Add(5 * time.Second)
+	for sinkRequests(sink) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the burst to be sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if got := sinkRequests(sink); got != 2 {
+		t.Fatalf("sink received %d requests before the clock advanced, want 2", got)
+	}
+
+	advanceUntil(t, mock, done)
+	if err != nil || count != 3 {
+		t.Fatalf("sendEvents() = %d, %v, want 3, nil", count, err)
+	}
+	if got := sinkRequests(sink); got != 3 {
+		t.Errorf("sink received %d requests, want 3", got)
+	}
+	if reporter.throttled <= 0 || reporter.throttled > time.Second {
+		t.Errorf("sendEvents() throttled = %v, want between 0s and 1s", reporter.throttled)
+	}
+}
+
+func TestSendEventsRateLimitDrop(t *testing.T) {
+	events := createTestEvents(4, source, time.Now().UTC())
+
+	for _, batchSize := range []int{1, 2} {
+		sink := &flakySink{}
+		srv := httptest.NewServer(sink)
+
+		p, err := cehttp.New(cehttp.WithTarget(srv.URL), cehttp.WithClient(http.Client{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mock := clock.NewMock()
+		limiter := newRateLimiter(1, 2, mock)
+		limiter.drop = true
+		reporter := &fakeStatsReporter{}
+		adapter := vAdapter{
+			Logger:          zaptest.NewLogger(t).Sugar(),
+			CEClient:        c,
+			HTTPClient:      &http.Client{},
+			Source:          source,
+			PayloadEncoding: cloudevents.ApplicationXML,
+			VAPIVersion:     "6.7.0",
+			Sink:            srv.URL,
+			StatsReporter:   reporter,
+			RateLimiter:     limiter,
+			BatchSize:       batchSize,
+		}
+
+		// the burst is sent, the events exceeding it are dropped without
+		// waiting for the clock and counted as processed
+		count, err := adapter.sendEvents(context.Background(), events.vEvents)
+		if err != nil || count != 4 {
+			t.Fatalf("batch size %d: sendEvents() = %d, %v, want 4, nil", batchSize, count, err)
+		}
+
+		var delivered, dropped int
+		for _, n := range reporter.delivered {
+			delivered += n
+		}
+		for _, n := range reporter.dropped {
+			dropped += n
+		}
+		if delivered != 2 || dropped != 2 {
+			t.Errorf("batch size %d: sendEvents() delivered %d and dropped %d events, want 2 and 2", batchSize, delivered, dropped)
+		}
+		if reporter.throttled != 0 {
+			t.Errorf("batch size %d: sendEvents() throttled = %v, want 0", batchSize, reporter.throttled)
+		}
+
+		srv.Close()
+	}
+}
+
+// advanceUntil advances the mock clock in small steps until done is closed.
+func advanceUntil(t *testing.T, mock *clock.Mock, done <-chan struct{}) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the rate limiter")
+		}
+		mock.Add(100 * time.Millisecond)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func sinkRequests(s *flakySink) int {
+	s.Lock()
+	defer s.Unlock()
+	return s.requests
+}
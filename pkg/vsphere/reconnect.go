@@ -0,0 +1,88 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultReconnectBackoff is the time to wait before reconnecting to
+	// vCenter after the first connection failure. The backoff doubles with
+	// every consecutive failure.
+	DefaultReconnectBackoff = time.Second
+	// maxReconnectBackoff bounds the time to wait before reconnecting
+	maxReconnectBackoff = 5 * time.Minute
+)
+
+// isConnectionError returns true if the given error is caused by a failure to
+// reach vCenter, e.g. a refused connection or a timeout.
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// reconnect is called when the vCenter the adapter is connected to cannot be
+// reached. With failover addresses, it fails over to the next reachable
+// vCenter after FailoverThreshold consecutive failures. Otherwise it waits for
+// the reconnect backoff before the event stream is resumed with the same
+// vCenter.
+func (a *vAdapter) reconnect(ctx context.Context, cause error) error {
+	a.StatsReporter.ReportReconnect()
+
+	threshold := a.FailoverThreshold
+	if threshold <= 0 {
+		threshold = DefaultFailoverThreshold
+	}
+
+	a.connectionFailures++
+	a.Logger.Warnw("could not reach vCenter", zap.String("address", a.VClient.URL().Host),
+		zap.Int("failures", a.connectionFailures), zap.Error(cause))
+
+	if len(a.Addresses) > 1 && a.connectionFailures >= threshold {
+		err := a.failover(ctx)
+		if err == nil {
+			return nil
+		}
+		a.Logger.Warnw("could not fail over to another vCenter", zap.Error(err))
+	}
+
+	backoff := a.reconnectBackoff()
+	a.Logger.Infow("reconnecting to vCenter", zap.String("backoff", backoff.String()))
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(backoff):
+		return nil
+	}
+}
+
+// reconnectBackoff returns the time to wait before reconnecting after the
+// current number of consecutive connection failures, i.e. ReconnectBackoff
+// doubled for every failure after the first, up to maxReconnectBackoff.
+func (a *vAdapter) reconnectBackoff() time.Duration {
+	backoff := a.ReconnectBackoff
+	if backoff <= 0 {
+		backoff = DefaultReconnectBackoff
+	}
+
+	for i := 1; i < a.connectionFailures; i++ {
+		if backoff >= maxReconnectBackoff/2 {
+			return maxReconnectBackoff
+		}
+		backoff *= 2
+	}
+	if backoff > maxReconnectBackoff {
+		return maxReconnectBackoff
+	}
+	return backoff
+}
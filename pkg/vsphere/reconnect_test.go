@@ -0,0 +1,211 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/client"
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_isConnectionError(t *testing.T) {
+	refused := &url.Error{Op: "Post", URL: "https://vcenter/sdk", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "regular error", err: errors.New("invalid argument"), want: false},
+		{name: "connection refused", err: refused, want: true},
+		{name: "wrapped connection refused", err: fmt.Errorf("read events from vcenter: %w", refused), want: true},
+		{name: "not authenticated", err: soap.WrapVimFault(&types.NotAuthenticated{}), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isConnectionError(tt.err); got != tt.want {
+				t.Errorf("isConnectionError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_vAdapter_reconnectBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		backoff  time.Duration
+		failures int
+		want     time.Duration
+	}{
+		{name: "default backoff", failures: 1, want: DefaultReconnectBackoff},
+		{name: "configured backoff", backoff: 3 * time.Second, failures: 1, want: 3 * time.Second},
+		{name: "doubled per failure", backoff: 3 * time.Second, failures: 3, want: 12 * time.Second},
+		{name: "capped", backoff: 3 * time.Second, failures: 100, want: maxReconnectBackoff},
+		{name: "configured above cap", backoff: time.Hour, failures: 1, want: maxReconnectBackoff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &vAdapter{ReconnectBackoff: tt.backoff, connectionFailures: tt.failures}
+			if got := a.reconnectBackoff(); got != tt.want {
+				t.Errorf("reconnectBackoff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_vAdapter_runReconnect(t *testing.T) {
+	const poweredOffEventType = "com.vmware.vsphere.VmPoweredOffEvent.v0"
+
+	simulator.Run(func(ctx context.Context, vim *vim25.Client) error {
+		ctx = cecontext.WithTarget(ctx, "fake.example.com")
+
+		p, err := cehttp.New(cehttp.WithRoundTripper(&roundTripperTest{statusCodes: createStatusCodes(100, failNever)}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the only vCenter is a proxy of the simulator which drops the
+		// connections while it is down
+		var down atomic.Value
+		down.Store(false)
+		u := vim.URL()
+		rp := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: u.Scheme, Host: u.Host})
+		rp.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if down.Load().(bool) {
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err == nil {
+					_ = conn.Close()
+				}
+				return
+			}
+			rp.ServeHTTP(w, r)
+		}))
+		defer proxy.Close()
+
+		pu, err := soap.ParseURL(proxy.URL + u.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pu.User = simulator.DefaultLogin
+		vc, err := govmomi.NewClient(ctx, pu, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stats := &fakeStatsReporter{}
+		a := &vAdapter{
+			Logger:   zaptest.NewLogger(t).Sugar(),
+			Source:   source,
+			VClient:  vc,
+			CEClient: c,
+			KVStore: &fakeKVStore{
+				// replay the events of the inventory
+				data:     map[string]string{checkpointKey: createCheckpoint(t, time.Now().UTC().Add(-time.Hour))},
+				dataChan: make(chan string, 1),
+			},
+			CpConfig: CheckpointConfig{
+				MaxAge: time.Hour,
+				// only the final checkpoint is saved
+				Period: time.Hour,
+			},
+			PollInterval:     10 * time.Millisecond,
+			ReconnectBackoff: 10 * time.Millisecond,
+			StatsReporter:    stats,
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		runErr := make(chan error, 1)
+		go func() {
+			runErr <- a.run(ctx)
+		}()
+
+		delivered := func(eventType string) bool {
+			stats.Lock()
+			defer stats.Unlock()
+			if eventType == "" {
+				return len(stats.delivered) > 0
+			}
+			return stats.delivered[eventType] > 0
+		}
+		reconnects := func() int {
+			stats.Lock()
+			defer stats.Unlock()
+			return stats.reconnects
+		}
+		sessionActive := func() bool {
+			stats.Lock()
+			defer stats.Unlock()
+			return stats.sessionActive
+		}
+		waitFor := func(what string, cond func() bool) {
+			t.Helper()
+			for deadline := time.Now().Add(10 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+				select {
+				case err := <-runErr:
+					t.Fatalf("run() returned while waiting for %s: %v", what, err)
+				default:
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("timed out waiting for %s", what)
+				}
+			}
+		}
+
+		waitFor("events before the outage", func() bool { return delivered("") && sessionActive() })
+
+		down.Store(true)
+		waitFor("reconnects during the outage", func() bool { return reconnects() >= 2 && !sessionActive() })
+
+		vm, err := find.NewFinder(vim).VirtualMachine(ctx, "DC0_H0_VM0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		task, err := vm.PowerOff(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = task.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		down.Store(false)
+		waitFor("events after the outage", func() bool { return delivered(poweredOffEventType) && sessionActive() })
+
+		cancel()
+		if err := <-runErr; !errors.Is(err, context.Canceled) {
+			t.Errorf("run() error = %v, want %v", err, context.Canceled)
+		}
+		return nil
+	})
+}
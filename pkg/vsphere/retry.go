@@ -0,0 +1,140 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"go.uber.org/zap"
+	"knative.dev/pkg/logging"
+)
+
+const (
+	// DefaultRetryBackoff is the default delay before the first retry of a
+	// failed delivery
+	DefaultRetryBackoff = time.Second
+
+	// DefaultSinkTimeout is the default maximum time of a single delivery
+	// attempt to a sink
+	DefaultSinkTimeout = 30 * time.Second
+
+	// retryJitter is the maximum fraction of the backoff delay randomly added
+	// to each retry so that adapters do not retry a recovering sink in
+	// lockstep
+	retryJitter = 0.2
+)
+
+// newRetryParams returns the retry parameters of event deliveries for the
+// given maximum number of retries, initial backoff and backoff policy. Nil is
+// returned when retries are disabled.
+func newRetryParams(maxRetries int, backoff time.Duration, policy string) (*cecontext.RetryParams, error) {
+	if maxRetries <= 0 {
+		return nil, nil
+	}
+
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+
+	var strategy cecontext.BackoffStrategy
+	switch policy {
+	case "", cecontext.BackoffStrategyExponential:
+		strategy = cecontext.BackoffStrategyExponential
+	case cecontext.BackoffStrategyLinear:
+		strategy = cecontext.BackoffStrategyLinear
+	default:
+		return nil, fmt.Errorf("unsupported backoff policy %q", policy)
+	}
+
+	return &cecontext.RetryParams{
+		Strategy: strategy,
+		MaxTries: maxRetries,
+		Period:   backoff,
+	}, nil
+}
+
+// sendWithRetries calls send to deliver the given events and retries
+// transient failures according to the configured retry parameters. The
+// result of the last attempt is returned.
+func (a *vAdapter) sendWithRetries(ctx context.Context, events []cloudevents.Event,
+	send func(context.Context, []cloudevents.Event) error) error {
+	result := a.sendAttempt(ctx, events, send)
+	if a.RetryParams == nil {
+		return result
+	}
+
+	for retries := 0; !cloudevents.IsACK(result) && isRetryable(result) && retries < a.RetryParams.MaxTries; retries++ {
+		delay := retryDelay(a.RetryParams, retries+1)
+		logging.FromContext(ctx).Debugw("retrying cloudevent delivery", zap.Int("retry", retries+1),
+			zap.Duration("delay", delay), zap.Error(result))
+
+		a.health.progress(delay)
+		select {
+		case <-ctx.Done():
+			return result
+		case <-time.After(delay):
+		}
+
+		for _, ev := range events {
+			a.StatsReporter.ReportEventRetried(ev.Type())
+		}
+		result = a.sendAttempt(ctx, events, send)
+	}
+	return result
+}
+
+// sendAttempt sets the processed time of the given events to the current
+// time and calls send with a context canceled after the sink timeout, if
+// configured. A timed out delivery fails with the deadline error of the HTTP
+// client, which is retryable.
+func (a *vAdapter) sendAttempt(ctx context.Context, events []cloudevents.Event,
+	send func(context.Context, []cloudevents.Event) error) error {
+	now := time.Now().UTC()
+	for i := range events {
+		events[i].SetExtension(ceVSphereProcessedTime, now)
+	}
+
+	if a.SinkTimeout <= 0 {
+		return send(ctx, events)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.SinkTimeout)
+	defer cancel()
+	return send(ctx, events)
+}
+
+// retryDelay returns the backoff delay before the given retry with up to
+// retryJitter of random jitter added.
+func retryDelay(params *cecontext.RetryParams, retry int) time.Duration {
+	delay := params.BackoffFor(retry)
+	//nolint:gosec
+	return delay + time.Duration(rand.Float64()*retryJitter*float64(delay))
+}
+
+// isRetryable returns true if the given delivery result is a transient
+// failure, i.e. the sink could not be reached or responded with a 429 or 5xx
+// status code.
+func isRetryable(result error) bool {
+	var uErr *url.Error
+	if errors.As(result, &uErr) {
+		return true
+	}
+
+	var res *cehttp.Result
+	if errors.As(result, &res) {
+		return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
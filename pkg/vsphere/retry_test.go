@@ -0,0 +1,443 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/client"
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/zap/zaptest"
+)
+
+// flakySink fails the first failures requests with the given status code and
+// accepts all following requests.
+type flakySink struct {
+	sync.Mutex
+	failures   int
+	statusCode int
+	requests   int
+	headers    []http.Header
+}
+
+func (s *flakySink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.requests++
+	s.headers = append(s.headers, r.Header.Clone())
+	if s.requests <= s.failures {
+		w.WriteHeader(s.statusCode)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func Test_newRetryParams(t *testing.T) {
+	testCases := map[string]struct {
+		maxRetries int
+		backoff    time.Duration
+		policy     string
+		want       *cecontext.RetryParams
+		wantErr    bool
+	}{
+		"retries disabled": {
+			maxRetries: 0,
+			backoff:    time.Second,
+			policy:     "linear",
+			want:       nil,
+		},
+		"linear policy": {
+			maxRetries: 3,
+			backoff:    2 * time.Second,
+			policy:     "linear",
+			want: &cecontext.RetryParams{
+				Strategy: cecontext.BackoffStrategyLinear,
+				MaxTries: 3,
+				Period:   2 * time.Second,
+			},
+		},
+		"exponential policy": {
+			maxRetries: 5,
+			backoff:    time.Second,
+			policy:     "exponential",
+			want: &cecontext.RetryParams{
+				Strategy: cecontext.BackoffStrategyExponential,
+				MaxTries: 5,
+				Period:   time.Second,
+			},
+		},
+		"defaults to exponential policy and default backoff": {
+			maxRetries: 1,
+			want: &cecontext.RetryParams{
+				Strategy: cecontext.BackoffStrategyExponential,
+				MaxTries: 1,
+				Period:   DefaultRetryBackoff,
+			},
+		},
+		"invalid policy": {
+			maxRetries: 1,
+			backoff:    time.Second,
+			policy:     "constant",
+			wantErr:    true,
+		},
+	}
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			got, err := newRetryParams(tc.maxRetries, tc.backoff, tc.policy)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("newRetryParams() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("newRetryParams() (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func Test_retryDelay(t *testing.T) {
+	params := &cecontext.RetryParams{
+		Strategy: cecontext.BackoffStrategyExponential,
+		MaxTries: 3,
+		Period:   time.Second,
+	}
+
+	for retry := 1; retry <= params.MaxTries; retry++ {
+		lower := params.BackoffFor(retry)
+		upper := lower + time.Duration(retryJitter*float64(lower))
+
+		for i := 0; i < 100; i++ {
+			if got := retryDelay(params, retry); got < lower || got > upper {
+				t.Fatalf("retryDelay(%d) = %v, want between %v and %v", retry, got, lower, upper)
+			}
+		}
+	}
+}
+
+func Test_isRetryable(t *testing.T) {
+	testCases := map[string]struct {
+		result error
+		want   bool
+	}{
+		"connection error": {
+			result: fmt.Errorf("send cloudevent batch: %w", &url.Error{Op: "Post", URL: testSink, Err: errors.New("connection refused")}),
+			want:   true,
+		},
+		"too many requests": {
+			result: cehttp.NewResult(http.StatusTooManyRequests, "slow down"),
+			want:   true,
+		},
+		"service unavailable": {
+			result: cehttp.NewResult(http.StatusServiceUnavailable, "unavailable"),
+			want:   true,
+		},
+		"bad request": {
+			result: cehttp.NewResult(http.StatusBadRequest, "invalid event"),
+			want:   false,
+		},
+		"other error": {
+			result: errors.New("marshal cloudevent batch"),
+			want:   false,
+		},
+	}
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			if got := isRetryable(tc.result); got != tc.want {
+				t.Errorf("isRetryable() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSendEventsFlakySink(t *testing.T) {
+	now := time.Now().UTC()
+	events := createTestEvents(2, source, now)
+	eventType := events.ceEvents[0].Type()
+
+	testCases := map[string]struct {
+		batchSize     int
+		failures      int
+		statusCode    int
+		maxRetries    int
+		wantRequests  int
+		wantCount     int
+		wantErr       bool
+		wantRetried   map[string]int
+		wantDelivered map[string]int
+	}{
+		"single events, delivered after retries": {
+			failures:      2,
+			statusCode:    http.StatusServiceUnavailable,
+			maxRetries:    3,
+			wantRequests:  4,
+			wantCount:     2,
+			wantRetried:   map[string]int{eventType: 2},
+			wantDelivered: map[string]int{eventType: 2},
+		},
+		"single events, retries exhausted": {
+			failures:     5,
+			statusCode:   http.StatusInternalServerError,
+			maxRetries:   2,
+			wantRequests: 3,
+			wantCount:    0,
+			wantErr:      true,
+			wantRetried:  map[string]int{eventType: 2},
+		},
+		"single events, permanent failure is not retried": {
+			failures:     1,
+			statusCode:   http.StatusBadRequest,
+			maxRetries:   3,
+			wantRequests: 1,
+			wantCount:    0,
+			wantErr:      true,
+		},
+		"batch, delivered after retries": {
+			batchSize:     2,
+			failures:      1,
+			statusCode:    http.StatusTooManyRequests,
+			maxRetries:    1,
+			wantRequests:  2,
+			wantCount:     2,
+			wantRetried:   map[string]int{eventType: 2},
+			wantDelivered: map[string]int{eventType: 2},
+		},
+	}
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			sink := &flakySink{failures: tc.failures, statusCode: tc.statusCode}
+			srv := httptest.NewServer(sink)
+			defer srv.Close()
+
+			p, err := cehttp.New(cehttp.WithTarget(srv.URL), cehttp.WithClient(*srv.Client()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reporter := &fakeStatsReporter{}
+			adapter := vAdapter{
+				Logger:          zaptest.NewLogger(t).Sugar(),
+				CEClient:        c,
+				Source:          source,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				VAPIVersion:     "6.7.0",
+				Sink:            srv.URL,
+				BatchSize:       tc.batchSize,
+				HTTPClient:      srv.Client(),
+				StatsReporter:   reporter,
+				RetryParams: &cecontext.RetryParams{
+					Strategy: cecontext.BackoffStrategyLinear,
+					MaxTries: tc.maxRetries,
+					Period:   time.Millisecond,
+				},
+			}
+
+			count, err := adapter.sendEvents(context.Background(), events.vEvents)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("sendEvents() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if count != tc.wantCount {
+				t.Errorf("sendEvents() count = %d, want %d", count, tc.wantCount)
+			}
+			if sink.requests != tc.wantRequests {
+				t.Errorf("sink received %d requests, want %d", sink.requests, tc.wantRequests)
+			}
+			if diff := cmp.Diff(tc.wantRetried, reporter.retried); diff != "" {
+				t.Errorf("sendEvents() unexpected retried events (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tc.wantDelivered, reporter.delivered); diff != "" {
+				t.Errorf("sendEvents() unexpected delivered events (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestSendEventsProcessedTimeRetried(t *testing.T) {
+	events := createTestEvents(1, source, time.Now().UTC())
+	backoff := 200 * time.Millisecond
+
+	sink := &flakySink{failures: 1, statusCode: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(sink)
+	defer srv.Close()
+
+	p, err := cehttp.New(cehttp.WithTarget(srv.URL), cehttp.WithClient(*srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adapter := vAdapter{
+		Logger:          zaptest.NewLogger(t).Sugar(),
+		CEClient:        c,
+		Source:          source,
+		PayloadEncoding: cloudevents.ApplicationXML,
+		VAPIVersion:     "6.7.0",
+		Sink:            srv.URL,
+		StatsReporter:   &fakeStatsReporter{},
+		RetryParams: &cecontext.RetryParams{
+			Strategy: cecontext.BackoffStrategyLinear,
+			MaxTries: 1,
+			Period:   backoff,
+		},
+	}
+
+	if count, err := adapter.sendEvents(context.Background(), events.vEvents); err != nil || count != 1 {
+		t.Fatalf("sendEvents() = %d, %v, want 1, nil", count, err)
+	}
+	if len(sink.headers) != 2 {
+		t.Fatalf("sink received %d requests, want 2", len(sink.headers))
+	}
+
+	var processed []time.Time
+	for _, h := range sink.headers {
+		ts, err := time.Parse(time.RFC3339Nano, h.Get("Ce-"+ceVSphereProcessedTime))
+		if err != nil {
+			t.Fatalf("parse %s attribute: %v", ceVSphereProcessedTime, err)
+		}
+		processed = append(processed, ts)
+	}
+	// the retry is sent after the backoff and carries its own send time
+	if d := processed[1].Sub(processed[0]); d < backoff {
+		t.Errorf("%s of retry = %v, want at least %s after first attempt %v", ceVSphereProcessedTime,
+			processed[1], backoff, processed[0])
+	}
+}
+
+// slowSink does not respond to the first slow requests until they are
+// canceled and accepts all following requests.
+type slowSink struct {
+	sync.Mutex
+	slow     int
+	requests int
+}
+
+func (s *slowSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	s.requests++
+	slow := s.requests <= s.slow
+	s.Unlock()
+
+	if slow {
+		// the closed connection is only noticed once the body is read
+		_, _ = io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func TestSendEventsSinkTimeout(t *testing.T) {
+	now := time.Now().UTC()
+	events := createTestEvents(2, source, now)
+	eventType := events.ceEvents[0].Type()
+
+	testCases := map[string]struct {
+		batchSize     int
+		slow          int
+		maxRetries    int
+		wantRequests  int
+		wantCount     int
+		wantErr       bool
+		wantRetried   map[string]int
+		wantDelivered map[string]int
+	}{
+		"single events, delivered after timeout": {
+			slow:          1,
+			maxRetries:    1,
+			wantRequests:  3,
+			wantCount:     2,
+			wantRetried:   map[string]int{eventType: 1},
+			wantDelivered: map[string]int{eventType: 2},
+		},
+		"single events, timed out without retries": {
+			slow:         1,
+			wantRequests: 1,
+			wantCount:    0,
+			wantErr:      true,
+		},
+		"batch, delivered after timeout": {
+			batchSize:     2,
+			slow:          1,
+			maxRetries:    1,
+			wantRequests:  2,
+			wantCount:     2,
+			wantRetried:   map[string]int{eventType: 2},
+			wantDelivered: map[string]int{eventType: 2},
+		},
+	}
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			sink := &slowSink{slow: tc.slow}
+			srv := httptest.NewServer(sink)
+			defer srv.Close()
+
+			p, err := cehttp.New(cehttp.WithTarget(srv.URL), cehttp.WithClient(*srv.Client()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reporter := &fakeStatsReporter{}
+			adapter := vAdapter{
+				Logger:          zaptest.NewLogger(t).Sugar(),
+				CEClient:        c,
+				Source:          source,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				VAPIVersion:     "6.7.0",
+				Sink:            srv.URL,
+				BatchSize:       tc.batchSize,
+				HTTPClient:      srv.Client(),
+				StatsReporter:   reporter,
+				SinkTimeout:     50 * time.Millisecond,
+			}
+			if tc.maxRetries > 0 {
+				adapter.RetryParams = &cecontext.RetryParams{
+					Strategy: cecontext.BackoffStrategyLinear,
+					MaxTries: tc.maxRetries,
+					Period:   time.Millisecond,
+				}
+			}
+
+			count, err := adapter.sendEvents(context.Background(), events.vEvents)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("sendEvents() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if count != tc.wantCount {
+				t.Errorf("sendEvents() count = %d, want %d", count, tc.wantCount)
+			}
+			if sink.requests != tc.wantRequests {
+				t.Errorf("sink received %d requests, want %d", sink.requests, tc.wantRequests)
+			}
+			if diff := cmp.Diff(tc.wantRetried, reporter.retried); diff != "" {
+				t.Errorf("sendEvents() unexpected retried events (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tc.wantDelivered, reporter.delivered); diff != "" {
+				t.Errorf("sendEvents() unexpected delivered events (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// sessionLogoutTimeout bounds the logout of the session terminating the
+// sessions of an adapter
+const sessionLogoutTimeout = 5 * time.Second
+
+// isNotAuthenticated returns true if the given error is caused by a
+// NotAuthenticated fault, which vCenter returns when the session expired or
+// was terminated.
+func isNotAuthenticated(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		var fault interface{}
+		switch {
+		case soap.IsSoapFault(err):
+			fault = soap.ToSoapFault(err).VimFault()
+		case soap.IsVimFault(err):
+			fault = soap.ToVimFault(err)
+		}
+		// decoded faults are values, faults created by clients are pointers
+		switch fault.(type) {
+		case types.NotAuthenticated, *types.NotAuthenticated:
+			return true
+		}
+	}
+	return false
+}
+
+// relogin creates a new vCenter session with the credentials read from the
+// secret, which might have been rotated since the adapter started. The
+// keep-alive of the session is restarted by the login.
+func (a *vAdapter) relogin(ctx context.Context) error {
+	a.StatsReporter.ReportRelogin()
+
+	credentials := a.Credentials
+	if credentials == nil {
+		credentials = ReadCredentials
+	}
+	user, err := credentials()
+	if err != nil {
+		return fmt.Errorf("read vCenter credentials: %w", err)
+	}
+
+	if err = a.VClient.SessionManager.Login(ctx, user); err != nil {
+		return fmt.Errorf("login to vCenter: %w", err)
+	}
+	return nil
+}
+
+// SessionUserAgent returns the user agent of the vCenter sessions of the
+// adapter of the VSphereSource with the given namespace and name, which
+// identifies the sessions when the source is deleted.
+func SessionUserAgent(namespace, name string) string {
+	return fmt.Sprintf("vsphere-source/%s/%s", namespace, name)
+}
+
+// SessionCleanup configures the vCenter connection of TerminateSessions.
+type SessionCleanup struct {
+	Address  string
+	Username string
+	Password string
+	Insecure bool
+	// CACerts are PEM encoded CA bundles trusted instead of the system roots
+	// when not empty
+	CACerts [][]byte
+	// HTTPProxy, HTTPSProxy and NoProxy configure the proxy to vCenter
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+	// UserAgent is the user agent of the sessions to terminate
+	UserAgent string
+}
+
+// TerminateSessions logs into vCenter and terminates the sessions with the
+// configured user agent. Terminating a session also destroys the objects
+// vCenter keeps for it, e.g. the event history collector of an adapter. It
+// returns the number of terminated sessions.
+func TerminateSessions(ctx context.Context, c SessionCleanup) (int, error) {
+	u, err := soap.ParseURL(c.Address)
+	if err != nil {
+		return 0, fmt.Errorf("parse vCenter address: %w", err)
+	}
+	u.User = url.UserPassword(c.Username, c.Password)
+
+	soapClient, err := newSOAP(u, EnvConfig{
+		Insecure:   c.Insecure,
+		HTTPProxy:  c.HTTPProxy,
+		HTTPSProxy: c.HTTPSProxy,
+		NoProxy:    c.NoProxy,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(c.CACerts) > 0 {
+		pool := x509.NewCertPool()
+		for _, pem := range c.CACerts {
+			if !pool.AppendCertsFromPEM(pem) {
+				return 0, errors.New("invalid CA certificates")
+			}
+		}
+		soapClient.DefaultTransport().TLSClientConfig.RootCAs = pool
+	}
+
+	vimClient, err := vim25.NewClient(ctx, soapClient)
+	if err != nil {
+		return 0, fmt.Errorf("connect to vCenter: %w", err)
+	}
+	m := session.NewManager(vimClient)
+	if err = m.Login(ctx, u.User); err != nil {
+		return 0, fmt.Errorf("login to vCenter: %w", err)
+	}
+	defer func() {
+		// using fresh ctx to log out when ctx is done
+		logoutCtx, cancel := context.WithTimeout(context.Background(), sessionLogoutTimeout)
+		defer cancel()
+		_ = m.Logout(logoutCtx) // best effort, ignoring error
+	}()
+
+	own, err := m.UserSession(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("get vCenter session: %w", err)
+	}
+
+	var sm mo.SessionManager
+	pc := property.DefaultCollector(vimClient)
+	if err = pc.RetrieveOne(ctx, *vimClient.ServiceContent.SessionManager, []string{"sessionList"}, &sm); err != nil {
+		return 0, fmt.Errorf("list vCenter sessions: %w", err)
+	}
+
+	terminated := 0
+	for _, s := range sm.SessionList {
+		if s.UserAgent != c.UserAgent || (own != nil && s.Key == own.Key) {
+			continue
+		}
+		if err = m.TerminateSession(ctx, []string{s.Key}); err != nil {
+			// the session ended since it was listed
+			if isNotFound(err) {
+				continue
+			}
+			return terminated, fmt.Errorf("terminate vCenter session: %w", err)
+		}
+		terminated++
+	}
+	return terminated, nil
+}
+
+// isNotFound returns true if the given error is caused by a NotFound fault.
+func isNotFound(err error) bool {
+	if !soap.IsSoapFault(err) {
+		return false
+	}
+	switch soap.ToSoapFault(err).VimFault().(type) {
+	case types.NotFound, *types.NotFound:
+		return true
+	}
+	return false
+}
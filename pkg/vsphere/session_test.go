@@ -0,0 +1,231 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/client"
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_isNotAuthenticated(t *testing.T) {
+	notAuthenticated := soap.WrapVimFault(&types.NotAuthenticated{})
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "regular error", err: errors.New("connection refused"), want: false},
+		{name: "not authenticated", err: notAuthenticated, want: true},
+		{name: "wrapped not authenticated", err: fmt.Errorf("read events from vcenter: %w", notAuthenticated), want: true},
+		{name: "soap fault", err: soap.WrapSoapFault(&soap.Fault{Detail: struct {
+			Fault types.AnyType `xml:",any,typeattr"`
+		}{Fault: types.NotAuthenticated{}}}), want: true},
+		{name: "other fault", err: soap.WrapVimFault(&types.InvalidLogin{}), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNotAuthenticated(tt.err); got != tt.want {
+				t.Errorf("isNotAuthenticated() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_vAdapter_runRelogin(t *testing.T) {
+	const poweredOffEventType = "com.vmware.vsphere.VmPoweredOffEvent.v0"
+
+	simulator.Run(func(ctx context.Context, vim *vim25.Client) error {
+		ctx = cecontext.WithTarget(ctx, "fake.example.com")
+
+		p, err := cehttp.New(cehttp.WithRoundTripper(&roundTripperTest{statusCodes: createStatusCodes(100, failNever)}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stats := &fakeStatsReporter{}
+		a := &vAdapter{
+			Logger: zaptest.NewLogger(t).Sugar(),
+			Source: source,
+			VClient: &govmomi.Client{
+				Client:         vim,
+				SessionManager: session.NewManager(vim),
+			},
+			Credentials: func() (*url.Userinfo, error) {
+				return simulator.DefaultLogin, nil
+			},
+			CEClient: c,
+			KVStore: &fakeKVStore{
+				// replay the events of the inventory
+				data:     map[string]string{checkpointKey: createCheckpoint(t, time.Now().UTC().Add(-time.Hour))},
+				dataChan: make(chan string, 1),
+			},
+			CpConfig: CheckpointConfig{
+				MaxAge: time.Hour,
+				// only the final checkpoint is saved
+				Period: time.Hour,
+			},
+			PollInterval:  10 * time.Millisecond,
+			StatsReporter: stats,
+		}
+
+		userSession, err := a.VClient.SessionManager.UserSession(ctx)
+		if err != nil || userSession == nil {
+			t.Fatalf("get adapter session: %v", err)
+		}
+
+		// a second session terminates the session of the adapter
+		u := vim.URL()
+		u.User = simulator.DefaultLogin
+		admin, err := govmomi.NewClient(ctx, u, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		runErr := make(chan error, 1)
+		go func() {
+			runErr <- a.run(ctx)
+		}()
+
+		delivered := func(eventType string) bool {
+			stats.Lock()
+			defer stats.Unlock()
+			if eventType == "" {
+				return len(stats.delivered) > 0
+			}
+			return stats.delivered[eventType] > 0
+		}
+		waitFor := func(what string, cond func() bool) {
+			t.Helper()
+			for deadline := time.Now().Add(10 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+				select {
+				case err := <-runErr:
+					t.Fatalf("run() returned while waiting for %s: %v", what, err)
+				default:
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("timed out waiting for %s", what)
+				}
+			}
+		}
+
+		waitFor("events of the first session", func() bool { return delivered("") })
+
+		if err = admin.SessionManager.TerminateSession(ctx, []string{userSession.Key}); err != nil {
+			t.Fatalf("terminate adapter session: %v", err)
+		}
+
+		vm, err := find.NewFinder(admin.Client).VirtualMachine(ctx, "DC0_H0_VM0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		task, err := vm.PowerOff(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = task.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		waitFor("events of the new session", func() bool { return delivered(poweredOffEventType) })
+
+		stats.Lock()
+		relogins := stats.relogins
+		stats.Unlock()
+		if relogins != 1 {
+			t.Errorf("run() relogins = %d, want 1", relogins)
+		}
+
+		cancel()
+		if err := <-runErr; !errors.Is(err, context.Canceled) {
+			t.Errorf("run() error = %v, want %v", err, context.Canceled)
+		}
+		return nil
+	})
+}
+
+func TestTerminateSessions(t *testing.T) {
+	simulator.Run(func(ctx context.Context, vim *vim25.Client) error {
+		login := func(userAgent string) *session.Manager {
+			t.Helper()
+			u := vim.URL()
+			sc := soap.NewClient(u, true)
+			sc.UserAgent = userAgent
+			c, err := vim25.NewClient(ctx, sc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := session.NewManager(c)
+			if err = m.Login(ctx, simulator.DefaultLogin); err != nil {
+				t.Fatal(err)
+			}
+			return m
+		}
+
+		userAgent := SessionUserAgent("ns", "source")
+		adapter1, adapter2 := login(userAgent), login(userAgent)
+		other := login(SessionUserAgent("ns", "other"))
+
+		password, _ := simulator.DefaultLogin.Password()
+		cleanup := SessionCleanup{
+			Address:   vim.URL().String(),
+			Username:  simulator.DefaultLogin.Username(),
+			Password:  password,
+			Insecure:  true,
+			UserAgent: userAgent,
+		}
+
+		terminated, err := TerminateSessions(ctx, cleanup)
+		if err != nil {
+			t.Fatalf("TerminateSessions() error = %v", err)
+		}
+		if terminated != 2 {
+			t.Errorf("TerminateSessions() = %d, want 2", terminated)
+		}
+
+		for name, m := range map[string]*session.Manager{"adapter1": adapter1, "adapter2": adapter2, "other": other} {
+			active, err := m.SessionIsActive(ctx)
+			if want := name == "other"; active != want {
+				t.Errorf("session of %s active = %v (%v), want %v", name, active, err, want)
+			}
+		}
+
+		// nothing left to terminate
+		if terminated, err = TerminateSessions(ctx, cleanup); err != nil || terminated != 0 {
+			t.Errorf("TerminateSessions() = %d, %v, want 0, nil", terminated, err)
+		}
+
+		cleanup.Address = "https://127.0.0.1:1/sdk"
+		if _, err = TerminateSessions(ctx, cleanup); err == nil {
+			t.Error("TerminateSessions() of unreachable vCenter error = nil, want error")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultShutdownTimeout is the maximum time the adapter spends delivering
+// pending events and saving its final checkpoint when it is stopped. It must
+// be shorter than the termination grace period of the adapter pod.
+const DefaultShutdownTimeout = 20 * time.Second
+
+// ShutdownGracePeriodMargin is the part of the termination grace period of the
+// adapter pod which is not spent in the shutdown, e.g. to close the vCenter
+// session and flush the logs.
+const ShutdownGracePeriodMargin = 10 * time.Second
+
+// detachedContext keeps the values of its parent, e.g. the logger, but is
+// never cancelled.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+// shutdownContext returns a context with the values of ctx which is cancelled
+// timeout after ctx is done. Deliveries use it so that events in flight when
+// the adapter is stopped are not dropped.
+func shutdownContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	sctx, cancel := context.WithCancel(detachedContext{ctx})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-sctx.Done():
+			return
+		}
+
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		select {
+		case <-t.C:
+			cancel()
+		case <-sctx.Done():
+		}
+	}()
+
+	return sctx, cancel
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/client"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"go.uber.org/zap/zaptest"
+)
+
+type ctxKey struct{}
+
+func Test_shutdownContext(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "value"))
+	ctx, cancel := shutdownContext(parent, 50*time.Millisecond)
+	defer cancel()
+
+	if got := ctx.Value(ctxKey{}); got != "value" {
+		t.Errorf("shutdownContext() value = %v, want %v", got, "value")
+	}
+
+	cancelParent()
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("shutdownContext() cancelled with its parent: %v", err)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdownContext() not cancelled after the timeout")
+	}
+}
+
+func Test_vAdapter_runShutdown(t *testing.T) {
+	// number of vcsim events emitted for default VPX model
+	const vcsimEvents = 26
+
+	simulator.Run(func(ctx context.Context, vim *vim25.Client) error {
+		sink := &batchSink{statusCodes: []int{202}}
+		srv := httptest.NewServer(sink)
+		defer srv.Close()
+
+		p, err := cehttp.New(cehttp.WithTarget(srv.URL), cehttp.WithClient(*srv.Client()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		kv := &fakeKVStore{
+			data: map[string]string{
+				checkpointKey: createCheckpoint(t, time.Now().UTC().Add(-time.Hour)),
+			},
+			dataChan: make(chan string, 1),
+		}
+
+		// events are pending in a batch which never fills up
+		a := &vAdapter{
+			Logger:          zaptest.NewLogger(t).Sugar(),
+			Source:          source,
+			VClient:         &govmomi.Client{Client: vim, SessionManager: session.NewManager(vim)},
+			CEClient:        c,
+			KVStore:         kv,
+			CpConfig:        CheckpointConfig{MaxAge: time.Hour, Period: time.Hour},
+			PayloadEncoding: "application/json",
+			Sink:            srv.URL,
+			HTTPClient:      srv.Client(),
+			BatchSize:       1000,
+			BatchTimeout:    time.Hour,
+			ShutdownTimeout: 5 * time.Second,
+			StatsReporter:   &fakeStatsReporter{},
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() {
+			done <- a.run(ctx)
+		}()
+
+		time.Sleep(200 * time.Millisecond)
+		cancel()
+
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("run() did not return after the context was cancelled")
+		}
+
+		sink.Lock()
+		batches := sink.batches
+		sink.Unlock()
+		if len(batches) != 1 || len(batches[0]) != vcsimEvents {
+			t.Fatalf("run() delivered %d batches, want 1 batch of %d pending events", len(batches), vcsimEvents)
+		}
+
+		select {
+		case data := <-kv.dataChan:
+			var cp checkpoint
+			if err := json.Unmarshal([]byte(data), &cp); err != nil {
+				t.Fatalf("unmarshal checkpoint: %v", err)
+			}
+			if cp.LastEventKey != vcsimEvents {
+				t.Errorf("run() final checkpoint key = %d, want %d", cp.LastEventKey, vcsimEvents)
+			}
+		default:
+			t.Error("run() did not save a final checkpoint")
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"go.opencensus.io/plugin/ochttp"
+	"knative.dev/eventing/pkg/adapter/v2"
+	sourcemetrics "knative.dev/eventing/pkg/metrics/source"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/pkg/tracing/propagation/tracecontextb3"
+)
+
+// newSinkTransport returns an HTTP transport trusting the PEM encoded CA
+// bundle in addition to the system roots when delivering events to sinks
+// served over HTTPS.
+func newSinkTransport(caCerts string) (*http.Transport, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM([]byte(caCerts)) {
+		return nil, errors.New("no PEM encoded certificates found")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+	return transport, nil
+}
+
+// newSinkCEClient returns a CloudEvents client like the one created by the
+// adapter main, but delivering events with the given transport. The client of
+// the adapter main always uses the default transport.
+func newSinkCEClient(target string, ceOverrides *duckv1.CloudEventOverrides, transport http.RoundTripper) (cloudevents.Client, error) {
+	reporter, err := sourcemetrics.NewStatsReporter()
+	if err != nil {
+		return nil, err
+	}
+
+	return adapter.NewCloudEventsClientWithOptions(ceOverrides, reporter,
+		cehttp.WithTarget(target),
+		cehttp.WithRoundTripper(&ochttp.Transport{
+			Base:        transport,
+			Propagation: tracecontextb3.TraceContextEgress,
+		}),
+	)
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_newSinkTransport(t *testing.T) {
+	if _, err := newSinkTransport("not a certificate"); err == nil {
+		t.Error("newSinkTransport() error = nil, want an error for invalid PEM")
+	}
+}
+
+func TestSendEventsSinkCACerts(t *testing.T) {
+	now := time.Now().UTC()
+	events := createTestEvents(1, source, now)
+
+	testCases := map[string]struct {
+		caCerts   func(srv *httptest.Server) string
+		wantCount int
+		wantErr   bool
+	}{
+		"trusted CA": {
+			caCerts:   serverCACerts,
+			wantCount: 1,
+			wantErr:   false,
+		},
+		"untrusted CA": {
+			caCerts: func(*httptest.Server) string {
+				return selfSignedCACerts(t)
+			},
+			wantCount: 0,
+			wantErr:   true,
+		},
+	}
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			sink := &flakySink{}
+			srv := httptest.NewTLSServer(sink)
+			defer srv.Close()
+
+			transport, err := newSinkTransport(tc.caCerts(srv))
+			if err != nil {
+				t.Fatalf("newSinkTransport() error = %v", err)
+			}
+			c, err := newSinkCEClient(srv.URL, nil, transport)
+			if err != nil {
+				t.Fatalf("newSinkCEClient() error = %v", err)
+			}
+
+			adapter := vAdapter{
+				Logger:          zaptest.NewLogger(t).Sugar(),
+				CEClient:        c,
+				Source:          source,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				VAPIVersion:     "6.7.0",
+				Sink:            srv.URL,
+				StatsReporter:   &fakeStatsReporter{},
+			}
+
+			count, err := adapter.sendEvents(context.Background(), events.vEvents)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("sendEvents() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if count != tc.wantCount {
+				t.Errorf("sendEvents() count = %d, want %d", count, tc.wantCount)
+			}
+			if got := sinkRequests(sink); got != tc.wantCount {
+				t.Errorf("sink received %d requests, want %d", got, tc.wantCount)
+			}
+		})
+	}
+}
+
+// serverCACerts returns the self-signed certificate of a TLS test server as
+// PEM encoded CA bundle.
+func serverCACerts(srv *httptest.Server) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+}
+
+// selfSignedCACerts returns a PEM encoded self-signed CA certificate which did
+// not issue the certificate of any test server.
+func selfSignedCACerts(t *testing.T) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "untrusted-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
@@ -0,0 +1,345 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/resource"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	eventingmetrics "knative.dev/eventing/pkg/metrics"
+	"knative.dev/pkg/metrics"
+	"knative.dev/pkg/metrics/metricskey"
+)
+
+// resourceGroup is the resource group of the source in the resource labels of
+// the adapter metrics.
+const resourceGroup = "vspheresources.sources.tanzu.vmware.com"
+
+var (
+	// eventsReceivedM is a counter which records the number of events
+	// retrieved from vCenter.
+	eventsReceivedM = stats.Int64(
+		"events_received_total",
+		"Number of events received from vCenter",
+		stats.UnitDimensionless,
+	)
+
+	// eventsDeliveredM is a counter which records the number of events
+	// accepted by the sink.
+	eventsDeliveredM = stats.Int64(
+		"events_delivered_total",
+		"Number of events delivered to the sink",
+		stats.UnitDimensionless,
+	)
+
+	// eventsFailedM is a counter which records the number of events
+	// rejected by the sink.
+	eventsFailedM = stats.Int64(
+		"events_failed_total",
+		"Number of events which failed to be delivered to the sink",
+		stats.UnitDimensionless,
+	)
+
+	// eventsDeduplicatedM is a counter which records the number of replayed
+	// events skipped as they were delivered before the adapter restarted.
+	eventsDeduplicatedM = stats.Int64(
+		"events_deduplicated_total",
+		"Number of replayed events skipped as already delivered",
+		stats.UnitDimensionless,
+	)
+
+	// eventsRetriedM is a counter which records the number of retried
+	// event deliveries.
+	eventsRetriedM = stats.Int64(
+		"events_retried_total",
+		"Number of event delivery retries to the sink",
+		stats.UnitDimensionless,
+	)
+
+	// eventsDroppedM is a counter which records the number of events dropped
+	// by the rate limit.
+	eventsDroppedM = stats.Int64(
+		"events_dropped_total",
+		"Number of events dropped by the event rate limit",
+		stats.UnitDimensionless,
+	)
+
+	// throttledSecondsM is a counter which records the time the adapter was
+	// throttled by the rate limit.
+	throttledSecondsM = stats.Float64(
+		"throttled_seconds_total",
+		"Time spent waiting for the event rate limit",
+		stats.UnitSeconds,
+	)
+
+	// reloginsM is a counter which records the number of logins to vCenter
+	// after the session expired.
+	reloginsM = stats.Int64(
+		"relogins_total",
+		"Number of logins to vCenter after the session expired",
+		stats.UnitDimensionless,
+	)
+
+	// checkpointFailuresM is a counter which records the number of
+	// checkpoints which could not be saved.
+	checkpointFailuresM = stats.Int64(
+		"checkpoint_failures_total",
+		"Number of checkpoints which could not be saved",
+		stats.UnitDimensionless,
+	)
+
+	// reconnectsM is a counter which records the number of reconnects to
+	// vCenter after it could not be reached.
+	reconnectsM = stats.Int64(
+		"reconnects_total",
+		"Number of reconnects to vCenter after it could not be reached",
+		stats.UnitDimensionless,
+	)
+
+	// sessionActiveM is a gauge which records whether the vCenter session
+	// and event stream of the adapter are active (1) or not (0).
+	sessionActiveM = stats.Int64(
+		"session_active",
+		"Whether the vCenter session and event stream are active",
+		stats.UnitDimensionless,
+	)
+
+	// checkpointLagM is a gauge which records the age of the last event of
+	// the last saved checkpoint when it was saved.
+	checkpointLagM = stats.Float64(
+		"checkpoint_lag_seconds",
+		"Age of the last checkpointed event when the checkpoint was saved",
+		stats.UnitSeconds,
+	)
+
+	// eventsInFlightM is a gauge which records the number of events read from
+	// vCenter which are being delivered.
+	eventsInFlightM = stats.Int64(
+		"events_in_flight",
+		"Number of events read from vCenter which are being delivered",
+		stats.UnitDimensionless,
+	)
+
+	eventTypeKey         = tag.MustNewKey("event_type")
+	responseCodeClassKey = tag.MustNewKey(metricskey.LabelResponseCodeClass)
+)
+
+func init() {
+	register()
+}
+
+// statsReporter reports adapter metrics.
+type statsReporter interface {
+	// ReportEventReceived records an event of the given type retrieved from
+	// vCenter.
+	ReportEventReceived(eventType string)
+	// ReportEventDelivered records an event of the given type accepted by
+	// the sink.
+	ReportEventDelivered(eventType string)
+	// ReportEventFailed records an event of the given type rejected by the
+	// sink with the given HTTP status code, zero if no response was received.
+	ReportEventFailed(eventType string, statusCode int)
+	// ReportEventRetried records a retried delivery of an event of the given
+	// type.
+	ReportEventRetried(eventType string)
+	// ReportEventDeduplicated records a replayed event of the given type
+	// skipped as it was delivered before.
+	ReportEventDeduplicated(eventType string)
+	// ReportEventDropped records an event of the given type dropped by the
+	// rate limit.
+	ReportEventDropped(eventType string)
+	// ReportThrottled records the given time spent waiting for the rate
+	// limit.
+	ReportThrottled(d time.Duration)
+	// ReportRelogin records a login to vCenter after the session expired.
+	ReportRelogin()
+	// ReportCheckpointFailure records a checkpoint which could not be saved.
+	ReportCheckpointFailure()
+	// ReportReconnect records a reconnect to vCenter after it could not be
+	// reached.
+	ReportReconnect()
+	// ReportSessionActive records whether the vCenter session and event
+	// stream are active.
+	ReportSessionActive(active bool)
+	// ReportCheckpointLag records the age of the last event of a saved
+	// checkpoint.
+	ReportCheckpointLag(lag time.Duration)
+	// ReportEventsInFlight records the number of events being delivered.
+	ReportEventsInFlight(n int)
+}
+
+var _ statsReporter = (*reporter)(nil)
+
+// reporter reports adapter metrics to the configured metrics backend.
+type reporter struct {
+	// ctx holds the monitored resource of the source, which labels all
+	// metrics
+	ctx context.Context
+}
+
+// newStatsReporter returns a reporter of the metrics of the adapter of the
+// source with the given namespace and name.
+func newStatsReporter(namespace, name string) statsReporter {
+	ctx := metricskey.WithResource(context.Background(), resource.Resource{
+		Type: eventingmetrics.ResourceTypeKnativeSource,
+		Labels: map[string]string{
+			metricskey.LabelNamespaceName:      namespace,
+			eventingmetrics.LabelName:          name,
+			eventingmetrics.LabelResourceGroup: resourceGroup,
+		},
+	})
+	return &reporter{ctx: ctx}
+}
+
+func (r *reporter) ReportEventReceived(eventType string) {
+	r.report(eventsReceivedM, eventType)
+}
+
+func (r *reporter) ReportEventDelivered(eventType string) {
+	r.report(eventsDeliveredM, eventType)
+}
+
+func (r *reporter) ReportEventFailed(eventType string, statusCode int) {
+	r.report(eventsFailedM, eventType,
+		metrics.MaybeInsertStringTag(responseCodeClassKey, metrics.ResponseCodeClass(statusCode), statusCode > 0))
+}
+
+func (r *reporter) ReportEventDeduplicated(eventType string) {
+	r.report(eventsDeduplicatedM, eventType)
+}
+
+func (r *reporter) ReportEventRetried(eventType string) {
+	r.report(eventsRetriedM, eventType)
+}
+
+func (r *reporter) ReportEventDropped(eventType string) {
+	r.report(eventsDroppedM, eventType)
+}
+
+func (r *reporter) ReportThrottled(d time.Duration) {
+	metrics.Record(r.ctx, throttledSecondsM.M(d.Seconds()))
+}
+
+func (r *reporter) ReportRelogin() {
+	metrics.Record(r.ctx, reloginsM.M(1))
+}
+
+func (r *reporter) ReportCheckpointFailure() {
+	metrics.Record(r.ctx, checkpointFailuresM.M(1))
+}
+
+func (r *reporter) ReportReconnect() {
+	metrics.Record(r.ctx, reconnectsM.M(1))
+}
+
+func (r *reporter) ReportSessionActive(active bool) {
+	var v int64
+	if active {
+		v = 1
+	}
+	metrics.Record(r.ctx, sessionActiveM.M(v))
+}
+
+func (r *reporter) ReportCheckpointLag(lag time.Duration) {
+	metrics.Record(r.ctx, checkpointLagM.M(lag.Seconds()))
+}
+
+func (r *reporter) ReportEventsInFlight(n int) {
+	metrics.Record(r.ctx, eventsInFlightM.M(int64(n)))
+}
+
+func (r *reporter) report(m *stats.Int64Measure, eventType string, mutators ...tag.Mutator) {
+	mutators = append(mutators, tag.Insert(eventTypeKey, eventType))
+	ctx, err := tag.New(r.ctx, mutators...)
+	if err != nil {
+		return
+	}
+	metrics.Record(ctx, m.M(1))
+}
+
+func register() {
+	tagKeys := []tag.Key{eventTypeKey}
+
+	if err := metrics.RegisterResourceView(
+		&view.View{
+			Description: eventsReceivedM.Description(),
+			Measure:     eventsReceivedM,
+			Aggregation: view.Count(),
+			TagKeys:     tagKeys,
+		},
+		&view.View{
+			Description: eventsDeliveredM.Description(),
+			Measure:     eventsDeliveredM,
+			Aggregation: view.Count(),
+			TagKeys:     tagKeys,
+		},
+		&view.View{
+			Description: eventsFailedM.Description(),
+			Measure:     eventsFailedM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{eventTypeKey, responseCodeClassKey},
+		},
+		&view.View{
+			Description: eventsDeduplicatedM.Description(),
+			Measure:     eventsDeduplicatedM,
+			Aggregation: view.Count(),
+			TagKeys:     tagKeys,
+		},
+		&view.View{
+			Description: eventsRetriedM.Description(),
+			Measure:     eventsRetriedM,
+			Aggregation: view.Count(),
+			TagKeys:     tagKeys,
+		},
+		&view.View{
+			Description: eventsDroppedM.Description(),
+			Measure:     eventsDroppedM,
+			Aggregation: view.Count(),
+			TagKeys:     tagKeys,
+		},
+		&view.View{
+			Description: throttledSecondsM.Description(),
+			Measure:     throttledSecondsM,
+			Aggregation: view.Sum(),
+		},
+		&view.View{
+			Description: reloginsM.Description(),
+			Measure:     reloginsM,
+			Aggregation: view.Count(),
+		},
+		&view.View{
+			Description: checkpointFailuresM.Description(),
+			Measure:     checkpointFailuresM,
+			Aggregation: view.Count(),
+		},
+		&view.View{
+			Description: reconnectsM.Description(),
+			Measure:     reconnectsM,
+			Aggregation: view.Count(),
+		},
+		&view.View{
+			Description: sessionActiveM.Description(),
+			Measure:     sessionActiveM,
+			Aggregation: view.LastValue(),
+		},
+		&view.View{
+			Description: checkpointLagM.Description(),
+			Measure:     checkpointLagM,
+			Aggregation: view.LastValue(),
+		},
+		&view.View{
+			Description: eventsInFlightM.Description(),
+			Measure:     eventsInFlightM,
+			Aggregation: view.LastValue(),
+		},
+	); err != nil {
+		panic(err)
+	}
+}
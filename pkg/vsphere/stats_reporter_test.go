@@ -0,0 +1,337 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/client"
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/google/go-cmp/cmp"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	"go.opencensus.io/resource"
+	"go.uber.org/zap/zaptest"
+	"knative.dev/pkg/metrics"
+	"knative.dev/pkg/metrics/metricstest"
+)
+
+// fakeStatsReporter counts the reported events by metric and event type
+type fakeStatsReporter struct {
+	sync.Mutex
+	received           map[string]int
+	delivered          map[string]int
+	failed             map[string]int
+	failedStatusCodes  []int
+	deduplicated       map[string]int
+	retried            map[string]int
+	dropped            map[string]int
+	throttled          time.Duration
+	relogins           int
+	checkpointFailures int
+	reconnects         int
+	sessionActive      bool
+	checkpointLag      time.Duration
+	inFlight           int
+	maxInFlight        int
+}
+
+func (r *fakeStatsReporter) ReportEventReceived(eventType string) {
+	r.Lock()
+	defer r.Unlock()
+	if r.received == nil {
+		r.received = make(map[string]int)
+	}
+	r.received[eventType]++
+}
+
+func (r *fakeStatsReporter) ReportEventDelivered(eventType string) {
+	r.Lock()
+	defer r.Unlock()
+	if r.delivered == nil {
+		r.delivered = make(map[string]int)
+	}
+	r.delivered[eventType]++
+}
+
+func (r *fakeStatsReporter) ReportEventFailed(eventType string, statusCode int) {
+	r.Lock()
+	defer r.Unlock()
+	if r.failed == nil {
+		r.failed = make(map[string]int)
+	}
+	r.failed[eventType]++
+	r.failedStatusCodes = append(r.failedStatusCodes, statusCode)
+}
+
+func (r *fakeStatsReporter) ReportEventDeduplicated(eventType string) {
+	r.Lock()
+	defer r.Unlock()
+	if r.deduplicated == nil {
+		r.deduplicated = make(map[string]int)
+	}
+	r.deduplicated[eventType]++
+}
+
+func (r *fakeStatsReporter) ReportEventRetried(eventType string) {
+	r.Lock()
+	defer r.Unlock()
+	if r.retried == nil {
+		r.retried = make(map[string]int)
+	}
+	r.retried[eventType]++
+}
+
+func (r *fakeStatsReporter) ReportEventDropped(eventType string) {
+	r.Lock()
+	defer r.Unlock()
+	if r.dropped == nil {
+		r.dropped = make(map[string]int)
+	}
+	r.dropped[eventType]++
+}
+
+func (r *fakeStatsReporter) ReportThrottled(d time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+	r.throttled += d
+}
+
+func (r *fakeStatsReporter) ReportRelogin() {
+	r.Lock()
+	defer r.Unlock()
+	r.relogins++
+}
+
+func (r *fakeStatsReporter) ReportCheckpointFailure() {
+	r.Lock()
+	defer r.Unlock()
+	r.checkpointFailures++
+}
+
+func (r *fakeStatsReporter) ReportReconnect() {
+	r.Lock()
+	defer r.Unlock()
+	r.reconnects++
+}
+
+func (r *fakeStatsReporter) ReportSessionActive(active bool) {
+	r.Lock()
+	defer r.Unlock()
+	r.sessionActive = active
+}
+
+func (r *fakeStatsReporter) ReportCheckpointLag(lag time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+	r.checkpointLag = lag
+}
+
+func (r *fakeStatsReporter) ReportEventsInFlight(n int) {
+	r.Lock()
+	defer r.Unlock()
+	r.inFlight = n
+	if n > r.maxInFlight {
+		r.maxInFlight = n
+	}
+}
+
+func TestSendEventsStats(t *testing.T) {
+	now := time.Now().UTC()
+	events := createTestEvents(3, source, now)
+	eventType := events.ceEvents[0].Type()
+
+	testCases := map[string]struct {
+		batchSize         int
+		deliveredKey      int32
+		statusCodes       []int
+		wantReceived      map[string]int
+		wantDelivered     map[string]int
+		wantFailed        map[string]int
+		wantFailedCodes   []int
+		wantDeduplicated  map[string]int
+		wantCheckpointLag bool
+	}{
+		"single events, all succeed": {
+			statusCodes:   createStatusCodes(3, failNever),
+			wantReceived:  map[string]int{eventType: 3},
+			wantDelivered: map[string]int{eventType: 3},
+		},
+		"single events, second fails": {
+			statusCodes:     createStatusCodes(3, 1),
+			wantReceived:    map[string]int{eventType: 3},
+			wantDelivered:   map[string]int{eventType: 1},
+			wantFailed:      map[string]int{eventType: 1},
+			wantFailedCodes: []int{500},
+		},
+		"single events, first replayed": {
+			deliveredKey:     events.vEvents[0].GetEvent().Key,
+			statusCodes:      createStatusCodes(2, failNever),
+			wantReceived:     map[string]int{eventType: 3},
+			wantDelivered:    map[string]int{eventType: 2},
+			wantDeduplicated: map[string]int{eventType: 1},
+		},
+		"batch succeeds": {
+			batchSize:     3,
+			statusCodes:   []int{200},
+			wantReceived:  map[string]int{eventType: 3},
+			wantDelivered: map[string]int{eventType: 3},
+		},
+		"batch fails": {
+			batchSize:       3,
+			statusCodes:     []int{500},
+			wantReceived:    map[string]int{eventType: 3},
+			wantFailed:      map[string]int{eventType: 3},
+			wantFailedCodes: []int{500, 500, 500},
+		},
+		"batch, first replayed": {
+			batchSize:        3,
+			deliveredKey:     events.vEvents[0].GetEvent().Key,
+			statusCodes:      []int{200},
+			wantReceived:     map[string]int{eventType: 3},
+			wantDelivered:    map[string]int{eventType: 2},
+			wantDeduplicated: map[string]int{eventType: 1},
+		},
+	}
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			sink := &batchSink{statusCodes: tc.statusCodes}
+			srv := httptest.NewServer(sink)
+			defer srv.Close()
+
+			p, err := cehttp.New(cehttp.WithRoundTripper(&roundTripperTest{statusCodes: tc.statusCodes}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reporter := &fakeStatsReporter{}
+			adapter := vAdapter{
+				Logger:          zaptest.NewLogger(t).Sugar(),
+				CEClient:        c,
+				Source:          source,
+				PayloadEncoding: cloudevents.ApplicationXML,
+				VAPIVersion:     "6.7.0",
+				Sink:            srv.URL,
+				BatchSize:       tc.batchSize,
+				HTTPClient:      srv.Client(),
+				StatsReporter:   reporter,
+				deliveredKey:    tc.deliveredKey,
+			}
+
+			ctx := cecontext.WithTarget(context.Background(), "fake.example.com")
+			_, _ = adapter.sendEvents(ctx, events.vEvents)
+
+			if reporter.maxInFlight != len(events.vEvents) || reporter.inFlight != 0 {
+				t.Errorf("sendEvents() events in flight = %d (max %d), want 0 (max %d)",
+					reporter.inFlight, reporter.maxInFlight, len(events.vEvents))
+			}
+			if diff := cmp.Diff(tc.wantFailedCodes, reporter.failedStatusCodes); diff != "" {
+				t.Errorf("sendEvents() unexpected failure status codes (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tc.wantDeduplicated, reporter.deduplicated); diff != "" {
+				t.Errorf("sendEvents() unexpected deduplicated events (-want, +got) = %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.wantReceived, reporter.received); diff != "" {
+				t.Errorf("sendEvents() unexpected received events (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tc.wantDelivered, reporter.delivered); diff != "" {
+				t.Errorf("sendEvents() unexpected delivered events (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(tc.wantFailed, reporter.failed); diff != "" {
+				t.Errorf("sendEvents() unexpected failed events (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestSetCheckpointLag(t *testing.T) {
+	u, err := url.Parse("https://vcenter.example.com/sdk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reporter := &fakeStatsReporter{}
+	adapter := vAdapter{
+		VClient:       &govmomi.Client{Client: &vim25.Client{Client: soap.NewClient(u, false)}},
+		KVStore:       &fakeKVStore{},
+		StatsReporter: reporter,
+	}
+
+	be := &types.VmPoweredOnEvent{}
+	be.Key = 42
+	be.CreatedTime = time.Now().Add(-time.Minute)
+	if err := adapter.setCheckpoint(context.Background(), be); err != nil {
+		t.Fatalf("setCheckpoint() error = %v", err)
+	}
+
+	if reporter.checkpointLag < time.Minute || reporter.checkpointLag > 2*time.Minute {
+		t.Errorf("setCheckpoint() checkpoint lag = %v, want about %v", reporter.checkpointLag, time.Minute)
+	}
+}
+
+func TestStatsReporterResource(t *testing.T) {
+	metrics.InitForTesting()
+
+	r := newStatsReporter("ns", "stats-reporter-test")
+	r.ReportEventFailed("com.vmware.vsphere.VmPoweredOnEvent.v0", 503)
+	r.ReportEventFailed("com.vmware.vsphere.VmPoweredOnEvent.v0", 0)
+	r.ReportEventDeduplicated("com.vmware.vsphere.VmPoweredOnEvent.v0")
+	r.ReportCheckpointLag(2 * time.Second)
+	r.ReportEventsInFlight(3)
+
+	res := &resource.Resource{
+		Type: "knative_source",
+		Labels: map[string]string{
+			"namespace_name": "ns",
+			"name":           "stats-reporter-test",
+			"resource_group": "vspheresources.sources.tanzu.vmware.com",
+		},
+	}
+	eventType := map[string]string{"event_type": "com.vmware.vsphere.VmPoweredOnEvent.v0"}
+
+	failed := metricstest.IntMetric("events_failed_total", 1, map[string]string{
+		"event_type":          "com.vmware.vsphere.VmPoweredOnEvent.v0",
+		"response_code_class": "5xx",
+	}).WithResource(res)
+	failed.Values = append(failed.Values, metricstest.IntMetric("", 1, eventType).Values...)
+
+	metricstest.EnsureRecorded()
+	for _, want := range []metricstest.Metric{
+		failed,
+		metricstest.IntMetric("events_deduplicated_total", 1, eventType).WithResource(res),
+		metricstest.FloatMetric("checkpoint_lag_seconds", 2, nil).WithResource(res),
+		metricstest.IntMetric("events_in_flight", 3, nil).WithResource(res),
+	} {
+		var got *metricstest.Metric
+		for _, m := range metricstest.GetMetric(want.Name) {
+			if cmp.Equal(m.Resource, res) {
+				m := m
+				got = &m
+			}
+		}
+		if got == nil {
+			t.Errorf("metric %s of the source not recorded", want.Name)
+			continue
+		}
+		if diff := cmp.Diff(want, *got); diff != "" {
+			t.Errorf("metric %s (-want, +got) = %v", want.Name, diff)
+		}
+	}
+}
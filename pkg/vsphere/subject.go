@@ -0,0 +1,34 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/vmware/govmomi/vim25/types"
+	"go.uber.org/zap"
+)
+
+// ParseSubjectTemplate parses the given template of the CloudEvent subject,
+// e.g. {{.Vm.Name}}, which is evaluated against the vSphere event.
+func ParseSubjectTemplate(text string) (*template.Template, error) {
+	return template.New("subject").Parse(text)
+}
+
+// templateSubject returns the CloudEvent subject of the given event from the
+// configured subject template. An empty subject is returned if the template
+// cannot be evaluated against the event, e.g. when it references a field the
+// event does not have, or a nil entity such as Vm.
+func (a *vAdapter) templateSubject(be types.BaseEvent) string {
+	var subject strings.Builder
+	if err := a.SubjectTemplate.Execute(&subject, be); err != nil {
+		a.Logger.Debugw("could not evaluate subject template, omitting subject",
+			zap.String("eventType", getEventDetails(be).Type), zap.Error(err))
+		return ""
+	}
+	return subject.String()
+}
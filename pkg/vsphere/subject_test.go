@@ -0,0 +1,72 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/vmware/govmomi/vim25/types"
+	"go.uber.org/zap/zaptest"
+)
+
+func Test_newCloudEventSubjectTemplate(t *testing.T) {
+	vmEvent := &types.VmPoweredOnEvent{VmEvent: types.VmEvent{Event: types.Event{
+		Key: 42,
+		Vm: &types.VmEventArgument{
+			EntityEventArgument: types.EntityEventArgument{Name: "web-01"},
+			Vm:                  types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-57"},
+		},
+	}}}
+	hostEvent := &types.HostConnectedEvent{HostEvent: types.HostEvent{Event: types.Event{
+		Key: 43,
+		Host: &types.HostEventArgument{
+			EntityEventArgument: types.EntityEventArgument{Name: "esx-01"},
+			Host:                types.ManagedObjectReference{Type: "HostSystem", Value: "host-42"},
+		},
+	}}}
+	alarmEvent := &types.AlarmStatusChangedEvent{AlarmEvent: types.AlarmEvent{Event: types.Event{Key: 44}}}
+
+	tests := []struct {
+		name     string
+		template string
+		event    types.BaseEvent
+		want     string
+	}{
+		{name: "default subject", event: vmEvent, want: "vm-57"},
+		{name: "vm name", template: "{{.Vm.Name}}", event: vmEvent, want: "web-01"},
+		{name: "vm moref", template: "{{.Vm.Vm.Value}}", event: vmEvent, want: "vm-57"},
+		{name: "combined", template: "{{.Vm.Name}}/{{.Key}}", event: vmEvent, want: "web-01/42"},
+		{name: "nil entity", template: "{{.Vm.Name}}", event: hostEvent, want: ""},
+		{name: "missing field", template: "{{.From}}", event: vmEvent, want: ""},
+		{name: "conditional", template: "{{with .Vm}}{{.Name}}{{else}}{{.Host.Name}}{{end}}", event: hostEvent, want: "esx-01"},
+		{name: "event without entity", template: "{{.Vm.Name}}", event: alarmEvent, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := vAdapter{
+				Logger:          zaptest.NewLogger(t).Sugar(),
+				Source:          source,
+				PayloadEncoding: cloudevents.ApplicationXML,
+			}
+			if tt.template != "" {
+				tmpl, err := ParseSubjectTemplate(tt.template)
+				if err != nil {
+					t.Fatalf("ParseSubjectTemplate() error = %v", err)
+				}
+				a.SubjectTemplate = tmpl
+			}
+
+			ev, err := a.newCloudEvent(tt.event)
+			if err != nil {
+				t.Fatalf("newCloudEvent() error = %v", err)
+			}
+			if got := ev.Subject(); got != tt.want {
+				t.Errorf("newCloudEvent() subject = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
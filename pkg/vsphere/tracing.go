@@ -0,0 +1,86 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"fmt"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/extensions"
+	"github.com/vmware/govmomi/vim25/types"
+	"go.opencensus.io/trace"
+)
+
+const (
+	// eventSpanName is the name of the span started for each vSphere event
+	eventSpanName = "vsphere.event"
+	// readSpanName is the name of the span started for each read of the
+	// event collector
+	readSpanName = "vsphere.read"
+
+	// eventKeyAttribute is the span attribute holding the vSphere event key
+	eventKeyAttribute = "vsphere.event.key"
+)
+
+// startEventSpan starts a span for the given vSphere event and injects its
+// context into the cloud event as W3C traceparent. The span is a child of the
+// span in ctx, if any, and must be ended by the caller.
+func startEventSpan(ctx context.Context, be types.BaseEvent, ev *cloudevents.Event) (context.Context, *trace.Span) {
+	ctx, span := trace.StartSpan(ctx, eventSpanName, trace.WithSpanKind(trace.SpanKindClient))
+	if span.IsRecordingEvents() {
+		span.AddAttributes(
+			trace.Int64Attribute(eventKeyAttribute, int64(be.GetEvent().Key)),
+			trace.StringAttribute("cloudevents.id", ev.ID()),
+			trace.StringAttribute("cloudevents.source", ev.Source()),
+			trace.StringAttribute("cloudevents.type", ev.Type()),
+		)
+	}
+
+	extensions.DistributedTracingExtension{
+		TraceParent: traceParent(span.SpanContext()),
+	}.AddTracingAttributes(ev)
+
+	return ctx, span
+}
+
+// endEventSpan ends the given span, recording the delivery result unless the
+// event was acknowledged.
+func endEventSpan(span *trace.Span, result error) {
+	if !cloudevents.IsACK(result) {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: result.Error()})
+	}
+	span.End()
+}
+
+// startReadSpan starts a span for a read of the event collector, which must
+// be ended with endReadSpan.
+func startReadSpan(ctx context.Context) (context.Context, *trace.Span) {
+	return trace.StartSpan(ctx, readSpanName, trace.WithSpanKind(trace.SpanKindClient))
+}
+
+// endReadSpan ends the given span, recording the number and the key range of
+// the read events or the error of the read.
+func endReadSpan(span *trace.Span, events []types.BaseEvent, err error) {
+	if err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+	} else if span.IsRecordingEvents() {
+		span.AddAttributes(trace.Int64Attribute("vsphere.events", int64(len(events))))
+		if len(events) > 0 {
+			span.AddAttributes(
+				trace.Int64Attribute("vsphere.event.key.first", int64(events[0].GetEvent().Key)),
+				trace.Int64Attribute("vsphere.event.key.last", int64(events[len(events)-1].GetEvent().Key)),
+			)
+		}
+	}
+	span.End()
+}
+
+// traceParent returns the W3C traceparent header value of the given span
+// context.
+func traceParent(sc trace.SpanContext) string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.TraceOptions)
+}
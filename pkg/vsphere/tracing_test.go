@@ -0,0 +1,138 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/client"
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestSendEventsTracing(t *testing.T) {
+	events := createTestEvents(2, source, time.Now().UTC())
+
+	roundTripper := &roundTripperTest{statusCodes: createStatusCodes(2, failNever)}
+	p, err := cehttp.New(cehttp.WithRoundTripper(roundTripper))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := client.New(p, client.WithTimeNow(), client.WithUUIDs())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adapter := vAdapter{
+		Logger:          zaptest.NewLogger(t).Sugar(),
+		CEClient:        c,
+		Source:          source,
+		PayloadEncoding: cloudevents.ApplicationXML,
+		VAPIVersion:     "6.7.0",
+		StatsReporter:   &fakeStatsReporter{},
+	}
+
+	ctx := cecontext.WithTarget(context.Background(), "fake.example.com")
+	ctx, parent := trace.StartSpan(ctx, "poll", trace.WithSampler(trace.AlwaysSample()))
+	defer parent.End()
+
+	if _, err := adapter.sendEvents(ctx, events.vEvents); err != nil {
+		t.Fatalf("sendEvents() error = %v", err)
+	}
+
+	if got := len(roundTripper.traceParents); got != 2 {
+		t.Fatalf("sendEvents() got %d events with traceparent, want 2", got)
+	}
+
+	traceID := parent.SpanContext().TraceID.String()
+	seen := make(map[string]bool)
+	for _, tp := range roundTripper.traceParents {
+		parts := strings.Split(tp, "-")
+		if len(parts) != 4 || parts[0] != "00" {
+			t.Fatalf("sendEvents() invalid traceparent %q", tp)
+		}
+		if parts[1] != traceID {
+			t.Errorf("sendEvents() traceparent trace ID = %q, want %q", parts[1], traceID)
+		}
+		if parts[3] != "01" {
+			t.Errorf("sendEvents() traceparent flags = %q, want sampled", parts[3])
+		}
+
+		// every event gets its own span
+		if seen[parts[2]] {
+			t.Errorf("sendEvents() span ID %q used for more than one event", parts[2])
+		}
+		seen[parts[2]] = true
+	}
+}
+
+// spanRecorder is a trace.Exporter recording the exported spans.
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (r *spanRecorder) ExportSpan(s *trace.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+func TestSpanAttributes(t *testing.T) {
+	recorder := &spanRecorder{}
+	trace.RegisterExporter(recorder)
+	defer trace.UnregisterExporter(recorder)
+
+	events := createTestEvents(2, source, time.Now().UTC())
+	ctx, parent := trace.StartSpan(context.Background(), "poll", trace.WithSampler(trace.AlwaysSample()))
+
+	_, readSpan := startReadSpan(ctx)
+	endReadSpan(readSpan, events.vEvents, nil)
+
+	ev := cloudevents.NewEvent()
+	ev.SetID("id")
+	_, eventSpan := startEventSpan(ctx, events.vEvents[1], &ev)
+	endEventSpan(eventSpan, errors.New("delivery failed"))
+	parent.End()
+
+	spans := make(map[string]*trace.SpanData)
+	for _, s := range recorder.spans {
+		spans[s.Name] = s
+	}
+
+	read, ok := spans[readSpanName]
+	if !ok {
+		t.Fatalf("span %q not exported", readSpanName)
+	}
+	wantRead := map[string]interface{}{
+		"vsphere.events":          int64(2),
+		"vsphere.event.key.first": int64(events.vEvents[0].GetEvent().Key),
+		"vsphere.event.key.last":  int64(events.vEvents[1].GetEvent().Key),
+	}
+	if diff := cmp.Diff(wantRead, read.Attributes); diff != "" {
+		t.Errorf("read span attributes (-want, +got) = %v", diff)
+	}
+
+	event, ok := spans[eventSpanName]
+	if !ok {
+		t.Fatalf("span %q not exported", eventSpanName)
+	}
+	if got, want := event.Attributes[eventKeyAttribute], int64(events.vEvents[1].GetEvent().Key); got != want {
+		t.Errorf("event span %s = %v, want %v", eventKeyAttribute, got, want)
+	}
+	if event.Status.Code == trace.StatusCodeOK {
+		t.Error("event span status is OK, want failure")
+	}
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+const (
+	// TypeSchemeNative emits the CloudEvent types of this source, e.g.
+	// com.vmware.vsphere.VmPoweredOnEvent.v0
+	TypeSchemeNative = "native"
+	// TypeSchemeVEB emits the CloudEvent types of the VMware Event Broker,
+	// e.g. com.vmware.event.router/VmPoweredOnEvent
+	TypeSchemeVEB = "veb"
+
+	// VEBEventTypePrefix is the prefix of the CloudEvent type of all vSphere
+	// events in the VMware Event Broker type scheme
+	VEBEventTypePrefix = "com.vmware.event.router"
+)
+
+// VEBEventType returns the CloudEvent type of the given vSphere event type,
+// e.g. VmPoweredOnEvent, in the VMware Event Broker type scheme.
+func VEBEventType(vEventType string) string {
+	return VEBEventTypePrefix + "/" + vEventType
+}
+
+// SchemeEventType returns the CloudEvent type of the given vSphere event type
+// in the given type scheme. The native scheme is used when empty.
+func SchemeEventType(scheme, vEventType string) string {
+	if scheme == TypeSchemeVEB {
+		return VEBEventType(vEventType)
+	}
+	return EventType(vEventType)
+}
+
+// SchemeEventTypePrefix returns the prefix of the CloudEvent type of all
+// vSphere events in the given type scheme. The native scheme is used when
+// empty.
+func SchemeEventTypePrefix(scheme string) string {
+	if scheme == TypeSchemeVEB {
+		return VEBEventTypePrefix
+	}
+	return EventTypePrefix
+}
+
+// eventType returns the CloudEvent type of the given event. In the native
+// scheme, the type depends on the category of the event. The VMware Event
+// Broker has no categories, so tasks and alarms are emitted with the type of
+// their vSphere event.
+func (a *vAdapter) eventType(be types.BaseEvent) string {
+	if a.TypeScheme == TypeSchemeVEB {
+		return VEBEventType(getEventDetails(be).Type)
+	}
+	return a.Categories.eventType(be)
+}
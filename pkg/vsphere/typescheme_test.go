@@ -0,0 +1,66 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vsphere
+
+import (
+	"testing"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestSchemeEventType(t *testing.T) {
+	tests := []struct {
+		scheme     string
+		want       string
+		wantPrefix string
+	}{
+		{scheme: "", want: "com.vmware.vsphere.VmPoweredOnEvent.v0", wantPrefix: "com.vmware.vsphere"},
+		{scheme: TypeSchemeNative, want: "com.vmware.vsphere.VmPoweredOnEvent.v0", wantPrefix: "com.vmware.vsphere"},
+		{scheme: TypeSchemeVEB, want: "com.vmware.event.router/VmPoweredOnEvent", wantPrefix: "com.vmware.event.router"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scheme, func(t *testing.T) {
+			if got := SchemeEventType(tt.scheme, "VmPoweredOnEvent"); got != tt.want {
+				t.Errorf("SchemeEventType() = %q, want %q", got, tt.want)
+			}
+			if got := SchemeEventTypePrefix(tt.scheme); got != tt.wantPrefix {
+				t.Errorf("SchemeEventTypePrefix() = %q, want %q", got, tt.wantPrefix)
+			}
+		})
+	}
+}
+
+func Test_vAdapterEventType(t *testing.T) {
+	task := &types.TaskEvent{Info: types.TaskInfo{DescriptionId: "VirtualMachine.powerOn"}}
+	alarm := &types.AlarmStatusChangedEvent{From: "green", To: "red"}
+	vm := &types.VmPoweredOnEvent{}
+
+	tests := []struct {
+		name   string
+		scheme string
+		event  types.BaseEvent
+		want   string
+	}{
+		{name: "native event", scheme: TypeSchemeNative, event: vm, want: "com.vmware.vsphere.VmPoweredOnEvent.v0"},
+		{name: "native task", scheme: TypeSchemeNative, event: task, want: "com.vmware.vsphere.task.VirtualMachine.powerOn.v0"},
+		{name: "native alarm", scheme: TypeSchemeNative, event: alarm, want: "com.vmware.vsphere.alarm.AlarmStatusChangedEvent.v0"},
+		{name: "veb event", scheme: TypeSchemeVEB, event: vm, want: "com.vmware.event.router/VmPoweredOnEvent"},
+		{name: "veb task", scheme: TypeSchemeVEB, event: task, want: "com.vmware.event.router/TaskEvent"},
+		{name: "veb alarm", scheme: TypeSchemeVEB, event: alarm, want: "com.vmware.event.router/AlarmStatusChangedEvent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newCategories([]string{CategoryEvent, CategoryTask, CategoryAlarm})
+			if err != nil {
+				t.Fatal(err)
+			}
+			a := &vAdapter{Categories: c, TypeScheme: tt.scheme}
+			if got := a.eventType(tt.event); got != tt.want {
+				t.Errorf("eventType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
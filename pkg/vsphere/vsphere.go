@@ -7,7 +7,9 @@ package vsphere
 
 import (
 	"context"
+	"encoding/json"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/vmware/govmomi/event"
@@ -15,7 +17,10 @@ import (
 	"github.com/vmware/govmomi/vim25/types"
 )
 
-func newHistoryCollector(ctx context.Context, client *vim25.Client, begin time.Time) (*event.HistoryCollector, error) {
+// newHistoryCollector returns an event history collector starting at begin, or
+// at the oldest event retained by vCenter when begin is zero. If eventTypes is
+// not empty, only events of the given types are collected.
+func newHistoryCollector(ctx context.Context, client *vim25.Client, begin time.Time, eventTypes []string) (*event.HistoryCollector, error) {
 	mgr := event.NewManager(client)
 	root := client.ServiceContent.RootFolder
 
@@ -25,9 +30,12 @@ func newHistoryCollector(ctx context.Context, client *vim25.Client, begin time.T
 			Entity:    root,
 			Recursion: types.EventFilterSpecRecursionOptionAll,
 		},
-		Time: &types.EventFilterSpecByTime{
+		EventTypeId: eventTypes,
+	}
+	if !begin.IsZero() {
+		filter.Time = &types.EventFilterSpecByTime{
 			BeginTime: types.NewTime(begin),
-		},
+		}
 	}
 
 	return mgr.CreateCollectorForEvents(ctx, filter)
@@ -37,8 +45,10 @@ func newHistoryCollector(ctx context.Context, client *vim25.Client, begin time.T
 // supported event classes: event, eventex, extendedevent. Class to type
 // mapping:
 // event: retrieved from event Class, e.g. VmPoweredOnEvent
-// eventex: retrieved from EventTypeId
+// eventex: retrieved from EventTypeId, e.g. com.vmware.vc.HA.FailoverFailedEvent
 // extendedevent: retrieved from EventTypeId
+// Surrounding whitespace is removed from the EventTypeId. An event without
+// EventTypeId falls back to its class name, i.e. EventEx or ExtendedEvent.
 type eventDetails struct {
 	Class string
 	Type  string
@@ -53,10 +63,10 @@ func getEventDetails(event types.BaseEvent) eventDetails {
 	switch e := event.(type) {
 	case *types.EventEx:
 		details.Class = "eventex"
-		details.Type = e.EventTypeId
+		details.Type = normalizeEventTypeID(e.EventTypeId, "EventEx")
 	case *types.ExtendedEvent:
 		details.Class = "extendedevent"
-		details.Type = e.EventTypeId
+		details.Type = normalizeEventTypeID(e.EventTypeId, "ExtendedEvent")
 	default:
 		t := reflect.TypeOf(event).Elem().Name()
 		details.Class = "event"
@@ -65,3 +75,139 @@ func getEventDetails(event types.BaseEvent) eventDetails {
 
 	return details
 }
+
+// normalizeEventTypeID returns the given EventTypeId without surrounding
+// whitespace or the fallback if it is empty.
+func normalizeEventTypeID(id, fallback string) string {
+	if id = strings.TrimSpace(id); id != "" {
+		return id
+	}
+	return fallback
+}
+
+// EventFilter matches vCenter events by type and, optionally, by the managed
+// object reference value of the entity the event refers to.
+type EventFilter struct {
+	Type   string `json:"type"`
+	Entity string `json:"entity,omitempty"`
+}
+
+// newEventFilters returns the event filters for the given JSON-encoded string.
+// An empty config results in no filters, i.e. all events are emitted.
+func newEventFilters(config string) ([]EventFilter, error) {
+	if config == "" {
+		return nil, nil
+	}
+
+	var filters []EventFilter
+	if err := json.Unmarshal([]byte(config), &filters); err != nil {
+		return nil, err
+	}
+	return filters, nil
+}
+
+// matchEventFilters returns true if the given event matches at least one of
+// the filters or if no filters are specified.
+func matchEventFilters(filters []EventFilter, event types.BaseEvent) bool {
+	if len(filters) == 0 {
+		return true
+	}
+
+	details := getEventDetails(event)
+	for _, f := range filters {
+		if f.Type != details.Type {
+			continue
+		}
+		if f.Entity == "" || hasEntity(event.GetEvent(), f.Entity) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasEntity returns true if the given managed object reference value is one of
+// the entities the event refers to.
+func hasEntity(e *types.Event, moref string) bool {
+	var refs []types.ManagedObjectReference
+
+	if e.Datacenter != nil {
+		refs = append(refs, e.Datacenter.Datacenter)
+	}
+	if e.ComputeResource != nil {
+		refs = append(refs, e.ComputeResource.ComputeResource)
+	}
+	if e.Host != nil {
+		refs = append(refs, e.Host.Host)
+	}
+	if e.Vm != nil {
+		refs = append(refs, e.Vm.Vm)
+	}
+	if e.Ds != nil {
+		refs = append(refs, e.Ds.Datastore)
+	}
+	if e.Net != nil {
+		refs = append(refs, e.Net.Network)
+	}
+	if e.Dvs != nil {
+		refs = append(refs, e.Dvs.Dvs)
+	}
+
+	for _, ref := range refs {
+		if ref.Value == moref {
+			return true
+		}
+	}
+	return false
+}
+
+// eventSubject returns the managed object reference value of the primary
+// entity the event refers to, e.g. vm-42, which is the most specific entity of
+// the event. The object name is returned for an EventEx without object
+// reference. An empty string is returned if the event refers to no entity.
+func eventSubject(be types.BaseEvent) string {
+	switch e := be.(type) {
+	case *types.EventEx:
+		if e.ObjectId != "" {
+			return e.ObjectId
+		}
+	case *types.ExtendedEvent:
+		if e.ManagedObject.Value != "" {
+			return e.ManagedObject.Value
+		}
+	}
+
+	e := be.GetEvent()
+	var refs []types.ManagedObjectReference
+	if e.Vm != nil {
+		refs = append(refs, e.Vm.Vm)
+	}
+	if e.Host != nil {
+		refs = append(refs, e.Host.Host)
+	}
+	if e.Ds != nil {
+		refs = append(refs, e.Ds.Datastore)
+	}
+	if e.Net != nil {
+		refs = append(refs, e.Net.Network)
+	}
+	if e.Dvs != nil {
+		refs = append(refs, e.Dvs.Dvs)
+	}
+	if e.ComputeResource != nil {
+		refs = append(refs, e.ComputeResource.ComputeResource)
+	}
+	if e.Datacenter != nil {
+		refs = append(refs, e.Datacenter.Datacenter)
+	}
+
+	for _, ref := range refs {
+		if ref.Value != "" {
+			return ref.Value
+		}
+	}
+
+	if ex, ok := be.(*types.EventEx); ok {
+		return ex.ObjectName
+	}
+	return ""
+}
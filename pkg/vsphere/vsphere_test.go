@@ -6,10 +6,15 @@ SPDX-License-Identifier: Apache-2.0
 package vsphere
 
 import (
+	"context"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/types"
+	"go.uber.org/zap/zaptest"
 )
 
 func Test_getEventDetails(t *testing.T) {
@@ -49,6 +54,32 @@ func Test_getEventDetails(t *testing.T) {
 				Type:  "tokeninvalid.com.auth.provider.foo",
 			},
 		},
+		{
+			name: "EventEx with surrounding whitespace",
+			args: args{&types.EventEx{
+				EventTypeId: " com.vmware.vc.HA.FailoverFailedEvent\n",
+			}},
+			want: eventDetails{
+				Class: "eventex",
+				Type:  "com.vmware.vc.HA.FailoverFailedEvent",
+			},
+		},
+		{
+			name: "EventEx without EventTypeId",
+			args: args{&types.EventEx{}},
+			want: eventDetails{
+				Class: "eventex",
+				Type:  "EventEx",
+			},
+		},
+		{
+			name: "ExtendedEvent without EventTypeId",
+			args: args{&types.ExtendedEvent{}},
+			want: eventDetails{
+				Class: "extendedevent",
+				Type:  "ExtendedEvent",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -58,3 +89,201 @@ func Test_getEventDetails(t *testing.T) {
 		})
 	}
 }
+
+func Test_eventSubject(t *testing.T) {
+	vm := &types.VmEventArgument{Vm: types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-1234"}}
+	host := &types.HostEventArgument{Host: types.ManagedObjectReference{Type: "HostSystem", Value: "host-42"}}
+	dc := &types.DatacenterEventArgument{Datacenter: types.ManagedObjectReference{Type: "Datacenter", Value: "datacenter-2"}}
+
+	tests := []struct {
+		name  string
+		event types.BaseEvent
+		want  string
+	}{
+		{
+			name: "VmEvent",
+			event: &types.VmPoweredOnEvent{VmEvent: types.VmEvent{Event: types.Event{
+				Vm: vm, Host: host, Datacenter: dc,
+			}}},
+			want: "vm-1234",
+		},
+		{
+			name: "HostEvent",
+			event: &types.HostConnectionLostEvent{HostEvent: types.HostEvent{Event: types.Event{
+				Host: host, Datacenter: dc,
+			}}},
+			want: "host-42",
+		},
+		{
+			name: "DatacenterEvent",
+			event: &types.DatacenterRenamedEvent{DatacenterEvent: types.DatacenterEvent{Event: types.Event{
+				Datacenter: dc,
+			}}},
+			want: "datacenter-2",
+		},
+		{
+			name: "EventEx with object reference",
+			event: &types.EventEx{
+				Event:      types.Event{Datacenter: dc},
+				ObjectId:   "vm-77",
+				ObjectType: "VirtualMachine",
+				ObjectName: "vm-01",
+			},
+			want: "vm-77",
+		},
+		{
+			name: "EventEx with entity",
+			event: &types.EventEx{
+				Event:      types.Event{Vm: vm},
+				ObjectName: "vm-01",
+			},
+			want: "vm-1234",
+		},
+		{
+			name: "EventEx with object name only",
+			event: &types.EventEx{
+				ObjectName: "com.vmware.license",
+			},
+			want: "com.vmware.license",
+		},
+		{
+			name: "ExtendedEvent",
+			event: &types.ExtendedEvent{
+				ManagedObject: types.ManagedObjectReference{Type: "HostSystem", Value: "host-43"},
+			},
+			want: "host-43",
+		},
+		{
+			name:  "no entity",
+			event: &types.SessionTerminatedEvent{},
+			want:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := eventSubject(tt.event); got != tt.want {
+				t.Errorf("eventSubject() = %q, want %q", got, tt.want)
+			}
+
+			a := vAdapter{Logger: zaptest.NewLogger(t).Sugar(), PayloadEncoding: "application/json", Source: "vcenter.local"}
+			ev, err := a.newCloudEvent(tt.event)
+			if err != nil {
+				t.Fatalf("newCloudEvent() error = %v", err)
+			}
+			if got := ev.Context.GetSubject(); got != tt.want {
+				t.Errorf("newCloudEvent() subject = %q, want %q", got, tt.want)
+			}
+			// an unset subject is omitted from the event
+			if tt.want == "" && ev.Context.AsV1().Subject != nil {
+				t.Errorf("newCloudEvent() subject = %q, want unset", *ev.Context.AsV1().Subject)
+			}
+		})
+	}
+}
+
+func Test_matchEventFilters(t *testing.T) {
+	vmEvent := &types.VmPoweredOnEvent{
+		VmEvent: types.VmEvent{
+			Event: types.Event{
+				Vm: &types.VmEventArgument{
+					Vm: types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-42"},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		filters []EventFilter
+		event   types.BaseEvent
+		want    bool
+	}{
+		{
+			name:    "no filters",
+			filters: nil,
+			event:   vmEvent,
+			want:    true,
+		},
+		{
+			name:    "type matches",
+			filters: []EventFilter{{Type: "VmPoweredOffEvent"}, {Type: "VmPoweredOnEvent"}},
+			event:   vmEvent,
+			want:    true,
+		},
+		{
+			name:    "type does not match",
+			filters: []EventFilter{{Type: "VmPoweredOffEvent"}},
+			event:   vmEvent,
+			want:    false,
+		},
+		{
+			name:    "type and entity match",
+			filters: []EventFilter{{Type: "VmPoweredOnEvent", Entity: "vm-42"}},
+			event:   vmEvent,
+			want:    true,
+		},
+		{
+			name:    "type matches but entity does not",
+			filters: []EventFilter{{Type: "VmPoweredOnEvent", Entity: "vm-7"}},
+			event:   vmEvent,
+			want:    false,
+		},
+		{
+			name:    "EventEx type matches",
+			filters: []EventFilter{{Type: "com.vmware.vc.VmDiskConsolidatedEvent"}},
+			event:   &types.EventEx{EventTypeId: "com.vmware.vc.VmDiskConsolidatedEvent"},
+			want:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchEventFilters(tt.filters, tt.event); got != tt.want {
+				t.Errorf("matchEventFilters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newHistoryCollector(t *testing.T) {
+	tests := []struct {
+		name       string
+		eventTypes []string
+	}{
+		{
+			name:       "all events",
+			eventTypes: nil,
+		},
+		{
+			name:       "only VmPoweredOnEvent",
+			eventTypes: []string{"VmPoweredOnEvent"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			simulator.Run(func(ctx context.Context, vim *vim25.Client) error {
+				begin := time.Now().UTC().Add(time.Hour * -1)
+				c, err := newHistoryCollector(ctx, vim, begin, tt.eventTypes)
+				if err != nil {
+					t.Fatalf("newHistoryCollector() error = %v", err)
+				}
+
+				events, err := c.ReadNextEvents(ctx, maxEventsBatch)
+				if err != nil {
+					t.Fatalf("ReadNextEvents() error = %v", err)
+				}
+
+				if len(events) == 0 {
+					t.Fatal("ReadNextEvents() returned no events")
+				}
+
+				for _, e := range events {
+					details := getEventDetails(e)
+					if len(tt.eventTypes) > 0 && details.Type != tt.eventTypes[0] {
+						t.Errorf("ReadNextEvents() unexpected event type %q, want %q", details.Type, tt.eventTypes[0])
+					}
+				}
+				return nil
+			})
+		})
+	}
+}
@@ -21,11 +21,11 @@ import (
 func main() {
 	clients, err := pkg.NewClients(os.Getenv("KUBECONFIG"))
 	if err != nil {
-		fmt.Printf("ERROR: %v\n", err)
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
 		os.Exit(1)
 	}
 	if err = root.NewRootCommand(clients).Execute(); err != nil {
-		fmt.Printf("ERROR: %v\n", err)
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
 		os.Exit(1)
 	}
 }
@@ -40,6 +40,7 @@ func NewBindingCommand(clients *pkg.Clients) *cobra.Command {
 	result.AddCommand(NewBindingCreateCommand(clients, &options))
 	result.AddCommand(NewBindingDeleteCommand(clients, &options))
 	result.AddCommand(NewBindingListCommand(clients, &options))
+	result.AddCommand(NewBindingCheckCommand(clients, &options))
 
 	return &result
 }
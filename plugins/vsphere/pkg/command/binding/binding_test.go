@@ -33,10 +33,11 @@ func TestNewBindingCommand(t *testing.T) {
 			"command should have a nonempty long description")
 		command.CheckFlag(t, cmd, "namespace")
 
-		assert.Check(t, len(cmd.Commands()) == 3, "unexpected number of subcommands")
+		assert.Check(t, len(cmd.Commands()) == 4, "unexpected number of subcommands")
 		assert.Check(t, command.HasLeafCommand(cmd, "create"), "command should have subcommand create")
 		assert.Check(t, command.HasLeafCommand(cmd, "delete"), "command should have subcommand delete")
 		assert.Check(t, command.HasLeafCommand(cmd, "list"), "command should have subcommand delete")
+		assert.Check(t, command.HasLeafCommand(cmd, "check"), "command should have subcommand check")
 	})
 }
 
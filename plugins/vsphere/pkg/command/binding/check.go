@@ -0,0 +1,197 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package binding
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware/govmomi/event"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
+	"github.com/vmware-tanzu/sources-for-knative/plugins/vsphere/pkg"
+	"github.com/vmware-tanzu/sources-for-knative/plugins/vsphere/pkg/command/flags"
+)
+
+// Stages of a connectivity check, reported when the check fails.
+const (
+	CheckStageConfig  = "config"
+	CheckStageConnect = "connect"
+	CheckStageLogin   = "login"
+	CheckStageEvents  = "events"
+)
+
+// CheckResult is the machine-readable result of a connectivity check.
+type CheckResult struct {
+	Address string `json:"address"`
+	Success bool   `json:"success"`
+	// Stage is the stage of the check which failed
+	Stage string `json:"stage,omitempty"`
+	Error string `json:"error,omitempty"`
+	// LatestEventKey is the key of the latest vCenter event, if any
+	LatestEventKey *int32 `json:"latestEventKey,omitempty"`
+}
+
+// errCheckFailed is returned after the failed result is printed so that the
+// command exits with a non-zero exit code.
+var errCheckFailed = errors.New("vCenter connectivity check failed")
+
+func NewBindingCheckCommand(clients *pkg.Clients, opts *Options) *cobra.Command {
+	var timeout time.Duration
+
+	result := cobra.Command{
+		Use:   "check",
+		Short: "Check the vCenter connectivity of a vSphere binding",
+		Long: `Check the vCenter connectivity of a vSphere binding
+
+Logs in to vCenter with the address and credentials of an existing binding, or
+of a binding about to be created, and queries the latest event. The result is
+printed as JSON and the command exits with a non-zero exit code on failure.`,
+		Example: `# Check an existing binding in the default namespace
+kn vsphere binding check --name vc-binding
+
+# Check an address and credentials before creating a binding
+kn vsphere binding check --vc-address https://my-vsphere-endpoint.local --secret-ref vsphere-credentials
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if opts.Name == "" && (opts.VCAddress == "" || opts.SecretRef == "") {
+				return fmt.Errorf("'check' requires the name of a binding provided with the --name option," +
+					"\nor an address and a secret reference provided with the --vc-address and --secret-ref options")
+			}
+			if !flags.MutuallyExclusiveStringFlags(opts.Name, opts.VCAddress) {
+				return fmt.Errorf("the binding to check can optionally be configured with one of the following flags (but several were set):\n\t" +
+					"--name, --vc-address")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			namespace, err := clients.GetExplicitOrDefaultNamespace(opts.Namespace)
+			if err != nil {
+				return fmt.Errorf("failed to get namespace: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			defer cancel()
+
+			res := checkBinding(ctx, clients, namespace, *opts)
+
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(res); err != nil {
+				return fmt.Errorf("failed to print check result: %v", err)
+			}
+
+			if !res.Success {
+				cmd.SilenceUsage = true
+				return errCheckFailed
+			}
+			return nil
+		},
+	}
+
+	fl := result.Flags()
+	fl.StringVar(&opts.Name, "name", "", "name of the binding to check (cannot be used with --vc-address)")
+	fl.StringVarP(&opts.VCAddress, "vc-address", "a", "", "URL of the vCenter instance to check (cannot be used with --name)")
+	fl.BoolVarP(&opts.SkipTLSVerify, "skip-tls-verify", "k", false, "disables certificate verification for the vCenter address")
+	fl.StringVarP(&opts.SecretRef, "secret-ref", "s", "", "reference to the Kubernetes secret for the vSphere credentials")
+	fl.DurationVar(&timeout, "timeout", 30*time.Second, "timeout of the check")
+
+	return &result
+}
+
+// checkBinding logs in to vCenter with the address and credentials of the
+// binding described by the options and queries the latest event.
+func checkBinding(ctx context.Context, clients *pkg.Clients, namespace string, opts Options) CheckResult {
+	res := CheckResult{Address: opts.VCAddress}
+	fail := func(stage string, err error) CheckResult {
+		res.Stage = stage
+		res.Error = err.Error()
+		return res
+	}
+
+	auth := v1alpha1.VAuthSpec{
+		SkipTLSVerify: opts.SkipTLSVerify,
+		SecretRef:     corev1.LocalObjectReference{Name: opts.SecretRef},
+	}
+	if opts.Name != "" {
+		binding, err := clients.VSphereClientSet.SourcesV1alpha1().VSphereBindings(namespace).Get(ctx, opts.Name, metav1.GetOptions{})
+		if err != nil {
+			return fail(CheckStageConfig, fmt.Errorf("failed to get binding: %w", err))
+		}
+		auth = binding.Spec.VAuthSpec
+		res.Address = auth.Address.String()
+	}
+	auth.SetDefaults(ctx)
+
+	vcURL, err := soap.ParseURL(res.Address)
+	if err != nil {
+		return fail(CheckStageConfig, fmt.Errorf("failed to parse vCenter URL: %w", err))
+	}
+
+	secret, err := clients.ClientSet.CoreV1().Secrets(namespace).Get(ctx, auth.SecretRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return fail(CheckStageConfig, fmt.Errorf("failed to get credentials: %w", err))
+	}
+	user := url.UserPassword(
+		string(secret.Data[auth.UsernameKey]),
+		string(secret.Data[auth.PasswordKey]),
+	)
+
+	soapClient := soap.NewClient(vcURL, auth.SkipTLSVerify)
+	if auth.CABundle != nil || auth.CACertKey != "" {
+		pool := x509.NewCertPool()
+		if cab := auth.CABundle; cab != nil {
+			cm, err := clients.ClientSet.CoreV1().ConfigMaps(namespace).Get(ctx, cab.Name, metav1.GetOptions{})
+			if err != nil {
+				return fail(CheckStageConfig, fmt.Errorf("failed to get CA bundle: %w", err))
+			}
+			if !pool.AppendCertsFromPEM([]byte(cm.Data[cab.Key])) {
+				return fail(CheckStageConfig, fmt.Errorf("no PEM encoded certificates in key %q of configmap %q", cab.Key, cab.Name))
+			}
+		}
+		if key := auth.CACertKey; key != "" {
+			if !pool.AppendCertsFromPEM(secret.Data[key]) {
+				return fail(CheckStageConfig, fmt.Errorf("no PEM encoded certificates in key %q of secret %q", key, auth.SecretRef.Name))
+			}
+		}
+		soapClient.DefaultTransport().TLSClientConfig.RootCAs = pool
+	}
+
+	vimClient, err := vim25.NewClient(ctx, soapClient)
+	if err != nil {
+		return fail(CheckStageConnect, fmt.Errorf("failed to connect to vCenter: %w", err))
+	}
+
+	mgr := session.NewManager(vimClient)
+	if err := mgr.Login(ctx, user); err != nil {
+		return fail(CheckStageLogin, fmt.Errorf("failed to authenticate with vCenter: %w", err))
+	}
+	defer func() { _ = mgr.Logout(context.Background()) }()
+
+	events, err := event.NewManager(vimClient).QueryEvents(ctx, types.EventFilterSpec{MaxCount: 1})
+	if err != nil {
+		return fail(CheckStageEvents, fmt.Errorf("failed to query events: %w", err))
+	}
+	if len(events) > 0 {
+		key := events[0].GetEvent().Key
+		res.LatestEventKey = &key
+	}
+
+	res.Success = true
+	return res
+}
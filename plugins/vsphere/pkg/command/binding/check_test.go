@@ -0,0 +1,250 @@
+/*
+Copyright 2022 VMware, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package binding_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vmware-tanzu/sources-for-knative/pkg/apis/sources/v1alpha1"
+	vspherefake "github.com/vmware-tanzu/sources-for-knative/pkg/client/clientset/versioned/fake"
+	"github.com/vmware-tanzu/sources-for-knative/plugins/vsphere/pkg"
+	"github.com/vmware-tanzu/sources-for-knative/plugins/vsphere/pkg/command"
+	"github.com/vmware-tanzu/sources-for-knative/plugins/vsphere/pkg/command/binding"
+)
+
+func TestNewCheckCommand(t *testing.T) {
+	const (
+		secretRef = "vsphere-credentials"
+		username  = "fxmulder"
+		password  = "trustno1"
+	)
+
+	credentials := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: command.DefaultNamespace,
+			Name:      secretRef,
+		},
+		Type: corev1.SecretTypeBasicAuth,
+		Data: map[string][]byte{
+			corev1.BasicAuthUsernameKey: []byte(username),
+			corev1.BasicAuthPasswordKey: []byte(password),
+		},
+	}
+
+	// vcAddress returns the address of the simulator without credentials
+	vcAddress := func(vc *vim25.Client) string {
+		u := *vc.URL()
+		u.User = nil
+		return u.String()
+	}
+
+	t.Run("defines basic metadata", func(t *testing.T) {
+		cmd := binding.NewBindingCheckCommand(&pkg.Clients{}, &binding.Options{})
+
+		assert.Equal(t, cmd.Use, "check")
+		assert.Check(t, len(cmd.Short) > 0,
+			"command should have a nonempty short description")
+		assert.Check(t, len(cmd.Long) > 0,
+			"command should have a nonempty long description")
+		command.CheckFlag(t, cmd, "name")
+		command.CheckFlag(t, cmd, "vc-address")
+		command.CheckFlag(t, cmd, "skip-tls-verify")
+		command.CheckFlag(t, cmd, "secret-ref")
+		command.CheckFlag(t, cmd, "timeout")
+	})
+
+	t.Run("fails without binding name or address", func(t *testing.T) {
+		cmd, _ := checkTestCommand(nil)
+		cmd.SetArgs([]string{
+			"check",
+			"--vc-address", "https://my-vsphere-endpoint.example.com",
+		})
+
+		err := cmd.Execute()
+		assert.ErrorContains(t, err, "'check' requires the name of a binding")
+	})
+
+	t.Run("fails with both binding name and address", func(t *testing.T) {
+		cmd, _ := checkTestCommand(nil)
+		cmd.SetArgs([]string{
+			"check",
+			"--name", "vc-binding",
+			"--vc-address", "https://my-vsphere-endpoint.example.com",
+			"--secret-ref", secretRef,
+		})
+
+		err := cmd.Execute()
+		assert.ErrorContains(t, err, "--name, --vc-address")
+	})
+
+	t.Run("reports missing credentials", func(t *testing.T) {
+		cmd, out := checkTestCommand(nil)
+		cmd.SetArgs([]string{
+			"check",
+			"--vc-address", "https://my-vsphere-endpoint.example.com",
+			"--secret-ref", secretRef,
+		})
+
+		err := cmd.Execute()
+		assert.ErrorContains(t, err, "connectivity check failed")
+
+		res := checkResult(t, out)
+		assert.Equal(t, res.Success, false)
+		assert.Equal(t, res.Stage, binding.CheckStageConfig)
+		assert.Check(t, is.Contains(res.Error, `failed to get credentials: secrets "vsphere-credentials" not found`))
+	})
+
+	t.Run("reports untrusted certificate", func(t *testing.T) {
+		simulator.Run(func(ctx context.Context, vc *vim25.Client) error {
+			cmd, out := checkTestCommand([]runtime.Object{credentials})
+			cmd.SetArgs([]string{
+				"check",
+				"--vc-address", vcAddress(vc),
+				"--secret-ref", secretRef,
+			})
+
+			err := cmd.Execute()
+			assert.ErrorContains(t, err, "connectivity check failed")
+
+			res := checkResult(t, out)
+			assert.Equal(t, res.Stage, binding.CheckStageConnect)
+			assert.Check(t, is.Contains(res.Error, "x509: certificate signed by unknown authority"))
+			return nil
+		})
+	})
+
+	t.Run("reports login failure", func(t *testing.T) {
+		model := simulator.VPX()
+		defer model.Remove()
+		assert.NilError(t, model.Create())
+
+		model.Service.Listen = &url.URL{
+			User: url.UserPassword("not-my-username", password),
+		}
+
+		simulator.Run(func(ctx context.Context, vc *vim25.Client) error {
+			cmd, out := checkTestCommand([]runtime.Object{credentials})
+			cmd.SetArgs([]string{
+				"check",
+				"--vc-address", vcAddress(vc),
+				"--secret-ref", secretRef,
+				"--skip-tls-verify",
+			})
+
+			err := cmd.Execute()
+			assert.ErrorContains(t, err, "connectivity check failed")
+
+			res := checkResult(t, out)
+			assert.Equal(t, res.Stage, binding.CheckStageLogin)
+			assert.Check(t, is.Contains(res.Error, "failed to authenticate with vCenter: ServerFaultCode: Login failure"))
+			return nil
+		}, model)
+	})
+
+	t.Run("succeeds with address and credentials", func(t *testing.T) {
+		simulator.Run(func(ctx context.Context, vc *vim25.Client) error {
+			cmd, out := checkTestCommand([]runtime.Object{credentials})
+			cmd.SetArgs([]string{
+				"check",
+				"--vc-address", vcAddress(vc),
+				"--secret-ref", secretRef,
+				"--skip-tls-verify",
+			})
+
+			assert.NilError(t, cmd.Execute())
+
+			res := checkResult(t, out)
+			assert.Equal(t, res.Success, true)
+			assert.Equal(t, res.Address, vcAddress(vc))
+			assert.Equal(t, res.Stage, "")
+			assert.Check(t, res.LatestEventKey != nil, "latest event key should be reported")
+			return nil
+		})
+	})
+
+	t.Run("succeeds with existing binding", func(t *testing.T) {
+		simulator.Run(func(ctx context.Context, vc *vim25.Client) error {
+			bnd := newBinding(t, command.DefaultNamespace, "vc-binding", vcAddress(vc), secretRef, "apps/v1", "Deployment", "my-simple-app").(*v1alpha1.VSphereBinding)
+			bnd.Spec.SkipTLSVerify = true
+
+			cmd, out := checkTestCommand([]runtime.Object{credentials}, bnd)
+			cmd.SetArgs([]string{
+				"check",
+				"--name", "vc-binding",
+			})
+
+			assert.NilError(t, cmd.Execute())
+
+			res := checkResult(t, out)
+			assert.Equal(t, res.Success, true)
+			assert.Equal(t, res.Address, vcAddress(vc))
+			return nil
+		})
+	})
+
+	t.Run("succeeds with configured secret keys", func(t *testing.T) {
+		simulator.Run(func(ctx context.Context, vc *vim25.Client) error {
+			esoCredentials := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: command.DefaultNamespace,
+					Name:      "eso-credentials",
+				},
+				Data: map[string][]byte{
+					"user": []byte(username),
+					"pass": []byte(password),
+				},
+			}
+			bnd := newBinding(t, command.DefaultNamespace, "vc-binding", vcAddress(vc), "eso-credentials", "apps/v1", "Deployment", "my-simple-app").(*v1alpha1.VSphereBinding)
+			bnd.Spec.SkipTLSVerify = true
+			bnd.Spec.UsernameKey = "user"
+			bnd.Spec.PasswordKey = "pass"
+
+			cmd, out := checkTestCommand([]runtime.Object{esoCredentials}, bnd)
+			cmd.SetArgs([]string{
+				"check",
+				"--name", "vc-binding",
+			})
+
+			assert.NilError(t, cmd.Execute())
+			assert.Equal(t, checkResult(t, out).Success, true)
+			return nil
+		})
+	})
+}
+
+func checkTestCommand(kubeObjects []runtime.Object, objects ...runtime.Object) (*cobra.Command, *bytes.Buffer) {
+	cmd := binding.NewBindingCommand(&pkg.Clients{
+		ClientSet:        k8sfake.NewSimpleClientset(kubeObjects...),
+		ClientConfig:     command.RegularClientConfig(),
+		VSphereClientSet: vspherefake.NewSimpleClientset(objects...),
+	})
+	out := &bytes.Buffer{}
+	cmd.SetErr(ioutil.Discard)
+	cmd.SetOut(out)
+	return cmd, out
+}
+
+func checkResult(t *testing.T, out *bytes.Buffer) binding.CheckResult {
+	t.Helper()
+	var res binding.CheckResult
+	assert.NilError(t, json.Unmarshal(out.Bytes(), &res), "output should be JSON: %s", out.String())
+	return res
+}